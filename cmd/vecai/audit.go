@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abdul-hamid-achik/vecai/internal/audit"
+	"github.com/abdul-hamid-achik/vecai/internal/config"
+)
+
+// handleAuditCommand handles the "audit" subcommand
+func handleAuditCommand(cfg *config.Config, args []string, jsonOut bool) error {
+	sub := "list"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		sub, args = args[0], args[1:]
+	}
+	if sub == "help" || sub == "--help" || sub == "-h" {
+		return auditHelp()
+	}
+
+	dir := cfg.Audit.Dir
+	if dir == "" {
+		dir = audit.DefaultDir
+	}
+
+	if sub == "verify" {
+		n, err := audit.Verify(dir)
+		if err != nil {
+			return fmt.Errorf("audit log tampered or corrupt after %d good records: %w", n, err)
+		}
+		fmt.Printf("Audit log intact: %d records verified\n", n)
+		return nil
+	}
+
+	filter, err := parseAuditFilter(args)
+	if err != nil {
+		return err
+	}
+	records, err := audit.Read(dir, filter)
+	if err != nil {
+		return err
+	}
+
+	switch sub {
+	case "list":
+		if jsonOut {
+			enc := json.NewEncoder(os.Stdout)
+			for _, rec := range records {
+				if err := enc.Encode(rec); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		auditList(records)
+	case "summary":
+		summary := audit.Summarize(records)
+		if jsonOut {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(summary)
+		}
+		auditSummary(summary)
+	default:
+		return fmt.Errorf("unknown audit subcommand: %s. Use 'vecai audit help' for usage", sub)
+	}
+	return nil
+}
+
+// auditHelp shows help for the audit subcommand
+func auditHelp() error {
+	fmt.Print(`vecai audit - Inspect the tool audit log (.vecai/audit/)
+
+Usage:
+  vecai audit [list] [filters]    List audited tool calls
+  vecai audit summary [filters]   Summarize by session, tool and decision
+  vecai audit verify              Check the hash chain for tampering
+  vecai audit help                Show this help
+
+Filters:
+  --session <id>          Session ID or prefix
+  --tool <name>           Tool name
+  --since <time>          RFC 3339 time, date (2006-01-02) or age (24h, 7d)
+  --until <time>          Same formats as --since
+  --json                  Output JSON (one record per line for list)
+
+Examples:
+  vecai audit --tool bash --since 24h
+  vecai audit summary --session 3fa2c1d0
+  vecai audit verify
+`)
+	return nil
+}
+
+// parseAuditFilter parses the filter flags of list and summary
+func parseAuditFilter(args []string) (audit.Filter, error) {
+	var f audit.Filter
+	for i := 0; i < len(args); i++ {
+		name, value, hasValue := strings.Cut(args[i], "=")
+		if !hasValue {
+			if i+1 >= len(args) {
+				return f, fmt.Errorf("%s requires a value", name)
+			}
+			i++
+			value = args[i]
+		}
+
+		var err error
+		switch name {
+		case "--session":
+			f.SessionID = value
+		case "--tool":
+			f.Tool = value
+		case "--since":
+			f.Since, err = parseAuditTime(value)
+		case "--until":
+			f.Until, err = parseAuditTime(value)
+		default:
+			return f, fmt.Errorf("unknown audit flag: %s", name)
+		}
+		if err != nil {
+			return f, fmt.Errorf("invalid %s: %w", name, err)
+		}
+	}
+	return f, nil
+}
+
+// parseAuditTime accepts RFC 3339 times, local dates and ages like 24h or 7d
+func parseAuditTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil {
+			return time.Now().AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("%q is not a time, date or age", s)
+}
+
+// auditList prints one line per record
+func auditList(records []audit.Record) {
+	if len(records) == 0 {
+		fmt.Println("No audit records.")
+		return
+	}
+	for _, rec := range records {
+		decision := rec.Decision
+		if rec.DecidedBy != "" {
+			decision += " (" + rec.DecidedBy + ")"
+		}
+		if rec.Error {
+			decision += ", failed"
+		}
+		fmt.Printf("%s  %-8.8s  %-16s %-18s %s\n",
+			rec.Time.Local().Format("2006-01-02 15:04:05"), rec.SessionID, rec.Tool, decision, auditInputSummary(rec.Input))
+	}
+}
+
+// auditInputSummary renders the most telling input field on one line
+func auditInputSummary(input map[string]any) string {
+	for _, key := range []string{"command", "path", "file_path", "query", "pattern"} {
+		if v, ok := input[key].(string); ok && v != "" {
+			v = strings.Join(strings.Fields(v), " ")
+			if len(v) > 60 {
+				v = v[:57] + "..."
+			}
+			return v
+		}
+	}
+	return ""
+}
+
+// auditSummary prints aggregate counts
+func auditSummary(s audit.Summary) {
+	if s.Total == 0 {
+		fmt.Println("No audit records.")
+		return
+	}
+	fmt.Printf("Records:  %d (%s to %s)\n", s.Total,
+		s.First.Local().Format("2006-01-02 15:04"), s.Last.Local().Format("2006-01-02 15:04"))
+	fmt.Printf("Failures: %d tool errors\n", s.ToolErrors)
+	printCounts("Sessions", s.Sessions)
+	printCounts("Tools", s.Tools)
+	printCounts("Decisions", s.Decisions)
+	printCounts("Decided by", s.DecidedBy)
+}
+
+// printCounts prints a count table, largest first
+func printCounts(title string, counts map[string]int) {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	fmt.Printf("\n%s:\n", title)
+	for _, k := range keys {
+		name := k
+		if name == "" {
+			name = "(none)"
+		}
+		fmt.Printf("  %-24s %d\n", name, counts[k])
+	}
+}
@@ -12,10 +12,13 @@ import (
 	"time"
 
 	"github.com/abdul-hamid-achik/vecai/internal/agent"
+	"github.com/abdul-hamid-achik/vecai/internal/commands"
 	"github.com/abdul-hamid-achik/vecai/internal/config"
 	"github.com/abdul-hamid-achik/vecai/internal/debug"
+	"github.com/abdul-hamid-achik/vecai/internal/hooks"
 	"github.com/abdul-hamid-achik/vecai/internal/llm"
 	"github.com/abdul-hamid-achik/vecai/internal/logging"
+	"github.com/abdul-hamid-achik/vecai/internal/mcp"
 	"github.com/abdul-hamid-achik/vecai/internal/permissions"
 	"github.com/abdul-hamid-achik/vecai/internal/skills"
 	"github.com/abdul-hamid-achik/vecai/internal/tools"
@@ -131,9 +134,9 @@ func run() error {
 		}
 	}
 
-	// Parse prompt flag (-p/--prompt) and JSON output flag (--json)
+	// Parse prompt flag (-p/--prompt) and output format (--json, --output-format)
 	promptFlag := ""
-	jsonMode := false
+	outputFormat := ""
 	for i := 0; i < len(args); i++ {
 		if (args[i] == "-p" || args[i] == "--prompt") && i+1 < len(args) {
 			promptFlag = args[i+1]
@@ -148,11 +151,30 @@ func run() error {
 			continue
 		}
 		if args[i] == "--json" {
-			jsonMode = true
+			outputFormat = "json"
+			args = append(args[:i], args[i+1:]...)
+			i--
+			continue
+		}
+		if args[i] == "--output-format" && i+1 < len(args) {
+			outputFormat = args[i+1]
+			args = append(args[:i], args[i+2:]...)
+			i--
+			continue
+		}
+		if strings.HasPrefix(args[i], "--output-format=") {
+			outputFormat = strings.TrimPrefix(args[i], "--output-format=")
 			args = append(args[:i], args[i+1:]...)
 			i--
 		}
 	}
+	switch outputFormat {
+	case "", "text", "json", "stream-json":
+	default:
+		return fmt.Errorf("unknown output format %q (use text, json or stream-json)", outputFormat)
+	}
+	jsonMode := outputFormat == "json"
+	headless := promptFlag != "" || outputFormat == "json" || outputFormat == "stream-json"
 
 	// Parse CLI flags
 	var loadOpts config.LoadOptions
@@ -189,6 +211,16 @@ func run() error {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
+	// Handle audit subcommand (no provider needed)
+	if len(args) > 0 && args[0] == "audit" {
+		return handleAuditCommand(cfg, args[1:], jsonMode)
+	}
+
+	// Handle router subcommand (no provider needed)
+	if len(args) > 0 && args[0] == "router" {
+		return handleRouterCommand(cfg, args[1:], jsonMode)
+	}
+
 	// Determine permission mode and analysis mode
 	permMode := permissions.ModeAsk
 	analysisMode := cfg.Analysis.Enabled // Default from config
@@ -203,6 +235,10 @@ func run() error {
 			permMode = permissions.ModeStrict
 			args = append(args[:i], args[i+1:]...)
 			i--
+		case "--worktree":
+			cfg.Agent.WorktreeIsolation = true
+			args = append(args[:i], args[i+1:]...)
+			i--
 		case "--analyze", "-a":
 			analysisMode = true
 			permMode = permissions.ModeAnalysis
@@ -211,19 +247,71 @@ func run() error {
 		}
 	}
 
+	// Parse permission answers for runs without a user (lists are
+	// comma-separated and may be repeated)
+	var allowTools, denyTools, allowPaths []string
+	permissionPromptCmd := ""
+	for i := 0; i < len(args); i++ {
+		name, value, hasValue := strings.Cut(args[i], "=")
+		switch name {
+		case "--allow-tools", "--deny-tools", "--allow-paths", "--permission-prompt-cmd":
+		default:
+			continue
+		}
+		n := 1
+		if !hasValue {
+			if i+1 >= len(args) {
+				return fmt.Errorf("%s requires a value", name)
+			}
+			value, n = args[i+1], 2
+		}
+		args = append(args[:i], args[i+n:]...)
+		i--
+		switch name {
+		case "--allow-tools":
+			allowTools = append(allowTools, splitList(value)...)
+		case "--deny-tools":
+			denyTools = append(denyTools, splitList(value)...)
+		case "--allow-paths":
+			allowPaths = append(allowPaths, splitList(value)...)
+		case "--permission-prompt-cmd":
+			permissionPromptCmd = value
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	flagRules, err := permissions.FlagRules(wd, allowTools, denyTools, allowPaths)
+	if err != nil {
+		return err
+	}
+	hookRunner, err := hooks.New(cfg.Hooks)
+	if err != nil {
+		return fmt.Errorf("invalid hooks config: %w", err)
+	}
+	if _, err := tools.NewCommandPolicy(cfg.Tools.Bash); err != nil {
+		return fmt.Errorf("invalid bash policy: %w", err)
+	}
+	customCommands := commands.NewLoader()
+
 	// Initialize components
 	output := ui.NewOutputHandler()
 	input := ui.NewInputHandler()
 
-	// Create Ollama client with resilience wrapper (retry + circuit breaker)
-	rawClient := llm.NewClient(cfg)
+	// Create the provider client with resilience wrapper (retry + circuit breaker)
+	rawClient, err := llm.NewProviderClient(cfg)
+	if err != nil {
+		return err
+	}
 	llmClient := llm.NewResilientClient(rawClient, cfg.RateLimit)
 
-	// Health check for Ollama connectivity and model warming
-	{
+	// Health check for provider connectivity and model warming
+	switch c := rawClient.(type) {
+	case *llm.OllamaClient:
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
-		if version, err := rawClient.CheckHealthWithVersion(ctx); err != nil {
+		if version, err := c.CheckHealthWithVersion(ctx); err != nil {
 			fmt.Fprintf(os.Stderr, "Warning: Ollama is not running. Start with: ollama serve\n")
 		} else {
 			logDebug("Ollama connected: version %s", version)
@@ -232,41 +320,96 @@ func run() error {
 			go func() {
 				warmCtx, warmCancel := context.WithTimeout(context.Background(), 60*time.Second)
 				defer warmCancel()
-				model := rawClient.GetModel()
+				model := c.GetModel()
 				logDebug("Warming model %s...", model)
-				if err := rawClient.WarmModel(warmCtx); err != nil {
+				if err := c.WarmModel(warmCtx); err != nil {
 					logDebug("Model warm failed (non-fatal): %v", err)
 				} else {
 					logDebug("Model %s warmed and ready", model)
 				}
 			}()
 		}
+	case *llm.OpenAIClient:
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := c.CheckHealth(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: OpenAI-compatible server at %s is not reachable\n", cfg.OpenAI.BaseURL)
+		} else {
+			logDebug("OpenAI-compatible server connected: %s", cfg.OpenAI.BaseURL)
+		}
 	}
 
-	// Select registry based on mode
-	var registry *tools.Registry
-	if analysisMode {
-		registry = tools.NewAnalysisRegistry(&cfg.Tools)
-		logDebug("Using analysis registry (read-only tools)")
-	} else {
-		registry = tools.NewRegistry(&cfg.Tools)
+	// Memory, audit and router stores are shared by every agent this
+	// process creates
+	shared := agent.OpenShared(cfg)
+	defer func() {
+		if err := shared.Close(); err != nil {
+			logDebug("error closing shared stores: %v", err)
+		}
+	}()
+
+	// newAgent creates an agent with its own tool registry and permission
+	// policy; serve --http creates one per session
+	newAgent := func(client llm.LLMClient) (*agent.Agent, error) {
+		// Select registry based on mode
+		var registry *tools.Registry
+		if analysisMode {
+			registry = tools.NewAnalysisRegistry(&cfg.Tools)
+			logDebug("Using analysis registry (read-only tools)")
+		} else {
+			registry = tools.NewRegistry(&cfg.Tools)
+		}
+		// Start configured MCP servers; they stop when the agent closes the registry
+		if len(cfg.MCPServers) > 0 {
+			mcp.ClientVersion = Version
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			for _, err := range registry.ConnectMCPServers(ctx, cfg.MCPServers, analysisMode) {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+			cancel()
+		}
+		policy := permissions.NewPolicy(permMode, input, output)
+		rules, err := permissions.LoadRules(permissions.DefaultRulesFile)
+		if err != nil {
+			_ = registry.Close()
+			return nil, err
+		}
+		policy.SetRules(rules)
+		if flagRules != nil {
+			policy.SetOverrides(flagRules)
+		}
+
+		return agent.New(agent.Config{
+			LLM:          client,
+			Tools:        registry,
+			Permissions:  policy,
+			Skills:       skills.NewLoader(),
+			Output:       output,
+			Input:        input,
+			Config:       cfg,
+			AnalysisMode: analysisMode,
+			AutoTier:     true,        // Enable smart tier selection by default
+			CaptureMode:  captureMode, // Prompt to save responses to notes
+			Shared:       shared,
+			Hooks:        hookRunner,
+			Commands:     customCommands,
+
+			PermissionPromptCmd: permissionPromptCmd,
+		}), nil
+	}
+
+	// Handle serve subcommand; it creates its own agents
+	if len(args) > 0 && args[0] == "serve" {
+		return handleServeCommand(args[1:], func() (*agent.Agent, error) {
+			return newAgent(llmClient.Fork())
+		})
 	}
-	policy := permissions.NewPolicy(permMode, input, output)
-	skillLoader := skills.NewLoader()
 
 	// Create agent
-	a := agent.New(agent.Config{
-		LLM:          llmClient,
-		Tools:        registry,
-		Permissions:  policy,
-		Skills:       skillLoader,
-		Output:       output,
-		Input:        input,
-		Config:       cfg,
-		AnalysisMode: analysisMode,
-		AutoTier:     true,        // Enable smart tier selection by default
-		CaptureMode:  captureMode, // Prompt to save responses to notes
-	})
+	a, err := newAgent(llmClient)
+	if err != nil {
+		return err
+	}
 	defer func() {
 		if err := a.Close(); err != nil {
 			logDebug("error closing agent: %v", err)
@@ -282,6 +425,9 @@ func run() error {
 		if err := a.Close(); err != nil {
 			logDebug("error during signal shutdown: %v", err)
 		}
+		if err := shared.Close(); err != nil {
+			logDebug("error during signal shutdown: %v", err)
+		}
 		os.Exit(0)
 	}()
 
@@ -291,7 +437,7 @@ func run() error {
 	}
 
 	// Headless/pipe mode: -p flag or piped stdin
-	if promptFlag != "" || jsonMode {
+	if headless {
 		query := promptFlag
 
 		// Read stdin if piped (non-TTY)
@@ -314,9 +460,12 @@ func run() error {
 			return fmt.Errorf("no prompt provided (-p) and no stdin data")
 		}
 
-		logDebug("Headless mode: json=%v, query=%s", jsonMode, query)
-		if jsonMode {
+		logDebug("Headless mode: format=%s, query=%s", outputFormat, query)
+		switch outputFormat {
+		case "json":
 			return a.RunHeadlessJSON(query)
+		case "stream-json":
+			return a.RunHeadlessStream(query)
 		}
 		return a.RunHeadless(query)
 	}
@@ -354,6 +503,17 @@ func joinArgs(args []string) string {
 	return strings.Join(args, " ")
 }
 
+// splitList splits a comma-separated flag value, dropping empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func printHelp() {
 	fmt.Print(`vecai - Local AI-powered codebase assistant (Ollama)
 
@@ -362,18 +522,31 @@ Usage:
   vecai                   Start interactive mode
   vecai plan <goal>       Create and execute a plan
   vecai models <cmd>      Manage Ollama models (list/test/pull)
+  vecai audit [cmd]       Inspect the tool audit log (list/summary/verify)
+  vecai router stats      Show learned tier router accuracy
+  vecai serve --stdio     Serve JSON-RPC on stdio for editor integrations
+  vecai serve --http ADDR Serve a local HTTP API with server-sent events
   vecai version           Show version
   vecai help              Show this help
 
 Flags:
   -p, --prompt <text>     Headless mode: run prompt without TUI (pipe-friendly)
   --json                  Output JSON instead of plain text (use with -p)
+  --output-format FMT     Headless output: text, json or stream-json (NDJSON events)
+  --allow-tools LIST      Approve these tools without prompting (comma-separated globs)
+  --deny-tools LIST       Refuse these tools in every mode
+  --allow-paths LIST      Approve calls touching only these path globs (with
+                          --allow-tools, only calls to those tools)
+  --permission-prompt-cmd CMD
+                          Headless: run CMD with the pending call as JSON on
+                          stdin; exit 0 allows it, anything else denies it
   -q, --quick             Quick mode: fast response, no tools (for simple questions)
   -c, --capture           Capture mode: prompt to save responses to notes
   --model <name>          Override model (e.g., "qwen3:8b", "qwen3:14b")
   --ollama-url <url>      Override Ollama URL (default: http://localhost:11434)
   --auto                  Auto-approve all tool executions
   --strict                Prompt for all tool executions (including reads)
+  --worktree              Run Build mode tasks in a scratch git worktree
   --analyze, -a           Token-efficient analysis mode (read-only, minimal prompt)
   --debug, -d             Enable debug tracing to /tmp/vecai-debug/
   --verbose, -V           Enable verbose logging (debug level without full tracing)
@@ -401,9 +574,16 @@ Model Tiers (default models):
 
 Environment:
   OLLAMA_HOST             Ollama server URL (overrides config)
+  OPENAI_API_KEY          API key for provider "openai" (if openai.api_key unset)
   VECAI_DEBUG=1           Enable debug tracing (prefer --debug flag)
   VECAI_DEBUG_DIR         Override debug log directory
   VECAI_DEBUG_LLM=1       Enable full LLM payload logging
+  VECAI_SERVE_TOKEN       Bearer token for vecai serve --http
+
+Providers:
+  provider: ollama        Ollama /api/chat (default)
+  provider: openai        OpenAI-compatible /v1/chat/completions (llama.cpp, vLLM)
+                          configured under openai: base_url, api_key, model_fast/smart/genius
 
 Config Files (in priority order):
   ./vecai.yaml
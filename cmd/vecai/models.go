@@ -73,6 +73,9 @@ func modelsList(cfg *config.Config) error {
 		{"smart", cfg.Ollama.ModelSmart},
 		{"genius", cfg.Ollama.ModelGenius},
 	}
+	if cfg.Memory.Enabled && cfg.Memory.EmbeddingModel != "" {
+		models = append(models, struct{ tier, model string }{"memory", cfg.Memory.EmbeddingModel})
+	}
 
 	for _, m := range models {
 		available := checkModelAvailable(cfg.Ollama.BaseURL, m.model)
@@ -131,6 +134,9 @@ func modelsPull(cfg *config.Config) error {
 		cfg.Ollama.ModelSmart,
 		cfg.Ollama.ModelGenius,
 	}
+	if cfg.Memory.Enabled && cfg.Memory.EmbeddingModel != "" {
+		models = append(models, cfg.Memory.EmbeddingModel)
+	}
 
 	// Deduplicate in case same model is used for multiple tiers
 	seen := make(map[string]bool)
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/abdul-hamid-achik/vecai/internal/config"
+	"github.com/abdul-hamid-achik/vecai/internal/router"
+)
+
+// handleRouterCommand handles the "router" subcommand
+func handleRouterCommand(cfg *config.Config, args []string, jsonOut bool) error {
+	sub := "stats"
+	if len(args) > 0 {
+		sub = args[0]
+	}
+
+	switch sub {
+	case "stats":
+		return routerStats(cfg, jsonOut)
+	case "help", "--help", "-h":
+		return routerHelp()
+	default:
+		return fmt.Errorf("unknown router subcommand: %s. Use 'vecai router help' for usage", sub)
+	}
+}
+
+// routerHelp shows help for the router subcommand
+func routerHelp() error {
+	fmt.Print(`vecai router - Inspect the learned tier router
+
+Usage:
+  vecai router stats [--json]   Show recorded samples and classifier accuracy
+  vecai router help             Show this help
+
+Every query records the tier it ran on, how many iterations and tool calls
+it took, and whether you retried it or escalated with /mode. Once
+router.min_samples queries are recorded, a naive Bayes classifier trained on
+them picks the tier and intent instead of the keyword lists.
+`)
+	return nil
+}
+
+// routerStats prints sample counts and cross-validated accuracy
+func routerStats(cfg *config.Config, jsonOut bool) error {
+	samples, err := router.LoadSamples(cfg.Router.Dir)
+	if err != nil {
+		return err
+	}
+	stats := router.ComputeStats(samples, router.Options{
+		MinSamples:    cfg.Router.MinSamples,
+		MinConfidence: cfg.Router.MinConfidence,
+	})
+
+	if jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(stats)
+	}
+
+	if stats.Samples == 0 {
+		fmt.Printf("No router samples recorded yet (%s).\n", cfg.Router.Dir)
+		return nil
+	}
+
+	status := "keyword routing (learning)"
+	if !cfg.Router.Learned {
+		status = "disabled"
+	} else if stats.Active {
+		status = "learned routing"
+	}
+	fmt.Printf("Status:   %s\n", status)
+	fmt.Printf("Samples:  %d (learned routing starts at %d)\n", stats.Samples, stats.MinSamples)
+	fmt.Printf("Feedback: %d retries, %d escalations, %d failures\n", stats.Retries, stats.Escalations, stats.Failures)
+	printCounts("Learned tier labels", stats.TierLabels)
+
+	fmt.Println("\nAccuracy (5-fold cross-validation for the classifiers):")
+	if stats.RoutedSamples > 0 {
+		fmt.Printf("  routed at the time:  %5.1f%% of %d auto-routed queries\n", stats.RoutedAccuracy*100, stats.RoutedSamples)
+	}
+	fmt.Printf("  tier classifier:     %5.1f%%, %.0f%% confident enough to use\n", stats.TierAccuracy*100, stats.TierCoverage*100)
+	if stats.IntentSamples > 0 {
+		fmt.Printf("  intent classifier:   %5.1f%% of %d labeled queries\n", stats.IntentAccuracy*100, stats.IntentSamples)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/abdul-hamid-achik/vecai/internal/agent"
+	"github.com/abdul-hamid-achik/vecai/internal/serve"
+)
+
+// handleServeCommand handles the "serve" subcommand. newAgent creates the
+// agent behind each session.
+func handleServeCommand(args []string, newAgent func() (*agent.Agent, error)) error {
+	var stdio, allowRemote bool
+	var httpAddr, token string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--stdio":
+			stdio = true
+		case arg == "--http" || arg == "--token":
+			if i+1 >= len(args) {
+				return fmt.Errorf("%s requires a value", arg)
+			}
+			i++
+			if arg == "--http" {
+				httpAddr = args[i]
+			} else {
+				token = args[i]
+			}
+		case strings.HasPrefix(arg, "--http="):
+			httpAddr = strings.TrimPrefix(arg, "--http=")
+		case strings.HasPrefix(arg, "--token="):
+			token = strings.TrimPrefix(arg, "--token=")
+		case arg == "--allow-remote":
+			allowRemote = true
+		case arg == "help" || arg == "--help" || arg == "-h":
+			return serveHelp()
+		default:
+			return fmt.Errorf("unknown serve option: %s. Use 'vecai serve help' for usage", arg)
+		}
+	}
+	if stdio == (httpAddr != "") {
+		return fmt.Errorf("choose one transport: vecai serve --stdio or vecai serve --http :PORT")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	info := serve.Info{Name: "vecai", Version: Version}
+
+	if stdio {
+		a, err := newAgent()
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := a.Close(); err != nil {
+				logDebug("error closing agent: %v", err)
+			}
+		}()
+		logDebug("Serving JSON-RPC on stdio")
+		return serve.Stdio(ctx, a, info, os.Stdin, os.Stdout)
+	}
+
+	if token == "" {
+		token = os.Getenv("VECAI_SERVE_TOKEN")
+	}
+	generated := token == ""
+	if generated {
+		buf := make([]byte, 24)
+		if _, err := rand.Read(buf); err != nil {
+			return fmt.Errorf("failed to generate token: %w", err)
+		}
+		token = hex.EncodeToString(buf)
+	}
+
+	return serve.HTTP(ctx, serve.HTTPConfig{
+		Addr:        httpAddr,
+		Token:       token,
+		AllowRemote: allowRemote,
+		Info:        info,
+		NewAgent:    newAgent,
+		Ready: func(addr string) {
+			fmt.Fprintf(os.Stderr, "vecai serving on http://%s\n", addr)
+			if generated {
+				fmt.Fprintf(os.Stderr, "Token: %s\n", token)
+			}
+		},
+	})
+}
+
+// serveHelp shows help for the serve subcommand
+func serveHelp() error {
+	fmt.Print(`vecai serve - Run vecai as a server for editors, dashboards and scripts
+
+Usage:
+  vecai serve --stdio       Speak JSON-RPC 2.0 on stdin/stdout, one message per line
+  vecai serve --http ADDR   Serve the HTTP API on ADDR (e.g. :8420)
+  vecai serve help          Show this help
+
+HTTP options:
+  --token TOKEN             Bearer token (default: $VECAI_SERVE_TOKEN, or a
+                            random token printed at startup)
+  --allow-remote            Allow binding to non-loopback addresses
+
+JSON-RPC methods: initialize, session/start, session/list, prompt, cancel,
+permission/respond, mode/set, context/stats, shutdown. While a prompt runs,
+its output arrives as "event" notifications in the --output-format
+stream-json schema; permission_prompt events wait for permission/respond.
+
+HTTP endpoints (send "Authorization: Bearer TOKEN"):
+  GET    /v1/info                             Server name, version and event schema
+  GET    /v1/sessions                         Saved and live sessions
+  POST   /v1/sessions                         Start a live session {"resume", "mode"}
+  GET    /v1/sessions/{id}                    Load a saved session
+  DELETE /v1/sessions/{id}                    Delete a saved session
+  POST   /v1/sessions/{id}/tasks              Run a task {"text"}
+  POST   /v1/sessions/{id}/cancel             Cancel the running task
+  POST   /v1/sessions/{id}/permissions/{pid}  Answer a prompt {"decision"}
+  PUT    /v1/sessions/{id}/mode               Switch mode {"mode"}
+  GET    /v1/sessions/{id}/context            Context window usage
+  POST   /v1/sessions/{id}/close              Save and close a live session
+  GET    /v1/events[?session=ID]              Server-sent events
+Each live session has its own agent, context and permission policy.
+`)
+	return nil
+}
@@ -6,19 +6,27 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 
+	"github.com/abdul-hamid-achik/vecai/internal/audit"
+	"github.com/abdul-hamid-achik/vecai/internal/commands"
 	"github.com/abdul-hamid-achik/vecai/internal/config"
 	ctxmgr "github.com/abdul-hamid-achik/vecai/internal/context"
+	"github.com/abdul-hamid-achik/vecai/internal/hooks"
 	"github.com/abdul-hamid-achik/vecai/internal/llm"
 	"github.com/abdul-hamid-achik/vecai/internal/logging"
 	"github.com/abdul-hamid-achik/vecai/internal/memory"
 	"github.com/abdul-hamid-achik/vecai/internal/permissions"
+	"github.com/abdul-hamid-achik/vecai/internal/router"
 	"github.com/abdul-hamid-achik/vecai/internal/session"
 	"github.com/abdul-hamid-achik/vecai/internal/skills"
 	"github.com/abdul-hamid-achik/vecai/internal/tools"
 	"github.com/abdul-hamid-achik/vecai/internal/tui"
 	"github.com/abdul-hamid-achik/vecai/internal/ui"
+	"github.com/abdul-hamid-achik/vecai/internal/worktree"
 )
 
 // ErrExit is returned when the user requests to exit
@@ -43,9 +51,11 @@ CRITICAL: You are an autonomous agent. ALWAYS use tools directly to find informa
 - read_file: Read file contents (use AFTER vecgrep identifies relevant files)
 - write_file: Write content to a file
 - edit_file: Make targeted edits to a file
+- apply_patch: Apply a unified diff or SEARCH/REPLACE blocks across many files at once
 - list_files: List files in a directory
 - bash: Execute shell commands
 - grep: Exact pattern matching (literals, identifiers, regex)
+- task: Hand a self-contained investigation to a subagent that returns a short report
 
 ## Tool Selection Strategy (CRITICAL)
 
@@ -66,6 +76,10 @@ CRITICAL: You are an autonomous agent. ALWAYS use tools directly to find informa
 - Finding all usages of a known function/variable name
 - Pattern matching with regex where semantics don't matter
 
+**Use task when:**
+- An investigation would read many files or search results you do not need to keep
+- Several independent questions can be answered at once (call task several times in one turn)
+
 **Only use list_files/read_file when:**
 - You already know the exact file path from vecgrep results
 - You need the FULL file context after vecgrep identified it
@@ -81,7 +95,7 @@ vecgrep uses semantic embeddings that understand code MEANING, not just text mat
 1. Start exploration with vecgrep_search - it understands concepts
 2. Use vecgrep results to identify files, then read_file for full context
 3. Always read files before modifying them
-4. Use edit_file for small changes, write_file for new files or complete rewrites
+4. Use edit_file for small changes, apply_patch for changes spanning several places or files, write_file for new files or complete rewrites
 5. Be concise but thorough in explanations
 6. Ask clarifying questions if the request is ambiguous
 
@@ -124,6 +138,20 @@ type Config struct {
 	AnalysisMode bool // Enable token-efficient analysis mode
 	AutoTier     bool // Enable automatic tier selection based on query
 	CaptureMode  bool // Prompt to save responses to notes
+
+	// Shared stores for processes running several agents; nil means the
+	// agent opens and closes its own
+	Shared *Shared
+
+	// PermissionPromptCmd answers permission prompts in headless runs; empty
+	// denies them
+	PermissionPromptCmd string
+
+	// Hooks run user commands on agent events; nil runs none
+	Hooks *hooks.Runner
+
+	// Commands are user-defined slash commands; nil has none
+	Commands *commands.Loader
 }
 
 // Agent is the main AI agent
@@ -145,11 +173,21 @@ type Agent struct {
 	planner             *Planner
 	pipeline            *Pipeline
 	router              *TaskRouter
+	learnedRouter       *router.Router // Learns tiers and intents from query outcomes, nil when disabled
 	repoMap             *RepoMap
 	checkpointMgr       *CheckpointManager
+	auditLog            *audit.Log         // Tool call audit trail, nil when disabled
+	worktree            *worktree.Worktree // Active isolated worktree for Build mode tasks
+	worktreeTask        string             // First prompt run in the worktree, used for its commit message
 	calibrator          *ctxmgr.TokenCalibrator
 	sessionMgr          *session.Manager
 	memoryLayer         *memory.MemoryLayer // Unified memory access
+	ownStores           *Shared             // Stores opened by this agent, nil when shared
+	permissionPromptCmd string              // Answers headless permission prompts
+	hooks               *hooks.Runner       // User commands run on agent events, nil when none
+	sessionStarted      bool                // SessionStart hooks have run
+	commands            *commands.Loader    // User-defined slash commands
+	activeCommand       *commands.Command   // Custom command limiting the tools of the running query
 	analysisMode        bool                // Token-efficient analysis mode
 	autoTier            bool                // Enable automatic tier selection
 	quickMode           bool                // Quick mode (no tools, fast tier)
@@ -160,6 +198,20 @@ type Agent struct {
 	// Track if we've shown the context warning this session
 	shownContextWarning bool
 
+	// Learned router feedback for the last query (see route_feedback.go)
+	routeMu       sync.Mutex
+	pendingRoute  *router.Sample
+	currentIntent Intent // Intent classified for the query about to run
+
+	// Tier escalation in the running agent loop (see escalation.go)
+	escalation string
+
+	// Numbers tool calls the model returned without an ID
+	toolCallSeq atomic.Int64
+
+	// Serializes permission prompts of concurrent subagents (see subagent.go)
+	promptMu sync.Mutex
+
 	// Agent mode state
 	agentMode        tui.AgentMode    // Current mode: Ask, Plan, Build
 	previousPermMode permissions.Mode // To restore after exiting non-Build mode
@@ -209,17 +261,13 @@ func New(cfg Config) *Agent {
 		}
 	}
 
-	// Initialize memory layer if enabled
-	var memLayer *memory.MemoryLayer
-	if cfg.Config.Memory.Enabled {
-		wd, _ := os.Getwd()
-		memLayer, err = memory.NewMemoryLayer(wd)
-		if err != nil {
-			if log := logging.Global(); log != nil {
-				log.Warn("memory layer init failed", logging.Error(err))
-			}
-		}
+	// Open memory, audit and learned router stores unless they are shared
+	shared, ownStores := cfg.Shared, (*Shared)(nil)
+	if shared == nil {
+		shared = OpenShared(cfg.Config)
+		ownStores = shared
 	}
+	memLayer := shared.Memory
 
 	// Select system prompt based on mode
 	prompt := systemPrompt
@@ -227,8 +275,9 @@ func New(cfg Config) *Agent {
 		prompt = analysisSystemPrompt
 	}
 
-	// Initialize result cache for tool result summarization
-	resultCache := ctxmgr.NewToolResultCache(ctxmgr.DefaultCacheTTL)
+	// Initialize result cache for tool result summarization; full results
+	// stay long enough for recall_tool_result to bring them back
+	resultCache := ctxmgr.NewToolResultCache(ctxmgr.RecallCacheTTL)
 
 	// Create shutdown context for graceful cleanup
 	shutdownCtx, shutdownCancel := context.WithCancel(context.Background())
@@ -248,6 +297,10 @@ func New(cfg Config) *Agent {
 		resultCache:         resultCache,
 		sessionMgr:          sessionMgr,
 		memoryLayer:         memLayer,
+		ownStores:           ownStores,
+		permissionPromptCmd: cfg.PermissionPromptCmd,
+		hooks:               cfg.Hooks,
+		commands:            cfg.Commands,
 		analysisMode:        cfg.AnalysisMode,
 		autoTier:            cfg.AutoTier,
 		captureMode:         cfg.CaptureMode,
@@ -255,9 +308,30 @@ func New(cfg Config) *Agent {
 		shutdownCtx:         shutdownCtx,
 		shutdownCancel:      shutdownCancel,
 	}
-	a.checkpointMgr = NewCheckpointManager()
+	if wd, wdErr := os.Getwd(); wdErr == nil {
+		a.checkpointMgr = NewPersistentCheckpointManager(filepath.Join(wd, ".vecai", "checkpoints"))
+	} else {
+		a.checkpointMgr = NewCheckpointManager()
+	}
+	// Checkpoints and permission rules resolve paths like the tools do
+	a.checkpointMgr.workspace = cfg.Tools.Workspace()
+	if cfg.Permissions != nil {
+		cfg.Permissions.SetWorkspace(cfg.Tools.Workspace())
+	}
 	a.toolExecutor = NewToolExecutor(cfg.Tools, cfg.Permissions, resultCache, cfg.AnalysisMode)
+	a.toolExecutor.parallelExec = newParallelExecutor(cfg.Tools, cfg.Config.Parallel.MaxConcurrency)
 	a.toolExecutor.checkpointMgr = a.checkpointMgr
+	a.toolExecutor.hooks = a.hooks
+	a.toolExecutor.sessionID = a.currentSessionID
+	if shared.Audit != nil {
+		a.auditLog = shared.Audit
+		a.toolExecutor.auditLog = a.auditLog
+		a.toolExecutor.auditMeta = a.auditMeta
+	}
+	a.toolExecutor.budget = ctxmgr.NewManagerToolBudget(a.contextMgr, cfg.Config.Context.ToolBudgets)
+	cfg.Tools.Register(&taskTool{agent: a})
+	cfg.Tools.Register(&recallTool{cache: resultCache, budget: a.toolExecutor.budget})
+	a.syncSandboxMode()
 	a.commandHandler = NewCommandHandler(a)
 	a.planner = NewPlanner(a)
 	if wd, wdErr := os.Getwd(); wdErr == nil {
@@ -271,12 +345,18 @@ func New(cfg Config) *Agent {
 		Permissions: cfg.Permissions,
 	})
 	a.router = NewTaskRouter(cfg.LLM.Fork(), cfg.Config)
+	if learned := shared.Router; learned != nil {
+		a.learnedRouter = learned
+		a.tierSelector.SetLearned(learned)
+		a.router.SetLearned(learned)
+		a.pipeline.GetRouter().SetLearned(learned)
+	}
 	a.syncContextWindow()
 
 	// Wire up auto-save callback
 	if sessionMgr != nil {
 		a.contextMgr.SetOnSave(func(msgs []llm.Message) {
-			if err := a.sessionMgr.Save(msgs, a.llm.GetModel()); err != nil {
+			if err := a.saveSession(msgs); err != nil {
 				if log := logging.Global(); log != nil {
 					log.Warn("failed to save session", logging.Error(err))
 				}
@@ -349,6 +429,11 @@ func (a *Agent) runLineBased(query string) error {
 
 	cliOut := &CLIOutput{Out: a.output, In: a.input}
 
+	hookCtx, err := a.submitPrompt(ctx, query, cliOut)
+	if err != nil {
+		return err
+	}
+
 	// Auto-select mode based on intent classification
 	var intent Intent
 	if a.autoTier && !a.quickMode && !a.analysisMode {
@@ -401,13 +486,13 @@ func (a *Agent) runLineBased(query string) error {
 		Role:    "user",
 		Content: query,
 	})
+	a.addHookContext(hookCtx)
 
 	// Detect and record corrections for learning
 	a.detectAndRecordCorrection(query)
 
 	originalQuery := query // Save for capture
-	err := a.runAgentLoop(ctx, cliOut, cliOut)
-	if err != nil {
+	if err := a.runAgentLoop(ctx, cliOut, cliOut); err != nil {
 		return err
 	}
 
@@ -447,6 +532,7 @@ func (a *Agent) RunTUI(initialQuery string, interactive bool) error {
 	runner.SetModeChangeCallback(func(mode tui.AgentMode) {
 		a.applyModeChange(mode, true)
 	})
+	a.showJobs(adapter)
 
 	// Set up the onReady callback to execute initial query
 	if initialQuery != "" {
@@ -507,8 +593,9 @@ func (a *Agent) RunTUI(initialQuery string, interactive bool) error {
 		}
 	})
 
-	// Inject skill commands into autocomplete
+	// Inject skill and custom commands into autocomplete
 	a.injectSkillCommands(runner)
+	a.injectCustomCommands(runner)
 
 	// Check vecgrep status on startup (only for interactive mode)
 	if interactive || initialQuery == "" {
@@ -584,6 +671,7 @@ func (a *Agent) RunInteractiveTUI() error {
 	runner.SetModeChangeCallback(func(mode tui.AgentMode) {
 		a.applyModeChange(mode, true)
 	})
+	a.showJobs(adapter)
 
 	// Set up the onReady callback
 	runner.SetOnReady(func() {
@@ -659,8 +747,9 @@ func (a *Agent) RunInteractiveTUI() error {
 		}
 	})
 
-	// Inject skill commands into autocomplete
+	// Inject skill and custom commands into autocomplete
 	a.injectSkillCommands(runner)
+	a.injectCustomCommands(runner)
 
 	// Check vecgrep status on startup
 	a.checkVecgrepStatusTUI(adapter)
@@ -669,22 +758,45 @@ func (a *Agent) RunInteractiveTUI() error {
 	return runner.Run()
 }
 
+// showJobs keeps the TUI's list of background jobs up to date.
+func (a *Agent) showJobs(adapter *tui.TUIAdapter) {
+	if a.tools == nil {
+		return
+	}
+	if jobs := a.tools.Jobs(); jobs != nil {
+		jobs.SetListener(adapter.SetJobs)
+	}
+}
+
 // runWithTUIOutput runs a query using the TUI adapter for output.
 // taggedFiles contains any @-tagged files from the user's input.
 func (a *Agent) runWithTUIOutput(query string, adapter *tui.TUIAdapter, taggedFiles []tui.TaggedFile) error {
-	ctx := context.Background()
+	tuiOut := &TUIOutput{Adapter: adapter}
 
+	var fileCtx string
+	if len(taggedFiles) > 0 {
+		fileCtx = formatTaggedFileContext(taggedFiles)
+		logDebug("Injected %d tagged file(s) into context", len(taggedFiles))
+	}
+	return a.runQuery(context.Background(), query, fileCtx, tuiOut, tuiOut)
+}
+
+// runQuery runs an interactive query: it picks the mode and tier, routes
+// complex tasks to the multi-agent pipeline, and otherwise runs the agent
+// loop. fileCtx holds files the user attached to the query, if any.
+func (a *Agent) runQuery(ctx context.Context, query, fileCtx string, output AgentOutput, input AgentInput) error {
 	// Track current query for smart tool selection
 	a.currentQuery = query
 
-	tuiOut := &TUIOutput{Adapter: adapter}
+	hookCtx, err := a.submitPrompt(ctx, query, output)
+	if err != nil {
+		return err
+	}
 
 	// Auto-select mode based on intent classification, then apply mode-aware tier
 	var intent Intent
 	if a.autoTier && !a.quickMode && !a.analysisMode {
-		intent = a.autoSelectMode(ctx, query, tuiOut)
-		// Sync TUI display with the (possibly changed) agent mode
-		adapter.SetAgentMode(a.agentMode)
+		intent = a.autoSelectMode(ctx, query, output)
 	}
 
 	// Apply auto-tier selection if enabled (mode-aware: Smart floor for Plan/Build)
@@ -710,14 +822,14 @@ func (a *Agent) runWithTUIOutput(query string, adapter *tui.TUIAdapter, taggedFi
 		if a.router.ShouldUseMultiAgent(intent) {
 			a.llm.SetTier(a.router.GetRecommendedTier(intent))
 			a.syncContextWindow()
-			tuiOut.Info(fmt.Sprintf("Using multi-agent pipeline (intent: %s)", intent))
-			result, err := a.pipeline.ExecuteWithIntent(ctx, query, intent, tuiOut)
+			output.Info(fmt.Sprintf("Using multi-agent pipeline (intent: %s)", intent))
+			result, err := a.pipeline.ExecuteWithIntent(ctx, query, intent, output)
 			if err != nil {
 				return err
 			}
 			if result.FinalOutput != "" {
-				adapter.StreamText(result.FinalOutput)
-				adapter.StreamDone()
+				output.StreamText(result.FinalOutput)
+				output.StreamDone()
 			}
 			return nil
 		}
@@ -725,7 +837,7 @@ func (a *Agent) runWithTUIOutput(query string, adapter *tui.TUIAdapter, taggedFi
 
 	// Check for skill match
 	if skill := a.skills.Match(query); skill != nil {
-		adapter.Info(fmt.Sprintf("Using skill: %s", skill.Name))
+		output.Info(fmt.Sprintf("Using skill: %s", skill.Name))
 		query = skill.GetPrompt() + "\n\nUser request: " + query
 	}
 
@@ -735,21 +847,19 @@ func (a *Agent) runWithTUIOutput(query string, adapter *tui.TUIAdapter, taggedFi
 		Content: query,
 	})
 
-	// Inject tagged file context (similar to auto-RAG)
-	if len(taggedFiles) > 0 {
-		if fileCtx := formatTaggedFileContext(taggedFiles); fileCtx != "" {
-			a.contextMgr.AddMessage(llm.Message{
-				Role:    "user",
-				Content: "[Files provided by user via @mentions]\n" + fileCtx,
-			})
-			logDebug("Injected %d tagged file(s) into context", len(taggedFiles))
-		}
+	// Inject attached file context (similar to auto-RAG)
+	if fileCtx != "" {
+		a.contextMgr.AddMessage(llm.Message{
+			Role:    "user",
+			Content: "[Files provided by user via @mentions]\n" + fileCtx,
+		})
 	}
+	a.addHookContext(hookCtx)
 
 	// Detect and record corrections for learning
 	a.detectAndRecordCorrection(query)
 
-	return a.runAgentLoop(ctx, tuiOut, tuiOut)
+	return a.runAgentLoop(ctx, output, input)
 }
 
 // RunHeadless executes a query in headless mode (no TUI, text output to stdout).
@@ -757,22 +867,12 @@ func (a *Agent) RunHeadless(query string) error {
 	ctx := context.Background()
 	a.currentQuery = query
 
-	headlessOut := &HeadlessOutput{}
-	headlessIn := &HeadlessInput{}
-
-	// Auto-tier if enabled
-	if a.autoTier && !a.quickMode {
-		selectedTier := a.tierSelector.SelectTier(query, a.config.DefaultTier)
-		a.llm.SetTier(selectedTier)
-		a.syncContextWindow()
+	output := &HeadlessOutput{}
+	a.selectHeadlessTier(query)
+	if err := a.startHeadless(ctx, query, output); err != nil {
+		return err
 	}
-
-	a.contextMgr.AddMessage(llm.Message{
-		Role:    "user",
-		Content: query,
-	})
-
-	return a.runAgentLoop(ctx, headlessOut, headlessIn)
+	return a.runAgentLoop(ctx, output, a.headlessInput())
 }
 
 // RunHeadlessJSON executes a query and outputs a single JSON result.
@@ -781,25 +881,67 @@ func (a *Agent) RunHeadlessJSON(query string) error {
 	a.currentQuery = query
 
 	jsonOut := &JSONOutput{}
-	headlessIn := &HeadlessInput{}
+	a.selectHeadlessTier(query)
+
+	err := a.startHeadless(ctx, query, jsonOut)
+	if err == nil {
+		err = a.runAgentLoop(ctx, jsonOut, a.headlessInput())
+	}
+	if emitErr := jsonOut.Emit(); emitErr != nil {
+		return emitErr
+	}
+	return err
+}
+
+// RunHeadlessStream executes a query and writes every output event to
+// stdout as one NDJSON line while it runs.
+func (a *Agent) RunHeadlessStream(query string) error {
+	ctx := context.Background()
+	a.currentQuery = query
+
+	streamOut := NewStreamJSONOutput(os.Stdout)
+	a.selectHeadlessTier(query)
+	streamOut.Init(a.llm.GetModel(), a.agentMode)
+
+	err := a.startHeadless(ctx, query, streamOut)
+	if err == nil {
+		err = a.runAgentLoop(ctx, streamOut, a.headlessInput())
+	}
+	streamOut.Finish(err)
+	return err
+}
 
-	// Auto-tier if enabled
+// headlessInput answers permission prompts of headless runs with the
+// permission prompt command, or denies them.
+func (a *Agent) headlessInput() AgentInput {
+	if a.permissionPromptCmd != "" {
+		return &CommandInput{Command: a.permissionPromptCmd}
+	}
+	return &HeadlessInput{}
+}
+
+// selectHeadlessTier picks the tier for a headless query.
+func (a *Agent) selectHeadlessTier(query string) {
 	if a.autoTier && !a.quickMode {
 		selectedTier := a.tierSelector.SelectTier(query, a.config.DefaultTier)
 		a.llm.SetTier(selectedTier)
 		a.syncContextWindow()
 	}
+}
 
+// startHeadless runs the prompt hooks for a headless query and adds it to
+// the conversation.
+func (a *Agent) startHeadless(ctx context.Context, query string, output AgentOutput) error {
+	hookCtx, err := a.submitPrompt(ctx, query, output)
+	if err != nil {
+		return err
+	}
 	a.contextMgr.AddMessage(llm.Message{
 		Role:    "user",
 		Content: query,
 	})
-
-	err := a.runAgentLoop(ctx, jsonOut, headlessIn)
-	if emitErr := jsonOut.Emit(); emitErr != nil {
-		return emitErr
-	}
-	return err
+	a.addHookContext(hookCtx)
+	return nil
 }
 
 // RunPlan runs in plan mode
@@ -809,14 +951,18 @@ func (a *Agent) RunPlan(goal string) error {
 
 // readOnlyToolNames lists tools available in Ask mode
 var readOnlyToolNames = map[string]bool{
-	"read_file":       true,
-	"list_files":      true,
-	"grep":            true,
-	"vecgrep_search":  true,
-	"vecgrep_similar": true,
-	"vecgrep_status":  true,
-	"ast_parse":       true,
-	"lsp_query":       true,
+	"read_file":           true,
+	"list_files":          true,
+	"grep":                true,
+	"vecgrep_search":      true,
+	"vecgrep_similar":     true,
+	"vecgrep_status":      true,
+	"ast_parse":           true,
+	"lsp_query":           true,
+	"job_output":          true,
+	"job_status":          true,
+	ctxmgr.RecallToolName: true,
+	taskToolName:          true, // Ask mode subagents get read-only tools too
 }
 
 // getToolDefinitions converts tools to LLM format
@@ -836,7 +982,18 @@ func (a *Agent) getToolDefinitions() []llm.ToolDefinition {
 	if a.agentMode == tui.ModeAsk {
 		filtered := make([]tools.ToolDefinition, 0, len(registryDefs))
 		for _, d := range registryDefs {
-			if readOnlyToolNames[d.Name] {
+			if readOnlyToolNames[d.Name] || a.isReadOnlyMCPTool(d.Name) {
+				filtered = append(filtered, d)
+			}
+		}
+		registryDefs = filtered
+	}
+
+	// Limit to the tools a running custom command allows
+	if a.activeCommand != nil {
+		filtered := make([]tools.ToolDefinition, 0, len(registryDefs))
+		for _, d := range registryDefs {
+			if a.activeCommand.AllowsTool(d.Name) {
 				filtered = append(filtered, d)
 			}
 		}
@@ -855,6 +1012,16 @@ func (a *Agent) getToolDefinitions() []llm.ToolDefinition {
 	return defs
 }
 
+// isReadOnlyMCPTool reports whether name is an MCP server tool configured
+// with read permission, which Ask mode may use.
+func (a *Agent) isReadOnlyMCPTool(name string) bool {
+	if !tools.IsMCPTool(name) {
+		return false
+	}
+	tool, ok := a.tools.Get(name)
+	return ok && tool.Permission() == tools.PermissionRead
+}
+
 // getSystemPrompt returns the appropriate system prompt based on analysis mode
 // Appends project-specific instructions from VECAI.md or AGENTS.md if present
 // Also includes memory context enrichment when available
@@ -968,11 +1135,14 @@ func (a *Agent) Close() error {
 		a.shutdownCancel()
 	}
 
+	// Record the last query's outcome for the learned router
+	a.flushRouteSample()
+
 	// Save session if available
 	if a.sessionMgr != nil {
 		msgs := a.contextMgr.GetMessages()
 		if len(msgs) > 0 {
-			if err := a.sessionMgr.Save(msgs, a.llm.GetModel()); err != nil {
+			if err := a.saveSession(msgs); err != nil {
 				if log := logging.Global(); log != nil {
 					log.Warn("failed to save session during shutdown", logging.Error(err))
 				}
@@ -985,11 +1155,23 @@ func (a *Agent) Close() error {
 		a.resultCache.Stop()
 	}
 
-	// Close memory layer
-	if a.memoryLayer != nil {
-		if err := a.memoryLayer.Close(); err != nil {
+	// Keep unresolved worktree changes on their scratch branch
+	a.closeWorktree()
+
+	// Stop long-lived tool processes (language servers, background jobs)
+	if a.tools != nil {
+		if err := a.tools.Close(); err != nil {
 			if log := logging.Global(); log != nil {
-				log.Warn("failed to close memory layer", logging.Error(err))
+				log.Warn("failed to close tools", logging.Error(err))
+			}
+		}
+	}
+
+	// Close the audit trail and memory layer unless they are shared
+	if a.ownStores != nil {
+		if err := a.ownStores.Close(); err != nil {
+			if log := logging.Global(); log != nil {
+				log.Warn("failed to close memory or audit log", logging.Error(err))
 			}
 		}
 	}
@@ -1011,6 +1193,53 @@ func (a *Agent) Close() error {
 	return nil
 }
 
+// saveSession saves the conversation and its compaction state to the
+// current session.
+func (a *Agent) saveSession(msgs []llm.Message) error {
+	if err := a.sessionMgr.SetState(a.contextMgr.State()); err != nil {
+		return err
+	}
+	return a.sessionMgr.Save(msgs, a.llm.GetModel())
+}
+
+// bindCheckpointSession points the checkpoint manager at the current session,
+// starting one if needed, so /rewind history is saved alongside it.
+func (a *Agent) bindCheckpointSession() {
+	if a.checkpointMgr == nil || a.sessionMgr == nil {
+		return
+	}
+	sess := a.sessionMgr.GetCurrentSession()
+	if sess == nil {
+		var err error
+		if sess, err = a.sessionMgr.StartNew(); err != nil {
+			if log := logging.Global(); log != nil {
+				log.Warn("failed to start session for checkpoints", logging.Error(err))
+			}
+			return
+		}
+	}
+	if err := a.checkpointMgr.BindSession(sess.ID); err != nil {
+		if log := logging.Global(); log != nil {
+			log.Warn("failed to load checkpoints", logging.Error(err))
+		}
+	}
+}
+
+// auditMeta returns the session ID and model to stamp on audit records.
+func (a *Agent) auditMeta() (sessionID, model string) {
+	return a.currentSessionID(), a.llm.GetModel()
+}
+
+// currentSessionID returns the ID of the current session, if any.
+func (a *Agent) currentSessionID() string {
+	if a.sessionMgr != nil {
+		if sess := a.sessionMgr.GetCurrentSession(); sess != nil {
+			return sess.ID
+		}
+	}
+	return ""
+}
+
 // applyModeChange consolidates mode switching logic: updates agent mode,
 // permissions, and optionally the model tier to match the new mode.
 func (a *Agent) applyModeChange(mode tui.AgentMode, updateTier bool) {
@@ -1018,6 +1247,13 @@ func (a *Agent) applyModeChange(mode tui.AgentMode, updateTier bool) {
 		return
 	}
 	a.agentMode = mode
+	a.syncSandboxMode()
+
+	// Only user-initiated switches update the tier; they also tell the
+	// learned router the last query wanted another mode
+	if updateTier {
+		a.noteModeSwitch(mode)
+	}
 
 	// Update permissions based on mode
 	switch mode {
@@ -1047,10 +1283,20 @@ func (a *Agent) applyModeChange(mode tui.AgentMode, updateTier bool) {
 	}
 }
 
+// syncSandboxMode mounts the project read-only for bash in Ask and Plan
+// modes when tools.sandbox.read_only_project is set.
+func (a *Agent) syncSandboxMode() {
+	if a.tools == nil {
+		return
+	}
+	a.tools.SetProjectReadOnly(a.config.Tools.Sandbox.ReadOnlyProject && a.agentMode != tui.ModeBuild)
+}
+
 // autoSelectMode classifies intent and auto-switches the agent mode.
 // Returns the classified intent for reuse by the caller.
 func (a *Agent) autoSelectMode(ctx context.Context, query string, output AgentOutput) Intent {
 	intent := a.router.ClassifyIntent(ctx, query)
+	a.currentIntent = intent
 	recommendedMode, shouldSwitch := a.router.GetRecommendedMode(intent)
 	if !shouldSwitch || a.agentMode == recommendedMode {
 		return intent
@@ -1058,6 +1304,9 @@ func (a *Agent) autoSelectMode(ctx context.Context, query string, output AgentOu
 	a.applyModeChange(recommendedMode, false) // tier set separately by caller
 	if output != nil {
 		output.Info(fmt.Sprintf("Auto-switched to %s mode", recommendedMode.String()))
+		if ms, ok := output.(ModeSupport); ok {
+			ms.ModeChanged(recommendedMode)
+		}
 	}
 	return intent
 }
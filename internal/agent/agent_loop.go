@@ -9,6 +9,7 @@ import (
 
 	ctxmgr "github.com/abdul-hamid-achik/vecai/internal/context"
 	vecerr "github.com/abdul-hamid-achik/vecai/internal/errors"
+	"github.com/abdul-hamid-achik/vecai/internal/hooks"
 	"github.com/abdul-hamid-achik/vecai/internal/llm"
 	"github.com/abdul-hamid-achik/vecai/internal/tui"
 )
@@ -20,12 +21,17 @@ var ErrInterrupted = errors.New("interrupted by user")
 // It handles both CLI and TUI modes via the AgentOutput/AgentInput interfaces.
 // If output implements InterruptSupport, the loop supports ESC-based interruption.
 // If output implements StatsSupport, context stats are displayed/updated.
-func (a *Agent) runAgentLoop(ctx context.Context, output AgentOutput, input AgentInput) error {
+func (a *Agent) runAgentLoop(ctx context.Context, output AgentOutput, input AgentInput) (err error) {
 	maxIterations := a.config.Agent.MaxIterations
 	if maxIterations == 0 {
 		maxIterations = 20
 	}
 
+	// Track the query's outcome for the learned router
+	a.beginRouteSample()
+	var iterations, toolCallCount int
+	defer func() { a.endRouteSample(iterations, toolCallCount, err) }()
+
 	loopStartTime := time.Now()
 
 	// Check if output supports interrupt and stats
@@ -67,10 +73,21 @@ func (a *Agent) runAgentLoop(ctx context.Context, output AgentOutput, input Agen
 		}()
 	}
 
+	// Isolate Build mode tasks in a scratch worktree when enabled
+	if a.enterWorktree(output) {
+		defer a.reportWorktree(output)
+	}
+
 	// Start a checkpoint for /rewind support
 	if a.checkpointMgr != nil {
+		a.bindCheckpointSession()
 		a.checkpointMgr.StartCheckpoint(a.currentQuery)
-		defer a.checkpointMgr.CommitCheckpoint()
+		defer func() {
+			if err := a.checkpointMgr.CommitCheckpoint(); err != nil {
+				// Non-fatal: the checkpoint is still available in memory
+				output.Warning(err.Error())
+			}
+		}()
 	}
 
 	// Auto RAG: inject relevant code context before first LLM call
@@ -83,11 +100,15 @@ func (a *Agent) runAgentLoop(ctx context.Context, output AgentOutput, input Agen
 		}
 	}
 
-	consecutiveParseErrors := 0
+	stuck := newStuckDetector(a.config.Agent.StuckThreshold)
+	a.escalation = ""
+	budget := maxIterations
 	streamDoneSent := false
+	stopHookActive := false
 
-	for i := 0; i < maxIterations; i++ {
+	for i := 0; i < budget; i++ {
 		streamDoneSent = false // reset per iteration
+		iterations = i + 1
 		// Check for interrupt/cancellation before starting iteration
 		if hasInterrupt {
 			select {
@@ -105,8 +126,9 @@ func (a *Agent) runAgentLoop(ctx context.Context, output AgentOutput, input Agen
 		if hasStats {
 			statsOut.UpdateStats(tui.SessionStats{
 				LoopIteration: i + 1,
-				MaxIterations: maxIterations,
+				MaxIterations: budget,
 				LoopStartTime: loopStartTime,
+				Escalation:    a.escalation,
 			})
 		}
 
@@ -176,6 +198,7 @@ func (a *Agent) runAgentLoop(ctx context.Context, output AgentOutput, input Agen
 
 		response.Content = textContent.String()
 		response.ToolCalls = toolCalls
+		toolCallCount += len(toolCalls)
 
 		// Add assistant message with tool calls
 		if response.Content != "" || len(response.ToolCalls) > 0 {
@@ -189,8 +212,16 @@ func (a *Agent) runAgentLoop(ctx context.Context, output AgentOutput, input Agen
 		// Update context stats
 		a.updateContextStats(runCtx, output)
 
-		// If no tool calls, we're done
+		// If no tool calls, we're done unless a Stop hook keeps the agent going
 		if len(response.ToolCalls) == 0 {
+			if reason := a.stopHook(runCtx, response.Content, stopHookActive, output); reason != "" && i < budget-1 {
+				stopHookActive = true
+				a.contextMgr.AddMessage(llm.Message{
+					Role:    "user",
+					Content: "[Stop hook] " + reason,
+				})
+				continue
+			}
 			return nil
 		}
 
@@ -223,26 +254,6 @@ func (a *Agent) runAgentLoop(ctx context.Context, output AgentOutput, input Agen
 		// Execute tool calls
 		toolResults := a.toolExecutor.ExecuteToolCalls(runCtx, response.ToolCalls, output, input)
 
-		// Track consecutive iterations where ALL tool calls had parse errors
-		allParseErrors := true
-		for _, result := range toolResults {
-			if !result.Error || !strings.Contains(result.Result, "could not parse arguments") {
-				allParseErrors = false
-			}
-		}
-		if allParseErrors && len(toolResults) > 0 {
-			consecutiveParseErrors++
-		} else {
-			consecutiveParseErrors = 0
-		}
-		if consecutiveParseErrors >= 3 {
-			output.Warning("Too many consecutive tool call parse errors — stopping")
-			if !streamDoneSent {
-				output.StreamDone()
-			}
-			return nil
-		}
-
 		// Add individual tool result messages
 		for _, result := range toolResults {
 			a.contextMgr.AddMessage(llm.Message{
@@ -251,9 +262,25 @@ func (a *Agent) runAgentLoop(ctx context.Context, output AgentOutput, input Agen
 				ToolCallID: result.ToolCallID,
 			})
 		}
+
+		// Hand a stuck loop to a stronger model, keeping the conversation
+		if reason := stuck.observe(response.ToolCalls, toolResults); reason != "" {
+			if a.escalateTier(output, reason) {
+				stuck.reset()
+			} else if reason == stuckParseErrors {
+				output.Warning("Too many consecutive tool call parse errors — stopping")
+				if !streamDoneSent {
+					output.StreamDone()
+				}
+				return nil
+			}
+		} else if i == budget-1 && a.escalateTier(output, fmt.Sprintf("reached %d iterations", budget)) {
+			stuck.reset()
+			budget += maxIterations
+		}
 	}
 
-	return vecerr.MaxIterationsReached(maxIterations)
+	return vecerr.MaxIterationsReached(budget)
 }
 
 // processStreamChunk handles a single stream chunk for both CLI and TUI modes.
@@ -269,7 +296,12 @@ func (a *Agent) processStreamChunk(chunk llm.StreamChunk, output AgentOutput, te
 
 	case "tool_call":
 		if chunk.ToolCall != nil {
-			*toolCalls = append(*toolCalls, *chunk.ToolCall)
+			call := *chunk.ToolCall
+			// Ollama may omit call IDs; outputs and tool messages match on them
+			if call.ID == "" {
+				call.ID = fmt.Sprintf("call_%d", a.toolCallSeq.Add(1))
+			}
+			*toolCalls = append(*toolCalls, call)
 		}
 
 	case "done":
@@ -333,7 +365,7 @@ func (a *Agent) updateContextStats(ctx context.Context, output AgentOutput) {
 	// Handle auto-compact at threshold
 	if stats.NeedsCompaction && a.config.Context.EnableAutoCompact {
 		output.Warning(fmt.Sprintf("Context at %.0f%% - auto-compacting...", stats.UsagePercent*100))
-		if err := a.compactConversation(ctx, "", output); err != nil {
+		if err := a.compactConversation(ctx, hooks.TriggerAuto, "", output); err != nil {
 			output.Warning("Auto-compact failed: " + err.Error())
 		} else if _, ok := output.(StatsSupport); !ok {
 			// CLI mode: show success message since compactConversation only updates TUI stats
@@ -350,9 +382,10 @@ func (a *Agent) updateContextStats(ctx context.Context, output AgentOutput) {
 	}
 }
 
-// compactConversation compacts the conversation history.
+// compactConversation compacts the conversation history. trigger says
+// whether compaction was automatic or asked for, for PreCompact hooks.
 // If output implements StatsSupport, updates the stats display after compaction.
-func (a *Agent) compactConversation(ctx context.Context, focusPrompt string, output AgentOutput) error {
+func (a *Agent) compactConversation(ctx context.Context, trigger, focusPrompt string, output AgentOutput) error {
 	messages := a.contextMgr.GetMessages()
 	if len(messages) == 0 {
 		if output != nil {
@@ -361,10 +394,15 @@ func (a *Agent) compactConversation(ctx context.Context, focusPrompt string, out
 		return nil
 	}
 
+	if out := a.runHook(ctx, hooks.Input{Event: hooks.PreCompact, Trigger: trigger}, output); out.Blocked {
+		return fmt.Errorf("compaction blocked by hook: %s", out.Reason)
+	}
+
 	preserveLast := a.contextMgr.GetPreserveLast()
 
 	result, err := a.compactor.Compact(ctx, ctxmgr.CompactRequest{
 		Messages:     messages,
+		State:        a.contextMgr.State(),
 		FocusPrompt:  focusPrompt,
 		PreserveLast: preserveLast,
 	})
@@ -372,8 +410,11 @@ func (a *Agent) compactConversation(ctx context.Context, focusPrompt string, out
 		return fmt.Errorf("compaction failed: %w", err)
 	}
 
-	// Replace history with summary
-	a.contextMgr.ReplaceWithSummary(result.Summary, result.PreservedMsgs)
+	// Replace history with the merged state, unless there was nothing new
+	// to merge
+	if result.MessagesSummarized > 0 {
+		a.contextMgr.ReplaceWithState(result.State, result.PreservedMsgs)
+	}
 
 	// Reset warning flag after compaction
 	a.shownContextWarning = false
@@ -2,9 +2,12 @@ package agent
 
 import (
 	"context"
+	"strings"
 	"testing"
 
 	"github.com/abdul-hamid-achik/vecai/internal/config"
+	ctxmgr "github.com/abdul-hamid-achik/vecai/internal/context"
+	"github.com/abdul-hamid-achik/vecai/internal/hooks"
 	"github.com/abdul-hamid-achik/vecai/internal/llm"
 	"github.com/abdul-hamid-achik/vecai/internal/permissions"
 	"github.com/abdul-hamid-achik/vecai/internal/tui"
@@ -251,3 +254,39 @@ func contains(s, substr string) bool {
 	}
 	return false
 }
+
+// infoRecorder is an output that keeps Info messages.
+type infoRecorder struct {
+	mockOutput
+	infos []string
+}
+
+func (r *infoRecorder) Info(msg string) { r.infos = append(r.infos, msg) }
+
+func TestCompactConversation_KeepsState(t *testing.T) {
+	a, mock := newTestAgent(t)
+	mock.ChatFunc = func(context.Context, []llm.Message, []llm.ToolDefinition, string) (*llm.Response, error) {
+		return &llm.Response{Content: `{"goals": ["Fix the login bug"], "files": [{"path": "auth/login.go", "reason": "nil check added"}]}`}, nil
+	}
+	a.compactor = ctxmgr.NewCompactor(mock)
+	for _, content := range []string{"login panics", "fixed in auth/login.go", "thanks", "welcome", "bye"} {
+		a.contextMgr.AddMessage(llm.Message{Role: "user", Content: content})
+	}
+
+	if err := a.compactConversation(context.Background(), hooks.TriggerManual, "", &mockOutput{}); err != nil {
+		t.Fatal(err)
+	}
+	msgs := a.contextMgr.GetMessages()
+	if !strings.HasPrefix(msgs[0].Content, ctxmgr.StateMessagePrefix) || !strings.Contains(msgs[0].Content, "- auth/login.go: nil check added") {
+		t.Errorf("expected the state at the start of the conversation, got %q", msgs[0].Content)
+	}
+	if state := a.contextMgr.State(); state == nil || state.Compactions != 1 {
+		t.Fatalf("expected the state kept, got %+v", state)
+	}
+
+	out := &infoRecorder{}
+	a.commandHandler.Handle("/context", out, nil)
+	if joined := strings.Join(out.infos, "\n"); !strings.Contains(joined, "Compaction state (") || !strings.Contains(joined, "1. Fix the login bug") {
+		t.Errorf("expected /context to show the state, got:\n%s", joined)
+	}
+}
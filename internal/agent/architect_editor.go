@@ -11,8 +11,9 @@ import (
 
 // writeToolNames lists tools that modify files.
 var writeToolNames = map[string]bool{
-	"write_file": true,
-	"edit_file":  true,
+	"write_file":  true,
+	"edit_file":   true,
+	"apply_patch": true,
 }
 
 // hasWriteToolCalls returns true if any tool call is a write operation.
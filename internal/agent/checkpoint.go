@@ -1,51 +1,150 @@
 package agent
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
 	"sync"
+	"time"
+
+	"github.com/abdul-hamid-achik/vecai/internal/tools"
 )
 
-const maxCheckpoints = 10
+const (
+	maxCheckpoints = 10
+
+	// Each session directory holds a manifest indexing its checkpoints; file
+	// contents live next to it in blobs/, named by their SHA-256.
+	checkpointManifestFile = "manifest.json"
+	checkpointBlobDir      = "blobs"
+)
 
 // fileState stores the original content and permissions of a file.
 type fileState struct {
-	Content []byte      // nil means the file didn't exist
-	Mode    os.FileMode // original file permissions
+	Blob string      `json:"blob,omitempty"` // SHA-256 of the content; empty means the file didn't exist
+	Mode os.FileMode `json:"mode,omitempty"` // original file permissions
+
+	content []byte // original content, kept in memory until written to the blob store
+}
+
+// existed reports whether the file existed when the state was recorded.
+func (fs fileState) existed() bool {
+	return fs.Blob != ""
 }
 
 // checkpoint stores the original state of files before an agent loop iteration.
 type checkpoint struct {
-	Prompt string
-	// Files maps path → original state (nil content means the file didn't exist).
-	Files map[string]fileState
+	Prompt    string    `json:"prompt"`
+	CreatedAt time.Time `json:"created_at"`
+	// Files maps absolute path → original state.
+	Files map[string]fileState `json:"files"`
+}
+
+// checkpointManifest is the on-disk form of a session's checkpoints.
+type checkpointManifest struct {
+	SessionID   string       `json:"session_id"`
+	Checkpoints []checkpoint `json:"checkpoints"`
+}
+
+// CheckpointInfo describes a stored checkpoint for listing.
+type CheckpointInfo struct {
+	Prompt    string
+	CreatedAt time.Time
+	Files     []string // absolute paths, sorted
 }
 
 // CheckpointManager tracks file states across agent loop iterations for /rewind.
+// When created with a storage directory, checkpoints are written to
+// <dir>/<session-id>/ so they survive restarts and /resume.
 type CheckpointManager struct {
 	mu          sync.Mutex
 	checkpoints []checkpoint
 	current     *checkpoint
+	dir         string // e.g. .vecai/checkpoints; empty keeps checkpoints in memory only
+	sessionID   string
+	workspace   *tools.Workspace // Where relative paths resolve; nil means the working directory
 }
 
-// NewCheckpointManager creates a new checkpoint manager.
+// NewCheckpointManager creates a checkpoint manager that keeps checkpoints in memory.
 func NewCheckpointManager() *CheckpointManager {
 	return &CheckpointManager{}
 }
 
+// NewPersistentCheckpointManager creates a checkpoint manager that stores
+// checkpoints under dir, one subdirectory per session.
+func NewPersistentCheckpointManager(dir string) *CheckpointManager {
+	return &CheckpointManager{dir: dir}
+}
+
+// BindSession switches the manager to the given session, loading any
+// checkpoints previously saved for it. Binding the current session is a no-op.
+func (cm *CheckpointManager) BindSession(sessionID string) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if sessionID == cm.sessionID {
+		return nil
+	}
+	cm.sessionID = sessionID
+	cm.checkpoints = nil
+	if cm.dir == "" || sessionID == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(cm.manifestPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read checkpoint manifest: %w", err)
+	}
+	var manifest checkpointManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return fmt.Errorf("failed to parse checkpoint manifest: %w", err)
+	}
+	cm.checkpoints = manifest.Checkpoints
+	return nil
+}
+
+// SessionID returns the session the manager is bound to.
+func (cm *CheckpointManager) SessionID() string {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	return cm.sessionID
+}
+
+// DeleteSession removes all stored checkpoints for a session.
+func (cm *CheckpointManager) DeleteSession(sessionID string) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if sessionID == cm.sessionID {
+		cm.checkpoints = nil
+	}
+	if cm.dir == "" || sessionID == "" {
+		return nil
+	}
+	return os.RemoveAll(filepath.Join(cm.dir, sessionID))
+}
+
 // StartCheckpoint begins a new checkpoint for the given prompt.
 // Call this before each agent loop iteration.
 func (cm *CheckpointManager) StartCheckpoint(prompt string) {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 	cm.current = &checkpoint{
-		Prompt: prompt,
-		Files:  make(map[string]fileState),
+		Prompt:    prompt,
+		CreatedAt: time.Now(),
+		Files:     make(map[string]fileState),
 	}
 }
 
 // SaveFileState records the current state of a file before it's modified.
-// Should be called before write_file or edit_file executions.
+// Should be called before write_file, edit_file or apply_patch executions.
 // Only saves if the file hasn't already been saved in this checkpoint.
 func (cm *CheckpointManager) SaveFileState(path string) {
 	cm.mu.Lock()
@@ -54,6 +153,9 @@ func (cm *CheckpointManager) SaveFileState(path string) {
 	if cm.current == nil {
 		return
 	}
+	if abs, err := cm.workspace.ResolvePath(path); err == nil {
+		path = abs
+	}
 
 	// Already saved in this checkpoint
 	if _, exists := cm.current.Files[path]; exists {
@@ -63,26 +165,29 @@ func (cm *CheckpointManager) SaveFileState(path string) {
 	info, statErr := os.Stat(path)
 	content, readErr := os.ReadFile(path)
 	if readErr != nil {
-		// File doesn't exist yet — record nil content so rewind deletes it
-		cm.current.Files[path] = fileState{Content: nil}
+		// File doesn't exist yet — record no blob so rewind deletes it
+		cm.current.Files[path] = fileState{}
 	} else {
 		mode := os.FileMode(0644)
 		if statErr == nil {
 			mode = info.Mode()
 		}
-		cm.current.Files[path] = fileState{Content: content, Mode: mode}
+		sum := sha256.Sum256(content)
+		cm.current.Files[path] = fileState{Blob: hex.EncodeToString(sum[:]), Mode: mode, content: content}
 	}
 }
 
 // CommitCheckpoint finalizes the current checkpoint.
 // Only commits if files were actually saved (i.e., writes happened).
-func (cm *CheckpointManager) CommitCheckpoint() {
+// An error means the checkpoint could not be saved to disk; it is still
+// available in memory.
+func (cm *CheckpointManager) CommitCheckpoint() error {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
 	if cm.current == nil || len(cm.current.Files) == 0 {
 		cm.current = nil
-		return
+		return nil
 	}
 
 	cm.checkpoints = append(cm.checkpoints, *cm.current)
@@ -92,43 +197,66 @@ func (cm *CheckpointManager) CommitCheckpoint() {
 	if len(cm.checkpoints) > maxCheckpoints {
 		cm.checkpoints = cm.checkpoints[len(cm.checkpoints)-maxCheckpoints:]
 	}
+
+	if err := cm.persist(); err != nil {
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+	return nil
 }
 
-// Rewind restores all files from the last checkpoint to their pre-modification state.
-// Returns the list of restored file paths and any error.
-func (cm *CheckpointManager) Rewind() ([]string, error) {
+// Rewind undoes the last n checkpoints, restoring every file they touched to
+// its state before the oldest of them. Returns the restored file paths and any error.
+func (cm *CheckpointManager) Rewind(n int) ([]string, error) {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
 	if len(cm.checkpoints) == 0 {
 		return nil, fmt.Errorf("no checkpoints to rewind")
 	}
+	if n < 1 || n > len(cm.checkpoints) {
+		return nil, fmt.Errorf("can only rewind 1 to %d checkpoints", len(cm.checkpoints))
+	}
 
-	// Pop the last checkpoint
-	last := cm.checkpoints[len(cm.checkpoints)-1]
-	cm.checkpoints = cm.checkpoints[:len(cm.checkpoints)-1]
+	targets := cm.rewindTargets(n)
+	cm.checkpoints = cm.checkpoints[:len(cm.checkpoints)-n]
+
+	paths := make([]string, 0, len(targets))
+	for path := range targets {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
 
 	var restored []string
 	var errors []string
 
-	for path, state := range last.Files {
-		if state.Content == nil {
+	for _, path := range paths {
+		state := targets[path]
+		if !state.existed() {
 			// File didn't exist before — delete it
 			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
 				errors = append(errors, fmt.Sprintf("failed to remove %s: %v", path, err))
 			} else {
 				restored = append(restored, path+" (deleted)")
 			}
+			continue
+		}
+
+		// Restore original content with original permissions
+		content, err := cm.blobContent(state)
+		if err == nil {
+			err = os.WriteFile(path, content, state.Mode)
+		}
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("failed to restore %s: %v", path, err))
 		} else {
-			// Restore original content with original permissions
-			if err := os.WriteFile(path, state.Content, state.Mode); err != nil {
-				errors = append(errors, fmt.Sprintf("failed to restore %s: %v", path, err))
-			} else {
-				restored = append(restored, path)
-			}
+			restored = append(restored, path)
 		}
 	}
 
+	if err := cm.persist(); err != nil {
+		errors = append(errors, fmt.Sprintf("failed to update checkpoint manifest: %v", err))
+	}
+
 	if len(errors) > 0 {
 		return restored, fmt.Errorf("partial rewind: %s", errors[0])
 	}
@@ -136,6 +264,51 @@ func (cm *CheckpointManager) Rewind() ([]string, error) {
 	return restored, nil
 }
 
+// PreviewRewind returns a unified diff per file showing what Rewind(n) would
+// change, keyed by absolute path. Files already in their original state are omitted.
+func (cm *CheckpointManager) PreviewRewind(n int) (map[string]string, error) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if n < 1 || n > len(cm.checkpoints) {
+		return nil, fmt.Errorf("can only rewind 1 to %d checkpoints", len(cm.checkpoints))
+	}
+
+	diffs := make(map[string]string)
+	for path, state := range cm.rewindTargets(n) {
+		var original []byte
+		if state.existed() {
+			content, err := cm.blobContent(state)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read checkpoint of %s: %w", path, err)
+			}
+			original = content
+		}
+		current, _ := os.ReadFile(path)
+		if diff := tools.GenerateUnifiedDiff(path, string(current), string(original), 3); diff != "" {
+			diffs[path] = diff
+		}
+	}
+	return diffs, nil
+}
+
+// List returns the stored checkpoints, oldest first.
+func (cm *CheckpointManager) List() []CheckpointInfo {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	infos := make([]CheckpointInfo, 0, len(cm.checkpoints))
+	for _, cp := range cm.checkpoints {
+		files := make([]string, 0, len(cp.Files))
+		for path := range cp.Files {
+			files = append(files, path)
+		}
+		sort.Strings(files)
+		infos = append(infos, CheckpointInfo{Prompt: cp.Prompt, CreatedAt: cp.CreatedAt, Files: files})
+	}
+	return infos
+}
+
 // HasCheckpoints returns true if there are checkpoints available to rewind.
 func (cm *CheckpointManager) HasCheckpoints() bool {
 	cm.mu.Lock()
@@ -149,3 +322,105 @@ func (cm *CheckpointManager) Count() int {
 	defer cm.mu.Unlock()
 	return len(cm.checkpoints)
 }
+
+// rewindTargets returns the state each file must be restored to when undoing
+// the last n checkpoints: the oldest recorded state wins. Callers hold cm.mu.
+func (cm *CheckpointManager) rewindTargets(n int) map[string]fileState {
+	targets := make(map[string]fileState)
+	for i := len(cm.checkpoints) - 1; i >= len(cm.checkpoints)-n; i-- {
+		for path, state := range cm.checkpoints[i].Files {
+			targets[path] = state
+		}
+	}
+	return targets
+}
+
+// blobContent returns the original content for a state, reading it from the
+// blob store if it is no longer held in memory. Callers hold cm.mu.
+func (cm *CheckpointManager) blobContent(state fileState) ([]byte, error) {
+	if state.content != nil {
+		return state.content, nil
+	}
+	if cm.dir == "" || cm.sessionID == "" {
+		return nil, fmt.Errorf("checkpoint content for blob %s is missing", state.Blob)
+	}
+	return os.ReadFile(filepath.Join(cm.sessionDir(), checkpointBlobDir, state.Blob))
+}
+
+// persist writes new blobs and the manifest for the bound session, then
+// removes blobs no checkpoint references anymore. Callers hold cm.mu.
+func (cm *CheckpointManager) persist() error {
+	if cm.dir == "" || cm.sessionID == "" {
+		return nil
+	}
+	blobDir := filepath.Join(cm.sessionDir(), checkpointBlobDir)
+	if err := os.MkdirAll(blobDir, 0700); err != nil {
+		return err
+	}
+
+	referenced := make(map[string]bool)
+	for _, cp := range cm.checkpoints {
+		for _, state := range cp.Files {
+			if !state.existed() {
+				continue
+			}
+			referenced[state.Blob] = true
+			if state.content == nil {
+				continue // Loaded from disk, already stored
+			}
+			blobPath := filepath.Join(blobDir, state.Blob)
+			if _, err := os.Stat(blobPath); err == nil {
+				continue // Content-addressed: identical content is stored once
+			}
+			if err := writeFileAtomic(blobPath, state.content); err != nil {
+				return fmt.Errorf("failed to write blob: %w", err)
+			}
+		}
+	}
+
+	data, err := json.MarshalIndent(checkpointManifest{SessionID: cm.sessionID, Checkpoints: cm.checkpoints}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint manifest: %w", err)
+	}
+	if err := writeFileAtomic(cm.manifestPath(), data); err != nil {
+		return fmt.Errorf("failed to write checkpoint manifest: %w", err)
+	}
+
+	entries, err := os.ReadDir(blobDir)
+	if err != nil {
+		return nil
+	}
+	for _, entry := range entries {
+		if !referenced[entry.Name()] {
+			_ = os.Remove(filepath.Join(blobDir, entry.Name())) // Best effort cleanup
+		}
+	}
+	return nil
+}
+
+func (cm *CheckpointManager) sessionDir() string {
+	return filepath.Join(cm.dir, cm.sessionID)
+}
+
+func (cm *CheckpointManager) manifestPath() string {
+	return filepath.Join(cm.sessionDir(), checkpointManifestFile)
+}
+
+// writeFileAtomic writes data to a temporary file and renames it into place
+// so a crash never leaves a truncated manifest or blob behind.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
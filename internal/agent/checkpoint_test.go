@@ -0,0 +1,166 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runCheckpoint records one agent turn that writes the given files.
+func runCheckpoint(t *testing.T, cm *CheckpointManager, prompt string, files map[string]string) {
+	t.Helper()
+	cm.StartCheckpoint(prompt)
+	for path, content := range files {
+		cm.SaveFileState(path)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cm.CommitCheckpoint(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckpointManager_CommitReportsSaveFailure(t *testing.T) {
+	dir := t.TempDir()
+	store := filepath.Join(dir, "checkpoints")
+	a := filepath.Join(dir, "a.go")
+	cm := NewPersistentCheckpointManager(store)
+	if err := cm.BindSession("sess1"); err != nil {
+		t.Fatal(err)
+	}
+	// A file where the blob directory goes makes saving fail
+	if err := os.MkdirAll(filepath.Join(store, "sess1"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(store, "sess1", checkpointBlobDir), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cm.StartCheckpoint("write")
+	cm.SaveFileState(a)
+	if err := os.WriteFile(a, []byte("v1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := cm.CommitCheckpoint(); err == nil || !strings.Contains(err.Error(), "failed to save checkpoint") {
+		t.Errorf("expected a save error, got %v", err)
+	}
+	if !cm.HasCheckpoints() {
+		t.Error("expected the checkpoint to stay available in memory")
+	}
+}
+
+func TestCheckpointManager_PersistsAcrossRestart(t *testing.T) {
+	dir := t.TempDir()
+	store := filepath.Join(dir, ".vecai", "checkpoints")
+	a := filepath.Join(dir, "a.go")
+	b := filepath.Join(dir, "b.go")
+	if err := os.WriteFile(a, []byte("v0"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cm := NewPersistentCheckpointManager(store)
+	if err := cm.BindSession("sess1"); err != nil {
+		t.Fatal(err)
+	}
+	runCheckpoint(t, cm, "first", map[string]string{a: "v1"})
+	runCheckpoint(t, cm, "second", map[string]string{a: "v2", b: "new"})
+
+	// Identical content is stored once
+	blobs, err := os.ReadDir(filepath.Join(store, "sess1", "blobs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blobs) != 2 {
+		t.Errorf("expected 2 blobs (v0, v1), got %d", len(blobs))
+	}
+
+	// A fresh manager, as after a restart, sees nothing until bound
+	restarted := NewPersistentCheckpointManager(store)
+	if restarted.HasCheckpoints() {
+		t.Fatal("expected no checkpoints before binding a session")
+	}
+	if err := restarted.BindSession("sess1"); err != nil {
+		t.Fatal(err)
+	}
+	infos := restarted.List()
+	if len(infos) != 2 || infos[0].Prompt != "first" || infos[1].Prompt != "second" {
+		t.Fatalf("unexpected checkpoints after reload: %+v", infos)
+	}
+	if got := strings.Join(infos[1].Files, ","); got != a+","+b {
+		t.Errorf("unexpected files in second checkpoint: %s", got)
+	}
+
+	diffs, err := restarted.PreviewRewind(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(diffs[a], "-v2") || !strings.Contains(diffs[a], "+v0") {
+		t.Errorf("unexpected preview for a.go:\n%s", diffs[a])
+	}
+	if data, _ := os.ReadFile(a); string(data) != "v2" {
+		t.Errorf("preview must not modify files, a.go = %q", data)
+	}
+
+	// Rewinding both turns restores the state before the first one
+	if _, err := restarted.Rewind(2); err != nil {
+		t.Fatalf("rewind failed: %v", err)
+	}
+	if data, _ := os.ReadFile(a); string(data) != "v0" {
+		t.Errorf("expected a.go restored to v0, got %q", data)
+	}
+	if _, err := os.Stat(b); !os.IsNotExist(err) {
+		t.Errorf("expected b.go removed, stat err = %v", err)
+	}
+	if restarted.HasCheckpoints() {
+		t.Error("expected no checkpoints left")
+	}
+
+	// The manifest and blob store reflect the rewind
+	reloaded := NewPersistentCheckpointManager(store)
+	if err := reloaded.BindSession("sess1"); err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.Count() != 0 {
+		t.Errorf("expected empty manifest after rewind, got %d checkpoints", reloaded.Count())
+	}
+	if blobs, _ := os.ReadDir(filepath.Join(store, "sess1", "blobs")); len(blobs) != 0 {
+		t.Errorf("expected unreferenced blobs to be removed, %d left", len(blobs))
+	}
+}
+
+func TestCheckpointManager_SessionsAreIsolated(t *testing.T) {
+	dir := t.TempDir()
+	store := filepath.Join(dir, "checkpoints")
+	path := filepath.Join(dir, "f.txt")
+
+	cm := NewPersistentCheckpointManager(store)
+	if err := cm.BindSession("one"); err != nil {
+		t.Fatal(err)
+	}
+	runCheckpoint(t, cm, "edit", map[string]string{path: "x"})
+
+	if err := cm.BindSession("two"); err != nil {
+		t.Fatal(err)
+	}
+	if cm.HasCheckpoints() {
+		t.Error("expected a new session to start without checkpoints")
+	}
+	if err := cm.BindSession("one"); err != nil {
+		t.Fatal(err)
+	}
+	if cm.Count() != 1 {
+		t.Errorf("expected session one's checkpoint back, got %d", cm.Count())
+	}
+
+	if err := cm.DeleteSession("one"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(store, "one")); !os.IsNotExist(err) {
+		t.Errorf("expected session directory removed, stat err = %v", err)
+	}
+	if _, err := cm.Rewind(1); err == nil {
+		t.Error("expected rewind to fail after deleting the session's checkpoints")
+	}
+}
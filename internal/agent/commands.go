@@ -4,10 +4,14 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/abdul-hamid-achik/vecai/internal/config"
+	"github.com/abdul-hamid-achik/vecai/internal/hooks"
 	"github.com/abdul-hamid-achik/vecai/internal/logging"
 	"github.com/abdul-hamid-achik/vecai/internal/session"
 	"github.com/abdul-hamid-achik/vecai/internal/tui"
@@ -56,6 +60,7 @@ func (ch *CommandHandler) Handle(cmd string, output AgentOutput, cmdCtx CommandC
 	switch parts[0] {
 	case "/help":
 		ch.showHelp(output)
+		ch.showCustomCommands(output)
 		return true
 
 	case "/exit", "/quit":
@@ -98,6 +103,11 @@ func (ch *CommandHandler) Handle(cmd string, output AgentOutput, cmdCtx CommandC
 			breakdown.SystemPrompt, breakdown.UserMessages,
 			breakdown.AssistantMsgs, breakdown.ToolResults))
 		output.Info(fmt.Sprintf("  Messages: %d", stats.MessageCount))
+		if state := a.contextMgr.State(); !state.IsEmpty() {
+			output.Info(fmt.Sprintf("Compaction state (%d messages in %d compactions):",
+				state.MessagesCompacted, state.Compactions))
+			output.Info(state.Format())
+		}
 		if stats.NeedsCompaction {
 			output.Warning("Context needs compaction - use /compact")
 		} else if stats.NeedsWarning {
@@ -112,7 +122,7 @@ func (ch *CommandHandler) Handle(cmd string, output AgentOutput, cmdCtx CommandC
 		}
 		output.Info("Compacting conversation...")
 		ctx := context.Background()
-		if err := a.compactConversation(ctx, focusPrompt, output); err != nil {
+		if err := a.compactConversation(ctx, hooks.TriggerManual, focusPrompt, output); err != nil {
 			output.ErrorStr("Compact failed: " + err.Error())
 		} else if _, ok := output.(StatsSupport); !ok {
 			// CLI mode: compactConversation only prints success for StatsSupport outputs
@@ -130,12 +140,15 @@ func (ch *CommandHandler) Handle(cmd string, output AgentOutput, cmdCtx CommandC
 		switch parts[1] {
 		case "fast":
 			a.llm.SetTier(config.TierFast)
+			a.noteTierSwitch(config.TierFast)
 			output.Success("Switched to fast mode (" + a.config.GetModel(config.TierFast) + ")")
 		case "smart":
 			a.llm.SetTier(config.TierSmart)
+			a.noteTierSwitch(config.TierSmart)
 			output.Success("Switched to smart mode (" + a.config.GetModel(config.TierSmart) + ")")
 		case "genius":
 			a.llm.SetTier(config.TierGenius)
+			a.noteTierSwitch(config.TierGenius)
 			output.Success("Switched to genius mode (" + a.config.GetModel(config.TierGenius) + ")")
 		default:
 			output.ErrorStr("Unknown mode: " + parts[1])
@@ -205,7 +218,15 @@ func (ch *CommandHandler) Handle(cmd string, output AgentOutput, cmdCtx CommandC
 		return true
 
 	case "/rewind":
-		ch.rewindCheckpoint(output)
+		ch.rewindCheckpoint(parts, output)
+		return true
+
+	case "/checkpoints":
+		ch.showCheckpoints(output)
+		return true
+
+	case "/worktree":
+		ch.handleWorktree(parts, output)
 		return true
 
 	case "/software-architect", "/architect":
@@ -222,6 +243,11 @@ func (ch *CommandHandler) Handle(cmd string, output AgentOutput, cmdCtx CommandC
 		return true
 
 	default:
+		if custom, ok := a.commands.Get(strings.TrimPrefix(parts[0], "/")); ok {
+			args := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(cmd), parts[0]))
+			ch.runCustomCommand(custom, args, output, cmdCtx)
+			return true
+		}
 		output.ErrorStr("Unknown command: " + parts[0] + ". Type /help for available commands.")
 		return true
 	}
@@ -240,13 +266,15 @@ func (ch *CommandHandler) showHelp(output AgentOutput) {
   /skills          List available skills
   /status          Check vecgrep status
   /reindex         Update vecgrep search index
-  /context         Show context usage breakdown
+  /context         Show context usage and compaction state
   /compact [focus] Compact conversation (optional focus)
   /sessions        List saved sessions
   /resume [id]     Resume a session (last if no id)
   /new             Start a new session
   /delete <id>     Delete a session
-  /rewind          Undo last agent's file changes
+  /checkpoints     List file checkpoints for this session
+  /rewind [n]      Preview undoing the last n agent turns' file changes
+  /worktree [cmd]  Worktree isolation: on|off|diff|merge|squash|discard
   /clear           Clear conversation
   /exit            Exit interactive mode
 
@@ -349,7 +377,9 @@ func (ch *CommandHandler) resumeSession(parts []string, output AgentOutput, cmdC
 		return
 	}
 	a.contextMgr.RestoreMessages(sess.Messages)
+	a.contextMgr.SetState(sess.State)
 	a.sessionMgr.SetCurrent(sess)
+	ch.bindCheckpoints(sess.ID, output)
 	cmdCtx.SetSessionID(sess.ID[:8])
 	output.Success(fmt.Sprintf("Resumed session %s (%d messages)", sess.ID[:8], len(sess.Messages)))
 	// Show last exchange as context
@@ -382,6 +412,7 @@ func (ch *CommandHandler) newSession(output AgentOutput, cmdCtx CommandContext)
 		output.ErrorStr("Failed to start new session: " + err.Error())
 		return
 	}
+	ch.bindCheckpoints(sess.ID, output)
 	cmdCtx.ClearDisplay()
 	cmdCtx.ClearQueue()
 	cmdCtx.SetSessionID(sess.ID[:8])
@@ -415,24 +446,175 @@ func (ch *CommandHandler) switchMode(mode tui.AgentMode, output AgentOutput, cmd
 	}
 }
 
-// rewindCheckpoint restores files from the last checkpoint.
-func (ch *CommandHandler) rewindCheckpoint(output AgentOutput) {
+// bindCheckpoints loads the checkpoints stored for a session.
+func (ch *CommandHandler) bindCheckpoints(sessionID string, output AgentOutput) {
+	a := ch.agent
+	if a.checkpointMgr == nil {
+		return
+	}
+	if err := a.checkpointMgr.BindSession(sessionID); err != nil {
+		output.Warning("Failed to load checkpoints: " + err.Error())
+	} else if n := a.checkpointMgr.Count(); n > 0 {
+		output.Info(fmt.Sprintf("%d checkpoint(s) available - /checkpoints to list, /rewind to undo", n))
+	}
+}
+
+// showCheckpoints lists the checkpoints for the current session, newest first.
+func (ch *CommandHandler) showCheckpoints(output AgentOutput) {
+	a := ch.agent
+	if a.checkpointMgr == nil || !a.checkpointMgr.HasCheckpoints() {
+		output.Info("No checkpoints in this session")
+		return
+	}
+	infos := a.checkpointMgr.List()
+	output.Info("Checkpoints (/rewind n undoes the newest n):")
+	for i := len(infos) - 1; i >= 0; i-- {
+		cp := infos[i]
+		prompt := strings.Join(strings.Fields(cp.Prompt), " ")
+		if len(prompt) > 50 {
+			prompt = prompt[:47] + "..."
+		}
+		output.Info(fmt.Sprintf("  %2d  %-8s  \"%s\"", len(infos)-i, session.FormatRelativeTime(cp.CreatedAt), prompt))
+		for _, path := range cp.Files {
+			output.Info("        " + displayPath(path))
+		}
+	}
+}
+
+// rewindCheckpoint previews or restores files from the last n checkpoints.
+// Without --force it only shows the diff that restoring would apply.
+func (ch *CommandHandler) rewindCheckpoint(parts []string, output AgentOutput) {
 	a := ch.agent
 	if a.checkpointMgr == nil || !a.checkpointMgr.HasCheckpoints() {
 		output.Info("No checkpoints available to rewind")
 		return
 	}
-	restored, err := a.checkpointMgr.Rewind()
+
+	n := 1
+	force := false
+	for _, arg := range parts[1:] {
+		if arg == "--force" {
+			force = true
+			continue
+		}
+		v, err := strconv.Atoi(arg)
+		if err != nil || v < 1 {
+			output.ErrorStr("Usage: /rewind [n] [--force]")
+			return
+		}
+		n = v
+	}
+	if count := a.checkpointMgr.Count(); n > count {
+		output.ErrorStr(fmt.Sprintf("Only %d checkpoint(s) available", count))
+		return
+	}
+
+	if !force {
+		diffs, err := a.checkpointMgr.PreviewRewind(n)
+		if err != nil {
+			output.ErrorStr("Failed to preview rewind: " + err.Error())
+			return
+		}
+		if len(diffs) == 0 {
+			output.Info("Files already match the checkpoint; nothing to restore")
+		} else {
+			paths := make([]string, 0, len(diffs))
+			for path := range diffs {
+				paths = append(paths, path)
+			}
+			sort.Strings(paths)
+			output.Info(fmt.Sprintf("Rewinding %d checkpoint(s) would change %d file(s):", n, len(paths)))
+			for _, path := range paths {
+				output.Info(strings.TrimRight(diffs[path], "\n"))
+			}
+		}
+		arg := ""
+		if n > 1 {
+			arg = " " + strconv.Itoa(n)
+		}
+		output.Info("Use /rewind" + arg + " --force to restore")
+		return
+	}
+
+	restored, err := a.checkpointMgr.Rewind(n)
 	if err != nil {
 		output.Warning("Rewind completed with errors: " + err.Error())
 	}
 	if len(restored) == 0 {
-		output.Info("No files were changed in the last checkpoint")
+		output.Info("No files were changed in the rewound checkpoints")
 		return
 	}
 	output.Success(fmt.Sprintf("Rewound %d file(s):", len(restored)))
 	for _, path := range restored {
-		output.Info("  " + path)
+		output.Info("  " + displayPath(path))
+	}
+}
+
+// displayPath shortens an absolute path to be relative to the working directory.
+func displayPath(path string) string {
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+	return path
+}
+
+// handleWorktree manages worktree isolation for Build mode tasks.
+func (ch *CommandHandler) handleWorktree(parts []string, output AgentOutput) {
+	a := ch.agent
+	if len(parts) < 2 {
+		state := "off"
+		if a.config.Agent.WorktreeIsolation {
+			state = "on"
+		}
+		output.Info("Worktree isolation: " + state)
+		if a.worktree == nil {
+			output.Info("No active worktree")
+			return
+		}
+		output.Info(fmt.Sprintf("Active worktree: %s (%s)", a.worktree.Branch, a.worktree.Dir))
+		if stat, err := a.worktree.DiffStat(); err != nil {
+			output.Warning("Failed to diff worktree: " + err.Error())
+		} else if stat != "" {
+			output.Info(stat)
+		}
+		return
+	}
+
+	switch parts[1] {
+	case "on":
+		a.config.Agent.WorktreeIsolation = true
+		output.Success("Worktree isolation enabled for Build mode tasks")
+	case "off":
+		if a.worktree != nil {
+			output.ErrorStr("Resolve the active worktree first: /worktree merge, squash or discard")
+			return
+		}
+		a.config.Agent.WorktreeIsolation = false
+		output.Success("Worktree isolation disabled")
+	case "diff":
+		if a.worktree == nil {
+			output.Info("No active worktree")
+			return
+		}
+		diff, err := a.worktree.Diff()
+		if err != nil {
+			output.ErrorStr("Failed to diff worktree: " + err.Error())
+		} else if diff == "" {
+			output.Info("No changes in the worktree")
+		} else {
+			output.Info(diff)
+		}
+	case "merge", "squash", "discard":
+		msg, err := a.resolveWorktree(parts[1])
+		if err != nil {
+			output.ErrorStr("Worktree " + parts[1] + " failed: " + err.Error())
+			return
+		}
+		output.Success(msg)
+	default:
+		output.ErrorStr("Usage: /worktree [on|off|diff|merge|squash|discard]")
 	}
 }
 
@@ -485,5 +667,10 @@ func (ch *CommandHandler) deleteSession(parts []string, output AgentOutput) {
 		output.ErrorStr("Failed to delete session: " + err.Error())
 		return
 	}
+	if a.checkpointMgr != nil {
+		if err := a.checkpointMgr.DeleteSession(found); err != nil {
+			output.Warning("Failed to delete checkpoints: " + err.Error())
+		}
+	}
 	output.Success(fmt.Sprintf("Deleted session %s", found[:8]))
 }
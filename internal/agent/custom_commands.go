@@ -0,0 +1,134 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/abdul-hamid-achik/vecai/internal/commands"
+	"github.com/abdul-hamid-achik/vecai/internal/tui"
+)
+
+// parseAgentMode parses an agent mode name: ask, plan or build.
+func parseAgentMode(name string) (tui.AgentMode, bool) {
+	switch name {
+	case "ask":
+		return tui.ModeAsk, true
+	case "plan":
+		return tui.ModePlan, true
+	case "build":
+		return tui.ModeBuild, true
+	}
+	return 0, false
+}
+
+// runCustomCommand expands a user-defined command and runs the prompt as a
+// query in the command's mode and tier, limited to its allowed tools. The
+// previous mode is restored afterwards.
+func (ch *CommandHandler) runCustomCommand(cmd *commands.Command, args string, output AgentOutput, cmdCtx CommandContext) {
+	a := ch.agent
+	input, ok := output.(AgentInput)
+	if !ok {
+		output.ErrorStr("/" + cmd.Name + " cannot run here")
+		return
+	}
+
+	ctx := context.Background()
+	prompt, files, err := cmd.Expand(ctx, args)
+	if err != nil {
+		output.ErrorStr(fmt.Sprintf("/%s failed: %v", cmd.Name, err))
+		return
+	}
+
+	if mode, ok := parseAgentMode(cmd.Mode); ok && mode != a.agentMode {
+		previous := a.agentMode
+		a.applyModeChange(mode, false)
+		cmdCtx.SetAgentMode(mode)
+		defer func() {
+			a.applyModeChange(previous, false)
+			cmdCtx.SetAgentMode(previous)
+		}()
+	}
+
+	// A fixed model or mode turns off automatic tier and mode selection
+	if cmd.Model != "" || cmd.Mode != "" {
+		autoTier := a.autoTier
+		tier := cmd.Model
+		if tier == "" && autoTier && !a.quickMode {
+			tier = a.selectTierForMode(prompt)
+		}
+		model := a.llm.GetModel()
+		if tier != "" {
+			a.llm.SetTier(tier)
+			a.syncContextWindow()
+		}
+		a.autoTier = false
+		defer func() {
+			if tier != "" {
+				a.llm.SetModel(model)
+				a.syncContextWindow()
+			}
+			a.autoTier = autoTier
+		}()
+	}
+
+	if len(cmd.AllowedTools) > 0 {
+		a.activeCommand = cmd
+		a.toolExecutor.allowTool = cmd.AllowsTool
+		defer func() {
+			a.activeCommand = nil
+			a.toolExecutor.allowTool = nil
+		}()
+	}
+
+	tagged := make([]tui.TaggedFile, 0, len(files))
+	for _, f := range files {
+		abs, _ := filepath.Abs(f)
+		tagged = append(tagged, tui.TaggedFile{RelPath: f, AbsPath: abs})
+	}
+	if err := a.runQuery(ctx, prompt, formatTaggedFileContext(tagged), output, input); err != nil {
+		output.Error(err)
+	}
+}
+
+// showCustomCommands lists the user-defined commands for /help.
+func (ch *CommandHandler) showCustomCommands(output AgentOutput) {
+	list := ch.agent.commands.List()
+	if len(list) == 0 {
+		return
+	}
+	text := "Custom commands:"
+	for _, cmd := range list {
+		name := "/" + cmd.Name
+		if cmd.ArgumentHint != "" {
+			name += " " + cmd.ArgumentHint
+		}
+		text += fmt.Sprintf("\n  %-16s %s", name, cmd.Description)
+	}
+	output.Info(text)
+}
+
+// injectCustomCommands adds the user-defined commands to the TUI slash
+// completer, skipping any a built-in command shadows.
+func (a *Agent) injectCustomCommands(runner *tui.TUIRunner) {
+	builtin := make(map[string]bool, len(tui.BuiltinCommands))
+	for _, def := range tui.BuiltinCommands {
+		builtin[def.Name] = true
+	}
+	var defs []tui.CommandDef
+	for _, cmd := range a.commands.List() {
+		name := "/" + cmd.Name
+		if builtin[name] {
+			continue
+		}
+		defs = append(defs, tui.CommandDef{
+			Name:        name,
+			Description: cmd.Description,
+			HasArgs:     cmd.ArgumentHint != "",
+			ArgHint:     cmd.ArgumentHint,
+		})
+	}
+	if len(defs) > 0 {
+		runner.AddSkillCommands(defs)
+	}
+}
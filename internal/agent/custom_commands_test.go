@@ -0,0 +1,148 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/abdul-hamid-achik/vecai/internal/commands"
+	"github.com/abdul-hamid-achik/vecai/internal/config"
+	"github.com/abdul-hamid-achik/vecai/internal/llm"
+	"github.com/abdul-hamid-achik/vecai/internal/tui"
+)
+
+// mockIO is an output that also answers input, like CLIOutput and TUIOutput.
+type mockIO struct {
+	*mockOutput
+	*mockInput
+}
+
+// tierClient switches models by tier, like the real clients.
+type tierClient struct {
+	*llm.MockLLMClient
+	cfg *config.Config
+}
+
+func (c *tierClient) SetTier(tier config.ModelTier) {
+	c.MockLLMClient.SetTier(tier)
+	c.SetModel(c.cfg.GetModel(tier))
+}
+
+func TestHandle_CustomCommand(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if err := os.WriteFile("main.go", []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmdDir := filepath.Join(dir, commands.ProjectDir)
+	if err := os.MkdirAll(cmdDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	content := "---\ndescription: Review a file\nargument-hint: <file>\nallowed-tools: read_file, grep\nmode: plan\n---\nReview @$1 for $2 bugs.\n"
+	if err := os.WriteFile(filepath.Join(cmdDir, "review.md"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	a, mock := newTestAgent(t)
+	loader, errs := commands.LoadDirs(cmdDir)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	a.commands = loader
+	a.applyModeChange(tui.ModeBuild, false)
+
+	var toolNames []string
+	var messages []llm.Message
+	var mode tui.AgentMode
+	mock.ChatStreamFunc = func(_ context.Context, msgs []llm.Message, defs []llm.ToolDefinition, _ string) <-chan llm.StreamChunk {
+		messages, mode = msgs, a.agentMode
+		for _, d := range defs {
+			toolNames = append(toolNames, d.Name)
+		}
+		ch := make(chan llm.StreamChunk, 3)
+		ch <- llm.StreamChunk{Type: "tool_call", ToolCall: &llm.ToolCall{ID: "c1", Name: "bash", Input: map[string]any{"command": "ls"}}}
+		ch <- llm.StreamChunk{Type: "done"}
+		close(ch)
+		return ch
+	}
+
+	out := &mockIO{&mockOutput{}, &mockInput{}}
+	a.config.Agent.MaxIterations = 1
+	a.config.Agent.AutoEscalate = false
+	a.commandHandler.Handle(`/review main.go "concurrency"`, out, &CLICommandContext{})
+
+	if mode != tui.ModePlan {
+		t.Errorf("expected the command to run in plan mode, got %s", mode)
+	}
+	if strings.Join(toolNames, ",") != "read_file,grep" && strings.Join(toolNames, ",") != "grep,read_file" {
+		t.Errorf("expected only the allowed tools, got %v", toolNames)
+	}
+	if len(messages) < 2 || messages[0].Content != "Review @main.go for concurrency bugs." || !strings.Contains(messages[1].Content, "package main") {
+		t.Errorf("expected the expanded prompt and attached file, got %+v", messages)
+	}
+
+	// The model asked for a tool the command does not allow
+	var result string
+	for _, m := range a.contextMgr.GetMessages() {
+		if m.Role == "tool" {
+			result = m.Content
+		}
+	}
+	if result != "Tool bash is not allowed by this command" {
+		t.Errorf("expected bash to be refused, got %q", result)
+	}
+
+	if a.agentMode != tui.ModeBuild || a.activeCommand != nil || a.toolExecutor.allowTool != nil {
+		t.Error("expected the command's mode and tool limits to be undone")
+	}
+}
+
+func TestHandle_CustomCommandRestoresModel(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	t.Chdir(dir)
+	cmdDir := filepath.Join(dir, commands.ProjectDir)
+	if err := os.MkdirAll(cmdDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	content := "---\ndescription: Quick answer\nmodel: fast\n---\nAnswer $ARGUMENTS.\n"
+	if err := os.WriteFile(filepath.Join(cmdDir, "quick.md"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	a, mock := newTestAgent(t)
+	loader, errs := commands.LoadDirs(cmdDir)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	a.commands = loader
+	a.llm = &tierClient{mock, a.config}
+	a.llm.SetTier(config.TierSmart)
+	a.syncContextWindow()
+	model, window := a.llm.GetModel(), a.contextMgr.GetStats().ContextWindow
+
+	var used string
+	var usedWindow int
+	mock.ChatStreamFunc = func(_ context.Context, _ []llm.Message, _ []llm.ToolDefinition, _ string) <-chan llm.StreamChunk {
+		used, usedWindow = a.llm.GetModel(), a.contextMgr.GetStats().ContextWindow
+		ch := make(chan llm.StreamChunk, 2)
+		ch <- llm.StreamChunk{Type: "text", Text: "done"}
+		ch <- llm.StreamChunk{Type: "done"}
+		close(ch)
+		return ch
+	}
+
+	out := &mockIO{&mockOutput{}, &mockInput{}}
+	a.config.Agent.AutoEscalate = false
+	a.commandHandler.Handle("/quick now", out, &CLICommandContext{})
+
+	if used != a.config.GetModel(config.TierFast) || usedWindow == window {
+		t.Errorf("expected the command to run on the fast model, got %s with a %d window", used, usedWindow)
+	}
+	if a.llm.GetModel() != model || a.contextMgr.GetStats().ContextWindow != window {
+		t.Errorf("expected %s with a %d window after the command, got %s with %d", model, window, a.llm.GetModel(), a.contextMgr.GetStats().ContextWindow)
+	}
+}
@@ -0,0 +1,174 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/abdul-hamid-achik/vecai/internal/config"
+	"github.com/abdul-hamid-achik/vecai/internal/llm"
+	"github.com/abdul-hamid-achik/vecai/internal/logging"
+)
+
+// defaultStuckThreshold is used when agent.stuck_threshold is unset
+const defaultStuckThreshold = 3
+
+// stuckParseErrors is the stuck reason for repeated malformed tool calls.
+// The loop stops on it when it cannot escalate, since the model will not
+// recover on its own.
+const stuckParseErrors = "malformed tool calls"
+
+// stuckDetector spots agent loops that stopped making progress: tool calls
+// that keep failing to parse, the same calls repeated every iteration, or
+// iterations that produce no new successful tool result.
+type stuckDetector struct {
+	threshold int
+
+	parseErrors   int // Consecutive iterations whose calls all failed to parse
+	lastSignature string
+	repeats       int // Consecutive iterations repeating the previous calls
+	idle          int // Consecutive iterations without a new successful result
+	seen          map[string]bool
+}
+
+func newStuckDetector(threshold int) *stuckDetector {
+	if threshold <= 0 {
+		threshold = defaultStuckThreshold
+	}
+	return &stuckDetector{threshold: threshold, seen: make(map[string]bool)}
+}
+
+// observe records one iteration's tool calls and results and returns why
+// the loop looks stuck, or "" while it is making progress.
+func (d *stuckDetector) observe(calls []llm.ToolCall, results []toolResult) string {
+	if len(calls) == 0 {
+		return ""
+	}
+
+	allParseErrors := len(results) > 0
+	for _, result := range results {
+		if !isParseError(result) {
+			allParseErrors = false
+		}
+	}
+	if allParseErrors {
+		d.parseErrors++
+	} else {
+		d.parseErrors = 0
+	}
+
+	signature := callsSignature(calls)
+	if signature == d.lastSignature {
+		d.repeats++
+	} else {
+		d.lastSignature = signature
+		d.repeats = 0
+	}
+
+	progress := false
+	for i, call := range calls {
+		key := callsSignature(calls[i : i+1])
+		failed := call.ParseError != "" || (i < len(results) && results[i].Error)
+		if !failed && !d.seen[key] {
+			progress = true
+		}
+		d.seen[key] = true
+	}
+	if progress {
+		d.idle = 0
+	} else {
+		d.idle++
+	}
+
+	switch {
+	case d.parseErrors >= d.threshold:
+		return stuckParseErrors
+	case d.repeats+1 >= d.threshold:
+		return "repeated the same tool call"
+	case d.idle >= 2*d.threshold:
+		return fmt.Sprintf("no progress in %d iterations", d.idle)
+	}
+	return ""
+}
+
+// isParseError reports whether a tool result is the executor's rejection
+// of arguments that did not parse.
+func isParseError(result toolResult) bool {
+	return result.Error && strings.Contains(result.Result, "could not parse arguments")
+}
+
+// reset forgets the streaks, keeping calls already seen, after the loop
+// switched to a new model.
+func (d *stuckDetector) reset() {
+	d.parseErrors = 0
+	d.lastSignature = ""
+	d.repeats = 0
+	d.idle = 0
+}
+
+// callsSignature identifies a set of tool calls by name and arguments.
+func callsSignature(calls []llm.ToolCall) string {
+	parts := make([]string, 0, len(calls))
+	for _, call := range calls {
+		args, _ := json.Marshal(call.Input) // map keys marshal sorted
+		parts = append(parts, call.Name+string(args))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, "\n")
+}
+
+// escalateTier moves the running task to the next stronger tier, keeping
+// the conversation. It returns false when escalation is disabled, the model
+// is not a configured tier, or no stronger model is configured.
+func (a *Agent) escalateTier(output AgentOutput, reason string) bool {
+	if !a.config.Agent.AutoEscalate || a.quickMode {
+		return false
+	}
+	from, ok := a.modelTier()
+	if !ok {
+		return false
+	}
+
+	// Skip tiers configured with the model already running
+	current := a.llm.GetModel()
+	to := from
+	for {
+		switch to {
+		case config.TierFast:
+			to = config.TierSmart
+		case config.TierSmart:
+			to = config.TierGenius
+		default:
+			return false
+		}
+		if a.config.GetModel(to) != current {
+			break
+		}
+	}
+
+	a.llm.SetTier(to)
+	a.syncContextWindow()
+	a.escalation = fmt.Sprintf("%s→%s: %s", from, to, reason)
+	a.noteTierSwitch(to)
+
+	output.Warning(fmt.Sprintf("Escalating from %s to %s model (%s)", from, to, reason))
+	output.ModelInfo(a.llm.GetModel())
+	if es, ok := output.(EscalationSupport); ok {
+		es.TierEscalated(from, to, reason)
+	}
+	if log := logging.Global(); log != nil {
+		log.Event(logging.EventAgentTierChange,
+			logging.Tier(string(to)),
+			logging.Reason("escalated: "+reason),
+		)
+	}
+
+	// Let the new model know why it is taking over
+	a.contextMgr.AddMessage(llm.Message{
+		Role: "user",
+		Content: fmt.Sprintf("[The previous model got stuck (%s) and a stronger model is taking over. "+
+			"Review the conversation so far, avoid repeating failed tool calls, and continue the task.]", reason),
+	})
+	return true
+}
@@ -0,0 +1,111 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/abdul-hamid-achik/vecai/internal/config"
+	"github.com/abdul-hamid-achik/vecai/internal/llm"
+)
+
+func readCall(path string) llm.ToolCall {
+	return llm.ToolCall{Name: "read_file", Input: map[string]any{"path": path}}
+}
+
+func okResult(call llm.ToolCall) toolResult {
+	return toolResult{Name: call.Name, Result: "contents"}
+}
+
+func TestStuckDetector_RepeatedCalls(t *testing.T) {
+	d := newStuckDetector(3)
+	call := readCall("main.go")
+
+	for i := 0; i < 2; i++ {
+		if reason := d.observe([]llm.ToolCall{call}, []toolResult{okResult(call)}); reason != "" {
+			t.Fatalf("iteration %d: unexpected stuck reason %q", i, reason)
+		}
+	}
+	if reason := d.observe([]llm.ToolCall{call}, []toolResult{okResult(call)}); reason != "repeated the same tool call" {
+		t.Errorf("expected repeated call detection, got %q", reason)
+	}
+
+	d.reset()
+	if reason := d.observe([]llm.ToolCall{call}, []toolResult{okResult(call)}); reason != "" {
+		t.Errorf("expected reset to clear the streak, got %q", reason)
+	}
+}
+
+func TestStuckDetector_ParseErrors(t *testing.T) {
+	d := newStuckDetector(3)
+	bad := llm.ToolCall{Name: "edit_file", ParseError: "unexpected end of JSON input"}
+	result := toolResult{Name: "edit_file", Error: true, Result: "Tool call 'edit_file' failed: could not parse arguments (EOF)."}
+
+	var reason string
+	for i := 0; i < 3; i++ {
+		reason = d.observe([]llm.ToolCall{bad}, []toolResult{result})
+	}
+	if reason != stuckParseErrors {
+		t.Errorf("expected parse error detection, got %q", reason)
+	}
+
+	// A parsed call breaks the streak
+	good := readCall("go.mod")
+	if reason := d.observe([]llm.ToolCall{good}, []toolResult{okResult(good)}); reason != "" {
+		t.Errorf("expected progress, got %q", reason)
+	}
+}
+
+func TestStuckDetector_NoProgress(t *testing.T) {
+	d := newStuckDetector(2)
+	a, b := readCall("a.go"), readCall("b.go")
+	failed := func(call llm.ToolCall) []toolResult {
+		return []toolResult{{Name: call.Name, Error: true, Result: "file not found"}}
+	}
+
+	// Alternating failing calls never repeat back to back
+	var reason string
+	for i := 0; i < 4; i++ {
+		call := a
+		if i%2 == 1 {
+			call = b
+		}
+		reason = d.observe([]llm.ToolCall{call}, failed(call))
+	}
+	if !strings.HasPrefix(reason, "no progress") {
+		t.Errorf("expected no-progress detection, got %q", reason)
+	}
+}
+
+func TestEscalateTier(t *testing.T) {
+	a, mock := newTestAgent(t)
+	mock.SetModel(a.config.GetModel(config.TierFast))
+	out := &JSONOutput{}
+
+	if !a.escalateTier(out, "repeated the same tool call") {
+		t.Fatal("expected escalation from fast")
+	}
+	if mock.CurrentTier != config.TierSmart {
+		t.Errorf("expected smart tier, got %s", mock.CurrentTier)
+	}
+	if a.escalation != "fast→smart: repeated the same tool call" {
+		t.Errorf("unexpected status %q", a.escalation)
+	}
+	if len(out.escalations) != 1 || out.escalations[0].To != config.TierSmart {
+		t.Errorf("expected the escalation in the JSON output, got %+v", out.escalations)
+	}
+	messages := a.contextMgr.GetMessages()
+	if last := messages[len(messages)-1]; !strings.Contains(last.Content, "stronger model is taking over") {
+		t.Errorf("expected a handover note, got %q", last.Content)
+	}
+
+	mock.SetModel(a.config.GetModel(config.TierGenius))
+	if a.escalateTier(out, "malformed tool calls") {
+		t.Error("expected no escalation past genius")
+	}
+
+	mock.SetModel(a.config.GetModel(config.TierFast))
+	a.config.Agent.AutoEscalate = false
+	if a.escalateTier(out, "malformed tool calls") {
+		t.Error("expected no escalation when disabled")
+	}
+}
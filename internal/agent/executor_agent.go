@@ -263,7 +263,7 @@ func (e *ExecutorAgent) getToolsForStepType(stepType string) []llm.ToolDefinitio
 	case "code":
 		toolNames = []string{
 			"read_file", "list_files", "grep",
-			"write_file", "edit_file",
+			"write_file", "edit_file", "apply_patch",
 			"ast_parse", "lsp_query",
 		}
 	case "test":
@@ -352,7 +352,7 @@ func (e *ExecutorAgent) executeToolCall(ctx context.Context, tc llm.ToolCall) To
 
 	// Check permission
 	description := fmt.Sprintf("Execute %s", tc.Name)
-	allowed, err := e.permissions.Check(tc.Name, tool.Permission(), description)
+	allowed, err := e.permissions.CheckInput(tc.Name, tool.Permission(), description, tc.Input)
 	if err != nil {
 		result.Error = fmt.Errorf("permission check failed: %w", err)
 		result.Output = result.Error.Error()
@@ -0,0 +1,76 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/abdul-hamid-achik/vecai/internal/hooks"
+	"github.com/abdul-hamid-achik/vecai/internal/llm"
+)
+
+// ErrPromptBlocked is returned when a UserPromptSubmit hook blocks a prompt.
+var ErrPromptBlocked = errors.New("prompt blocked by hook")
+
+// runHook runs the hooks for an event and shows their warnings on output,
+// which may be nil.
+func (a *Agent) runHook(ctx context.Context, in hooks.Input, output AgentOutput) hooks.Outcome {
+	if !a.hooks.Has(in.Event) {
+		return hooks.Outcome{}
+	}
+	in.SessionID = a.currentSessionID()
+	out := a.hooks.Run(ctx, in)
+	for _, w := range out.Warnings {
+		if output != nil {
+			output.Warning(w)
+		} else {
+			logWarn("%s", w)
+		}
+	}
+	return out
+}
+
+// submitPrompt runs the SessionStart hooks before the agent's first prompt
+// and the UserPromptSubmit hooks before every prompt. It returns the context
+// the hooks added, or ErrPromptBlocked.
+func (a *Agent) submitPrompt(ctx context.Context, prompt string, output AgentOutput) (string, error) {
+	var hookCtx string
+	if !a.sessionStarted {
+		a.sessionStarted = true
+		hookCtx = a.runHook(ctx, hooks.Input{Event: hooks.SessionStart, Prompt: prompt}, output).Context
+	}
+
+	out := a.runHook(ctx, hooks.Input{Event: hooks.UserPromptSubmit, Prompt: prompt}, output)
+	if out.Blocked {
+		return "", fmt.Errorf("%w: %s", ErrPromptBlocked, out.Reason)
+	}
+	if out.Context != "" {
+		if hookCtx != "" {
+			hookCtx += "\n\n"
+		}
+		hookCtx += out.Context
+	}
+	return hookCtx, nil
+}
+
+// addHookContext adds context from prompt hooks after the user's prompt.
+func (a *Agent) addHookContext(hookCtx string) {
+	if hookCtx == "" {
+		return
+	}
+	a.contextMgr.AddMessage(llm.Message{
+		Role:    "user",
+		Content: "[Context from hooks]\n" + hookCtx,
+	})
+}
+
+// stopHook runs the Stop hooks once the model has answered without tool
+// calls. It returns the reason a hook gave for keeping the agent going, or
+// "" to stop. active is true when a Stop hook already did so in this loop.
+func (a *Agent) stopHook(ctx context.Context, response string, active bool, output AgentOutput) string {
+	out := a.runHook(ctx, hooks.Input{Event: hooks.Stop, Response: response, StopHookActive: active}, output)
+	if !out.Blocked {
+		return ""
+	}
+	return out.Reason
+}
@@ -0,0 +1,140 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/abdul-hamid-achik/vecai/internal/config"
+	"github.com/abdul-hamid-achik/vecai/internal/hooks"
+	"github.com/abdul-hamid-achik/vecai/internal/llm"
+	"github.com/abdul-hamid-achik/vecai/internal/permissions"
+)
+
+func newHookRunner(t *testing.T, cfg map[string][]config.HookConfig) *hooks.Runner {
+	t.Helper()
+	r, err := hooks.New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestExecuteToolCalls_Hooks(t *testing.T) {
+	registry := newMockRegistry(&mockWriteTool{name: "writer"}, &mockReadTool{name: "reader"})
+	te := newTestToolExecutor(registry, permissions.ModeAuto)
+	te.hooks = newHookRunner(t, map[string][]config.HookConfig{
+		hooks.PreToolUse: {
+			{Matcher: "writer", Command: `grep -q '"path":"migrations/' && { echo "migrations are read-only" >&2; exit 2; }; echo '{"updated_input": {"path": "b.go"}}'`},
+		},
+		hooks.PostToolUse: {
+			{Matcher: "writer", Command: `grep -q '"path":"b.go"' && echo "gofmt: ok"`},
+		},
+	})
+
+	calls := []llm.ToolCall{
+		{ID: "c1", Name: "writer", Input: map[string]any{"path": "migrations/001.sql"}},
+		{ID: "c2", Name: "writer", Input: map[string]any{"path": "a.go"}},
+		{ID: "c3", Name: "reader", Input: map[string]any{"path": "a.go"}},
+		{ID: "c4", Name: "reader", Input: map[string]any{"path": "b.go"}},
+	}
+	results := te.ExecuteToolCalls(context.Background(), calls, &mockOutput{}, &mockInput{})
+
+	if !results[0].Error || results[0].Result != "Blocked by hook: migrations are read-only" {
+		t.Errorf("expected the hook to block the migration, got %+v", results[0])
+	}
+	if results[1].Error || results[1].Result != "write-result\n\n[Hook] gofmt: ok" {
+		t.Errorf("expected the rewritten call to run with hook context, got %+v", results[1])
+	}
+	if results[2].Result != "read-result" || results[3].Result != "read-result" {
+		t.Errorf("expected unmatched tools to run untouched, got %+v", results[2:])
+	}
+}
+
+func TestCanParallelize_Hooks(t *testing.T) {
+	registry := newMockRegistry(&mockReadTool{name: "tool_a"}, &mockReadTool{name: "tool_b"})
+	te := newTestToolExecutor(registry, permissions.ModeAuto)
+	te.hooks = newHookRunner(t, map[string][]config.HookConfig{
+		hooks.PostToolUse: {{Command: "true"}},
+	})
+	calls := []llm.ToolCall{{Name: "tool_a"}, {Name: "tool_b"}}
+	if te.canParallelize(calls) {
+		t.Error("expected tool hooks to force sequential execution")
+	}
+}
+
+func TestSubmitPrompt_Hooks(t *testing.T) {
+	a, _ := newTestAgent(t)
+	a.hooks = newHookRunner(t, map[string][]config.HookConfig{
+		hooks.SessionStart: {{Command: "echo on branch main"}},
+		hooks.UserPromptSubmit: {
+			{Command: `grep -q password && { echo "no secrets" >&2; exit 2; }; echo "style guide applies"`},
+		},
+	})
+	out := &mockOutput{}
+
+	hookCtx, err := a.submitPrompt(context.Background(), "fix the bug", out)
+	if err != nil || hookCtx != "on branch main\n\nstyle guide applies" {
+		t.Errorf("unexpected first prompt context %q, %v", hookCtx, err)
+	}
+	hookCtx, err = a.submitPrompt(context.Background(), "fix it again", out)
+	if err != nil || hookCtx != "style guide applies" {
+		t.Errorf("expected SessionStart to run once, got %q, %v", hookCtx, err)
+	}
+	if _, err := a.submitPrompt(context.Background(), "my password is hunter2", out); !errors.Is(err, ErrPromptBlocked) || !strings.Contains(err.Error(), "no secrets") {
+		t.Errorf("expected the prompt to be blocked, got %v", err)
+	}
+}
+
+func TestRunAgentLoop_StopHookContinues(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	a, mock := newTestAgent(t)
+	a.hooks = newHookRunner(t, map[string][]config.HookConfig{
+		hooks.Stop: {{Command: `grep -q stop_hook_active || echo '{"decision": "block", "reason": "run the tests first"}'`}},
+	})
+
+	var mu sync.Mutex
+	turns := 0
+	mock.ChatStreamFunc = func(_ context.Context, _ []llm.Message, _ []llm.ToolDefinition, _ string) <-chan llm.StreamChunk {
+		mu.Lock()
+		turns++
+		mu.Unlock()
+		ch := make(chan llm.StreamChunk, 2)
+		ch <- llm.StreamChunk{Type: "text", Text: "done"}
+		ch <- llm.StreamChunk{Type: "done"}
+		close(ch)
+		return ch
+	}
+
+	if err := a.runAgentLoop(context.Background(), &mockOutput{}, &mockInput{}); err != nil {
+		t.Fatal(err)
+	}
+	if turns != 2 {
+		t.Errorf("expected one extra turn after the Stop hook, got %d turns", turns)
+	}
+	found := false
+	for _, m := range a.contextMgr.GetMessages() {
+		found = found || (m.Role == "user" && m.Content == "[Stop hook] run the tests first")
+	}
+	if !found {
+		t.Error("expected the hook's reason in the conversation")
+	}
+}
+
+func TestCompactConversation_PreCompactHook(t *testing.T) {
+	a, _ := newTestAgent(t)
+	a.hooks = newHookRunner(t, map[string][]config.HookConfig{
+		hooks.PreCompact: {{Command: `grep -q '"trigger":"manual"' && { echo "keep history" >&2; exit 2; }; true`}},
+	})
+	a.contextMgr.AddMessage(llm.Message{Role: "user", Content: "hello"})
+
+	err := a.compactConversation(context.Background(), hooks.TriggerManual, "", &mockOutput{})
+	if err == nil || !strings.Contains(err.Error(), "keep history") {
+		t.Errorf("expected the hook to block compaction, got %v", err)
+	}
+	if len(a.contextMgr.GetMessages()) != 1 {
+		t.Error("expected the conversation to be untouched")
+	}
+}
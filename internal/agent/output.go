@@ -1,6 +1,10 @@
 package agent
 
 import (
+	"context"
+
+	"github.com/abdul-hamid-achik/vecai/internal/config"
+	"github.com/abdul-hamid-achik/vecai/internal/llm"
 	"github.com/abdul-hamid-achik/vecai/internal/tools"
 	"github.com/abdul-hamid-achik/vecai/internal/tui"
 )
@@ -48,6 +52,13 @@ type AgentInput interface {
 	Confirm(prompt string, defaultYes bool) (bool, error)
 }
 
+// PermissionDecider is optionally implemented by inputs that answer
+// permission prompts without a user. When implemented, the tool executor
+// passes it the whole call instead of prompting through ReadLine.
+type PermissionDecider interface {
+	DecidePermission(ctx context.Context, req PermissionRequest) (bool, error)
+}
+
 // InterruptSupport is optionally implemented by outputs that support interruption.
 type InterruptSupport interface {
 	GetInterruptChan() <-chan struct{}
@@ -64,6 +75,34 @@ type PlanSupport interface {
 	PlanUpdate(text string)
 }
 
+// ToolCallSupport is optionally implemented by outputs that match tool
+// results to calls by ID. When implemented, the tool executor reports calls
+// through it instead of ToolCall and ToolResult.
+type ToolCallSupport interface {
+	ToolCallStarted(call llm.ToolCall, description string)
+	ToolCallFinished(call llm.ToolCall, result string, isError bool)
+}
+
+// SubagentSupport is optionally implemented by outputs that show the tool
+// calls of task subagents nested under the task. task is the label the
+// model gave it; call IDs are unique across subagents.
+type SubagentSupport interface {
+	SubagentToolStarted(task string, call llm.ToolCall, description string)
+	SubagentToolFinished(task string, call llm.ToolCall, result string, isError bool)
+}
+
+// ModeSupport is optionally implemented by outputs that report agent mode
+// switches made by the agent itself.
+type ModeSupport interface {
+	ModeChanged(mode tui.AgentMode)
+}
+
+// EscalationSupport is optionally implemented by outputs that report
+// automatic tier escalations.
+type EscalationSupport interface {
+	TierEscalated(from, to config.ModelTier, reason string)
+}
+
 // StatsSupport is optionally implemented by outputs that display stats.
 type StatsSupport interface {
 	UpdateContextStats(usagePercent float64, usedTokens, contextWindow int, needsWarning bool)
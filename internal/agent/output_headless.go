@@ -6,6 +6,8 @@ import (
 	"os"
 	"strings"
 
+	"github.com/abdul-hamid-achik/vecai/internal/config"
+	"github.com/abdul-hamid-achik/vecai/internal/llm"
 	"github.com/abdul-hamid-achik/vecai/internal/tools"
 )
 
@@ -32,7 +34,8 @@ func (h *HeadlessOutput) Info(msg string)     {} // Suppress in headless
 func (h *HeadlessOutput) ToolCall(_, _ string) {}
 func (h *HeadlessOutput) ToolResult(_, _ string, _ bool) {
 }
-func (h *HeadlessOutput) PermissionPrompt(_ string, _ tools.PermissionLevel, _ string) {
+func (h *HeadlessOutput) PermissionPrompt(toolName string, _ tools.PermissionLevel, _ string) {
+	fmt.Fprintf(os.Stderr, "Warning: denied %s, which needs permission (see --allow-tools and --permission-prompt-cmd)\n", toolName)
 }
 func (h *HeadlessOutput) Header(_ string)     {}
 func (h *HeadlessOutput) Separator()          {}
@@ -56,22 +59,32 @@ func (h *HeadlessInput) Confirm(_ string, _ bool) (bool, error) {
 
 // JSONOutput captures all output and emits a single JSON object at the end.
 type JSONOutput struct {
-	textBuf   strings.Builder
-	toolCalls []jsonToolCall
-	errors    []string
+	textBuf     strings.Builder
+	toolCalls   []jsonToolCall
+	errors      []string
+	escalations []jsonEscalation
 }
 
 type jsonToolCall struct {
-	Name        string `json:"name"`
-	Description string `json:"description,omitempty"`
-	Result      string `json:"result,omitempty"`
-	IsError     bool   `json:"is_error,omitempty"`
+	ID          string         `json:"id,omitempty"`
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Input       map[string]any `json:"input,omitempty"`
+	Result      string         `json:"result,omitempty"`
+	IsError     bool           `json:"is_error,omitempty"`
+}
+
+type jsonEscalation struct {
+	From   config.ModelTier `json:"from"`
+	To     config.ModelTier `json:"to"`
+	Reason string           `json:"reason"`
 }
 
 type jsonResult struct {
-	Text      string         `json:"text"`
-	ToolCalls []jsonToolCall `json:"tool_calls,omitempty"`
-	Errors    []string       `json:"errors,omitempty"`
+	Text        string           `json:"text"`
+	ToolCalls   []jsonToolCall   `json:"tool_calls,omitempty"`
+	Errors      []string         `json:"errors,omitempty"`
+	Escalations []jsonEscalation `json:"escalations,omitempty"`
 }
 
 func (j *JSONOutput) StreamText(text string)         { j.textBuf.WriteString(text) }
@@ -99,21 +112,47 @@ func (j *JSONOutput) ToolResult(name, result string, isError bool) {
 		}
 	}
 }
+
+// ToolCallStarted records a tool call with its ID and input.
+func (j *JSONOutput) ToolCallStarted(call llm.ToolCall, description string) {
+	j.toolCalls = append(j.toolCalls, jsonToolCall{ID: call.ID, Name: call.Name, Description: description, Input: call.Input})
+}
+
+// ToolCallFinished stores a result on the call with the same ID. Calls
+// rejected before they ran (parse errors, denied permission) have no entry
+// yet and are added with their result.
+func (j *JSONOutput) ToolCallFinished(call llm.ToolCall, result string, isError bool) {
+	for i := len(j.toolCalls) - 1; i >= 0; i-- {
+		if j.toolCalls[i].ID == call.ID {
+			j.toolCalls[i].Result = result
+			j.toolCalls[i].IsError = isError
+			return
+		}
+	}
+	j.toolCalls = append(j.toolCalls, jsonToolCall{ID: call.ID, Name: call.Name, Input: call.Input, Result: result, IsError: isError})
+}
+
 func (j *JSONOutput) PermissionPrompt(_ string, _ tools.PermissionLevel, _ string) {}
-func (j *JSONOutput) Header(_ string)                                               {}
-func (j *JSONOutput) Separator()                                                    {}
-func (j *JSONOutput) Thinking(_ string)                                             {}
-func (j *JSONOutput) ThinkingLn(_ string)                                           {}
-func (j *JSONOutput) ModelInfo(_ string)                                            {}
-func (j *JSONOutput) Activity(_ string)                                             {}
-func (j *JSONOutput) Done()                                                         {}
+func (j *JSONOutput) Header(_ string)                                              {}
+func (j *JSONOutput) Separator()                                                   {}
+func (j *JSONOutput) Thinking(_ string)                                            {}
+func (j *JSONOutput) ThinkingLn(_ string)                                          {}
+func (j *JSONOutput) ModelInfo(_ string)                                           {}
+func (j *JSONOutput) Activity(_ string)                                            {}
+func (j *JSONOutput) Done()                                                        {}
+
+// TierEscalated records an automatic tier escalation in the result.
+func (j *JSONOutput) TierEscalated(from, to config.ModelTier, reason string) {
+	j.escalations = append(j.escalations, jsonEscalation{From: from, To: to, Reason: reason})
+}
 
 // Emit writes the JSON result to stdout.
 func (j *JSONOutput) Emit() error {
 	result := jsonResult{
-		Text:      j.textBuf.String(),
-		ToolCalls: j.toolCalls,
-		Errors:    j.errors,
+		Text:        j.textBuf.String(),
+		ToolCalls:   j.toolCalls,
+		Errors:      j.errors,
+		Escalations: j.escalations,
 	}
 	enc := json.NewEncoder(os.Stdout)
 	enc.SetIndent("", "  ")
@@ -0,0 +1,212 @@
+package agent
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abdul-hamid-achik/vecai/internal/config"
+	"github.com/abdul-hamid-achik/vecai/internal/llm"
+	"github.com/abdul-hamid-achik/vecai/internal/tools"
+	"github.com/abdul-hamid-achik/vecai/internal/tui"
+)
+
+// StreamSchemaVersion is the "v" field of every stream-json event. It only
+// changes when an existing field changes meaning or goes away; new event
+// types and fields are added without a bump.
+const StreamSchemaVersion = 1
+
+// Stream event types
+const (
+	EventInit             = "init"              // Model and mode the run starts with
+	EventText             = "text"              // Assistant text delta
+	EventThinking         = "thinking"          // Reasoning delta
+	EventStreamDone       = "stream_done"       // End of one model response
+	EventUsage            = "usage"             // Token usage of one model response
+	EventMessage          = "message"           // Status line (level: info, success, warning, activity, header)
+	EventToolCall         = "tool_call"         // Tool call with its full input
+	EventToolResult       = "tool_result"       // Result of the tool call with the same id
+	EventPermissionPrompt = "permission_prompt" // Headless runs answer these with a denial
+	EventModel            = "model"             // Active model changed
+	EventMode             = "mode"              // Agent mode changed
+	EventEscalation       = "escalation"        // Automatic tier escalation
+	EventPlan             = "plan"              // Plan text, replaced by later plan events
+	EventError            = "error"
+	EventDone             = "done" // Last event of a run
+)
+
+// StreamEvent is one line of the stream-json protocol. Fields not used by
+// an event type are omitted.
+type StreamEvent struct {
+	Version   int       `json:"v"`
+	Seq       int64     `json:"seq"`
+	Type      string    `json:"type"`
+	Time      time.Time `json:"ts"`
+	ElapsedMs int64     `json:"elapsed_ms"`
+
+	Text  string `json:"text,omitempty"`
+	Level string `json:"level,omitempty"`
+
+	// Tool calls and permission prompts
+	ID          string         `json:"id,omitempty"`
+	Name        string         `json:"name,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Input       map[string]any `json:"input,omitempty"`
+	Result      string         `json:"result,omitempty"`
+	IsError     bool           `json:"is_error,omitempty"`
+	Permission  string         `json:"permission,omitempty"`
+
+	// Usage
+	InputTokens  int64 `json:"input_tokens,omitempty"`
+	OutputTokens int64 `json:"output_tokens,omitempty"`
+
+	// Model, mode and escalation
+	Model  string `json:"model,omitempty"`
+	Mode   string `json:"mode,omitempty"`
+	From   string `json:"from,omitempty"`
+	To     string `json:"to,omitempty"`
+	Reason string `json:"reason,omitempty"`
+
+	// Done
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms,omitempty"`
+}
+
+// StreamJSONOutput turns every output callback into a StreamEvent as it
+// happens, written as one NDJSON line or handed to a callback.
+type StreamJSONOutput struct {
+	mu    sync.Mutex
+	send  func(StreamEvent)
+	start time.Time
+	seq   int64
+
+	inputTokens  int64
+	outputTokens int64
+}
+
+// NewStreamJSONOutput creates a stream-json output writing to w. Write
+// errors are dropped; a consumer that went away cannot be told anyway.
+func NewStreamJSONOutput(w io.Writer) *StreamJSONOutput {
+	enc := json.NewEncoder(w)
+	return NewEventOutput(func(ev StreamEvent) { _ = enc.Encode(ev) })
+}
+
+// NewEventOutput creates an output that passes each event to send, in
+// order and one at a time.
+func NewEventOutput(send func(StreamEvent)) *StreamJSONOutput {
+	return &StreamJSONOutput{send: send, start: time.Now()}
+}
+
+// emit stamps and sends an event.
+func (s *StreamJSONOutput) emit(ev StreamEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	now := time.Now()
+	ev.Version = StreamSchemaVersion
+	ev.Seq = s.seq
+	ev.Time = now.UTC()
+	ev.ElapsedMs = now.Sub(s.start).Milliseconds()
+	s.send(ev)
+}
+
+func (s *StreamJSONOutput) message(level, text string) {
+	s.emit(StreamEvent{Type: EventMessage, Level: level, Text: text})
+}
+
+// Init announces the model and agent mode a run starts with.
+func (s *StreamJSONOutput) Init(model string, mode tui.AgentMode) {
+	s.emit(StreamEvent{Type: EventInit, Model: model, Mode: strings.ToLower(mode.String())})
+}
+
+// Finish writes the final done event with total usage and the run's error.
+func (s *StreamJSONOutput) Finish(err error) {
+	ev := StreamEvent{Type: EventDone, DurationMs: time.Since(s.start).Milliseconds()}
+	s.mu.Lock()
+	ev.InputTokens, ev.OutputTokens = s.inputTokens, s.outputTokens
+	s.mu.Unlock()
+	if err != nil {
+		ev.Error = err.Error()
+		ev.IsError = true
+	}
+	s.emit(ev)
+}
+
+func (s *StreamJSONOutput) StreamText(text string) { s.emit(StreamEvent{Type: EventText, Text: text}) }
+func (s *StreamJSONOutput) StreamThinking(text string) {
+	s.emit(StreamEvent{Type: EventThinking, Text: text})
+}
+func (s *StreamJSONOutput) StreamDone() { s.emit(StreamEvent{Type: EventStreamDone}) }
+func (s *StreamJSONOutput) StreamDoneWithUsage(inputTokens, outputTokens int64) {
+	s.mu.Lock()
+	s.inputTokens += inputTokens
+	s.outputTokens += outputTokens
+	s.mu.Unlock()
+	s.emit(StreamEvent{Type: EventUsage, InputTokens: inputTokens, OutputTokens: outputTokens})
+	s.emit(StreamEvent{Type: EventStreamDone})
+}
+
+func (s *StreamJSONOutput) Text(text string) { s.emit(StreamEvent{Type: EventText, Text: text}) }
+func (s *StreamJSONOutput) TextLn(text string) {
+	s.emit(StreamEvent{Type: EventText, Text: text + "\n"})
+}
+func (s *StreamJSONOutput) Error(err error) {
+	s.emit(StreamEvent{Type: EventError, Error: err.Error()})
+}
+func (s *StreamJSONOutput) ErrorStr(msg string) { s.emit(StreamEvent{Type: EventError, Error: msg}) }
+func (s *StreamJSONOutput) Warning(msg string)  { s.message("warning", msg) }
+func (s *StreamJSONOutput) Success(msg string)  { s.message("success", msg) }
+func (s *StreamJSONOutput) Info(msg string)     { s.message("info", msg) }
+func (s *StreamJSONOutput) Activity(msg string) { s.message("activity", msg) }
+func (s *StreamJSONOutput) Header(text string)  { s.message("header", text) }
+func (s *StreamJSONOutput) Separator()          {}
+func (s *StreamJSONOutput) Thinking(text string) {
+	s.emit(StreamEvent{Type: EventThinking, Text: text})
+}
+func (s *StreamJSONOutput) ThinkingLn(text string) {
+	s.emit(StreamEvent{Type: EventThinking, Text: text + "\n"})
+}
+func (s *StreamJSONOutput) ModelInfo(model string) {
+	s.emit(StreamEvent{Type: EventModel, Model: model})
+}
+func (s *StreamJSONOutput) Done() {}
+
+// ToolCall and ToolResult are only used by callers without the call itself;
+// the tool executor reports through ToolCallStarted and ToolCallFinished.
+func (s *StreamJSONOutput) ToolCall(name, description string) {
+	s.emit(StreamEvent{Type: EventToolCall, Name: name, Description: description})
+}
+func (s *StreamJSONOutput) ToolResult(name, result string, isError bool) {
+	s.emit(StreamEvent{Type: EventToolResult, Name: name, Result: result, IsError: isError})
+}
+
+// ToolCallStarted writes a tool call with its ID and full input.
+func (s *StreamJSONOutput) ToolCallStarted(call llm.ToolCall, description string) {
+	s.emit(StreamEvent{Type: EventToolCall, ID: call.ID, Name: call.Name, Description: description, Input: call.Input})
+}
+
+// ToolCallFinished writes a tool result carrying its call's ID.
+func (s *StreamJSONOutput) ToolCallFinished(call llm.ToolCall, result string, isError bool) {
+	s.emit(StreamEvent{Type: EventToolResult, ID: call.ID, Name: call.Name, Result: result, IsError: isError})
+}
+
+func (s *StreamJSONOutput) PermissionPrompt(toolName string, level tools.PermissionLevel, description string) {
+	s.emit(StreamEvent{Type: EventPermissionPrompt, Name: toolName, Permission: level.String(), Description: description})
+}
+
+// ModeChanged writes an agent mode switch.
+func (s *StreamJSONOutput) ModeChanged(mode tui.AgentMode) {
+	s.emit(StreamEvent{Type: EventMode, Mode: strings.ToLower(mode.String())})
+}
+
+// TierEscalated writes an automatic tier escalation.
+func (s *StreamJSONOutput) TierEscalated(from, to config.ModelTier, reason string) {
+	s.emit(StreamEvent{Type: EventEscalation, From: string(from), To: string(to), Reason: reason})
+}
+
+// Plan and PlanUpdate write the current plan text.
+func (s *StreamJSONOutput) Plan(text string)       { s.emit(StreamEvent{Type: EventPlan, Text: text}) }
+func (s *StreamJSONOutput) PlanUpdate(text string) { s.emit(StreamEvent{Type: EventPlan, Text: text}) }
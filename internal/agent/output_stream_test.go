@@ -0,0 +1,140 @@
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/abdul-hamid-achik/vecai/internal/llm"
+	"github.com/abdul-hamid-achik/vecai/internal/permissions"
+	"github.com/abdul-hamid-achik/vecai/internal/tools"
+	"github.com/abdul-hamid-achik/vecai/internal/tui"
+)
+
+func decodeEvents(t *testing.T, data []byte) []StreamEvent {
+	t.Helper()
+	var events []StreamEvent
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var ev StreamEvent
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			t.Fatalf("line %q is not JSON: %v", scanner.Text(), err)
+		}
+		events = append(events, ev)
+	}
+	return events
+}
+
+func TestStreamJSONOutput_Events(t *testing.T) {
+	var buf bytes.Buffer
+	out := NewStreamJSONOutput(&buf)
+
+	out.Init("qwen2.5-coder:7b", tui.ModeBuild)
+	out.StreamThinking("hmm")
+	out.StreamText("Hello")
+	out.StreamDoneWithUsage(120, 30)
+	out.PermissionPrompt("bash", tools.PermissionExecute, "go test ./...")
+	out.Warning("careful")
+	out.Finish(errors.New("max iterations reached"))
+
+	events := decodeEvents(t, buf.Bytes())
+	var types []string
+	for i, ev := range events {
+		if ev.Version != StreamSchemaVersion || ev.Seq != int64(i+1) || ev.Time.IsZero() {
+			t.Errorf("event %d has bad envelope %+v", i, ev)
+		}
+		types = append(types, ev.Type)
+	}
+	want := "init thinking text usage stream_done permission_prompt message done"
+	if got := strings.Join(types, " "); got != want {
+		t.Fatalf("event types = %s, want %s", got, want)
+	}
+
+	if events[0].Model != "qwen2.5-coder:7b" || events[0].Mode != "build" {
+		t.Errorf("unexpected init %+v", events[0])
+	}
+	if events[3].InputTokens != 120 || events[3].OutputTokens != 30 {
+		t.Errorf("unexpected usage %+v", events[3])
+	}
+	if events[5].Name != "bash" || events[5].Permission != "execute" {
+		t.Errorf("unexpected permission prompt %+v", events[5])
+	}
+	done := events[len(events)-1]
+	if !done.IsError || done.Error != "max iterations reached" || done.InputTokens != 120 {
+		t.Errorf("unexpected done %+v", done)
+	}
+}
+
+func TestStreamJSONOutput_MatchesToolResultsByID(t *testing.T) {
+	registry := newMockRegistry(&mockReadTool{name: "tool_a"}, &mockWriteTool{name: "writer"})
+	te := newTestToolExecutor(registry, permissions.ModeAuto)
+	var buf bytes.Buffer
+	out := NewStreamJSONOutput(&buf)
+
+	// Same tool name twice plus a call that fails to parse
+	calls := []llm.ToolCall{
+		{ID: "c1", Name: "writer", Input: map[string]any{"path": "a.go"}},
+		{ID: "c2", Name: "writer", Input: map[string]any{"path": "b.go"}},
+		{ID: "c3", Name: "tool_a", ParseError: "unexpected EOF"},
+	}
+	te.ExecuteToolCalls(context.Background(), calls, out, &mockInput{})
+
+	called := map[string]StreamEvent{}
+	results := map[string]StreamEvent{}
+	for _, ev := range decodeEvents(t, buf.Bytes()) {
+		switch ev.Type {
+		case EventToolCall:
+			called[ev.ID] = ev
+		case EventToolResult:
+			results[ev.ID] = ev
+		}
+	}
+
+	if called["c2"].Input["path"] != "b.go" {
+		t.Errorf("expected the full input on tool_call, got %+v", called["c2"])
+	}
+	for _, id := range []string{"c1", "c2"} {
+		if results[id].Result != "write-result" || results[id].IsError {
+			t.Errorf("unexpected result for %s: %+v", id, results[id])
+		}
+	}
+	if !results["c3"].IsError || !strings.Contains(results["c3"].Result, "could not parse") {
+		t.Errorf("expected the parse error under its ID, got %+v", results["c3"])
+	}
+}
+
+func TestJSONOutput_MatchesToolResultsByID(t *testing.T) {
+	out := &JSONOutput{}
+	first := llm.ToolCall{ID: "c1", Name: "read_file"}
+	second := llm.ToolCall{ID: "c2", Name: "read_file"}
+
+	out.ToolCallStarted(first, "a.go")
+	out.ToolCallStarted(second, "b.go")
+	out.ToolCallFinished(second, "b contents", false)
+	out.ToolCallFinished(first, "a contents", false)
+
+	if out.toolCalls[0].Result != "a contents" || out.toolCalls[1].Result != "b contents" {
+		t.Errorf("results attached to the wrong calls: %+v", out.toolCalls)
+	}
+}
+
+func TestProcessStreamChunk_AssignsMissingToolCallIDs(t *testing.T) {
+	a, _ := newTestAgent(t)
+	var text strings.Builder
+	var calls []llm.ToolCall
+
+	for _, id := range []string{"", "given", ""} {
+		chunk := llm.StreamChunk{Type: "tool_call", ToolCall: &llm.ToolCall{ID: id, Name: "read_file"}}
+		if err := a.processStreamChunk(chunk, &JSONOutput{}, &text, &calls); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if calls[0].ID == "" || calls[1].ID != "given" || calls[2].ID == calls[0].ID {
+		t.Errorf("unexpected IDs %q %q %q", calls[0].ID, calls[1].ID, calls[2].ID)
+	}
+}
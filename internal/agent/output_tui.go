@@ -1,6 +1,7 @@
 package agent
 
 import (
+	"github.com/abdul-hamid-achik/vecai/internal/llm"
 	"github.com/abdul-hamid-achik/vecai/internal/tools"
 	"github.com/abdul-hamid-achik/vecai/internal/tui"
 )
@@ -17,6 +18,8 @@ var _ AgentInput = (*TUIOutput)(nil)
 var _ InterruptSupport = (*TUIOutput)(nil)
 var _ StatsSupport = (*TUIOutput)(nil)
 var _ PlanSupport = (*TUIOutput)(nil)
+var _ ModeSupport = (*TUIOutput)(nil)
+var _ SubagentSupport = (*TUIOutput)(nil)
 
 // --- AgentOutput: Streaming ---
 
@@ -68,6 +71,20 @@ func (t *TUIOutput) Confirm(prompt string, defaultYes bool) (bool, error) {
 	return t.Adapter.Confirm(prompt, defaultYes)
 }
 
+// --- SubagentSupport ---
+
+func (t *TUIOutput) SubagentToolStarted(task string, call llm.ToolCall, description string) {
+	t.Adapter.SubagentToolCall(task, call.ID, call.Name, description)
+}
+
+func (t *TUIOutput) SubagentToolFinished(task string, call llm.ToolCall, result string, isError bool) {
+	t.Adapter.SubagentToolResult(task, call.ID, call.Name, result, isError)
+}
+
+// --- ModeSupport ---
+
+func (t *TUIOutput) ModeChanged(mode tui.AgentMode) { t.Adapter.SetAgentMode(mode) }
+
 // --- InterruptSupport ---
 
 func (t *TUIOutput) GetInterruptChan() <-chan struct{} { return t.Adapter.GetInterruptChan() }
@@ -58,7 +58,7 @@ func (pe *parallelExecutor) executeParallel(
 			sem <- struct{}{}
 			defer func() { <-sem }()
 
-			result, err := pe.registry.Execute(ctx, call.Name, call.Input)
+			result, err := pe.registry.Execute(withToolCallID(ctx, call.ID), call.Name, call.Input)
 			if err != nil {
 				results[idx] = toolResult{Name: call.Name, Result: "Error: " + err.Error(), Error: true}
 			} else {
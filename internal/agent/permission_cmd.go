@@ -0,0 +1,67 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// permissionCmdTimeout bounds how long a permission prompt command may run
+// before the call is denied.
+const permissionCmdTimeout = 60 * time.Second
+
+// PermissionRequest is a tool call waiting for permission, as sent to a
+// permission prompt command.
+type PermissionRequest struct {
+	Tool        string         `json:"tool"`
+	Permission  string         `json:"permission"` // read, write or execute
+	Description string         `json:"description"`
+	Input       map[string]any `json:"input"`
+}
+
+// CommandInput is the headless input for --permission-prompt-cmd. For each
+// permission prompt it runs Command through sh with the PermissionRequest
+// as JSON on stdin: exit status 0 allows the call, any other status denies
+// it. The command's output goes to stderr so it never mixes with the
+// agent's answer.
+type CommandInput struct {
+	HeadlessInput
+	Command string
+}
+
+var _ PermissionDecider = (*CommandInput)(nil)
+
+// DecidePermission runs the command for req. A command that cannot run or
+// times out denies the call with an error.
+func (c *CommandInput) DecidePermission(ctx context.Context, req PermissionRequest) (bool, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode permission request: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, permissionCmdTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", c.Command)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return false, fmt.Errorf("permission prompt command timed out after %s", permissionCmdTimeout)
+	case ctx.Err() != nil:
+		return false, ctx.Err()
+	case errors.As(err, &exitErr):
+		return false, nil
+	}
+	return false, fmt.Errorf("permission prompt command failed: %w", err)
+}
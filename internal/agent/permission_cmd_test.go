@@ -0,0 +1,69 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/abdul-hamid-achik/vecai/internal/llm"
+	"github.com/abdul-hamid-achik/vecai/internal/permissions"
+)
+
+func TestCommandInput_AnswersPermissionPrompts(t *testing.T) {
+	dir := t.TempDir()
+	requestFile := filepath.Join(dir, "request.json")
+	// Approve writes under internal/ and record the last request
+	script := `tee "` + requestFile + `" | grep -q '"path":"internal/'`
+
+	registry := newMockRegistry(&mockWriteTool{name: "writer"})
+	te := newTestToolExecutor(registry, permissions.ModeAsk)
+	input := &CommandInput{Command: script}
+
+	calls := []llm.ToolCall{
+		{ID: "c1", Name: "writer", Input: map[string]any{"path": "internal/a.go"}},
+		{ID: "c2", Name: "writer", Input: map[string]any{"path": "cmd/b.go"}},
+	}
+	results := te.ExecuteToolCalls(context.Background(), calls, &mockOutput{}, input)
+
+	if results[0].Error || results[0].Result != "write-result" {
+		t.Errorf("expected the command to allow internal/a.go, got %+v", results[0])
+	}
+	if !results[1].Error || !strings.Contains(results[1].Result, "Permission denied") {
+		t.Errorf("expected the command to deny cmd/b.go, got %+v", results[1])
+	}
+
+	data, err := os.ReadFile(requestFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var req PermissionRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		t.Fatalf("request is not JSON: %s", data)
+	}
+	if req.Tool != "writer" || req.Permission != "write" || req.Input["path"] != "cmd/b.go" {
+		t.Errorf("unexpected request %+v", req)
+	}
+}
+
+func TestCommandInput_CancelledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	input := &CommandInput{Command: "exit 0"}
+	if allowed, err := input.DecidePermission(ctx, PermissionRequest{Tool: "bash"}); allowed || err == nil {
+		t.Errorf("expected a cancelled prompt to deny with an error, got %v, %v", allowed, err)
+	}
+}
+
+func TestAgent_HeadlessInput(t *testing.T) {
+	a, _ := newTestAgent(t)
+	if _, ok := a.headlessInput().(PermissionDecider); ok {
+		t.Error("expected plain headless input without a prompt command")
+	}
+	a.permissionPromptCmd = "./approve.sh"
+	if in, ok := a.headlessInput().(*CommandInput); !ok || in.Command != "./approve.sh" {
+		t.Errorf("expected a command input, got %T", a.headlessInput())
+	}
+}
@@ -282,11 +282,9 @@ func (p *Pipeline) extractChangedFiles(executions []*ExecutionResult) []string {
 
 	for _, exec := range executions {
 		for _, tc := range exec.ToolCalls {
-			// Check for file write/edit tools
-			if tc.Tool == "write_file" || tc.Tool == "edit_file" {
-				if path, ok := tc.Input["path"].(string); ok {
-					fileSet[path] = true
-				}
+			// Check for file write/edit/patch tools
+			for _, path := range tools.ChangedPaths(tc.Tool, tc.Input) {
+				fileSet[path] = true
 			}
 		}
 	}
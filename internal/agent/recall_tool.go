@@ -0,0 +1,101 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	ctxmgr "github.com/abdul-hamid-achik/vecai/internal/context"
+	"github.com/abdul-hamid-achik/vecai/internal/tools"
+)
+
+// recallTool brings back the full output of a tool call whose result was
+// compressed or masked in the conversation. Output that does not fit the
+// tool's allowance comes back a page of lines at a time.
+type recallTool struct {
+	cache  *ctxmgr.ToolResultCache
+	budget *ctxmgr.ToolBudget
+}
+
+func (t *recallTool) Name() string { return ctxmgr.RecallToolName }
+
+func (t *recallTool) Description() string {
+	return "Retrieve the full output of an earlier tool call that was compressed or masked. " +
+		"Pass the key from the result's note; use start_line and end_line to read part of a long output."
+}
+
+func (t *recallTool) InputSchema() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"key": map[string]any{
+				"type":        "string",
+				"description": "The key given in the compressed or masked result",
+			},
+			"start_line": map[string]any{
+				"type":        "integer",
+				"description": "First line to return, 1-based (default: 1)",
+			},
+			"end_line": map[string]any{
+				"type":        "integer",
+				"description": "Last line to return (default: as many as fit)",
+			},
+		},
+		"required": []string{"key"},
+	}
+}
+
+func (t *recallTool) Permission() tools.PermissionLevel { return tools.PermissionRead }
+
+func (t *recallTool) Execute(_ context.Context, input map[string]any) (string, error) {
+	key, _ := input["key"].(string)
+	if key == "" {
+		return "", errors.New("key is required")
+	}
+	result, ok := t.cache.Get(key)
+	if !ok {
+		return "", fmt.Errorf("no output stored for key %q; it may have expired, so run the original tool call again", key)
+	}
+
+	lines := strings.Split(strings.TrimRight(result, "\n"), "\n")
+	start := 1
+	if v, ok := input["start_line"].(float64); ok && v > 1 {
+		start = int(v)
+	}
+	end := len(lines)
+	if v, ok := input["end_line"].(float64); ok && int(v) >= start && int(v) < end {
+		end = int(v)
+	}
+	if start > len(lines) {
+		return "", fmt.Errorf("start_line %d is past the end of the output (%d lines)", start, len(lines))
+	}
+
+	// Take whole lines until the page reaches the allowance; the first
+	// line always goes in
+	maxTokens := ctxmgr.DefaultToolQuotas[ctxmgr.RecallToolName]
+	if t.budget != nil {
+		maxTokens = t.budget.Allowance(ctxmgr.RecallToolName, 1)
+	}
+	var sb strings.Builder
+	last, tokens := start-1, 0
+	for last < end {
+		line := lines[last] + "\n"
+		tokens += ctxmgr.EstimateTokens(line)
+		if last >= start && tokens > maxTokens {
+			break
+		}
+		sb.WriteString(line)
+		last++
+	}
+
+	page := sb.String()
+	if start == 1 && last == len(lines) {
+		return page, nil
+	}
+	page = fmt.Sprintf("Lines %d-%d of %d:\n%s", start, last, len(lines), page)
+	if last < end {
+		page += fmt.Sprintf("[More output: call again with start_line=%d]\n", last+1)
+	}
+	return page, nil
+}
@@ -0,0 +1,130 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	ctxmgr "github.com/abdul-hamid-achik/vecai/internal/context"
+	"github.com/abdul-hamid-achik/vecai/internal/llm"
+	"github.com/abdul-hamid-achik/vecai/internal/permissions"
+	"github.com/abdul-hamid-achik/vecai/internal/tools"
+)
+
+// mockLogTool returns a fixed, long output.
+type mockLogTool struct{ output string }
+
+func (t *mockLogTool) Name() string                { return "logs" }
+func (t *mockLogTool) Description() string         { return "mock log tool" }
+func (t *mockLogTool) InputSchema() map[string]any { return nil }
+func (t *mockLogTool) Execute(_ context.Context, _ map[string]any) (string, error) {
+	return t.output, nil
+}
+func (t *mockLogTool) Permission() tools.PermissionLevel { return tools.PermissionRead }
+
+func TestToolBudget_CompressesAndRecalls(t *testing.T) {
+	var lines []string
+	for i := range 400 {
+		lines = append(lines, fmt.Sprintf("event %d: %s", i, strings.Repeat(string(rune('a'+i%26)), 30)))
+	}
+	full := strings.Join(lines, "\n")
+
+	cache := ctxmgr.NewToolResultCache(time.Minute)
+	defer cache.Stop()
+	budget := ctxmgr.NewToolBudget(func() (int, int) { return 0, 4000 }, map[string]int{"logs": 500})
+	recall := &recallTool{cache: cache, budget: budget}
+	registry := newMockRegistry(&mockLogTool{output: full}, recall)
+	te := NewToolExecutor(registry, permissions.NewPolicy(permissions.ModeAuto, nil, nil), cache, false)
+	te.budget = budget
+
+	out := &mockOutput{}
+	results := te.ExecuteToolCalls(context.Background(), []llm.ToolCall{{ID: "c1", Name: "logs", Input: map[string]any{}}}, out, &mockInput{})
+	got := results[0].Result
+	key := ctxmgr.CacheKey("logs", map[string]any{})
+	if !strings.Contains(got, "lines elided") || !strings.Contains(got, `recall_tool_result key="`+key+`"`) {
+		t.Errorf("expected a compressed result with its recall key, got tail %q", got[len(got)-150:])
+	}
+	if !strings.HasSuffix(out.toolResultCalls[0], lines[399]) || !strings.Contains(out.toolResultCalls[0], lines[200]) {
+		t.Error("expected the user to see the full output")
+	}
+
+	// The first page ends with where to continue, and the next one picks up there
+	page, err := recall.Execute(context.Background(), map[string]any{"key": key})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(page, "Lines 1-") || !strings.Contains(page, lines[0]+"\n") || ctxmgr.EstimateTokens(page) > 1100 {
+		t.Errorf("unexpected first page (~%d tokens): %q", ctxmgr.EstimateTokens(page), page[:100])
+	}
+	var next int
+	if _, err := fmt.Sscanf(page[strings.LastIndex(page, "start_line="):], "start_line=%d]", &next); err != nil || next < 2 {
+		t.Fatalf("expected a start_line to continue from, got %q", page[len(page)-60:])
+	}
+	page, _ = recall.Execute(context.Background(), map[string]any{"key": key, "start_line": float64(next)})
+	if !strings.HasPrefix(page, fmt.Sprintf("Lines %d-", next)) || !strings.Contains(page, lines[next-1]+"\n") {
+		t.Errorf("unexpected second page: %q", page[:100])
+	}
+
+	page, _ = recall.Execute(context.Background(), map[string]any{"key": key, "start_line": float64(10), "end_line": float64(12)})
+	if page != "Lines 10-12 of 400:\n"+strings.Join(lines[9:12], "\n")+"\n" {
+		t.Errorf("unexpected line range: %q", page)
+	}
+
+	// Recalled output is paged already and goes into the context as is
+	results = te.ExecuteToolCalls(context.Background(), []llm.ToolCall{{ID: "c2", Name: ctxmgr.RecallToolName, Input: map[string]any{"key": key}}}, out, &mockInput{})
+	if strings.Contains(results[0].Result, "Compressed from") {
+		t.Errorf("expected recalled output not to be compressed again, got %q", results[0].Result[len(results[0].Result)-100:])
+	}
+
+	if _, err := recall.Execute(context.Background(), map[string]any{"key": "missing"}); err == nil || !strings.Contains(err.Error(), "run the original tool call again") {
+		t.Errorf("expected an error for an unknown key, got %v", err)
+	}
+	if _, err := recall.Execute(context.Background(), map[string]any{"key": key, "start_line": float64(500)}); err == nil {
+		t.Error("expected an error past the end of the output")
+	}
+}
+
+func TestToolBudget_RecallsOutputPastTruncation(t *testing.T) {
+	var lines []string
+	for i := 0; len(strings.Join(lines, "\n")) <= maxToolOutput+10000; i++ {
+		lines = append(lines, fmt.Sprintf("row %d: %s", i, strings.Repeat(string(rune('a'+i%26)), 60)))
+	}
+	full := strings.Join(lines, "\n") + "\n"
+
+	cache := ctxmgr.NewToolResultCache(time.Minute)
+	defer cache.Stop()
+	budget := ctxmgr.NewToolBudget(func() (int, int) { return 0, 40000 }, nil)
+	recall := &recallTool{cache: cache, budget: budget}
+	te := NewToolExecutor(newMockRegistry(&mockLogTool{output: full}, recall), permissions.NewPolicy(permissions.ModeAuto, nil, nil), cache, false)
+	te.budget = budget
+
+	out := &mockOutput{}
+	te.ExecuteToolCalls(context.Background(), []llm.ToolCall{{ID: "c1", Name: "logs", Input: map[string]any{}}}, out, &mockInput{})
+	if !strings.Contains(out.toolResultCalls[0], "output truncated") {
+		t.Error("expected the displayed output to be truncated")
+	}
+
+	// Page through the recalled output and put it back together
+	key := ctxmgr.CacheKey("logs", map[string]any{})
+	var got strings.Builder
+	for start := 1; ; {
+		page, err := recall.Execute(context.Background(), map[string]any{"key": key, "start_line": float64(start)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, body, _ := strings.Cut(page, "\n")
+		body, more, found := strings.Cut(body, "[More output: call again with start_line=")
+		got.WriteString(body)
+		if !found {
+			break
+		}
+		if _, err := fmt.Sscanf(more, "%d]", &start); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got.String() != full {
+		t.Errorf("expected all %d bytes back, got %d", len(full), got.Len())
+	}
+}
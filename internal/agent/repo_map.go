@@ -2,9 +2,6 @@ package agent
 
 import (
 	"fmt"
-	"go/ast"
-	"go/parser"
-	"go/token"
 	"os"
 	"path/filepath"
 	"sort"
@@ -14,21 +11,61 @@ import (
 )
 
 const (
-	repoMapMaxChars = 8000
-	repoMapCacheTTL = 5 * time.Minute
+	repoMapTokenBudget   = 2000 // Roughly the old 8000-char cap
+	repoMapCharsPerToken = 4
+	repoMapCacheTTL      = 5 * time.Minute
+	repoMapMaxFileSize   = 512 * 1024 // Skip generated/minified files
 )
 
-// RepoMap generates a compact overview of the Go codebase's exported API.
+// repoMapSkipDirs are never walked: dependencies, build output and tool state.
+var repoMapSkipDirs = map[string]bool{
+	"vendor":       true,
+	".git":         true,
+	".vecai":       true,
+	".vecgrep":     true,
+	"node_modules": true,
+	"dist":         true,
+	"build":        true,
+	"target":       true,
+	"__pycache__":  true,
+	".venv":        true,
+	"venv":         true,
+}
+
+// RepoMap generates a compact overview of the codebase's public API across
+// every language with a registered SymbolExtractor. Symbols are ranked by how
+// often other files reference them and trimmed to a token budget.
 type RepoMap struct {
-	mu        sync.Mutex
-	cached    string
-	cachedAt  time.Time
-	rootDir   string
+	mu         sync.Mutex
+	cached     string
+	cachedAt   time.Time
+	rootDir    string
+	extractors map[string]SymbolExtractor // keyed by file extension
+	budget     int                        // in tokens
 }
 
-// NewRepoMap creates a new RepoMap rooted at the given directory.
+// NewRepoMap creates a new RepoMap rooted at the given directory using the
+// built-in extractors.
 func NewRepoMap(rootDir string) *RepoMap {
-	return &RepoMap{rootDir: rootDir}
+	rm := &RepoMap{
+		rootDir:    rootDir,
+		extractors: make(map[string]SymbolExtractor),
+		budget:     repoMapTokenBudget,
+	}
+	for _, ex := range DefaultSymbolExtractors() {
+		rm.Register(ex)
+	}
+	return rm
+}
+
+// Register adds an extractor, replacing any existing one for the same extensions.
+func (rm *RepoMap) Register(ex SymbolExtractor) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	for _, ext := range ex.Extensions() {
+		rm.extractors[ext] = ex
+	}
+	rm.cached = ""
 }
 
 // Get returns the cached repo map, rebuilding if stale.
@@ -45,197 +82,156 @@ func (rm *RepoMap) Get() string {
 	return rm.cached
 }
 
-// build walks .go files (skipping tests, vendor, .git) and extracts exported symbols.
+// rankedSymbol is an extracted symbol with the file it came from and its score.
+type rankedSymbol struct {
+	RepoSymbol
+	file string
+	refs int
+}
+
+// build walks source files, extracts symbols and renders the highest-ranked
+// ones that fit in the token budget.
 func (rm *RepoMap) build() string {
-	dirMap := make(map[string][]string)
-	fset := token.NewFileSet()
+	var symbols []rankedSymbol
+	refs := make(map[string]int) // identifier -> occurrences across all files
+	ownRefs := make(map[int]int) // index into symbols -> occurrences in its own file
 
 	_ = filepath.Walk(rm.rootDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return nil
 		}
-
-		// Skip directories
-		name := info.Name()
 		if info.IsDir() {
-			if name == "vendor" || name == ".git" || name == ".vecai" || name == ".vecgrep" || name == "node_modules" {
+			if repoMapSkipDirs[info.Name()] {
 				return filepath.SkipDir
 			}
 			return nil
 		}
 
-		// Only .go files, skip tests
-		if !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+		ex, ok := rm.extractors[strings.ToLower(filepath.Ext(path))]
+		if !ok || info.Size() > repoMapMaxFileSize {
 			return nil
 		}
-
-		f, parseErr := parser.ParseFile(fset, path, nil, parser.SkipObjectResolution)
-		if parseErr != nil {
+		src, readErr := os.ReadFile(path)
+		if readErr != nil {
 			return nil
 		}
 
-		relDir, _ := filepath.Rel(rm.rootDir, filepath.Dir(path))
-		if relDir == "" {
-			relDir = "."
+		// Tests count as references but do not contribute symbols
+		counts := countIdentifiers(src)
+		for name, n := range counts {
+			refs[name] += n
 		}
-
-		for _, decl := range f.Decls {
-			switch d := decl.(type) {
-			case *ast.FuncDecl:
-				if !d.Name.IsExported() {
-					continue
-				}
-				sig := formatFuncSig(d)
-				dirMap[relDir] = append(dirMap[relDir], sig)
-
-			case *ast.GenDecl:
-				for _, spec := range d.Specs {
-					switch s := spec.(type) {
-					case *ast.TypeSpec:
-						if !s.Name.IsExported() {
-							continue
-						}
-						kind := "type"
-						switch s.Type.(type) {
-						case *ast.StructType:
-							kind = "struct"
-						case *ast.InterfaceType:
-							kind = "interface"
-						}
-						dirMap[relDir] = append(dirMap[relDir], fmt.Sprintf("type %s %s", s.Name.Name, kind))
-					}
-				}
-			}
+		if ex.IsTest(path) {
+			return nil
 		}
 
+		rel, _ := filepath.Rel(rm.rootDir, path)
+		for _, sym := range ex.Extract(path, src) {
+			ownRefs[len(symbols)] = counts[sym.Name]
+			symbols = append(symbols, rankedSymbol{RepoSymbol: sym, file: filepath.ToSlash(rel)})
+		}
 		return nil
 	})
 
-	// Sort directories for stable output
-	var dirs []string
-	for dir := range dirMap {
-		dirs = append(dirs, dir)
+	// A name defined in many places (Close, Error, String) splits its
+	// references across those definitions, like aider's repo map does
+	defs := make(map[string]int)
+	for _, sym := range symbols {
+		defs[sym.Name]++
 	}
-	sort.Strings(dirs)
+	for i := range symbols {
+		symbols[i].refs = (refs[symbols[i].Name] - ownRefs[i]) / defs[symbols[i].Name]
+	}
+	return renderRepoMap(symbols, rm.budget)
+}
 
-	var sb strings.Builder
-	sb.WriteString("## Repository Map\n\n")
+// renderRepoMap keeps the most-referenced symbols that fit in budget tokens
+// and renders them grouped by file.
+func renderRepoMap(symbols []rankedSymbol, budget int) string {
+	sort.SliceStable(symbols, func(i, j int) bool {
+		if symbols[i].refs != symbols[j].refs {
+			return symbols[i].refs > symbols[j].refs
+		}
+		if symbols[i].file != symbols[j].file {
+			return symbols[i].file < symbols[j].file
+		}
+		return symbols[i].Line < symbols[j].Line
+	})
 
-	totalLen := sb.Len()
+	header := "## Repository Map\n\n"
+	used := len(header)
+	limit := budget * repoMapCharsPerToken
+	byFile := make(map[string][]rankedSymbol)
+	seen := make(map[string]bool)
 	truncated := false
 
-	for _, dir := range dirs {
-		symbols := dirMap[dir]
-		// Deduplicate
-		sort.Strings(symbols)
-		symbols = dedup(symbols)
-
-		section := fmt.Sprintf("### %s\n", dir)
-		for _, sym := range symbols {
-			section += fmt.Sprintf("  %s\n", sym)
+	for _, sym := range symbols {
+		key := sym.file + "\x00" + sym.Signature
+		if seen[key] {
+			continue
 		}
-		section += "\n"
-
-		if totalLen+len(section) > repoMapMaxChars {
+		cost := len(sym.Signature) + 3 // "  " + "\n"
+		if _, ok := byFile[sym.file]; !ok {
+			cost += len(sym.file) + 6 // "### " + "\n" + blank line
+		}
+		if used+cost > limit {
 			truncated = true
-			break
+			continue // A shorter, lower-ranked symbol may still fit
 		}
-		sb.WriteString(section)
-		totalLen += len(section)
+		seen[key] = true
+		used += cost
+		byFile[sym.file] = append(byFile[sym.file], sym)
 	}
 
-	if truncated {
-		sb.WriteString("[... truncated]\n")
+	if len(byFile) == 0 {
+		return ""
 	}
 
-	return sb.String()
-}
+	files := make([]string, 0, len(byFile))
+	for file := range byFile {
+		files = append(files, file)
+	}
+	sort.Strings(files)
 
-// formatFuncSig formats a function declaration as a compact signature.
-func formatFuncSig(d *ast.FuncDecl) string {
 	var sb strings.Builder
-	sb.WriteString("func ")
-	if d.Recv != nil && len(d.Recv.List) > 0 {
-		recv := d.Recv.List[0]
-		sb.WriteString("(")
-		sb.WriteString(typeString(recv.Type))
-		sb.WriteString(").")
-	}
-	sb.WriteString(d.Name.Name)
-	sb.WriteString("(")
-
-	// Parameters
-	if d.Type.Params != nil {
-		var params []string
-		for _, p := range d.Type.Params.List {
-			typeName := typeString(p.Type)
-			if len(p.Names) == 0 {
-				params = append(params, typeName)
-			} else {
-				for range p.Names {
-					params = append(params, typeName)
-				}
-			}
+	sb.WriteString(header)
+	for _, file := range files {
+		syms := byFile[file]
+		sort.Slice(syms, func(i, j int) bool { return syms[i].Line < syms[j].Line })
+		sb.WriteString(fmt.Sprintf("### %s\n", file))
+		for _, sym := range syms {
+			sb.WriteString(fmt.Sprintf("  %s\n", sym.Signature))
 		}
-		sb.WriteString(strings.Join(params, ", "))
+		sb.WriteString("\n")
 	}
-	sb.WriteString(")")
-
-	// Return types
-	if d.Type.Results != nil && len(d.Type.Results.List) > 0 {
-		var rets []string
-		for _, r := range d.Type.Results.List {
-			rets = append(rets, typeString(r.Type))
-		}
-		if len(rets) == 1 {
-			sb.WriteString(" ")
-			sb.WriteString(rets[0])
-		} else {
-			sb.WriteString(" (")
-			sb.WriteString(strings.Join(rets, ", "))
-			sb.WriteString(")")
-		}
+	if truncated {
+		sb.WriteString("[... truncated: less-referenced symbols omitted]\n")
 	}
-
 	return sb.String()
 }
 
-// typeString returns a compact string for an AST type expression.
-func typeString(expr ast.Expr) string {
-	switch t := expr.(type) {
-	case *ast.Ident:
-		return t.Name
-	case *ast.StarExpr:
-		return "*" + typeString(t.X)
-	case *ast.SelectorExpr:
-		return typeString(t.X) + "." + t.Sel.Name
-	case *ast.ArrayType:
-		return "[]" + typeString(t.Elt)
-	case *ast.MapType:
-		return "map[" + typeString(t.Key) + "]" + typeString(t.Value)
-	case *ast.InterfaceType:
-		return "interface{}"
-	case *ast.FuncType:
-		return "func(...)"
-	case *ast.ChanType:
-		return "chan " + typeString(t.Value)
-	case *ast.Ellipsis:
-		return "..." + typeString(t.Elt)
-	default:
-		return "any"
+// countIdentifiers tallies identifier-like tokens in src. It does not skip
+// comments or strings; mentions there are a reasonable relevance signal too.
+func countIdentifiers(src []byte) map[string]int {
+	counts := make(map[string]int)
+	start := -1
+	for i := 0; i <= len(src); i++ {
+		if i < len(src) && isIdentByte(src[i]) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			if c := src[start]; c < '0' || c > '9' {
+				counts[string(src[start:i])]++
+			}
+			start = -1
+		}
 	}
+	return counts
 }
 
-// dedup removes consecutive duplicates from a sorted slice.
-func dedup(s []string) []string {
-	if len(s) <= 1 {
-		return s
-	}
-	result := s[:1]
-	for _, v := range s[1:] {
-		if v != result[len(result)-1] {
-			result = append(result, v)
-		}
-	}
-	return result
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c >= 0x80
 }
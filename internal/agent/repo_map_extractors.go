@@ -0,0 +1,369 @@
+package agent
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	repoMapMaxDeclLines = 6   // Lines scanned for a multi-line declaration
+	repoMapMaxSigLen    = 160 // Longer signatures are cut
+)
+
+// RepoSymbol is one public symbol listed in the repo map.
+type RepoSymbol struct {
+	Name      string // Bare identifier, used to count references
+	Signature string // Compact declaration shown to the model
+	Line      int    // 1-indexed
+}
+
+// SymbolExtractor lists the public symbols a source file defines.
+type SymbolExtractor interface {
+	// Extensions returns the lowercase file extensions handled, e.g. ".py".
+	Extensions() []string
+	// IsTest reports whether path is a test file. Tests contribute
+	// references but no symbols.
+	IsTest(path string) bool
+	// Extract returns the file's public symbols. Unparseable input yields nil.
+	Extract(path string, src []byte) []RepoSymbol
+}
+
+// DefaultSymbolExtractors returns the built-in extractors for Go, Python,
+// TypeScript/JavaScript and Rust.
+func DefaultSymbolExtractors() []SymbolExtractor {
+	return []SymbolExtractor{
+		GoExtractor{},
+		PythonExtractor{},
+		TypeScriptExtractor{},
+		RustExtractor{},
+	}
+}
+
+// GoExtractor lists exported functions, methods and types using go/parser.
+type GoExtractor struct{}
+
+func (GoExtractor) Extensions() []string { return []string{".go"} }
+
+func (GoExtractor) IsTest(path string) bool { return strings.HasSuffix(path, "_test.go") }
+
+func (GoExtractor) Extract(path string, src []byte) []RepoSymbol {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil
+	}
+
+	var symbols []RepoSymbol
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if !d.Name.IsExported() {
+				continue
+			}
+			symbols = append(symbols, RepoSymbol{
+				Name:      d.Name.Name,
+				Signature: formatFuncSig(d),
+				Line:      fset.Position(d.Pos()).Line,
+			})
+
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				s, ok := spec.(*ast.TypeSpec)
+				if !ok || !s.Name.IsExported() {
+					continue
+				}
+				kind := "type"
+				switch s.Type.(type) {
+				case *ast.StructType:
+					kind = "struct"
+				case *ast.InterfaceType:
+					kind = "interface"
+				}
+				symbols = append(symbols, RepoSymbol{
+					Name:      s.Name.Name,
+					Signature: fmt.Sprintf("type %s %s", s.Name.Name, kind),
+					Line:      fset.Position(s.Pos()).Line,
+				})
+			}
+		}
+	}
+	return symbols
+}
+
+// formatFuncSig formats a function declaration as a compact signature.
+func formatFuncSig(d *ast.FuncDecl) string {
+	var sb strings.Builder
+	sb.WriteString("func ")
+	if d.Recv != nil && len(d.Recv.List) > 0 {
+		recv := d.Recv.List[0]
+		sb.WriteString("(")
+		sb.WriteString(typeString(recv.Type))
+		sb.WriteString(").")
+	}
+	sb.WriteString(d.Name.Name)
+	sb.WriteString("(")
+
+	// Parameters
+	if d.Type.Params != nil {
+		var params []string
+		for _, p := range d.Type.Params.List {
+			typeName := typeString(p.Type)
+			if len(p.Names) == 0 {
+				params = append(params, typeName)
+			} else {
+				for range p.Names {
+					params = append(params, typeName)
+				}
+			}
+		}
+		sb.WriteString(strings.Join(params, ", "))
+	}
+	sb.WriteString(")")
+
+	// Return types
+	if d.Type.Results != nil && len(d.Type.Results.List) > 0 {
+		var rets []string
+		for _, r := range d.Type.Results.List {
+			rets = append(rets, typeString(r.Type))
+		}
+		if len(rets) == 1 {
+			sb.WriteString(" ")
+			sb.WriteString(rets[0])
+		} else {
+			sb.WriteString(" (")
+			sb.WriteString(strings.Join(rets, ", "))
+			sb.WriteString(")")
+		}
+	}
+
+	return sb.String()
+}
+
+// typeString returns a compact string for an AST type expression.
+func typeString(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.StarExpr:
+		return "*" + typeString(t.X)
+	case *ast.SelectorExpr:
+		return typeString(t.X) + "." + t.Sel.Name
+	case *ast.ArrayType:
+		return "[]" + typeString(t.Elt)
+	case *ast.MapType:
+		return "map[" + typeString(t.Key) + "]" + typeString(t.Value)
+	case *ast.InterfaceType:
+		return "interface{}"
+	case *ast.FuncType:
+		return "func(...)"
+	case *ast.ChanType:
+		return "chan " + typeString(t.Value)
+	case *ast.Ellipsis:
+		return "..." + typeString(t.Elt)
+	default:
+		return "any"
+	}
+}
+
+// PythonExtractor lists module-level functions and classes plus the methods
+// defined directly in those classes. Names starting with "_" are private.
+type PythonExtractor struct{}
+
+var (
+	pyDefRe   = regexp.MustCompile(`^(\s*)(?:async\s+)?def\s+([A-Za-z_]\w*)`)
+	pyClassRe = regexp.MustCompile(`^class\s+([A-Za-z_]\w*)`)
+)
+
+func (PythonExtractor) Extensions() []string { return []string{".py", ".pyi"} }
+
+func (PythonExtractor) IsTest(path string) bool {
+	base := filepath.Base(path)
+	return strings.HasPrefix(base, "test_") || strings.HasSuffix(base, "_test.py") || base == "conftest.py"
+}
+
+func (PythonExtractor) Extract(path string, src []byte) []RepoSymbol {
+	lines := strings.Split(string(src), "\n")
+	var symbols []RepoSymbol
+	class := ""        // Enclosing module-level class, if any
+	memberIndent := "" // Indentation of that class's body
+
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+
+		if indent == "" {
+			class = ""
+			if m := pyClassRe.FindStringSubmatch(line); m != nil {
+				if !strings.HasPrefix(m[1], "_") {
+					class, memberIndent = m[1], ""
+					symbols = append(symbols, RepoSymbol{Name: m[1], Signature: declarationText(lines, i, ":"), Line: i + 1})
+				}
+				continue
+			}
+		} else if class != "" && memberIndent == "" {
+			memberIndent = indent
+		}
+
+		m := pyDefRe.FindStringSubmatch(line)
+		if m == nil || strings.HasPrefix(m[2], "_") {
+			continue
+		}
+		switch {
+		case indent == "":
+			symbols = append(symbols, RepoSymbol{Name: m[2], Signature: declarationText(lines, i, ":"), Line: i + 1})
+		case class != "" && indent == memberIndent:
+			sig := strings.Replace(declarationText(lines, i, ":"), "def "+m[2], "def "+class+"."+m[2], 1)
+			symbols = append(symbols, RepoSymbol{Name: m[2], Signature: sig, Line: i + 1})
+		}
+	}
+	return symbols
+}
+
+// TypeScriptExtractor lists exported declarations in TypeScript and
+// JavaScript. Files without any exports (scripts, CommonJS) fall back to
+// top-level functions and classes.
+type TypeScriptExtractor struct{}
+
+var (
+	tsExportRe   = regexp.MustCompile(`^export\s+(?:default\s+)?(?:declare\s+)?(?:abstract\s+)?(?:async\s+)?(function\s*\*?\s*|(?:class|interface|type|enum|const\s+enum|const|let|var|namespace)\s+)([A-Za-z_$][\w$]*)`)
+	tsTopLevelRe = regexp.MustCompile(`^(?:async\s+)?(function\s*\*?\s*|class\s+)([A-Za-z_$][\w$]*)`)
+)
+
+func (TypeScriptExtractor) Extensions() []string {
+	return []string{".ts", ".tsx", ".mts", ".cts", ".js", ".jsx", ".mjs", ".cjs"}
+}
+
+func (TypeScriptExtractor) IsTest(path string) bool {
+	base := filepath.Base(path)
+	return strings.Contains(base, ".test.") || strings.Contains(base, ".spec.")
+}
+
+func (TypeScriptExtractor) Extract(path string, src []byte) []RepoSymbol {
+	lines := strings.Split(string(src), "\n")
+	symbols := extractTSDecls(lines, tsExportRe)
+	if len(symbols) == 0 {
+		symbols = extractTSDecls(lines, tsTopLevelRe)
+	}
+	return symbols
+}
+
+func extractTSDecls(lines []string, re *regexp.Regexp) []RepoSymbol {
+	var symbols []RepoSymbol
+	for i, line := range lines {
+		m := re.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		stops := "{"
+		switch strings.Fields(m[1])[0] {
+		case "const", "let", "var", "type":
+			stops = "{="
+		}
+		sig := strings.TrimPrefix(declarationText(lines, i, stops), "export ")
+		symbols = append(symbols, RepoSymbol{Name: m[2], Signature: sig, Line: i + 1})
+	}
+	return symbols
+}
+
+// RustExtractor lists pub items. Methods in inherent impl blocks are
+// qualified with the implementing type.
+type RustExtractor struct{}
+
+var (
+	rustPubRe  = regexp.MustCompile(`^\s*pub(?:\([^)]*\))?\s+(?:(?:async|unsafe|const|extern\s+"[^"]*")\s+)*(fn|struct|enum|trait|type|mod|const|static|union|macro)\s+([A-Za-z_]\w*)`)
+	rustImplRe = regexp.MustCompile(`^impl\b(?:<.*?>)?\s+(?:.*\sfor\s+)?(?:[\w:]+::)?([A-Za-z_]\w*)`)
+)
+
+func (RustExtractor) Extensions() []string { return []string{".rs"} }
+
+func (RustExtractor) IsTest(path string) bool {
+	return strings.Contains(filepath.ToSlash(path), "/tests/")
+}
+
+func (RustExtractor) Extract(path string, src []byte) []RepoSymbol {
+	lines := strings.Split(string(src), "\n")
+	var symbols []RepoSymbol
+	impl := "" // Type of the enclosing impl block, if any
+
+	for i, line := range lines {
+		if strings.HasPrefix(line, "impl") {
+			impl = ""
+			if m := rustImplRe.FindStringSubmatch(line); m != nil {
+				impl = m[1]
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "}") {
+			impl = ""
+			continue
+		}
+
+		m := rustPubRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		stops := "{;"
+		if m[1] == "const" || m[1] == "static" || m[1] == "type" {
+			stops = "{;="
+		}
+		sig := declarationText(lines, i, stops)
+		if idx := strings.Index(sig, m[1]+" "); idx > 0 {
+			sig = sig[idx:] // Drop visibility and qualifiers before the kind
+		}
+		if impl != "" && m[1] == "fn" && line != strings.TrimLeft(line, " \t") {
+			sig = strings.Replace(sig, "fn "+m[2], "fn "+impl+"::"+m[2], 1)
+		}
+		symbols = append(symbols, RepoSymbol{Name: m[2], Signature: sig, Line: i + 1})
+	}
+	return symbols
+}
+
+// declarationText joins the declaration starting at lines[i] until its
+// bracketed parameter list closes, cutting at the first of stops found
+// outside brackets. Whitespace is collapsed and a trailing ":" dropped.
+func declarationText(lines []string, i int, stops string) string {
+	var parts []string
+	depth := 0
+	for j := i; j < len(lines) && j < i+repoMapMaxDeclLines; j++ {
+		line := lines[j]
+		cut := len(line)
+		for k := 0; k < len(line); k++ {
+			c := line[k]
+			switch {
+			case c == '(' || c == '[':
+				depth++
+			case c == ')' || c == ']':
+				depth--
+			case depth <= 0 && strings.IndexByte(stops, c) >= 0:
+				// "=>" and "==" are not assignments
+				if c == '=' && k+1 < len(line) && (line[k+1] == '>' || line[k+1] == '=') {
+					continue
+				}
+				cut = k
+			}
+			if cut != len(line) {
+				break
+			}
+		}
+		parts = append(parts, line[:cut])
+		if cut != len(line) || depth <= 0 {
+			break
+		}
+	}
+
+	sig := strings.Join(strings.Fields(strings.Join(parts, " ")), " ")
+	sig = strings.TrimSuffix(sig, ":")
+	sig = strings.ReplaceAll(strings.ReplaceAll(sig, "( ", "("), " )", ")")
+	if len(sig) > repoMapMaxSigLen {
+		sig = sig[:repoMapMaxSigLen] + "..."
+	}
+	return strings.TrimSpace(sig)
+}
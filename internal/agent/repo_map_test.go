@@ -0,0 +1,195 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func signatures(symbols []RepoSymbol) []string {
+	var sigs []string
+	for _, s := range symbols {
+		sigs = append(sigs, s.Signature)
+	}
+	return sigs
+}
+
+func assertSignatures(t *testing.T, got []RepoSymbol, want []string) {
+	t.Helper()
+	sigs := signatures(got)
+	if strings.Join(sigs, "\n") != strings.Join(want, "\n") {
+		t.Errorf("signatures mismatch\ngot:\n  %s\nwant:\n  %s", strings.Join(sigs, "\n  "), strings.Join(want, "\n  "))
+	}
+}
+
+func TestGoExtractor(t *testing.T) {
+	src := `package store
+
+type Store struct{}
+type Reader interface{ Read() }
+type id string
+
+func New(dir string, max int) (*Store, error) { return nil, nil }
+func (s *Store) Get(key string) []byte { return nil }
+func helper() {}
+`
+	assertSignatures(t, GoExtractor{}.Extract("store.go", []byte(src)), []string{
+		"type Store struct",
+		"type Reader interface",
+		"func New(string, int) (*Store, error)",
+		"func (*Store).Get(string) []byte",
+	})
+	if !(GoExtractor{}).IsTest("store_test.go") {
+		t.Error("expected _test.go to be a test file")
+	}
+}
+
+func TestPythonExtractor(t *testing.T) {
+	src := `import os
+
+class Client(Base):
+    """Talks to the API."""
+
+    def __init__(self, url):
+        self.url = url
+
+    def fetch(self, path: str,
+              timeout: float = 1.0) -> Dict[str, int]:
+        def inner():
+            pass
+        return {}
+
+    def _private(self):
+        pass
+
+async def connect(url: str) -> Client:
+    return Client(url)
+
+def _helper():
+    pass
+
+class _Hidden:
+    def visible_but_hidden(self):
+        pass
+`
+	assertSignatures(t, PythonExtractor{}.Extract("client.py", []byte(src)), []string{
+		"class Client(Base)",
+		"def Client.fetch(self, path: str, timeout: float = 1.0) -> Dict[str, int]",
+		"async def connect(url: str) -> Client",
+	})
+	if !(PythonExtractor{}).IsTest("tests/test_client.py") {
+		t.Error("expected test_*.py to be a test file")
+	}
+}
+
+func TestTypeScriptExtractor(t *testing.T) {
+	src := `import { x } from "./x";
+
+export interface Props<T> {
+  value: T;
+}
+export type Handler = (e: Event) => void;
+export default async function render(props: Props<string>): Promise<void> {
+}
+export const DEFAULT_LIMIT = 10;
+export abstract class Widget extends Base {
+}
+function internal() {}
+`
+	assertSignatures(t, TypeScriptExtractor{}.Extract("widget.ts", []byte(src)), []string{
+		"interface Props<T>",
+		"type Handler",
+		"default async function render(props: Props<string>): Promise<void>",
+		"const DEFAULT_LIMIT",
+		"abstract class Widget extends Base",
+	})
+
+	// CommonJS files without exports fall back to top-level declarations
+	cjs := "function handler(req, res) {\n}\nclass Router {\n}\nmodule.exports = { handler, Router };\n"
+	assertSignatures(t, TypeScriptExtractor{}.Extract("server.js", []byte(cjs)), []string{
+		"function handler(req, res)",
+		"class Router",
+	})
+	if !(TypeScriptExtractor{}).IsTest("widget.spec.tsx") {
+		t.Error("expected .spec. files to be test files")
+	}
+}
+
+func TestRustExtractor(t *testing.T) {
+	src := `use std::fmt;
+
+pub struct Config {
+    pub name: String,
+}
+
+pub(crate) enum Mode { Fast, Slow }
+
+impl Config {
+    pub fn new(name: &str) -> Self {
+        Config { name: name.to_string() }
+    }
+
+    fn private(&self) {}
+}
+
+impl fmt::Display for Config {
+    fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result { Ok(()) }
+}
+
+pub async fn run(cfg: Config) -> Result<(), Error>;
+pub const MAX: usize = 10;
+`
+	assertSignatures(t, RustExtractor{}.Extract("lib.rs", []byte(src)), []string{
+		"struct Config",
+		"enum Mode",
+		"fn Config::new(name: &str) -> Self",
+		"fn run(cfg: Config) -> Result<(), Error>",
+		"const MAX: usize",
+	})
+}
+
+func TestRepoMap_RanksByReferences(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"core/core.go":      "package core\n\nfunc Popular() {}\nfunc Unused() {}\n",
+		"core/core_test.go": "package core\n\nfunc TestX() { Popular(); Popular() }\n",
+		"app/main.py":       "from lib import shared\n\ndef entry():\n    shared()\n    Popular()\n",
+		"lib/shared.py":     "def shared():\n    pass\n",
+		"web/index.ts":      "export function render() {}\n",
+		"node_modules/x.js": "export function ignored() {}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out := NewRepoMap(dir).Get()
+	for _, want := range []string{"### core/core.go", "func Popular()", "### lib/shared.py", "def shared()", "### web/index.ts"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected repo map to contain %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "ignored") || strings.Contains(out, "TestX") {
+		t.Errorf("repo map includes skipped files:\n%s", out)
+	}
+
+	// A tight budget keeps only the most-referenced symbol
+	rm := NewRepoMap(dir)
+	rm.budget = 15
+	out = rm.Get()
+	if !strings.Contains(out, "func Popular()") {
+		t.Errorf("expected the most-referenced symbol to survive the budget:\n%s", out)
+	}
+	if strings.Contains(out, "Unused") || strings.Contains(out, "render") {
+		t.Errorf("expected less-referenced symbols to be dropped:\n%s", out)
+	}
+	if !strings.Contains(out, "[... truncated") {
+		t.Errorf("expected truncation marker:\n%s", out)
+	}
+}
@@ -0,0 +1,113 @@
+package agent
+
+import (
+	"strings"
+	"time"
+
+	"github.com/abdul-hamid-achik/vecai/internal/config"
+	"github.com/abdul-hamid-achik/vecai/internal/router"
+	"github.com/abdul-hamid-achik/vecai/internal/tui"
+)
+
+// The learned router trains on what happened to each query. A query's
+// sample stays pending after its agent loop finishes, because whether the
+// tier was right only shows in what the user does next: re-asking the same
+// thing, escalating with /mode, or switching agent mode. The sample is
+// recorded when the next query starts or the agent closes.
+
+// beginRouteSample starts tracking the current query, recording the
+// previous one first.
+func (a *Agent) beginRouteSample() {
+	if a.learnedRouter == nil || a.currentQuery == "" {
+		return
+	}
+
+	a.routeMu.Lock()
+	defer a.routeMu.Unlock()
+
+	if a.pendingRoute != nil {
+		if router.IsRetry(a.pendingRoute.Query, a.currentQuery) {
+			a.pendingRoute.Retried = true
+		}
+		a.recordRouteLocked()
+	}
+
+	a.pendingRoute = &router.Sample{
+		Time:   time.Now(),
+		Query:  a.currentQuery,
+		Tier:   a.currentTier(),
+		Routed: a.autoTier && !a.quickMode,
+		Intent: string(a.currentIntent),
+	}
+	a.currentIntent = ""
+}
+
+// endRouteSample stores how much work the agent loop took.
+func (a *Agent) endRouteSample(iterations, toolCalls int, err error) {
+	a.routeMu.Lock()
+	defer a.routeMu.Unlock()
+
+	if a.pendingRoute == nil {
+		return
+	}
+	a.pendingRoute.Iterations = iterations
+	a.pendingRoute.ToolCalls = toolCalls
+	a.pendingRoute.Failed = err != nil
+}
+
+// noteTierSwitch records a user switch to a stronger tier than the last
+// query ran on.
+func (a *Agent) noteTierSwitch(tier config.ModelTier) {
+	a.routeMu.Lock()
+	defer a.routeMu.Unlock()
+
+	if a.pendingRoute != nil && router.TierRank(tier) > router.TierRank(a.pendingRoute.Tier) {
+		a.pendingRoute.EscalatedTo = tier
+	}
+}
+
+// noteModeSwitch records a user switch of agent mode after the last query.
+func (a *Agent) noteModeSwitch(mode tui.AgentMode) {
+	a.routeMu.Lock()
+	defer a.routeMu.Unlock()
+
+	if a.pendingRoute != nil {
+		a.pendingRoute.SwitchedMode = strings.ToLower(mode.String())
+	}
+}
+
+// flushRouteSample records the pending sample, if any.
+func (a *Agent) flushRouteSample() {
+	a.routeMu.Lock()
+	defer a.routeMu.Unlock()
+
+	if a.pendingRoute != nil {
+		a.recordRouteLocked()
+	}
+}
+
+func (a *Agent) recordRouteLocked() {
+	if err := a.learnedRouter.Record(*a.pendingRoute); err != nil {
+		logWarn("Failed to record router sample: %v", err)
+	}
+	a.pendingRoute = nil
+}
+
+// currentTier maps the active model back to its tier.
+func (a *Agent) currentTier() config.ModelTier {
+	if tier, ok := a.modelTier(); ok {
+		return tier
+	}
+	return a.config.DefaultTier
+}
+
+// modelTier returns the lowest tier configured with the active model.
+func (a *Agent) modelTier() (config.ModelTier, bool) {
+	model := a.llm.GetModel()
+	for _, tier := range []config.ModelTier{config.TierFast, config.TierSmart, config.TierGenius} {
+		if a.config.GetModel(tier) == model {
+			return tier, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,70 @@
+package agent
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/abdul-hamid-achik/vecai/internal/config"
+	"github.com/abdul-hamid-achik/vecai/internal/router"
+	"github.com/abdul-hamid-achik/vecai/internal/tui"
+)
+
+func TestRouteFeedback_RecordsOutcomeOnNextQuery(t *testing.T) {
+	a, mock := newTestAgent(t)
+	dir := t.TempDir()
+	learned, err := router.Open(dir, router.Options{MinSamples: 50, MinConfidence: 0.6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.learnedRouter = learned
+	a.autoTier = true
+	mock.SetModel(a.config.GetModel(config.TierFast))
+
+	// First query runs on fast, then the user escalates and re-asks
+	a.currentQuery = "find the race in the scheduler"
+	a.currentIntent = IntentQuestion
+	a.beginRouteSample()
+	a.endRouteSample(1, 0, nil)
+	a.noteTierSwitch(config.TierSmart)
+	a.noteTierSwitch(config.TierFast) // not an escalation
+	a.applyModeChange(tui.ModeBuild, true)
+
+	if samples, _ := router.LoadSamples(dir); len(samples) != 0 {
+		t.Fatalf("expected the sample to stay pending, got %d", len(samples))
+	}
+
+	mock.SetModel(a.config.GetModel(config.TierSmart))
+	a.currentQuery = "find the race in the scheduler please"
+	a.beginRouteSample()
+	a.endRouteSample(7, 12, errors.New("max iterations"))
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	samples, err := router.LoadSamples(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 2 {
+		t.Fatalf("expected 2 samples, got %d", len(samples))
+	}
+
+	first := samples[0]
+	if first.Tier != config.TierFast || !first.Routed || first.Intent != string(IntentQuestion) {
+		t.Errorf("unexpected first sample %+v", first)
+	}
+	if first.EscalatedTo != config.TierSmart || !first.Retried || first.SwitchedMode != "build" {
+		t.Errorf("expected escalation, retry and mode switch feedback, got %+v", first)
+	}
+	if first.IntentLabel() != string(IntentCode) {
+		t.Errorf("expected the mode switch to relabel the intent, got %s", first.IntentLabel())
+	}
+
+	second := samples[1]
+	if second.Tier != config.TierSmart || second.Iterations != 7 || second.ToolCalls != 12 || !second.Failed {
+		t.Errorf("unexpected second sample %+v", second)
+	}
+	if second.Intent != "" {
+		t.Errorf("expected the intent not to carry over, got %q", second.Intent)
+	}
+}
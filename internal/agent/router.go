@@ -7,6 +7,7 @@ import (
 	"github.com/abdul-hamid-achik/vecai/internal/config"
 	"github.com/abdul-hamid-achik/vecai/internal/debug"
 	"github.com/abdul-hamid-achik/vecai/internal/llm"
+	"github.com/abdul-hamid-achik/vecai/internal/router"
 	"github.com/abdul-hamid-achik/vecai/internal/tui"
 )
 
@@ -24,8 +25,9 @@ const (
 
 // TaskRouter classifies user intent to route to appropriate agent
 type TaskRouter struct {
-	client llm.LLMClient
-	config *config.Config
+	client  llm.LLMClient
+	config  *config.Config
+	learned *router.Router // Learned intents, preferred over keywords once trained
 }
 
 // NewTaskRouter creates a new task router
@@ -36,9 +38,23 @@ func NewTaskRouter(client llm.LLMClient, cfg *config.Config) *TaskRouter {
 	}
 }
 
+// SetLearned makes the router prefer intents learned from past outcomes
+func (r *TaskRouter) SetLearned(learned *router.Router) {
+	r.learned = learned
+}
+
 // ClassifyIntent determines the appropriate intent for a user query
 func (r *TaskRouter) ClassifyIntent(ctx context.Context, query string) Intent {
-	// First try fast keyword-based classification
+	// Learned classification once it has enough samples and is confident
+	if r.learned != nil {
+		if learned, confidence, ok := r.learned.PredictIntent(query); ok {
+			logDebug("Router: classified by learned router as %s (%.2f)", learned, confidence)
+			debug.IntentClassified(query, learned, "learned")
+			return Intent(learned)
+		}
+	}
+
+	// Then try fast keyword-based classification
 	intent := r.classifyByKeywords(query)
 	if intent != "" {
 		logDebug("Router: classified by keywords as %s", intent)
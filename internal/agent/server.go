@@ -0,0 +1,485 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abdul-hamid-achik/vecai/internal/session"
+	"github.com/abdul-hamid-achik/vecai/internal/tools"
+)
+
+// Server errors, distinguished by RPC transports
+var (
+	ErrBusy            = errors.New("a prompt is already running")
+	ErrNoPrompt        = errors.New("no pending permission prompt with that id")
+	ErrNoSessions      = errors.New("session manager not available")
+	ErrSessionNotFound = errors.New("session not found")
+	ErrInvalidArgument = errors.New("invalid argument")
+	ErrClosed          = errors.New("server is closed")
+)
+
+// Server drives the agent for an editor or other client connected through
+// an RPC transport (see internal/serve). It runs one prompt at a time and
+// sends its output as StreamEvents; permission prompts wait for the client
+// to answer with RespondPermission.
+type Server struct {
+	agent *Agent
+	send  func(StreamEvent)
+
+	mu      sync.Mutex
+	running *serverIO
+	closed  bool
+}
+
+// NewServer creates a server for a. send receives every event of every
+// prompt and must not block for long.
+func NewServer(a *Agent, send func(StreamEvent)) *Server {
+	return &Server{agent: a, send: send}
+}
+
+// SessionState describes the session a server is working in.
+type SessionState struct {
+	SessionID string `json:"session_id"`
+	Model     string `json:"model"`
+	Mode      string `json:"mode"`
+	Messages  int    `json:"messages"`
+}
+
+// SessionSummary is a saved session as listed to clients.
+type SessionSummary struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Model     string    `json:"model"`
+	Preview   string    `json:"preview"`
+	Messages  int       `json:"messages"`
+}
+
+// ContextStats is the conversation's context window usage.
+type ContextStats struct {
+	UsedTokens      int     `json:"used_tokens"`
+	ContextWindow   int     `json:"context_window"`
+	UsagePercent    float64 `json:"usage_percent"`
+	Messages        int     `json:"messages"`
+	NeedsWarning    bool    `json:"needs_warning"`
+	NeedsCompaction bool    `json:"needs_compaction"`
+}
+
+// PromptResult is how a prompt ended.
+type PromptResult struct {
+	StopReason string `json:"stop_reason"` // "done", "cancelled" or "error"
+	Error      string `json:"error,omitempty"`
+}
+
+// StartSession starts a new session, or resumes the saved session whose ID
+// starts with resume ("last" for the most recent one).
+func (s *Server) StartSession(resume string) (SessionState, error) {
+	a := s.agent
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running != nil {
+		return SessionState{}, ErrBusy
+	}
+	if a.sessionMgr == nil {
+		return SessionState{}, ErrNoSessions
+	}
+
+	var sess *session.Session
+	var err error
+	switch resume {
+	case "":
+		a.contextMgr.Clear()
+		a.shownContextWarning = false
+		sess, err = a.sessionMgr.StartNew()
+	case "last":
+		sess, err = a.sessionMgr.GetCurrent()
+	default:
+		var infos []session.SessionInfo
+		if infos, err = a.sessionMgr.List(); err == nil {
+			for _, info := range infos {
+				if strings.HasPrefix(info.ID, resume) {
+					sess, err = a.sessionMgr.Load(info.ID)
+					break
+				}
+			}
+		}
+	}
+	if err != nil {
+		return SessionState{}, err
+	}
+	if sess == nil {
+		return SessionState{}, fmt.Errorf("%w: %s", ErrSessionNotFound, resume)
+	}
+	if resume != "" {
+		a.contextMgr.RestoreMessages(sess.Messages)
+		a.contextMgr.SetState(sess.State)
+		a.sessionMgr.SetCurrent(sess)
+	}
+	return s.stateLocked(sess.ID), nil
+}
+
+func (s *Server) stateLocked(sessionID string) SessionState {
+	return SessionState{
+		SessionID: sessionID,
+		Model:     s.agent.llm.GetModel(),
+		Mode:      strings.ToLower(s.agent.agentMode.String()),
+		Messages:  len(s.agent.contextMgr.GetMessages()),
+	}
+}
+
+// ListSessions returns the saved sessions, newest first.
+func (s *Server) ListSessions() ([]SessionSummary, error) {
+	if s.agent.sessionMgr == nil {
+		return nil, ErrNoSessions
+	}
+	infos, err := s.agent.sessionMgr.List()
+	if err != nil {
+		return nil, err
+	}
+	return summarizeSessions(infos), nil
+}
+
+func summarizeSessions(infos []session.SessionInfo) []SessionSummary {
+	summaries := make([]SessionSummary, 0, len(infos))
+	for _, info := range infos {
+		summaries = append(summaries, SessionSummary{
+			ID:        info.ID,
+			CreatedAt: info.CreatedAt,
+			UpdatedAt: info.UpdatedAt,
+			Model:     info.Model,
+			Preview:   info.Preview,
+			Messages:  info.MsgCount,
+		})
+	}
+	return summaries
+}
+
+// SavedSessions lists, loads and deletes saved sessions for transports
+// that run several agents, such as the HTTP API.
+type SavedSessions struct {
+	mu          sync.Mutex
+	mgr         *session.Manager
+	checkpoints *CheckpointManager
+}
+
+// OpenSavedSessions opens the session store every agent saves to.
+func OpenSavedSessions() (*SavedSessions, error) {
+	mgr, err := session.NewManager()
+	if err != nil {
+		return nil, err
+	}
+	s := &SavedSessions{mgr: mgr, checkpoints: NewCheckpointManager()}
+	if wd, wdErr := os.Getwd(); wdErr == nil {
+		s.checkpoints = NewPersistentCheckpointManager(filepath.Join(wd, ".vecai", "checkpoints"))
+	}
+	return s, nil
+}
+
+// List returns the saved sessions, newest first.
+func (s *SavedSessions) List() ([]SessionSummary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	infos, err := s.mgr.List()
+	if err != nil {
+		return nil, err
+	}
+	return summarizeSessions(infos), nil
+}
+
+// Load returns the saved session with the given ID.
+func (s *SavedSessions) Load(id string) (*session.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.findLocked(id); err != nil {
+		return nil, err
+	}
+	return s.mgr.Load(id)
+}
+
+// Delete removes the saved session with the given ID and its checkpoints.
+func (s *SavedSessions) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.findLocked(id); err != nil {
+		return err
+	}
+	if err := s.mgr.Delete(id); err != nil {
+		return err
+	}
+	return s.checkpoints.DeleteSession(id)
+}
+
+// findLocked checks that id names a saved session, so IDs from clients
+// never reach a file path unchecked.
+func (s *SavedSessions) findLocked(id string) error {
+	infos, err := s.mgr.List()
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		if info.ID == id {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrSessionNotFound, id)
+}
+
+// Prompt runs text as a query and blocks until it finishes, sending its
+// output as events. Only one prompt runs at a time.
+func (s *Server) Prompt(ctx context.Context, text string) (PromptResult, error) {
+	run, err := s.begin(text)
+	if err != nil {
+		return PromptResult{}, err
+	}
+	return s.run(ctx, run, text), nil
+}
+
+// Start runs text as a query in the background and calls done with how it
+// ended. Unlike Prompt it returns as soon as the prompt is accepted, so
+// ErrBusy is reported right away.
+func (s *Server) Start(ctx context.Context, text string, done func(PromptResult)) error {
+	run, err := s.begin(text)
+	if err != nil {
+		return err
+	}
+	go func() {
+		result := s.run(ctx, run, text)
+		if done != nil {
+			done(result)
+		}
+	}()
+	return nil
+}
+
+// begin reserves the server for a prompt, starting a session if none is
+// active.
+func (s *Server) begin(text string) (*serverIO, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("%w: prompt text is empty", ErrInvalidArgument)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, ErrClosed
+	}
+	if s.running != nil {
+		return nil, ErrBusy
+	}
+	a := s.agent
+	if a.sessionMgr != nil && a.sessionMgr.GetCurrentSession() == nil {
+		if _, err := a.sessionMgr.StartNew(); err != nil {
+			logWarn("Failed to start session: %v", err)
+		}
+	}
+	s.running = newServerIO(s.send)
+	return s.running, nil
+}
+
+// run executes a prompt reserved by begin.
+func (s *Server) run(ctx context.Context, run *serverIO, text string) PromptResult {
+	a := s.agent
+	defer func() {
+		s.mu.Lock()
+		s.running = nil
+		s.mu.Unlock()
+		close(run.done)
+	}()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-run.interrupt:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	run.Init(a.llm.GetModel(), a.agentMode)
+	err := a.runQuery(ctx, text, "", run, run)
+	run.Finish(err)
+
+	switch {
+	case run.cancelled():
+		return PromptResult{StopReason: "cancelled"}
+	case err != nil:
+		return PromptResult{StopReason: "error", Error: err.Error()}
+	}
+	return PromptResult{StopReason: "done"}
+}
+
+// Cancel interrupts the running prompt. It reports whether one was running.
+func (s *Server) Cancel() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running == nil {
+		return false
+	}
+	s.running.cancel()
+	return true
+}
+
+// Close cancels the running prompt, waits for it to end and refuses new
+// prompts. It does not close the agent.
+func (s *Server) Close() {
+	s.mu.Lock()
+	s.closed = true
+	run := s.running
+	s.mu.Unlock()
+	if run != nil {
+		run.cancel()
+		<-run.done
+	}
+}
+
+// RespondPermission answers the pending permission prompt with the given
+// id. decision is one of allow, deny, always or never.
+func (s *Server) RespondPermission(id, decision string) error {
+	var answer string
+	switch decision {
+	case "allow", "yes", "y":
+		answer = "y"
+	case "deny", "no", "n":
+		answer = "n"
+	case "always", "a":
+		answer = "a"
+	case "never", "v":
+		answer = "v"
+	default:
+		return fmt.Errorf("%w: unknown decision %q (use allow, deny, always or never)", ErrInvalidArgument, decision)
+	}
+
+	s.mu.Lock()
+	run := s.running
+	s.mu.Unlock()
+	if run == nil || !run.answer(id, answer) {
+		return ErrNoPrompt
+	}
+	return nil
+}
+
+// SetMode switches the agent mode the way Shift+Tab does in the TUI.
+func (s *Server) SetMode(mode string) (string, error) {
+	m, ok := parseAgentMode(strings.ToLower(mode))
+	if !ok {
+		return "", fmt.Errorf("%w: unknown mode %q (use ask, plan or build)", ErrInvalidArgument, mode)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running != nil {
+		return "", ErrBusy
+	}
+	s.agent.applyModeChange(m, true)
+	return strings.ToLower(s.agent.agentMode.String()), nil
+}
+
+// ContextStats returns the conversation's context window usage.
+func (s *Server) ContextStats() ContextStats {
+	stats := s.agent.contextMgr.GetStats()
+	return ContextStats{
+		UsedTokens:      stats.UsedTokens,
+		ContextWindow:   stats.ContextWindow,
+		UsagePercent:    stats.UsagePercent,
+		Messages:        stats.MessageCount,
+		NeedsWarning:    stats.NeedsWarning,
+		NeedsCompaction: stats.NeedsCompaction,
+	}
+}
+
+// serverIO is the AgentOutput and AgentInput of one server prompt. Output
+// becomes events; permission prompts get an id and block ReadLine until the
+// client answers or the prompt is cancelled.
+type serverIO struct {
+	*StreamJSONOutput
+
+	interrupt chan struct{}
+	stopOnce  sync.Once
+	done      chan struct{} // Closed when the prompt has finished
+
+	mu      sync.Mutex
+	seq     int
+	pending string      // ID of the unanswered permission prompt
+	answers chan string // Receives the answer to pending
+}
+
+var _ AgentOutput = (*serverIO)(nil)
+var _ AgentInput = (*serverIO)(nil)
+var _ InterruptSupport = (*serverIO)(nil)
+
+func newServerIO(send func(StreamEvent)) *serverIO {
+	return &serverIO{
+		StreamJSONOutput: NewEventOutput(send),
+		interrupt:        make(chan struct{}),
+		done:             make(chan struct{}),
+		answers:          make(chan string, 1),
+	}
+}
+
+func (s *serverIO) GetInterruptChan() <-chan struct{} { return s.interrupt }
+
+func (s *serverIO) cancel() { s.stopOnce.Do(func() { close(s.interrupt) }) }
+
+func (s *serverIO) cancelled() bool {
+	select {
+	case <-s.interrupt:
+		return true
+	default:
+		return false
+	}
+}
+
+// PermissionPrompt sends the prompt with a fresh id for the client to
+// answer.
+func (s *serverIO) PermissionPrompt(toolName string, level tools.PermissionLevel, description string) {
+	s.prompt(toolName, level.String(), description)
+}
+
+func (s *serverIO) prompt(name, permission, description string) {
+	s.mu.Lock()
+	s.seq++
+	id := fmt.Sprintf("perm_%d", s.seq)
+	s.pending = id
+	s.mu.Unlock()
+	s.emit(StreamEvent{Type: EventPermissionPrompt, ID: id, Name: name, Permission: permission, Description: description})
+}
+
+// answer delivers the client's answer to the pending prompt.
+func (s *serverIO) answer(id, answer string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id == "" || id != s.pending {
+		return false
+	}
+	s.pending = ""
+	s.answers <- answer
+	return true
+}
+
+// ReadLine waits for the answer to the last permission prompt. A cancelled
+// prompt is denied.
+func (s *serverIO) ReadLine(_ string) (string, error) {
+	select {
+	case answer := <-s.answers:
+		return answer, nil
+	case <-s.interrupt:
+		s.mu.Lock()
+		s.pending = ""
+		s.mu.Unlock()
+		return "n", nil
+	}
+}
+
+// Confirm asks the client through a permission prompt.
+func (s *serverIO) Confirm(prompt string, _ bool) (bool, error) {
+	s.prompt("", "confirm", prompt)
+	answer, err := s.ReadLine("")
+	return answer == "y" || answer == "a", err
+}
@@ -0,0 +1,174 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/abdul-hamid-achik/vecai/internal/llm"
+	"github.com/abdul-hamid-achik/vecai/internal/permissions"
+)
+
+// scriptedStream makes the mock call the writer tool on its first turn and
+// answer with text afterwards.
+func scriptedStream(mock *llm.MockLLMClient) {
+	var mu sync.Mutex
+	turn := 0
+	mock.ChatStreamFunc = func(_ context.Context, _ []llm.Message, _ []llm.ToolDefinition, _ string) <-chan llm.StreamChunk {
+		mu.Lock()
+		turn++
+		first := turn == 1
+		mu.Unlock()
+
+		ch := make(chan llm.StreamChunk, 2)
+		if first {
+			ch <- llm.StreamChunk{Type: "tool_call", ToolCall: &llm.ToolCall{ID: "c1", Name: "writer", Input: map[string]any{"path": "a.go"}}}
+		} else {
+			ch <- llm.StreamChunk{Type: "text", Text: "all done"}
+		}
+		ch <- llm.StreamChunk{Type: "done"}
+		close(ch)
+		return ch
+	}
+}
+
+// newTestServer returns a server whose writer tool needs permission, and
+// a channel of the events it sends.
+func newTestServer(t *testing.T) (*Server, chan StreamEvent) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	a, mock := newTestAgent(t)
+	a.tools.Register(&mockWriteTool{name: "writer"})
+	a.permissions.SetMode(permissions.ModeAsk)
+	scriptedStream(mock)
+
+	events := make(chan StreamEvent, 100)
+	return NewServer(a, func(ev StreamEvent) { events <- ev }), events
+}
+
+// waitFor returns the next event of the given type.
+func waitFor(t *testing.T, events <-chan StreamEvent, typ string) StreamEvent {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case ev := <-events:
+			if ev.Type == typ {
+				return ev
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %s event", typ)
+		}
+	}
+}
+
+func TestServer_PromptWaitsForPermission(t *testing.T) {
+	srv, events := newTestServer(t)
+	if _, err := srv.StartSession(""); err != nil {
+		t.Fatal(err)
+	}
+
+	type outcome struct {
+		result PromptResult
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		result, err := srv.Prompt(context.Background(), "write a.go")
+		done <- outcome{result, err}
+	}()
+
+	prompt := waitFor(t, events, EventPermissionPrompt)
+	if prompt.ID == "" || prompt.Name != "writer" || prompt.Permission != "write" {
+		t.Fatalf("unexpected permission prompt %+v", prompt)
+	}
+	if _, err := srv.Prompt(context.Background(), "another"); !errors.Is(err, ErrBusy) {
+		t.Errorf("expected ErrBusy while a prompt runs, got %v", err)
+	}
+	if err := srv.RespondPermission("perm_999", "allow"); !errors.Is(err, ErrNoPrompt) {
+		t.Errorf("expected ErrNoPrompt for a wrong id, got %v", err)
+	}
+	if err := srv.RespondPermission(prompt.ID, "allow"); err != nil {
+		t.Fatal(err)
+	}
+
+	result := waitFor(t, events, EventToolResult)
+	if result.ID != "c1" || result.Result != "write-result" {
+		t.Errorf("expected the approved tool to run, got %+v", result)
+	}
+	waitFor(t, events, EventDone)
+
+	out := <-done
+	if out.err != nil || out.result.StopReason != "done" {
+		t.Errorf("unexpected prompt outcome %+v", out)
+	}
+	if stats := srv.ContextStats(); stats.Messages < 4 {
+		t.Errorf("expected the exchange in context, got %+v", stats)
+	}
+}
+
+func TestServer_CancelDeniesPendingPrompt(t *testing.T) {
+	srv, events := newTestServer(t)
+
+	done := make(chan PromptResult, 1)
+	go func() {
+		result, _ := srv.Prompt(context.Background(), "write a.go")
+		done <- result
+	}()
+
+	waitFor(t, events, EventPermissionPrompt)
+	if !srv.Cancel() {
+		t.Fatal("expected a running prompt to cancel")
+	}
+
+	select {
+	case result := <-done:
+		if result.StopReason != "cancelled" {
+			t.Errorf("expected cancelled, got %+v", result)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("prompt did not stop after cancel")
+	}
+	if srv.Cancel() {
+		t.Error("expected nothing to cancel after the prompt ended")
+	}
+}
+
+func TestServer_SetModeAndSessions(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	if mode, err := srv.SetMode("ask"); err != nil || mode != "ask" {
+		t.Errorf("SetMode(ask) = %q, %v", mode, err)
+	}
+	if _, err := srv.SetMode("yolo"); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("expected ErrInvalidArgument, got %v", err)
+	}
+
+	first, err := srv.StartSession("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.agent.contextMgr.AddMessage(llm.Message{Role: "user", Content: "remember this"})
+	if _, err := srv.StartSession(""); err != nil {
+		t.Fatal(err)
+	}
+
+	resumed, err := srv.StartSession(first.SessionID[:8])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resumed.SessionID != first.SessionID || resumed.Messages != 1 {
+		t.Errorf("unexpected resumed session %+v", resumed)
+	}
+	if _, err := srv.StartSession("nope"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected ErrSessionNotFound, got %v", err)
+	}
+
+	// Only sessions with messages are saved
+	sessions, err := srv.ListSessions()
+	if err != nil || len(sessions) != 1 || sessions[0].ID != first.SessionID {
+		t.Errorf("ListSessions = %+v, %v", sessions, err)
+	}
+}
@@ -0,0 +1,94 @@
+package agent
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/abdul-hamid-achik/vecai/internal/audit"
+	"github.com/abdul-hamid-achik/vecai/internal/config"
+	"github.com/abdul-hamid-achik/vecai/internal/logging"
+	"github.com/abdul-hamid-achik/vecai/internal/memory"
+	"github.com/abdul-hamid-achik/vecai/internal/router"
+)
+
+// Shared holds the stores that must have one writer per process: the
+// audit log chains each record to the previous one, and the memory store
+// rewrites its whole file. A server running several agents at once opens
+// them with OpenShared and closes them after its agents; agents never close
+// shared stores. Nil fields stay disabled.
+type Shared struct {
+	Memory *memory.MemoryLayer
+	Audit  *audit.Log
+	Router *router.Router
+}
+
+// OpenShared opens the stores enabled in cfg.
+func OpenShared(cfg *config.Config) *Shared {
+	return &Shared{
+		Memory: openMemoryLayer(cfg),
+		Audit:  openAuditLog(cfg),
+		Router: openLearnedRouter(cfg),
+	}
+}
+
+// Close closes the shared stores.
+func (s *Shared) Close() error {
+	var errs []error
+	if s.Memory != nil {
+		errs = append(errs, s.Memory.Close())
+	}
+	if s.Audit != nil {
+		errs = append(errs, s.Audit.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// openMemoryLayer opens the memory layer for the working directory, or
+// returns nil when memory is disabled or fails to open.
+func openMemoryLayer(cfg *config.Config) *memory.MemoryLayer {
+	if !cfg.Memory.Enabled {
+		return nil
+	}
+	wd, _ := os.Getwd()
+	memLayer, err := memory.NewMemoryLayer(wd)
+	if err != nil {
+		if log := logging.Global(); log != nil {
+			log.Warn("memory layer init failed", logging.Error(err))
+		}
+		return nil
+	}
+	if model := cfg.Memory.EmbeddingModel; model != "" {
+		memLayer.SetEmbedder(memory.NewOllamaEmbedder(cfg.Ollama.BaseURL, model))
+	}
+	return memLayer
+}
+
+// openAuditLog returns the audit log, or nil when auditing is disabled.
+func openAuditLog(cfg *config.Config) *audit.Log {
+	if !cfg.Audit.Enabled {
+		return nil
+	}
+	dir := cfg.Audit.Dir
+	if wd, err := os.Getwd(); err == nil && !filepath.IsAbs(dir) {
+		dir = filepath.Join(wd, dir)
+	}
+	return audit.Open(dir)
+}
+
+// openLearnedRouter opens the learned router, or returns nil when it is
+// disabled or fails to open.
+func openLearnedRouter(cfg *config.Config) *router.Router {
+	rc := cfg.Router
+	if !rc.Learned {
+		return nil
+	}
+	learned, err := router.Open(rc.Dir, router.Options{MinSamples: rc.MinSamples, MinConfidence: rc.MinConfidence})
+	if err != nil {
+		if log := logging.Global(); log != nil {
+			log.Warn("learned router init failed", logging.Error(err))
+		}
+		return nil
+	}
+	return learned
+}
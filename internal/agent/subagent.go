@@ -0,0 +1,307 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	ctxmgr "github.com/abdul-hamid-achik/vecai/internal/context"
+	"github.com/abdul-hamid-achik/vecai/internal/llm"
+	"github.com/abdul-hamid-achik/vecai/internal/tools"
+	"github.com/abdul-hamid-achik/vecai/internal/tui"
+)
+
+// taskToolName is the tool the model calls to start a subagent.
+const taskToolName = "task"
+
+// defaultSubagentMaxIterations bounds a subagent's tool loop when the
+// config sets no budget.
+const defaultSubagentMaxIterations = 10
+
+// maxSubagentReport bounds the report a subagent hands back.
+const maxSubagentReport = 4000
+
+const subagentSystemPrompt = `You are a vecai subagent. Another agent gave you one self-contained task.
+
+Use your tools to investigate, then reply with a concise report. Your reply is all the other agent will see:
+- Lead with the answer or outcome
+- Cite file:line for every finding
+- Quote only the few lines of code that matter, never whole files
+- Say what you could not find or verify
+
+Do not ask questions; nobody can answer them. Work with what you have.`
+
+// taskTool hands a self-contained investigation to a subagent: a child
+// agent with a forked LLM client, a fresh conversation, a subset of the
+// tools and its own iteration budget. Only its report reaches the parent's
+// context.
+type taskTool struct {
+	agent *Agent
+}
+
+func (t *taskTool) Name() string { return taskToolName }
+
+func (t *taskTool) Description() string {
+	return "Hand a self-contained investigation to a subagent with its own context. " +
+		"It works with read-only tools (or the ones listed) and returns only a short report, " +
+		"keeping file contents and search results out of this conversation. " +
+		"Several task calls in one turn run concurrently."
+}
+
+func (t *taskTool) InputSchema() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"description": map[string]any{
+				"type":        "string",
+				"description": "A 3-6 word label for the task, shown to the user",
+			},
+			"prompt": map[string]any{
+				"type":        "string",
+				"description": "The full task. The subagent sees nothing else, so include every detail it needs and say what the report should contain",
+			},
+			"tools": map[string]any{
+				"type":        "array",
+				"items":       map[string]any{"type": "string"},
+				"description": "Tools the subagent may use (default: the read-only tools)",
+			},
+		},
+		"required": []string{"description", "prompt"},
+	}
+}
+
+// Permission is read: the subagent's own tool calls are checked one by one.
+func (t *taskTool) Permission() tools.PermissionLevel { return tools.PermissionRead }
+
+func (t *taskTool) Execute(ctx context.Context, input map[string]any) (string, error) {
+	description, _ := input["description"].(string)
+	prompt, _ := input["prompt"].(string)
+	if strings.TrimSpace(prompt) == "" {
+		return "", errors.New("prompt is required")
+	}
+	if description == "" {
+		description = "task"
+	}
+	var requested []string
+	if list, ok := input["tools"].([]any); ok {
+		for _, v := range list {
+			if name, ok := v.(string); ok && name != "" {
+				requested = append(requested, name)
+			}
+		}
+	}
+	return t.agent.runSubagent(ctx, description, prompt, requested)
+}
+
+// Tool calls carry the output and input of the loop that made them, and
+// their ID, so the task tool can show a subagent's progress under its call.
+type toolIOKey struct{}
+type toolCallIDKey struct{}
+
+type toolIO struct {
+	output AgentOutput
+	input  AgentInput
+}
+
+func withToolIO(ctx context.Context, output AgentOutput, input AgentInput) context.Context {
+	return context.WithValue(ctx, toolIOKey{}, toolIO{output: output, input: input})
+}
+
+func withToolCallID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, toolCallIDKey{}, id)
+}
+
+// runSubagent runs a child agent on prompt and returns its report.
+func (a *Agent) runSubagent(ctx context.Context, description, prompt string, requested []string) (string, error) {
+	defs, err := a.subagentTools(requested)
+	if err != nil {
+		return "", err
+	}
+	allowed := make(map[string]bool, len(defs))
+	for _, d := range defs {
+		allowed[d.Name] = true
+	}
+
+	parent, _ := ctx.Value(toolIOKey{}).(toolIO)
+	taskID, _ := ctx.Value(toolCallIDKey{}).(string)
+	if taskID == "" {
+		taskID = syntheticToolCallID()
+	}
+	output := &subagentOutput{parent: parent.output, task: description, taskID: taskID}
+	var input AgentInput = parent.input
+	if input == nil {
+		input = &HeadlessInput{}
+	}
+
+	// The child shares the parent's tools, permissions, hooks and audit
+	// trail but keeps its own conversation
+	te := NewToolExecutor(a.tools, a.permissions, a.resultCache, a.analysisMode)
+	te.parallelExec = newParallelExecutor(a.tools, a.config.Parallel.MaxConcurrency)
+	te.checkpointMgr = a.checkpointMgr
+	te.auditLog = a.auditLog
+	te.auditMeta = a.toolExecutor.auditMeta
+	te.hooks = a.hooks
+	te.sessionID = a.currentSessionID
+	te.allowTool = func(name string) bool { return allowed[name] }
+	te.promptMu = &a.promptMu
+
+	client := a.llm.Fork()
+	budget := a.config.Agent.SubagentMaxIterations
+	if budget <= 0 {
+		budget = defaultSubagentMaxIterations
+	}
+	logDebug("Subagent %s: %q with %d tools, budget %d", taskID, description, len(defs), budget)
+
+	messages := []llm.Message{{Role: "user", Content: prompt}}
+	// Tool results are fitted to the child's own conversation, which shares
+	// the parent's context window size
+	window := a.contextMgr.GetStats().ContextWindow
+	te.budget = ctxmgr.NewToolBudget(func() (int, int) {
+		return ctxmgr.EstimateMessagesTokens(subagentSystemPrompt, messages), window
+	}, a.config.Context.ToolBudgets)
+	for i := 0; i < budget; i++ {
+		resp, err := client.Chat(ctx, messages, defs, subagentSystemPrompt)
+		if err != nil {
+			return "", fmt.Errorf("subagent failed: %w", err)
+		}
+		if len(resp.ToolCalls) == 0 {
+			return subagentReport(resp.Content), nil
+		}
+
+		calls := make([]llm.ToolCall, len(resp.ToolCalls))
+		for j, call := range resp.ToolCalls {
+			if call.ID == "" {
+				call.ID = syntheticToolCallID()
+			}
+			calls[j] = call
+		}
+		messages = append(messages, llm.Message{Role: "assistant", Content: resp.Content, ToolCalls: calls})
+		for _, r := range te.ExecuteToolCalls(ctx, calls, output, input) {
+			messages = append(messages, llm.Message{Role: "tool", Content: r.Result, ToolCallID: r.ToolCallID})
+		}
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+	}
+
+	// Out of budget: ask for a report on what it found so far
+	messages = append(messages, llm.Message{
+		Role:    "user",
+		Content: "You have used your tool budget. Write your report now from what you found, and say what is left unchecked.",
+	})
+	resp, err := client.Chat(ctx, messages, nil, subagentSystemPrompt)
+	if err != nil {
+		return "", fmt.Errorf("subagent failed: %w", err)
+	}
+	return subagentReport(resp.Content), nil
+}
+
+// subagentTools returns the tool definitions a subagent may use: the
+// requested tools, or the read-only ones when none are named. Subagents
+// cannot start subagents, and they keep the limits of Ask mode and of a
+// running custom command.
+func (a *Agent) subagentTools(requested []string) ([]llm.ToolDefinition, error) {
+	names := requested
+	if len(names) == 0 {
+		for _, tool := range a.tools.List() {
+			if tool.Name() != taskToolName && (readOnlyToolNames[tool.Name()] || a.isReadOnlyMCPTool(tool.Name())) {
+				names = append(names, tool.Name())
+			}
+		}
+	}
+
+	var defs []llm.ToolDefinition
+	for _, name := range names {
+		tool, ok := a.tools.Get(name)
+		switch {
+		case name == taskToolName:
+			return nil, errors.New("subagents cannot start subagents")
+		case !ok:
+			return nil, fmt.Errorf("unknown tool: %s", name)
+		case a.agentMode == tui.ModeAsk && !readOnlyToolNames[name] && !a.isReadOnlyMCPTool(name):
+			return nil, fmt.Errorf("tool %s is not available in Ask mode", name)
+		case a.activeCommand != nil && !a.activeCommand.AllowsTool(name):
+			return nil, fmt.Errorf("tool %s is not allowed by this command", name)
+		}
+		defs = append(defs, llm.ToolDefinition{
+			Name:        tool.Name(),
+			Description: tool.Description(),
+			InputSchema: tool.InputSchema(),
+		})
+	}
+	return defs, nil
+}
+
+// subagentReport trims a subagent's final reply to report length.
+func subagentReport(content string) string {
+	report := strings.TrimSpace(content)
+	if report == "" {
+		return "(the subagent returned no report)"
+	}
+	if len(report) > maxSubagentReport {
+		report = report[:maxSubagentReport] + "\n\n... (report truncated)"
+	}
+	return report
+}
+
+// subagentOutput shows a subagent's tool calls nested under the task call
+// that started it, on outputs that support it, and keeps the subagent's
+// own text out of the parent's transcript. Warnings and permission prompts
+// still reach the user.
+type subagentOutput struct {
+	parent AgentOutput // nil discards everything
+	task   string      // The task's label
+	taskID string      // ID of the task call, prefixed to the subagent's call IDs
+}
+
+var _ AgentOutput = (*subagentOutput)(nil)
+var _ ToolCallSupport = (*subagentOutput)(nil)
+
+func (s *subagentOutput) StreamText(string)                      {}
+func (s *subagentOutput) StreamThinking(string)                  {}
+func (s *subagentOutput) StreamDone()                            {}
+func (s *subagentOutput) StreamDoneWithUsage(int64, int64)       {}
+func (s *subagentOutput) Text(string)                            {}
+func (s *subagentOutput) TextLn(string)                          {}
+func (s *subagentOutput) Success(string)                         {}
+func (s *subagentOutput) Info(string)                            {}
+func (s *subagentOutput) Header(string)                          {}
+func (s *subagentOutput) Separator()                             {}
+func (s *subagentOutput) Thinking(string)                        {}
+func (s *subagentOutput) ThinkingLn(string)                      {}
+func (s *subagentOutput) ModelInfo(string)                       {}
+func (s *subagentOutput) Activity(string)                        {}
+func (s *subagentOutput) Done()                                  {}
+func (s *subagentOutput) ToolCall(name, description string)      {}
+func (s *subagentOutput) ToolResult(name, result string, _ bool) {}
+
+func (s *subagentOutput) Error(err error) { s.ErrorStr(err.Error()) }
+
+func (s *subagentOutput) ErrorStr(msg string) { s.Warning(msg) }
+
+func (s *subagentOutput) Warning(msg string) {
+	if s.parent != nil {
+		s.parent.Warning(fmt.Sprintf("[%s] %s", s.task, msg))
+	}
+}
+
+func (s *subagentOutput) PermissionPrompt(toolName string, level tools.PermissionLevel, description string) {
+	if s.parent != nil {
+		s.parent.PermissionPrompt(toolName, level, fmt.Sprintf("[%s] %s", s.task, description))
+	}
+}
+
+func (s *subagentOutput) ToolCallStarted(call llm.ToolCall, description string) {
+	if sub, ok := s.parent.(SubagentSupport); ok {
+		call.ID = s.taskID + "/" + call.ID
+		sub.SubagentToolStarted(s.task, call, description)
+	}
+}
+
+func (s *subagentOutput) ToolCallFinished(call llm.ToolCall, result string, isError bool) {
+	if sub, ok := s.parent.(SubagentSupport); ok {
+		call.ID = s.taskID + "/" + call.ID
+		sub.SubagentToolFinished(s.task, call, result, isError)
+	}
+}
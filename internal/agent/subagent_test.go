@@ -0,0 +1,144 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/abdul-hamid-achik/vecai/internal/llm"
+	"github.com/abdul-hamid-achik/vecai/internal/permissions"
+	"github.com/abdul-hamid-achik/vecai/internal/tui"
+)
+
+// subagentRecorder is an output that shows subagent tool calls.
+type subagentRecorder struct {
+	mockOutput
+	nested []string
+}
+
+func (r *subagentRecorder) SubagentToolStarted(task string, call llm.ToolCall, _ string) {
+	r.mu.Lock()
+	r.nested = append(r.nested, task+" "+call.ID+" "+call.Name)
+	r.mu.Unlock()
+}
+
+func (r *subagentRecorder) SubagentToolFinished(string, llm.ToolCall, string, bool) {}
+
+func TestTaskTool_RunsSubagent(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := os.WriteFile("main.go", []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	a, mock := newTestAgent(t)
+
+	var toolNames []string
+	var seen string
+	mock.ChatFunc = func(_ context.Context, msgs []llm.Message, defs []llm.ToolDefinition, _ string) (*llm.Response, error) {
+		if len(msgs) == 1 {
+			for _, d := range defs {
+				toolNames = append(toolNames, d.Name)
+			}
+			return &llm.Response{ToolCalls: []llm.ToolCall{{ID: "c1", Name: "read_file", Input: map[string]any{"path": "main.go"}}}}, nil
+		}
+		seen = msgs[len(msgs)-1].Content
+		return &llm.Response{Content: "  main.go:1 declares package main  "}, nil
+	}
+
+	out := &subagentRecorder{}
+	calls := []llm.ToolCall{{ID: "t1", Name: "task", Input: map[string]any{"description": "find package", "prompt": "Which package is main.go in?"}}}
+	results := a.toolExecutor.ExecuteToolCalls(context.Background(), calls, out, &mockInput{})
+
+	if results[0].Error || results[0].Result != "main.go:1 declares package main" {
+		t.Errorf("expected the subagent's report, got %+v", results[0])
+	}
+	if !strings.Contains(seen, "package main") {
+		t.Errorf("expected the subagent to see its tool result, got %q", seen)
+	}
+	for _, name := range toolNames {
+		if !readOnlyToolNames[name] || name == "task" {
+			t.Errorf("expected only read-only tools without task, got %v", toolNames)
+			break
+		}
+	}
+	if len(out.nested) != 1 || out.nested[0] != "find package t1/c1 read_file" {
+		t.Errorf("expected the subagent's call nested under the task, got %v", out.nested)
+	}
+	if len(a.contextMgr.GetMessages()) != 0 {
+		t.Error("expected the subagent to leave the parent conversation alone")
+	}
+}
+
+func TestTaskTool_BudgetEndsWithReport(t *testing.T) {
+	a, mock := newTestAgent(t)
+	a.config.Agent.SubagentMaxIterations = 2
+
+	var mu sync.Mutex
+	var withTools, withoutTools int
+	mock.ChatFunc = func(_ context.Context, _ []llm.Message, defs []llm.ToolDefinition, _ string) (*llm.Response, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if defs == nil {
+			withoutTools++
+			return &llm.Response{Content: "partial findings"}, nil
+		}
+		withTools++
+		return &llm.Response{ToolCalls: []llm.ToolCall{{Name: "list_files", Input: map[string]any{}}}}, nil
+	}
+
+	report, err := a.runSubagent(context.Background(), "loop", "list forever", nil)
+	if err != nil || report != "partial findings" {
+		t.Errorf("expected a report once the budget ran out, got %q, %v", report, err)
+	}
+	if withTools != 2 || withoutTools != 1 {
+		t.Errorf("expected 2 tool turns and a final report turn, got %d and %d", withTools, withoutTools)
+	}
+}
+
+func TestExecuteToolCalls_TasksRunConcurrently(t *testing.T) {
+	a, mock := newTestAgent(t)
+	a.permissions.SetMode(permissions.ModeAsk)
+
+	var started atomic.Int32
+	mock.ChatFunc = func(_ context.Context, _ []llm.Message, _ []llm.ToolDefinition, _ string) (*llm.Response, error) {
+		started.Add(1)
+		deadline := time.Now().Add(2 * time.Second)
+		for started.Load() < 2 && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		if started.Load() < 2 {
+			return &llm.Response{Content: "alone"}, nil
+		}
+		return &llm.Response{Content: "together"}, nil
+	}
+
+	calls := []llm.ToolCall{
+		{ID: "t1", Name: "task", Input: map[string]any{"description": "one", "prompt": "first"}},
+		{ID: "t2", Name: "task", Input: map[string]any{"description": "two", "prompt": "second"}},
+	}
+	results := a.toolExecutor.ExecuteToolCalls(context.Background(), calls, &mockOutput{}, &mockInput{})
+	for _, r := range results {
+		if r.Result != "together" {
+			t.Errorf("expected task calls to run concurrently in Ask mode, got %+v", r)
+		}
+	}
+}
+
+func TestSubagentTools(t *testing.T) {
+	a, _ := newTestAgent(t)
+
+	for _, requested := range [][]string{{"task"}, {"no_such_tool"}, {"read_file", "write_file"}} {
+		if _, err := a.subagentTools(requested); err == nil {
+			t.Errorf("expected %v to be refused in Ask mode", requested)
+		}
+	}
+
+	a.agentMode = tui.ModeBuild
+	defs, err := a.subagentTools([]string{"read_file", "write_file"})
+	if err != nil || len(defs) != 2 {
+		t.Errorf("expected the requested tools in Build mode, got %v, %v", defs, err)
+	}
+}
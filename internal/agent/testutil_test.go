@@ -19,6 +19,8 @@ func newTestAgent(t *testing.T) (*Agent, *llm.MockLLMClient) {
 	cfg := config.DefaultConfig()
 	// Disable memory to avoid filesystem side effects in tests.
 	cfg.Memory.Enabled = false
+	cfg.Audit.Enabled = false
+	cfg.Router.Learned = false
 
 	registry := tools.NewRegistry(&cfg.Tools)
 	output := ui.NewOutputHandler()
@@ -1,19 +1,28 @@
 package agent
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/abdul-hamid-achik/vecai/internal/config"
+	"github.com/abdul-hamid-achik/vecai/internal/router"
 )
 
 // TierSelector automatically selects the appropriate model tier based on query complexity
-type TierSelector struct{}
+type TierSelector struct {
+	learned *router.Router // Replaces the trigger lists once trained; nil for keywords only
+}
 
 // NewTierSelector creates a new tier selector
 func NewTierSelector() *TierSelector {
 	return &TierSelector{}
 }
 
+// SetLearned makes the selector prefer tiers learned from past outcomes
+func (ts *TierSelector) SetLearned(r *router.Router) {
+	ts.learned = r
+}
+
 // simpleTriggers are patterns that indicate a simple query suitable for fast tier
 var simpleTriggers = []string{
 	"where is",
@@ -63,6 +72,13 @@ var complexTriggers = []string{
 
 // SelectTier chooses the appropriate model tier based on query content
 func (ts *TierSelector) SelectTier(query string, defaultTier config.ModelTier) config.ModelTier {
+	// Learned routing wins once it has enough samples and is confident
+	if ts.learned != nil {
+		if tier, _, ok := ts.learned.PredictTier(query); ok {
+			return tier
+		}
+	}
+
 	queryLower := strings.ToLower(query)
 
 	// Check for complex triggers first (higher priority)
@@ -85,6 +101,12 @@ func (ts *TierSelector) SelectTier(query string, defaultTier config.ModelTier) c
 
 // GetTierReason returns a human-readable reason for the tier selection
 func (ts *TierSelector) GetTierReason(query string) string {
+	if ts.learned != nil {
+		if tier, confidence, ok := ts.learned.PredictTier(query); ok {
+			return fmt.Sprintf("learned router chose %s (%.0f%% confidence)", tier, confidence*100)
+		}
+	}
+
 	queryLower := strings.ToLower(query)
 
 	for _, trigger := range complexTriggers {
@@ -1,9 +1,11 @@
 package agent
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/abdul-hamid-achik/vecai/internal/config"
+	"github.com/abdul-hamid-achik/vecai/internal/router"
 )
 
 func TestNewTierSelector(t *testing.T) {
@@ -187,3 +189,36 @@ func TestSelectTier_DefaultTierIsUsed(t *testing.T) {
 		t.Errorf("SelectTier with no triggers = %q, want %q", got, config.TierGenius)
 	}
 }
+
+func TestSelectTier_LearnedRouter(t *testing.T) {
+	learned, err := router.Open(t.TempDir(), router.Options{MinSamples: 4, MinConfidence: 0.6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := NewTierSelector()
+	ts.SetLearned(learned)
+
+	// Untrained: keyword triggers still apply ("find" is a simple trigger)
+	query := "find the race in the scheduler"
+	if tier := ts.SelectTier(query, config.TierSmart); tier != config.TierFast {
+		t.Fatalf("expected keyword routing before training, got %s", tier)
+	}
+
+	for _, s := range []router.Sample{
+		{Query: "where is the config", Tier: config.TierFast, Iterations: 1},
+		{Query: "show me the readme", Tier: config.TierFast, Iterations: 1},
+		{Query: "find the race in the worker pool", Tier: config.TierFast, Iterations: 1, EscalatedTo: config.TierGenius},
+		{Query: "race condition in the scheduler", Tier: config.TierFast, Iterations: 1, EscalatedTo: config.TierGenius},
+	} {
+		if err := learned.Record(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if tier := ts.SelectTier(query, config.TierSmart); tier != config.TierGenius {
+		t.Errorf("expected learned routing to pick genius, got %s", tier)
+	}
+	if reason := ts.GetTierReason(query); !strings.Contains(reason, "learned router") {
+		t.Errorf("unexpected reason %q", reason)
+	}
+}
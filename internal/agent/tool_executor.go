@@ -4,10 +4,14 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
 
+	"github.com/abdul-hamid-achik/vecai/internal/audit"
 	ctxmgr "github.com/abdul-hamid-achik/vecai/internal/context"
 	"github.com/abdul-hamid-achik/vecai/internal/debug"
+	"github.com/abdul-hamid-achik/vecai/internal/hooks"
 	"github.com/abdul-hamid-achik/vecai/internal/llm"
+	"github.com/abdul-hamid-achik/vecai/internal/logging"
 	"github.com/abdul-hamid-achik/vecai/internal/permissions"
 	"github.com/abdul-hamid-achik/vecai/internal/tools"
 )
@@ -36,9 +40,16 @@ type ToolExecutor struct {
 	tools         *tools.Registry
 	permissions   *permissions.Policy
 	resultCache   *ctxmgr.ToolResultCache
+	budget        *ctxmgr.ToolBudget // Optional: fits results to the free context window
 	parallelExec  *parallelExecutor
 	analysisMode  bool
 	checkpointMgr *CheckpointManager // Optional: records file state before writes
+	auditLog      *audit.Log         // Optional: records every call and its permission decision
+	auditMeta     func() (sessionID, model string)
+	hooks         *hooks.Runner          // Optional: PreToolUse and PostToolUse hooks
+	sessionID     func() string          // Session ID sent to hooks
+	allowTool     func(name string) bool // Optional: tools a running custom command or subagent allows
+	promptMu      *sync.Mutex            // Optional: serializes permission prompts of concurrent subagents
 }
 
 // NewToolExecutor creates a new ToolExecutor.
@@ -58,7 +69,12 @@ func (te *ToolExecutor) canParallelize(calls []llm.ToolCall) bool {
 	if len(calls) < 2 {
 		return false
 	}
-	if te.permissions.GetMode() != permissions.ModeAuto {
+	// Subagents check their own tool calls, so task calls need no auto mode
+	if te.permissions.GetMode() != permissions.ModeAuto && !allTasks(calls) {
+		return false
+	}
+	// Hooks run in order around each call
+	if te.hooks.Has(hooks.PreToolUse) || te.hooks.Has(hooks.PostToolUse) {
 		return false
 	}
 	for _, call := range calls {
@@ -69,6 +85,23 @@ func (te *ToolExecutor) canParallelize(calls []llm.ToolCall) bool {
 		if tool.Permission() != tools.PermissionRead {
 			return false
 		}
+		if te.allowTool != nil && !te.allowTool(call.Name) {
+			return false
+		}
+		// Rule-denied calls take the sequential path, which reports the denial
+		if v := te.permissions.Evaluate(call.Name, tool.Permission(), call.Input); !v.Allowed || v.Prompt {
+			return false
+		}
+	}
+	return true
+}
+
+// allTasks reports whether every call starts a subagent.
+func allTasks(calls []llm.ToolCall) bool {
+	for _, call := range calls {
+		if call.Name != taskToolName {
+			return false
+		}
 	}
 	return true
 }
@@ -77,6 +110,7 @@ func (te *ToolExecutor) canParallelize(calls []llm.ToolCall) bool {
 // It works for both CLI and TUI modes via the AgentOutput and AgentInput interfaces.
 // When all calls are read-only and auto-permission is enabled, they run in parallel.
 func (te *ToolExecutor) ExecuteToolCalls(ctx context.Context, calls []llm.ToolCall, output AgentOutput, input AgentInput) []toolResult {
+	ctx = withToolIO(ctx, output, input)
 	if te.canParallelize(calls) {
 		return te.executeParallel(ctx, calls, output)
 	}
@@ -87,6 +121,7 @@ func (te *ToolExecutor) ExecuteToolCalls(ctx context.Context, calls []llm.ToolCa
 		// Check for context cancellation between tool calls
 		select {
 		case <-ctx.Done():
+			te.audit(call, audit.DecisionCancelled, permissions.Verdict{}, "", false)
 			results = append(results, toolResult{
 				Name:       call.Name,
 				Result:     "Interrupted",
@@ -103,6 +138,7 @@ func (te *ToolExecutor) ExecuteToolCalls(ctx context.Context, calls []llm.ToolCa
 		// so the model can learn the correct format and retry
 		if call.ParseError != "" {
 			debug.ToolResult(call.Name, false, 0)
+			te.audit(call, audit.DecisionInvalid, permissions.Verdict{}, "", false)
 			errMsg := fmt.Sprintf("Tool call '%s' failed: could not parse arguments (%s). "+
 				"Please provide arguments as a valid JSON object. Example: {\"path\": \"file.go\"}", call.Name, call.ParseError)
 			results = append(results, toolResult{
@@ -111,7 +147,7 @@ func (te *ToolExecutor) ExecuteToolCalls(ctx context.Context, calls []llm.ToolCa
 				Error:      true,
 				ToolCallID: callID,
 			})
-			output.ToolResult(call.Name, errMsg, true)
+			showToolResult(output, call, errMsg, true)
 			continue
 		}
 
@@ -120,131 +156,278 @@ func (te *ToolExecutor) ExecuteToolCalls(ctx context.Context, calls []llm.ToolCa
 		tool, ok := te.tools.Get(call.Name)
 		if !ok {
 			debug.ToolResult(call.Name, false, 0)
+			te.audit(call, audit.DecisionInvalid, permissions.Verdict{}, "", false)
 			results = append(results, toolResult{
 				Name:       call.Name,
 				Result:     fmt.Sprintf("Unknown tool: %s", call.Name),
 				Error:      true,
 				ToolCallID: callID,
 			})
+			showToolResult(output, call, "Unknown tool: "+call.Name, true)
+			continue
+		}
+
+		if te.allowTool != nil && !te.allowTool(call.Name) {
+			debug.ToolResult(call.Name, false, 0)
+			te.audit(call, audit.DecisionDeny, permissions.Verdict{}, "", false)
+			msg := fmt.Sprintf("Tool %s is not allowed by this command", call.Name)
+			results = append(results, toolResult{
+				Name:       call.Name,
+				Result:     msg,
+				Error:      true,
+				ToolCallID: callID,
+			})
+			showToolResult(output, call, msg, true)
 			continue
 		}
 
+		// PreToolUse hooks may block the call or rewrite its input
+		pre := te.runToolHook(ctx, hooks.Input{Event: hooks.PreToolUse, ToolInput: call.Input}, call, output)
+		if pre.Blocked {
+			debug.ToolResult(call.Name, false, 0)
+			te.audit(call, audit.DecisionDeny, permissions.Verdict{Source: permissions.SourceHook}, "", false)
+			msg := "Blocked by hook: " + pre.Reason
+			results = append(results, toolResult{
+				Name:       call.Name,
+				Result:     withHookContext(msg, pre.Context),
+				Error:      true,
+				ToolCallID: callID,
+			})
+			showToolResult(output, call, msg, true)
+			continue
+		}
+		if pre.UpdatedInput != nil {
+			call.Input = pre.UpdatedInput
+		}
+
 		description := formatToolDescription(call.Name, call.Input)
 
 		// Check permission
-		allowed, err := te.checkPermission(call.Name, tool.Permission(), description, output, input)
+		verdict, err := te.checkPermission(ctx, call.Name, tool.Permission(), description, call.Input, output, input)
 		if err != nil {
 			debug.ToolResult(call.Name, false, 0)
+			te.audit(call, audit.DecisionError, verdict, "", false)
 			results = append(results, toolResult{
 				Name:       call.Name,
 				Result:     fmt.Sprintf("Permission error: %s", err),
 				Error:      true,
 				ToolCallID: callID,
 			})
-			output.ToolResult(call.Name, "Permission error: "+err.Error(), true)
+			showToolResult(output, call, "Permission error: "+err.Error(), true)
 			continue
 		}
 
-		if !allowed {
+		if !verdict.Allowed {
 			debug.ToolResult(call.Name, false, 0)
+			te.audit(call, audit.DecisionDeny, verdict, "", false)
 			results = append(results, toolResult{
 				Name:       call.Name,
 				Result:     "Permission denied by user",
 				Error:      true,
 				ToolCallID: callID,
 			})
-			output.ToolResult(call.Name, "Permission denied", true)
+			showToolResult(output, call, "Permission denied", true)
 			continue
 		}
 
 		// Show tool call after permission granted
-		output.ToolCall(call.Name, description)
+		showToolCall(output, call, description)
 
 		// Save file state before write operations for /rewind
-		if te.checkpointMgr != nil && (call.Name == "write_file" || call.Name == "edit_file") {
-			if path, ok := call.Input["path"].(string); ok {
+		changedPaths := tools.ChangedPaths(call.Name, call.Input)
+		if te.checkpointMgr != nil {
+			for _, path := range changedPaths {
 				te.checkpointMgr.SaveFileState(path)
 			}
 		}
 
 		// Execute tool
-		result, err := tool.Execute(ctx, call.Input)
+		result, err := tool.Execute(withToolCallID(ctx, callID), call.Input)
 		if err != nil {
 			debug.ToolResult(call.Name, false, 0)
+			te.audit(call, audit.DecisionAllow, verdict, err.Error(), true)
 			results = append(results, toolResult{
 				Name:       call.Name,
 				Result:     fmt.Sprintf("Error: %s", err),
 				Error:      true,
 				ToolCallID: callID,
 			})
-			output.ToolResult(call.Name, err.Error(), true)
+			showToolResult(output, call, err.Error(), true)
 		} else {
 			debug.ToolResult(call.Name, true, len(result))
-			// Truncate large tool outputs to prevent memory bloat
-			result = truncateToolOutput(result)
-			contextResult := result
-			if te.resultCache != nil && ctxmgr.ShouldCache(result) {
-				summary, _ := te.resultCache.Store(call.Name, call.Input, result)
-				contextResult = summary
+			te.audit(call, audit.DecisionAllow, verdict, result, false)
+			// Keep long-lived tool state (e.g. the gopls session) in sync with writes
+			for _, path := range changedPaths {
+				te.tools.NotifyFileChanged(path)
 			}
+			// The full result goes to the budget and the cache; what is
+			// shown is truncated to prevent memory bloat
+			contextResult := te.contextResult(call.Name, call.Input, result, 1)
+			result = truncateToolOutput(result)
 			results = append(results, toolResult{
 				Name:       call.Name,
 				Result:     contextResult,
 				Error:      false,
 				ToolCallID: callID,
 			})
-			output.ToolResult(call.Name, result, false)
+			showToolResult(output, call, result, false)
+		}
+
+		// PostToolUse hooks see the result and may add to it
+		last := &results[len(results)-1]
+		hookResult := result
+		if err != nil {
+			hookResult = err.Error()
 		}
+		post := te.runToolHook(ctx, hooks.Input{Event: hooks.PostToolUse, ToolInput: call.Input, Result: hookResult, IsError: err != nil}, call, output)
+		last.Result = withHookContext(last.Result, pre.Context, post.Context, post.Reason)
 	}
 
 	return results
 }
 
-// checkPermission checks permission using the unified output/input interfaces.
-func (te *ToolExecutor) checkPermission(toolName string, level tools.PermissionLevel, description string, output AgentOutput, input AgentInput) (bool, error) {
-	// Auto mode: always allow
-	if te.permissions.GetMode() == permissions.ModeAuto {
-		return true, nil
+// contextResult returns a tool result as it goes into the conversation.
+// With a budget, the full result is cached and fitted to the tool's share
+// of the free context window; without one, it is truncated and long results
+// are replaced by a cached summary. results is the number of results added
+// to the context together.
+func (te *ToolExecutor) contextResult(name string, input map[string]any, result string, results int) string {
+	// Subagent reports are already short, and recalled output was fitted
+	// when it was paged
+	if name == taskToolName || name == ctxmgr.RecallToolName {
+		return truncateToolOutput(result)
+	}
+	if te.budget != nil {
+		return te.budget.Fit(te.resultCache, name, input, result, results)
 	}
+	result = truncateToolOutput(result)
+	if te.resultCache != nil && ctxmgr.ShouldCache(result) {
+		summary, _ := te.resultCache.Store(name, input, result)
+		return summary
+	}
+	return result
+}
 
-	// Check cache
-	if decision, ok := te.permissions.GetCachedDecision(toolName); ok {
-		switch decision {
-		case permissions.DecisionAlwaysAllow:
-			return true, nil
-		case permissions.DecisionNeverAllow:
-			return false, nil
+// runToolHook runs the hooks for a tool event and shows their warnings.
+func (te *ToolExecutor) runToolHook(ctx context.Context, in hooks.Input, call llm.ToolCall, output AgentOutput) hooks.Outcome {
+	if !te.hooks.Has(in.Event) {
+		return hooks.Outcome{}
+	}
+	in.Tool = call.Name
+	in.ToolCallID = call.ID
+	if te.sessionID != nil {
+		in.SessionID = te.sessionID()
+	}
+	out := te.hooks.Run(ctx, in)
+	for _, w := range out.Warnings {
+		output.Warning(w)
+	}
+	return out
+}
+
+// withHookContext appends text added by hooks to what the model sees.
+func withHookContext(content string, extra ...string) string {
+	for _, e := range extra {
+		if e != "" {
+			content += "\n\n[Hook] " + e
 		}
 	}
+	return content
+}
 
-	// Ask mode: auto-approve reads
-	if te.permissions.GetMode() == permissions.ModeAsk && level == tools.PermissionRead {
-		return true, nil
+// checkPermission checks permission using the unified output/input interfaces.
+// Project rules are matched against the call's input; "always" and "never"
+// answers are remembered as scoped rules when rules are configured. Inputs
+// implementing PermissionDecider answer instead of the user.
+func (te *ToolExecutor) checkPermission(ctx context.Context, toolName string, level tools.PermissionLevel, description string, toolInput map[string]any, output AgentOutput, input AgentInput) (permissions.Verdict, error) {
+	v := te.permissions.Evaluate(toolName, level, toolInput)
+	if !v.Prompt {
+		return v, nil
 	}
+	v.Prompt = false
+
+	if te.promptMu != nil {
+		te.promptMu.Lock()
+		defer te.promptMu.Unlock()
+	}
+
+	if decider, ok := input.(PermissionDecider); ok {
+		v.Source = permissions.SourceCmd
+		var err error
+		v.Allowed, err = decider.DecidePermission(ctx, PermissionRequest{
+			Tool:        toolName,
+			Permission:  level.String(),
+			Description: description,
+			Input:       toolInput,
+		})
+		return v, err
+	}
+	v.Source = permissions.SourceUser
 
 	// Prompt user via output/input interfaces
 	output.PermissionPrompt(toolName, level, description)
 
 	response, err := input.ReadLine("")
 	if err != nil {
-		return false, fmt.Errorf("failed to read response: %w", err)
+		return v, fmt.Errorf("failed to read response: %w", err)
 	}
 
 	response = strings.ToLower(strings.TrimSpace(response))
 
 	switch response {
 	case "y", "yes":
-		return true, nil
+		v.Allowed = true
 	case "n", "no":
-		return false, nil
 	case "a", "always":
-		te.permissions.CacheDecision(toolName, permissions.DecisionAlwaysAllow)
-		return true, nil
+		te.rememberDecision(toolName, toolInput, permissions.DecisionAlwaysAllow, v.Before, output)
+		v.Allowed = true
 	case "v", "never":
-		te.permissions.CacheDecision(toolName, permissions.DecisionNeverAllow)
-		return false, nil
-	default:
-		return false, nil
+		te.rememberDecision(toolName, toolInput, permissions.DecisionNeverAllow, v.Before, output)
+	}
+	return v, nil
+}
+
+// rememberDecision saves an "always"/"never" answer and tells the user which
+// rule was added, if any.
+func (te *ToolExecutor) rememberDecision(toolName string, toolInput map[string]any, decision permissions.Decision, before int, output AgentOutput) {
+	rule, err := te.permissions.Remember(toolName, toolInput, decision, before)
+	if err != nil {
+		output.Warning(err.Error())
+		return
+	}
+	if te.permissions.Rules() != nil {
+		verb := "Allowed"
+		if decision == permissions.DecisionNeverAllow {
+			verb = "Denied"
+		}
+		output.Info(fmt.Sprintf("%s from now on (saved rule %q)", verb, rule))
+	}
+}
+
+// audit records a call in the audit log, if one is configured. result is the
+// full tool output (or error message) and is stored only as a hash.
+func (te *ToolExecutor) audit(call llm.ToolCall, decision string, verdict permissions.Verdict, result string, failed bool) {
+	if te.auditLog == nil {
+		return
+	}
+	rec := audit.Record{
+		Tool:      call.Name,
+		Input:     call.Input,
+		Decision:  decision,
+		DecidedBy: string(verdict.Source),
+		Rule:      verdict.Rule,
+		Error:     failed,
+	}
+	if decision == audit.DecisionAllow {
+		rec.ResultHash = audit.HashResult(result)
+	}
+	if te.auditMeta != nil {
+		rec.SessionID, rec.Model = te.auditMeta()
+	}
+	if err := te.auditLog.Append(rec); err != nil {
+		if log := logging.Global(); log != nil {
+			log.Warn("failed to write audit record", logging.Error(err))
+		}
 	}
 }
 
@@ -257,7 +440,7 @@ func (te *ToolExecutor) executeParallel(ctx context.Context, calls []llm.ToolCal
 	for _, call := range calls {
 		description := formatToolDescription(call.Name, call.Input)
 		debug.ToolCall(call.Name, call.Input)
-		output.ToolCall(call.Name, description)
+		showToolCall(output, call, description)
 	}
 
 	// Run all tools concurrently
@@ -271,19 +454,22 @@ func (te *ToolExecutor) executeParallel(ctx context.Context, calls []llm.ToolCal
 
 	// Show results in order and apply caching
 	for i, r := range results {
+		if te.auditLog != nil {
+			var verdict permissions.Verdict
+			if tool, ok := te.tools.Get(r.Name); ok {
+				verdict = te.permissions.Evaluate(r.Name, tool.Permission(), calls[i].Input)
+			}
+			te.audit(calls[i], audit.DecisionAllow, verdict, r.Result, r.Error)
+		}
 		if r.Error {
 			debug.ToolResult(r.Name, false, 0)
-			output.ToolResult(r.Name, r.Result, true)
+			showToolResult(output, calls[i], r.Result, true)
 		} else {
 			debug.ToolResult(r.Name, true, len(r.Result))
-			// Truncate large tool outputs
-			results[i].Result = truncateToolOutput(r.Result)
-			displayResult := results[i].Result
-			if te.resultCache != nil && ctxmgr.ShouldCache(r.Result) {
-				summary, _ := te.resultCache.Store(r.Name, calls[i].Input, r.Result)
-				results[i].Result = summary
-			}
-			output.ToolResult(r.Name, displayResult, false)
+			// Truncate large tool outputs for display only
+			displayResult := truncateToolOutput(r.Result)
+			results[i].Result = te.contextResult(r.Name, calls[i].Input, r.Result, len(calls))
+			showToolResult(output, calls[i], displayResult, false)
 		}
 		// Set ToolCallID from original call
 		results[i].ToolCallID = calls[i].ID
@@ -292,6 +478,26 @@ func (te *ToolExecutor) executeParallel(ctx context.Context, calls []llm.ToolCal
 	return results
 }
 
+// showToolCall reports a tool call, with its ID and input when the output
+// tracks calls by ID.
+func showToolCall(output AgentOutput, call llm.ToolCall, description string) {
+	if tc, ok := output.(ToolCallSupport); ok {
+		tc.ToolCallStarted(call, description)
+		return
+	}
+	output.ToolCall(call.Name, description)
+}
+
+// showToolResult reports a tool result, matched to its call by ID when the
+// output tracks calls by ID.
+func showToolResult(output AgentOutput, call llm.ToolCall, result string, isError bool) {
+	if tc, ok := output.(ToolCallSupport); ok {
+		tc.ToolCallFinished(call, result, isError)
+		return
+	}
+	output.ToolResult(call.Name, result, isError)
+}
+
 // formatToolDescription creates a human-readable description of a tool call.
 func formatToolDescription(name string, input map[string]any) string {
 	// Helper to extract a string field with truncation
@@ -319,6 +525,13 @@ func formatToolDescription(name string, input map[string]any) string {
 		if p := getStr("path", 0); p != "" {
 			return fmt.Sprintf("Edit %s", p)
 		}
+	case "apply_patch":
+		if paths := tools.ChangedPaths(name, input); len(paths) > 0 {
+			if len(paths) > 3 {
+				return fmt.Sprintf("Patch %s and %d more", strings.Join(paths[:3], ", "), len(paths)-3)
+			}
+			return fmt.Sprintf("Patch %s", strings.Join(paths, ", "))
+		}
 	case "list_files":
 		path := "."
 		if p := getStr("path", 0); p != "" {
@@ -332,11 +545,39 @@ func formatToolDescription(name string, input map[string]any) string {
 			return fmt.Sprintf("Grep: %s", p)
 		}
 
+	// Subagents
+	case taskToolName:
+		if d := getStr("description", 60); d != "" {
+			return fmt.Sprintf("Subagent: %s", d)
+		}
+	case ctxmgr.RecallToolName:
+		if start, ok := input["start_line"].(float64); ok {
+			return fmt.Sprintf("Recall tool output from line %d", int(start))
+		}
+		return "Recall tool output"
+
 	// Execution
 	case "bash":
 		if cmd := getStr("command", 50); cmd != "" {
 			return fmt.Sprintf("Run: %s", cmd)
 		}
+	case "bash_background":
+		if cmd := getStr("command", 50); cmd != "" {
+			return fmt.Sprintf("Start job: %s", cmd)
+		}
+	case "job_output":
+		if id, ok := input["id"].(float64); ok {
+			return fmt.Sprintf("Read output of job %d", int(id))
+		}
+	case "job_status":
+		if id, ok := input["id"].(float64); ok {
+			return fmt.Sprintf("Check job %d", int(id))
+		}
+		return "Check jobs"
+	case "job_kill":
+		if id, ok := input["id"].(float64); ok {
+			return fmt.Sprintf("Kill job %d", int(id))
+		}
 
 	// Analysis tools
 	case "ast_parse":
@@ -344,8 +585,12 @@ func formatToolDescription(name string, input map[string]any) string {
 			return fmt.Sprintf("Parse AST: %s", p)
 		}
 	case "lsp_query":
+		action := getStr("action", 0)
+		if p := getStr("path", 0); p != "" {
+			return fmt.Sprintf("LSP %s: %s", action, p)
+		}
 		if q := getStr("query", 50); q != "" {
-			return fmt.Sprintf("LSP: %s", q)
+			return fmt.Sprintf("LSP %s: %s", action, q)
 		}
 	case "lint":
 		if p := getStr("path", 0); p != "" {
@@ -3,10 +3,13 @@ package agent
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/abdul-hamid-achik/vecai/internal/audit"
 	"github.com/abdul-hamid-achik/vecai/internal/llm"
 	"github.com/abdul-hamid-achik/vecai/internal/permissions"
 	"github.com/abdul-hamid-achik/vecai/internal/tools"
@@ -327,6 +330,55 @@ func TestExecuteParallel(t *testing.T) {
 	})
 }
 
+// mockPatchTool stands in for apply_patch, overwriting each target with its
+// name so tests can check the checkpoint captured the originals.
+type mockPatchTool struct{ targets []string }
+
+func (t *mockPatchTool) Name() string                      { return "apply_patch" }
+func (t *mockPatchTool) Description() string               { return "mock patch tool" }
+func (t *mockPatchTool) InputSchema() map[string]any       { return nil }
+func (t *mockPatchTool) Permission() tools.PermissionLevel { return tools.PermissionWrite }
+func (t *mockPatchTool) Execute(_ context.Context, _ map[string]any) (string, error) {
+	for _, path := range t.targets {
+		if err := os.WriteFile(path, []byte("patched"), 0644); err != nil {
+			return "", err
+		}
+	}
+	return "patched", nil
+}
+
+func TestExecuteToolCalls_ApplyPatchCheckpointsEveryFile(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "a.go")
+	created := filepath.Join(dir, "b.go")
+	if err := os.WriteFile(existing, []byte("original"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	te := newTestToolExecutor(newMockRegistry(&mockPatchTool{targets: []string{existing, created}}), permissions.ModeAuto)
+	te.checkpointMgr = NewCheckpointManager()
+	te.checkpointMgr.StartCheckpoint("refactor")
+
+	patch := fmt.Sprintf("--- %s\n+++ %s\n@@ -1 +1 @@\n-original\n+patched\n--- /dev/null\n+++ %s\n@@ -0,0 +1 @@\n+patched\n", existing, existing, created)
+	calls := []llm.ToolCall{{ID: "c1", Name: "apply_patch", Input: map[string]any{"patch": patch}}}
+	results := te.ExecuteToolCalls(context.Background(), calls, &mockOutput{}, &mockInput{})
+	if len(results) != 1 || results[0].Error {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	te.checkpointMgr.CommitCheckpoint()
+
+	// One rewind undoes the whole patch
+	if _, err := te.checkpointMgr.Rewind(1); err != nil {
+		t.Fatalf("rewind failed: %v", err)
+	}
+	if data, _ := os.ReadFile(existing); string(data) != "original" {
+		t.Errorf("expected a.go restored, got %q", data)
+	}
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Errorf("expected b.go removed by rewind, stat err = %v", err)
+	}
+}
+
 // --- Helper: create a registry with only the given tools ---
 
 func newMockRegistry(mockTools ...tools.Tool) *tools.Registry {
@@ -336,3 +388,47 @@ func newMockRegistry(mockTools ...tools.Tool) *tools.Registry {
 	}
 	return r
 }
+
+func TestExecuteToolCalls_AuditsEveryCall(t *testing.T) {
+	registry := newMockRegistry(&mockReadTool{name: "reader"}, &mockWriteTool{name: "writer"})
+	te := NewToolExecutor(registry, permissions.NewPolicy(permissions.ModeAsk, nil, nil), nil, false)
+	dir := t.TempDir()
+	te.auditLog = audit.Open(dir)
+	te.auditMeta = func() (string, string) { return "sess-1", "qwen3:8b" }
+	t.Cleanup(func() { _ = te.auditLog.Close() })
+
+	calls := []llm.ToolCall{
+		{ID: "c1", Name: "reader", Input: map[string]any{"path": "a.go"}},
+		{ID: "c2", Name: "writer", Input: map[string]any{"path": "b.go"}},
+		{ID: "c3", Name: "missing", Input: map[string]any{}},
+	}
+	te.ExecuteToolCalls(context.Background(), calls, &mockOutput{}, &mockInput{response: "n"})
+
+	recs, err := audit.Read(dir, audit.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 3 {
+		t.Fatalf("expected 3 audit records, got %d", len(recs))
+	}
+	want := []struct{ tool, decision, by string }{
+		{"reader", audit.DecisionAllow, "auto"},
+		{"writer", audit.DecisionDeny, "user"},
+		{"missing", audit.DecisionInvalid, ""},
+	}
+	for i, w := range want {
+		r := recs[i]
+		if r.Tool != w.tool || r.Decision != w.decision || r.DecidedBy != w.by {
+			t.Errorf("record %d = %s/%s/%s, want %s/%s/%s", i, r.Tool, r.Decision, r.DecidedBy, w.tool, w.decision, w.by)
+		}
+		if r.SessionID != "sess-1" || r.Model != "qwen3:8b" {
+			t.Errorf("record %d missing session/model: %+v", i, r)
+		}
+	}
+	if recs[0].ResultHash != audit.HashResult("read-result") {
+		t.Errorf("expected hash of the read result, got %q", recs[0].ResultHash)
+	}
+	if n, err := audit.Verify(dir); err != nil || n != 3 {
+		t.Errorf("Verify = %d, %v", n, err)
+	}
+}
@@ -0,0 +1,146 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/abdul-hamid-achik/vecai/internal/logging"
+	"github.com/abdul-hamid-achik/vecai/internal/tui"
+	"github.com/abdul-hamid-achik/vecai/internal/worktree"
+)
+
+// maxWorktreeDiffLines caps the combined diff shown after each task; the full
+// diff is available with /worktree diff.
+const maxWorktreeDiffLines = 200
+
+// enterWorktree moves tool execution into a scratch git worktree for Build
+// mode tasks when isolation is enabled. Follow-up tasks reuse the active
+// worktree until it is merged, squashed or discarded. Returns true if the
+// task runs in a worktree.
+func (a *Agent) enterWorktree(output AgentOutput) bool {
+	if a.worktree != nil {
+		return true
+	}
+	if !a.config.Agent.WorktreeIsolation || a.agentMode != tui.ModeBuild || a.tools == nil {
+		return false
+	}
+
+	wd, err := os.Getwd()
+	if err != nil || !worktree.IsRepo(wd) {
+		output.Warning("Worktree isolation needs a git repository; running in the working tree")
+		return false
+	}
+	wt, err := worktree.Create(wd)
+	if err != nil {
+		output.Warning("Failed to create worktree, running in the working tree: " + err.Error())
+		return false
+	}
+	if err := a.tools.SetWorkspace(wt.WorkDir()); err != nil {
+		_ = wt.Discard()
+		output.Warning("Failed to switch tools to the worktree: " + err.Error())
+		return false
+	}
+
+	a.worktree = wt
+	a.worktreeTask = a.currentQuery
+	output.Info(fmt.Sprintf("Running in isolated worktree on branch %s", wt.Branch))
+	if wt.Dirty() {
+		output.Warning("The worktree starts from HEAD; uncommitted changes in your working tree are not included")
+	}
+	return true
+}
+
+// reportWorktree shows the combined diff of the active worktree and how to resolve it.
+func (a *Agent) reportWorktree(output AgentOutput) {
+	if a.worktree == nil {
+		return
+	}
+	diff, err := a.worktree.Diff()
+	if err != nil {
+		output.Warning("Failed to diff worktree: " + err.Error())
+		return
+	}
+	if diff == "" {
+		output.Info(fmt.Sprintf("No changes in worktree %s yet", a.worktree.Branch))
+		return
+	}
+
+	lines := strings.Split(diff, "\n")
+	if len(lines) > maxWorktreeDiffLines {
+		diff = strings.Join(lines[:maxWorktreeDiffLines], "\n") +
+			fmt.Sprintf("\n... (%d more lines, /worktree diff for all)", len(lines)-maxWorktreeDiffLines)
+	}
+	output.Info(fmt.Sprintf("Changes on %s:\n%s", a.worktree.Branch, diff))
+	output.Info("Apply with /worktree merge or /worktree squash, or /worktree discard")
+}
+
+// resolveWorktree merges, squash-applies or discards the active worktree and
+// points the tools back at the project root.
+func (a *Agent) resolveWorktree(action string) (string, error) {
+	wt := a.worktree
+	if wt == nil {
+		return "", fmt.Errorf("no active worktree")
+	}
+
+	var err error
+	var done string
+	switch action {
+	case "merge":
+		err = wt.Merge(a.worktreeCommitMessage())
+		done = fmt.Sprintf("Merged %s into the working tree", wt.Branch)
+	case "squash":
+		err = wt.SquashApply(a.worktreeCommitMessage())
+		done = "Applied worktree changes to the working tree (staged, not committed)"
+	case "discard":
+		err = wt.Discard()
+		done = fmt.Sprintf("Discarded worktree %s", wt.Branch)
+	default:
+		return "", fmt.Errorf("unknown worktree action %q", action)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	a.worktree = nil
+	a.worktreeTask = ""
+	if err := a.tools.SetWorkspace(""); err != nil {
+		return done, fmt.Errorf("failed to restore project root: %w", err)
+	}
+	return done, nil
+}
+
+// closeWorktree keeps any unresolved worktree changes on their scratch branch
+// when the agent shuts down, so nothing is lost.
+func (a *Agent) closeWorktree() {
+	wt := a.worktree
+	if wt == nil {
+		return
+	}
+	a.worktree = nil
+	kept, err := wt.Keep(a.worktreeCommitMessage())
+	if err != nil {
+		if log := logging.Global(); log != nil {
+			log.Warn("failed to close worktree", logging.Error(err))
+		}
+		fmt.Fprintf(os.Stderr, "Warning: worktree left at %s: %v\n", wt.Dir, err)
+		return
+	}
+	if kept {
+		fmt.Fprintf(os.Stderr, "Task changes kept on branch %s (git merge %s to apply)\n", wt.Branch, wt.Branch)
+	}
+	if a.tools != nil {
+		_ = a.tools.SetWorkspace("")
+	}
+}
+
+func (a *Agent) worktreeCommitMessage() string {
+	task := strings.Join(strings.Fields(a.worktreeTask), " ")
+	if len(task) > 60 {
+		task = task[:57] + "..."
+	}
+	if task == "" {
+		return "vecai task"
+	}
+	return "vecai: " + task
+}
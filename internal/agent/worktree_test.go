@@ -0,0 +1,84 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/abdul-hamid-achik/vecai/internal/tui"
+)
+
+// initTestRepo creates a throwaway git repository with one commit and makes
+// it the working directory.
+func initTestRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"config", "user.email", "test@example.com"},
+		{"config", "user.name", "Test"},
+		{"config", "commit.gpgsign", "false"},
+		{"add", "-A"},
+		{"commit", "-q", "-m", "initial"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	t.Chdir(dir)
+	return dir
+}
+
+func TestWorktreeIsolation_WritesLandInWorktreeUntilMerged(t *testing.T) {
+	repo := initTestRepo(t)
+	a, _ := newTestAgent(t)
+	a.config.Agent.WorktreeIsolation = true
+	a.currentQuery = "add a helper"
+	out := &mockOutput{}
+
+	// Only Build mode tasks are isolated
+	if a.enterWorktree(out) {
+		t.Fatal("expected no worktree outside Build mode")
+	}
+	a.agentMode = tui.ModeBuild
+	if !a.enterWorktree(out) {
+		t.Fatal("expected a worktree in Build mode")
+	}
+	t.Cleanup(func() { a.closeWorktree() })
+
+	ctx := context.Background()
+	if _, err := a.tools.Execute(ctx, "write_file", map[string]any{"path": "helper.go", "content": "package main\n\nfunc helper() {}\n"}); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(repo, "helper.go")); !os.IsNotExist(err) {
+		t.Fatal("write reached the working tree before merge")
+	}
+	diff, err := a.worktree.Diff()
+	if err != nil || !strings.Contains(diff, "+func helper() {}") {
+		t.Fatalf("unexpected worktree diff %q, %v", diff, err)
+	}
+
+	if _, err := a.resolveWorktree("merge"); err != nil {
+		t.Fatalf("merge failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(repo, "helper.go")); err != nil {
+		t.Errorf("expected helper.go merged into the working tree: %v", err)
+	}
+	if a.worktree != nil {
+		t.Error("expected the worktree to be cleared after merge")
+	}
+}
@@ -0,0 +1,356 @@
+// Package audit keeps an append-only, hash-chained JSONL trail of tool
+// executions and the permission decisions behind them.
+//
+// Each process appends to its own file under the audit directory, so
+// concurrent vecai instances never interleave writes. Every record carries
+// the hash of the record before it; the first record of a file links to the
+// last record written before the file was opened. Editing, reordering or
+// removing records (or whole files) breaks the chain, which Verify reports.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultDir is where audit files live, relative to the project root.
+const DefaultDir = ".vecai/audit"
+
+// Decisions recorded for a tool call.
+const (
+	DecisionAllow     = "allow"     // Permission granted and the tool ran
+	DecisionDeny      = "deny"      // Permission refused
+	DecisionError     = "error"     // Permission could not be obtained
+	DecisionInvalid   = "invalid"   // Unknown tool or unparseable arguments
+	DecisionCancelled = "cancelled" // Interrupted before the call was reached
+)
+
+// Record is one audited tool call.
+type Record struct {
+	Seq        int            `json:"seq"`
+	Time       time.Time      `json:"ts"`
+	SessionID  string         `json:"session_id,omitempty"`
+	Model      string         `json:"model,omitempty"`
+	Tool       string         `json:"tool"`
+	Input      map[string]any `json:"input,omitempty"`
+	Decision   string         `json:"decision"`
+	DecidedBy  string         `json:"decided_by,omitempty"` // auto, rule, cached, user, cmd or hook
+	Rule       string         `json:"rule,omitempty"`       // Matching permission rule, if any
+	ResultHash string         `json:"result_sha256,omitempty"`
+	Error      bool           `json:"error,omitempty"` // The tool ran and failed
+	Prev       string         `json:"prev"`
+	Hash       string         `json:"hash"`
+}
+
+// HashResult returns the hex SHA-256 of a tool result.
+func HashResult(result string) string {
+	sum := sha256.Sum256([]byte(result))
+	return hex.EncodeToString(sum[:])
+}
+
+// computeHash hashes the record with its Hash field cleared.
+func (r Record) computeHash() (string, error) {
+	r.Hash = ""
+	data, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Log appends records for one process. The file is created on first append.
+type Log struct {
+	mu   sync.Mutex
+	dir  string
+	file *os.File
+	seq  int
+	prev string
+	now  func() time.Time
+}
+
+// Open returns a log writing under dir.
+func Open(dir string) *Log {
+	return &Log{dir: dir, now: time.Now}
+}
+
+// Append stamps, chains and writes a record.
+func (l *Log) Append(rec Record) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		if err := l.create(); err != nil {
+			return err
+		}
+	}
+
+	l.seq++
+	rec.Seq = l.seq
+	if rec.Time.IsZero() {
+		rec.Time = l.now()
+	}
+	rec.Time = rec.Time.UTC()
+	rec.Prev = l.prev
+	hash, err := rec.computeHash()
+	if err != nil {
+		l.seq--
+		return fmt.Errorf("failed to hash audit record: %w", err)
+	}
+	rec.Hash = hash
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		l.seq--
+		return fmt.Errorf("failed to encode audit record: %w", err)
+	}
+	if _, err := l.file.Write(append(data, '\n')); err != nil {
+		l.seq--
+		return fmt.Errorf("failed to write audit record: %w", err)
+	}
+	l.prev = hash
+	return nil
+}
+
+// create opens this process's file, linking it to the newest existing record.
+func (l *Log) create() error {
+	if err := os.MkdirAll(l.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create audit directory: %w", err)
+	}
+	prev, err := latestHash(l.dir)
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%d.jsonl", l.now().UTC().Format("20060102T150405.000000000Z"), os.Getpid())
+	f, err := os.OpenFile(filepath.Join(l.dir, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create audit file: %w", err)
+	}
+	l.file = f
+	l.prev = prev
+	return nil
+}
+
+// Close closes the current file.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// latestHash returns the hash of the newest record in dir, or "".
+func latestHash(dir string) (string, error) {
+	files, err := auditFiles(dir)
+	if err != nil {
+		return "", err
+	}
+	var latest Record
+	for _, path := range files {
+		recs, err := readFile(path)
+		if err != nil {
+			return "", err
+		}
+		if n := len(recs); n > 0 && !recs[n-1].Time.Before(latest.Time) {
+			latest = recs[n-1]
+		}
+	}
+	return latest.Hash, nil
+}
+
+// auditFiles lists the audit files in dir in name (creation) order.
+func auditFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit directory: %w", err)
+	}
+	var files []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".jsonl") {
+			files = append(files, filepath.Join(dir, e.Name()))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// readFile decodes one audit file. Numbers in inputs are kept verbatim so
+// records re-hash to the same value.
+func readFile(path string) ([]Record, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit file: %w", err)
+	}
+	var recs []Record
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		dec := json.NewDecoder(bytes.NewReader(scanner.Bytes()))
+		dec.UseNumber()
+		var rec Record
+		if err := dec.Decode(&rec); err != nil {
+			return nil, fmt.Errorf("%s:%d: malformed record: %w", filepath.Base(path), line, err)
+		}
+		recs = append(recs, rec)
+	}
+	return recs, scanner.Err()
+}
+
+// Filter selects records. Zero fields match everything.
+type Filter struct {
+	SessionID string // Exact ID or prefix
+	Tool      string
+	Since     time.Time
+	Until     time.Time
+}
+
+// Match reports whether rec passes the filter.
+func (f Filter) Match(rec Record) bool {
+	if f.SessionID != "" && !strings.HasPrefix(rec.SessionID, f.SessionID) {
+		return false
+	}
+	if f.Tool != "" && rec.Tool != f.Tool {
+		return false
+	}
+	if !f.Since.IsZero() && rec.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && rec.Time.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+// Read returns the records in dir matching the filter, oldest first.
+func Read(dir string, filter Filter) ([]Record, error) {
+	files, err := auditFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	var out []Record
+	for _, path := range files {
+		recs, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, rec := range recs {
+			if filter.Match(rec) {
+				out = append(out, rec)
+			}
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Time.Before(out[j].Time) })
+	return out, nil
+}
+
+// Verify checks every record's hash and chain links in dir. It returns the
+// number of records checked and an error describing the first break found.
+func Verify(dir string) (int, error) {
+	files, err := auditFiles(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	all := make(map[string][]Record, len(files))
+	known := make(map[string]bool)
+	for _, path := range files {
+		recs, err := readFile(path)
+		if err != nil {
+			return 0, err
+		}
+		all[path] = recs
+		for _, rec := range recs {
+			known[rec.Hash] = true
+		}
+	}
+
+	count := 0
+	for _, path := range files {
+		name := filepath.Base(path)
+		prev := ""
+		for i, rec := range all[path] {
+			where := fmt.Sprintf("%s record %d", name, i+1)
+			if rec.Seq != i+1 {
+				return count, fmt.Errorf("%s: sequence %d, want %d (record removed or reordered)", where, rec.Seq, i+1)
+			}
+			hash, err := rec.computeHash()
+			if err != nil {
+				return count, fmt.Errorf("%s: %w", where, err)
+			}
+			if hash != rec.Hash {
+				return count, fmt.Errorf("%s: hash mismatch (record modified)", where)
+			}
+			if i == 0 {
+				if rec.Prev != "" && !known[rec.Prev] {
+					return count, fmt.Errorf("%s: links to missing record %.12s (earlier records removed)", where, rec.Prev)
+				}
+			} else if rec.Prev != prev {
+				return count, fmt.Errorf("%s: chain broken (record removed or reordered)", where)
+			}
+			prev = rec.Hash
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Summary aggregates records.
+type Summary struct {
+	Total      int            `json:"total"`
+	First      time.Time      `json:"first"`
+	Last       time.Time      `json:"last"`
+	Sessions   map[string]int `json:"sessions"`
+	Tools      map[string]int `json:"tools"`
+	Decisions  map[string]int `json:"decisions"`
+	DecidedBy  map[string]int `json:"decided_by"`
+	ToolErrors int            `json:"tool_errors"`
+}
+
+// Summarize aggregates records.
+func Summarize(recs []Record) Summary {
+	s := Summary{
+		Sessions:  make(map[string]int),
+		Tools:     make(map[string]int),
+		Decisions: make(map[string]int),
+		DecidedBy: make(map[string]int),
+	}
+	for _, rec := range recs {
+		s.Total++
+		if s.First.IsZero() || rec.Time.Before(s.First) {
+			s.First = rec.Time
+		}
+		if rec.Time.After(s.Last) {
+			s.Last = rec.Time
+		}
+		s.Sessions[rec.SessionID]++
+		s.Tools[rec.Tool]++
+		s.Decisions[rec.Decision]++
+		if rec.DecidedBy != "" {
+			s.DecidedBy[rec.DecidedBy]++
+		}
+		if rec.Error {
+			s.ToolErrors++
+		}
+	}
+	return s
+}
@@ -0,0 +1,149 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeRecords appends one record per tool through a fresh log, as one
+// vecai process would.
+func writeRecords(t *testing.T, dir string, start time.Time, session string, tools ...string) {
+	t.Helper()
+	l := Open(dir)
+	now := start
+	l.now = func() time.Time { now = now.Add(time.Second); return now }
+	for _, tool := range tools {
+		err := l.Append(Record{
+			SessionID:  session,
+			Model:      "qwen3:8b",
+			Tool:       tool,
+			Input:      map[string]any{"path": "main.go", "limit": 12345678901234567.0, "nested": map[string]any{"n": 1.5}},
+			Decision:   DecisionAllow,
+			DecidedBy:  "auto",
+			ResultHash: HashResult("ok"),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func auditFile(t *testing.T, dir string, i int) string {
+	t.Helper()
+	files, err := auditFiles(dir)
+	if err != nil || len(files) <= i {
+		t.Fatalf("expected audit file %d, got %v, %v", i, files, err)
+	}
+	return files[i]
+}
+
+func TestLog_ChainsAcrossProcesses(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	writeRecords(t, dir, base, "s1", "read_file", "bash")
+	writeRecords(t, dir, base.Add(time.Hour), "s2", "write_file")
+
+	n, err := Verify(dir)
+	if err != nil || n != 3 {
+		t.Fatalf("Verify = %d, %v", n, err)
+	}
+	recs, err := Read(dir, Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recs[2].Seq != 1 || recs[2].Prev != recs[1].Hash {
+		t.Errorf("second file should link to the last record of the first: %+v", recs[2])
+	}
+}
+
+func TestVerify_DetectsTampering(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(t *testing.T, dir string)
+		want   string
+	}{
+		{"edited record", func(t *testing.T, dir string) {
+			path := auditFile(t, dir, 0)
+			data, _ := os.ReadFile(path)
+			data = []byte(strings.Replace(string(data), `"decision":"allow"`, `"decision":"deny"`, 1))
+			if err := os.WriteFile(path, data, 0600); err != nil {
+				t.Fatal(err)
+			}
+		}, "hash mismatch"},
+		{"removed record", func(t *testing.T, dir string) {
+			path := auditFile(t, dir, 0)
+			data, _ := os.ReadFile(path)
+			lines := strings.SplitAfter(string(data), "\n")
+			if err := os.WriteFile(path, []byte(lines[0]+lines[2]), 0600); err != nil {
+				t.Fatal(err)
+			}
+		}, "sequence"},
+		{"removed file", func(t *testing.T, dir string) {
+			if err := os.Remove(auditFile(t, dir, 0)); err != nil {
+				t.Fatal(err)
+			}
+		}, "missing record"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			base := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+			writeRecords(t, dir, base, "s1", "read_file", "bash", "edit_file")
+			writeRecords(t, dir, base.Add(time.Hour), "s2", "write_file")
+
+			tt.tamper(t, dir)
+			if _, err := Verify(dir); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Verify error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRead_FiltersAndSummary(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	writeRecords(t, dir, base, "session-aaa", "read_file", "bash")
+	writeRecords(t, dir, base.Add(time.Hour), "session-bbb", "bash")
+
+	recs, err := Read(dir, Filter{Tool: "bash"})
+	if err != nil || len(recs) != 2 {
+		t.Fatalf("tool filter = %d records, %v", len(recs), err)
+	}
+	recs, _ = Read(dir, Filter{SessionID: "session-a"})
+	if len(recs) != 2 {
+		t.Errorf("session prefix filter = %d records, want 2", len(recs))
+	}
+	recs, _ = Read(dir, Filter{Since: base.Add(30 * time.Minute)})
+	if len(recs) != 1 || recs[0].SessionID != "session-bbb" {
+		t.Errorf("since filter = %+v", recs)
+	}
+
+	all, _ := Read(dir, Filter{})
+	s := Summarize(all)
+	if s.Total != 3 || s.Tools["bash"] != 2 || len(s.Sessions) != 2 || s.DecidedBy["auto"] != 3 {
+		t.Errorf("unexpected summary %+v", s)
+	}
+	if !s.First.Equal(all[0].Time) || !s.Last.Equal(all[2].Time) || !s.Last.After(base.Add(time.Hour)) {
+		t.Errorf("unexpected span %v - %v", s.First, s.Last)
+	}
+}
+
+func TestOpen_NoFileUntilFirstAppend(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "audit")
+	l := Open(dir)
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("expected no audit directory without records, stat err = %v", err)
+	}
+	if n, err := Verify(dir); err != nil || n != 0 {
+		t.Errorf("Verify of missing dir = %d, %v", n, err)
+	}
+}
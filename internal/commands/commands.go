@@ -0,0 +1,342 @@
+// Package commands loads user-defined slash commands from markdown files.
+//
+// A command file is a prompt template with optional YAML frontmatter:
+//
+//	---
+//	description: Review a file for bugs
+//	argument-hint: <file>
+//	allowed-tools: [read_file, grep]
+//	model: smart
+//	mode: ask
+//	---
+//	Review @$1 for bugs. Recent changes:
+//	!`git log --oneline -5 -- $1`
+//
+// The file name, without .md, is the command name.
+package commands
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/abdul-hamid-achik/vecai/internal/config"
+)
+
+// ProjectDir holds the project's commands, relative to the project root.
+const ProjectDir = ".vecai/commands"
+
+// shellTimeout bounds each !`command` in a template.
+const shellTimeout = 30 * time.Second
+
+// maxShellOutput bounds the output of each !`command` in a template.
+const maxShellOutput = 10000
+
+var (
+	namePattern  = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+	shellPattern = regexp.MustCompile("!`([^`\n]+)`")
+	argPattern   = regexp.MustCompile(`\$(ARGUMENTS|[1-9])`)
+	filePattern  = regexp.MustCompile(`(^|[\s(])@([^\s@()]+)`)
+)
+
+// ToolList is a list of tool names, written in YAML as a list or a
+// comma-separated string.
+type ToolList []string
+
+// UnmarshalYAML accepts both forms.
+func (l *ToolList) UnmarshalYAML(node *yaml.Node) error {
+	var list []string
+	if node.Kind == yaml.ScalarNode {
+		for _, name := range strings.Split(node.Value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				list = append(list, name)
+			}
+		}
+	} else if err := node.Decode(&list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// Command is a user-defined slash command.
+type Command struct {
+	Name         string           `yaml:"-"`
+	Description  string           `yaml:"description"`
+	ArgumentHint string           `yaml:"argument-hint"` // e.g. "<file> [focus]"
+	AllowedTools ToolList         `yaml:"allowed-tools"` // Tool name globs the model may use; empty allows all
+	Model        config.ModelTier `yaml:"model"`         // fast, smart or genius; empty picks automatically
+	Mode         string           `yaml:"mode"`          // ask, plan or build; empty keeps the current mode
+	Template     string           `yaml:"-"`             // The markdown body
+	Path         string           `yaml:"-"`
+}
+
+// AllowsTool reports whether the command lets the model use a tool.
+func (c *Command) AllowsTool(name string) bool {
+	if len(c.AllowedTools) == 0 {
+		return true
+	}
+	for _, pattern := range c.AllowedTools {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Expand renders the prompt for a command line's arguments. $ARGUMENTS
+// becomes the whole argument string and $1 to $9 the individual arguments;
+// when the template uses neither, the arguments are appended. Each
+// !`command` runs through sh, with the arguments as its positional
+// parameters, and is replaced by its output. Files mentioned as @path
+// outside command output are returned for the caller to attach.
+func (c *Command) Expand(ctx context.Context, args string) (prompt string, files []string, err error) {
+	argv := SplitArgs(args)
+	var b, mentions strings.Builder
+	text := func(s string) {
+		s = substituteArgs(s, args, argv)
+		b.WriteString(s)
+		mentions.WriteString(s + "\n")
+	}
+	last := 0
+	for _, loc := range shellPattern.FindAllStringSubmatchIndex(c.Template, -1) {
+		text(c.Template[last:loc[0]])
+		out, err := runShell(ctx, c.Template[loc[2]:loc[3]], args, argv)
+		if err != nil {
+			return "", nil, err
+		}
+		b.WriteString(out)
+		last = loc[1]
+	}
+	text(c.Template[last:])
+
+	prompt = strings.TrimSpace(b.String())
+	if args != "" && !argPattern.MatchString(c.Template) {
+		prompt += "\n\n" + args
+		mentions.WriteString(args)
+	}
+	return prompt, mentionedFiles(mentions.String()), nil
+}
+
+func substituteArgs(text, args string, argv []string) string {
+	return argPattern.ReplaceAllStringFunc(text, func(m string) string {
+		if m == "$ARGUMENTS" {
+			return args
+		}
+		if i := int(m[1] - '1'); i < len(argv) {
+			return argv[i]
+		}
+		return ""
+	})
+}
+
+// runShell runs one !`command` and returns its output. A failing command
+// is reported in the output rather than failing the expansion.
+func runShell(parent context.Context, command, args string, argv []string) (string, error) {
+	ctx, cancel := context.WithTimeout(parent, shellTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", append([]string{"-c", command, "vecai"}, argv...)...)
+	cmd.Env = append(os.Environ(), "ARGUMENTS="+args)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	cmd.WaitDelay = time.Second
+
+	runErr := cmd.Run()
+	if err := parent.Err(); err != nil {
+		return "", err
+	}
+	text := strings.TrimRight(out.String(), "\n")
+	if len(text) > maxShellOutput {
+		text = text[:maxShellOutput] + "\n... (output truncated)"
+	}
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		text += fmt.Sprintf("\n(command timed out after %s)", shellTimeout)
+	case runErr != nil:
+		text += fmt.Sprintf("\n(command failed: %v)", runErr)
+	}
+	return text, nil
+}
+
+// mentionedFiles returns the existing files mentioned as @path, in order.
+func mentionedFiles(text string) []string {
+	var files []string
+	seen := make(map[string]bool)
+	for _, m := range filePattern.FindAllStringSubmatch(text, -1) {
+		p := strings.TrimRight(m[2], ".,;:!?")
+		if seen[p] {
+			continue
+		}
+		if info, err := os.Stat(p); err == nil && !info.IsDir() {
+			seen[p] = true
+			files = append(files, p)
+		}
+	}
+	return files
+}
+
+// SplitArgs splits a command line into arguments. Single or double quotes
+// group words.
+func SplitArgs(s string) []string {
+	var args []string
+	var cur strings.Builder
+	var quote rune
+	inArg := false
+	for _, r := range s {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			cur.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args
+}
+
+// Loader holds the commands found in the command directories. A nil Loader
+// has no commands.
+type Loader struct {
+	commands map[string]*Command
+}
+
+// NewLoader loads commands from the project's .vecai/commands and the
+// user's ~/.config/vecai/commands. Project commands win over user commands
+// with the same name. Files that fail to load are reported on stderr.
+func NewLoader() *Loader {
+	dirs := []string{ProjectDir}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".config", "vecai", "commands"))
+	}
+	l, errs := LoadDirs(dirs...)
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	return l
+}
+
+// LoadDirs loads the *.md files in dirs, earlier directories first.
+// Missing directories are skipped.
+func LoadDirs(dirs ...string) (*Loader, []error) {
+	l := &Loader{commands: make(map[string]*Command)}
+	var errs []error
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name, ok := strings.CutSuffix(entry.Name(), ".md")
+			if entry.IsDir() || !ok || l.commands[name] != nil {
+				continue
+			}
+			p := filepath.Join(dir, entry.Name())
+			cmd, err := load(p, name)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to load command %s: %w", p, err))
+				continue
+			}
+			l.commands[name] = cmd
+		}
+	}
+	return l, errs
+}
+
+// load reads one command file.
+func load(p, name string) (*Command, error) {
+	if !namePattern.MatchString(name) {
+		return nil, fmt.Errorf("invalid command name %q (use letters, digits, - and _)", name)
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := &Command{Name: name, Path: p}
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	if rest, ok := strings.CutPrefix(text, "---\n"); ok {
+		front, body, found := strings.Cut("\n"+rest, "\n---")
+		if !found {
+			return nil, errors.New("unterminated frontmatter")
+		}
+		if err := yaml.Unmarshal([]byte(front), cmd); err != nil {
+			return nil, fmt.Errorf("invalid frontmatter: %w", err)
+		}
+		text = body
+		if i := strings.IndexByte(text, '\n'); i >= 0 {
+			text = text[i+1:]
+		} else {
+			text = ""
+		}
+	}
+	cmd.Template = strings.TrimSpace(text)
+	if cmd.Template == "" {
+		return nil, errors.New("empty prompt")
+	}
+
+	switch cmd.Model {
+	case "", config.TierFast, config.TierSmart, config.TierGenius:
+	default:
+		return nil, fmt.Errorf("unknown model %q (use fast, smart or genius)", cmd.Model)
+	}
+	cmd.Mode = strings.ToLower(cmd.Mode)
+	switch cmd.Mode {
+	case "", "ask", "plan", "build":
+	default:
+		return nil, fmt.Errorf("unknown mode %q (use ask, plan or build)", cmd.Mode)
+	}
+	for _, pattern := range cmd.AllowedTools {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid allowed-tools pattern %q: %w", pattern, err)
+		}
+	}
+	return cmd, nil
+}
+
+// Get returns the command with the given name, without the leading "/".
+func (l *Loader) Get(name string) (*Command, bool) {
+	if l == nil {
+		return nil, false
+	}
+	cmd, ok := l.commands[name]
+	return cmd, ok
+}
+
+// List returns the commands sorted by name.
+func (l *Loader) List() []*Command {
+	if l == nil {
+		return nil
+	}
+	list := make([]*Command, 0, len(l.commands))
+	for _, cmd := range l.commands {
+		list = append(list, cmd)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
@@ -0,0 +1,133 @@
+package commands
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/abdul-hamid-achik/vecai/internal/config"
+)
+
+func writeCommand(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadDirs(t *testing.T) {
+	project := filepath.Join(t.TempDir(), "project")
+	user := filepath.Join(t.TempDir(), "user")
+	writeCommand(t, project, "review.md", `---
+description: Review a file
+argument-hint: <file>
+allowed-tools: read_file, grep
+model: smart
+mode: Ask
+---
+Review $1.
+`)
+	writeCommand(t, project, "plain.md", "Summarize the repo.\n")
+	writeCommand(t, project, "notes.txt", "not a command")
+	writeCommand(t, project, "bad-model.md", "---\nmodel: huge\n---\nhi\n")
+	writeCommand(t, project, "empty.md", "---\ndescription: nothing\n---\n")
+	writeCommand(t, user, "review.md", "User review.\n")
+	writeCommand(t, user, "tests.md", "---\nallowed-tools: [test_run, \"mcp__ci__*\"]\n---\nRun the tests.\n")
+
+	l, errs := LoadDirs(project, user, filepath.Join(t.TempDir(), "missing"))
+	if len(errs) != 2 {
+		t.Errorf("expected two broken commands, got %v", errs)
+	}
+
+	var names []string
+	for _, cmd := range l.List() {
+		names = append(names, cmd.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"plain", "review", "tests"}) {
+		t.Errorf("unexpected commands %v", names)
+	}
+
+	review, _ := l.Get("review")
+	if review.Description != "Review a file" || review.ArgumentHint != "<file>" || review.Model != config.TierSmart || review.Mode != "ask" || review.Template != "Review $1." {
+		t.Errorf("expected the project command with its frontmatter, got %+v", review)
+	}
+	if !review.AllowsTool("grep") || review.AllowsTool("bash") {
+		t.Errorf("unexpected allowed tools %v", review.AllowedTools)
+	}
+	tests, _ := l.Get("tests")
+	if !tests.AllowsTool("mcp__ci__run") || tests.AllowsTool("write_file") {
+		t.Errorf("unexpected allowed tools %v", tests.AllowedTools)
+	}
+	plain, _ := l.Get("plain")
+	if plain.Template != "Summarize the repo." || !plain.AllowsTool("bash") {
+		t.Errorf("unexpected command without frontmatter %+v", plain)
+	}
+
+	var nilLoader *Loader
+	if _, ok := nilLoader.Get("review"); ok || nilLoader.List() != nil {
+		t.Error("expected a nil loader to have no commands")
+	}
+}
+
+func TestExpand(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if err := os.WriteFile("main.go", []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		args     string
+		want     string
+		files    []string
+	}{
+		{"arguments", "Fix $ARGUMENTS now", "the login bug", "Fix the login bug now", nil},
+		{"positional", "Compare $1 with $2, ignore $3", `"old name" new`, "Compare old name with new, ignore", nil},
+		{"appended", "Explain this", "main.go", "Explain this\n\nmain.go", nil},
+		{"file", "Review @$1.", "main.go", "Review @main.go.", []string{"main.go"}},
+		{"missing file", "Review @nope.go and mail me@example.com", "", "Review @nope.go and mail me@example.com", nil},
+		{"shell", "Branch: !`echo \"$1-$ARGUMENTS\"`", "a b", "Branch: a-a b", nil},
+		{"shell output is literal", "!`echo '$1 @main.go'`", "x", "$1 @main.go", nil},
+		{"shell failure", "Status: !`echo oops; exit 3`", "", "Status: oops\n(command failed: exit status 3)", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := &Command{Name: "test", Template: tt.template}
+			got, files, err := cmd.Expand(context.Background(), tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if !reflect.DeepEqual(files, tt.files) {
+				t.Errorf("got files %v, want %v", files, tt.files)
+			}
+		})
+	}
+}
+
+func TestExpand_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cmd := &Command{Name: "test", Template: "!`echo hi`"}
+	if _, _, err := cmd.Expand(ctx, ""); err == nil || !strings.Contains(err.Error(), "canceled") {
+		t.Errorf("expected a cancelled expansion to fail, got %v", err)
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	got := SplitArgs(`fix  "the bug" in 'main.go' ""`)
+	want := []string{"fix", "the bug", "in", "main.go", ""}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -15,6 +15,7 @@ type Provider string
 
 const (
 	ProviderOllama Provider = "ollama"
+	ProviderOpenAI Provider = "openai" // OpenAI-compatible servers (llama.cpp, vLLM, LM Studio)
 )
 
 // ModelTier represents the model capability level
@@ -37,6 +38,16 @@ type OllamaConfig struct {
 	NumThread   int    `yaml:"num_thread"`   // Explicit num_thread override (0 = Ollama default)
 }
 
+// OpenAIConfig holds configuration for OpenAI-compatible servers
+// (/v1/chat/completions), such as llama.cpp server or vLLM.
+type OpenAIConfig struct {
+	BaseURL     string `yaml:"base_url"`     // Default: "http://localhost:8080/v1"
+	APIKey      string `yaml:"api_key"`      // Optional bearer token (falls back to OPENAI_API_KEY)
+	ModelFast   string `yaml:"model_fast"`   // Model name for the fast tier
+	ModelSmart  string `yaml:"model_smart"`  // Model name for the smart tier
+	ModelGenius string `yaml:"model_genius"` // Model name for the genius tier
+}
+
 // ModelContextWindows maps model name patterns to their maximum context window sizes.
 // These caps prevent over-allocation of VRAM by ensuring num_ctx never exceeds what
 // the model actually supports.
@@ -83,10 +94,15 @@ func (c *Config) GetContextWindowForModel(model string) int {
 
 // AgentConfig holds multi-agent configuration
 type AgentConfig struct {
-	MaxRetries          int  `yaml:"max_retries"`          // Max retries per step (default: 3)
-	MaxIterations       int  `yaml:"max_iterations"`       // Max agent loop iterations (default: 20)
-	VerificationEnabled bool `yaml:"verification_enabled"` // Enable verification agent (default: true)
+	MaxRetries          int  `yaml:"max_retries"`           // Max retries per step (default: 3)
+	MaxIterations       int  `yaml:"max_iterations"`        // Max agent loop iterations (default: 20)
+	VerificationEnabled bool `yaml:"verification_enabled"`  // Enable verification agent (default: true)
 	ArchitectEditorMode bool `yaml:"architect_editor_mode"` // Enable architect/editor split (default: true)
+	WorktreeIsolation   bool `yaml:"worktree_isolation"`    // Run Build mode tasks in a scratch git worktree (default: false)
+	AutoEscalate        bool `yaml:"auto_escalate"`         // Switch to the next tier when the loop gets stuck (default: true)
+	StuckThreshold      int  `yaml:"stuck_threshold"`       // Parse errors or repeated calls before escalating (default: 3)
+
+	SubagentMaxIterations int `yaml:"subagent_max_iterations"` // Tool loop budget of each task subagent (default: 10)
 }
 
 // ParallelConfig holds parallel tool execution configuration
@@ -100,6 +116,42 @@ type WebSearchConfig struct {
 	MinRequestDelay time.Duration `yaml:"min_request_delay"` // Min delay between requests (default: 1s)
 }
 
+// MCPServerConfig describes one Model Context Protocol server. Set Command
+// for a stdio server or URL for a streamable HTTP server.
+type MCPServerConfig struct {
+	Command    string            `yaml:"command"`    // Executable for stdio servers, e.g. "npx"
+	Args       []string          `yaml:"args"`       // Arguments, e.g. ["-y", "@modelcontextprotocol/server-github"]
+	Env        map[string]string `yaml:"env"`        // Extra environment; values expand ${VAR}
+	URL        string            `yaml:"url"`        // Streamable HTTP endpoint
+	Headers    map[string]string `yaml:"headers"`    // HTTP headers; values expand ${VAR}
+	Permission string            `yaml:"permission"` // read, write or execute for all tools (default: execute)
+	Tools      map[string]string `yaml:"tools"`      // Per-tool permission overrides
+	Timeout    time.Duration     `yaml:"timeout"`    // Per-call timeout (default: 60s)
+	Disabled   bool              `yaml:"disabled"`   // Skip this server
+}
+
+// HookConfig is one command run on an agent event. Matcher restricts tool
+// events to tool names matching a glob; alternatives are separated by "|".
+type HookConfig struct {
+	Matcher string        `yaml:"matcher"` // Tool name glob, e.g. "write_file|edit_file" (default: all tools)
+	Command string        `yaml:"command"` // Shell command; receives the event as JSON on stdin
+	Timeout time.Duration `yaml:"timeout"` // Per-run timeout (default: 60s)
+}
+
+// AuditConfig holds tool audit log configuration
+type AuditConfig struct {
+	Enabled bool   `yaml:"enabled"` // Record every tool call (default: true)
+	Dir     string `yaml:"dir"`     // Audit directory (default: ".vecai/audit")
+}
+
+// RouterConfig holds learned tier router configuration
+type RouterConfig struct {
+	Learned       bool    `yaml:"learned"`        // Record query outcomes and learn tiers from them (default: true)
+	Dir           string  `yaml:"dir"`            // Sample directory (default: "~/.config/vecai/router")
+	MinSamples    int     `yaml:"min_samples"`    // Samples needed before learned routing replaces keywords (default: 50)
+	MinConfidence float64 `yaml:"min_confidence"` // Probability a prediction needs to be used (default: 0.6)
+}
+
 // MemoryConfig holds memory layer configuration
 type MemoryConfig struct {
 	Enabled         bool   `yaml:"enabled"`              // Enable memory layer (default: true)
@@ -107,6 +159,7 @@ type MemoryConfig struct {
 	GlobalDir       string `yaml:"global_dir"`           // Global memory (default: "~/.config/vecai/memory")
 	StoreMaxEntries int    `yaml:"store_max_entries"`    // Max entries per store (default: 10000, 0 = unlimited)
 	StoreMaxDiskMB  int    `yaml:"store_max_disk_mb"`    // Max disk size per store in MB (default: 10, 0 = unlimited)
+	EmbeddingModel  string `yaml:"embedding_model"`      // Ollama embedding model for semantic search ("" = keyword only)
 }
 
 // RateLimitConfig holds rate limiting configuration (kept for backward compatibility)
@@ -125,6 +178,10 @@ type ContextConfig struct {
 	PreserveLast         int     `yaml:"preserve_last"`          // Messages to preserve during compact (default: 4)
 	EnableAutoCompact    bool    `yaml:"enable_auto_compact"`    // Enable auto-compaction (default: true)
 	ContextWindow        int     `yaml:"context_window"`         // Context window size in tokens (qwen3:8b=32K, cogito:14b=128K)
+
+	// ToolBudgets caps the tokens one result of a tool may take, by tool
+	// name, over the built-in quotas. Larger results are compressed.
+	ToolBudgets map[string]int `yaml:"tool_budgets"`
 }
 
 // AnalysisConfig holds configuration for token-efficient analysis mode
@@ -137,8 +194,20 @@ type AnalysisConfig struct {
 
 // SandboxConfig holds sandbox configuration for bash command execution
 type SandboxConfig struct {
-	Enabled  bool `yaml:"enabled"`   // Enable OS-level sandboxing (default: true)
-	AllowNet bool `yaml:"allow_net"` // Allow network access in sandbox (default: false)
+	Enabled         bool          `yaml:"enabled"`           // Enable OS-level sandboxing (default: true)
+	AllowNet        bool          `yaml:"allow_net"`         // Allow network access in sandbox (default: false)
+	ReadOnlyProject bool          `yaml:"read_only_project"` // Mount the project read-only in Ask and Plan modes (default: false)
+	Limits          SandboxLimits `yaml:"limits"`            // Resource limits for each sandboxed command
+}
+
+// SandboxLimits bounds the resources of each sandboxed command. Zero means
+// no limit. Memory and process limits use cgroup v2 through systemd-run when
+// a user systemd instance is available, and rlimits otherwise.
+type SandboxLimits struct {
+	MemoryMB     int `yaml:"memory_mb"`     // Memory (the rlimit counts address space, so keep it generous)
+	CPUSeconds   int `yaml:"cpu_seconds"`   // CPU time
+	FileSizeMB   int `yaml:"file_size_mb"`  // Largest file a command may write
+	MaxProcesses int `yaml:"max_processes"` // Processes and threads
 }
 
 // ToolsConfig holds configuration for all tools
@@ -147,6 +216,54 @@ type ToolsConfig struct {
 	Noted   NotedToolConfig   `yaml:"noted"`
 	Gpeek   GpeekToolConfig   `yaml:"gpeek"`
 	Sandbox SandboxConfig     `yaml:"sandbox"`
+	Bash    BashToolConfig    `yaml:"bash"`
+	LSP     LSPToolConfig     `yaml:"lsp"`
+}
+
+// BashToolConfig holds the command policy for the bash tool. Rules are
+// checked against every command in a script, including pipelines, subshells
+// and command substitutions.
+type BashToolConfig struct {
+	Allow []CommandRuleConfig `yaml:"allow"` // When set, only matching commands may run
+	Deny  []CommandRuleConfig `yaml:"deny"`  // Blocked on top of the built-in rules
+}
+
+// CommandRuleConfig matches a command by name and arguments. In YAML it is
+// either a mapping or a string such as "git push", the command followed by
+// its argument patterns.
+type CommandRuleConfig struct {
+	Command string   `yaml:"command"` // Executable name glob, e.g. "git" or "mkfs*"
+	Args    []string `yaml:"args"`    // Each pattern must match some argument; "|" separates alternatives
+	Reason  string   `yaml:"reason"`  // Shown when a deny rule blocks a command
+}
+
+// UnmarshalYAML accepts both forms.
+func (r *CommandRuleConfig) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		fields := strings.Fields(node.Value)
+		if len(fields) == 0 {
+			return fmt.Errorf("line %d: empty command rule", node.Line)
+		}
+		*r = CommandRuleConfig{Command: fields[0], Args: fields[1:]}
+		return nil
+	}
+	type plain CommandRuleConfig
+	return node.Decode((*plain)(r))
+}
+
+// LSPToolConfig holds language server configuration for lsp_query
+type LSPToolConfig struct {
+	// Servers are keyed by name, e.g. "typescript". gopls handles .go files
+	// unless a configured server claims that extension.
+	Servers map[string]LSPServerConfig `yaml:"servers"`
+}
+
+// LSPServerConfig describes one language server and the files routed to it
+type LSPServerConfig struct {
+	Command    string   `yaml:"command"`     // Executable, e.g. "typescript-language-server"
+	Args       []string `yaml:"args"`        // Arguments, e.g. ["--stdio"]
+	Extensions []string `yaml:"extensions"`  // File extensions, e.g. [".ts", ".tsx"]
+	LanguageID string   `yaml:"language_id"` // LSP languageId (default: inferred from extension)
 }
 
 // VecgrepToolConfig holds vecgrep-specific configuration
@@ -170,21 +287,26 @@ type GpeekToolConfig struct {
 
 // Config holds the application configuration
 type Config struct {
-	Provider    Provider        `yaml:"provider"`    // LLM provider (only "ollama" supported)
-	Ollama      OllamaConfig    `yaml:"ollama"`      // Ollama configuration
-	Agent       AgentConfig     `yaml:"agent"`       // Multi-agent configuration
-	Memory      MemoryConfig    `yaml:"memory"`      // Memory layer configuration
-	Tools       ToolsConfig     `yaml:"tools"`       // Tool-specific configuration
-	DefaultTier ModelTier       `yaml:"default_tier"`
-	MaxTokens   int             `yaml:"max_tokens"`
-	Temperature float64         `yaml:"temperature"`
-	SkillsDir   string          `yaml:"skills_dir"`
-	VecgrepPath string          `yaml:"vecgrep_path"`
-	RateLimit   RateLimitConfig `yaml:"rate_limit"` // Kept for backward compat
-	Context     ContextConfig   `yaml:"context"`
-	Analysis    AnalysisConfig  `yaml:"analysis"`
-	Parallel    ParallelConfig  `yaml:"parallel"`
-	WebSearch   WebSearchConfig `yaml:"web_search"`
+	Provider    Provider                   `yaml:"provider"`    // LLM provider: "ollama" (default) or "openai"
+	Ollama      OllamaConfig               `yaml:"ollama"`      // Ollama configuration
+	OpenAI      OpenAIConfig               `yaml:"openai"`      // OpenAI-compatible server configuration
+	Agent       AgentConfig                `yaml:"agent"`       // Multi-agent configuration
+	Memory      MemoryConfig               `yaml:"memory"`      // Memory layer configuration
+	Audit       AuditConfig                `yaml:"audit"`       // Tool audit log configuration
+	Router      RouterConfig               `yaml:"router"`      // Learned tier router configuration
+	MCPServers  map[string]MCPServerConfig `yaml:"mcp_servers"` // External MCP tool servers, keyed by name
+	Hooks       map[string][]HookConfig    `yaml:"hooks"`       // Commands run on agent events, keyed by event name
+	Tools       ToolsConfig                `yaml:"tools"`       // Tool-specific configuration
+	DefaultTier ModelTier                  `yaml:"default_tier"`
+	MaxTokens   int                        `yaml:"max_tokens"`
+	Temperature float64                    `yaml:"temperature"`
+	SkillsDir   string                     `yaml:"skills_dir"`
+	VecgrepPath string                     `yaml:"vecgrep_path"`
+	RateLimit   RateLimitConfig            `yaml:"rate_limit"` // Kept for backward compat
+	Context     ContextConfig              `yaml:"context"`
+	Analysis    AnalysisConfig             `yaml:"analysis"`
+	Parallel    ParallelConfig             `yaml:"parallel"`
+	WebSearch   WebSearchConfig            `yaml:"web_search"`
 
 	// Internal: where config was loaded from
 	configPath string
@@ -201,11 +323,18 @@ func DefaultConfig() *Config {
 			ModelGenius: "qwen2.5-coder:14b",
 			KeepAlive:   "10m",
 		},
+		OpenAI: OpenAIConfig{
+			BaseURL: "http://localhost:8080/v1",
+		},
 		Agent: AgentConfig{
 			MaxRetries:          3,
 			MaxIterations:       20,
 			VerificationEnabled: true,
 			ArchitectEditorMode: true,
+			AutoEscalate:        true,
+			StuckThreshold:      3,
+
+			SubagentMaxIterations: 10,
 		},
 		Memory: MemoryConfig{
 			Enabled:         true,
@@ -213,6 +342,17 @@ func DefaultConfig() *Config {
 			GlobalDir:       "~/.config/vecai/memory",
 			StoreMaxEntries: 10000,
 			StoreMaxDiskMB:  10,
+			EmbeddingModel:  "nomic-embed-text",
+		},
+		Audit: AuditConfig{
+			Enabled: true,
+			Dir:     ".vecai/audit",
+		},
+		Router: RouterConfig{
+			Learned:       true,
+			Dir:           "~/.config/vecai/router",
+			MinSamples:    50,
+			MinConfidence: 0.6,
 		},
 		Tools: ToolsConfig{
 			Vecgrep: VecgrepToolConfig{
@@ -309,6 +449,10 @@ func LoadWithOptions(opts LoadOptions) (*Config, error) {
 		cfg.Ollama.BaseURL = normalizeOllamaHost(hostEnv)
 	}
 
+	if cfg.OpenAI.APIKey == "" {
+		cfg.OpenAI.APIKey = os.Getenv("OPENAI_API_KEY")
+	}
+
 	// Apply CLI overrides
 	if opts.BaseURLOverride != "" {
 		cfg.Ollama.BaseURL = opts.BaseURLOverride
@@ -317,6 +461,9 @@ func LoadWithOptions(opts LoadOptions) (*Config, error) {
 		cfg.Ollama.ModelFast = opts.ModelOverride
 		cfg.Ollama.ModelSmart = opts.ModelOverride
 		cfg.Ollama.ModelGenius = opts.ModelOverride
+		cfg.OpenAI.ModelFast = opts.ModelOverride
+		cfg.OpenAI.ModelSmart = opts.ModelOverride
+		cfg.OpenAI.ModelGenius = opts.ModelOverride
 	}
 
 	return cfg, nil
@@ -366,8 +513,13 @@ func (c *Config) createDefault() error {
 	return os.WriteFile(path, []byte(content), 0644)
 }
 
-// GetModel returns the Ollama model ID for a tier
+// GetModel returns the model ID for a tier from the active provider's config.
+// For the OpenAI provider, unset tiers fall back to the next configured tier
+// so a single-model server only needs one entry.
 func (c *Config) GetModel(tier ModelTier) string {
+	if c.Provider == ProviderOpenAI {
+		return c.getOpenAIModel(tier)
+	}
 	switch tier {
 	case TierFast:
 		return c.Ollama.ModelFast
@@ -380,6 +532,25 @@ func (c *Config) GetModel(tier ModelTier) string {
 	}
 }
 
+// getOpenAIModel resolves a tier against OpenAIConfig, falling back across tiers.
+func (c *Config) getOpenAIModel(tier ModelTier) string {
+	var order []string
+	switch tier {
+	case TierFast:
+		order = []string{c.OpenAI.ModelFast, c.OpenAI.ModelSmart, c.OpenAI.ModelGenius}
+	case TierGenius:
+		order = []string{c.OpenAI.ModelGenius, c.OpenAI.ModelSmart, c.OpenAI.ModelFast}
+	default:
+		order = []string{c.OpenAI.ModelSmart, c.OpenAI.ModelGenius, c.OpenAI.ModelFast}
+	}
+	for _, m := range order {
+		if m != "" {
+			return m
+		}
+	}
+	return ""
+}
+
 // GetDefaultModel returns the model ID for the default tier
 func (c *Config) GetDefaultModel() string {
 	return c.GetModel(c.DefaultTier)
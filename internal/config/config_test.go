@@ -61,6 +61,30 @@ func TestGetModel(t *testing.T) {
 	}
 }
 
+func TestGetModel_OpenAIProvider(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Provider = ProviderOpenAI
+	cfg.OpenAI.ModelSmart = "qwen2.5-coder-7b-instruct"
+	cfg.OpenAI.ModelGenius = "qwen2.5-coder-32b-instruct"
+
+	tests := []struct {
+		tier     ModelTier
+		expected string
+	}{
+		{TierFast, "qwen2.5-coder-7b-instruct"}, // unset fast falls back to smart
+		{TierSmart, "qwen2.5-coder-7b-instruct"},
+		{TierGenius, "qwen2.5-coder-32b-instruct"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.tier), func(t *testing.T) {
+			if got := cfg.GetModel(tt.tier); got != tt.expected {
+				t.Errorf("GetModel(%s) = %s, want %s", tt.tier, got, tt.expected)
+			}
+		})
+	}
+}
+
 func TestGetDefaultModel(t *testing.T) {
 	cfg := DefaultConfig()
 	cfg.DefaultTier = TierFast
@@ -126,7 +150,17 @@ ollama:
 	}
 }
 
+// isolateConfig runs a test in empty project and home directories, so Load
+// finds no config files and writes its default into the temp directory.
+func isolateConfig(t *testing.T) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	t.Chdir(t.TempDir())
+}
+
 func TestLoadWithOllamaHostEnv(t *testing.T) {
+	isolateConfig(t)
+
 	// Set Ollama host env
 	original := os.Getenv("OLLAMA_HOST")
 	_ = os.Setenv("OLLAMA_HOST", "http://custom-ollama:11434")
@@ -149,6 +183,8 @@ func TestLoadWithOllamaHostEnv(t *testing.T) {
 }
 
 func TestLoadWithOverrides(t *testing.T) {
+	isolateConfig(t)
+
 	cfg, err := LoadWithOptions(LoadOptions{
 		BaseURLOverride: "http://override:11434",
 		ModelOverride:   "custom-model:7b",
@@ -2,6 +2,8 @@ package config
 
 import (
 	"testing"
+
+	"gopkg.in/yaml.v3"
 )
 
 func TestToolsConfigDefaults(t *testing.T) {
@@ -73,3 +75,26 @@ func TestToolsConfigStructure(t *testing.T) {
 		t.Error("failed to set Gpeek.Enabled")
 	}
 }
+
+func TestBashToolConfig_Rules(t *testing.T) {
+	var cfg ToolsConfig
+	content := `bash:
+  allow: [go, "git status"]
+  deny:
+    - command: git
+      args: ["push", "--force|-f"]
+      reason: no force pushes
+`
+	if err := yaml.Unmarshal([]byte(content), &cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	allow := cfg.Bash.Allow
+	if len(allow) != 2 || allow[0].Command != "go" || allow[1].Command != "git" || len(allow[1].Args) != 1 || allow[1].Args[0] != "status" {
+		t.Errorf("expected string rules to split into command and args, got %+v", allow)
+	}
+	deny := cfg.Bash.Deny
+	if len(deny) != 1 || deny[0].Command != "git" || len(deny[0].Args) != 2 || deny[0].Reason != "no force pushes" {
+		t.Errorf("unexpected deny rules %+v", deny)
+	}
+}
@@ -0,0 +1,135 @@
+package context
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/abdul-hamid-achik/vecai/internal/llm"
+)
+
+const (
+	// ToolResultShare is the share of the free context window one tool
+	// result may take
+	ToolResultShare = 0.25
+
+	// MinToolAllowance is the smallest allowance a tool result gets, so a
+	// nearly full context still sees something of each result
+	MinToolAllowance = 256
+
+	// DefaultToolQuota caps results of tools without their own quota
+	DefaultToolQuota = 2500
+)
+
+// DefaultToolQuotas caps the tokens one result of each tool may take,
+// however much of the context window is free. Large files and diffs get
+// more room than search hits and status output.
+var DefaultToolQuotas = map[string]int{
+	"read_file":       6000,
+	"gpeek_diff":      4000,
+	"bash":            3000,
+	"test_run":        3000,
+	"job_output":      3000,
+	"lint":            2000,
+	"grep":            2000,
+	"lsp_query":       2000,
+	"ast_parse":       2000,
+	"vecgrep_search":  2000,
+	"vecgrep_similar": 2000,
+	"vecgrep_status":  500,
+	"gpeek_status":    1000,
+	RecallToolName:    6000,
+}
+
+// ToolBudget gives each tool result a token allowance from the context
+// window that is still free, capped by a per-tool quota, and compresses
+// results that exceed it.
+type ToolBudget struct {
+	quotas map[string]int
+	usage  func() (used, window int)
+}
+
+// NewToolBudget creates a tool budget. usage reports the tokens in use and
+// the size of the context window; quotas override DefaultToolQuotas.
+func NewToolBudget(usage func() (used, window int), quotas map[string]int) *ToolBudget {
+	merged := make(map[string]int, len(DefaultToolQuotas)+len(quotas))
+	for name, q := range DefaultToolQuotas {
+		merged[name] = q
+	}
+	for name, q := range quotas {
+		if q > 0 {
+			merged[name] = q
+		}
+	}
+	return &ToolBudget{quotas: merged, usage: usage}
+}
+
+// NewManagerToolBudget creates a tool budget for the conversation held by cm.
+func NewManagerToolBudget(cm *ContextManager, quotas map[string]int) *ToolBudget {
+	return NewToolBudget(func() (int, int) {
+		stats := cm.GetStats()
+		return stats.UsedTokens, stats.ContextWindow
+	}, quotas)
+}
+
+// Quota returns the most tokens one result of the tool may take.
+func (b *ToolBudget) Quota(toolName string) int {
+	if q, ok := b.quotas[toolName]; ok {
+		return q
+	}
+	return DefaultToolQuota
+}
+
+// Allowance returns the tokens a result of the tool may take when results
+// of that many calls are added to the context together.
+func (b *ToolBudget) Allowance(toolName string, results int) int {
+	used, window := b.usage()
+	free := max(window-used, 0)
+	allowance := int(float64(free) * ToolResultShare)
+	if results > 1 {
+		allowance /= results
+	}
+	return max(min(allowance, b.Quota(toolName)), MinToolAllowance)
+}
+
+// Fit returns the result as it should go into the context: unchanged when
+// it fits the tool's allowance, and compressed otherwise. Results large
+// enough to be compressed or masked are kept in cache in full; a compressed
+// one ends with the key that recall_tool_result takes to bring it back, or,
+// without a cache, a note that the full output is unavailable.
+func (b *ToolBudget) Fit(cache *ToolResultCache, toolName string, input map[string]any, result string, results int) string {
+	tokens := estimateTokens(result)
+	key := ""
+	if cache != nil && tokens >= MinMaskTokens {
+		_, key = cache.Store(toolName, input, result)
+	}
+
+	allowance := b.Allowance(toolName, results)
+	if tokens <= allowance {
+		return result
+	}
+	compressed, method := CompressToolResult(toolName, input, result, allowance)
+	var sb strings.Builder
+	sb.WriteString(strings.TrimRight(compressed, "\n"))
+	fmt.Fprintf(&sb, "\n\n[Compressed from ~%d to ~%d tokens: %s. ", tokens, estimateTokens(compressed), method)
+	if key != "" {
+		fmt.Fprintf(&sb, "Full output: %s key=%q]", RecallToolName, key)
+	} else {
+		sb.WriteString("Full output unavailable]")
+	}
+	return sb.String()
+}
+
+// EstimateTokens estimates the tokens text takes in the context window.
+func EstimateTokens(text string) int {
+	return estimateTokens(text)
+}
+
+// EstimateMessagesTokens estimates the tokens a system prompt and messages
+// take in the context window.
+func EstimateMessagesTokens(systemPrompt string, messages []llm.Message) int {
+	total := estimateTokens(systemPrompt)
+	for _, msg := range messages {
+		total += estimateMessageTokens(msg)
+	}
+	return total
+}
@@ -0,0 +1,91 @@
+package context
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abdul-hamid-achik/vecai/internal/llm"
+)
+
+// fixedUsage reports a constant context usage.
+func fixedUsage(used, window int) func() (int, int) {
+	return func() (int, int) { return used, window }
+}
+
+func TestToolBudget_Allowance(t *testing.T) {
+	b := NewToolBudget(fixedUsage(20000, 60000), map[string]int{"grep": 9000, "bash": 0})
+
+	// A quarter of the 40000 free tokens, capped by the quota
+	if got := b.Allowance("read_file", 1); got != 6000 {
+		t.Errorf("expected the read_file quota, got %d", got)
+	}
+	if got := b.Allowance("grep", 1); got != 9000 {
+		t.Errorf("expected the configured grep quota to win, got %d", got)
+	}
+	if got := b.Allowance("grep", 4); got != 2500 {
+		t.Errorf("expected the free share split between 4 results, got %d", got)
+	}
+	if got := b.Quota("bash"); got != DefaultToolQuotas["bash"] {
+		t.Errorf("expected a zero quota to keep the default, got %d", got)
+	}
+	if got := b.Quota("unknown_tool"); got != DefaultToolQuota {
+		t.Errorf("expected the default quota, got %d", got)
+	}
+
+	full := NewToolBudget(fixedUsage(60000, 60000), nil)
+	if got := full.Allowance("read_file", 1); got != MinToolAllowance {
+		t.Errorf("expected the minimum allowance in a full context, got %d", got)
+	}
+}
+
+func TestToolBudget_Fit(t *testing.T) {
+	cache := NewToolResultCache(time.Minute)
+	defer cache.Stop()
+	b := NewToolBudget(fixedUsage(0, 4000), nil) // 1000-token allowance
+
+	input := map[string]any{"command": "make build"}
+	small := "build ok"
+	if got := b.Fit(cache, "bash", input, small, 1); got != small {
+		t.Errorf("expected a small result unchanged, got %q", got)
+	}
+	if cache.Size() != 0 {
+		t.Error("expected a small result not to be cached")
+	}
+
+	var lines []string
+	for i := range 2000 {
+		lines = append(lines, fmt.Sprintf("compiling package %d of a large build with unique output %x", i, i*7919))
+	}
+	large := strings.Join(lines, "\n")
+	got := b.Fit(cache, "bash", input, large, 1)
+	if EstimateTokens(got) > 1100 {
+		t.Errorf("expected the result near its allowance, got ~%d tokens", EstimateTokens(got))
+	}
+	key := CacheKey("bash", input)
+	if !strings.Contains(got, `Full output: recall_tool_result key="`+key+`"`) {
+		t.Errorf("expected a recall key in the compressed result, got tail %q", got[len(got)-200:])
+	}
+	if full, ok := cache.Get(key); !ok || full != large {
+		t.Error("expected the full result in the cache")
+	}
+
+	// Without a cache there is nothing to recall
+	got = b.Fit(nil, "bash", input, large, 1)
+	if strings.Contains(got, RecallToolName) || !strings.HasSuffix(got, "Full output unavailable]") {
+		t.Errorf("expected no recall key without a cache, got tail %q", got[len(got)-100:])
+	}
+}
+
+func TestEstimateMessagesTokens(t *testing.T) {
+	msgs := []llm.Message{
+		{Role: "user", Content: "hello there"},
+		{Role: "tool", Content: strings.Repeat("output ", 100)},
+	}
+	got := EstimateMessagesTokens("system prompt", msgs)
+	want := estimateTokens("system prompt") + estimateMessageTokens(msgs[0]) + estimateMessageTokens(msgs[1])
+	if got != want || got <= estimateTokens(msgs[1].Content) {
+		t.Errorf("unexpected estimate %d, want %d", got, want)
+	}
+}
@@ -32,6 +32,13 @@ type CacheEntry struct {
 // DefaultCacheTTL is the default time-to-live for cache entries (5 minutes)
 const DefaultCacheTTL = 5 * time.Minute
 
+// RecallCacheTTL keeps results long enough for recall_tool_result to bring
+// back ones compressed or masked earlier in a session
+const RecallCacheTTL = time.Hour
+
+// RecallToolName is the tool that returns a cached result in full
+const RecallToolName = "recall_tool_result"
+
 // MaxSummaryLength is the maximum length of a summary in characters
 const MaxSummaryLength = 500
 
@@ -111,8 +118,13 @@ func (c *ToolResultCache) Size() int {
 }
 
 // generateKey creates a unique cache key from tool name and input.
-// Keys in the input map are sorted to ensure deterministic output.
 func (c *ToolResultCache) generateKey(toolName string, input map[string]any) string {
+	return CacheKey(toolName, input)
+}
+
+// CacheKey returns the key a tool call's result is cached under.
+// Keys in the input map are sorted to ensure deterministic output.
+func CacheKey(toolName string, input map[string]any) string {
 	var parts []string
 	parts = append(parts, toolName)
 
@@ -6,40 +6,50 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/abdul-hamid-achik/vecai/internal/llm"
 )
 
-const compactionPrompt = `You are summarizing a conversation to preserve context while reducing token usage. Create a concise summary that captures:
-
-1. Key decisions and conclusions reached
-2. Important code changes or files discussed (with specific paths and line numbers if mentioned)
-3. Critical technical context (paths, functions, errors, configurations)
-4. Current task state and any pending actions
-5. User preferences or requirements mentioned
+const compactionPrompt = `You are keeping the running state of a coding conversation so its older messages can be dropped. Read the new messages and report only what they add to the current state.
+
+Return ONLY a JSON object with these fields, leaving out any with nothing new:
+{
+  "goals": ["a goal or requirement the user stated"],
+  "completed_goals": [1],
+  "files": [{"path": "internal/api/handler.go", "reason": "what was done to it or why it matters"}],
+  "decisions": ["a decision and the reason for it"],
+  "open_issues": ["an unresolved error or question, with the exact error message"],
+  "resolved_issues": [2],
+  "facts": ["something verified, e.g. a test result or where a function lives"]
+}
 
-Format your summary as clear bullet points. Preserve critical code snippets verbatim if they are essential for context.
-Keep the summary focused and factual - avoid unnecessary elaboration.
+completed_goals and resolved_issues hold the numbers of goals and open issues in the current state.
+Keep exact file paths, line numbers, identifiers, commands and error messages. Do not repeat what the current state already says.
 
+%s
+CURRENT STATE:
 %s
 
-CONVERSATION TO SUMMARIZE:
+NEW MESSAGES:
 %s`
 
 // CompactRequest contains parameters for compaction
 type CompactRequest struct {
 	Messages     []llm.Message
-	FocusPrompt  string // Optional: "preserve code samples", "keep file paths", etc.
-	PreserveLast int    // Keep last N messages verbatim
+	State        *CompactionState // State from the last compaction, if any
+	FocusPrompt  string           // Optional: "preserve code samples", "keep file paths", etc.
+	PreserveLast int              // Keep last N messages verbatim
 }
 
 // CompactResult contains the result of compaction
 type CompactResult struct {
-	Summary          string
-	PreservedMsgs    []llm.Message
-	OriginalTokens   int
-	SummaryTokens    int
-	TokensSaved      int
+	Summary            string           // State rendered as markdown
+	State              *CompactionState // State with the compacted messages merged in
+	PreservedMsgs      []llm.Message
+	OriginalTokens     int
+	SummaryTokens      int
+	TokensSaved        int
 	MessagesSummarized int
 }
 
@@ -70,11 +80,16 @@ func (c *Compactor) SetLearningsCallback(cb LearningsCallback) {
 	c.learningsCallback = cb
 }
 
-// Compact compresses a conversation history into a summary
+// Compact merges the messages before the last PreserveLast into the
+// conversation state. The message carrying the previous state is not sent
+// again: only the messages since the last compaction are, and the LLM
+// reports what they add, which is merged into the state here.
 func (c *Compactor) Compact(ctx context.Context, req CompactRequest) (*CompactResult, error) {
+	state := req.State.Clone()
 	if len(req.Messages) == 0 {
 		return &CompactResult{
 			Summary:       "",
+			State:         state,
 			PreservedMsgs: []llm.Message{},
 		}, nil
 	}
@@ -89,23 +104,25 @@ func (c *Compactor) Compact(ctx context.Context, req CompactRequest) (*CompactRe
 		copy(toPreserve, req.Messages[splitPoint:])
 	}
 
+	// Calculate original tokens, the previous state included
+	originalTokens := calculateTokens(toSummarize)
+	delta := sinceLastCompaction(toSummarize)
+
 	// If nothing to summarize, just return preserved messages
-	if len(toSummarize) == 0 {
+	if len(delta) == 0 {
 		return &CompactResult{
-			Summary:          "",
-			PreservedMsgs:    toPreserve,
-			OriginalTokens:   calculateTokens(req.Messages),
-			SummaryTokens:    0,
-			TokensSaved:      0,
+			Summary:            state.Format(),
+			State:              state,
+			PreservedMsgs:      toPreserve,
+			OriginalTokens:     calculateTokens(req.Messages),
+			SummaryTokens:      0,
+			TokensSaved:        0,
 			MessagesSummarized: 0,
 		}, nil
 	}
 
-	// Calculate original tokens
-	originalTokens := calculateTokens(toSummarize)
-
 	// Format messages for summarization
-	conversationText := formatConversationForSummary(toSummarize)
+	conversationText := formatConversationForSummary(delta)
 
 	// Build focus instruction if provided
 	focusInstruction := ""
@@ -114,39 +131,82 @@ func (c *Compactor) Compact(ctx context.Context, req CompactRequest) (*CompactRe
 	}
 
 	// Create summarization prompt
-	prompt := fmt.Sprintf(compactionPrompt, focusInstruction, conversationText)
+	prompt := fmt.Sprintf(compactionPrompt, focusInstruction, state.Format(), conversationText)
 
-	// Call LLM for summarization
+	// Call LLM for the state delta
 	response, err := c.llmClient.Chat(ctx, []llm.Message{
 		{Role: "user", Content: prompt},
-	}, nil, "You are a helpful assistant that creates concise, accurate summaries.")
+	}, nil, "You keep a structured record of a conversation. Return only valid JSON.")
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate summary: %w", err)
 	}
+	stateDelta, err := parseStateDelta(response.Content)
+	if err != nil {
+		// A reply without usable JSON is kept as a prose summary, so the
+		// previous state survives and compaction still frees the context
+		summary := proseSummary(response.Content)
+		if summary == "" {
+			return nil, fmt.Errorf("failed to parse conversation state: %w", err)
+		}
+		stateDelta = StateDelta{Facts: []string{summary}}
+	}
+
+	state = state.Merge(stateDelta)
+	state.Compactions++
+	state.MessagesCompacted += len(delta)
+	state.UpdatedAt = time.Now()
 
-	summary := strings.TrimSpace(response.Content)
-	summaryTokens := estimateTokens(summary)
+	summaryTokens := calculateTokens(stateMessages(state))
 	preservedTokens := calculateTokens(toPreserve)
 
 	// Extract and save learnings if callback is set and we have enough conversation
-	if c.learningsCallback != nil && len(toSummarize) > 2 {
+	if c.learningsCallback != nil && len(delta) > 2 {
 		c.wg.Add(1)
 		go func() {
 			defer c.wg.Done()
-			c.extractAndSaveLearnings(ctx, toSummarize)
+			c.extractAndSaveLearnings(ctx, delta)
 		}()
 	}
 
 	return &CompactResult{
-		Summary:            summary,
+		Summary:            state.Format(),
+		State:              state,
 		PreservedMsgs:      toPreserve,
 		OriginalTokens:     originalTokens + preservedTokens,
 		SummaryTokens:      summaryTokens + preservedTokens,
 		TokensSaved:        originalTokens - summaryTokens,
-		MessagesSummarized: len(toSummarize),
+		MessagesSummarized: len(delta),
 	}, nil
 }
 
+// maxProseSummary bounds a prose summary kept in place of a state delta.
+const maxProseSummary = 2000
+
+// proseSummary turns a reply that is not a state delta into one line for
+// the verified facts.
+func proseSummary(content string) string {
+	text := strings.Join(strings.Fields(content), " ")
+	if text == "" {
+		return ""
+	}
+	if len(text) > maxProseSummary {
+		text = truncateUTF8(text, maxProseSummary) + "..."
+	}
+	return "Summary of earlier messages: " + text
+}
+
+// sinceLastCompaction drops the leading messages that carry the state of
+// an earlier compaction, which is merged into already.
+func sinceLastCompaction(messages []llm.Message) []llm.Message {
+	if len(messages) > 0 && isStateMessage(messages[0]) {
+		messages = messages[1:]
+		if len(messages) > 0 && messages[0].Role == "assistant" && len(messages[0].ToolCalls) == 0 {
+			messages = messages[1:]
+		}
+	}
+	return messages
+}
+
 // formatConversationForSummary formats messages into a readable conversation format
 func formatConversationForSummary(messages []llm.Message) string {
 	var b strings.Builder
@@ -164,6 +224,12 @@ func formatConversationForSummary(messages []llm.Message) string {
 			content = content[:5000] + "\n[... truncated for summarization ...]"
 		}
 
+		// Tool calls carry the paths and commands the state should keep
+		for _, tc := range msg.ToolCalls {
+			input, _ := json.Marshal(tc.Input)
+			content += fmt.Sprintf("\n[calls %s %s]", tc.Name, truncateUTF8(string(input), 300))
+		}
+
 		fmt.Fprintf(&b, "[%d] %s:\n%s\n\n", i+1, role, content)
 	}
 
@@ -1,7 +1,11 @@
 package context
 
 import (
+	"context"
+	"strings"
 	"testing"
+
+	"github.com/abdul-hamid-achik/vecai/internal/llm"
 )
 
 func TestParseLearningsResponse(t *testing.T) {
@@ -116,3 +120,96 @@ func TestFormatConversationForSummary(t *testing.T) {
 		})
 	}
 }
+
+func TestCompact_MergesOnlyNewMessages(t *testing.T) {
+	mock := llm.NewMockLLMClient()
+	var prompts []string
+	replies := []string{
+		`{"goals": ["Fix the login bug"], "files": [{"path": "auth/login.go", "reason": "nil pointer at line 42"}], "open_issues": ["panic: nil pointer in Login"]}`,
+		`{"resolved_issues": [1], "decisions": ["Return ErrNoUser instead of panicking"], "facts": ["go test ./auth passes"]}`,
+	}
+	mock.ChatFunc = func(_ context.Context, msgs []llm.Message, _ []llm.ToolDefinition, _ string) (*llm.Response, error) {
+		prompts = append(prompts, msgs[0].Content)
+		return &llm.Response{Content: replies[len(prompts)-1]}, nil
+	}
+	c := NewCompactor(mock)
+	cm := NewContextManager("", DefaultContextConfig())
+
+	for _, content := range []string{"login panics", "found it in auth/login.go", "please fix it", "on it"} {
+		cm.AddMessage(llm.Message{Role: "user", Content: content})
+	}
+	result, err := c.Compact(context.Background(), CompactRequest{Messages: cm.GetMessages(), State: cm.State(), PreserveLast: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.MessagesSummarized != 3 || len(result.PreservedMsgs) != 1 {
+		t.Errorf("unexpected first result %+v", result)
+	}
+	cm.ReplaceWithState(result.State, result.PreservedMsgs)
+
+	cm.AddMessage(llm.Message{Role: "user", Content: "added the nil check"})
+	cm.AddMessage(llm.Message{Role: "user", Content: "tests pass"})
+	result, err = c.Compact(context.Background(), CompactRequest{Messages: cm.GetMessages(), State: cm.State(), PreserveLast: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The second prompt sends the state once and only the messages since
+	_, newMessages, _ := strings.Cut(prompts[1], "NEW MESSAGES:")
+	if strings.Contains(newMessages, StateMessagePrefix) || strings.Contains(newMessages, "login panics") || !strings.Contains(newMessages, "on it") {
+		t.Errorf("expected only messages since the last compaction, got:\n%s", newMessages)
+	}
+	if !strings.Contains(prompts[1], "1. panic: nil pointer in Login") {
+		t.Error("expected the current state, with numbered issues, in the prompt")
+	}
+
+	s := result.State
+	if len(s.Goals) != 1 || len(s.Files) != 1 || s.Files[0].Reason != "nil pointer at line 42" {
+		t.Errorf("expected early goals and files to survive, got %+v", s)
+	}
+	if len(s.OpenIssues) != 0 || len(s.Decisions) != 1 || len(s.Facts) != 1 {
+		t.Errorf("expected the issue resolved and the decision and fact added, got %+v", s)
+	}
+	if s.Compactions != 2 || s.MessagesCompacted != 5 {
+		t.Errorf("expected 5 messages in 2 compactions, got %d in %d", s.MessagesCompacted, s.Compactions)
+	}
+}
+
+func TestCompact_ProseReplyFallsBackToSummary(t *testing.T) {
+	mock := llm.NewMockLLMClient()
+	reply := "- Fixed the nil pointer in auth/login.go\n- Tests pass"
+	mock.ChatFunc = func(context.Context, []llm.Message, []llm.ToolDefinition, string) (*llm.Response, error) {
+		return &llm.Response{Content: reply}, nil
+	}
+	prev := &CompactionState{Goals: []string{"Fix the login bug"}, Compactions: 1}
+	msgs := []llm.Message{
+		{Role: "user", Content: "fix it"},
+		{Role: "assistant", Content: "done"},
+		{Role: "user", Content: "thanks"},
+	}
+
+	result, err := NewCompactor(mock).Compact(context.Background(), CompactRequest{Messages: msgs, State: prev, PreserveLast: 1})
+	if err != nil {
+		t.Fatalf("expected a prose reply to still compact, got %v", err)
+	}
+	s := result.State
+	if len(s.Goals) != 1 || s.Goals[0] != "Fix the login bug" {
+		t.Errorf("expected the previous state kept, got %+v", s)
+	}
+	if len(s.Facts) != 1 || s.Facts[0] != "Summary of earlier messages: - Fixed the nil pointer in auth/login.go - Tests pass" {
+		t.Errorf("expected the reply kept as a fact, got %q", s.Facts)
+	}
+	if s.Compactions != 2 || result.MessagesSummarized != 2 || len(result.PreservedMsgs) != 1 {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	// A cut-off JSON reply is kept the same way; an empty one fails
+	reply = `{"goals": ["Ship v2"], "files": [{"path": "`
+	if result, err = NewCompactor(mock).Compact(context.Background(), CompactRequest{Messages: msgs, PreserveLast: 1}); err != nil || !strings.Contains(result.State.Facts[0], `"Ship v2"`) {
+		t.Errorf("expected a cut-off reply kept as a fact, got %v", err)
+	}
+	reply = "  "
+	if _, err = NewCompactor(mock).Compact(context.Background(), CompactRequest{Messages: msgs, PreserveLast: 1}); err == nil {
+		t.Error("expected an error for an empty reply")
+	}
+}
@@ -0,0 +1,258 @@
+package context
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// codeExtensions are the files whose reads are collapsed to signatures
+var codeExtensions = map[string]bool{
+	".go": true, ".py": true, ".js": true, ".jsx": true, ".ts": true, ".tsx": true,
+	".rs": true, ".java": true, ".kt": true, ".swift": true, ".c": true, ".h": true,
+	".cc": true, ".cpp": true, ".hpp": true, ".cs": true, ".rb": true, ".php": true,
+	".scala": true, ".lua": true, ".zig": true,
+}
+
+var (
+	// numberedLine matches the line numbers read_file adds to ranges and chunks
+	numberedLine = regexp.MustCompile(`^\s*\d+ \| `)
+
+	// declarationLine matches declarations nested in a class or module
+	declarationLine = regexp.MustCompile(`^\s*(export\s+|default\s+|pub(\([^)]*\))?\s+|public\s+|private\s+|protected\s+|internal\s+|static\s+|async\s+|abstract\s+|override\s+|final\s+)*(func|fn|def|class|interface|struct|enum|trait|impl|function|type|module|namespace)\b`)
+
+	// testCommand matches commands that run a test suite
+	testCommand = regexp.MustCompile(`\b(go test|pytest|npm (run )?test|yarn test|pnpm test|cargo test|jest|vitest|mocha|rspec|make test|gotestsum)\b`)
+
+	// testResultLine matches the per-test result lines of common runners
+	testResultLine = regexp.MustCompile(`^\s*(=== RUN|--- (PASS|FAIL|SKIP)|PASS$|FAIL\b|ok\s)|\b(PASSED|FAILED)\b`)
+
+	// passingLine matches test runner lines that report progress or success
+	passingLine = regexp.MustCompile(`^\s*(=== (RUN|PAUSE|CONT|NAME)\b|--- (PASS|SKIP)\b|PASS$|ok\s|✓|✔)|\bPASSED\b`)
+
+	// passedTest matches the line ending a passing test; the indented lines
+	// under it are its log output
+	passedTest = regexp.MustCompile(`^\s*(--- (PASS|SKIP)\b|✓|✔)|\bPASSED\b`)
+
+	digits = regexp.MustCompile(`\d+`)
+)
+
+// CompressToolResult shrinks a tool result toward maxTokens while keeping
+// its structure: code is collapsed to its declarations, test output to its
+// failures, and runs of repeated log lines to one. Whatever is still over
+// the limit loses lines from the middle. It returns the compressed result
+// and a description of what was done.
+func CompressToolResult(toolName string, input map[string]any, result string, maxTokens int) (string, string) {
+	var methods []string
+	code := isCodeResult(toolName, input)
+	switch {
+	case code:
+		if r, ok := collapseToSignatures(result); ok {
+			result = r
+			methods = append(methods, "code collapsed to signatures")
+		}
+	case isTestOutput(toolName, input, result):
+		if r, ok := reduceToFailures(result); ok {
+			result = r
+			methods = append(methods, "test output reduced to failures")
+		}
+	}
+	if !code {
+		if r, ok := collapseRepeats(result); ok {
+			result = r
+			methods = append(methods, "repeated lines collapsed")
+		}
+	}
+	if estimateTokens(result) > maxTokens {
+		result = elideMiddle(result, maxTokens)
+		methods = append(methods, "middle elided")
+	}
+	return result, strings.Join(methods, ", ")
+}
+
+// isCodeResult reports whether a result holds source code.
+func isCodeResult(toolName string, input map[string]any) bool {
+	if toolName != "read_file" {
+		return false
+	}
+	path, _ := input["path"].(string)
+	return codeExtensions[strings.ToLower(filepath.Ext(path))]
+}
+
+// isTestOutput reports whether a result is the output of a test run.
+func isTestOutput(toolName string, input map[string]any, result string) bool {
+	if toolName == "test_run" {
+		return true
+	}
+	if command, ok := input["command"].(string); ok && testCommand.MatchString(command) {
+		return true
+	}
+	n := 0
+	for _, line := range strings.Split(result, "\n") {
+		if testResultLine.MatchString(line) {
+			if n++; n >= 3 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// collapseToSignatures keeps unindented lines and nested declarations and
+// replaces each longer run of other lines (function bodies, mostly) with a
+// count.
+func collapseToSignatures(result string) (string, bool) {
+	var sb strings.Builder
+	var run []string
+	code := 0
+	flush := func() {
+		if code > 0 && len(run) > 2 {
+			text := numberedLine.ReplaceAllString(run[0], "")
+			indent := text[:len(text)-len(strings.TrimLeft(text, " \t"))]
+			fmt.Fprintf(&sb, "%s... %d lines\n", indent, len(run))
+		} else {
+			for _, line := range run {
+				sb.WriteString(line)
+				sb.WriteString("\n")
+			}
+		}
+		run, code = run[:0], 0
+	}
+	for _, line := range strings.Split(result, "\n") {
+		text := numberedLine.ReplaceAllString(line, "")
+		trimmed := strings.TrimSpace(text)
+		unindented := text != "" && text[0] != ' ' && text[0] != '\t'
+		blank := trimmed == "" && len(run) == 0
+		if unindented || blank || declarationLine.MatchString(text) {
+			flush()
+			sb.WriteString(line)
+			sb.WriteString("\n")
+			continue
+		}
+		run = append(run, line)
+		if trimmed != "" && !closingOnly(trimmed) {
+			code++
+		}
+	}
+	flush()
+	out := strings.TrimSuffix(sb.String(), "\n")
+	return out, len(out) < len(result)
+}
+
+// closingOnly reports whether a line only closes blocks, e.g. "})".
+func closingOnly(s string) bool {
+	return strings.Trim(s, "})];,") == ""
+}
+
+// reduceToFailures drops the lines of passing tests, along with the
+// indented output under them, and keeps failures and summaries. Output
+// under a test that is still running may explain a failure, so it stays.
+func reduceToFailures(result string) (string, bool) {
+	var kept []string
+	dropped := 0
+	skipIndent := -1
+	for _, line := range strings.Split(result, "\n") {
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		if skipIndent >= 0 && strings.TrimSpace(line) != "" && indent > skipIndent {
+			dropped++
+			continue
+		}
+		skipIndent = -1
+		if passingLine.MatchString(line) {
+			dropped++
+			if passedTest.MatchString(line) {
+				skipIndent = indent
+			}
+			continue
+		}
+		kept = append(kept, line)
+	}
+	if dropped == 0 {
+		return result, false
+	}
+	return fmt.Sprintf("[%d lines of passing tests omitted]\n%s", dropped, strings.Join(kept, "\n")), true
+}
+
+// collapseRepeats replaces runs of three or more lines that differ only in
+// their numbers (counters, timestamps) with the first line and a count.
+func collapseRepeats(result string) (string, bool) {
+	lines := strings.Split(result, "\n")
+	var sb strings.Builder
+	collapsed := false
+	for i := 0; i < len(lines); {
+		key := digits.ReplaceAllString(lines[i], "0")
+		j := i + 1
+		same := true
+		for j < len(lines) && digits.ReplaceAllString(lines[j], "0") == key {
+			same = same && lines[j] == lines[i]
+			j++
+		}
+		sb.WriteString(lines[i])
+		if n := j - i - 1; n >= 2 && strings.TrimSpace(lines[i]) != "" {
+			collapsed = true
+			if same {
+				fmt.Fprintf(&sb, "\n[previous line repeated %d more times]", n)
+			} else {
+				fmt.Fprintf(&sb, "\n[%d more similar lines]", n)
+			}
+		} else {
+			for _, line := range lines[i+1 : j] {
+				sb.WriteString("\n")
+				sb.WriteString(line)
+			}
+		}
+		if j < len(lines) {
+			sb.WriteString("\n")
+		}
+		i = j
+	}
+	if !collapsed {
+		return result, false
+	}
+	return sb.String(), true
+}
+
+// elideMiddle keeps the first two thirds and last third of what fits in
+// maxTokens, in whole lines where it can, and notes what was left out.
+func elideMiddle(result string, maxTokens int) string {
+	// estimateTokens adds 10% on top of the chars-per-token ratio
+	budget := int(float64(maxTokens) * estimateCharsPerToken(result) / 1.1)
+	headBudget := budget * 2 / 3
+	tailBudget := budget - headBudget
+
+	lines := strings.Split(result, "\n")
+	head, used := 0, 0
+	for head < len(lines) && used+len(lines[head])+1 <= headBudget {
+		used += len(lines[head]) + 1
+		head++
+	}
+	var sb strings.Builder
+	sb.WriteString(strings.Join(lines[:head], "\n"))
+	if head == 0 {
+		// A first line longer than the budget: keep its start
+		sb.WriteString(truncateUTF8(lines[0], headBudget))
+		head = 1
+	}
+	tail, used := len(lines), 0
+	for tail > head && used+len(lines[tail-1])+1 <= tailBudget {
+		used += len(lines[tail-1]) + 1
+		tail--
+	}
+
+	fmt.Fprintf(&sb, "\n[... %d lines elided ...]\n", tail-head)
+	sb.WriteString(strings.Join(lines[tail:], "\n"))
+	return sb.String()
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a rune.
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
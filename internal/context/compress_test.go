@@ -0,0 +1,116 @@
+package context
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCompressToolResult_Code(t *testing.T) {
+	src := `package main
+
+import "fmt"
+
+// Greet prints a greeting
+func Greet(name string) {
+	msg := "hello " + name
+	if name == "" {
+		msg = "hello"
+	}
+	fmt.Println(msg)
+}
+
+type Server struct {
+	addr string
+}
+`
+	got, method := CompressToolResult("read_file", map[string]any{"path": "main.go"}, src, 10000)
+	if method != "code collapsed to signatures" {
+		t.Errorf("unexpected method %q", method)
+	}
+	for _, want := range []string{"// Greet prints a greeting\n", "func Greet(name string) {\n\t... 5 lines\n}\n\ntype Server struct {\n\taddr string\n}"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "fmt.Println(msg)") {
+		t.Errorf("expected the function body to be collapsed:\n%s", got)
+	}
+
+	// Nested declarations survive in numbered ranges
+	numbered := "  10 | class Store:\n  11 |     def get(self, key):\n  12 |         value = self.data[key]\n  13 |         self.hits += 1\n  14 |         return value\n"
+	got, _ = CompressToolResult("read_file", map[string]any{"path": "store.py"}, numbered, 10000)
+	if !strings.Contains(got, "  11 |     def get(self, key):\n") || strings.Contains(got, "return value") {
+		t.Errorf("unexpected collapsed python:\n%s", got)
+	}
+}
+
+func TestCompressToolResult_TestOutput(t *testing.T) {
+	out := `=== RUN   TestA
+--- PASS: TestA (0.00s)
+=== RUN   TestB
+    b_test.go:12: expected 2, got 3
+--- FAIL: TestB (0.00s)
+=== RUN   TestC
+--- PASS: TestC (0.00s)
+    --- PASS: TestC/sub (0.00s)
+FAIL
+FAIL	example.com/pkg	0.01s`
+	got, method := CompressToolResult("bash", map[string]any{"command": "go test ./..."}, out, 10000)
+	if method != "test output reduced to failures" {
+		t.Errorf("unexpected method %q", method)
+	}
+	for _, want := range []string{"[6 lines of passing tests omitted]", "b_test.go:12: expected 2, got 3", "--- FAIL: TestB", "FAIL\texample.com/pkg"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "TestA") || strings.Contains(got, "TestC") {
+		t.Errorf("expected passing tests dropped:\n%s", got)
+	}
+}
+
+func TestCompressToolResult_Repeats(t *testing.T) {
+	var lines []string
+	lines = append(lines, "starting")
+	for range 5 {
+		lines = append(lines, "retrying connection")
+	}
+	for i := range 4 {
+		lines = append(lines, fmt.Sprintf("2024-01-01 10:00:%02d request %d served", i, i))
+	}
+	lines = append(lines, "done")
+
+	got, method := CompressToolResult("bash", map[string]any{"command": "tail app.log"}, strings.Join(lines, "\n"), 10000)
+	if method != "repeated lines collapsed" {
+		t.Errorf("unexpected method %q", method)
+	}
+	want := "starting\nretrying connection\n[previous line repeated 4 more times]\n" +
+		"2024-01-01 10:00:00 request 0 served\n[3 more similar lines]\ndone"
+	if got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestCompressToolResult_ElidesMiddle(t *testing.T) {
+	var lines []string
+	for i := range 1000 {
+		lines = append(lines, fmt.Sprintf("line %d says %s", i, strings.Repeat(string(rune('a'+i%26)), 40)))
+	}
+	got, method := CompressToolResult("grep", map[string]any{"pattern": "x"}, strings.Join(lines, "\n"), 500)
+	if method != "middle elided" {
+		t.Errorf("unexpected method %q", method)
+	}
+	if tokens := estimateTokens(got); tokens > 550 {
+		t.Errorf("expected about 500 tokens, got %d", tokens)
+	}
+	if !strings.HasPrefix(got, "line 0 says") || !strings.HasSuffix(got, lines[999]) || !strings.Contains(got, "lines elided ...]") {
+		t.Errorf("expected the head and tail with an elision note, got:\n%s", got)
+	}
+
+	// One huge line is cut without splitting a rune
+	got = elideMiddle(strings.Repeat("é", 5000), 100)
+	if !strings.HasSuffix(got, "[... 0 lines elided ...]\n") || strings.ContainsRune(got, '�') {
+		t.Errorf("unexpected cut of a long line: %q", got[len(got)-40:])
+	}
+}
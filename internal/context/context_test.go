@@ -173,39 +173,6 @@ func TestGetOlderMessages(t *testing.T) {
 	}
 }
 
-func TestReplaceWithSummary(t *testing.T) {
-	cfg := DefaultContextConfig()
-	cm := NewContextManager("", cfg)
-
-	// Add some messages
-	for i := 0; i < 5; i++ {
-		cm.AddMessage(llm.Message{
-			Role:    "user",
-			Content: "Original message " + string(rune('A'+i)),
-		})
-	}
-
-	preserved := []llm.Message{
-		{Role: "user", Content: "Recent message"},
-	}
-
-	cm.ReplaceWithSummary("This is a summary", preserved)
-
-	messages := cm.GetMessages()
-	// Should have: summary user msg, summary ack, preserved message
-	if len(messages) != 3 {
-		t.Errorf("expected 3 messages after replacement, got %d", len(messages))
-	}
-
-	// First message should be the summary
-	if messages[0].Role != "user" {
-		t.Error("expected first message to be user role (summary)")
-	}
-	if messages[0].Content == "" || len(messages[0].Content) < 10 {
-		t.Error("expected summary content in first message")
-	}
-}
-
 func TestEstimateTokens(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -70,6 +70,9 @@ type ContextManager struct {
 	cachedTokens int
 	statsDirty   bool
 
+	// Structured state kept by compaction
+	state *CompactionState
+
 	// Session persistence callback
 	onSave func([]llm.Message)
 }
@@ -225,28 +228,30 @@ func (cm *ContextManager) GetBreakdown() MessageBreakdown {
 	return breakdown
 }
 
-// ReplaceWithSummary replaces the conversation history with a summary
-func (cm *ContextManager) ReplaceWithSummary(summary string, preserveRecent []llm.Message) {
+// ReplaceWithState replaces the conversation history with the compaction
+// state, carried in a leading message, and the preserved recent messages.
+func (cm *ContextManager) ReplaceWithState(state *CompactionState, preserveRecent []llm.Message) {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
-	// Create new message list with summary as first message
-	newMessages := []llm.Message{
-		{
-			Role:    "user",
-			Content: "[Previous conversation summary]\n" + summary,
-		},
-		{
-			Role:    "assistant",
-			Content: "I understand. I have the context from our previous conversation. How can I help you continue?",
-		},
-	}
+	cm.messages = append(stateMessages(state), preserveRecent...)
+	cm.state = state
+	cm.statsDirty = true
+}
 
-	// Add preserved recent messages
-	newMessages = append(newMessages, preserveRecent...)
+// State returns the compaction state, or nil before the first compaction.
+// The state is replaced, never changed, so it may be read freely.
+func (cm *ContextManager) State() *CompactionState {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	return cm.state
+}
 
-	cm.messages = newMessages
-	cm.statsDirty = true
+// SetState restores the compaction state of a resumed session.
+func (cm *ContextManager) SetState(state *CompactionState) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.state = state
 }
 
 // Clear clears all messages
@@ -255,6 +260,7 @@ func (cm *ContextManager) Clear() {
 	defer cm.mu.Unlock()
 
 	cm.messages = []llm.Message{}
+	cm.state = nil
 	cm.cachedTokens = 0
 	cm.statsDirty = true
 }
@@ -319,21 +325,22 @@ func (cm *ContextManager) SetContextWindow(size int) {
 
 // calculateTotalTokens estimates total tokens for all content
 func (cm *ContextManager) calculateTotalTokens() int {
-	total := estimateTokens(cm.systemPrompt)
-	for _, msg := range cm.messages {
-		total += estimateTokens(msg.Content)
-		// Estimate tokens for tool calls (name + serialized arguments)
-		for _, tc := range msg.ToolCalls {
-			total += estimateTokens(tc.Name)
-			for k, v := range tc.Input {
-				total += estimateTokens(k)
-				total += estimateTokens(fmt.Sprintf("%v", v))
-			}
+	return EstimateMessagesTokens(cm.systemPrompt, cm.messages)
+}
+
+// estimateMessageTokens estimates the tokens of one message, tool calls included
+func estimateMessageTokens(msg llm.Message) int {
+	total := estimateTokens(msg.Content)
+	// Estimate tokens for tool calls (name + serialized arguments)
+	for _, tc := range msg.ToolCalls {
+		total += estimateTokens(tc.Name)
+		for k, v := range tc.Input {
+			total += estimateTokens(k)
+			total += estimateTokens(fmt.Sprintf("%v", v))
 		}
-		// Add overhead for message structure
-		total += 10
 	}
-	return total
+	// Add overhead for message structure
+	return total + 10
 }
 
 // estimateTokens provides a content-aware token estimate for text.
@@ -2,6 +2,7 @@ package context
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/abdul-hamid-achik/vecai/internal/llm"
@@ -10,9 +11,15 @@ import (
 // DefaultPreserveRecent is the number of recent tool results to keep unmasked.
 const DefaultPreserveRecent = 4
 
+// MinMaskTokens is the size below which a tool result is never masked;
+// its mask would save next to nothing.
+const MinMaskTokens = 100
+
 // MaskOldToolResults replaces old tool result content with a short summary
 // to reduce context usage while preserving ToolCallID linkage for Ollama's
-// tool protocol. The last `preserveRecent` tool results are kept verbatim.
+// tool protocol. The last `preserveRecent` tool results are kept verbatim,
+// and so are small ones. A mask names the cache key under which
+// recall_tool_result can bring back the full result.
 func MaskOldToolResults(messages []llm.Message, preserveRecent int) []llm.Message {
 	if preserveRecent <= 0 {
 		preserveRecent = DefaultPreserveRecent
@@ -35,16 +42,30 @@ func MaskOldToolResults(messages []llm.Message, preserveRecent int) []llm.Messag
 	maskUpTo := len(toolIndices) - preserveRecent
 	maskSet := make(map[int]bool, maskUpTo)
 	for _, idx := range toolIndices[:maskUpTo] {
-		maskSet[idx] = true
+		if estimateTokens(messages[idx].Content) >= MinMaskTokens {
+			maskSet[idx] = true
+		}
+	}
+
+	// Tool calls by ID, to name the cache key of each result
+	calls := make(map[string]llm.ToolCall)
+	for _, msg := range messages {
+		for _, tc := range msg.ToolCalls {
+			calls[tc.ID] = tc
+		}
 	}
 
 	// Create a new slice with masked messages
 	result := make([]llm.Message, len(messages))
 	for i, msg := range messages {
 		if maskSet[i] {
+			key := recallKey(msg.Content)
+			if tc, ok := calls[msg.ToolCallID]; ok && key == "" {
+				key = CacheKey(tc.Name, tc.Input)
+			}
 			result[i] = llm.Message{
 				Role:       msg.Role,
-				Content:    maskContent(msg.Content),
+				Content:    maskContent(msg.Content, key),
 				ToolCallID: msg.ToolCallID, // Preserve linkage
 			}
 		} else {
@@ -55,8 +76,19 @@ func MaskOldToolResults(messages []llm.Message, preserveRecent int) []llm.Messag
 	return result
 }
 
+// recallKeyPattern finds the recall key a compressed result ends with
+var recallKeyPattern = regexp.MustCompile(RecallToolName + ` key="([0-9a-f]+)"`)
+
+// recallKey returns the cache key named in a compressed result, if any.
+func recallKey(content string) string {
+	if m := recallKeyPattern.FindStringSubmatch(content); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
 // maskContent creates a short masked summary of tool output.
-func maskContent(content string) string {
+func maskContent(content, key string) string {
 	lines := strings.Count(content, "\n") + 1
 	preview := content
 	if idx := strings.IndexByte(content, '\n'); idx > 0 {
@@ -65,5 +97,8 @@ func maskContent(content string) string {
 	if len(preview) > 80 {
 		preview = preview[:77] + "..."
 	}
-	return fmt.Sprintf("[Masked: %d lines, preview: %s]", lines, preview)
+	if key == "" {
+		return fmt.Sprintf("[Masked: %d lines, preview: %s]", lines, preview)
+	}
+	return fmt.Sprintf("[Masked: %d lines, preview: %s. Full output: %s key=%q]", lines, preview, RecallToolName, key)
 }
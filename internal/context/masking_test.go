@@ -0,0 +1,45 @@
+package context
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/abdul-hamid-achik/vecai/internal/llm"
+)
+
+func TestMaskOldToolResults(t *testing.T) {
+	big := strings.Repeat("a line of tool output\n", 50)
+	compressed := "head\n\n[Compressed from ~900 to ~300 tokens: middle elided. Full output: recall_tool_result key=\"abc123\"]" + strings.Repeat(" pad", 100)
+	input := map[string]any{"path": "main.go"}
+	messages := []llm.Message{
+		{Role: "assistant", ToolCalls: []llm.ToolCall{
+			{ID: "c1", Name: "read_file", Input: input},
+			{ID: "c2", Name: "bash"},
+			{ID: "c3", Name: "grep"},
+		}},
+		{Role: "tool", ToolCallID: "c1", Content: big},
+		{Role: "tool", ToolCallID: "c2", Content: "ok"},
+		{Role: "tool", ToolCallID: "c3", Content: compressed},
+		{Role: "tool", ToolCallID: "c4", Content: big},
+	}
+
+	got := MaskOldToolResults(messages, 1)
+	if want := `Full output: recall_tool_result key="` + CacheKey("read_file", input) + `"`; !strings.HasPrefix(got[1].Content, "[Masked: 51 lines") || !strings.Contains(got[1].Content, want) {
+		t.Errorf("expected a mask with the call's cache key, got %q", got[1].Content)
+	}
+	if got[1].ToolCallID != "c1" {
+		t.Error("expected the tool call ID to be kept")
+	}
+	if got[2].Content != "ok" {
+		t.Errorf("expected a small result to stay, got %q", got[2].Content)
+	}
+	if !strings.Contains(got[3].Content, `key="abc123"`) {
+		t.Errorf("expected the compressed result's key, got %q", got[3].Content)
+	}
+	if got[4].Content != big {
+		t.Error("expected the most recent result to stay")
+	}
+	if messages[1].Content != big {
+		t.Error("expected the input messages to be left alone")
+	}
+}
@@ -0,0 +1,206 @@
+package context
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/abdul-hamid-achik/vecai/internal/llm"
+)
+
+// StateMessagePrefix starts the message that carries the compaction state
+// in the conversation.
+const StateMessagePrefix = "[Conversation state]"
+
+// MaxStateItems bounds each section of the compaction state; the oldest
+// entries go first.
+const MaxStateItems = 30
+
+// CompactionState is the structured running state of a conversation that
+// compaction keeps in place of the messages it drops. Each compaction
+// merges in what the messages since the last one added, so details from
+// early in a session survive any number of compactions.
+type CompactionState struct {
+	Goals      []string   `json:"goals,omitempty"`
+	Files      []FileNote `json:"files,omitempty"`
+	Decisions  []string   `json:"decisions,omitempty"`
+	OpenIssues []string   `json:"open_issues,omitempty"`
+	Facts      []string   `json:"facts,omitempty"`
+
+	Compactions       int       `json:"compactions"`
+	MessagesCompacted int       `json:"messages_compacted"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// FileNote is a file the conversation touched and why.
+type FileNote struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// StateDelta is what one compaction adds to the state. Completed goals and
+// resolved issues are 1-based numbers of items in the current state.
+type StateDelta struct {
+	Goals          []string   `json:"goals"`
+	CompletedGoals []int      `json:"completed_goals"`
+	Files          []FileNote `json:"files"`
+	Decisions      []string   `json:"decisions"`
+	OpenIssues     []string   `json:"open_issues"`
+	ResolvedIssues []int      `json:"resolved_issues"`
+	Facts          []string   `json:"facts"`
+}
+
+// IsEmpty reports whether the state holds nothing.
+func (s *CompactionState) IsEmpty() bool {
+	return s == nil || len(s.Goals)+len(s.Files)+len(s.Decisions)+len(s.OpenIssues)+len(s.Facts) == 0
+}
+
+// Clone returns a deep copy of the state. A nil state clones to an empty one.
+func (s *CompactionState) Clone() *CompactionState {
+	if s == nil {
+		return &CompactionState{}
+	}
+	c := *s
+	c.Goals = slices.Clone(s.Goals)
+	c.Files = slices.Clone(s.Files)
+	c.Decisions = slices.Clone(s.Decisions)
+	c.OpenIssues = slices.Clone(s.OpenIssues)
+	c.Facts = slices.Clone(s.Facts)
+	return &c
+}
+
+// Merge returns the state with delta applied; the receiver is left alone.
+func (s *CompactionState) Merge(delta StateDelta) *CompactionState {
+	m := s.Clone()
+	m.Goals = appendUnique(removeNumbered(m.Goals, delta.CompletedGoals), delta.Goals)
+	m.OpenIssues = appendUnique(removeNumbered(m.OpenIssues, delta.ResolvedIssues), delta.OpenIssues)
+	m.Decisions = appendUnique(m.Decisions, delta.Decisions)
+	m.Facts = appendUnique(m.Facts, delta.Facts)
+	for _, f := range delta.Files {
+		f.Path, f.Reason = strings.TrimSpace(f.Path), strings.TrimSpace(f.Reason)
+		if f.Path == "" {
+			continue
+		}
+		i := slices.IndexFunc(m.Files, func(n FileNote) bool { return n.Path == f.Path })
+		switch {
+		case i < 0:
+			m.Files = append(m.Files, f)
+		case f.Reason != "":
+			m.Files[i].Reason = f.Reason
+		}
+	}
+	m.Files = keepLast(m.Files)
+	return m
+}
+
+// removeNumbered drops the items with the given 1-based numbers.
+func removeNumbered(items []string, numbers []int) []string {
+	if len(numbers) == 0 {
+		return items
+	}
+	var kept []string
+	for i, item := range items {
+		if !slices.Contains(numbers, i+1) {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+// appendUnique appends the non-empty items not already present, ignoring
+// case and surrounding space.
+func appendUnique(items, add []string) []string {
+	for _, a := range add {
+		a = strings.TrimSpace(a)
+		if a == "" {
+			continue
+		}
+		dup := slices.ContainsFunc(items, func(item string) bool { return strings.EqualFold(item, a) })
+		if !dup {
+			items = append(items, a)
+		}
+	}
+	return keepLast(items)
+}
+
+// keepLast keeps the newest MaxStateItems items.
+func keepLast[T any](items []T) []T {
+	if len(items) > MaxStateItems {
+		return items[len(items)-MaxStateItems:]
+	}
+	return items
+}
+
+// Format renders the state as markdown. Goals and open issues are numbered
+// so a compaction can refer to them.
+func (s *CompactionState) Format() string {
+	if s.IsEmpty() {
+		return "(empty)"
+	}
+	var sb strings.Builder
+	section := func(title string, items []string, numbered bool) {
+		if len(items) == 0 {
+			return
+		}
+		if sb.Len() > 0 {
+			sb.WriteString("\n")
+		}
+		fmt.Fprintf(&sb, "## %s\n", title)
+		for i, item := range items {
+			if numbered {
+				fmt.Fprintf(&sb, "%d. %s\n", i+1, item)
+			} else {
+				fmt.Fprintf(&sb, "- %s\n", item)
+			}
+		}
+	}
+	section("Goals", s.Goals, true)
+	files := make([]string, len(s.Files))
+	for i, f := range s.Files {
+		files[i] = f.Path
+		if f.Reason != "" {
+			files[i] += ": " + f.Reason
+		}
+	}
+	section("Files touched", files, false)
+	section("Decisions", s.Decisions, false)
+	section("Open issues", s.OpenIssues, true)
+	section("Verified facts", s.Facts, false)
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// parseStateDelta extracts the state delta from an LLM response.
+func parseStateDelta(content string) (StateDelta, error) {
+	var delta StateDelta
+	start := strings.Index(content, "{")
+	end := strings.LastIndex(content, "}")
+	if start == -1 || end <= start {
+		return delta, fmt.Errorf("no JSON object in response")
+	}
+	if err := json.Unmarshal([]byte(content[start:end+1]), &delta); err != nil {
+		return delta, fmt.Errorf("invalid state JSON: %w", err)
+	}
+	return delta, nil
+}
+
+// isStateMessage reports whether a message carries the compaction state.
+func isStateMessage(msg llm.Message) bool {
+	return msg.Role == "user" && strings.HasPrefix(msg.Content, StateMessagePrefix)
+}
+
+// stateMessages returns the messages that carry a compaction state in the
+// conversation: the state and the reply acknowledging it.
+func stateMessages(state *CompactionState) []llm.Message {
+	return []llm.Message{
+		{
+			Role:    "user",
+			Content: StateMessagePrefix + "\nEarlier messages were compacted into this state of our work so far.\n\n" + state.Format(),
+		},
+		{
+			Role:    "assistant",
+			Content: "I understand. I have the context from our previous conversation. How can I help you continue?",
+		},
+	}
+}
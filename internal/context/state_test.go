@@ -0,0 +1,86 @@
+package context
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompactionState_Merge(t *testing.T) {
+	s := &CompactionState{
+		Goals:      []string{"Fix the login bug", "Add tests"},
+		Files:      []FileNote{{Path: "auth/login.go", Reason: "bug is here"}},
+		OpenIssues: []string{"nil pointer in Login", "flaky test"},
+	}
+	merged := s.Merge(StateDelta{
+		Goals:          []string{"add tests", "Update the docs", " "},
+		CompletedGoals: []int{1},
+		Files:          []FileNote{{Path: "auth/login.go", Reason: "nil check added"}, {Path: "auth/login_test.go"}},
+		Decisions:      []string{"Return an error instead of panicking"},
+		ResolvedIssues: []int{1, 9},
+		Facts:          []string{"go test ./auth passes"},
+	})
+
+	if got := strings.Join(merged.Goals, "|"); got != "Add tests|Update the docs" {
+		t.Errorf("unexpected goals %q", got)
+	}
+	if len(merged.Files) != 2 || merged.Files[0].Reason != "nil check added" || merged.Files[1].Path != "auth/login_test.go" {
+		t.Errorf("unexpected files %+v", merged.Files)
+	}
+	if len(merged.OpenIssues) != 1 || merged.OpenIssues[0] != "flaky test" {
+		t.Errorf("unexpected open issues %v", merged.OpenIssues)
+	}
+	if len(merged.Decisions) != 1 || len(merged.Facts) != 1 {
+		t.Errorf("expected the new decision and fact, got %+v", merged)
+	}
+	if len(s.Goals) != 2 || s.Files[0].Reason != "bug is here" {
+		t.Error("expected Merge to leave the receiver alone")
+	}
+
+	var facts []string
+	for i := range MaxStateItems + 5 {
+		facts = append(facts, strings.Repeat("f", i+1))
+	}
+	merged = merged.Merge(StateDelta{Facts: facts})
+	if len(merged.Facts) != MaxStateItems || merged.Facts[MaxStateItems-1] != facts[len(facts)-1] {
+		t.Errorf("expected the newest %d facts, got %d", MaxStateItems, len(merged.Facts))
+	}
+}
+
+func TestCompactionState_Format(t *testing.T) {
+	var empty *CompactionState
+	if !empty.IsEmpty() || empty.Format() != "(empty)" {
+		t.Error("expected a nil state to be empty")
+	}
+
+	s := &CompactionState{
+		Goals:      []string{"Fix the login bug"},
+		Files:      []FileNote{{Path: "auth/login.go", Reason: "nil check added"}, {Path: "go.mod"}},
+		OpenIssues: []string{"flaky test", "slow build"},
+	}
+	want := `## Goals
+1. Fix the login bug
+
+## Files touched
+- auth/login.go: nil check added
+- go.mod
+
+## Open issues
+1. flaky test
+2. slow build`
+	if got := s.Format(); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestParseStateDelta(t *testing.T) {
+	delta, err := parseStateDelta("Here is the update:\n```json\n{\"goals\": [\"Ship v2\"], \"resolved_issues\": [2]}\n```")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(delta.Goals) != 1 || delta.Goals[0] != "Ship v2" || len(delta.ResolvedIssues) != 1 || delta.ResolvedIssues[0] != 2 {
+		t.Errorf("unexpected delta %+v", delta)
+	}
+	if _, err := parseStateDelta("- Ship v2"); err == nil {
+		t.Error("expected an error without JSON")
+	}
+}
@@ -0,0 +1,282 @@
+// Package hooks runs user-configured shell commands on agent events.
+//
+// Each hook command runs through sh with the event as JSON on stdin. Exit
+// status 0 lets the agent continue; the command may print a JSON Reply to
+// block, rewrite the tool input or add context, and any other output is
+// added as context. Exit status 2 blocks, with stderr as the reason. Other
+// failures are reported as warnings and never stop the agent.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/abdul-hamid-achik/vecai/internal/config"
+)
+
+// Events hooks can be configured for.
+const (
+	PreToolUse       = "PreToolUse"       // Before a tool runs; may block it or rewrite its input
+	PostToolUse      = "PostToolUse"      // After a tool ran; may add to its result
+	UserPromptSubmit = "UserPromptSubmit" // Before a prompt is sent; may block it or add context
+	SessionStart     = "SessionStart"     // Before the first prompt; may add context
+	Stop             = "Stop"             // When the model is done; blocking makes it continue
+	PreCompact       = "PreCompact"       // Before the conversation is compacted; may block it
+)
+
+// Events lists every event name in the order they occur.
+var Events = []string{SessionStart, UserPromptSubmit, PreToolUse, PostToolUse, Stop, PreCompact}
+
+// Compaction triggers sent with PreCompact.
+const (
+	TriggerAuto   = "auto"
+	TriggerManual = "manual"
+)
+
+// DefaultTimeout bounds a hook that sets no timeout.
+const DefaultTimeout = 60 * time.Second
+
+// blockStatus is the exit status that blocks the event.
+const blockStatus = 2
+
+// maxContext bounds the context a single hook can add.
+const maxContext = 10000
+
+// Input is the event sent to a hook on stdin.
+type Input struct {
+	Event          string         `json:"event"`
+	SessionID      string         `json:"session_id,omitempty"`
+	Cwd            string         `json:"cwd"`
+	Tool           string         `json:"tool,omitempty"`
+	ToolCallID     string         `json:"tool_call_id,omitempty"`
+	ToolInput      map[string]any `json:"tool_input,omitempty"`
+	Result         string         `json:"result,omitempty"`           // PostToolUse
+	IsError        bool           `json:"is_error,omitempty"`         // PostToolUse
+	Prompt         string         `json:"prompt,omitempty"`           // UserPromptSubmit, SessionStart
+	Response       string         `json:"response,omitempty"`         // Stop: the model's final text
+	StopHookActive bool           `json:"stop_hook_active,omitempty"` // Stop: a Stop hook already kept the agent going
+	Trigger        string         `json:"trigger,omitempty"`          // PreCompact: auto or manual
+}
+
+// Reply is the optional JSON a hook prints on stdout when it exits 0.
+type Reply struct {
+	Decision          string         `json:"decision,omitempty"` // "block" to block the event
+	Reason            string         `json:"reason,omitempty"`
+	UpdatedInput      map[string]any `json:"updated_input,omitempty"` // PreToolUse: replaces the tool input
+	AdditionalContext string         `json:"additional_context,omitempty"`
+}
+
+// Outcome is the combined result of the hooks run for one event.
+type Outcome struct {
+	Blocked      bool
+	Reason       string         // Why the event was blocked
+	UpdatedInput map[string]any // Tool input rewritten by PreToolUse hooks, nil if unchanged
+	Context      string         // Text the hooks added for the model
+	Warnings     []string       // Hooks that failed
+}
+
+type hook struct {
+	matchers []string
+	command  string
+	timeout  time.Duration
+}
+
+// matches reports whether the hook applies to a tool; hooks without a
+// matcher apply to every tool and to events without one.
+func (h hook) matches(tool string) bool {
+	if len(h.matchers) == 0 {
+		return true
+	}
+	for _, m := range h.matchers {
+		if ok, _ := path.Match(m, tool); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Runner runs the hooks configured for each event. A nil Runner runs
+// nothing.
+type Runner struct {
+	hooks map[string][]hook
+}
+
+// New validates the configured hooks. It returns nil when none are
+// configured.
+func New(cfg map[string][]config.HookConfig) (*Runner, error) {
+	r := &Runner{hooks: make(map[string][]hook)}
+	var errs []error
+	for _, event := range sortedKeys(cfg) {
+		if !validEvent(event) {
+			errs = append(errs, fmt.Errorf("unknown hook event %q (want one of %s)", event, strings.Join(Events, ", ")))
+			continue
+		}
+		for i, hc := range cfg[event] {
+			h, err := newHook(hc)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("hooks.%s[%d]: %w", event, i, err))
+				continue
+			}
+			r.hooks[event] = append(r.hooks[event], h)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	if len(r.hooks) == 0 {
+		return nil, nil
+	}
+	return r, nil
+}
+
+func newHook(hc config.HookConfig) (hook, error) {
+	h := hook{command: strings.TrimSpace(hc.Command), timeout: hc.Timeout}
+	if h.command == "" {
+		return h, errors.New("command is required")
+	}
+	if h.timeout <= 0 {
+		h.timeout = DefaultTimeout
+	}
+	all := false
+	for _, m := range strings.Split(hc.Matcher, "|") {
+		m = strings.TrimSpace(m)
+		if m == "" {
+			continue
+		}
+		if _, err := path.Match(m, ""); err != nil {
+			return h, fmt.Errorf("invalid matcher %q: %w", m, err)
+		}
+		all = all || m == "*"
+		h.matchers = append(h.matchers, m)
+	}
+	if all {
+		h.matchers = nil
+	}
+	return h, nil
+}
+
+func validEvent(event string) bool {
+	for _, e := range Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string][]config.HookConfig) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Has reports whether any hook is configured for event.
+func (r *Runner) Has(event string) bool {
+	return r != nil && len(r.hooks[event]) > 0
+}
+
+// Run runs the hooks for in.Event whose matcher accepts in.Tool, in
+// configuration order, and stops at the first one that blocks. Input
+// rewritten by one PreToolUse hook is passed to the next.
+func (r *Runner) Run(ctx context.Context, in Input) Outcome {
+	var out Outcome
+	if !r.Has(in.Event) {
+		return out
+	}
+	if in.Cwd == "" {
+		in.Cwd, _ = os.Getwd()
+	}
+
+	var contexts []string
+	for _, h := range r.hooks[in.Event] {
+		if in.Tool != "" && !h.matches(in.Tool) {
+			continue
+		}
+		reply, err := h.run(ctx, in)
+		if err != nil {
+			out.Warnings = append(out.Warnings, fmt.Sprintf("%s hook %q: %v", in.Event, h.command, err))
+			continue
+		}
+		if c := strings.TrimSpace(reply.AdditionalContext); c != "" {
+			contexts = append(contexts, truncate(c))
+		}
+		if reply.UpdatedInput != nil && in.Event == PreToolUse {
+			in.ToolInput = reply.UpdatedInput
+			out.UpdatedInput = reply.UpdatedInput
+		}
+		if reply.Decision == "block" {
+			out.Blocked = true
+			out.Reason = reply.Reason
+			if out.Reason == "" {
+				out.Reason = fmt.Sprintf("blocked by %s hook", in.Event)
+			}
+			break
+		}
+	}
+	out.Context = strings.Join(contexts, "\n\n")
+	return out
+}
+
+// run runs one hook and turns its exit status and output into a Reply.
+func (h hook) run(ctx context.Context, in Input) (Reply, error) {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return Reply{}, fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", h.command)
+	cmd.Stdin = bytes.NewReader(data)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// Children of sh may keep the output pipes open after it is killed
+	cmd.WaitDelay = time.Second
+
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return parseReply(stdout.Bytes()), nil
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return Reply{}, fmt.Errorf("timed out after %s", h.timeout)
+	case ctx.Err() != nil:
+		return Reply{}, ctx.Err()
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == blockStatus:
+		return Reply{Decision: "block", Reason: strings.TrimSpace(stderr.String())}, nil
+	}
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return Reply{}, fmt.Errorf("%w: %s", err, truncate(msg))
+	}
+	return Reply{}, err
+}
+
+// parseReply reads a JSON reply, treating any other output as context.
+func parseReply(stdout []byte) Reply {
+	text := bytes.TrimSpace(stdout)
+	var reply Reply
+	if len(text) > 0 && text[0] == '{' && json.Unmarshal(text, &reply) == nil {
+		return reply
+	}
+	return Reply{AdditionalContext: string(text)}
+}
+
+func truncate(s string) string {
+	if len(s) <= maxContext {
+		return s
+	}
+	return s[:maxContext] + "\n... (hook output truncated)"
+}
@@ -0,0 +1,127 @@
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abdul-hamid-achik/vecai/internal/config"
+)
+
+func newRunner(t *testing.T, cfg map[string][]config.HookConfig) *Runner {
+	t.Helper()
+	r, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestNew(t *testing.T) {
+	if r, err := New(nil); r != nil || err != nil {
+		t.Errorf("expected no runner without hooks, got %v, %v", r, err)
+	}
+	var r *Runner
+	if r.Has(PreToolUse) || r.Run(context.Background(), Input{Event: PreToolUse}).Blocked {
+		t.Error("expected a nil runner to run nothing")
+	}
+
+	_, err := New(map[string][]config.HookConfig{
+		"PreToolUse": {{Command: ""}, {Command: "true", Matcher: "[write"}},
+		"BeforeTool": {{Command: "true"}},
+	})
+	if err == nil {
+		t.Fatal("expected invalid hooks to fail")
+	}
+	for _, want := range []string{"hooks.PreToolUse[0]: command is required", "hooks.PreToolUse[1]: invalid matcher", `unknown hook event "BeforeTool"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %v", want, err)
+		}
+	}
+}
+
+func TestRun_SendsEventAndMatchesTools(t *testing.T) {
+	eventFile := filepath.Join(t.TempDir(), "event.json")
+	r := newRunner(t, map[string][]config.HookConfig{
+		PreToolUse: {
+			{Matcher: "write_file|edit_*", Command: `cat > "` + eventFile + `"`},
+			{Matcher: "bash", Command: "echo bash only"},
+		},
+	})
+
+	out := r.Run(context.Background(), Input{Event: PreToolUse, Tool: "edit_file", ToolInput: map[string]any{"path": "a.go"}})
+	if out.Blocked || out.Context != "" || len(out.Warnings) > 0 {
+		t.Errorf("unexpected outcome %+v", out)
+	}
+	data, err := os.ReadFile(eventFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var in Input
+	if err := json.Unmarshal(data, &in); err != nil {
+		t.Fatalf("event is not JSON: %s", data)
+	}
+	wd, _ := os.Getwd()
+	if in.Event != PreToolUse || in.Tool != "edit_file" || in.ToolInput["path"] != "a.go" || in.Cwd != wd {
+		t.Errorf("unexpected event %+v", in)
+	}
+
+	if out := r.Run(context.Background(), Input{Event: PreToolUse, Tool: "bash"}); out.Context != "bash only" {
+		t.Errorf("expected plain output as context, got %+v", out)
+	}
+	if out := r.Run(context.Background(), Input{Event: PreToolUse, Tool: "read_file"}); out.Context != "" {
+		t.Errorf("expected no hook to match read_file, got %+v", out)
+	}
+}
+
+func TestRun_Replies(t *testing.T) {
+	r := newRunner(t, map[string][]config.HookConfig{
+		PreToolUse: {
+			{Command: `echo '{"updated_input": {"path": "safe.go"}, "additional_context": "rewrote path"}'`},
+			{Command: `grep -q '"path":"safe.go"' && echo '{"additional_context": "saw rewrite"}'`},
+			{Matcher: "write_file", Command: `echo "no writes to migrations" >&2; exit 2`},
+			{Matcher: "write_file", Command: "echo never runs"},
+		},
+		PostToolUse: {
+			{Command: "exit 1"},
+			{Command: "echo boom >&2; exit 3"},
+			{Command: `echo '{"decision": "block"}'`},
+		},
+	})
+
+	out := r.Run(context.Background(), Input{Event: PreToolUse, Tool: "edit_file", ToolInput: map[string]any{"path": "a.go"}})
+	if out.Blocked || out.UpdatedInput["path"] != "safe.go" || out.Context != "rewrote path\n\nsaw rewrite" {
+		t.Errorf("unexpected outcome %+v", out)
+	}
+
+	out = r.Run(context.Background(), Input{Event: PreToolUse, Tool: "write_file"})
+	if !out.Blocked || out.Reason != "no writes to migrations" || strings.Contains(out.Context, "never runs") {
+		t.Errorf("expected exit 2 to block, got %+v", out)
+	}
+
+	out = r.Run(context.Background(), Input{Event: PostToolUse, Tool: "bash"})
+	if len(out.Warnings) != 2 || !strings.Contains(out.Warnings[1], "exit status 3: boom") {
+		t.Errorf("expected failing hooks to warn, got %+v", out.Warnings)
+	}
+	if !out.Blocked || out.Reason != "blocked by PostToolUse hook" {
+		t.Errorf("expected a default reason, got %+v", out)
+	}
+}
+
+func TestRun_Timeout(t *testing.T) {
+	r := newRunner(t, map[string][]config.HookConfig{
+		Stop: {{Command: "sleep 5", Timeout: 50 * time.Millisecond}},
+	})
+	start := time.Now()
+	out := r.Run(context.Background(), Input{Event: Stop})
+	if out.Blocked || len(out.Warnings) != 1 || !strings.Contains(out.Warnings[0], "timed out") {
+		t.Errorf("expected a timeout warning, got %+v", out)
+	}
+	if time.Since(start) > 3*time.Second {
+		t.Error("expected the hook to be killed at its timeout")
+	}
+}
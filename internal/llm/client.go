@@ -2,6 +2,7 @@ package llm
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/abdul-hamid-achik/vecai/internal/config"
 )
@@ -87,3 +88,16 @@ type Client = OllamaClient
 func NewClient(cfg *config.Config) *Client {
 	return NewOllamaClient(cfg)
 }
+
+// NewProviderClient creates the LLM client for cfg.Provider.
+// An empty provider defaults to Ollama.
+func NewProviderClient(cfg *config.Config) (LLMClient, error) {
+	switch cfg.Provider {
+	case "", config.ProviderOllama:
+		return NewOllamaClient(cfg), nil
+	case config.ProviderOpenAI:
+		return NewOpenAIClient(cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown LLM provider %q (supported: ollama, openai)", cfg.Provider)
+	}
+}
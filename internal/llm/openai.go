@@ -0,0 +1,569 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abdul-hamid-achik/vecai/internal/config"
+	"github.com/abdul-hamid-achik/vecai/internal/debug"
+	vecerr "github.com/abdul-hamid-achik/vecai/internal/errors"
+	"github.com/abdul-hamid-achik/vecai/internal/logging"
+)
+
+// ErrOpenAIUnavailable is returned when the OpenAI-compatible server cannot be reached.
+var ErrOpenAIUnavailable = errors.New("openai-compatible server unavailable - check openai.base_url")
+
+// OpenAIClient implements LLMClient for servers exposing the OpenAI
+// /v1/chat/completions API (llama.cpp server, vLLM, LM Studio, ...).
+type OpenAIClient struct {
+	baseURL    string
+	apiKey     string
+	model      string
+	modelMu    sync.RWMutex // Protects model field from concurrent access
+	config     *config.Config
+	httpClient *http.Client
+	ownsHTTP   bool // false for forks, which share the parent's transport
+}
+
+// openAIMessage represents a message in OpenAI's chat format
+type openAIMessage struct {
+	Role       string           `json:"role"`
+	Content    string           `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+// openAIToolCall represents a tool call. In streaming deltas Index identifies
+// which call a fragment belongs to; arguments arrive as partial JSON strings.
+type openAIToolCall struct {
+	Index    *int   `json:"index,omitempty"`
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Function struct {
+		Name      string `json:"name,omitempty"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// openAITool represents a tool definition
+type openAITool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string         `json:"name"`
+		Description string         `json:"description"`
+		Parameters  map[string]any `json:"parameters"`
+	} `json:"function"`
+}
+
+// openAIStreamOptions requests a final usage chunk when streaming
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// openAIChatRequest represents a /chat/completions request
+type openAIChatRequest struct {
+	Model         string               `json:"model"`
+	Messages      []openAIMessage      `json:"messages"`
+	Tools         []openAITool         `json:"tools,omitempty"`
+	Stream        bool                 `json:"stream"`
+	StreamOptions *openAIStreamOptions `json:"stream_options,omitempty"`
+	Temperature   float64              `json:"temperature"`
+	MaxTokens     int                  `json:"max_tokens,omitempty"`
+}
+
+// openAIUsage represents token usage
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// openAIDelta is the incremental message in a streamed choice. Reasoning
+// models served by llama.cpp and vLLM put their thinking in ReasoningContent.
+type openAIDelta struct {
+	Role             string           `json:"role,omitempty"`
+	Content          string           `json:"content,omitempty"`
+	ReasoningContent string           `json:"reasoning_content,omitempty"`
+	ToolCalls        []openAIToolCall `json:"tool_calls,omitempty"`
+}
+
+// openAIChoice is a single completion choice (Message for non-streaming, Delta for streaming)
+type openAIChoice struct {
+	Index        int         `json:"index"`
+	Message      openAIDelta `json:"message"`
+	Delta        openAIDelta `json:"delta"`
+	FinishReason string      `json:"finish_reason,omitempty"`
+}
+
+// openAIChatResponse represents a /chat/completions response or stream chunk
+type openAIChatResponse struct {
+	Model   string         `json:"model"`
+	Choices []openAIChoice `json:"choices"`
+	Usage   *openAIUsage   `json:"usage,omitempty"`
+	Error   *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// NewOpenAIClient creates a new client for an OpenAI-compatible server
+func NewOpenAIClient(cfg *config.Config) *OpenAIClient {
+	baseURL := strings.TrimRight(cfg.OpenAI.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = "http://localhost:8080/v1"
+	}
+
+	return &OpenAIClient{
+		baseURL: baseURL,
+		apiKey:  cfg.OpenAI.APIKey,
+		model:   cfg.GetDefaultModel(),
+		config:  cfg,
+		httpClient: &http.Client{
+			Transport: &http.Transport{
+				MaxIdleConns:        10,
+				MaxIdleConnsPerHost: 5,
+				IdleConnTimeout:     90 * time.Second,
+			},
+		},
+		ownsHTTP: true,
+	}
+}
+
+// SetModel changes the current model (thread-safe)
+func (c *OpenAIClient) SetModel(model string) {
+	c.modelMu.Lock()
+	defer c.modelMu.Unlock()
+	c.model = model
+}
+
+// SetTier changes the model tier (thread-safe)
+func (c *OpenAIClient) SetTier(tier config.ModelTier) {
+	c.modelMu.Lock()
+	defer c.modelMu.Unlock()
+	c.model = c.config.GetModel(tier)
+}
+
+// GetModel returns the current model (thread-safe)
+func (c *OpenAIClient) GetModel() string {
+	c.modelMu.RLock()
+	defer c.modelMu.RUnlock()
+	return c.model
+}
+
+// Fork returns a client sharing this client's HTTP transport but with an independent model field.
+func (c *OpenAIClient) Fork() LLMClient {
+	return &OpenAIClient{
+		baseURL:    c.baseURL,
+		apiKey:     c.apiKey,
+		model:      c.GetModel(),
+		config:     c.config,
+		httpClient: c.httpClient,
+	}
+}
+
+// Close closes idle HTTP connections. Forks do not own the transport, so Close is a no-op for them.
+func (c *OpenAIClient) Close() error {
+	if c.ownsHTTP {
+		c.httpClient.CloseIdleConnections()
+	}
+	return nil
+}
+
+// CheckHealth verifies the server is reachable by listing its models
+func (c *OpenAIClient) CheckHealth(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/models", nil)
+	if err != nil {
+		return err
+	}
+	c.setHeaders(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return vecerr.LLMUnavailable(ErrOpenAIUnavailable)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return vecerr.LLMUnavailable(ErrOpenAIUnavailable)
+	}
+
+	return nil
+}
+
+// Chat sends a message and returns the response
+func (c *OpenAIClient) Chat(ctx context.Context, messages []Message, tools []ToolDefinition, systemPrompt string) (*Response, error) {
+	// Per-request timeout for non-streaming calls
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	// Snapshot model name under lock for consistent use throughout this call
+	currentModel := c.GetModel()
+
+	requestID := debug.GenerateRequestID()
+	debug.LLMRequest(requestID, currentModel, len(messages), len(tools))
+	startTime := time.Now()
+
+	resp, err := c.send(ctx, c.buildRequest(ctx, currentModel, messages, tools, systemPrompt, false))
+	if err != nil {
+		debug.LLMResponse(requestID, time.Since(startTime).Milliseconds(), 0, err)
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, vecerr.LLMRequestFailed(fmt.Errorf("failed to read response: %w", err))
+	}
+
+	var chatResp openAIChatResponse
+	if err := json.Unmarshal(respBody, &chatResp); err != nil {
+		return nil, vecerr.LLMRequestFailed(fmt.Errorf("failed to parse response: %w", err))
+	}
+
+	if chatResp.Error != nil {
+		err := vecerr.LLMRequestFailed(fmt.Errorf("openai error: %s", chatResp.Error.Message))
+		debug.LLMResponse(requestID, time.Since(startTime).Milliseconds(), 0, err)
+		return nil, err
+	}
+
+	totalTokens := 0
+	if chatResp.Usage != nil {
+		totalTokens = chatResp.Usage.PromptTokens + chatResp.Usage.CompletionTokens
+	}
+	debug.LLMResponse(requestID, time.Since(startTime).Milliseconds(), totalTokens, nil)
+
+	return parseOpenAIResponse(&chatResp), nil
+}
+
+// ChatStream sends a message and streams the response
+func (c *OpenAIClient) ChatStream(ctx context.Context, messages []Message, tools []ToolDefinition, systemPrompt string) <-chan StreamChunk {
+	ch := make(chan StreamChunk, 100)
+
+	// Snapshot model name under lock for consistent use throughout this call
+	currentModel := c.GetModel()
+
+	// Generate request ID for tracing (before goroutine to ensure consistent ID)
+	requestID := debug.GenerateRequestID()
+
+	go func() {
+		defer close(ch)
+
+		// Add a default timeout if context has no deadline
+		if _, hasDeadline := ctx.Deadline(); !hasDeadline {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, 10*time.Minute)
+			defer cancel()
+		}
+
+		debug.LLMRequest(requestID, currentModel, len(messages), len(tools))
+		startTime := time.Now()
+
+		resp, err := c.send(ctx, c.buildRequest(ctx, currentModel, messages, tools, systemPrompt, true))
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				return // Clean exit on cancellation
+			}
+			ch <- StreamChunk{Type: "error", Error: err}
+			return
+		}
+		defer func() { _ = resp.Body.Close() }()
+
+		processOpenAIStream(ctx, resp.Body, ch, requestID, startTime)
+	}()
+
+	return ch
+}
+
+// buildRequest assembles a chat completion request for the given model
+func (c *OpenAIClient) buildRequest(ctx context.Context, model string, messages []Message, tools []ToolDefinition, systemPrompt string, stream bool) *openAIChatRequest {
+	temperature := c.config.Temperature
+	if override, ok := GetTemperature(ctx); ok {
+		temperature = override
+	}
+
+	request := &openAIChatRequest{
+		Model:       model,
+		Messages:    buildOpenAIMessages(messages, systemPrompt),
+		Tools:       buildOpenAITools(tools),
+		Stream:      stream,
+		Temperature: temperature,
+		MaxTokens:   c.config.MaxTokens,
+	}
+	if stream {
+		request.StreamOptions = &openAIStreamOptions{IncludeUsage: true}
+	}
+	return request
+}
+
+// send POSTs the request to /chat/completions and maps HTTP failures to vecai errors.
+// On success the caller owns the response body.
+func (c *OpenAIClient) send(ctx context.Context, request *openAIChatRequest) (*http.Response, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	c.setHeaders(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+		return nil, vecerr.LLMRequestFailed(fmt.Errorf("openai request failed: %w", err))
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, vecerr.LLMModelNotFound(request.Model)
+		}
+		return nil, vecerr.LLMRequestFailed(fmt.Errorf("openai server returned status %d: %s", resp.StatusCode, string(respBody)))
+	}
+
+	return resp, nil
+}
+
+// setHeaders applies content type and optional bearer auth
+func (c *OpenAIClient) setHeaders(req *http.Request) {
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+}
+
+// buildOpenAIMessages converts internal messages to OpenAI format
+func buildOpenAIMessages(messages []Message, systemPrompt string) []openAIMessage {
+	var out []openAIMessage
+
+	if systemPrompt != "" {
+		out = append(out, openAIMessage{Role: "system", Content: systemPrompt})
+	}
+
+	for _, msg := range messages {
+		om := openAIMessage{
+			Role:    msg.Role,
+			Content: msg.Content,
+		}
+		if msg.Role == "tool" && msg.ToolCallID != "" {
+			om.ToolCallID = msg.ToolCallID
+		}
+		for _, tc := range msg.ToolCalls {
+			args, _ := json.Marshal(tc.Input)
+			otc := openAIToolCall{
+				ID:   tc.ID,
+				Type: "function",
+			}
+			otc.Function.Name = tc.Name
+			otc.Function.Arguments = string(args)
+			om.ToolCalls = append(om.ToolCalls, otc)
+		}
+		out = append(out, om)
+	}
+
+	return out
+}
+
+// buildOpenAITools converts internal tool definitions to OpenAI format
+func buildOpenAITools(tools []ToolDefinition) []openAITool {
+	if len(tools) == 0 {
+		return nil
+	}
+
+	out := make([]openAITool, len(tools))
+	for i, tool := range tools {
+		out[i].Type = "function"
+		out[i].Function.Name = tool.Name
+		out[i].Function.Description = tool.Description
+		out[i].Function.Parameters = tool.InputSchema
+	}
+
+	return out
+}
+
+// parseOpenAIResponse converts a non-streaming response to internal format
+func parseOpenAIResponse(resp *openAIChatResponse) *Response {
+	result := &Response{}
+	if len(resp.Choices) == 0 {
+		return result
+	}
+
+	choice := resp.Choices[0]
+	result.Content = choice.Message.Content
+	result.Thinking = choice.Message.ReasoningContent
+	result.StopReason = choice.FinishReason
+
+	for i, tc := range choice.Message.ToolCalls {
+		result.ToolCalls = append(result.ToolCalls, toOpenAIToolCall(i, tc.ID, tc.Function.Name, tc.Function.Arguments))
+	}
+
+	return result
+}
+
+// toOpenAIToolCall builds an internal ToolCall, recording argument parse failures
+// for retry feedback. Servers that omit call IDs get a positional fallback.
+func toOpenAIToolCall(index int, id, name, arguments string) ToolCall {
+	if id == "" {
+		id = fmt.Sprintf("call_%d", index)
+	}
+
+	input, parseErr := parseToolArguments(json.RawMessage(arguments))
+	var parseErrStr string
+	if parseErr != nil {
+		if log := logging.Global(); log != nil {
+			log.Warn("failed to parse tool arguments",
+				logging.ToolName(name),
+				logging.Error(parseErr),
+			)
+		}
+		input = make(map[string]any)
+		parseErrStr = parseErr.Error()
+	}
+
+	return ToolCall{
+		ID:         id,
+		Name:       name,
+		Input:      input,
+		ParseError: parseErrStr,
+	}
+}
+
+// pendingToolCall accumulates a streamed tool call across deltas
+type pendingToolCall struct {
+	id   string
+	name string
+	args strings.Builder
+}
+
+// processOpenAIStream reads the SSE stream, emitting text/thinking chunks as they
+// arrive and tool calls once their argument fragments are complete.
+func processOpenAIStream(ctx context.Context, reader io.Reader, ch chan<- StreamChunk, requestID string, startTime time.Time) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+
+	pending := make(map[int]*pendingToolCall)
+	var usage *Usage
+
+	flushToolCalls := func() {
+		indexes := make([]int, 0, len(pending))
+		for idx := range pending {
+			indexes = append(indexes, idx)
+		}
+		sort.Ints(indexes)
+		for _, idx := range indexes {
+			p := pending[idx]
+			toolCall := toOpenAIToolCall(idx, p.id, p.name, p.args.String())
+			ch <- StreamChunk{Type: "tool_call", ToolCall: &toolCall}
+		}
+		pending = make(map[int]*pendingToolCall)
+	}
+
+	finish := func() {
+		flushToolCalls()
+		if usage == nil {
+			usage = &Usage{}
+		}
+		debug.LLMResponse(requestID, time.Since(startTime).Milliseconds(), int(usage.InputTokens+usage.OutputTokens), nil)
+		ch <- StreamChunk{Type: "done", Usage: usage}
+	}
+
+	for scanner.Scan() {
+		select {
+		case <-ctx.Done():
+			debug.LLMResponse(requestID, time.Since(startTime).Milliseconds(), 0, ctx.Err())
+			return
+		default:
+		}
+
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue // blank separators, comments and event: lines
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			finish()
+			return
+		}
+
+		var chunk openAIChatResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			if log := logging.Global(); log != nil {
+				log.Error("stream decode error", logging.Error(err))
+			}
+			debug.LLMResponse(requestID, time.Since(startTime).Milliseconds(), 0, err)
+			ch <- StreamChunk{Type: "error", Error: err}
+			return
+		}
+
+		if chunk.Error != nil {
+			err := vecerr.LLMRequestFailed(fmt.Errorf("openai error: %s", chunk.Error.Message))
+			debug.LLMResponse(requestID, time.Since(startTime).Milliseconds(), 0, err)
+			ch <- StreamChunk{Type: "error", Error: err}
+			return
+		}
+
+		if chunk.Usage != nil {
+			usage = &Usage{
+				InputTokens:  int64(chunk.Usage.PromptTokens),
+				OutputTokens: int64(chunk.Usage.CompletionTokens),
+			}
+		}
+
+		for _, choice := range chunk.Choices {
+			if choice.Delta.ReasoningContent != "" {
+				ch <- StreamChunk{Type: "thinking", Text: choice.Delta.ReasoningContent}
+			}
+			if choice.Delta.Content != "" {
+				ch <- StreamChunk{Type: "text", Text: choice.Delta.Content}
+			}
+			for i, tc := range choice.Delta.ToolCalls {
+				idx := i
+				if tc.Index != nil {
+					idx = *tc.Index
+				}
+				p, ok := pending[idx]
+				if !ok {
+					p = &pendingToolCall{}
+					pending[idx] = p
+				}
+				if tc.ID != "" {
+					p.id = tc.ID
+				}
+				if tc.Function.Name != "" {
+					p.name = tc.Function.Name
+				}
+				p.args.WriteString(tc.Function.Arguments)
+			}
+			if choice.FinishReason != "" {
+				// Usage (if requested) follows in a separate chunk before [DONE]
+				flushToolCalls()
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, context.Canceled) || ctx.Err() != nil {
+			debug.LLMResponse(requestID, time.Since(startTime).Milliseconds(), 0, err)
+			return
+		}
+		debug.LLMResponse(requestID, time.Since(startTime).Milliseconds(), 0, err)
+		ch <- StreamChunk{Type: "error", Error: vecerr.LLMRequestFailed(fmt.Errorf("stream read failed: %w", err))}
+		return
+	}
+
+	// Some servers close the stream without a [DONE] sentinel
+	finish()
+}
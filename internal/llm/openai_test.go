@@ -0,0 +1,268 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/abdul-hamid-achik/vecai/internal/config"
+)
+
+// newTestOpenAIClient creates an OpenAIClient pointing at the given base URL.
+func newTestOpenAIClient(baseURL string) *OpenAIClient {
+	cfg := config.DefaultConfig()
+	cfg.Provider = config.ProviderOpenAI
+	cfg.OpenAI.BaseURL = baseURL
+	cfg.OpenAI.ModelFast = "small"
+	cfg.OpenAI.ModelSmart = "medium"
+	cfg.OpenAI.ModelGenius = "large"
+	return NewOpenAIClient(cfg)
+}
+
+func TestNewProviderClient(t *testing.T) {
+	cfg := config.DefaultConfig()
+	c, err := NewProviderClient(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := c.(*OllamaClient); !ok {
+		t.Errorf("expected *OllamaClient for default provider, got %T", c)
+	}
+
+	cfg.Provider = config.ProviderOpenAI
+	c, err = NewProviderClient(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := c.(*OpenAIClient); !ok {
+		t.Errorf("expected *OpenAIClient for openai provider, got %T", c)
+	}
+
+	cfg.Provider = "bogus"
+	if _, err := NewProviderClient(cfg); err == nil {
+		t.Error("expected error for unknown provider")
+	}
+}
+
+func TestOpenAI_SetTierAndFork(t *testing.T) {
+	c := newTestOpenAIClient("http://unused")
+
+	c.SetTier(config.TierGenius)
+	if got := c.GetModel(); got != "large" {
+		t.Errorf("expected model large, got %q", got)
+	}
+
+	fork := c.Fork()
+	fork.SetTier(config.TierFast)
+	if fork.GetModel() != "small" {
+		t.Errorf("expected fork model small, got %q", fork.GetModel())
+	}
+	if c.GetModel() != "large" {
+		t.Errorf("fork should not change parent model, got %q", c.GetModel())
+	}
+	if err := fork.Close(); err != nil {
+		t.Errorf("fork Close returned error: %v", err)
+	}
+}
+
+func TestBuildOpenAIMessages_ToolCallArgumentsAreStrings(t *testing.T) {
+	msgs := buildOpenAIMessages([]Message{
+		{Role: "assistant", ToolCalls: []ToolCall{{ID: "call_1", Name: "read_file", Input: map[string]any{"path": "main.go"}}}},
+		{Role: "tool", Content: "package main", ToolCallID: "call_1"},
+	}, "sys")
+
+	if len(msgs) != 3 {
+		t.Fatalf("expected 3 messages, got %d", len(msgs))
+	}
+	if msgs[0].Role != "system" {
+		t.Errorf("expected system message first, got %q", msgs[0].Role)
+	}
+	if got := msgs[1].ToolCalls[0].Function.Arguments; got != `{"path":"main.go"}` {
+		t.Errorf("unexpected arguments %q", got)
+	}
+	if msgs[2].ToolCallID != "call_1" {
+		t.Errorf("expected tool_call_id call_1, got %q", msgs[2].ToolCallID)
+	}
+}
+
+func TestOpenAI_Chat(t *testing.T) {
+	var gotReq openAIChatRequest
+	var gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		gotAuth = r.Header.Get("Authorization")
+		_ = json.NewDecoder(r.Body).Decode(&gotReq)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{
+			"model": "medium",
+			"choices": [{
+				"index": 0,
+				"finish_reason": "tool_calls",
+				"message": {
+					"role": "assistant",
+					"content": "",
+					"tool_calls": [{"id": "call_x", "type": "function", "function": {"name": "grep", "arguments": "{\"pattern\":\"TODO\"}"}}]
+				}
+			}],
+			"usage": {"prompt_tokens": 12, "completion_tokens": 3}
+		}`))
+	}))
+	defer server.Close()
+
+	c := newTestOpenAIClient(server.URL + "/v1")
+	c.apiKey = "secret"
+	c.SetTier(config.TierSmart)
+
+	resp, err := c.Chat(context.Background(), []Message{{Role: "user", Content: "hi"}},
+		[]ToolDefinition{{Name: "grep", Description: "search", InputSchema: map[string]any{"type": "object"}}}, "")
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+
+	if gotReq.Model != "medium" || gotReq.Stream {
+		t.Errorf("unexpected request model=%q stream=%v", gotReq.Model, gotReq.Stream)
+	}
+	if len(gotReq.Tools) != 1 || gotReq.Tools[0].Function.Name != "grep" {
+		t.Errorf("expected grep tool in request, got %+v", gotReq.Tools)
+	}
+	if gotAuth != "Bearer secret" {
+		t.Errorf("expected bearer auth, got %q", gotAuth)
+	}
+	if resp.StopReason != "tool_calls" {
+		t.Errorf("expected stop reason tool_calls, got %q", resp.StopReason)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Input["pattern"] != "TODO" {
+		t.Fatalf("unexpected tool calls %+v", resp.ToolCalls)
+	}
+}
+
+func TestOpenAI_ChatModelNotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	c := newTestOpenAIClient(server.URL)
+	if _, err := c.Chat(context.Background(), nil, nil, ""); err == nil {
+		t.Fatal("expected error for 404")
+	}
+}
+
+func TestOpenAI_ChatStream(t *testing.T) {
+	var gotReq openAIChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&gotReq)
+		w.Header().Set("Content-Type", "text/event-stream")
+		events := []string{
+			`{"choices":[{"index":0,"delta":{"role":"assistant","reasoning_content":"hmm"}}]}`,
+			`{"choices":[{"index":0,"delta":{"content":"Let me "}}]}`,
+			`{"choices":[{"index":0,"delta":{"content":"look."}}]}`,
+			`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_a","type":"function","function":{"name":"read_file","arguments":"{\"pa"}}]}}]}`,
+			`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"th\":\"a.go\"}"}}]}}]}`,
+			`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"id":"call_b","type":"function","function":{"name":"list_files","arguments":"{}"}}]}}]}`,
+			`{"choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+			`{"choices":[],"usage":{"prompt_tokens":40,"completion_tokens":7}}`,
+		}
+		for _, e := range events {
+			_, _ = fmt.Fprintf(w, "data: %s\n\n", e)
+		}
+		_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	c := newTestOpenAIClient(server.URL)
+
+	var text, thinking string
+	var calls []ToolCall
+	var usage *Usage
+	for chunk := range c.ChatStream(context.Background(), []Message{{Role: "user", Content: "read a.go"}}, nil, "") {
+		switch chunk.Type {
+		case "text":
+			text += chunk.Text
+		case "thinking":
+			thinking += chunk.Text
+		case "tool_call":
+			calls = append(calls, *chunk.ToolCall)
+		case "done":
+			usage = chunk.Usage
+		case "error":
+			t.Fatalf("unexpected error chunk: %v", chunk.Error)
+		}
+	}
+
+	if !gotReq.Stream || gotReq.StreamOptions == nil || !gotReq.StreamOptions.IncludeUsage {
+		t.Errorf("expected streaming request with include_usage, got %+v", gotReq)
+	}
+	if text != "Let me look." {
+		t.Errorf("unexpected text %q", text)
+	}
+	if thinking != "hmm" {
+		t.Errorf("unexpected thinking %q", thinking)
+	}
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 tool calls, got %d", len(calls))
+	}
+	if calls[0].ID != "call_a" || calls[0].Name != "read_file" || calls[0].Input["path"] != "a.go" {
+		t.Errorf("unexpected first tool call %+v", calls[0])
+	}
+	if calls[1].Name != "list_files" || calls[1].ParseError != "" {
+		t.Errorf("unexpected second tool call %+v", calls[1])
+	}
+	if usage == nil || usage.InputTokens != 40 || usage.OutputTokens != 7 {
+		t.Errorf("unexpected usage %+v", usage)
+	}
+}
+
+func TestOpenAI_ChatStreamMalformedArguments(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"name":"bash","arguments":"{not json"}}]},"finish_reason":"tool_calls"}]}`+"\n\n")
+		// No [DONE]: the client must still finish cleanly on EOF
+	}))
+	defer server.Close()
+
+	c := newTestOpenAIClient(server.URL)
+
+	var calls []ToolCall
+	done := false
+	for chunk := range c.ChatStream(context.Background(), nil, nil, "") {
+		switch chunk.Type {
+		case "tool_call":
+			calls = append(calls, *chunk.ToolCall)
+		case "done":
+			done = true
+		}
+	}
+
+	if !done {
+		t.Error("expected done chunk on EOF")
+	}
+	if len(calls) != 1 || calls[0].ParseError == "" {
+		t.Fatalf("expected one tool call with ParseError, got %+v", calls)
+	}
+	if calls[0].ID != "call_0" {
+		t.Errorf("expected fallback ID call_0, got %q", calls[0].ID)
+	}
+}
+
+func TestOpenAI_CheckHealth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/models" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":[]}`))
+	}))
+	defer server.Close()
+
+	if err := newTestOpenAIClient(server.URL).CheckHealth(context.Background()); err != nil {
+		t.Errorf("expected healthy server, got %v", err)
+	}
+	if err := newTestOpenAIClient("http://127.0.0.1:1").CheckHealth(context.Background()); err == nil {
+		t.Error("expected error for unreachable server")
+	}
+}
@@ -0,0 +1,246 @@
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/abdul-hamid-achik/vecai/internal/logging"
+)
+
+// ErrClosed is returned for requests issued after the connection has shut down.
+var ErrClosed = errors.New("lsp connection closed")
+
+// rpcMessage is a JSON-RPC 2.0 request, response or notification.
+type rpcMessage struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  json.RawMessage  `json:"result,omitempty"`
+	Error   *RPCError        `json:"error,omitempty"`
+}
+
+// RPCError is an error returned by the language server.
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("lsp error %d: %s", e.Code, e.Message)
+}
+
+// NotificationHandler receives server-to-client notifications.
+type NotificationHandler func(method string, params json.RawMessage)
+
+// Conn is a JSON-RPC 2.0 connection using LSP's Content-Length framing.
+type Conn struct {
+	reader *bufio.Reader
+	writer io.WriteCloser
+
+	writeMu sync.Mutex
+	nextID  atomic.Int64
+
+	mu      sync.Mutex
+	pending map[int64]chan *rpcMessage
+	closed  bool
+	done    chan struct{}
+
+	onNotify NotificationHandler
+}
+
+// NewConn wraps a reader/writer pair (typically a server's stdout/stdin) and
+// starts reading messages in the background.
+func NewConn(r io.Reader, w io.WriteCloser, onNotify NotificationHandler) *Conn {
+	c := &Conn{
+		reader:   bufio.NewReader(r),
+		writer:   w,
+		pending:  make(map[int64]chan *rpcMessage),
+		done:     make(chan struct{}),
+		onNotify: onNotify,
+	}
+	go c.readLoop()
+	return c
+}
+
+// Call sends a request and decodes the response into result (which may be nil).
+func (c *Conn) Call(ctx context.Context, method string, params, result any) error {
+	id := c.nextID.Add(1)
+	ch := make(chan *rpcMessage, 1)
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return ErrClosed
+	}
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	rawID := json.RawMessage(strconv.FormatInt(id, 10))
+	if err := c.write(&rpcMessage{JSONRPC: "2.0", ID: &rawID, Method: method, Params: mustMarshal(params)}); err != nil {
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		// Best effort: tell the server we no longer need the answer
+		_ = c.Notify("$/cancelRequest", map[string]any{"id": id})
+		return ctx.Err()
+	case <-c.done:
+		return ErrClosed
+	case resp := <-ch:
+		if resp.Error != nil {
+			return resp.Error
+		}
+		if result == nil || len(resp.Result) == 0 || string(resp.Result) == "null" {
+			return nil
+		}
+		if err := json.Unmarshal(resp.Result, result); err != nil {
+			return fmt.Errorf("failed to decode %s result: %w", method, err)
+		}
+		return nil
+	}
+}
+
+// Notify sends a notification (no response expected).
+func (c *Conn) Notify(method string, params any) error {
+	return c.write(&rpcMessage{JSONRPC: "2.0", Method: method, Params: mustMarshal(params)})
+}
+
+// Close closes the write side and fails all pending calls.
+func (c *Conn) Close() error {
+	c.shutdown()
+	return c.writer.Close()
+}
+
+// Done is closed once the read loop has exited.
+func (c *Conn) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *Conn) shutdown() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.closed {
+		c.closed = true
+		close(c.done)
+	}
+}
+
+func (c *Conn) write(msg *rpcMessage) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal lsp message: %w", err)
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if _, err := fmt.Fprintf(c.writer, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = c.writer.Write(body)
+	return err
+}
+
+func (c *Conn) readLoop() {
+	defer c.shutdown()
+
+	tp := textproto.NewReader(c.reader)
+	for {
+		header, err := tp.ReadMIMEHeader()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				logLSP("lsp read header failed", err)
+			}
+			return
+		}
+		length, err := strconv.Atoi(strings.TrimSpace(header.Get("Content-Length")))
+		if err != nil || length <= 0 {
+			logLSP("lsp invalid Content-Length", err)
+			return
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(c.reader, body); err != nil {
+			logLSP("lsp read body failed", err)
+			return
+		}
+
+		var msg rpcMessage
+		if err := json.Unmarshal(body, &msg); err != nil {
+			logLSP("lsp decode failed", err)
+			continue
+		}
+		c.dispatch(&msg)
+	}
+}
+
+func (c *Conn) dispatch(msg *rpcMessage) {
+	switch {
+	case msg.Method != "" && msg.ID != nil:
+		// Server-to-client request
+		go c.replyToServer(msg)
+	case msg.Method != "":
+		if c.onNotify != nil {
+			c.onNotify(msg.Method, msg.Params)
+		}
+	case msg.ID != nil:
+		id, err := strconv.ParseInt(string(*msg.ID), 10, 64)
+		if err != nil {
+			return
+		}
+		c.mu.Lock()
+		ch, ok := c.pending[id]
+		c.mu.Unlock()
+		if ok {
+			ch <- msg
+		}
+	}
+}
+
+// replyToServer answers the handful of requests servers send to clients.
+// We advertise almost no client capabilities, so null results are acceptable.
+func (c *Conn) replyToServer(msg *rpcMessage) {
+	result := json.RawMessage("null")
+	if msg.Method == "workspace/configuration" {
+		var params struct {
+			Items []json.RawMessage `json:"items"`
+		}
+		_ = json.Unmarshal(msg.Params, &params)
+		result = mustMarshal(make([]any, len(params.Items)))
+	}
+	_ = c.write(&rpcMessage{JSONRPC: "2.0", ID: msg.ID, Result: result})
+}
+
+// mustMarshal encodes params; nil yields an omitted field.
+func mustMarshal(v any) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return json.RawMessage("null")
+	}
+	return data
+}
+
+func logLSP(msg string, err error) {
+	if log := logging.Global(); log != nil {
+		log.Debug(msg, logging.Error(err))
+	}
+}
@@ -0,0 +1,228 @@
+// Package lsp implements a minimal Language Server Protocol client that keeps
+// a language server (gopls) running for the lifetime of a project session.
+package lsp
+
+import (
+	"encoding/json"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// Position is a zero-based line and UTF-16 character offset.
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// Range is a half-open span between two positions.
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// Location is a range inside a document.
+type Location struct {
+	URI   string `json:"uri"`
+	Range Range  `json:"range"`
+}
+
+// TextDocumentIdentifier identifies a document by URI.
+type TextDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+// TextDocumentPositionParams is the common parameter shape for positional requests.
+type TextDocumentPositionParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Position     Position               `json:"position"`
+}
+
+// MarkupContent is the hover payload used by modern servers.
+type MarkupContent struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+// Hover is the result of textDocument/hover.
+type Hover struct {
+	Contents json.RawMessage `json:"contents"`
+	Range    *Range          `json:"range,omitempty"`
+}
+
+// Text returns the hover contents as plain text, handling MarkupContent,
+// MarkedString and MarkedString[] encodings.
+func (h *Hover) Text() string {
+	if h == nil || len(h.Contents) == 0 {
+		return ""
+	}
+	var markup MarkupContent
+	if err := json.Unmarshal(h.Contents, &markup); err == nil && markup.Value != "" {
+		return markup.Value
+	}
+	var s string
+	if err := json.Unmarshal(h.Contents, &s); err == nil {
+		return s
+	}
+	var parts []json.RawMessage
+	if err := json.Unmarshal(h.Contents, &parts); err == nil {
+		var out []string
+		for _, p := range parts {
+			if err := json.Unmarshal(p, &s); err == nil {
+				out = append(out, s)
+				continue
+			}
+			var marked struct {
+				Value string `json:"value"`
+			}
+			if err := json.Unmarshal(p, &marked); err == nil {
+				out = append(out, marked.Value)
+			}
+		}
+		return strings.Join(out, "\n")
+	}
+	return ""
+}
+
+// SymbolKind is the LSP symbol kind enumeration.
+type SymbolKind int
+
+var symbolKindNames = map[SymbolKind]string{
+	1: "file", 2: "module", 3: "namespace", 4: "package", 5: "class",
+	6: "method", 7: "property", 8: "field", 9: "constructor", 10: "enum",
+	11: "interface", 12: "function", 13: "variable", 14: "constant", 15: "string",
+	16: "number", 17: "boolean", 18: "array", 19: "object", 20: "key",
+	21: "null", 22: "enum member", 23: "struct", 24: "event", 25: "operator",
+	26: "type parameter",
+}
+
+func (k SymbolKind) String() string {
+	if name, ok := symbolKindNames[k]; ok {
+		return name
+	}
+	return "symbol"
+}
+
+// DocumentSymbol is the hierarchical result of textDocument/documentSymbol.
+type DocumentSymbol struct {
+	Name           string           `json:"name"`
+	Detail         string           `json:"detail,omitempty"`
+	Kind           SymbolKind       `json:"kind"`
+	Range          Range            `json:"range"`
+	SelectionRange Range            `json:"selectionRange"`
+	Children       []DocumentSymbol `json:"children,omitempty"`
+	// Location is set instead of Range by servers returning SymbolInformation[].
+	Location *Location `json:"location,omitempty"`
+}
+
+// SymbolInformation is the flat result of workspace/symbol.
+type SymbolInformation struct {
+	Name          string     `json:"name"`
+	Kind          SymbolKind `json:"kind"`
+	Location      Location   `json:"location"`
+	ContainerName string     `json:"containerName,omitempty"`
+}
+
+// TextEdit replaces a range with new text.
+type TextEdit struct {
+	Range   Range  `json:"range"`
+	NewText string `json:"newText"`
+}
+
+// TextDocumentEdit is a set of edits for one versioned document.
+type TextDocumentEdit struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Edits        []TextEdit             `json:"edits"`
+}
+
+// WorkspaceEdit is the result of textDocument/rename.
+type WorkspaceEdit struct {
+	Changes         map[string][]TextEdit `json:"changes,omitempty"`
+	DocumentChanges []TextDocumentEdit    `json:"documentChanges,omitempty"`
+}
+
+// Edits returns edits grouped by URI regardless of which encoding the server used.
+func (w *WorkspaceEdit) Edits() map[string][]TextEdit {
+	out := make(map[string][]TextEdit)
+	if w == nil {
+		return out
+	}
+	for uri, edits := range w.Changes {
+		out[uri] = append(out[uri], edits...)
+	}
+	for _, dc := range w.DocumentChanges {
+		out[dc.TextDocument.URI] = append(out[dc.TextDocument.URI], dc.Edits...)
+	}
+	return out
+}
+
+// CallHierarchyItem identifies a callable symbol.
+type CallHierarchyItem struct {
+	Name           string          `json:"name"`
+	Kind           SymbolKind      `json:"kind"`
+	Detail         string          `json:"detail,omitempty"`
+	URI            string          `json:"uri"`
+	Range          Range           `json:"range"`
+	SelectionRange Range           `json:"selectionRange"`
+	Data           json.RawMessage `json:"data,omitempty"`
+}
+
+// CallHierarchyIncomingCall is a caller of an item.
+type CallHierarchyIncomingCall struct {
+	From       CallHierarchyItem `json:"from"`
+	FromRanges []Range           `json:"fromRanges"`
+}
+
+// CallHierarchyOutgoingCall is a callee of an item.
+type CallHierarchyOutgoingCall struct {
+	To         CallHierarchyItem `json:"to"`
+	FromRanges []Range           `json:"fromRanges"`
+}
+
+// DiagnosticSeverity is the LSP severity enumeration.
+type DiagnosticSeverity int
+
+func (s DiagnosticSeverity) String() string {
+	switch s {
+	case 1:
+		return "error"
+	case 2:
+		return "warning"
+	case 3:
+		return "info"
+	case 4:
+		return "hint"
+	default:
+		return "diagnostic"
+	}
+}
+
+// Diagnostic is a single problem reported by the server.
+type Diagnostic struct {
+	Range    Range              `json:"range"`
+	Severity DiagnosticSeverity `json:"severity,omitempty"`
+	Source   string             `json:"source,omitempty"`
+	Message  string             `json:"message"`
+}
+
+// publishDiagnosticsParams is the payload of textDocument/publishDiagnostics.
+type publishDiagnosticsParams struct {
+	URI         string       `json:"uri"`
+	Version     *int         `json:"version,omitempty"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// PathToURI converts an absolute file path to a file:// URI.
+func PathToURI(path string) string {
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(path)}
+	return u.String()
+}
+
+// URIToPath converts a file:// URI back to a local path.
+func URIToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return filepath.FromSlash(u.Path)
+}
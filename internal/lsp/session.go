@@ -0,0 +1,463 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrServerNotFound is returned when the language server executable is not installed.
+var ErrServerNotFound = errors.New("language server not found in PATH")
+
+// ErrNoServer is returned when no configured server handles a file's extension.
+var ErrNoServer = errors.New("no language server configured")
+
+// ServerConfig describes how to launch a language server.
+type ServerConfig struct {
+	Name        string   // Identifies the server, e.g. "go" or "typescript"
+	Command     string   // Executable, e.g. "gopls"
+	Args        []string // Extra arguments
+	Extensions  []string // File extensions routed to this server, e.g. ".ts"
+	LanguageID  string   // LSP languageId sent in didOpen; inferred from the extension when empty
+	InstallHint string   // Shown when Command is missing
+}
+
+// GoplsServer is the default configuration for Go.
+var GoplsServer = ServerConfig{
+	Name:        "go",
+	Command:     "gopls",
+	Extensions:  []string{".go"},
+	LanguageID:  "go",
+	InstallHint: "go install golang.org/x/tools/gopls@latest",
+}
+
+// key identifies the server's session in a Manager.
+func (c ServerConfig) key() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return c.Command
+}
+
+// Handles reports whether the server is configured for path's extension.
+func (c ServerConfig) Handles(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, e := range c.Extensions {
+		if strings.ToLower(e) == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// languageID returns the languageId to announce for path.
+func (c ServerConfig) languageID(path string) string {
+	if c.LanguageID != "" {
+		return c.LanguageID
+	}
+	return LanguageIDForPath(path)
+}
+
+// languageIDs maps file extensions to LSP languageId values.
+var languageIDs = map[string]string{
+	".go":   "go",
+	".ts":   "typescript",
+	".mts":  "typescript",
+	".cts":  "typescript",
+	".tsx":  "typescriptreact",
+	".js":   "javascript",
+	".mjs":  "javascript",
+	".cjs":  "javascript",
+	".jsx":  "javascriptreact",
+	".py":   "python",
+	".pyi":  "python",
+	".rs":   "rust",
+	".rb":   "ruby",
+	".java": "java",
+	".c":    "c",
+	".h":    "c",
+	".cc":   "cpp",
+	".cpp":  "cpp",
+	".hpp":  "cpp",
+	".lua":  "lua",
+	".zig":  "zig",
+}
+
+// LanguageIDForPath returns the LSP languageId for path's extension, or the
+// extension itself (without the dot) when it is not a well-known one.
+func LanguageIDForPath(path string) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	if id, ok := languageIDs[ext]; ok {
+		return id
+	}
+	return strings.TrimPrefix(ext, ".")
+}
+
+// document tracks a file opened in the server so edits can be synced.
+type document struct {
+	version int
+	content string
+}
+
+// Session is a running language server bound to one project root.
+type Session struct {
+	root   string
+	server ServerConfig
+	conn   *Conn
+	cmd    *exec.Cmd
+
+	docsMu sync.Mutex
+	docs   map[string]*document // keyed by URI
+
+	diagMu      sync.Mutex
+	diagnostics map[string][]Diagnostic
+	diagNotify  chan struct{} // closed and replaced on every publishDiagnostics
+}
+
+// StartSession launches the server process in root and performs the initialize handshake.
+func StartSession(ctx context.Context, root string, server ServerConfig) (*Session, error) {
+	if _, err := exec.LookPath(server.Command); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrServerNotFound, server.Command)
+	}
+
+	// The process outlives ctx, which only bounds the handshake
+	cmd := exec.Command(server.Command, server.Args...)
+	cmd.Dir = root
+	cmd.Stderr = io.Discard
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s stdin: %w", server.Command, err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s stdout: %w", server.Command, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", server.Command, err)
+	}
+
+	s := newSession(root, server, stdout, stdin)
+	s.cmd = cmd
+
+	if err := s.initialize(ctx); err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// newSession wires a session to an already-running server's pipes.
+func newSession(root string, server ServerConfig, r io.Reader, w io.WriteCloser) *Session {
+	s := &Session{
+		root:        root,
+		server:      server,
+		docs:        make(map[string]*document),
+		diagnostics: make(map[string][]Diagnostic),
+		diagNotify:  make(chan struct{}),
+	}
+	s.conn = NewConn(r, w, s.handleNotification)
+	return s
+}
+
+func (s *Session) initialize(ctx context.Context) error {
+	params := map[string]any{
+		"processId": os.Getpid(),
+		"rootUri":   PathToURI(s.root),
+		"workspaceFolders": []map[string]any{
+			{"uri": PathToURI(s.root), "name": filepath.Base(s.root)},
+		},
+		"capabilities": map[string]any{
+			"textDocument": map[string]any{
+				"synchronization":    map[string]any{"didSave": false},
+				"hover":              map[string]any{"contentFormat": []string{"markdown", "plaintext"}},
+				"documentSymbol":     map[string]any{"hierarchicalDocumentSymbolSupport": true},
+				"rename":             map[string]any{"prepareSupport": false},
+				"publishDiagnostics": map[string]any{"versionSupport": true},
+			},
+			"workspace": map[string]any{
+				"workspaceFolders": true,
+				"configuration":    true,
+			},
+		},
+	}
+	if err := s.conn.Call(ctx, "initialize", params, nil); err != nil {
+		return fmt.Errorf("%s initialize failed: %w", s.server.Command, err)
+	}
+	return s.conn.Notify("initialized", map[string]any{})
+}
+
+// Close performs the shutdown/exit handshake and stops the process.
+func (s *Session) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_ = s.conn.Call(ctx, "shutdown", nil, nil)
+	_ = s.conn.Notify("exit", nil)
+	_ = s.conn.Close()
+
+	if s.cmd != nil && s.cmd.Process != nil {
+		waitDone := make(chan struct{})
+		go func() {
+			_ = s.cmd.Wait()
+			close(waitDone)
+		}()
+		select {
+		case <-waitDone:
+		case <-time.After(2 * time.Second):
+			_ = s.cmd.Process.Kill()
+			<-waitDone
+		}
+	}
+	return nil
+}
+
+// Alive reports whether the connection is still usable.
+func (s *Session) Alive() bool {
+	select {
+	case <-s.conn.Done():
+		return false
+	default:
+		return true
+	}
+}
+
+// SyncFile makes the server's view of path match the disk: it opens the
+// document on first use and sends a full-text didChange when it has changed.
+func (s *Session) SyncFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	uri := PathToURI(path)
+	content := string(data)
+
+	s.docsMu.Lock()
+	defer s.docsMu.Unlock()
+
+	doc, ok := s.docs[uri]
+	if !ok {
+		s.docs[uri] = &document{version: 1, content: content}
+		s.clearDiagnostics(uri)
+		return uri, s.conn.Notify("textDocument/didOpen", map[string]any{
+			"textDocument": map[string]any{
+				"uri":        uri,
+				"languageId": s.server.languageID(path),
+				"version":    1,
+				"text":       content,
+			},
+		})
+	}
+	if doc.content == content {
+		return uri, nil
+	}
+	doc.version++
+	doc.content = content
+	s.clearDiagnostics(uri)
+	return uri, s.conn.Notify("textDocument/didChange", map[string]any{
+		"textDocument":   map[string]any{"uri": uri, "version": doc.version},
+		"contentChanges": []map[string]any{{"text": content}},
+	})
+}
+
+// FileChanged tells the server a file was modified on disk. Open documents are
+// re-synced; others are reported through didChangeWatchedFiles.
+func (s *Session) FileChanged(path string) {
+	uri := PathToURI(path)
+	s.docsMu.Lock()
+	_, open := s.docs[uri]
+	s.docsMu.Unlock()
+
+	if open {
+		if _, err := s.SyncFile(path); err == nil {
+			return
+		}
+	}
+	s.clearDiagnostics(uri)
+	_ = s.conn.Notify("workspace/didChangeWatchedFiles", map[string]any{
+		"changes": []map[string]any{{"uri": uri, "type": 2}},
+	})
+}
+
+// Server returns the configuration the session was started with.
+func (s *Session) Server() ServerConfig {
+	return s.server
+}
+
+// Call forwards a request to the server.
+func (s *Session) Call(ctx context.Context, method string, params, result any) error {
+	return s.conn.Call(ctx, method, params, result)
+}
+
+// Diagnostics returns the latest diagnostics for uri, waiting up to wait for the
+// server to publish them if none have arrived since the document was synced.
+func (s *Session) Diagnostics(ctx context.Context, uri string, wait time.Duration) []Diagnostic {
+	deadline := time.NewTimer(wait)
+	defer deadline.Stop()
+
+	for {
+		s.diagMu.Lock()
+		diags, ok := s.diagnostics[uri]
+		notify := s.diagNotify
+		s.diagMu.Unlock()
+		if ok {
+			return diags
+		}
+
+		select {
+		case <-notify:
+		case <-deadline.C:
+			return nil
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (s *Session) handleNotification(method string, params json.RawMessage) {
+	if method != "textDocument/publishDiagnostics" {
+		return
+	}
+	var p publishDiagnosticsParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return
+	}
+	s.diagMu.Lock()
+	s.diagnostics[p.URI] = p.Diagnostics
+	close(s.diagNotify)
+	s.diagNotify = make(chan struct{})
+	s.diagMu.Unlock()
+}
+
+// clearDiagnostics forgets cached diagnostics for uri so the next Diagnostics
+// call waits for a fresh publish.
+func (s *Session) clearDiagnostics(uri string) {
+	s.diagMu.Lock()
+	delete(s.diagnostics, uri)
+	s.diagMu.Unlock()
+}
+
+// Manager owns the long-lived language server sessions for a project, one per
+// configured server. Servers start on first use and restart if they exit.
+type Manager struct {
+	root    string
+	servers []ServerConfig
+
+	mu       sync.Mutex
+	sessions map[string]*Session // keyed by ServerConfig.key
+}
+
+// NewManager creates a manager for the given project root. When several
+// servers handle the same extension, the first one wins.
+func NewManager(root string, servers []ServerConfig) *Manager {
+	return &Manager{root: root, servers: servers, sessions: make(map[string]*Session)}
+}
+
+// ServerFor returns the server configured for path's extension.
+func (m *Manager) ServerFor(path string) (ServerConfig, error) {
+	for _, server := range m.servers {
+		if server.Handles(path) {
+			return server, nil
+		}
+	}
+	ext := filepath.Ext(path)
+	if ext == "" {
+		ext = filepath.Base(path)
+	}
+	return ServerConfig{}, fmt.Errorf("%w for %s files", ErrNoServer, ext)
+}
+
+// Root returns the project root the manager's servers run in.
+func (m *Manager) Root() string {
+	return m.root
+}
+
+// Servers returns the configured servers in routing order.
+func (m *Manager) Servers() []ServerConfig {
+	return m.servers
+}
+
+// SessionFor returns the session for the server that handles path.
+func (m *Manager) SessionFor(ctx context.Context, path string) (*Session, error) {
+	server, err := m.ServerFor(path)
+	if err != nil {
+		return nil, err
+	}
+	return m.Session(ctx, server)
+}
+
+// Session returns the running session for server, starting (or restarting) it if needed.
+func (m *Manager) Session(ctx context.Context, server ServerConfig) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := server.key()
+	if s := m.sessions[key]; s != nil {
+		if s.Alive() {
+			return s, nil
+		}
+		_ = s.Close()
+		delete(m.sessions, key)
+	}
+
+	s, err := StartSession(ctx, m.root, server)
+	if err != nil {
+		return nil, err
+	}
+	m.sessions[key] = s
+	return s, nil
+}
+
+// Running returns the live sessions in routing order.
+func (m *Manager) Running() []*Session {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var running []*Session
+	for _, server := range m.servers {
+		if s := m.sessions[server.key()]; s != nil && s.Alive() {
+			running = append(running, s)
+		}
+	}
+	return running
+}
+
+// FileChanged forwards a disk change to the session handling path, if one is
+// running. Relative paths are taken from the manager's root. It never starts
+// a server.
+func (m *Manager) FileChanged(path string) {
+	server, err := m.ServerFor(path)
+	if err != nil {
+		return
+	}
+	m.mu.Lock()
+	s := m.sessions[server.key()]
+	m.mu.Unlock()
+	if s != nil && s.Alive() {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(m.root, path)
+		}
+		s.FileChanged(filepath.Clean(path))
+	}
+}
+
+// Close shuts down every running session.
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	sessions := m.sessions
+	m.sessions = make(map[string]*Session)
+	m.mu.Unlock()
+
+	var errs []error
+	for _, s := range sessions {
+		errs = append(errs, s.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,380 @@
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer speaks LSP framing over pipes and records what the client sends.
+type fakeServer struct {
+	t      *testing.T
+	reader *bufio.Reader
+	writer io.WriteCloser
+
+	mu       sync.Mutex
+	received []rpcMessage
+	handlers map[string]func(params json.RawMessage) any
+}
+
+// newFakePair returns a session connected to an in-process fake server.
+func newFakePair(t *testing.T, root string) (*Session, *fakeServer) {
+	t.Helper()
+	clientR, serverW := io.Pipe()
+	serverR, clientW := io.Pipe()
+
+	srv := &fakeServer{
+		t:        t,
+		reader:   bufio.NewReader(serverR),
+		writer:   serverW,
+		handlers: make(map[string]func(json.RawMessage) any),
+	}
+	srv.handlers["initialize"] = func(json.RawMessage) any {
+		return map[string]any{"capabilities": map[string]any{}}
+	}
+	srv.handlers["shutdown"] = func(json.RawMessage) any { return nil }
+	go srv.serve()
+
+	s := newSession(root, ServerConfig{Command: "fake", LanguageID: "go"}, clientR, clientW)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.initialize(ctx); err != nil {
+		t.Fatalf("initialize failed: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = s.Close()
+		_ = serverW.Close()
+	})
+	return s, srv
+}
+
+func (f *fakeServer) serve() {
+	tp := textproto.NewReader(f.reader)
+	for {
+		header, err := tp.ReadMIMEHeader()
+		if err != nil {
+			return
+		}
+		n, _ := strconv.Atoi(header.Get("Content-Length"))
+		body := make([]byte, n)
+		if _, err := io.ReadFull(f.reader, body); err != nil {
+			return
+		}
+		var msg rpcMessage
+		if err := json.Unmarshal(body, &msg); err != nil {
+			continue
+		}
+		f.mu.Lock()
+		f.received = append(f.received, msg)
+		handler := f.handlers[msg.Method]
+		f.mu.Unlock()
+
+		if msg.ID != nil && msg.Method != "" {
+			var result any
+			if handler != nil {
+				result = handler(msg.Params)
+			}
+			if rpcErr, ok := result.(*RPCError); ok {
+				f.send(map[string]any{"jsonrpc": "2.0", "id": msg.ID, "error": rpcErr})
+				continue
+			}
+			f.send(map[string]any{"jsonrpc": "2.0", "id": msg.ID, "result": result})
+		}
+	}
+}
+
+func (f *fakeServer) send(v any) {
+	body, _ := json.Marshal(v)
+	_, _ = fmt.Fprintf(f.writer, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+func (f *fakeServer) handle(method string, h func(json.RawMessage) any) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.handlers[method] = h
+}
+
+// waitFor blocks until a message with the given method has been received n times.
+func (f *fakeServer) waitFor(method string, n int) []rpcMessage {
+	f.t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		f.mu.Lock()
+		var matched []rpcMessage
+		for _, m := range f.received {
+			if m.Method == method {
+				matched = append(matched, m)
+			}
+		}
+		f.mu.Unlock()
+		if len(matched) >= n {
+			return matched
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	f.t.Fatalf("timed out waiting for %d %s messages", n, method)
+	return nil
+}
+
+func TestSession_InitializeHandshake(t *testing.T) {
+	root := t.TempDir()
+	_, srv := newFakePair(t, root)
+
+	init := srv.waitFor("initialize", 1)[0]
+	var params struct {
+		RootURI string `json:"rootUri"`
+	}
+	_ = json.Unmarshal(init.Params, &params)
+	if params.RootURI != PathToURI(root) {
+		t.Errorf("expected rootUri %s, got %s", PathToURI(root), params.RootURI)
+	}
+	srv.waitFor("initialized", 1)
+}
+
+func TestSession_CallDecodesResult(t *testing.T) {
+	s, srv := newFakePair(t, t.TempDir())
+	srv.handle("workspace/symbol", func(json.RawMessage) any {
+		return []SymbolInformation{{Name: "Foo", Kind: 12, Location: Location{URI: "file:///x.go"}}}
+	})
+
+	var syms []SymbolInformation
+	if err := s.Call(context.Background(), "workspace/symbol", map[string]any{"query": "Foo"}, &syms); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if len(syms) != 1 || syms[0].Name != "Foo" || syms[0].Kind.String() != "function" {
+		t.Errorf("unexpected symbols %+v", syms)
+	}
+}
+
+func TestSession_CallReturnsRPCError(t *testing.T) {
+	s, srv := newFakePair(t, t.TempDir())
+	srv.handle("textDocument/rename", func(json.RawMessage) any {
+		return &RPCError{Code: -32603, Message: "no identifier found"}
+	})
+
+	err := s.Call(context.Background(), "textDocument/rename", map[string]any{}, nil)
+	rpcErr, ok := err.(*RPCError)
+	if !ok {
+		t.Fatalf("expected *RPCError, got %T (%v)", err, err)
+	}
+	if rpcErr.Message != "no identifier found" {
+		t.Errorf("unexpected message %q", rpcErr.Message)
+	}
+}
+
+func TestSession_SyncFileOpensThenChanges(t *testing.T) {
+	root := t.TempDir()
+	s, srv := newFakePair(t, root)
+
+	path := filepath.Join(root, "main.go")
+	if err := os.WriteFile(path, []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	uri, err := s.SyncFile(path)
+	if err != nil {
+		t.Fatalf("SyncFile failed: %v", err)
+	}
+	if uri != PathToURI(path) {
+		t.Errorf("unexpected uri %s", uri)
+	}
+	srv.waitFor("textDocument/didOpen", 1)
+
+	// Unchanged content must not produce a didChange
+	if _, err := s.SyncFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s.FileChanged(path)
+
+	changes := srv.waitFor("textDocument/didChange", 1)
+	var params struct {
+		TextDocument struct {
+			Version int `json:"version"`
+		} `json:"textDocument"`
+		ContentChanges []struct {
+			Text string `json:"text"`
+		} `json:"contentChanges"`
+	}
+	_ = json.Unmarshal(changes[0].Params, &params)
+	if params.TextDocument.Version != 2 {
+		t.Errorf("expected version 2, got %d", params.TextDocument.Version)
+	}
+	if len(params.ContentChanges) != 1 || params.ContentChanges[0].Text != "package main\n\nfunc main() {}\n" {
+		t.Errorf("unexpected content changes %+v", params.ContentChanges)
+	}
+	if n := len(srv.waitFor("textDocument/didChange", 1)); n != 1 {
+		t.Errorf("expected exactly 1 didChange, got %d", n)
+	}
+}
+
+func TestSession_FileChangedUnopenedUsesWatchedFiles(t *testing.T) {
+	root := t.TempDir()
+	s, srv := newFakePair(t, root)
+
+	s.FileChanged(filepath.Join(root, "other.go"))
+	srv.waitFor("workspace/didChangeWatchedFiles", 1)
+}
+
+func TestManager_FileChangedResolvesAgainstRoot(t *testing.T) {
+	root := t.TempDir()
+	s, srv := newFakePair(t, root)
+	m := NewManager(root, []ServerConfig{GoplsServer})
+	m.sessions[GoplsServer.key()] = s
+
+	m.FileChanged(filepath.Join("pkg", "util.go"))
+	msgs := srv.waitFor("workspace/didChangeWatchedFiles", 1)
+	var params struct {
+		Changes []struct {
+			URI string `json:"uri"`
+		} `json:"changes"`
+	}
+	_ = json.Unmarshal(msgs[0].Params, &params)
+	if want := PathToURI(filepath.Join(root, "pkg", "util.go")); len(params.Changes) != 1 || params.Changes[0].URI != want {
+		t.Errorf("expected a change for %s, got %+v", want, params.Changes)
+	}
+}
+
+func TestSession_DiagnosticsWaitsForPublish(t *testing.T) {
+	root := t.TempDir()
+	s, srv := newFakePair(t, root)
+	uri := PathToURI(filepath.Join(root, "main.go"))
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		srv.send(map[string]any{
+			"jsonrpc": "2.0",
+			"method":  "textDocument/publishDiagnostics",
+			"params": map[string]any{
+				"uri":         uri,
+				"diagnostics": []map[string]any{{"message": "undefined: x", "severity": 1, "range": map[string]any{}}},
+			},
+		})
+	}()
+
+	diags := s.Diagnostics(context.Background(), uri, 2*time.Second)
+	if len(diags) != 1 || diags[0].Message != "undefined: x" || diags[0].Severity.String() != "error" {
+		t.Errorf("unexpected diagnostics %+v", diags)
+	}
+
+	if got := s.Diagnostics(context.Background(), PathToURI("/nope.go"), 20*time.Millisecond); got != nil {
+		t.Errorf("expected nil diagnostics on timeout, got %+v", got)
+	}
+}
+
+func TestSession_ServerRequestGetsReply(t *testing.T) {
+	_, srv := newFakePair(t, t.TempDir())
+
+	// A server-to-client request must be answered or the server blocks
+	srv.send(map[string]any{"jsonrpc": "2.0", "id": 99, "method": "workspace/configuration",
+		"params": map[string]any{"items": []any{map[string]any{}, map[string]any{}}}})
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		srv.mu.Lock()
+		for _, m := range srv.received {
+			if m.ID != nil && string(*m.ID) == "99" && m.Method == "" {
+				srv.mu.Unlock()
+				if string(m.Result) != "[null,null]" {
+					t.Errorf("expected one null per item, got %s", m.Result)
+				}
+				return
+			}
+		}
+		srv.mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("client never replied to workspace/configuration")
+}
+
+func TestHoverText(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{`{"kind":"markdown","value":"func Foo()"}`, "func Foo()"},
+		{`"plain"`, "plain"},
+		{`["a",{"language":"go","value":"b"}]`, "a\nb"},
+	}
+	for _, tt := range tests {
+		h := &Hover{Contents: json.RawMessage(tt.raw)}
+		if got := h.Text(); got != tt.want {
+			t.Errorf("Text(%s) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestURIRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dir with space", "a.go")
+	if got := URIToPath(PathToURI(path)); got != path {
+		t.Errorf("round trip mismatch: %s != %s", got, path)
+	}
+}
+
+func TestStartSession_MissingBinary(t *testing.T) {
+	_, err := StartSession(context.Background(), t.TempDir(), ServerConfig{Command: "definitely-not-a-real-lsp"})
+	if err == nil {
+		t.Fatal("expected error for missing binary")
+	}
+}
+
+func TestManager_ServerForRoutesByExtension(t *testing.T) {
+	ts := ServerConfig{Name: "typescript", Command: "typescript-language-server", Extensions: []string{".ts", ".tsx"}}
+	override := ServerConfig{Name: "custom-go", Command: "custom", Extensions: []string{".GO"}}
+	m := NewManager(t.TempDir(), []ServerConfig{override, ts, GoplsServer})
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"src/app.ts", "typescript"},
+		{"src/View.TSX", "typescript"},
+		{"main.go", "custom-go"}, // first match wins
+	}
+	for _, tt := range tests {
+		server, err := m.ServerFor(tt.path)
+		if err != nil {
+			t.Fatalf("ServerFor(%s): %v", tt.path, err)
+		}
+		if server.Name != tt.want {
+			t.Errorf("ServerFor(%s) = %s, want %s", tt.path, server.Name, tt.want)
+		}
+	}
+
+	if _, err := m.ServerFor("script.rb"); !errors.Is(err, ErrNoServer) {
+		t.Errorf("expected ErrNoServer, got %v", err)
+	}
+}
+
+func TestLanguageIDForPath(t *testing.T) {
+	tests := map[string]string{
+		"a.go":     "go",
+		"b.tsx":    "typescriptreact",
+		"c.PY":     "python",
+		"d.ex":     "ex",
+		"Makefile": "",
+	}
+	for path, want := range tests {
+		if got := LanguageIDForPath(path); got != want {
+			t.Errorf("LanguageIDForPath(%s) = %q, want %q", path, got, want)
+		}
+	}
+
+	withOverride := ServerConfig{LanguageID: "vue"}
+	if got := withOverride.languageID("x.ts"); got != "vue" {
+		t.Errorf("expected configured languageId to win, got %q", got)
+	}
+}
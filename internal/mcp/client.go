@@ -0,0 +1,295 @@
+// Package mcp is a Model Context Protocol client. It talks to tool servers
+// over stdio (newline-delimited JSON-RPC on a child process's stdin/stdout)
+// or streamable HTTP, and exposes their tools for the tool registry.
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/abdul-hamid-achik/vecai/internal/logging"
+)
+
+// ProtocolVersion is the MCP revision this client speaks.
+const ProtocolVersion = "2025-03-26"
+
+// ErrClosed is returned for requests issued after the connection has shut down.
+var ErrClosed = errors.New("mcp connection closed")
+
+// ClientVersion is reported to servers in clientInfo.
+var ClientVersion = "dev"
+
+// message is a JSON-RPC 2.0 request, response or notification.
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+func (m *message) isRequest() bool      { return m.Method != "" && m.ID != nil }
+func (m *message) isNotification() bool { return m.Method != "" && m.ID == nil }
+
+// RPCError is an error returned by the server.
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("mcp error %d: %s", e.Code, e.Message)
+}
+
+// transport carries messages to and from one server.
+type transport interface {
+	// roundTrip sends a request and waits for its response.
+	roundTrip(ctx context.Context, req *message) (*message, error)
+	// notify sends a notification.
+	notify(ctx context.Context, msg *message) error
+	close() error
+}
+
+// ServerConfig describes how to reach a server: Command for stdio, or URL
+// for streamable HTTP.
+type ServerConfig struct {
+	Name    string
+	Command string
+	Args    []string
+	Env     []string // KEY=VALUE pairs added to the server's environment
+	Dir     string   // Working directory for stdio servers
+	URL     string
+	Headers map[string]string // Extra HTTP headers, e.g. Authorization
+}
+
+// Implementation identifies a client or server.
+type Implementation struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// Tool is a tool advertised by a server.
+type Tool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	InputSchema map[string]any `json:"inputSchema"`
+}
+
+// Content is one item of a tool result.
+type Content struct {
+	Type     string            `json:"type"` // text, image, audio or resource
+	Text     string            `json:"text,omitempty"`
+	MimeType string            `json:"mimeType,omitempty"`
+	Data     string            `json:"data,omitempty"` // Base64 for image and audio
+	Resource *ResourceContents `json:"resource,omitempty"`
+}
+
+// ResourceContents is an embedded resource.
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Blob     string `json:"blob,omitempty"`
+}
+
+// CallToolResult is the result of tools/call.
+type CallToolResult struct {
+	Content []Content `json:"content"`
+	IsError bool      `json:"isError,omitempty"`
+}
+
+// Text renders the result for the model: text items verbatim, other items
+// as short placeholders.
+func (r *CallToolResult) Text() string {
+	parts := make([]string, 0, len(r.Content))
+	for _, c := range r.Content {
+		switch {
+		case c.Type == "text":
+			parts = append(parts, c.Text)
+		case c.Type == "resource" && c.Resource != nil && c.Resource.Text != "":
+			parts = append(parts, fmt.Sprintf("[resource %s]\n%s", c.Resource.URI, c.Resource.Text))
+		case c.Type == "resource" && c.Resource != nil:
+			parts = append(parts, fmt.Sprintf("[resource %s (%s)]", c.Resource.URI, c.Resource.MimeType))
+		default:
+			parts = append(parts, fmt.Sprintf("[%s content (%s), %d bytes base64]", c.Type, c.MimeType, len(c.Data)))
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// Client is a connection to one initialized server.
+type Client struct {
+	name   string
+	t      transport
+	nextID atomic.Int64
+	server Implementation
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// Connect starts or dials the server and performs the initialize handshake.
+// ctx bounds the handshake only; the connection lives until Close.
+func Connect(ctx context.Context, cfg ServerConfig) (*Client, error) {
+	c := &Client{name: cfg.Name}
+
+	var err error
+	switch {
+	case cfg.URL != "":
+		c.t = newHTTPTransport(cfg, c.handleRequest)
+	case cfg.Command != "":
+		c.t, err = startStdio(cfg, c.handleRequest)
+	default:
+		err = fmt.Errorf("mcp server %q needs a command or url", cfg.Name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.initialize(ctx); err != nil {
+		_ = c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Client) initialize(ctx context.Context) error {
+	params := map[string]any{
+		"protocolVersion": ProtocolVersion,
+		"capabilities":    map[string]any{},
+		"clientInfo":      Implementation{Name: "vecai", Version: ClientVersion},
+	}
+	var result struct {
+		ProtocolVersion string         `json:"protocolVersion"`
+		ServerInfo      Implementation `json:"serverInfo"`
+	}
+	if err := c.call(ctx, "initialize", params, &result); err != nil {
+		return fmt.Errorf("mcp server %q initialize failed: %w", c.name, err)
+	}
+	c.server = result.ServerInfo
+	if ht, ok := c.t.(*httpTransport); ok {
+		ht.setProtocolVersion(result.ProtocolVersion)
+	}
+	return c.t.notify(ctx, &message{JSONRPC: "2.0", Method: "notifications/initialized"})
+}
+
+// Name returns the configured server name.
+func (c *Client) Name() string {
+	return c.name
+}
+
+// ServerInfo returns what the server reported about itself.
+func (c *Client) ServerInfo() Implementation {
+	return c.server
+}
+
+// ListTools returns every tool the server offers, following pagination.
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	var tools []Tool
+	cursor := ""
+	for {
+		params := map[string]any{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		var page struct {
+			Tools      []Tool `json:"tools"`
+			NextCursor string `json:"nextCursor"`
+		}
+		if err := c.call(ctx, "tools/list", params, &page); err != nil {
+			return nil, err
+		}
+		tools = append(tools, page.Tools...)
+		if page.NextCursor == "" || page.NextCursor == cursor {
+			return tools, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// CallTool invokes a tool. A result with IsError set is a tool-level
+// failure; protocol failures are returned as errors.
+func (c *Client) CallTool(ctx context.Context, name string, args map[string]any) (*CallToolResult, error) {
+	if args == nil {
+		args = map[string]any{}
+	}
+	var result CallToolResult
+	if err := c.call(ctx, "tools/call", map[string]any{"name": name, "arguments": args}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Close shuts the connection down and stops a stdio server. It is safe to
+// call more than once.
+func (c *Client) Close() error {
+	c.closeOnce.Do(func() {
+		if c.t != nil {
+			c.closeErr = c.t.close()
+		}
+	})
+	return c.closeErr
+}
+
+// call sends a request and decodes the result into result (which may be nil).
+func (c *Client) call(ctx context.Context, method string, params, result any) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s params: %w", method, err)
+	}
+	id := json.RawMessage(strconv.FormatInt(c.nextID.Add(1), 10))
+	resp, err := c.t.roundTrip(ctx, &message{JSONRPC: "2.0", ID: id, Method: method, Params: raw})
+	if err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if result == nil || len(resp.Result) == 0 || string(resp.Result) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(resp.Result, result); err != nil {
+		return fmt.Errorf("failed to decode %s result: %w", method, err)
+	}
+	return nil
+}
+
+// handleRequest answers server-to-client requests. We advertise no client
+// capabilities, so only ping is supported.
+func (c *Client) handleRequest(req *message) *message {
+	resp := &message{JSONRPC: "2.0", ID: req.ID}
+	if req.Method == "ping" {
+		resp.Result = json.RawMessage("{}")
+	} else {
+		resp.Error = &RPCError{Code: -32601, Message: "method not found: " + req.Method}
+	}
+	return resp
+}
+
+// dispatchIncoming routes a message that is not the response being waited
+// for: server requests are answered through reply, notifications are logged.
+func dispatchIncoming(msg *message, handler func(*message) *message, reply func(*message)) {
+	switch {
+	case msg.isRequest():
+		reply(handler(msg))
+	case msg.isNotification():
+		logMCP("mcp notification "+msg.Method, nil)
+	}
+}
+
+func logMCP(msg string, err error) {
+	if log := logging.Global(); log != nil {
+		if err != nil {
+			log.Debug(msg, logging.Error(err))
+		} else {
+			log.Debug(msg)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abdul-hamid-achik/vecai/internal/mcp/mcptest"
+)
+
+// TestMain lets the test binary double as a stdio MCP server: with
+// VECAI_MCP_FAKE set it serves mcptest tools on stdin/stdout instead of
+// running tests.
+func TestMain(m *testing.M) {
+	switch os.Getenv("VECAI_MCP_FAKE") {
+	case "":
+		os.Exit(m.Run())
+	case "crash":
+		_, _ = os.Stderr.WriteString("fatal: missing API token\n")
+		os.Exit(1)
+	default:
+		srv := mcptest.NewServer(mcptest.Echo(), mcptest.Fail())
+		srv.PageSize = 1
+		_ = srv.ServeStdio(os.Stdin, os.Stdout)
+		os.Exit(0)
+	}
+}
+
+func stdioConfig(mode string) ServerConfig {
+	return ServerConfig{Name: "fake", Command: os.Args[0], Env: []string{"VECAI_MCP_FAKE=" + mode}}
+}
+
+func connect(t *testing.T, cfg ServerConfig) *Client {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	c, err := Connect(ctx, cfg)
+	if err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+// exercise lists and calls tools on a server offering mcptest.Echo and mcptest.Fail.
+func exercise(t *testing.T, c *Client) {
+	t.Helper()
+	ctx := context.Background()
+
+	if info := c.ServerInfo(); info.Name != "mcptest" {
+		t.Errorf("unexpected server info %+v", info)
+	}
+
+	tools, err := c.ListTools(ctx)
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(tools) != 2 || tools[0].Name != "echo" || tools[1].Name != "fail" {
+		t.Fatalf("unexpected tools %+v", tools)
+	}
+	if tools[0].InputSchema["type"] != "object" {
+		t.Errorf("expected input schema to survive, got %v", tools[0].InputSchema)
+	}
+
+	res, err := c.CallTool(ctx, "echo", map[string]any{"text": "hello"})
+	if err != nil || res.IsError || res.Text() != "hello" {
+		t.Errorf("echo = %+v, %v", res, err)
+	}
+	res, err = c.CallTool(ctx, "fail", nil)
+	if err != nil || !res.IsError || res.Text() != "something went wrong" {
+		t.Errorf("fail = %+v, %v", res, err)
+	}
+	var rpcErr *RPCError
+	if _, err := c.CallTool(ctx, "nope", nil); !errors.As(err, &rpcErr) {
+		t.Errorf("expected RPC error for unknown tool, got %v", err)
+	}
+}
+
+func TestClient_Stdio(t *testing.T) {
+	c := connect(t, stdioConfig("serve"))
+	exercise(t, c)
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ListTools(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed after Close, got %v", err)
+	}
+}
+
+func TestClient_StdioServerExitReportsStderr(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := Connect(ctx, stdioConfig("crash"))
+	if err == nil || !strings.Contains(err.Error(), "missing API token") {
+		t.Errorf("expected stderr in error, got %v", err)
+	}
+}
+
+func TestClient_HTTP(t *testing.T) {
+	for _, stream := range []bool{false, true} {
+		name := "json"
+		if stream {
+			name = "sse"
+		}
+		t.Run(name, func(t *testing.T) {
+			srv := mcptest.NewServer(mcptest.Echo(), mcptest.Fail())
+			srv.Stream = stream
+			httpSrv := httptest.NewServer(srv)
+			defer httpSrv.Close()
+
+			c := connect(t, ServerConfig{Name: "fake", URL: httpSrv.URL})
+			exercise(t, c)
+
+			if srv.Sessions() != 1 {
+				t.Errorf("expected one session, got %d", srv.Sessions())
+			}
+			if err := c.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if srv.Sessions() != 0 {
+				t.Error("expected Close to end the HTTP session")
+			}
+		})
+	}
+}
+
+func TestCallToolResult_TextPlaceholders(t *testing.T) {
+	r := CallToolResult{Content: []Content{
+		{Type: "text", Text: "caption"},
+		{Type: "image", MimeType: "image/png", Data: "aGVsbG8="},
+		{Type: "resource", Resource: &ResourceContents{URI: "file:///a.txt", Text: "body"}},
+	}}
+	want := "caption\n[image content (image/png), 8 bytes base64]\n[resource file:///a.txt]\nbody"
+	if got := r.Text(); got != want {
+		t.Errorf("Text() = %q, want %q", got, want)
+	}
+}
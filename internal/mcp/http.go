@@ -0,0 +1,231 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// httpTransport implements the streamable HTTP transport: every message is
+// POSTed to one endpoint, and the server answers with a JSON body or an SSE
+// stream that ends with the response.
+type httpTransport struct {
+	name    string
+	url     string
+	headers map[string]string
+	client  *http.Client
+	handler func(*message) *message
+
+	mu              sync.Mutex
+	sessionID       string
+	protocolVersion string
+	closed          bool
+}
+
+func newHTTPTransport(cfg ServerConfig, handler func(*message) *message) *httpTransport {
+	return &httpTransport{
+		name:    cfg.Name,
+		url:     cfg.URL,
+		headers: cfg.Headers,
+		// No overall timeout: SSE responses stream for as long as the tool runs
+		client:  &http.Client{Transport: &http.Transport{Proxy: http.ProxyFromEnvironment, ResponseHeaderTimeout: 5 * time.Minute}},
+		handler: handler,
+	}
+}
+
+func (t *httpTransport) setProtocolVersion(v string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.protocolVersion = v
+}
+
+func (t *httpTransport) roundTrip(ctx context.Context, req *message) (*message, error) {
+	resp, err := t.post(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if id := resp.Header.Get("Mcp-Session-Id"); id != "" && req.Method == "initialize" {
+		t.mu.Lock()
+		t.sessionID = id
+		t.mu.Unlock()
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/json":
+		return t.readJSON(ctx, resp.Body, req.ID)
+	case "text/event-stream":
+		return t.readSSE(ctx, resp.Body, req.ID)
+	default:
+		return nil, fmt.Errorf("mcp server %q: unexpected content type %q", t.name, resp.Header.Get("Content-Type"))
+	}
+}
+
+func (t *httpTransport) notify(ctx context.Context, msg *message) error {
+	resp, err := t.post(ctx, msg)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
+// close ends the server-side session, if the server assigned one.
+func (t *httpTransport) close() error {
+	t.mu.Lock()
+	sessionID := t.sessionID
+	t.closed = true
+	t.mu.Unlock()
+	if sessionID == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, t.url, nil)
+	if err != nil {
+		return nil
+	}
+	t.setHeaders(req, sessionID)
+	if resp, err := t.client.Do(req); err == nil {
+		_ = resp.Body.Close()
+	}
+	return nil
+}
+
+func (t *httpTransport) post(ctx context.Context, msg *message) (*http.Response, error) {
+	t.mu.Lock()
+	closed, sessionID := t.closed, t.sessionID
+	t.mu.Unlock()
+	if closed {
+		return nil, ErrClosed
+	}
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal mcp message: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	t.setHeaders(req, sessionID)
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("mcp server %q: %w", t.name, err)
+	}
+	if resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		_ = resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound && sessionID != "" {
+			return nil, fmt.Errorf("mcp server %q: session expired", t.name)
+		}
+		return nil, fmt.Errorf("mcp server %q: HTTP %d: %s", t.name, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return resp, nil
+}
+
+func (t *httpTransport) setHeaders(req *http.Request, sessionID string) {
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	if sessionID != "" {
+		req.Header.Set("Mcp-Session-Id", sessionID)
+	}
+	t.mu.Lock()
+	if t.protocolVersion != "" {
+		req.Header.Set("MCP-Protocol-Version", t.protocolVersion)
+	}
+	t.mu.Unlock()
+}
+
+// readJSON handles a plain JSON body, which may be a single message or a batch.
+func (t *httpTransport) readJSON(ctx context.Context, body io.Reader, id json.RawMessage) (*message, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("mcp server %q: %w", t.name, err)
+	}
+	var batch []*message
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(trimmed, &batch)
+	} else {
+		var msg message
+		err = json.Unmarshal(trimmed, &msg)
+		batch = []*message{&msg}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("mcp server %q: invalid response: %w", t.name, err)
+	}
+	var resp *message
+	for _, msg := range batch {
+		if t.isResponse(msg, id) {
+			resp = msg
+			continue
+		}
+		t.dispatch(ctx, msg)
+	}
+	if resp == nil {
+		return nil, fmt.Errorf("mcp server %q: no response to request %s", t.name, id)
+	}
+	return resp, nil
+}
+
+// readSSE reads events until the response to id arrives. Other messages on
+// the stream (progress notifications, server requests) are dispatched.
+func (t *httpTransport) readSSE(ctx context.Context, body io.Reader, id json.RawMessage) (*message, error) {
+	reader := bufio.NewReader(body)
+	var data strings.Builder
+	for {
+		line, err := reader.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		case line == "" && data.Len() > 0:
+			var msg message
+			if jsonErr := json.Unmarshal([]byte(data.String()), &msg); jsonErr != nil {
+				logMCP("mcp ignoring malformed SSE event", jsonErr)
+			} else if t.isResponse(&msg, id) {
+				return &msg, nil
+			} else {
+				t.dispatch(ctx, &msg)
+			}
+			data.Reset()
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, fmt.Errorf("mcp server %q: stream ended before response to request %s", t.name, id)
+		}
+	}
+}
+
+func (t *httpTransport) isResponse(msg *message, id json.RawMessage) bool {
+	return msg.Method == "" && bytes.Equal(bytes.TrimSpace(msg.ID), id)
+}
+
+func (t *httpTransport) dispatch(ctx context.Context, msg *message) {
+	dispatchIncoming(msg, t.handler, func(resp *message) {
+		if r, err := t.post(ctx, resp); err == nil {
+			_, _ = io.Copy(io.Discard, r.Body)
+			_ = r.Body.Close()
+		}
+	})
+}
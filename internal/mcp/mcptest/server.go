@@ -0,0 +1,264 @@
+// Package mcptest provides an in-memory MCP server for tests. It serves the
+// same tools over stdio (ServeStdio) or streamable HTTP (as an http.Handler).
+package mcptest
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// Tool is a fake tool. Handler returns the text result and whether it is a
+// tool-level error.
+type Tool struct {
+	Name        string
+	Description string
+	InputSchema map[string]any
+	Handler     func(args map[string]any) (string, bool)
+}
+
+// Call records one tools/call request.
+type Call struct {
+	Name string
+	Args map[string]any
+}
+
+// Server is a fake MCP server.
+type Server struct {
+	// PageSize splits tools/list into pages of this size (0: one page).
+	PageSize int
+	// Stream answers HTTP requests as SSE, preceded by a progress notification.
+	Stream bool
+
+	tools []Tool
+
+	mu       sync.Mutex
+	calls    []Call
+	sessions map[string]bool
+	nextSess int
+}
+
+// NewServer returns a server offering tools.
+func NewServer(tools ...Tool) *Server {
+	return &Server{tools: tools, sessions: make(map[string]bool)}
+}
+
+// Echo is a read-style tool that returns its "text" argument.
+func Echo() Tool {
+	return Tool{
+		Name:        "echo",
+		Description: "Echo the given text",
+		InputSchema: map[string]any{
+			"type":       "object",
+			"properties": map[string]any{"text": map[string]any{"type": "string"}},
+			"required":   []any{"text"},
+		},
+		Handler: func(args map[string]any) (string, bool) {
+			text, _ := args["text"].(string)
+			return text, false
+		},
+	}
+}
+
+// Fail is a tool that always reports a tool-level error.
+func Fail() Tool {
+	return Tool{
+		Name:        "fail",
+		Description: "Always fails",
+		Handler:     func(map[string]any) (string, bool) { return "something went wrong", true },
+	}
+}
+
+// Calls returns the tools/call requests received so far.
+func (s *Server) Calls() []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Call(nil), s.calls...)
+}
+
+// Sessions returns the number of open HTTP sessions.
+func (s *Server) Sessions() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions)
+}
+
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// ServeStdio serves newline-delimited JSON-RPC until r is exhausted.
+func (s *Server) ServeStdio(r io.Reader, w io.Writer) error {
+	reader := bufio.NewReader(r)
+	enc := json.NewEncoder(w)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			var msg message
+			if jsonErr := json.Unmarshal(line, &msg); jsonErr == nil {
+				if resp := s.handle(&msg); resp != nil {
+					if err := enc.Encode(resp); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// ServeHTTP implements the streamable HTTP transport.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.Header.Get("Mcp-Session-Id")
+	if r.Method == http.MethodDelete {
+		s.mu.Lock()
+		delete(s.sessions, sessionID)
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var msg message
+	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if msg.Method == "initialize" {
+		s.mu.Lock()
+		s.nextSess++
+		sessionID = "session-" + strconv.Itoa(s.nextSess)
+		s.sessions[sessionID] = true
+		s.mu.Unlock()
+		w.Header().Set("Mcp-Session-Id", sessionID)
+	} else {
+		s.mu.Lock()
+		ok := s.sessions[sessionID]
+		s.mu.Unlock()
+		if !ok {
+			http.Error(w, "unknown session", http.StatusNotFound)
+			return
+		}
+	}
+
+	resp := s.handle(&msg)
+	if resp == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if !s.Stream {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	progress := message{JSONRPC: "2.0", Method: "notifications/progress", Params: json.RawMessage(`{"progress":1}`)}
+	for _, m := range []any{progress, resp} {
+		data, _ := json.Marshal(m)
+		_, _ = fmt.Fprintf(w, "event: message\ndata: %s\n\n", data)
+	}
+}
+
+// handle returns the response to msg, or nil for notifications.
+func (s *Server) handle(msg *message) *message {
+	if msg.ID == nil {
+		return nil
+	}
+	resp := &message{JSONRPC: "2.0", ID: msg.ID}
+	switch msg.Method {
+	case "initialize":
+		resp.Result = map[string]any{
+			"protocolVersion": "2025-03-26",
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]any{"name": "mcptest", "version": "1.0.0"},
+		}
+	case "ping":
+		resp.Result = map[string]any{}
+	case "tools/list":
+		resp.Result = s.listTools(msg.Params)
+	case "tools/call":
+		result, err := s.callTool(msg.Params)
+		if err != nil {
+			resp.Error = err
+		} else {
+			resp.Result = result
+		}
+	default:
+		resp.Error = &rpcError{Code: -32601, Message: "method not found: " + msg.Method}
+	}
+	return resp
+}
+
+func (s *Server) listTools(params json.RawMessage) map[string]any {
+	var p struct {
+		Cursor string `json:"cursor"`
+	}
+	_ = json.Unmarshal(params, &p)
+	start, _ := strconv.Atoi(p.Cursor)
+	end := len(s.tools)
+	if s.PageSize > 0 && start+s.PageSize < end {
+		end = start + s.PageSize
+	}
+
+	tools := make([]map[string]any, 0, end-start)
+	for _, t := range s.tools[start:end] {
+		schema := t.InputSchema
+		if schema == nil {
+			schema = map[string]any{"type": "object"}
+		}
+		tools = append(tools, map[string]any{"name": t.Name, "description": t.Description, "inputSchema": schema})
+	}
+	result := map[string]any{"tools": tools}
+	if end < len(s.tools) {
+		result["nextCursor"] = strconv.Itoa(end)
+	}
+	return result
+}
+
+func (s *Server) callTool(params json.RawMessage) (map[string]any, *rpcError) {
+	var p struct {
+		Name      string         `json:"name"`
+		Arguments map[string]any `json:"arguments"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &rpcError{Code: -32602, Message: err.Error()}
+	}
+	s.mu.Lock()
+	s.calls = append(s.calls, Call{Name: p.Name, Args: p.Arguments})
+	s.mu.Unlock()
+
+	for _, t := range s.tools {
+		if t.Name == p.Name {
+			text, isError := t.Handler(p.Arguments)
+			return map[string]any{
+				"content": []any{map[string]any{"type": "text", "text": text}},
+				"isError": isError,
+			}, nil
+		}
+	}
+	return nil, &rpcError{Code: -32602, Message: "unknown tool: " + p.Name}
+}
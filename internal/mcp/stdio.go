@@ -0,0 +1,224 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxStderrTail bounds how much server stderr is kept for error messages.
+const maxStderrTail = 4096
+
+// stdioTransport runs a server as a child process and exchanges
+// newline-delimited JSON-RPC messages over its stdin and stdout.
+type stdioTransport struct {
+	name    string
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	stderr  *tailBuffer
+	handler func(*message) *message
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	pending map[string]chan *message
+	closed  bool
+	done    chan struct{}
+	exited  chan struct{}
+}
+
+func startStdio(cfg ServerConfig, handler func(*message) *message) (*stdioTransport, error) {
+	if _, err := exec.LookPath(cfg.Command); err != nil {
+		return nil, fmt.Errorf("mcp server %q: %s not found in PATH", cfg.Name, cfg.Command)
+	}
+
+	// The process outlives the connect context, which only bounds the handshake
+	cmd := exec.Command(cfg.Command, cfg.Args...)
+	cmd.Dir = cfg.Dir
+	cmd.Env = append(os.Environ(), cfg.Env...)
+	stderr := &tailBuffer{}
+	cmd.Stderr = stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s stdin: %w", cfg.Command, err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s stdout: %w", cfg.Command, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start mcp server %q: %w", cfg.Name, err)
+	}
+
+	t := &stdioTransport{
+		name:    cfg.Name,
+		cmd:     cmd,
+		stdin:   stdin,
+		stderr:  stderr,
+		handler: handler,
+		pending: make(map[string]chan *message),
+		done:    make(chan struct{}),
+		exited:  make(chan struct{}),
+	}
+	go t.readLoop(stdout)
+	go func() {
+		_ = cmd.Wait()
+		close(t.exited)
+	}()
+	return t, nil
+}
+
+func (t *stdioTransport) roundTrip(ctx context.Context, req *message) (*message, error) {
+	key := string(req.ID)
+	ch := make(chan *message, 1)
+
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil, ErrClosed
+	}
+	t.pending[key] = ch
+	t.mu.Unlock()
+
+	defer func() {
+		t.mu.Lock()
+		delete(t.pending, key)
+		t.mu.Unlock()
+	}()
+
+	if err := t.write(req); err != nil {
+		return nil, t.exitError(err)
+	}
+
+	select {
+	case <-ctx.Done():
+		// Best effort: tell the server we no longer need the answer
+		params, _ := json.Marshal(map[string]any{"requestId": req.ID, "reason": ctx.Err().Error()})
+		_ = t.write(&message{JSONRPC: "2.0", Method: "notifications/cancelled", Params: params})
+		return nil, ctx.Err()
+	case <-t.done:
+		return nil, t.exitError(ErrClosed)
+	case resp := <-ch:
+		return resp, nil
+	}
+}
+
+func (t *stdioTransport) notify(_ context.Context, msg *message) error {
+	return t.write(msg)
+}
+
+// close ends the session as the spec describes: close stdin, give the
+// server a moment to exit, then kill it.
+func (t *stdioTransport) close() error {
+	t.shutdown()
+	_ = t.stdin.Close()
+	select {
+	case <-t.exited:
+	case <-time.After(2 * time.Second):
+		_ = t.cmd.Process.Kill()
+		<-t.exited
+	}
+	return nil
+}
+
+func (t *stdioTransport) shutdown() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.closed {
+		t.closed = true
+		close(t.done)
+	}
+}
+
+// exitError adds the server's last stderr output to err, which usually
+// explains why it stopped.
+func (t *stdioTransport) exitError(err error) error {
+	// stderr is fully copied once the process has been reaped
+	select {
+	case <-t.exited:
+	case <-time.After(time.Second):
+	}
+	if tail := strings.TrimSpace(t.stderr.String()); tail != "" {
+		return fmt.Errorf("mcp server %q: %w: %s", t.name, err, tail)
+	}
+	return fmt.Errorf("mcp server %q: %w", t.name, err)
+}
+
+func (t *stdioTransport) write(msg *message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal mcp message: %w", err)
+	}
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	_, err = t.stdin.Write(append(data, '\n'))
+	return err
+}
+
+func (t *stdioTransport) readLoop(r io.Reader) {
+	defer t.shutdown()
+
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(strings.TrimSpace(string(line))) > 0 {
+			var msg message
+			if jsonErr := json.Unmarshal(line, &msg); jsonErr != nil {
+				// Servers must not write anything else to stdout, but some log there
+				logMCP("mcp ignoring non-JSON stdout line", jsonErr)
+			} else {
+				t.dispatch(&msg)
+			}
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				logMCP("mcp read failed", err)
+			}
+			return
+		}
+	}
+}
+
+func (t *stdioTransport) dispatch(msg *message) {
+	if msg.Method == "" && msg.ID != nil {
+		t.mu.Lock()
+		ch, ok := t.pending[string(msg.ID)]
+		t.mu.Unlock()
+		if ok {
+			ch <- msg
+		}
+		return
+	}
+	go dispatchIncoming(msg, t.handler, func(resp *message) { _ = t.write(resp) })
+}
+
+// tailBuffer keeps the last maxStderrTail bytes written to it.
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > maxStderrTail {
+		b.buf = b.buf[len(b.buf)-maxStderrTail:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
@@ -21,6 +21,10 @@ type Correction struct {
 	LastUsed    time.Time `json:"last_used"`
 }
 
+// minCorrectionSimilarity is the cosine similarity a correction needs to be
+// relevant when its trigger and context do not match literally
+const minCorrectionSimilarity = 0.6
+
 // CorrectionMemory learns from mistakes and applies corrections
 type CorrectionMemory struct {
 	store *Store
@@ -29,14 +33,24 @@ type CorrectionMemory struct {
 // NewCorrectionMemory creates a new correction memory
 // Uses global config directory for corrections that apply across projects
 func NewCorrectionMemory() (*CorrectionMemory, error) {
-	store, err := NewStore("~/.config/vecai/corrections")
+	c := &CorrectionMemory{}
+
+	// Embed what a correction is about, not its counters
+	cfg := DefaultStoreConfig()
+	cfg.EmbedText = func(entry *MemoryEntry) string {
+		if corr := c.parseCorrection(entry.Content); corr != nil {
+			return strings.Join([]string{corr.Trigger, corr.Problem, corr.Context}, "\n")
+		}
+		return entry.Content
+	}
+
+	store, err := NewStoreWithConfig("~/.config/vecai/corrections", cfg)
 	if err != nil {
 		return nil, err
 	}
+	c.store = store
 
-	return &CorrectionMemory{
-		store: store,
-	}, nil
+	return c, nil
 }
 
 // Learn records a new correction
@@ -73,36 +87,52 @@ func (c *CorrectionMemory) Learn(trigger, problem, solution, context string) err
 	return c.store.Add(entry)
 }
 
-// FindRelevant finds corrections relevant to the current context
+// SetEmbedder enables semantic matching of corrections
+func (c *CorrectionMemory) SetEmbedder(e Embedder) {
+	c.store.SetEmbedder(e)
+}
+
+// FindRelevant finds corrections relevant to the current context.
+// Literal trigger or context matches come first, then corrections ranked by
+// embedding similarity; ties go to the most useful correction.
 func (c *CorrectionMemory) FindRelevant(errorMessage, context string) []Correction {
-	entries := c.store.List(MemoryTypeCorrection)
+	query := strings.TrimSpace(errorMessage + "\n" + context)
+	ranked := c.store.Rank(query, MemoryTypeCorrection)
 
 	var relevant []Correction
-	for _, entry := range entries {
-		correction := c.parseCorrection(entry.Content)
+	var scores []float64
+	for _, se := range ranked {
+		correction := c.parseCorrection(se.Entry.Content)
 		if correction == nil {
 			continue
 		}
 
-		// Check if trigger matches
-		if c.matches(correction.Trigger, errorMessage) {
-			relevant = append(relevant, *correction)
-			continue
+		score := -1.0
+		switch {
+		case c.matches(correction.Trigger, errorMessage):
+			// Check if trigger matches
+			score = 1
+		case context != "" && c.matches(correction.Context, context):
+			// Check if context matches
+			score = 1
+		case se.Semantic && se.Score >= minCorrectionSimilarity:
+			score = se.Score
 		}
-
-		// Check if context matches
-		if context != "" && c.matches(correction.Context, context) {
-			relevant = append(relevant, *correction)
+		if score < 0 {
+			continue
 		}
+		relevant = append(relevant, *correction)
+		scores = append(scores, score)
 	}
 
-	// Sort by use count and success rate (most useful first)
+	// Sort by similarity, then use count and success rate (most useful first)
 	for i := 0; i < len(relevant); i++ {
 		for j := i + 1; j < len(relevant); j++ {
-			scoreI := float64(relevant[i].UseCount) * relevant[i].SuccessRate
-			scoreJ := float64(relevant[j].UseCount) * relevant[j].SuccessRate
-			if scoreJ > scoreI {
+			usefulI := float64(relevant[i].UseCount) * relevant[i].SuccessRate
+			usefulJ := float64(relevant[j].UseCount) * relevant[j].SuccessRate
+			if scores[j] > scores[i] || scores[j] == scores[i] && usefulJ > usefulI {
 				relevant[i], relevant[j] = relevant[j], relevant[i]
+				scores[i], scores[j] = scores[j], scores[i]
 			}
 		}
 	}
@@ -205,8 +235,9 @@ func (c *CorrectionMemory) generateID(trigger, problem string) string {
 }
 
 func (c *CorrectionMemory) matches(pattern, text string) bool {
-	// Simple substring matching (case-insensitive)
-	return strings.Contains(
+	// Simple substring matching (case-insensitive); an empty pattern
+	// would match everything
+	return pattern != "" && strings.Contains(
 		strings.ToLower(text),
 		strings.ToLower(pattern),
 	)
@@ -0,0 +1,145 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// embedTimeout bounds a single embedding request
+	embedTimeout = 30 * time.Second
+	// embedRetryAfter is how long an embedder stays off after a failure,
+	// so an unreachable Ollama does not slow down every lookup
+	embedRetryAfter = time.Minute
+	// maxBackfillBatch caps how many stored entries are embedded per lookup
+	maxBackfillBatch = 64
+)
+
+// Embedder turns texts into vectors for semantic search.
+type Embedder interface {
+	// Model identifies the embedding model; vectors from different models
+	// are never compared.
+	Model() string
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// OllamaEmbedder calls Ollama's /api/embed endpoint.
+type OllamaEmbedder struct {
+	baseURL    string
+	model      string
+	httpClient *http.Client
+
+	mu       sync.Mutex
+	failedAt time.Time
+}
+
+// NewOllamaEmbedder creates an embedder for the given Ollama server and model.
+func NewOllamaEmbedder(baseURL, model string) *OllamaEmbedder {
+	if baseURL == "" {
+		baseURL = "http://localhost:11434"
+	}
+	return &OllamaEmbedder{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		model:      model,
+		httpClient: &http.Client{Timeout: embedTimeout},
+	}
+}
+
+// Model returns the embedding model name
+func (e *OllamaEmbedder) Model() string {
+	return e.model
+}
+
+// Embed returns one vector per text
+func (e *OllamaEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+
+	e.mu.Lock()
+	recentlyFailed := !e.failedAt.IsZero() && time.Since(e.failedAt) < embedRetryAfter
+	e.mu.Unlock()
+	if recentlyFailed {
+		return nil, fmt.Errorf("embedding model %s unavailable", e.model)
+	}
+
+	vectors, err := e.embed(ctx, texts)
+
+	e.mu.Lock()
+	if err != nil {
+		e.failedAt = time.Now()
+	} else {
+		e.failedAt = time.Time{}
+	}
+	e.mu.Unlock()
+	return vectors, err
+}
+
+func (e *OllamaEmbedder) embed(ctx context.Context, texts []string) ([][]float32, error) {
+	body, err := json.Marshal(map[string]any{"model": e.model, "input": texts})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", e.baseURL+"/api/embed", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("embedding request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("embedding request failed (%d): %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	var result struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to parse embedding response: %w", err)
+	}
+	if len(result.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(result.Embeddings))
+	}
+	return result.Embeddings, nil
+}
+
+// embeddingKey identifies the model and text a vector was computed from.
+// A stored vector is only used while its key still matches.
+func embeddingKey(model, text string) string {
+	hash := sha256.Sum256([]byte(model + "\x00" + text))
+	return hex.EncodeToString(hash[:8])
+}
+
+// cosineSimilarity returns the cosine of the angle between a and b, or 0
+// when they cannot be compared.
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
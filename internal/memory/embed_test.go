@@ -0,0 +1,290 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// conceptEmbedder maps words to concept dimensions so that synonyms embed
+// close together while sharing no substring.
+type conceptEmbedder struct {
+	model string
+	fail  bool
+
+	mu    sync.Mutex
+	texts []string
+}
+
+var concepts = map[string]int{
+	"crash": 0, "panic": 0, "segfault": 0,
+	"database": 1, "postgres": 1, "sql": 1,
+	"deploy": 2, "release": 2, "ship": 2,
+	"test": 3, "tests": 3, "flaky": 3,
+}
+
+func (e *conceptEmbedder) Model() string { return e.model }
+
+func (e *conceptEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	if e.fail {
+		return nil, errors.New("model not found")
+	}
+	e.mu.Lock()
+	e.texts = append(e.texts, texts...)
+	e.mu.Unlock()
+
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		v := make([]float32, 5)
+		for _, word := range tokenize(text) {
+			if dim, ok := concepts[word]; ok {
+				v[dim]++
+			} else {
+				v[4] += 0.1
+			}
+		}
+		vectors[i] = v
+	}
+	return vectors, nil
+}
+
+func (e *conceptEmbedder) embedded() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]string(nil), e.texts...)
+}
+
+func testStoreConfig() StoreConfig {
+	cfg := DefaultStoreConfig()
+	cfg.PruneInterval = 0
+	cfg.WriteDebounce = 0
+	return cfg
+}
+
+func TestStore_SemanticSearch(t *testing.T) {
+	store, err := NewStoreWithConfig(t.TempDir(), testStoreConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	store.SetEmbedder(&conceptEmbedder{model: "concepts"})
+
+	for id, content := range map[string]string{
+		"db":     "postgres connection pool exhausted",
+		"crash":  "server panic on nil map",
+		"deploy": "release checklist",
+	} {
+		if err := store.Add(&MemoryEntry{ID: id, Type: MemoryTypeProject, Content: content}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entry, _ := store.Get("db")
+	if len(entry.Embedding) == 0 || entry.EmbeddingKey == "" {
+		t.Fatal("expected Add to store a vector")
+	}
+
+	results := store.Search("database errors", MemoryTypeProject, 5)
+	if len(results) != 1 || results[0].ID != "db" {
+		t.Errorf("expected semantic match on db, got %v", ids(results))
+	}
+	if results := store.Search("segfault", "", 5); len(results) != 1 || results[0].ID != "crash" {
+		t.Errorf("expected semantic match on crash, got %v", ids(results))
+	}
+
+	// A literal match is kept even when its similarity is low
+	results = store.Search("pool", MemoryTypeProject, 5)
+	if len(results) != 1 || results[0].ID != "db" {
+		t.Errorf("expected literal match on db, got %v", ids(results))
+	}
+}
+
+func TestStore_KeywordFallback(t *testing.T) {
+	store, err := NewStoreWithConfig(t.TempDir(), testStoreConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	store.SetEmbedder(&conceptEmbedder{model: "concepts", fail: true})
+
+	if err := store.Add(&MemoryEntry{ID: "db", Type: MemoryTypeProject, Content: "Postgres pool exhausted"}); err != nil {
+		t.Fatal(err)
+	}
+	if results := store.Search("database", MemoryTypeProject, 5); len(results) != 0 {
+		t.Errorf("keyword search should not match synonyms, got %v", ids(results))
+	}
+	if results := store.Search("postgres", MemoryTypeProject, 5); len(results) != 1 {
+		t.Errorf("expected case-insensitive keyword match, got %v", ids(results))
+	}
+}
+
+func TestStore_BackfillsExistingStore(t *testing.T) {
+	dir := t.TempDir()
+
+	// A store written before embeddings existed
+	legacy := map[string]*MemoryEntry{
+		"db":  {ID: "db", Type: MemoryTypeProject, Content: "postgres migrations", UpdatedAt: time.Now()},
+		"old": {ID: "old", Type: MemoryTypeSession, Content: "flaky tests", UpdatedAt: time.Now()},
+	}
+	data, _ := json.Marshal(legacy)
+	if err := os.WriteFile(filepath.Join(dir, "memory.json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := NewStoreWithConfig(dir, testStoreConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	embedder := &conceptEmbedder{model: "concepts"}
+	store.SetEmbedder(embedder)
+
+	if results := store.Search("sql", MemoryTypeProject, 5); len(results) != 1 || results[0].ID != "db" {
+		t.Fatalf("expected backfilled entry to match, got %v", ids(results))
+	}
+	// Only entries of the searched type are backfilled
+	if old, _ := store.Get("old"); old.EmbeddingKey != "" {
+		t.Error("expected other memory types to stay unembedded")
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Vectors are persisted, so a reopened store does not embed them again
+	reopened, err := NewStoreWithConfig(dir, testStoreConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	again := &conceptEmbedder{model: "concepts"}
+	reopened.SetEmbedder(again)
+	reopened.Search("sql", MemoryTypeProject, 5)
+	if texts := again.embedded(); len(texts) != 1 || texts[0] != "sql" {
+		t.Errorf("expected only the query to be embedded, got %q", texts)
+	}
+
+	// Switching models invalidates stored vectors
+	other := &conceptEmbedder{model: "other"}
+	reopened.SetEmbedder(other)
+	reopened.Search("sql", MemoryTypeProject, 5)
+	if texts := other.embedded(); len(texts) != 2 {
+		t.Errorf("expected re-embedding for a new model, got %q", texts)
+	}
+}
+
+func TestCorrectionMemory_FindRelevantSemantic(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	c, err := NewCorrectionMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.SetEmbedder(&conceptEmbedder{model: "concepts"})
+
+	if err := c.Learn("segfault in worker", "nil pointer", "check the map", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Learn("release failed", "missing tag", "push tags first", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	relevant := c.FindRelevant("panic: runtime error", "")
+	if len(relevant) != 1 || relevant[0].Problem != "nil pointer" {
+		t.Errorf("expected the crash correction, got %+v", relevant)
+	}
+	if relevant := c.FindRelevant("", "ship it"); len(relevant) != 1 || relevant[0].Problem != "missing tag" {
+		t.Errorf("expected the release correction, got %+v", relevant)
+	}
+}
+
+func TestSolutionCache_FindSimilarSemantic(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	s, err := NewSolutionCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	embedder := &conceptEmbedder{model: "concepts"}
+	s.SetEmbedder(embedder)
+
+	if err := s.Cache("fix flaky tests", "add retries to the fixture", nil); err != nil {
+		t.Fatal(err)
+	}
+	if sol := s.FindSimilar("flaky test"); sol == nil || sol.Solution != "add retries to the fixture" {
+		t.Errorf("expected semantic match, got %+v", sol)
+	}
+	if sol := s.FindSimilar("postgres crash"); sol != nil {
+		t.Errorf("expected no match for an unrelated request, got %+v", sol)
+	}
+	// Only the request is embedded, never the solution text
+	for _, text := range embedder.embedded() {
+		if strings.Contains(text, "retries") {
+			t.Errorf("solution text was embedded: %q", text)
+		}
+	}
+}
+
+func TestOllamaEmbedder(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		var req struct {
+			Model string   `json:"model"`
+			Input []string `json:"input"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if r.URL.Path != "/api/embed" || req.Model != "nomic-embed-text" {
+			http.Error(w, `{"error":"model not found"}`, http.StatusNotFound)
+			return
+		}
+		embeddings := make([][]float32, len(req.Input))
+		for i := range req.Input {
+			embeddings[i] = []float32{float32(i), 1}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"embeddings": embeddings})
+	}))
+	defer srv.Close()
+
+	e := NewOllamaEmbedder(srv.URL, "nomic-embed-text")
+	vectors, err := e.Embed(context.Background(), []string{"a", "b"})
+	if err != nil || len(vectors) != 2 || vectors[1][0] != 1 {
+		t.Fatalf("Embed = %v, %v", vectors, err)
+	}
+
+	missing := NewOllamaEmbedder(srv.URL, "missing")
+	if _, err := missing.Embed(context.Background(), []string{"a"}); err == nil || !strings.Contains(err.Error(), "model not found") {
+		t.Errorf("expected server error, got %v", err)
+	}
+	// A failed model is not retried immediately
+	before := calls
+	if _, err := missing.Embed(context.Background(), []string{"a"}); err == nil || calls != before {
+		t.Errorf("expected cached failure without a request, got %v after %d calls", err, calls-before)
+	}
+}
+
+func TestCosineSimilarity(t *testing.T) {
+	if got := cosineSimilarity([]float32{1, 0}, []float32{2, 0}); got < 0.999 {
+		t.Errorf("parallel vectors = %f", got)
+	}
+	if got := cosineSimilarity([]float32{1, 0}, []float32{0, 1}); got != 0 {
+		t.Errorf("orthogonal vectors = %f", got)
+	}
+	if got := cosineSimilarity([]float32{1}, []float32{1, 0}); got != 0 {
+		t.Errorf("mismatched lengths = %f", got)
+	}
+}
+
+func ids(entries []*MemoryEntry) []string {
+	var out []string
+	for _, e := range entries {
+		out = append(out, e.ID)
+	}
+	return out
+}
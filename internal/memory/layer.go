@@ -56,6 +56,20 @@ func NewMemoryLayer(projectPath string) (*MemoryLayer, error) {
 	return layer, nil
 }
 
+// SetEmbedder enables embedding-based search in the persistent stores.
+// Stores fall back to keyword matching whenever e is unavailable.
+func (m *MemoryLayer) SetEmbedder(e Embedder) {
+	if m.Project != nil {
+		m.Project.store.SetEmbedder(e)
+	}
+	if m.Corrections != nil {
+		m.Corrections.SetEmbedder(e)
+	}
+	if m.Solutions != nil {
+		m.Solutions.SetEmbedder(e)
+	}
+}
+
 // GetContextEnrichment returns formatted memory context for inclusion in prompts
 func (m *MemoryLayer) GetContextEnrichment(query string) string {
 	var sections []string
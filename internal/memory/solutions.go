@@ -28,15 +28,31 @@ type SolutionCache struct {
 // NewSolutionCache creates a new solution cache
 // Uses global config directory for solutions that apply across projects
 func NewSolutionCache() (*SolutionCache, error) {
-	store, err := NewStore("~/.config/vecai/solutions")
+	s := &SolutionCache{
+		similarityThreshold: 0.85,
+	}
+
+	// Requests are compared with requests, so embed only the request
+	cfg := DefaultStoreConfig()
+	cfg.EmbedText = func(entry *MemoryEntry) string {
+		if sol := s.parseSolution(entry.Content); sol != nil {
+			return sol.Request
+		}
+		return entry.Content
+	}
+
+	store, err := NewStoreWithConfig("~/.config/vecai/solutions", cfg)
 	if err != nil {
 		return nil, err
 	}
+	s.store = store
 
-	return &SolutionCache{
-		store:           store,
-		similarityThreshold: 0.85,
-	}, nil
+	return s, nil
+}
+
+// SetEmbedder enables semantic matching of requests
+func (s *SolutionCache) SetEmbedder(e Embedder) {
+	s.store.SetEmbedder(e)
 }
 
 // Cache stores a successful solution
@@ -80,20 +96,23 @@ func (s *SolutionCache) Cache(request, solution string, tags []string) error {
 	return s.store.Add(entry)
 }
 
-// FindSimilar finds a cached solution for a similar request
+// FindSimilar finds a cached solution for a similar request. Requests are
+// compared by embedding cosine similarity when available, otherwise by
+// token overlap.
 func (s *SolutionCache) FindSimilar(request string) *Solution {
-	entries := s.store.List(MemoryTypeSolution)
-
 	var bestMatch *Solution
 	var bestScore float64
 
-	for _, entry := range entries {
-		solution := s.parseSolution(entry.Content)
+	for _, se := range s.store.Rank(request, MemoryTypeSolution) {
+		solution := s.parseSolution(se.Entry.Content)
 		if solution == nil {
 			continue
 		}
 
-		score := s.calculateSimilarity(request, solution.Request)
+		score := se.Score
+		if !se.Semantic {
+			score = s.calculateSimilarity(request, solution.Request)
+		}
 		if score >= s.similarityThreshold && score > bestScore {
 			bestScore = score
 			bestMatch = solution
@@ -1,9 +1,12 @@
 package memory
 
 import (
+	"context"
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
@@ -25,8 +28,14 @@ type StoreConfig struct {
 	DefaultTTL    time.Duration // Default TTL for entries (default: 0 = no expiry)
 	PruneInterval time.Duration // Auto-prune interval (default: 1h)
 	WriteDebounce time.Duration // Debounce writes (default: 5s)
+
+	// EmbedText returns the text embedded for semantic search (default: Content)
+	EmbedText func(*MemoryEntry) string
 }
 
+// minSearchSimilarity is the cosine similarity an entry needs to match a Search
+const minSearchSimilarity = 0.5
+
 // DefaultStoreConfig returns sensible defaults for store configuration
 func DefaultStoreConfig() StoreConfig {
 	return StoreConfig{
@@ -40,15 +49,16 @@ func DefaultStoreConfig() StoreConfig {
 
 // MemoryEntry represents a single memory entry
 type MemoryEntry struct {
-	ID        string            `json:"id"`
-	Type      MemoryType        `json:"type"`
-	Content   string            `json:"content"`
-	Embedding []float32         `json:"embedding,omitempty"` // For semantic search
-	Metadata  map[string]string `json:"metadata,omitempty"`
-	UseCount  int               `json:"use_count"`
-	CreatedAt time.Time         `json:"created_at"`
-	UpdatedAt time.Time         `json:"updated_at"`
-	ExpiresAt time.Time         `json:"expires_at,omitempty"`
+	ID           string            `json:"id"`
+	Type         MemoryType        `json:"type"`
+	Content      string            `json:"content"`
+	Embedding    []float32         `json:"embedding,omitempty"`     // For semantic search
+	EmbeddingKey string            `json:"embedding_key,omitempty"` // Model and text Embedding was computed from
+	Metadata     map[string]string `json:"metadata,omitempty"`
+	UseCount     int               `json:"use_count"`
+	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+	ExpiresAt    time.Time         `json:"expires_at,omitempty"`
 }
 
 // Store provides persistent memory storage
@@ -57,6 +67,7 @@ type Store struct {
 	entries  map[string]*MemoryEntry
 	mu       sync.RWMutex
 	config   StoreConfig
+	embedder Embedder
 
 	// Write debouncing
 	savePending bool
@@ -111,8 +122,27 @@ func NewStoreWithConfig(basePath string, cfg StoreConfig) (*Store, error) {
 	return store, nil
 }
 
+// SetEmbedder enables semantic search with e. Entries stored without a
+// vector for e's model are embedded lazily as they are searched.
+func (s *Store) SetEmbedder(e Embedder) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.embedder = e
+}
+
 // Add adds a new entry to the store
 func (s *Store) Add(entry *MemoryEntry) error {
+	// Embed outside the lock; on failure the entry is backfilled later
+	if embedder := s.currentEmbedder(); embedder != nil {
+		text := s.embedText(entry)
+		ctx, cancel := context.WithTimeout(context.Background(), embedTimeout)
+		if vectors, err := embedder.Embed(ctx, []string{text}); err == nil {
+			entry.Embedding = vectors[0]
+			entry.EmbeddingKey = embeddingKey(embedder.Model(), text)
+		}
+		cancel()
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -190,33 +220,138 @@ func (s *Store) List(memType MemoryType) []*MemoryEntry {
 	return result
 }
 
-// Search finds entries matching a query (simple substring match)
-// For more advanced search, use with veclite/embeddings
+// Search finds entries matching a query. Entries containing the query
+// (case-insensitive) always match and come first; with an embedder, entries
+// similar enough to the query follow. Each group is ranked by cosine
+// similarity.
 func (s *Store) Search(query string, memType MemoryType, limit int) []*MemoryEntry {
+	var literal, semantic []*MemoryEntry
+	for _, se := range s.Rank(query, memType) {
+		switch {
+		case contains(se.Entry.Content, query):
+			literal = append(literal, se.Entry)
+		case se.Semantic && se.Score >= minSearchSimilarity:
+			semantic = append(semantic, se.Entry)
+		}
+	}
+	result := append(literal, semantic...)
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+// ScoredEntry is an entry with its similarity to a query
+type ScoredEntry struct {
+	Entry    *MemoryEntry
+	Score    float64 // Cosine similarity; only meaningful when Semantic
+	Semantic bool    // False when the query or entry could not be embedded
+}
+
+// Rank returns the unexpired entries of memType (all types if empty), those
+// scored by embedding similarity first in descending order. Without an
+// embedder, or if the query cannot be embedded, no entry is Semantic and
+// callers should fall back to keyword matching.
+func (s *Store) Rank(query string, memType MemoryType) []ScoredEntry {
+	var queryVec []float32
+	var model string
+	if embedder := s.currentEmbedder(); embedder != nil && strings.TrimSpace(query) != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), embedTimeout)
+		vectors, err := embedder.Embed(ctx, []string{query})
+		if err == nil {
+			queryVec = vectors[0]
+			model = embedder.Model()
+			s.backfill(ctx, embedder, memType)
+		} else {
+			logWarn("Memory embedding failed, using keyword search: %v", err)
+		}
+		cancel()
+	}
+
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	now := time.Now()
-	var result []*MemoryEntry
+	var result []ScoredEntry
 	for _, entry := range s.entries {
 		if memType != "" && entry.Type != memType {
 			continue
 		}
-
-		// Skip expired entries
 		if !entry.ExpiresAt.IsZero() && now.After(entry.ExpiresAt) {
 			continue
 		}
+		se := ScoredEntry{Entry: entry}
+		if queryVec != nil && entry.EmbeddingKey == embeddingKey(model, s.embedText(entry)) {
+			se.Score = cosineSimilarity(queryVec, entry.Embedding)
+			se.Semantic = true
+		}
+		result = append(result, se)
+	}
 
-		// Simple substring match
-		if contains(entry.Content, query) {
-			result = append(result, entry)
-			if limit > 0 && len(result) >= limit {
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Semantic != result[j].Semantic {
+			return result[i].Semantic
+		}
+		return result[i].Score > result[j].Score
+	})
+	return result
+}
+
+// backfill embeds up to maxBackfillBatch entries of memType whose vector is
+// missing or stale, e.g. entries loaded from a store written before
+// embeddings were enabled or with another model.
+func (s *Store) backfill(ctx context.Context, embedder Embedder, memType MemoryType) {
+	model := embedder.Model()
+
+	s.mu.RLock()
+	var ids, texts []string
+	for id, entry := range s.entries {
+		if memType != "" && entry.Type != memType {
+			continue
+		}
+		text := s.embedText(entry)
+		if entry.EmbeddingKey != embeddingKey(model, text) {
+			ids = append(ids, id)
+			texts = append(texts, text)
+			if len(ids) >= maxBackfillBatch {
 				break
 			}
 		}
 	}
-	return result
+	s.mu.RUnlock()
+
+	if len(ids) == 0 {
+		return
+	}
+	vectors, err := embedder.Embed(ctx, texts)
+	if err != nil {
+		logWarn("Memory embedding backfill failed: %v", err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, id := range ids {
+		// Skip entries removed or changed while embedding
+		if entry, ok := s.entries[id]; ok && s.embedText(entry) == texts[i] {
+			entry.Embedding = vectors[i]
+			entry.EmbeddingKey = embeddingKey(model, texts[i])
+		}
+	}
+	_ = s.debouncedSave()
+}
+
+func (s *Store) currentEmbedder() Embedder {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.embedder
+}
+
+func (s *Store) embedText(entry *MemoryEntry) string {
+	if s.config.EmbedText != nil {
+		return s.config.EmbedText(entry)
+	}
+	return entry.Content
 }
 
 // IncrementUseCount increases the use count for an entry
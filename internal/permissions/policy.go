@@ -2,6 +2,7 @@ package permissions
 
 import (
 	"fmt"
+	"os"
 	"strings"
 	"sync"
 
@@ -38,9 +39,9 @@ type Decision int
 
 const (
 	DecisionAllow       Decision = iota // Allow this time
-	DecisionAlwaysAllow                 // Always allow this tool
+	DecisionAlwaysAllow                 // Always allow this tool (or call, with rules)
 	DecisionDeny                        // Deny this time
-	DecisionNeverAllow                  // Never allow this tool
+	DecisionNeverAllow                  // Never allow this tool (or call, with rules)
 )
 
 // InputHandler interface for getting user input
@@ -53,6 +54,12 @@ type OutputHandler interface {
 	PermissionPrompt(toolName string, level tools.PermissionLevel, description string)
 }
 
+// warningOutput is implemented by output handlers that can show warnings.
+// Without it, warnings go to stderr so stdout stays machine-readable.
+type warningOutput interface {
+	Warning(msg string)
+}
+
 // Policy manages permission checking
 type Policy struct {
 	mode    Mode
@@ -61,6 +68,10 @@ type Policy struct {
 	output  OutputHandler
 	cache   map[string]Decision
 	cacheMu sync.RWMutex
+	rules   *RuleSet // Optional: project rules, consulted before the cache
+
+	overrides *RuleSet         // Optional: unsaved rules that take precedence, e.g. from flags
+	workspace *tools.Workspace // Optional: where rule paths resolve, e.g. an isolated worktree
 }
 
 // NewPolicy creates a new permission policy
@@ -73,49 +84,187 @@ func NewPolicy(mode Mode, input InputHandler, output OutputHandler) *Policy {
 	}
 }
 
-// Check checks if a tool execution is allowed
-func (p *Policy) Check(toolName string, level tools.PermissionLevel, description string) (bool, error) {
+// SetRules installs a rule set. With rules, "always" and "never" answers
+// are saved as rules scoped to the call instead of cached for the whole tool.
+func (p *Policy) SetRules(rules *RuleSet) {
+	p.cacheMu.Lock()
+	defer p.cacheMu.Unlock()
+	p.rules = rules
+}
+
+// Rules returns the installed rule set, or nil.
+func (p *Policy) Rules() *RuleSet {
+	p.cacheMu.RLock()
+	defer p.cacheMu.RUnlock()
+	return p.rules
+}
+
+// SetOverrides installs rules that take precedence over the project rules
+// and are never saved, such as those given on the command line. Override
+// deny rules apply first; override allow rules apply after project deny
+// rules, so a broad flag cannot lift a project's ban.
+func (p *Policy) SetOverrides(rules *RuleSet) {
+	p.cacheMu.Lock()
+	defer p.cacheMu.Unlock()
+	p.overrides = rules
+}
+
+// SetWorkspace makes rule paths resolve against ws, the workspace of the
+// tools this policy guards, so rules follow them into a worktree.
+func (p *Policy) SetWorkspace(ws *tools.Workspace) {
+	p.cacheMu.Lock()
+	defer p.cacheMu.Unlock()
+	p.workspace = ws
+}
+
+func (p *Policy) getWorkspace() *tools.Workspace {
+	p.cacheMu.RLock()
+	defer p.cacheMu.RUnlock()
+	return p.workspace
+}
+
+// Overrides returns the installed override rules, or nil.
+func (p *Policy) Overrides() *RuleSet {
+	p.cacheMu.RLock()
+	defer p.cacheMu.RUnlock()
+	return p.overrides
+}
+
+// Source says who or what made a permission decision.
+type Source string
+
+const (
+	SourceAuto   Source = "auto"   // The permission mode's defaults
+	SourceRule   Source = "rule"   // A project rule
+	SourceCached Source = "cached" // An earlier "always"/"never" answer this session
+	SourceUser   Source = "user"   // The user, at a prompt
+	SourceCmd    Source = "cmd"    // An external command answering the prompt
+	SourceHook   Source = "hook"   // A PreToolUse hook blocking the call
+)
+
+// Verdict is the outcome of evaluating a tool call.
+type Verdict struct {
+	Allowed bool
+	Prompt  bool   // The user must be asked; Allowed is meaningless until then
+	Before  int    // Index of the rule that asked for the prompt (-1 if none), for Remember
+	Source  Source // Who decided
+	Rule    string // The matching rule, when Source is SourceRule
+}
+
+// Evaluate decides a tool call without prompting.
+//
+// Deny rules apply in every mode, override rules before project rules.
+// Auto and analysis modes otherwise behave as before; in ask and strict modes
+// the first matching override or project rule decides, then cached
+// decisions, then the mode's defaults.
+func (p *Policy) Evaluate(toolName string, level tools.PermissionLevel, input map[string]any) Verdict {
 	currentMode := p.GetMode()
+	v := Verdict{Before: -1, Source: SourceAuto}
+
+	var override, rule Rule
+	overridden, matched := false, false
+	if overrides := p.Overrides(); overrides != nil {
+		_, override, overridden = overrides.match(p.getWorkspace(), toolName, input)
+	}
+	if overridden && override.Action == ActionDeny {
+		v.Source, v.Rule = SourceRule, override.String()
+		return v
+	}
+	if rules := p.Rules(); rules != nil {
+		v.Before, rule, matched = rules.match(p.getWorkspace(), toolName, input)
+	}
+	if matched && rule.Action == ActionDeny {
+		v.Source, v.Rule = SourceRule, rule.String()
+		return v
+	}
+	if overridden {
+		v.Before, rule, matched = -1, override, true
+	}
 
 	// Auto mode always allows
 	if currentMode == ModeAuto {
-		return true, nil
+		v.Allowed = true
+		return v
 	}
 
 	// Analysis mode: auto-approve reads, block writes/executes (no prompts)
 	if currentMode == ModeAnalysis {
-		if level == tools.PermissionRead {
-			return true, nil
+		v.Allowed = level == tools.PermissionRead
+		return v
+	}
+
+	if matched {
+		v.Source, v.Rule = SourceRule, rule.String()
+		if rule.Action == ActionAllow {
+			v.Allowed = true
+		} else {
+			v.Prompt = true // ActionAsk
 		}
-		// Block writes and executes in analysis mode
-		return false, nil
+		return v
 	}
 
-	// Check cache first
-	p.cacheMu.RLock()
-	if decision, ok := p.cache[toolName]; ok {
-		p.cacheMu.RUnlock()
+	// Check cache
+	if decision, ok := p.GetCachedDecision(toolName); ok {
 		switch decision {
 		case DecisionAlwaysAllow:
-			return true, nil
+			v.Allowed, v.Source = true, SourceCached
+			return v
 		case DecisionNeverAllow:
-			return false, nil
+			v.Source = SourceCached
+			return v
 		}
-	} else {
-		p.cacheMu.RUnlock()
 	}
 
 	// In ask mode, auto-approve reads
 	if currentMode == ModeAsk && level == tools.PermissionRead {
-		return true, nil
+		v.Allowed = true
+		return v
 	}
 
 	// Need to prompt user
-	return p.promptUser(toolName, level, description)
+	v.Prompt = true
+	return v
+}
+
+// Remember records an "always" or "never" answer for a call. With rules it
+// inserts a rule scoped to the call ahead of the rule at index before (or at
+// the end) and saves it; without rules it caches the decision for the tool.
+// It returns a short description of what was remembered.
+func (p *Policy) Remember(toolName string, input map[string]any, decision Decision, before int) (string, error) {
+	rules := p.Rules()
+	if rules == nil {
+		p.CacheDecision(toolName, decision)
+		return toolName, nil
+	}
+	action := ActionAllow
+	if decision == DecisionNeverAllow {
+		action = ActionDeny
+	}
+	rule := rules.scopedRule(p.getWorkspace(), toolName, input, action)
+	if err := rules.Insert(before, rule); err != nil {
+		// Still honor the answer for this session
+		p.CacheDecision(toolName, decision)
+		return rule.String(), fmt.Errorf("failed to save permission rule: %w", err)
+	}
+	return rule.String(), nil
+}
+
+// Check checks if a tool execution is allowed
+func (p *Policy) Check(toolName string, level tools.PermissionLevel, description string) (bool, error) {
+	return p.CheckInput(toolName, level, description, nil)
+}
+
+// CheckInput checks if a tool call is allowed, matching rules against its input.
+func (p *Policy) CheckInput(toolName string, level tools.PermissionLevel, description string, input map[string]any) (bool, error) {
+	v := p.Evaluate(toolName, level, input)
+	if !v.Prompt {
+		return v.Allowed, nil
+	}
+	return p.promptUser(toolName, level, description, input, v.Before)
 }
 
 // promptUser asks the user for permission
-func (p *Policy) promptUser(toolName string, level tools.PermissionLevel, description string) (bool, error) {
+func (p *Policy) promptUser(toolName string, level tools.PermissionLevel, description string, input map[string]any, before int) (bool, error) {
 	p.output.PermissionPrompt(toolName, level, description)
 
 	prompt := "[y]es / [n]o / [a]lways / ne[v]er: "
@@ -134,24 +283,33 @@ func (p *Policy) promptUser(toolName string, level tools.PermissionLevel, descri
 		return false, nil
 
 	case "a", "always":
-		p.cacheMu.Lock()
-		p.cache[toolName] = DecisionAlwaysAllow
-		p.cacheMu.Unlock()
+		if _, err := p.Remember(toolName, input, DecisionAlwaysAllow, before); err != nil {
+			p.warn(err.Error())
+		}
 		return true, nil
 
 	case "v", "never":
-		p.cacheMu.Lock()
-		p.cache[toolName] = DecisionNeverAllow
-		p.cacheMu.Unlock()
+		if _, err := p.Remember(toolName, input, DecisionNeverAllow, before); err != nil {
+			p.warn(err.Error())
+		}
 		return false, nil
 
 	default:
 		// Default to deny for unrecognized input
-		fmt.Println("Unrecognized response, denying.")
+		p.warn("Unrecognized response, denying.")
 		return false, nil
 	}
 }
 
+// warn shows msg through the output handler if it can, otherwise on stderr.
+func (p *Policy) warn(msg string) {
+	if w, ok := p.output.(warningOutput); ok {
+		w.Warning(msg)
+		return
+	}
+	fmt.Fprintln(os.Stderr, "Warning:", msg)
+}
+
 // GetMode returns the current permission mode (thread-safe)
 func (p *Policy) GetMode() Mode {
 	p.modeMu.RLock()
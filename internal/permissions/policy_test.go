@@ -18,12 +18,17 @@ func (m *mockInput) ReadLine(prompt string) (string, error) {
 // mockOutput implements OutputHandler for testing
 type mockOutput struct {
 	lastPrompt string
+	warnings   []string
 }
 
 func (m *mockOutput) PermissionPrompt(toolName string, level tools.PermissionLevel, description string) {
 	m.lastPrompt = toolName
 }
 
+func (m *mockOutput) Warning(msg string) {
+	m.warnings = append(m.warnings, msg)
+}
+
 func TestMode_String(t *testing.T) {
 	tests := []struct {
 		mode     Mode
@@ -142,6 +147,22 @@ func TestPolicy_ModeAsk_DenyOnNo(t *testing.T) {
 	}
 }
 
+func TestPolicy_ModeAsk_UnrecognizedResponse(t *testing.T) {
+	output := &mockOutput{}
+	policy := NewPolicy(ModeAsk, &mockInput{response: "maybe"}, output)
+
+	allowed, err := policy.Check("write_file", tools.PermissionWrite, "test")
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if allowed {
+		t.Error("expected an unrecognized response to deny")
+	}
+	if len(output.warnings) != 1 || output.warnings[0] != "Unrecognized response, denying." {
+		t.Errorf("expected a warning through the output handler, got %v", output.warnings)
+	}
+}
+
 func TestPolicy_CacheAlwaysAllow(t *testing.T) {
 	policy := NewPolicy(ModeAsk, &mockInput{response: "a"}, &mockOutput{})
 
@@ -0,0 +1,448 @@
+package permissions
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/abdul-hamid-achik/vecai/internal/tools"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultRulesFile is where project permission rules are loaded from,
+// relative to the project root.
+const DefaultRulesFile = ".vecai/permissions.yaml"
+
+// Action is the outcome of a matching permission rule.
+type Action string
+
+const (
+	ActionAllow Action = "allow" // Run without prompting
+	ActionAsk   Action = "ask"   // Always prompt, even for reads or cached tools
+	ActionDeny  Action = "deny"  // Refuse without prompting, in every mode
+)
+
+// Rule matches tool calls by tool name and, optionally, by the paths they
+// touch or the bash command they run. All conditions that are set must match.
+// An allow rule must cover every path and the whole command; deny and ask
+// rules match if any path or any command in the script does.
+type Rule struct {
+	Tool         string   `yaml:"tool"`                    // Tool name or glob ("*", "gpeek_*")
+	Paths        []string `yaml:"paths,omitempty"`         // Globs over project-relative paths; "**" spans directories
+	Command      string   `yaml:"command,omitempty"`       // Prefix of the bash command, on a word boundary
+	CommandRegex string   `yaml:"command_regex,omitempty"` // Regular expression searched in the bash command
+	Action       Action   `yaml:"action"`
+
+	commandRe *regexp.Regexp
+	pathRes   []*regexp.Regexp
+}
+
+// String renders the rule compactly, e.g. "bash: go test ./...".
+func (r Rule) String() string {
+	var scope []string
+	if r.Command != "" {
+		scope = append(scope, r.Command)
+	}
+	if r.CommandRegex != "" {
+		scope = append(scope, "/"+r.CommandRegex+"/")
+	}
+	scope = append(scope, r.Paths...)
+	if len(scope) == 0 {
+		return r.Tool
+	}
+	return r.Tool + ": " + strings.Join(scope, " ")
+}
+
+// compile validates the rule and prepares its patterns.
+func (r *Rule) compile() error {
+	if r.Tool == "" {
+		return fmt.Errorf("rule is missing tool")
+	}
+	if _, err := path.Match(r.Tool, ""); err != nil {
+		return fmt.Errorf("rule %q: invalid tool pattern: %w", r.Tool, err)
+	}
+	switch r.Action {
+	case ActionAllow, ActionAsk, ActionDeny:
+	default:
+		return fmt.Errorf("rule %q: action must be allow, ask or deny, got %q", r.String(), r.Action)
+	}
+	if r.CommandRegex != "" {
+		re, err := regexp.Compile(r.CommandRegex)
+		if err != nil {
+			return fmt.Errorf("rule %q: invalid command_regex: %w", r.String(), err)
+		}
+		r.commandRe = re
+	}
+	r.pathRes = nil
+	for _, glob := range r.Paths {
+		r.pathRes = append(r.pathRes, globRegexp(glob))
+	}
+	return nil
+}
+
+// matches reports whether the rule applies to a call. paths are
+// project-relative, slash-separated paths the call touches.
+func (r *Rule) matches(toolName string, paths []string, command string, hasCommand bool) bool {
+	if !r.matchesTool(toolName) {
+		return false
+	}
+	if r.Command != "" || r.commandRe != nil {
+		if !hasCommand {
+			return false
+		}
+		if r.Command != "" && !r.matchesCommand(command) {
+			return false
+		}
+		if r.commandRe != nil && !r.commandRe.MatchString(command) {
+			return false
+		}
+	}
+	if len(r.pathRes) > 0 {
+		if len(paths) == 0 {
+			return false
+		}
+		if r.Action == ActionAllow {
+			return !slices.ContainsFunc(paths, func(p string) bool { return !r.matchesPath(p) })
+		}
+		return slices.ContainsFunc(paths, r.matchesPath)
+	}
+	return true
+}
+
+// matchesCommand reports whether an allow rule's prefix covers the whole
+// command, or whether any command in the script starts with a deny or ask
+// rule's prefix.
+func (r *Rule) matchesCommand(command string) bool {
+	if r.Action == ActionAllow {
+		return commandHasPrefix(command, r.Command)
+	}
+	cmds, ok := tools.SimpleCommands(command)
+	if !ok {
+		cmds = shellControl.Split(command, -1)
+	}
+	return slices.ContainsFunc(cmds, func(c string) bool { return hasWordPrefix(c, r.Command) })
+}
+
+// ruleAliases maps tools to the tool whose rules also cover them.
+// bash_background runs any shell command, so a bash rule must apply to it.
+var ruleAliases = map[string]string{
+	"bash_background": "bash",
+}
+
+func (r *Rule) matchesTool(toolName string) bool {
+	if ok, _ := path.Match(r.Tool, toolName); ok {
+		return true
+	}
+	alias, ok := ruleAliases[toolName]
+	if !ok {
+		return false
+	}
+	ok, _ = path.Match(r.Tool, alias)
+	return ok
+}
+
+func (r *Rule) matchesPath(p string) bool {
+	for i, re := range r.pathRes {
+		// Patterns without a slash match the file name at any depth, like .gitignore
+		target := p
+		if !strings.Contains(r.Paths[i], "/") {
+			target = path.Base(p)
+		}
+		if re.MatchString(target) {
+			return true
+		}
+	}
+	return false
+}
+
+// shellControl matches shell syntax that can chain or redirect commands. A
+// prefix rule like "go test" must not approve "go test ./... && rm -rf ~".
+var shellControl = regexp.MustCompile("[;&|<>`\\n]|\\$\\(")
+
+// commandHasPrefix reports whether command starts with prefix on a word
+// boundary and does not chain further commands the prefix does not cover.
+func commandHasPrefix(command, prefix string) bool {
+	command = strings.TrimSpace(command)
+	prefix = strings.TrimSpace(prefix)
+	return hasWordPrefix(command, prefix) && !shellControl.MatchString(command[len(prefix):])
+}
+
+// hasWordPrefix reports whether command starts with prefix on a word boundary.
+func hasWordPrefix(command, prefix string) bool {
+	command = strings.TrimSpace(command)
+	prefix = strings.TrimSpace(prefix)
+	if !strings.HasPrefix(command, prefix) {
+		return false
+	}
+	rest := command[len(prefix):]
+	return rest == "" || rest[0] == ' ' || rest[0] == '\t'
+}
+
+// globRegexp converts a path glob to an anchored regular expression. "*" and
+// "?" stay within one path segment, "**" spans any number of segments.
+func globRegexp(glob string) *regexp.Regexp {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+				if i+1 < len(glob) && glob[i+1] == '/' {
+					i++
+					sb.WriteString("(?:.*/)?")
+				} else {
+					sb.WriteString(".*")
+				}
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.MustCompile(sb.String())
+}
+
+// RuleSet is an ordered list of permission rules; the first match wins.
+type RuleSet struct {
+	mu    sync.RWMutex
+	file  string // YAML file the rules are loaded from and saved to; empty if in-memory
+	root  string // Project root that rule paths are relative to
+	rules []Rule
+}
+
+// rulesFile is the on-disk format of a RuleSet.
+type rulesFile struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// NewRuleSet creates an in-memory rule set with paths relative to root.
+func NewRuleSet(root string, rules ...Rule) (*RuleSet, error) {
+	rs := &RuleSet{root: root}
+	for _, r := range rules {
+		if err := r.compile(); err != nil {
+			return nil, err
+		}
+		rs.rules = append(rs.rules, r)
+	}
+	return rs, nil
+}
+
+// FlagRules builds override rules from tool and path lists, as given by
+// --deny-tools, --allow-tools and --allow-paths. Denied tools come first.
+// With paths, allowed tools (or every tool, if none are listed) are allowed
+// only for calls whose paths all match one of them. It returns nil when all
+// lists are empty.
+func FlagRules(root string, allowTools, denyTools, allowPaths []string) (*RuleSet, error) {
+	if len(allowTools) == 0 && len(denyTools) == 0 && len(allowPaths) == 0 {
+		return nil, nil
+	}
+	var rules []Rule
+	for _, tool := range denyTools {
+		rules = append(rules, Rule{Tool: tool, Action: ActionDeny})
+	}
+	if len(allowPaths) > 0 && len(allowTools) == 0 {
+		allowTools = []string{"*"}
+	}
+	for _, tool := range allowTools {
+		rules = append(rules, Rule{Tool: tool, Paths: allowPaths, Action: ActionAllow})
+	}
+	return NewRuleSet(root, rules...)
+}
+
+// LoadRules loads rules from a YAML file. Paths in rules are relative to the
+// directory containing .vecai/. A missing file yields an empty rule set that
+// saves to file when a rule is added.
+func LoadRules(file string) (*RuleSet, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	root := filepath.Dir(abs)
+	if filepath.Base(root) == ".vecai" {
+		root = filepath.Dir(root)
+	}
+	rs := &RuleSet{file: abs, root: root}
+
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return rs, nil
+		}
+		return nil, fmt.Errorf("failed to read permission rules: %w", err)
+	}
+	var parsed rulesFile
+	if err := yaml.Unmarshal(data, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+	for i, r := range parsed.Rules {
+		if err := r.compile(); err != nil {
+			return nil, fmt.Errorf("%s rule %d: %w", file, i+1, err)
+		}
+		rs.rules = append(rs.rules, r)
+	}
+	return rs, nil
+}
+
+// Rules returns a copy of the rules in order.
+func (rs *RuleSet) Rules() []Rule {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return append([]Rule(nil), rs.rules...)
+}
+
+// Match returns the index and rule of the first rule matching the call.
+func (rs *RuleSet) Match(toolName string, input map[string]any) (int, Rule, bool) {
+	return rs.match(nil, toolName, input)
+}
+
+// match is Match with the call's paths resolved against ws.
+func (rs *RuleSet) match(ws *tools.Workspace, toolName string, input map[string]any) (int, Rule, bool) {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	paths := rs.callPaths(ws, toolName, input)
+	command, hasCommand := input["command"].(string)
+	for i := range rs.rules {
+		if rs.rules[i].matches(toolName, paths, command, hasCommand) {
+			return i, rs.rules[i], true
+		}
+	}
+	return -1, Rule{}, false
+}
+
+// ScopedRule builds the narrowest rule covering a call: its exact bash
+// command, or the exact paths it touches, or just the tool.
+func (rs *RuleSet) ScopedRule(toolName string, input map[string]any, action Action) Rule {
+	return rs.scopedRule(nil, toolName, input, action)
+}
+
+// scopedRule is ScopedRule with the call's paths resolved against ws.
+func (rs *RuleSet) scopedRule(ws *tools.Workspace, toolName string, input map[string]any, action Action) Rule {
+	r := Rule{Tool: toolName, Action: action}
+	if command, ok := input["command"].(string); ok && command != "" {
+		r.Command = strings.TrimSpace(command)
+	} else {
+		rs.mu.RLock()
+		r.Paths = rs.callPaths(ws, toolName, input)
+		rs.mu.RUnlock()
+	}
+	return r
+}
+
+// Insert adds a rule at index (clamped to the list), so it takes precedence
+// over the rule currently there, and saves the rule set to its file.
+func (rs *RuleSet) Insert(index int, rule Rule) error {
+	if err := rule.compile(); err != nil {
+		return err
+	}
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if index < 0 || index > len(rs.rules) {
+		index = len(rs.rules)
+	}
+	rs.rules = append(rs.rules, Rule{})
+	copy(rs.rules[index+1:], rs.rules[index:])
+	rs.rules[index] = rule
+
+	if rs.file == "" {
+		return nil
+	}
+	return rs.insertInFile(index, rule)
+}
+
+// insertInFile adds the rule to the YAML file, preserving the user's
+// comments and formatting elsewhere. Callers hold rs.mu.
+func (rs *RuleSet) insertInFile(index int, rule Rule) error {
+	var doc yaml.Node
+	data, err := os.ReadFile(rs.file)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read permission rules: %w", err)
+	}
+	if len(bytes.TrimSpace(data)) > 0 {
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("failed to parse permission rules: %w", err)
+		}
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	top := doc.Content[0]
+	if top.Kind != yaml.MappingNode {
+		return fmt.Errorf("%s: expected a mapping with a rules list", rs.file)
+	}
+
+	var seq *yaml.Node
+	for i := 0; i+1 < len(top.Content); i += 2 {
+		if top.Content[i].Value == "rules" {
+			seq = top.Content[i+1]
+		}
+	}
+	if seq == nil || seq.Kind != yaml.SequenceNode {
+		seq = &yaml.Node{Kind: yaml.SequenceNode}
+		top.Content = append(top.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "rules"}, seq)
+	}
+
+	var node yaml.Node
+	if err := node.Encode(rule); err != nil {
+		return fmt.Errorf("failed to encode rule: %w", err)
+	}
+	if index > len(seq.Content) {
+		index = len(seq.Content)
+	}
+	seq.Content = append(seq.Content, nil)
+	copy(seq.Content[index+1:], seq.Content[index:])
+	seq.Content[index] = &node
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return fmt.Errorf("failed to encode permission rules: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(rs.file), 0755); err != nil {
+		return fmt.Errorf("failed to create rules directory: %w", err)
+	}
+	return os.WriteFile(rs.file, buf.Bytes(), 0644)
+}
+
+// callPaths returns the project-relative, slash-separated paths a call
+// touches: every file for apply_patch, otherwise its path argument. Paths
+// resolve against ws, which may be nil. Callers hold rs.mu.
+func (rs *RuleSet) callPaths(ws *tools.Workspace, toolName string, input map[string]any) []string {
+	raw := tools.ChangedPaths(toolName, input)
+	if raw == nil {
+		if p, ok := input["path"].(string); ok && p != "" {
+			raw = []string{p}
+		}
+	}
+	paths := make([]string, 0, len(raw))
+	for _, p := range raw {
+		abs, err := ws.ResolvePath(p)
+		if err != nil {
+			continue
+		}
+		root := rs.root
+		if dir := ws.Dir(); dir != "" {
+			root = dir // Rules apply to the isolated worktree as to the project
+		}
+		rel, err := filepath.Rel(root, abs)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			// Outside the project: keep the absolute path so only explicit globs match
+			rel = abs
+		}
+		paths = append(paths, filepath.ToSlash(rel))
+	}
+	return paths
+}
@@ -0,0 +1,106 @@
+package router
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// NaiveBayes is a multinomial naive Bayes text classifier over word
+// unigrams and bigrams, with Laplace smoothing.
+type NaiveBayes struct {
+	docs        int
+	classDocs   map[string]int
+	tokenCounts map[string]map[string]int
+	classTokens map[string]int
+	vocab       map[string]struct{}
+}
+
+// NewNaiveBayes returns an untrained classifier.
+func NewNaiveBayes() *NaiveBayes {
+	return &NaiveBayes{
+		classDocs:   make(map[string]int),
+		tokenCounts: make(map[string]map[string]int),
+		classTokens: make(map[string]int),
+		vocab:       make(map[string]struct{}),
+	}
+}
+
+// Add trains the classifier on one labeled text.
+func (nb *NaiveBayes) Add(text, label string) {
+	nb.docs++
+	nb.classDocs[label]++
+	counts := nb.tokenCounts[label]
+	if counts == nil {
+		counts = make(map[string]int)
+		nb.tokenCounts[label] = counts
+	}
+	for _, f := range Features(text) {
+		counts[f]++
+		nb.classTokens[label]++
+		nb.vocab[f] = struct{}{}
+	}
+}
+
+// Docs returns the number of training texts.
+func (nb *NaiveBayes) Docs() int {
+	return nb.docs
+}
+
+// Classes returns the number of distinct labels seen.
+func (nb *NaiveBayes) Classes() int {
+	return len(nb.classDocs)
+}
+
+// Predict returns the most likely label for text and its posterior
+// probability. It returns "" when the classifier is untrained.
+func (nb *NaiveBayes) Predict(text string) (string, float64) {
+	if nb.docs == 0 {
+		return "", 0
+	}
+
+	features := Features(text)
+	vocabSize := float64(len(nb.vocab) + 1)
+
+	// Iterate labels in a fixed order so ties resolve deterministically
+	labels := make([]string, 0, len(nb.classDocs))
+	for label := range nb.classDocs {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	logProbs := make([]float64, len(labels))
+	best := 0
+	for i, label := range labels {
+		lp := math.Log(float64(nb.classDocs[label]) / float64(nb.docs))
+		denom := float64(nb.classTokens[label]) + vocabSize
+		for _, f := range features {
+			lp += math.Log(float64(nb.tokenCounts[label][f]+1) / denom)
+		}
+		logProbs[i] = lp
+		if lp > logProbs[best] {
+			best = i
+		}
+	}
+
+	// Posterior of the best label via log-sum-exp
+	var sum float64
+	for _, lp := range logProbs {
+		sum += math.Exp(lp - logProbs[best])
+	}
+	return labels[best], 1 / sum
+}
+
+// Features splits text into lowercase word unigrams and bigrams.
+func Features(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	})
+	features := make([]string, 0, 2*len(words))
+	features = append(features, words...)
+	for i := 1; i < len(words); i++ {
+		features = append(features, words[i-1]+" "+words[i])
+	}
+	return features
+}
@@ -0,0 +1,312 @@
+// Package router learns which model tier and intent fit a query from the
+// outcomes of past queries. Each finished query is recorded as a Sample;
+// naive Bayes classifiers trained on those samples take over from the
+// keyword heuristics once enough have been collected.
+package router
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abdul-hamid-achik/vecai/internal/config"
+)
+
+const (
+	// samplesFile holds one JSON Sample per line
+	samplesFile = "samples.jsonl"
+	// maxSamples caps how many of the most recent samples are trained on
+	maxSamples = 5000
+	// retrySimilarity is the word overlap above which a query counts as a
+	// retry of the previous one
+	retrySimilarity = 0.7
+)
+
+// Sample is the outcome of one query.
+type Sample struct {
+	Time       time.Time        `json:"ts"`
+	Query      string           `json:"query"`
+	Tier       config.ModelTier `json:"tier"`             // Tier the query ran on
+	Routed     bool             `json:"routed,omitempty"` // Tier was picked automatically, not by the user
+	Intent     string           `json:"intent,omitempty"` // Classified intent, if classification ran
+	Iterations int              `json:"iterations"`
+	ToolCalls  int              `json:"tool_calls"`
+	Failed     bool             `json:"failed,omitempty"` // Loop errored or ran out of iterations
+
+	// Feedback from what the user did next
+	Retried      bool             `json:"retried,omitempty"`       // Re-asked a near-identical query
+	EscalatedTo  config.ModelTier `json:"escalated_to,omitempty"`  // Switched to a higher tier with /mode
+	SwitchedMode string           `json:"switched_mode,omitempty"` // Switched agent mode (ask, plan, build)
+}
+
+// TierLabel returns the tier the query should have run on. The effort the
+// query took sets a baseline; a retry, failure or escalation means the tier
+// used was too weak.
+func (s Sample) TierLabel() config.ModelTier {
+	var label config.ModelTier
+	switch {
+	case s.ToolCalls <= 2 && s.Iterations <= 2:
+		label = config.TierFast
+	case s.ToolCalls <= 8 && s.Iterations <= 6:
+		label = config.TierSmart
+	default:
+		label = config.TierGenius
+	}
+
+	if s.Retried || s.Failed {
+		label = maxTier(label, nextTier(s.Tier))
+	}
+	if s.EscalatedTo != "" {
+		label = maxTier(label, s.EscalatedTo)
+	}
+	return label
+}
+
+// IntentLabel returns the intent the query should have been classified as:
+// the classified intent, unless the user switched to a mode that implies
+// another one. It returns "" when there is nothing to learn from.
+func (s Sample) IntentLabel() string {
+	switch strings.ToLower(s.SwitchedMode) {
+	case "ask":
+		return "question"
+	case "plan":
+		if s.Intent == "review" {
+			return s.Intent
+		}
+		return "plan"
+	case "build":
+		if s.Intent == "debug" {
+			return s.Intent
+		}
+		return "code"
+	}
+	return s.Intent
+}
+
+// Options configures a Router.
+type Options struct {
+	MinSamples    int     // Samples needed before predictions are used
+	MinConfidence float64 // Posterior probability a prediction needs
+}
+
+// Router records samples and predicts tiers and intents from them.
+type Router struct {
+	path string
+	opts Options
+
+	mu      sync.RWMutex
+	samples int
+	tier    *NaiveBayes
+	intent  *NaiveBayes
+}
+
+// Open loads the samples stored in dir and trains on them. A leading ~ in
+// dir is expanded to the home directory.
+func Open(dir string, opts Options) (*Router, error) {
+	dir, err := expandHome(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	r := &Router{
+		path:   filepath.Join(dir, samplesFile),
+		opts:   opts,
+		tier:   NewNaiveBayes(),
+		intent: NewNaiveBayes(),
+	}
+	samples, err := LoadSamples(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range samples {
+		r.train(s)
+	}
+	return r, nil
+}
+
+// Record appends s to the store and trains on it.
+func (r *Router) Record(s Sample) error {
+	if strings.TrimSpace(s.Query) == "" {
+		return nil
+	}
+	if s.Time.IsZero() {
+		s.Time = time.Now()
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f, err := os.OpenFile(r.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	r.trainLocked(s)
+	return nil
+}
+
+// PredictTier returns the learned tier for query. ok is false until the
+// router has enough samples, or when it is not confident enough.
+func (r *Router) PredictTier(query string) (tier config.ModelTier, confidence float64, ok bool) {
+	label, confidence, ok := r.predict(r.tier, query)
+	return config.ModelTier(label), confidence, ok
+}
+
+// PredictIntent returns the learned intent for query, like PredictTier.
+func (r *Router) PredictIntent(query string) (intent string, confidence float64, ok bool) {
+	return r.predict(r.intent, query)
+}
+
+// Samples returns the number of samples trained on.
+func (r *Router) Samples() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.samples
+}
+
+func (r *Router) predict(nb *NaiveBayes, query string) (string, float64, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if nb.Docs() < r.opts.MinSamples || nb.Classes() < 2 {
+		return "", 0, false
+	}
+	label, confidence := nb.Predict(query)
+	return label, confidence, label != "" && confidence >= r.opts.MinConfidence
+}
+
+func (r *Router) train(s Sample) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.trainLocked(s)
+}
+
+func (r *Router) trainLocked(s Sample) {
+	r.samples++
+	r.tier.Add(s.Query, string(s.TierLabel()))
+	if label := s.IntentLabel(); label != "" {
+		r.intent.Add(s.Query, label)
+	}
+}
+
+// LoadSamples reads the most recent samples stored in dir. Malformed lines
+// are skipped.
+func LoadSamples(dir string) ([]Sample, error) {
+	dir, err := expandHome(dir)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filepath.Join(dir, samplesFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	var samples []Sample
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var s Sample
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			continue
+		}
+		samples = append(samples, s)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read router samples: %w", err)
+	}
+	if len(samples) > maxSamples {
+		samples = samples[len(samples)-maxSamples:]
+	}
+	return samples, nil
+}
+
+// IsRetry reports whether query repeats previous closely enough to mean
+// the previous answer was not good enough.
+func IsRetry(previous, query string) bool {
+	a := wordSet(previous)
+	b := wordSet(query)
+	if len(a) == 0 || len(b) == 0 {
+		return false
+	}
+	var shared int
+	for w := range a {
+		if _, ok := b[w]; ok {
+			shared++
+		}
+	}
+	return float64(shared)/float64(len(a)+len(b)-shared) >= retrySimilarity
+}
+
+// TierRank orders tiers from fast (0) to genius (2); unknown tiers rank -1.
+func TierRank(tier config.ModelTier) int {
+	switch tier {
+	case config.TierFast:
+		return 0
+	case config.TierSmart:
+		return 1
+	case config.TierGenius:
+		return 2
+	default:
+		return -1
+	}
+}
+
+func nextTier(tier config.ModelTier) config.ModelTier {
+	switch tier {
+	case config.TierFast:
+		return config.TierSmart
+	default:
+		return config.TierGenius
+	}
+}
+
+func maxTier(a, b config.ModelTier) config.ModelTier {
+	if TierRank(b) > TierRank(a) {
+		return b
+	}
+	return a
+}
+
+func wordSet(text string) map[string]struct{} {
+	set := make(map[string]struct{})
+	for _, f := range Features(text) {
+		if !strings.Contains(f, " ") {
+			set[f] = struct{}{}
+		}
+	}
+	return set
+}
+
+func expandHome(dir string) (string, error) {
+	if !strings.HasPrefix(dir, "~") {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, dir[1:]), nil
+}
@@ -0,0 +1,170 @@
+package router
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/abdul-hamid-achik/vecai/internal/config"
+)
+
+func TestSample_TierLabel(t *testing.T) {
+	tests := []struct {
+		name   string
+		sample Sample
+		want   config.ModelTier
+	}{
+		{"quick answer", Sample{Tier: config.TierGenius, Iterations: 1}, config.TierFast},
+		{"some tool use", Sample{Tier: config.TierFast, Iterations: 4, ToolCalls: 5}, config.TierSmart},
+		{"long task", Sample{Tier: config.TierSmart, Iterations: 12, ToolCalls: 20}, config.TierGenius},
+		{"retried", Sample{Tier: config.TierFast, Iterations: 1, Retried: true}, config.TierSmart},
+		{"failed", Sample{Tier: config.TierSmart, Iterations: 1, Failed: true}, config.TierGenius},
+		{"escalated", Sample{Tier: config.TierFast, Iterations: 1, EscalatedTo: config.TierGenius}, config.TierGenius},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.sample.TierLabel(); got != tt.want {
+				t.Errorf("TierLabel() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSample_IntentLabel(t *testing.T) {
+	if got := (Sample{Intent: "question"}).IntentLabel(); got != "question" {
+		t.Errorf("expected classified intent, got %q", got)
+	}
+	if got := (Sample{Intent: "question", SwitchedMode: "build"}).IntentLabel(); got != "code" {
+		t.Errorf("expected build switch to mean code, got %q", got)
+	}
+	if got := (Sample{Intent: "debug", SwitchedMode: "build"}).IntentLabel(); got != "debug" {
+		t.Errorf("expected debug to survive a build switch, got %q", got)
+	}
+	if got := (Sample{}).IntentLabel(); got != "" {
+		t.Errorf("expected no label, got %q", got)
+	}
+}
+
+func TestIsRetry(t *testing.T) {
+	if !IsRetry("fix the failing login test", "fix the failing login test please") {
+		t.Error("expected near-identical query to be a retry")
+	}
+	if IsRetry("fix the failing login test", "where is the config loaded") {
+		t.Error("expected unrelated query not to be a retry")
+	}
+}
+
+func TestNaiveBayes(t *testing.T) {
+	nb := NewNaiveBayes()
+	if label, _ := nb.Predict("anything"); label != "" {
+		t.Errorf("untrained classifier predicted %q", label)
+	}
+
+	nb.Add("where is the config file", "fast")
+	nb.Add("show me the main function", "fast")
+	nb.Add("find the race in the worker pool", "genius")
+	nb.Add("the scheduler has a race condition", "genius")
+
+	label, confidence := nb.Predict("find the race in the scheduler")
+	if label != "genius" || confidence <= 0.5 {
+		t.Errorf("Predict = %s (%.2f), want genius", label, confidence)
+	}
+}
+
+// trainingSamples returns samples where race and deadlock queries needed
+// the genius tier and lookups were answered quickly.
+func trainingSamples() []Sample {
+	var samples []Sample
+	for _, q := range []string{"where is the config", "show me the readme", "list the handlers", "where is main", "show me the router"} {
+		samples = append(samples, Sample{Query: q, Tier: config.TierFast, Routed: true, Iterations: 1, Intent: "question"})
+	}
+	for _, q := range []string{"find the race in the worker", "debug the deadlock in the pool", "race between the cache and the writer", "fix the race in the queue", "deadlock when shutting down"} {
+		samples = append(samples, Sample{Query: q, Tier: config.TierFast, Routed: true, Iterations: 1, EscalatedTo: config.TierGenius, Intent: "debug"})
+	}
+	return samples
+}
+
+func TestRouter_LearnsFromRecordedSamples(t *testing.T) {
+	dir := t.TempDir()
+	r, err := Open(dir, Options{MinSamples: 10, MinConfidence: 0.6})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	samples := trainingSamples()
+	for _, s := range samples[:9] {
+		if err := r.Record(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, _, ok := r.PredictTier("find the race in the scheduler"); ok {
+		t.Error("expected no prediction below MinSamples")
+	}
+	if err := r.Record(samples[9]); err != nil {
+		t.Fatal(err)
+	}
+
+	tier, confidence, ok := r.PredictTier("find the race in the scheduler")
+	if !ok || tier != config.TierGenius {
+		t.Errorf("PredictTier = %s (%.2f, %v), want genius", tier, confidence, ok)
+	}
+	if intent, _, ok := r.PredictIntent("where is the logger"); !ok || intent != "question" {
+		t.Errorf("PredictIntent = %s (%v), want question", intent, ok)
+	}
+
+	// Samples persist across restarts
+	reopened, err := Open(dir, Options{MinSamples: 10, MinConfidence: 0.6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reopened.Samples() != 10 {
+		t.Errorf("expected 10 samples after reopening, got %d", reopened.Samples())
+	}
+	if tier, _, _ := reopened.PredictTier("deadlock in the scheduler"); tier != config.TierGenius {
+		t.Errorf("reopened router predicted %s", tier)
+	}
+}
+
+func TestLoadSamples_SkipsMalformedLines(t *testing.T) {
+	dir := t.TempDir()
+	data := `{"query":"where is main","tier":"fast","iterations":1}
+not json
+{"query":"fix the race","tier":"genius","iterations":9}
+`
+	if err := os.WriteFile(filepath.Join(dir, samplesFile), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	samples, err := LoadSamples(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 2 || samples[1].Query != "fix the race" {
+		t.Errorf("unexpected samples %+v", samples)
+	}
+}
+
+func TestComputeStats(t *testing.T) {
+	samples := trainingSamples()
+	stats := ComputeStats(samples, Options{MinSamples: 10, MinConfidence: 0.6})
+
+	if stats.Samples != 10 || !stats.Active {
+		t.Errorf("unexpected totals %+v", stats)
+	}
+	if stats.Escalations != 5 || stats.TierLabels["genius"] != 5 || stats.TierLabels["fast"] != 5 {
+		t.Errorf("unexpected feedback counts %+v", stats)
+	}
+	// Keyword routing sent every race query to the fast tier
+	if stats.RoutedAccuracy != 0.5 {
+		t.Errorf("RoutedAccuracy = %.2f, want 0.5", stats.RoutedAccuracy)
+	}
+	if stats.TierAccuracy < 0.8 {
+		t.Errorf("TierAccuracy = %.2f, expected the classifier to separate the samples", stats.TierAccuracy)
+	}
+	if stats.IntentSamples != 10 {
+		t.Errorf("IntentSamples = %d", stats.IntentSamples)
+	}
+
+	if empty := ComputeStats(nil, Options{MinSamples: 10}); empty.Active || empty.TierAccuracy != 0 {
+		t.Errorf("unexpected stats for no samples %+v", empty)
+	}
+}
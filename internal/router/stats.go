@@ -0,0 +1,109 @@
+package router
+
+// crossValidationFolds is the number of folds used to estimate accuracy
+const crossValidationFolds = 5
+
+// Stats summarizes the recorded samples and how well the classifiers fit
+// them.
+type Stats struct {
+	Samples     int            `json:"samples"`
+	MinSamples  int            `json:"min_samples"`
+	Active      bool           `json:"active"`      // Learned tier predictions are in use
+	TierLabels  map[string]int `json:"tier_labels"` // Samples per learned tier label
+	Retries     int            `json:"retries"`
+	Escalations int            `json:"escalations"`
+	Failures    int            `json:"failures"`
+
+	// RoutedAccuracy is how often the tier picked at the time (keyword or
+	// learned routing) matched the learned label
+	RoutedSamples  int     `json:"routed_samples"`
+	RoutedAccuracy float64 `json:"routed_accuracy"`
+
+	// Cross-validated accuracy of the classifiers, and the share of
+	// held-out predictions confident enough to be used
+	TierAccuracy   float64 `json:"tier_accuracy"`
+	TierCoverage   float64 `json:"tier_coverage"`
+	IntentSamples  int     `json:"intent_samples"`
+	IntentAccuracy float64 `json:"intent_accuracy"`
+}
+
+// ComputeStats evaluates samples with k-fold cross-validation.
+func ComputeStats(samples []Sample, opts Options) Stats {
+	stats := Stats{
+		Samples:    len(samples),
+		MinSamples: opts.MinSamples,
+		TierLabels: make(map[string]int),
+	}
+
+	var routedCorrect int
+	for _, s := range samples {
+		label := s.TierLabel()
+		stats.TierLabels[string(label)]++
+		if s.Retried {
+			stats.Retries++
+		}
+		if s.EscalatedTo != "" {
+			stats.Escalations++
+		}
+		if s.Failed {
+			stats.Failures++
+		}
+		if s.Routed {
+			stats.RoutedSamples++
+			if s.Tier == label {
+				routedCorrect++
+			}
+		}
+	}
+	if stats.RoutedSamples > 0 {
+		stats.RoutedAccuracy = float64(routedCorrect) / float64(stats.RoutedSamples)
+	}
+	stats.Active = len(samples) >= opts.MinSamples && len(stats.TierLabels) >= 2
+
+	tierLabel := func(s Sample) string { return string(s.TierLabel()) }
+	intentLabel := func(s Sample) string { return s.IntentLabel() }
+
+	var confident int
+	stats.TierAccuracy, confident, _ = crossValidate(samples, tierLabel, opts.MinConfidence)
+	if len(samples) > 0 {
+		stats.TierCoverage = float64(confident) / float64(len(samples))
+	}
+	stats.IntentAccuracy, _, stats.IntentSamples = crossValidate(samples, intentLabel, opts.MinConfidence)
+	return stats
+}
+
+// crossValidate trains on all folds but one and predicts the held-out one,
+// for each fold. It returns the accuracy, the number of predictions at or
+// above minConfidence, and the number of labeled samples evaluated.
+func crossValidate(samples []Sample, label func(Sample) string, minConfidence float64) (float64, int, int) {
+	var labeled []Sample
+	for _, s := range samples {
+		if label(s) != "" {
+			labeled = append(labeled, s)
+		}
+	}
+	if len(labeled) < 2 {
+		return 0, 0, len(labeled)
+	}
+
+	folds := min(crossValidationFolds, len(labeled))
+	var correct, confident int
+	for fold := 0; fold < folds; fold++ {
+		nb := NewNaiveBayes()
+		for i, s := range labeled {
+			if i%folds != fold {
+				nb.Add(s.Query, label(s))
+			}
+		}
+		for i := fold; i < len(labeled); i += folds {
+			predicted, confidence := nb.Predict(labeled[i].Query)
+			if predicted == label(labeled[i]) {
+				correct++
+			}
+			if confidence >= minConfidence {
+				confident++
+			}
+		}
+	}
+	return float64(correct) / float64(len(labeled)), confident, len(labeled)
+}
@@ -0,0 +1,570 @@
+package serve
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abdul-hamid-achik/vecai/internal/agent"
+)
+
+// HTTPConfig configures the HTTP API.
+type HTTPConfig struct {
+	Addr        string // host:port; an empty host means 127.0.0.1
+	Token       string // Bearer token every request must carry
+	AllowRemote bool   // Allow binding to addresses other than loopback
+	Info        Info
+
+	// NewAgent builds the agent for each live session. Agents must not
+	// share a context manager or permission policy.
+	NewAgent func() (*agent.Agent, error)
+
+	// Ready is called with the bound address once the server listens.
+	Ready func(addr string)
+}
+
+// Events published on /v1/events besides the agent's StreamEvents.
+const (
+	EventTaskEnd       = "task_end"
+	EventSessionClosed = "session_closed"
+)
+
+// eventBuffer is how many events a slow /v1/events subscriber may fall
+// behind before it is disconnected.
+const eventBuffer = 256
+
+// HTTP serves the REST API and its event stream on cfg.Addr until ctx is
+// cancelled, then cancels running tasks and closes every session's agent.
+func HTTP(ctx context.Context, cfg HTTPConfig) error {
+	addr, err := ListenAddr(cfg.Addr, cfg.AllowRemote)
+	if err != nil {
+		return err
+	}
+	h, err := NewHTTPServer(cfg)
+	if err != nil {
+		return err
+	}
+	defer h.Close()
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: h, ReadHeaderTimeout: 10 * time.Second}
+	if cfg.Ready != nil {
+		cfg.Ready(ln.Addr().String())
+	}
+
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(ln) }()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	// Ending the sessions first closes event streams, which Shutdown waits for
+	h.Close()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return srv.Shutdown(shutdownCtx)
+}
+
+// ListenAddr resolves addr for listening. A missing host binds loopback
+// only; other hosts need allowRemote.
+func ListenAddr(addr string, allowRemote bool) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("invalid address %q: %w", addr, err)
+	}
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	if !allowRemote && !isLoopback(host) {
+		return "", fmt.Errorf("refusing to listen on %s: only loopback addresses are allowed without --allow-remote", host)
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// HTTPServer is the HTTP API's handler. Every live session has its own
+// agent and agent.Server; saved sessions are read from disk.
+type HTTPServer struct {
+	cfg   HTTPConfig
+	mux   *http.ServeMux
+	saved *agent.SavedSessions
+	hub   *hub
+
+	ctx    context.Context // Cancelled by Close; tasks run under it
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	live   map[string]*liveSession
+	closed bool
+}
+
+// liveSession is a session with an agent behind it.
+type liveSession struct {
+	id    string
+	agent *agent.Agent
+	srv   *agent.Server
+}
+
+// NewHTTPServer creates the handler. Call Close to end its sessions.
+func NewHTTPServer(cfg HTTPConfig) (*HTTPServer, error) {
+	if cfg.Token == "" {
+		return nil, errors.New("a bearer token is required")
+	}
+	if cfg.NewAgent == nil {
+		return nil, errors.New("no agent factory configured")
+	}
+	saved, err := agent.OpenSavedSessions()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	h := &HTTPServer{
+		cfg:    cfg,
+		mux:    http.NewServeMux(),
+		saved:  saved,
+		hub:    newHub(),
+		ctx:    ctx,
+		cancel: cancel,
+		live:   make(map[string]*liveSession),
+	}
+	h.mux.HandleFunc("GET /v1/info", h.handleInfo)
+	h.mux.HandleFunc("GET /v1/sessions", h.handleListSessions)
+	h.mux.HandleFunc("POST /v1/sessions", h.handleCreateSession)
+	h.mux.HandleFunc("GET /v1/sessions/{id}", h.handleGetSession)
+	h.mux.HandleFunc("DELETE /v1/sessions/{id}", h.handleDeleteSession)
+	h.mux.HandleFunc("POST /v1/sessions/{id}/tasks", h.handleTask)
+	h.mux.HandleFunc("POST /v1/sessions/{id}/cancel", h.handleCancel)
+	h.mux.HandleFunc("POST /v1/sessions/{id}/permissions/{prompt}", h.handlePermission)
+	h.mux.HandleFunc("PUT /v1/sessions/{id}/mode", h.handleMode)
+	h.mux.HandleFunc("GET /v1/sessions/{id}/context", h.handleContext)
+	h.mux.HandleFunc("POST /v1/sessions/{id}/close", h.handleClose)
+	h.mux.HandleFunc("GET /v1/events", h.handleEvents)
+	return h, nil
+}
+
+// ServeHTTP checks the bearer token and routes the request. The token may
+// also be passed as ?token= for EventSource clients, which cannot set
+// headers.
+func (h *HTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		token, _ = strings.CutPrefix(auth, "Bearer ")
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(h.cfg.Token)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="vecai"`)
+		writeError(w, http.StatusUnauthorized, errors.New("missing or invalid bearer token"))
+		return
+	}
+	h.mux.ServeHTTP(w, r)
+}
+
+// Close cancels running tasks, closes every live session's agent and ends
+// event streams.
+func (h *HTTPServer) Close() {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return
+	}
+	h.closed = true
+	sessions := make([]*liveSession, 0, len(h.live))
+	for _, ls := range h.live {
+		sessions = append(sessions, ls)
+	}
+	h.live = map[string]*liveSession{}
+	h.mu.Unlock()
+
+	h.cancel()
+	for _, ls := range sessions {
+		ls.close()
+	}
+	h.hub.closeAll()
+}
+
+func (ls *liveSession) close() {
+	ls.srv.Close()
+	_ = ls.agent.Close()
+}
+
+func (h *HTTPServer) handleInfo(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"server":         h.cfg.Info,
+		"schema_version": agent.StreamSchemaVersion,
+	})
+}
+
+func (h *HTTPServer) handleListSessions(w http.ResponseWriter, _ *http.Request) {
+	sessions, err := h.saved.List()
+	if err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	h.mu.Lock()
+	live := make([]string, 0, len(h.live))
+	for id := range h.live {
+		live = append(live, id)
+	}
+	h.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]any{"sessions": sessions, "live": live})
+}
+
+// handleCreateSession starts a live session with a fresh agent, resuming
+// a saved session when asked.
+func (h *HTTPServer) handleCreateSession(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Resume string `json:"resume"`
+		Mode   string `json:"mode"`
+	}
+	if !readJSON(w, r, &body) {
+		return
+	}
+	if body.Resume != "" && h.lookup(body.Resume) != nil {
+		writeError(w, http.StatusConflict, fmt.Errorf("session %s is already live", body.Resume))
+		return
+	}
+
+	a, err := h.cfg.NewAgent()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	ls := &liveSession{agent: a}
+	ls.srv = agent.NewServer(a, func(ev agent.StreamEvent) { h.hub.publish(ls.id, ev.Type, ev) })
+	state, err := ls.srv.StartSession(body.Resume)
+	if err == nil && body.Mode != "" {
+		state.Mode, err = ls.srv.SetMode(body.Mode)
+	}
+	if err != nil {
+		_ = a.Close()
+		writeError(w, statusFor(err), err)
+		return
+	}
+	ls.id = state.SessionID
+
+	h.mu.Lock()
+	_, exists := h.live[ls.id]
+	if h.closed || exists {
+		h.mu.Unlock()
+		_ = a.Close()
+		writeError(w, http.StatusConflict, fmt.Errorf("session %s is not available", ls.id))
+		return
+	}
+	h.live[ls.id] = ls
+	h.mu.Unlock()
+	writeJSON(w, http.StatusCreated, state)
+}
+
+func (h *HTTPServer) handleGetSession(w http.ResponseWriter, r *http.Request) {
+	sess, err := h.saved.Load(r.PathValue("id"))
+	if err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, sess)
+}
+
+func (h *HTTPServer) handleDeleteSession(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if h.lookup(id) != nil {
+		writeError(w, http.StatusConflict, fmt.Errorf("session %s is live; close it first", id))
+		return
+	}
+	if err := h.saved.Delete(id); err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleTask starts a task and returns without waiting for it; its output
+// and a task_end event arrive on /v1/events.
+func (h *HTTPServer) handleTask(w http.ResponseWriter, r *http.Request) {
+	ls := h.liveOr404(w, r)
+	if ls == nil {
+		return
+	}
+	var body struct {
+		Text string `json:"text"`
+	}
+	if !readJSON(w, r, &body) {
+		return
+	}
+
+	err := ls.srv.Start(h.ctx, body.Text, func(result agent.PromptResult) {
+		h.hub.publish(ls.id, EventTaskEnd, result)
+	})
+	if err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{"session_id": ls.id, "status": "running"})
+}
+
+func (h *HTTPServer) handleCancel(w http.ResponseWriter, r *http.Request) {
+	if ls := h.liveOr404(w, r); ls != nil {
+		writeJSON(w, http.StatusOK, map[string]bool{"cancelled": ls.srv.Cancel()})
+	}
+}
+
+func (h *HTTPServer) handlePermission(w http.ResponseWriter, r *http.Request) {
+	ls := h.liveOr404(w, r)
+	if ls == nil {
+		return
+	}
+	var body struct {
+		Decision string `json:"decision"`
+	}
+	if !readJSON(w, r, &body) {
+		return
+	}
+	if err := ls.srv.RespondPermission(r.PathValue("prompt"), body.Decision); err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *HTTPServer) handleMode(w http.ResponseWriter, r *http.Request) {
+	ls := h.liveOr404(w, r)
+	if ls == nil {
+		return
+	}
+	var body struct {
+		Mode string `json:"mode"`
+	}
+	if !readJSON(w, r, &body) {
+		return
+	}
+	mode, err := ls.srv.SetMode(body.Mode)
+	if err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"mode": mode})
+}
+
+func (h *HTTPServer) handleContext(w http.ResponseWriter, r *http.Request) {
+	if ls := h.liveOr404(w, r); ls != nil {
+		writeJSON(w, http.StatusOK, ls.srv.ContextStats())
+	}
+}
+
+// handleClose cancels the session's task, saves it and frees its agent.
+func (h *HTTPServer) handleClose(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	h.mu.Lock()
+	ls := h.live[id]
+	delete(h.live, id)
+	h.mu.Unlock()
+	if ls == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("%w: %s is not live", agent.ErrSessionNotFound, id))
+		return
+	}
+	ls.close()
+	h.hub.publish(id, EventSessionClosed, struct{}{})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleEvents streams events as server-sent events, optionally only those
+// of ?session=<id>. Each event's data is the agent.StreamEvent (or task_end
+// result) plus the session_id it belongs to.
+func (h *HTTPServer) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming is not supported"))
+		return
+	}
+	sub := h.hub.subscribe(r.URL.Query().Get("session"))
+	if sub == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("server is shutting down"))
+		return
+	}
+	defer h.hub.unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	_, _ = fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	ping := time.NewTicker(30 * time.Second)
+	defer ping.Stop()
+	for {
+		select {
+		case msg, open := <-sub.ch:
+			if !open {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", msg.event, msg.data); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-ping.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (h *HTTPServer) lookup(id string) *liveSession {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.live[id]
+}
+
+func (h *HTTPServer) liveOr404(w http.ResponseWriter, r *http.Request) *liveSession {
+	id := r.PathValue("id")
+	ls := h.lookup(id)
+	if ls == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("%w: %s is not live", agent.ErrSessionNotFound, id))
+	}
+	return ls
+}
+
+// readJSON decodes an optional JSON request body, answering 400 when it
+// is malformed.
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	if r.ContentLength == 0 {
+		return true
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid JSON body: %w", err))
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func statusFor(err error) int {
+	switch {
+	case errors.Is(err, agent.ErrInvalidArgument):
+		return http.StatusBadRequest
+	case errors.Is(err, agent.ErrBusy):
+		return http.StatusConflict
+	case errors.Is(err, agent.ErrNoPrompt), errors.Is(err, agent.ErrSessionNotFound), errors.Is(err, agent.ErrClosed):
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// sseMessage is one encoded server-sent event.
+type sseMessage struct {
+	event string
+	data  []byte
+}
+
+type subscriber struct {
+	session string // Only this session's events, or "" for all
+	ch      chan sseMessage
+}
+
+// hub fans events out to /v1/events subscribers. A subscriber that falls
+// eventBuffer events behind is disconnected rather than silently missing
+// events.
+type hub struct {
+	mu     sync.Mutex
+	subs   map[*subscriber]struct{}
+	closed bool
+}
+
+func newHub() *hub {
+	return &hub{subs: make(map[*subscriber]struct{})}
+}
+
+func (h *hub) subscribe(session string) *subscriber {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil
+	}
+	sub := &subscriber{session: session, ch: make(chan sseMessage, eventBuffer)}
+	h.subs[sub] = struct{}{}
+	return sub
+}
+
+func (h *hub) unsubscribe(sub *subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[sub]; ok {
+		delete(h.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// publish sends payload, tagged with its session ID, to every matching
+// subscriber.
+func (h *hub) publish(session, event string, payload any) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	data = tagSession(session, data)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subs {
+		if sub.session != "" && sub.session != session {
+			continue
+		}
+		select {
+		case sub.ch <- sseMessage{event: event, data: data}:
+		default:
+			delete(h.subs, sub)
+			close(sub.ch)
+		}
+	}
+}
+
+func (h *hub) closeAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for sub := range h.subs {
+		delete(h.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// tagSession adds "session_id" to an encoded JSON object.
+func tagSession(session string, data []byte) []byte {
+	id, _ := json.Marshal(session)
+	tagged := append([]byte(`{"session_id":`), id...)
+	if len(data) > 2 {
+		tagged = append(tagged, ',')
+	}
+	return append(tagged, data[1:]...)
+}
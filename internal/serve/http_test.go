@@ -0,0 +1,327 @@
+package serve
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/abdul-hamid-achik/vecai/internal/agent"
+	"github.com/abdul-hamid-achik/vecai/internal/config"
+	"github.com/abdul-hamid-achik/vecai/internal/llm"
+	"github.com/abdul-hamid-achik/vecai/internal/permissions"
+	"github.com/abdul-hamid-achik/vecai/internal/skills"
+	"github.com/abdul-hamid-achik/vecai/internal/tools"
+	"github.com/abdul-hamid-achik/vecai/internal/ui"
+)
+
+const testToken = "secret"
+
+// newWritingAgent returns an agent whose model writes a.txt on its first
+// turn, which needs permission, and answers with text afterwards.
+func newWritingAgent() (*agent.Agent, error) {
+	cfg := config.DefaultConfig()
+	cfg.Memory.Enabled = false
+	cfg.Audit.Enabled = false
+	cfg.Router.Learned = false
+
+	var mu sync.Mutex
+	turn := 0
+	mock := llm.NewMockLLMClient()
+	mock.ChatStreamFunc = func(_ context.Context, _ []llm.Message, _ []llm.ToolDefinition, _ string) <-chan llm.StreamChunk {
+		mu.Lock()
+		turn++
+		first := turn == 1
+		mu.Unlock()
+
+		ch := make(chan llm.StreamChunk, 2)
+		if first {
+			ch <- llm.StreamChunk{Type: "tool_call", ToolCall: &llm.ToolCall{ID: "c1", Name: "write_file", Input: map[string]any{"path": "a.txt", "content": "hi"}}}
+		} else {
+			ch <- llm.StreamChunk{Type: "text", Text: "all done"}
+		}
+		ch <- llm.StreamChunk{Type: "done"}
+		close(ch)
+		return ch
+	}
+
+	// The project root is cached for the process, so point the tools at
+	// this test's directory
+	registry := tools.NewRegistry(&cfg.Tools)
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	if err := registry.SetWorkspace(wd); err != nil {
+		return nil, err
+	}
+
+	output := ui.NewOutputHandler()
+	input := ui.NewInputHandler()
+	return agent.New(agent.Config{
+		LLM:         mock,
+		Tools:       registry,
+		Permissions: permissions.NewPolicy(permissions.ModeAsk, input, output),
+		Skills:      skills.NewLoader(),
+		Output:      output,
+		Input:       input,
+		Config:      cfg,
+	}), nil
+}
+
+type apiClient struct {
+	t   *testing.T
+	url string
+}
+
+func startHTTP(t *testing.T) *apiClient {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	h, err := NewHTTPServer(HTTPConfig{Token: testToken, NewAgent: newWritingAgent, Info: Info{Name: "vecai", Version: "test"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(h)
+	t.Cleanup(func() {
+		h.Close()
+		ts.Close()
+	})
+	return &apiClient{t: t, url: ts.URL}
+}
+
+// do sends a request with the bearer token and decodes a JSON response
+// into out when it is not nil.
+func (c *apiClient) do(method, path, body string, out any) int {
+	c.t.Helper()
+	req, err := http.NewRequest(method, c.url+path, strings.NewReader(body))
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			c.t.Fatalf("%s %s: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+type sseEvent struct {
+	name string
+	data struct {
+		SessionID string `json:"session_id"`
+		agent.StreamEvent
+		StopReason string `json:"stop_reason"`
+	}
+}
+
+// events subscribes to /v1/events with the query-string token.
+func (c *apiClient) events(query string) <-chan sseEvent {
+	c.t.Helper()
+	resp, err := http.Get(c.url + "/v1/events?token=" + testToken + query)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		c.t.Fatalf("unexpected events response %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	c.t.Cleanup(func() { resp.Body.Close() })
+
+	ch := make(chan sseEvent, 100)
+	go func() {
+		defer close(ch)
+		scanner := bufio.NewScanner(resp.Body)
+		var ev sseEvent
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				ev.name = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				_ = json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ev.data)
+			case line == "" && ev.name != "":
+				ch <- ev
+				ev = sseEvent{}
+			}
+		}
+	}()
+	return ch
+}
+
+func waitEvent(t *testing.T, events <-chan sseEvent, name string) sseEvent {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				t.Fatalf("event stream ended waiting for %s", name)
+			}
+			if ev.name == name {
+				return ev
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %s event", name)
+		}
+	}
+}
+
+func TestHTTP_RequiresToken(t *testing.T) {
+	c := startHTTP(t)
+
+	resp, err := http.Get(c.url + "/v1/sessions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("WWW-Authenticate") == "" {
+		t.Errorf("expected 401 without a token, got %d", resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, c.url+"/v1/sessions?token="+testToken, nil)
+	req.Header.Set("Authorization", "Bearer wrong")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected a wrong header to win over the query token, got %d", resp.StatusCode)
+	}
+
+	var info struct {
+		SchemaVersion int `json:"schema_version"`
+	}
+	if code := c.do(http.MethodGet, "/v1/info", "", &info); code != http.StatusOK || info.SchemaVersion != agent.StreamSchemaVersion {
+		t.Errorf("unexpected info %d %+v", code, info)
+	}
+}
+
+func TestHTTP_TaskWithPermissionAndEvents(t *testing.T) {
+	c := startHTTP(t)
+
+	var first, second agent.SessionState
+	if code := c.do(http.MethodPost, "/v1/sessions", "", &first); code != http.StatusCreated {
+		t.Fatalf("create session returned %d", code)
+	}
+	if code := c.do(http.MethodPost, "/v1/sessions", `{"mode":"plan"}`, &second); code != http.StatusCreated || second.Mode != "plan" {
+		t.Fatalf("create session returned %d %+v", code, second)
+	}
+	if first.SessionID == second.SessionID {
+		t.Fatal("expected distinct sessions")
+	}
+	events := c.events("&session=" + first.SessionID)
+
+	base := "/v1/sessions/" + first.SessionID
+	if code := c.do(http.MethodPost, base+"/tasks", `{"text":"write a.txt"}`, nil); code != http.StatusAccepted {
+		t.Fatalf("task returned %d", code)
+	}
+	if code := c.do(http.MethodPost, base+"/tasks", `{"text":"again"}`, nil); code != http.StatusConflict {
+		t.Errorf("expected 409 while a task runs, got %d", code)
+	}
+
+	prompt := waitEvent(t, events, agent.EventPermissionPrompt)
+	if prompt.data.SessionID != first.SessionID || prompt.data.Name != "write_file" {
+		t.Fatalf("unexpected permission prompt %+v", prompt.data)
+	}
+	if code := c.do(http.MethodPost, base+"/permissions/perm_999", `{"decision":"allow"}`, nil); code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown prompt, got %d", code)
+	}
+	if code := c.do(http.MethodPost, base+"/permissions/"+prompt.data.ID, `{"decision":"maybe"}`, nil); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown decision, got %d", code)
+	}
+	if code := c.do(http.MethodPost, base+"/permissions/"+prompt.data.ID, `{"decision":"allow"}`, nil); code != http.StatusNoContent {
+		t.Fatalf("permission returned %d", code)
+	}
+
+	if result := waitEvent(t, events, agent.EventToolResult); result.data.IsError {
+		t.Errorf("expected the approved write to succeed, got %+v", result.data)
+	}
+	if end := waitEvent(t, events, EventTaskEnd); end.data.StopReason != "done" || end.data.SessionID != first.SessionID {
+		t.Errorf("unexpected task_end %+v", end.data)
+	}
+	if data, err := os.ReadFile("a.txt"); err != nil || string(data) != "hi" {
+		t.Errorf("expected a.txt to be written, got %q, %v", data, err)
+	}
+
+	// Each session has its own context
+	var stats agent.ContextStats
+	c.do(http.MethodGet, base+"/context", "", &stats)
+	if stats.Messages < 4 {
+		t.Errorf("expected the exchange in context, got %+v", stats)
+	}
+	c.do(http.MethodGet, "/v1/sessions/"+second.SessionID+"/context", "", &stats)
+	if stats.Messages != 0 {
+		t.Errorf("expected the second session to be empty, got %+v", stats)
+	}
+
+	// Closing saves the session, which can then be loaded and deleted
+	if code := c.do(http.MethodDelete, base, "", nil); code != http.StatusConflict {
+		t.Errorf("expected 409 deleting a live session, got %d", code)
+	}
+	if code := c.do(http.MethodPost, base+"/close", "", nil); code != http.StatusNoContent {
+		t.Fatalf("close returned %d", code)
+	}
+	if code := c.do(http.MethodPost, base+"/tasks", `{"text":"hello"}`, nil); code != http.StatusNotFound {
+		t.Errorf("expected 404 for a closed session, got %d", code)
+	}
+	var list struct {
+		Sessions []agent.SessionSummary `json:"sessions"`
+		Live     []string               `json:"live"`
+	}
+	c.do(http.MethodGet, "/v1/sessions", "", &list)
+	if len(list.Sessions) != 1 || list.Sessions[0].ID != first.SessionID || len(list.Live) != 1 || list.Live[0] != second.SessionID {
+		t.Errorf("unexpected session list %+v", list)
+	}
+	var saved struct {
+		Messages []llm.Message `json:"messages"`
+	}
+	if code := c.do(http.MethodGet, base, "", &saved); code != http.StatusOK || len(saved.Messages) < 4 {
+		t.Errorf("unexpected saved session %d with %d messages", code, len(saved.Messages))
+	}
+	if code := c.do(http.MethodDelete, base, "", nil); code != http.StatusNoContent {
+		t.Errorf("delete returned %d", code)
+	}
+	if code := c.do(http.MethodGet, base, "", nil); code != http.StatusNotFound {
+		t.Errorf("expected 404 after delete, got %d", code)
+	}
+	if code := c.do(http.MethodGet, "/v1/sessions/..%2Fcurrent", "", nil); code != http.StatusNotFound {
+		t.Errorf("expected 404 for a path-like id, got %d", code)
+	}
+}
+
+func TestListenAddr(t *testing.T) {
+	tests := []struct {
+		addr        string
+		allowRemote bool
+		want        string
+		wantErr     bool
+	}{
+		{":8420", false, "127.0.0.1:8420", false},
+		{"localhost:8420", false, "localhost:8420", false},
+		{"[::1]:8420", false, "[::1]:8420", false},
+		{"0.0.0.0:8420", false, "", true},
+		{"0.0.0.0:8420", true, "0.0.0.0:8420", false},
+		{"8420", false, "", true},
+	}
+	for _, tt := range tests {
+		got, err := ListenAddr(tt.addr, tt.allowRemote)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ListenAddr(%q, %v) = %q, %v", tt.addr, tt.allowRemote, got, err)
+		}
+	}
+}
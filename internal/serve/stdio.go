@@ -0,0 +1,293 @@
+// Package serve exposes the agent to editor integrations and other
+// programs: JSON-RPC 2.0 over stdio, and a local HTTP API with
+// server-sent events.
+package serve
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/abdul-hamid-achik/vecai/internal/agent"
+)
+
+// JSON-RPC error codes. The first five are defined by the spec.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+	codeBusy           = -32001 // A prompt is already running
+	codeNotFound       = -32002 // Unknown session or permission prompt
+)
+
+// EventMethod is the notification method carrying agent.StreamEvents.
+const EventMethod = "event"
+
+// Methods lists the JSON-RPC methods the server handles.
+var Methods = []string{
+	"initialize",
+	"session/start",
+	"session/list",
+	"prompt",
+	"cancel",
+	"permission/respond",
+	"mode/set",
+	"context/stats",
+	"shutdown",
+}
+
+// Info identifies the server to clients.
+type Info struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type notification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string { return e.Message }
+
+// stdioServer reads one JSON-RPC message per line and writes responses and
+// event notifications the same way.
+type stdioServer struct {
+	info Info
+	srv  *agent.Server
+
+	mu  sync.Mutex // Serializes writes
+	enc *json.Encoder
+
+	ctx      context.Context
+	prompts  sync.WaitGroup
+	shutdown bool
+}
+
+// Stdio serves a over JSON-RPC 2.0 on r and w until r ends, the client
+// calls shutdown, or ctx is cancelled. Prompts run in the background so
+// cancel and permission/respond can be handled while they stream events.
+func Stdio(ctx context.Context, a *agent.Agent, info Info, r io.Reader, w io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s := &stdioServer{info: info, enc: json.NewEncoder(w), ctx: ctx}
+	s.srv = agent.NewServer(a, func(ev agent.StreamEvent) {
+		s.write(notification{JSONRPC: "2.0", Method: EventMethod, Params: ev})
+	})
+	defer func() {
+		s.srv.Cancel()
+		s.prompts.Wait()
+	}()
+
+	lines := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		br := bufio.NewReader(r)
+		for {
+			line, err := br.ReadBytes('\n')
+			if len(bytes.TrimSpace(line)) > 0 {
+				select {
+				case lines <- line:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if err != nil {
+				readErr <- err
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-readErr:
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		case line := <-lines:
+			s.handle(line)
+			if s.shutdown {
+				return nil
+			}
+		}
+	}
+}
+
+func (s *stdioServer) write(v any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_ = s.enc.Encode(v)
+}
+
+func (s *stdioServer) reply(id json.RawMessage, result any, err error) {
+	if id == nil {
+		return // Notifications get no response
+	}
+	resp := response{JSONRPC: "2.0", ID: id}
+	if err != nil {
+		var rerr *rpcError
+		if !errors.As(err, &rerr) {
+			rerr = toRPCError(err)
+		}
+		resp.Error = rerr
+	} else {
+		if result == nil {
+			result = struct{}{}
+		}
+		resp.Result = result
+	}
+	s.write(resp)
+}
+
+// handle dispatches one message. Prompts run in their own goroutine; every
+// other method answers right away.
+func (s *stdioServer) handle(line []byte) {
+	var req request
+	if err := json.Unmarshal(line, &req); err != nil {
+		s.write(response{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{Code: codeParseError, Message: "parse error: " + err.Error()}})
+		return
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		id := req.ID
+		if id == nil {
+			id = json.RawMessage("null")
+		}
+		s.write(response{JSONRPC: "2.0", ID: id, Error: &rpcError{Code: codeInvalidRequest, Message: "invalid request"}})
+		return
+	}
+
+	if req.Method == "prompt" {
+		var params struct {
+			Text string `json:"text"`
+		}
+		if err := decodeParams(req.Params, &params); err != nil {
+			s.reply(req.ID, nil, err)
+			return
+		}
+		s.prompts.Add(1)
+		go func() {
+			defer s.prompts.Done()
+			result, err := s.srv.Prompt(s.ctx, params.Text)
+			s.reply(req.ID, result, err)
+		}()
+		return
+	}
+
+	result, err := s.call(req.Method, req.Params)
+	s.reply(req.ID, result, err)
+}
+
+// call runs every method except prompt.
+func (s *stdioServer) call(method string, raw json.RawMessage) (any, error) {
+	switch method {
+	case "initialize":
+		return map[string]any{
+			"server":         s.info,
+			"schema_version": agent.StreamSchemaVersion,
+			"methods":        Methods,
+		}, nil
+
+	case "session/start":
+		var params struct {
+			Resume string `json:"resume"`
+		}
+		if err := decodeParams(raw, &params); err != nil {
+			return nil, err
+		}
+		return s.srv.StartSession(params.Resume)
+
+	case "session/list":
+		sessions, err := s.srv.ListSessions()
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"sessions": sessions}, nil
+
+	case "cancel":
+		return map[string]bool{"cancelled": s.srv.Cancel()}, nil
+
+	case "permission/respond":
+		var params struct {
+			ID       string `json:"id"`
+			Decision string `json:"decision"`
+		}
+		if err := decodeParams(raw, &params); err != nil {
+			return nil, err
+		}
+		return nil, s.srv.RespondPermission(params.ID, params.Decision)
+
+	case "mode/set":
+		var params struct {
+			Mode string `json:"mode"`
+		}
+		if err := decodeParams(raw, &params); err != nil {
+			return nil, err
+		}
+		mode, err := s.srv.SetMode(params.Mode)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]string{"mode": mode}, nil
+
+	case "context/stats":
+		return s.srv.ContextStats(), nil
+
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	}
+	return nil, &rpcError{Code: codeMethodNotFound, Message: "method not found: " + method}
+}
+
+func decodeParams(raw json.RawMessage, v any) error {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return &rpcError{Code: codeInvalidParams, Message: "invalid params: " + err.Error()}
+	}
+	return nil
+}
+
+func toRPCError(err error) *rpcError {
+	code := codeInternalError
+	switch {
+	case errors.Is(err, agent.ErrInvalidArgument):
+		code = codeInvalidParams
+	case errors.Is(err, agent.ErrBusy):
+		code = codeBusy
+	case errors.Is(err, agent.ErrNoPrompt), errors.Is(err, agent.ErrSessionNotFound):
+		code = codeNotFound
+	}
+	return &rpcError{Code: code, Message: err.Error()}
+}
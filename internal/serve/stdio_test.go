@@ -0,0 +1,175 @@
+package serve
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/abdul-hamid-achik/vecai/internal/agent"
+	"github.com/abdul-hamid-achik/vecai/internal/config"
+	"github.com/abdul-hamid-achik/vecai/internal/llm"
+	"github.com/abdul-hamid-achik/vecai/internal/permissions"
+	"github.com/abdul-hamid-achik/vecai/internal/skills"
+	"github.com/abdul-hamid-achik/vecai/internal/tools"
+	"github.com/abdul-hamid-achik/vecai/internal/ui"
+)
+
+func newTestAgent(t *testing.T) *agent.Agent {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+
+	cfg := config.DefaultConfig()
+	cfg.Memory.Enabled = false
+	cfg.Audit.Enabled = false
+	cfg.Router.Learned = false
+
+	output := ui.NewOutputHandler()
+	input := ui.NewInputHandler()
+	a := agent.New(agent.Config{
+		LLM:         llm.NewMockLLMClient(),
+		Tools:       tools.NewRegistry(&cfg.Tools),
+		Permissions: permissions.NewPolicy(permissions.ModeAsk, input, output),
+		Skills:      skills.NewLoader(),
+		Output:      output,
+		Input:       input,
+		Config:      cfg,
+	})
+	t.Cleanup(func() { _ = a.Close() })
+	return a
+}
+
+// message is any JSON-RPC message the server writes.
+type message struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+	Params json.RawMessage `json:"params"`
+}
+
+type client struct {
+	t    *testing.T
+	in   *io.PipeWriter
+	out  *bufio.Scanner
+	done chan error
+}
+
+func startServer(t *testing.T) *client {
+	t.Helper()
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	c := &client{t: t, in: inW, out: bufio.NewScanner(outR), done: make(chan error, 1)}
+	go func() {
+		c.done <- Stdio(context.Background(), newTestAgent(t), Info{Name: "vecai", Version: "test"}, inR, outW)
+		outW.Close()
+	}()
+	return c
+}
+
+func (c *client) send(line string) {
+	c.t.Helper()
+	if _, err := io.WriteString(c.in, line+"\n"); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// next returns the next message, failing after a timeout.
+func (c *client) next() message {
+	c.t.Helper()
+	got := make(chan message, 1)
+	go func() {
+		var msg message
+		if c.out.Scan() {
+			_ = json.Unmarshal(c.out.Bytes(), &msg)
+		}
+		got <- msg
+	}()
+	select {
+	case msg := <-got:
+		return msg
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("timed out waiting for a message")
+		return message{}
+	}
+}
+
+func TestStdio(t *testing.T) {
+	c := startServer(t)
+
+	c.send(`{"jsonrpc":"2.0","id":1,"method":"initialize"}`)
+	msg := c.next()
+	var info struct {
+		Server        Info     `json:"server"`
+		SchemaVersion int      `json:"schema_version"`
+		Methods       []string `json:"methods"`
+	}
+	if err := json.Unmarshal(msg.Result, &info); err != nil || info.Server.Version != "test" || info.SchemaVersion != agent.StreamSchemaVersion {
+		t.Fatalf("unexpected initialize result %s (%v)", msg.Result, err)
+	}
+
+	c.send(`{"jsonrpc":"2.0","id":2,"method":"mode/set","params":{"mode":"plan"}}`)
+	if msg := c.next(); string(msg.Result) != `{"mode":"plan"}` {
+		t.Errorf("unexpected mode/set response %+v", msg)
+	}
+
+	// The mock answers with text, streamed as event notifications
+	c.send(`{"jsonrpc":"2.0","id":3,"method":"prompt","params":{"text":"hello"}}`)
+	var types []string
+	for {
+		msg := c.next()
+		if msg.Method == EventMethod {
+			var ev agent.StreamEvent
+			if err := json.Unmarshal(msg.Params, &ev); err != nil {
+				t.Fatal(err)
+			}
+			types = append(types, ev.Type)
+			continue
+		}
+		if string(msg.ID) != "3" || string(msg.Result) != `{"stop_reason":"done"}` {
+			t.Fatalf("unexpected prompt response %+v", msg)
+		}
+		break
+	}
+	if len(types) < 3 || types[0] != agent.EventInit || types[len(types)-1] != agent.EventDone {
+		t.Errorf("unexpected event types %v", types)
+	}
+
+	c.send(`{"jsonrpc":"2.0","id":4,"method":"context/stats"}`)
+	var stats agent.ContextStats
+	if msg := c.next(); json.Unmarshal(msg.Result, &stats) != nil || stats.Messages < 2 {
+		t.Errorf("unexpected context/stats %s", msg.Result)
+	}
+
+	c.send(`{"jsonrpc":"2.0","id":5,"method":"permission/respond","params":{"id":"perm_1","decision":"allow"}}`)
+	if msg := c.next(); msg.Error == nil || msg.Error.Code != codeNotFound {
+		t.Errorf("expected not found without a pending prompt, got %+v", msg)
+	}
+
+	c.send(`{"jsonrpc":"2.0","id":6,"method":"nope"}`)
+	if msg := c.next(); msg.Error == nil || msg.Error.Code != codeMethodNotFound {
+		t.Errorf("expected method not found, got %+v", msg)
+	}
+
+	c.send(`{not json`)
+	if msg := c.next(); msg.Error == nil || msg.Error.Code != codeParseError {
+		t.Errorf("expected parse error, got %+v", msg)
+	}
+
+	// Notifications get no response; the shutdown reply comes next
+	c.send(`{"jsonrpc":"2.0","method":"cancel"}`)
+	c.send(`{"jsonrpc":"2.0","id":7,"method":"shutdown"}`)
+	if msg := c.next(); string(msg.ID) != "7" || msg.Error != nil {
+		t.Errorf("unexpected shutdown response %+v", msg)
+	}
+	select {
+	case err := <-c.done:
+		if err != nil {
+			t.Errorf("Stdio returned %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not stop after shutdown")
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"strings"
 	"time"
 
+	ctxmgr "github.com/abdul-hamid-achik/vecai/internal/context"
 	"github.com/abdul-hamid-achik/vecai/internal/llm"
 )
 
@@ -29,6 +30,9 @@ type Session struct {
 	Model     string        `json:"model"`
 	Messages  []llm.Message `json:"messages"`
 	Summary   string        `json:"summary,omitempty"`
+
+	// State is the structured state compaction kept, restored on resume
+	State *ctxmgr.CompactionState `json:"state,omitempty"`
 }
 
 // SessionInfo contains summary information about a session for listing
@@ -104,6 +108,18 @@ func (m *Manager) Save(messages []llm.Message, model string) error {
 	return nil
 }
 
+// SetState sets the compaction state the next Save writes with the current
+// session, starting a session if none exists.
+func (m *Manager) SetState(state *ctxmgr.CompactionState) error {
+	if m.current == nil {
+		if _, err := m.StartNew(); err != nil {
+			return err
+		}
+	}
+	m.current.State = state
+	return nil
+}
+
 // Load loads a session by ID
 func (m *Manager) Load(id string) (*Session, error) {
 	path := m.sessionPath(id)
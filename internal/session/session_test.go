@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	ctxmgr "github.com/abdul-hamid-achik/vecai/internal/context"
 	"github.com/abdul-hamid-achik/vecai/internal/llm"
 )
 
@@ -64,6 +65,29 @@ func TestManager(t *testing.T) {
 		}
 	})
 
+	t.Run("Save and Load state", func(t *testing.T) {
+		sess, _ := mgr.StartNew()
+		state := &ctxmgr.CompactionState{
+			Goals:       []string{"Add retries to the fetcher"},
+			Files:       []ctxmgr.FileNote{{Path: "fetch/client.go", Reason: "retry loop added"}},
+			Compactions: 2,
+		}
+		if err := mgr.SetState(state); err != nil {
+			t.Fatal(err)
+		}
+		if err := mgr.Save([]llm.Message{{Role: "user", Content: "Hello"}}, "test"); err != nil {
+			t.Fatal(err)
+		}
+
+		loaded, err := mgr.Load(sess.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if loaded.State == nil || loaded.State.Format() != state.Format() || loaded.State.Compactions != 2 {
+			t.Errorf("expected the state to round-trip, got %+v", loaded.State)
+		}
+	})
+
 	t.Run("List", func(t *testing.T) {
 		// Create a few sessions
 		for i := 0; i < 3; i++ {
@@ -11,7 +11,9 @@ import (
 )
 
 // ASTTool parses Go files and extracts structured code information
-type ASTTool struct{}
+type ASTTool struct {
+	Workspace *Workspace // Root for paths; nil means the project root
+}
 
 func (t *ASTTool) Name() string {
 	return "ast_parse"
@@ -105,13 +107,13 @@ func (t *ASTTool) Execute(ctx context.Context, input map[string]any) (string, er
 	}
 
 	// Resolve path
-	absPath, err := filepath.Abs(path)
+	absPath, err := t.Workspace.ResolvePath(path)
 	if err != nil {
 		return "", fmt.Errorf("invalid path: %w", err)
 	}
 
 	// Validate path is within project directory
-	if err := ValidatePath(absPath); err != nil {
+	if err := t.Workspace.ValidatePath(absPath); err != nil {
 		return "", err
 	}
 
@@ -5,15 +5,23 @@ import (
 	"context"
 	"fmt"
 	"os/exec"
-	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
 // BashTool executes bash commands
 type BashTool struct {
-	Sandbox    Sandbox // OS-level sandbox; nil means no sandboxing
-	ProjectDir string  // Project root for sandbox filesystem restrictions
+	Sandbox    Sandbox        // OS-level sandbox; nil means no sandboxing
+	ProjectDir string         // Project root for sandbox filesystem restrictions
+	Policy     *CommandPolicy // Allow/deny rules; nil means the built-in rules only
+
+	readOnly atomic.Bool // Mount the project read-only, where the sandbox supports it
+}
+
+// SetProjectReadOnly makes sandboxed commands see the project read-only.
+func (t *BashTool) SetProjectReadOnly(readOnly bool) {
+	t.readOnly.Store(readOnly)
 }
 
 func (t *BashTool) Name() string {
@@ -55,11 +63,6 @@ func (t *BashTool) Execute(ctx context.Context, input map[string]any) (string, e
 		return "", fmt.Errorf("command is required")
 	}
 
-	// Multi-layer command safety check (blocklist + obfuscation + evasion)
-	if err := CheckCommandSafety(command); err != nil {
-		return "", err
-	}
-
 	timeout := 60
 	if tv, ok := input["timeout"].(float64); ok && tv > 0 {
 		timeout = int(tv)
@@ -73,28 +76,17 @@ func (t *BashTool) Execute(ctx context.Context, input map[string]any) (string, e
 	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
 	defer cancel()
 
-	// Determine command execution: sandboxed or direct
-	exe := "bash"
-	args := []string{"-c", command}
-	if t.Sandbox != nil && t.Sandbox.Available() {
-		projectDir := t.ProjectDir
-		if projectDir == "" {
-			projectDir = "."
-		}
-		var err error
-		exe, args, err = t.Sandbox.Wrap(command, projectDir)
-		if err != nil {
-			return "", fmt.Errorf("sandbox wrap failed: %w", err)
-		}
+	cmd, err := t.command(ctx, command)
+	if err != nil {
+		return "", err
 	}
-
-	cmd := exec.CommandContext(ctx, exe, args...)
-	cmd.Env = SanitizedEnv()
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
+	// Don't wait on background processes that keep the output pipes open
+	cmd.WaitDelay = time.Second
 
-	err := cmd.Run()
+	err = cmd.Run()
 
 	// Build output
 	var result strings.Builder
@@ -121,6 +113,11 @@ func (t *BashTool) Execute(ctx context.Context, input map[string]any) (string, e
 			result.WriteString("\n")
 		}
 		fmt.Fprintf(&result, "Exit code: %v", err)
+		if lr, ok := t.Sandbox.(LimitReporter); ok {
+			if limit := lr.LimitHit(cmd.ProcessState, stderr.String()); limit != "" {
+				fmt.Fprintf(&result, "\nStopped by the sandbox: hit the %s", limit)
+			}
+		}
 	}
 
 	output := result.String()
@@ -137,8 +134,47 @@ func (t *BashTool) Execute(ctx context.Context, input map[string]any) (string, e
 	return output, nil
 }
 
+// command checks a command against the policy and builds the process that
+// runs it, inside the sandbox when one is available.
+func (t *BashTool) command(ctx context.Context, command string) (*exec.Cmd, error) {
+	// Check every command in the script against the allow/deny rules
+	policy := t.Policy
+	if policy == nil {
+		policy = builtinPolicy
+	}
+	if err := policy.CheckIn(command, t.ProjectDir); err != nil {
+		return nil, err
+	}
+
+	// Determine command execution: sandboxed or direct
+	exe := "bash"
+	args := []string{"-c", command}
+	if t.Sandbox != nil && t.Sandbox.Available() {
+		projectDir := t.ProjectDir
+		if projectDir == "" {
+			projectDir = "."
+		}
+		var err error
+		if ro, ok := t.Sandbox.(ReadOnlySandbox); ok && t.readOnly.Load() {
+			exe, args, err = ro.WrapReadOnly(command, projectDir)
+		} else {
+			exe, args, err = t.Sandbox.Wrap(command, projectDir)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("sandbox wrap failed: %w", err)
+		}
+	}
+
+	cmd := exec.CommandContext(ctx, exe, args...)
+	cmd.Dir = t.ProjectDir
+	cmd.Env = SanitizedEnv()
+	return cmd, nil
+}
+
 // GrepTool searches for patterns in files
-type GrepTool struct{}
+type GrepTool struct {
+	Workspace *Workspace // Root for paths; nil means the project root
+}
 
 func (t *GrepTool) Name() string {
 	return "grep"
@@ -196,11 +232,11 @@ func (t *GrepTool) Execute(ctx context.Context, input map[string]any) (string, e
 	}
 
 	// Validate path is within project directory
-	absPath, err := filepath.Abs(path)
+	absPath, err := t.Workspace.ResolvePath(path)
 	if err != nil {
 		return "", fmt.Errorf("invalid path: %w", err)
 	}
-	if err := ValidatePath(absPath); err != nil {
+	if err := t.Workspace.ValidatePath(absPath); err != nil {
 		return "", err
 	}
 
@@ -224,6 +260,7 @@ func (t *GrepTool) Execute(ctx context.Context, input map[string]any) (string, e
 	args = append(args, pattern, path)
 
 	cmd := exec.CommandContext(ctx, "rg", args...)
+	cmd.Dir = t.Workspace.Dir()
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
@@ -274,6 +311,7 @@ func (t *GrepTool) fallbackGrep(ctx context.Context, input map[string]any) (stri
 	args = append(args, pattern, path)
 
 	cmd := exec.CommandContext(ctx, "grep", args...)
+	cmd.Dir = t.Workspace.Dir()
 	var stdout bytes.Buffer
 	cmd.Stdout = &stdout
 
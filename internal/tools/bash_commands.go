@@ -0,0 +1,109 @@
+package tools
+
+import (
+	"path"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// SimpleCommands splits a bash script into the simple commands it runs, each
+// as its words joined by spaces. It covers commands chained with ;, && or |,
+// in subshells, substitutions and functions, behind wrappers such as sudo
+// or env, and in sh -c and eval scripts. Words only known at run time are
+// kept as written. It reports false if the script does not parse.
+func SimpleCommands(script string) ([]string, bool) {
+	var cmds []string
+	ok := simpleCommands(script, 0, &cmds)
+	return cmds, ok
+}
+
+func simpleCommands(src string, depth int, cmds *[]string) bool {
+	if depth > maxPolicyDepth {
+		return true
+	}
+	file, err := syntax.NewParser(syntax.Variant(syntax.LangBash)).Parse(strings.NewReader(src), "")
+	if err != nil {
+		return false
+	}
+	c := &scriptCheck{
+		env:   newScriptEnv(SanitizedEnv()),
+		funcs: make(map[string]bool),
+	}
+	c.collect(file)
+
+	ok := true
+	syntax.Walk(file, func(node syntax.Node) bool {
+		call, isCall := node.(*syntax.CallExpr)
+		if !isCall || len(call.Args) == 0 {
+			return true
+		}
+		var args []shellArg
+		for _, w := range call.Args {
+			if fields := c.fields(w); len(fields) == 1 && fields[0].dynamic {
+				args = append(args, shellArg{value: wordSource(w), dynamic: true})
+			} else {
+				args = append(args, fields...)
+			}
+		}
+		for len(args) > 0 {
+			*cmds = append(*cmds, joinArgs(args))
+			name := strings.ToLower(path.Base(args[0].value))
+			rest := args[1:]
+			if _, wrapped := wrappers[name]; wrapped {
+				next, _, runs := unwrap(name, rest)
+				if !runs {
+					break
+				}
+				args = next
+				continue
+			}
+			if script, nested := nestedScript(name, rest); nested {
+				ok = simpleCommands(script, depth+1, cmds) && ok
+			}
+			break
+		}
+		return true
+	})
+	return ok
+}
+
+// nestedScript returns the script an eval or a shell's -c flag runs.
+func nestedScript(name string, args []shellArg) (string, bool) {
+	if name == "eval" {
+		return joinArgs(args), len(args) > 0
+	}
+	if !shells[name] {
+		return "", false
+	}
+	for i, a := range args {
+		if a.dynamic || len(a.value) < 2 || a.value[0] != '-' || strings.HasPrefix(a.value, "--") {
+			continue
+		}
+		if strings.Contains(a.value[1:], "c") {
+			for _, operand := range args[i+1:] {
+				if !strings.HasPrefix(operand.value, "-") {
+					return operand.value, true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+func joinArgs(args []shellArg) string {
+	parts := make([]string, len(args))
+	for i, a := range args {
+		parts[i] = a.value
+	}
+	return strings.Join(parts, " ")
+}
+
+// wordSource prints a word as it appears in the script.
+func wordSource(w *syntax.Word) string {
+	var sb strings.Builder
+	if err := syntax.NewPrinter().Print(&sb, w); err != nil {
+		return ""
+	}
+	return sb.String()
+}
@@ -0,0 +1,30 @@
+package tools
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSimpleCommands(t *testing.T) {
+	tests := []struct {
+		script string
+		want   []string
+	}{
+		{"go test ./...", []string{"go test ./..."}},
+		{"cd x && make; echo done | tee log", []string{"cd x", "make", "echo done", "tee log"}},
+		{"echo $(rm -rf x)", []string{"echo $(rm -rf x)", "rm -rf x"}},
+		{"sudo -u root rm x", []string{"sudo -u root rm x", "rm x"}},
+		{"sh -c 'git push; true'", []string{"sh -c git push; true", "git push", "true"}},
+		{"cmd=rm; $cmd x", []string{"rm x"}},
+	}
+	for _, tt := range tests {
+		got, ok := SimpleCommands(tt.script)
+		if !ok || !slices.Equal(got, tt.want) {
+			t.Errorf("SimpleCommands(%q) = %q, %v, want %q", tt.script, got, ok, tt.want)
+		}
+	}
+
+	if _, ok := SimpleCommands("echo 'unterminated"); ok {
+		t.Error("expected a script that does not parse to fail")
+	}
+}
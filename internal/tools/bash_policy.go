@@ -0,0 +1,992 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"mvdan.cc/sh/v3/expand"
+	"mvdan.cc/sh/v3/pattern"
+	"mvdan.cc/sh/v3/syntax"
+
+	"github.com/abdul-hamid-achik/vecai/internal/config"
+)
+
+// outsideProject matches paths that recursive deletes and permission
+// changes must not touch: the root, absolute paths, the home directory and
+// the project's parent. Path operands are resolved against the directory
+// the command runs in before they are matched, see resolvePaths.
+const outsideProject = "/|/*|~|~/*|..|../*"
+
+// builtinDenyRules are always checked, before any configured rules.
+var builtinDenyRules = []config.CommandRuleConfig{
+	{Command: "rm", Args: []string{"-r|-R|--recursive", outsideProject}, Reason: "recursive delete outside the project"},
+	{Command: "mkfs*|mke2fs|mkswap|wipefs|fdisk|sfdisk|parted", Reason: "formats or repartitions a disk"},
+	{Command: "dd", Args: []string{"of=/dev/*"}, Reason: "writes to a device"},
+	{Command: "shutdown|reboot|halt|poweroff", Reason: "shuts down or restarts the machine"},
+	{Command: "init|telinit", Args: []string{"0|6"}, Reason: "shuts down or restarts the machine"},
+	{Command: "systemctl", Args: []string{"poweroff|reboot|halt"}, Reason: "shuts down or restarts the machine"},
+	{Command: "chmod", Args: []string{"-R|--recursive", outsideProject}, Reason: "recursive permission change outside the project"},
+	{Command: "chown", Args: []string{"-R|--recursive", outsideProject}, Reason: "recursive ownership change outside the project"},
+	{Command: "find", Args: []string{outsideProject, "-delete"}, Reason: "deletes files outside the project"},
+	{Command: "find", Args: []string{outsideProject, "-exec|-execdir|-ok|-okdir", "rm|*/rm"}, Reason: "deletes files outside the project"},
+}
+
+// redirectRules block redirections to sockets and disks.
+var redirectRules = []struct {
+	target    argPattern
+	writeOnly bool
+	reason    string
+}{
+	{mustArgPattern("/dev/tcp/*|/dev/udp/*"), false, "opens a network connection"},
+	{mustArgPattern("/dev/sd*|/dev/hd*|/dev/vd*|/dev/xvd*|/dev/nvme*|/dev/mmcblk*|/dev/disk*"), true, "writes to a disk device"},
+}
+
+// safeBuiltins may run even when an allow list is configured.
+var safeBuiltins = map[string]bool{
+	":": true, "[": true, "cd": true, "declare": true, "echo": true, "exit": true,
+	"export": true, "false": true, "local": true, "printf": true, "pwd": true,
+	"read": true, "return": true, "set": true, "shift": true, "test": true,
+	"true": true, "unset": true,
+}
+
+// shells run a script from -c, a file or standard input.
+var shells = map[string]bool{
+	"bash": true, "sh": true, "zsh": true, "dash": true, "ksh": true,
+	"mksh": true, "ash": true, "fish": true,
+}
+
+// interpreter describes the flags of a language runtime that runs a script
+// from a file, inline code or standard input.
+type interpreter struct {
+	codeFlags   string // Flags followed by inline code
+	moduleFlags string // Flags followed by a module or file to run
+	valueFlags  string // Other flags that take a value
+}
+
+var interpreters = map[string]interpreter{
+	"python": {codeFlags: "c", moduleFlags: "m", valueFlags: "WXQ"},
+	"perl":   {codeFlags: "eE"},
+	"ruby":   {codeFlags: "e", valueFlags: "rICE"},
+	"node":   {codeFlags: "ep", valueFlags: "r"},
+	"php":    {codeFlags: "rBRE", moduleFlags: "F", valueFlags: "cdz"},
+	"lua":    {codeFlags: "e", valueFlags: "l"},
+}
+
+// wrapper describes a command that runs the command in its arguments.
+type wrapper struct {
+	valueFlags string   // Short flags that take a value
+	longValue  []string // Long flags that take a value
+	operands   int      // Operands before the command, e.g. the duration of timeout
+	chdir      string   // The short flag that runs the command in another directory
+}
+
+var wrappers = map[string]wrapper{
+	"sudo":    {valueFlags: "ughpCUrtDR", longValue: []string{"--user", "--group", "--host", "--prompt", "--close-from", "--other-user", "--role", "--type", "--chdir", "--chroot"}, chdir: "D"},
+	"doas":    {valueFlags: "uC"},
+	"env":     {valueFlags: "uCS", longValue: []string{"--unset", "--chdir", "--split-string"}, chdir: "C"},
+	"nice":    {valueFlags: "n", longValue: []string{"--adjustment"}},
+	"nohup":   {},
+	"time":    {valueFlags: "fo", longValue: []string{"--format", "--output"}},
+	"timeout": {valueFlags: "sk", longValue: []string{"--signal", "--kill-after"}, operands: 1},
+	"command": {},
+	"builtin": {},
+	"exec":    {valueFlags: "a"},
+	"xargs":   {valueFlags: "IaEdLnPs", longValue: []string{"--arg-file", "--eof", "--delimiter", "--max-lines", "--max-args", "--max-procs", "--max-chars", "--replace"}},
+	"stdbuf":  {valueFlags: "ioe", longValue: []string{"--input", "--output", "--error"}},
+	"ionice":  {valueFlags: "cnpPu", longValue: []string{"--class", "--classdata", "--pid", "--pgid", "--uid"}},
+	"busybox": {},
+}
+
+// maxPolicyDepth bounds how deeply bash -c and eval strings are unpacked.
+const maxPolicyDepth = 8
+
+// CommandPolicy decides which shell commands the bash tool may run. It
+// parses a command into a shell syntax tree and checks every simple command
+// in it against allow and deny rules: commands in pipelines, subshells,
+// command substitutions and function bodies, the commands run by wrappers
+// such as sudo, xargs and find -exec, and the strings given to bash -c and
+// eval. Values only known at run time count as matching every deny rule, so
+// quoting, variables and encodings cannot hide a blocked command.
+type CommandPolicy struct {
+	allow     []*commandRule
+	deny      []*commandRule
+	allowOnly bool // Only commands matching an allow rule may run
+}
+
+// builtinPolicy holds only the built-in rules.
+var builtinPolicy = &CommandPolicy{deny: mustCommandRules(builtinDenyRules)}
+
+// NewCommandPolicy builds a policy from the built-in deny rules and the
+// configured allow and deny rules. Invalid rules are left out and reported
+// in the error; an allow list whose rules are all invalid allows nothing.
+func NewCommandPolicy(cfg config.BashToolConfig) (*CommandPolicy, error) {
+	p := &CommandPolicy{
+		deny:      slices.Clone(builtinPolicy.deny),
+		allowOnly: len(cfg.Allow) > 0,
+	}
+	var errs []error
+	for i, rc := range cfg.Deny {
+		rule, err := newCommandRule(rc)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("tools.bash.deny[%d]: %w", i, err))
+			continue
+		}
+		p.deny = append(p.deny, rule)
+	}
+	for i, rc := range cfg.Allow {
+		rule, err := newCommandRule(rc)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("tools.bash.allow[%d]: %w", i, err))
+			continue
+		}
+		p.allow = append(p.allow, rule)
+	}
+	return p, errors.Join(errs...)
+}
+
+// CheckCommandSafety checks a command against the built-in rules.
+// Returns nil if the command is safe, or an error describing why it was blocked.
+func CheckCommandSafety(command string) error {
+	return builtinPolicy.Check(command)
+}
+
+// Check returns nil if the policy allows command, or an error describing
+// which part of it was blocked. Relative paths in the command are checked
+// as relative to the project directory.
+func (p *CommandPolicy) Check(command string) error {
+	return p.CheckIn(command, "")
+}
+
+// CheckIn checks a command run in dir, the absolute path of the project
+// directory. Paths the command touches resolve against dir and any cd
+// before them in the script, so rm -rf ../.. and cd / && rm -rf * are
+// caught. An empty dir is the same as Check.
+func (p *CommandPolicy) CheckIn(command, dir string) error {
+	if dir != "" && !filepath.IsAbs(dir) {
+		dir = ""
+	}
+	if dir != "" {
+		dir = filepath.Clean(dir)
+	}
+	return p.checkSource(command, 0, dir, startDirs(dir))
+}
+
+// checkSource checks a script that starts in one of dirs.
+func (p *CommandPolicy) checkSource(src string, depth int, root string, dirs workDirs) error {
+	if depth > maxPolicyDepth {
+		return blocked("commands are nested too deeply to check")
+	}
+	file, err := syntax.NewParser(syntax.Variant(syntax.LangBash)).Parse(strings.NewReader(src), "")
+	if err != nil {
+		return blocked("cannot parse command: %v", err)
+	}
+	c := &scriptCheck{
+		policy: p,
+		depth:  depth,
+		root:   root,
+		env:    newScriptEnv(SanitizedEnv()),
+		funcs:  make(map[string]bool),
+		piped:  make(map[*syntax.CallExpr]bool),
+		stdin:  make(map[*syntax.CallExpr]*syntax.Redirect),
+	}
+	return c.run(file, dirs)
+}
+
+// checkRules checks one command against the deny rules and the allow list.
+// Functions defined in the script are checked where they are defined.
+func (p *CommandPolicy) checkRules(name string, args []shellArg, isFunc bool) error {
+	for _, rule := range p.deny {
+		if rule.matches(name, args, true) {
+			return blocked("%s: %s", name, rule.reason)
+		}
+	}
+	if !p.allowOnly || isFunc || safeBuiltins[name] {
+		return nil
+	}
+	for _, rule := range p.allow {
+		if rule.matches(name, args, false) {
+			return nil
+		}
+	}
+	return blocked("%s is not in the bash allow list", name)
+}
+
+func blocked(format string, args ...any) error {
+	return fmt.Errorf("command blocked: "+format, args...)
+}
+
+// commandRule matches a command by name and arguments.
+type commandRule struct {
+	command argPattern
+	args    []argPattern
+	reason  string
+}
+
+func newCommandRule(rc config.CommandRuleConfig) (*commandRule, error) {
+	if rc.Command == "" {
+		return nil, errors.New("command is required")
+	}
+	command, err := compileArgPattern(rc.Command)
+	if err != nil {
+		return nil, err
+	}
+	rule := &commandRule{command: command, reason: rc.Reason}
+	if rule.reason == "" {
+		rule.reason = "matches a deny rule in tools.bash.deny"
+	}
+	for _, a := range rc.Args {
+		p, err := compileArgPattern(a)
+		if err != nil {
+			return nil, err
+		}
+		rule.args = append(rule.args, p)
+	}
+	return rule, nil
+}
+
+func mustCommandRules(rcs []config.CommandRuleConfig) []*commandRule {
+	rules := make([]*commandRule, len(rcs))
+	for i, rc := range rcs {
+		rule, err := newCommandRule(rc)
+		if err != nil {
+			panic(err)
+		}
+		rules[i] = rule
+	}
+	return rules
+}
+
+// matches reports whether a command matches the rule: its name matches and
+// every argument pattern matches some argument. A value only known at run
+// time matches any pattern of a deny rule and none of an allow rule; one
+// that may split into several words can match them all.
+func (r *commandRule) matches(name string, args []shellArg, deny bool) bool {
+	if !r.command.match(name) {
+		return false
+	}
+	missing := 0
+	for _, p := range r.args {
+		if !slices.ContainsFunc(args, func(a shellArg) bool { return !a.dynamic && p.match(a.value) }) {
+			missing++
+		}
+	}
+	if missing == 0 || !deny {
+		return missing == 0
+	}
+	unknown := 0
+	for _, a := range args {
+		if a.split {
+			return true
+		}
+		if a.dynamic {
+			unknown++
+		}
+	}
+	return unknown >= missing
+}
+
+// argPattern matches a value against shell globs separated by "|". In the
+// globs * also matches /. A single-letter flag such as -r also matches
+// combined short flags such as -rf.
+type argPattern struct {
+	globs []*regexp.Regexp
+	flags string
+}
+
+func compileArgPattern(s string) (argPattern, error) {
+	var p argPattern
+	for _, alt := range strings.Split(s, "|") {
+		if alt == "" {
+			return p, fmt.Errorf("empty alternative in pattern %q", s)
+		}
+		expr, err := pattern.Regexp(alt, pattern.EntireString)
+		if err != nil {
+			return p, fmt.Errorf("invalid pattern %q: %w", alt, err)
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return p, fmt.Errorf("invalid pattern %q: %w", alt, err)
+		}
+		p.globs = append(p.globs, re)
+		if len(alt) == 2 && alt[0] == '-' && isLetter(alt[1]) {
+			p.flags += alt[1:]
+		}
+	}
+	return p, nil
+}
+
+func mustArgPattern(s string) argPattern {
+	p, err := compileArgPattern(s)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+func (p argPattern) match(s string) bool {
+	for _, re := range p.globs {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return p.flags != "" && len(s) > 2 && s[0] == '-' && s[1] != '-' && strings.ContainsAny(s[1:], p.flags)
+}
+
+func isLetter(b byte) bool {
+	return 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z'
+}
+
+// shellArg is one argument of a command after expansion.
+type shellArg struct {
+	value   string
+	dynamic bool // The value is only known at run time
+	split   bool // A dynamic value that may split into several arguments
+}
+
+// commandInput says where a command's standard input comes from.
+type commandInput struct {
+	piped bool             // Another command's output
+	doc   *syntax.Redirect // A here-document or here-string
+}
+
+// scriptCheck checks one parsed script against a policy.
+type scriptCheck struct {
+	policy *CommandPolicy
+	depth  int
+	root   string // The project directory, or "" when not known
+	env    *scriptEnv
+	dirs   map[*syntax.CallExpr]workDirs         // Directories each command may run in
+	funcs  map[string]bool                       // Functions the script defines
+	piped  map[*syntax.CallExpr]bool             // Commands reading another command's output
+	stdin  map[*syntax.CallExpr]*syntax.Redirect // Commands with standard input redirected
+}
+
+func (c *scriptCheck) run(file *syntax.File, start workDirs) error {
+	c.collect(file)
+	c.dirs = c.flowDirs(file, start)
+	var err error
+	syntax.Walk(file, func(node syntax.Node) bool {
+		// Returning false only skips a node's children, not its siblings
+		if err != nil {
+			return false
+		}
+		switch n := node.(type) {
+		case *syntax.Stmt:
+			c.noteStdin(n)
+		case *syntax.BinaryCmd:
+			if n.Op == syntax.Pipe || n.Op == syntax.PipeAll {
+				c.markPiped(n.Y)
+			}
+		case *syntax.CallExpr:
+			err = c.checkCall(n)
+		case *syntax.Redirect:
+			err = c.checkRedirect(n)
+		case *syntax.FuncDecl:
+			err = c.checkFunc(n)
+		}
+		return err == nil
+	})
+	return err
+}
+
+// collect records the functions the script defines and the variables it
+// assigns, so expansions can tell values known before the script runs from
+// values only known at run time.
+func (c *scriptCheck) collect(file *syntax.File) {
+	syntax.Walk(file, func(node syntax.Node) bool {
+		switch n := node.(type) {
+		case *syntax.FuncDecl:
+			c.funcs[n.Name.Value] = true
+		case *syntax.Assign:
+			switch {
+			case n.Name == nil || n.Naked:
+			case n.Append || n.Index != nil || n.Array != nil:
+				c.env.assign(n.Name.Value, nil)
+			case n.Value == nil:
+				c.env.assign(n.Name.Value, &syntax.Word{})
+			default:
+				c.env.assign(n.Name.Value, n.Value)
+			}
+		case *syntax.WordIter:
+			c.env.assign(n.Name.Value, nil)
+		case *syntax.BinaryArithm:
+			switch n.Op {
+			case syntax.Assgn, syntax.AddAssgn, syntax.SubAssgn, syntax.MulAssgn, syntax.QuoAssgn, syntax.RemAssgn,
+				syntax.AndAssgn, syntax.OrAssgn, syntax.XorAssgn, syntax.ShlAssgn, syntax.ShrAssgn:
+				c.arithmAssign(n.X)
+			}
+		case *syntax.UnaryArithm:
+			if n.Op == syntax.Inc || n.Op == syntax.Dec {
+				c.arithmAssign(n.X)
+			}
+		case *syntax.CallExpr:
+			c.builtinAssigns(n.Args)
+		}
+		return true
+	})
+}
+
+func (c *scriptCheck) arithmAssign(x syntax.ArithmExpr) {
+	if w, ok := x.(*syntax.Word); ok && w.Lit() != "" {
+		c.env.assign(w.Lit(), nil)
+	}
+}
+
+var identPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// builtinAssigns records the variables builtins such as read set at run time.
+func (c *scriptCheck) builtinAssigns(args []*syntax.Word) {
+	if len(args) == 0 {
+		return
+	}
+	switch args[0].Lit() {
+	case "read", "mapfile", "readarray", "getopts", "unset":
+		for _, w := range args[1:] {
+			if identPattern.MatchString(w.Lit()) {
+				c.env.assign(w.Lit(), nil)
+			}
+		}
+	case "printf":
+		for i := 1; i+1 < len(args); i++ {
+			if args[i].Lit() == "-v" {
+				c.env.assign(args[i+1].Lit(), nil)
+			}
+		}
+	}
+}
+
+// noteStdin records a command's standard input redirection.
+func (c *scriptCheck) noteStdin(stmt *syntax.Stmt) {
+	call, ok := stmt.Cmd.(*syntax.CallExpr)
+	if !ok {
+		return
+	}
+	for _, r := range stmt.Redirs {
+		if r.N != nil && r.N.Value != "0" {
+			continue
+		}
+		switch r.Op {
+		case syntax.RdrIn, syntax.RdrInOut, syntax.Hdoc, syntax.DashHdoc, syntax.WordHdoc:
+			c.stdin[call] = r
+		}
+	}
+}
+
+// markPiped marks every command on the receiving end of a pipe.
+func (c *scriptCheck) markPiped(stmt *syntax.Stmt) {
+	syntax.Walk(stmt, func(node syntax.Node) bool {
+		if call, ok := node.(*syntax.CallExpr); ok {
+			c.piped[call] = true
+		}
+		return true
+	})
+}
+
+func (c *scriptCheck) checkCall(call *syntax.CallExpr) error {
+	var args []shellArg
+	for _, w := range call.Args {
+		args = append(args, c.fields(w)...)
+	}
+	in := commandInput{piped: c.piped[call]}
+	if r, ok := c.stdin[call]; ok {
+		in = commandInput{}
+		if r.Op != syntax.RdrIn && r.Op != syntax.RdrInOut {
+			in.doc = r
+		}
+	}
+	dirs, ok := c.dirs[call]
+	if !ok {
+		dirs = workDirs{unknown: true}
+	}
+	return c.checkArgs(args, in, dirs)
+}
+
+// checkArgs checks a command run in one of dirs, then any command it runs.
+func (c *scriptCheck) checkArgs(args []shellArg, in commandInput, dirs workDirs) error {
+	for len(args) > 0 {
+		if args[0].dynamic {
+			return blocked("the command name is only known at run time")
+		}
+		name := strings.ToLower(path.Base(args[0].value))
+		rest := c.resolvePaths(name, args[1:], dirs)
+		if err := c.policy.checkRules(name, rest, c.funcs[name]); err != nil {
+			return err
+		}
+		if c.funcs[name] {
+			return nil
+		}
+
+		if _, ok := wrappers[name]; ok {
+			next, chdir, ok := unwrap(name, rest)
+			if !ok {
+				// A bare exec at the end of a pipe dresses up a pipe into a shell
+				if name == "exec" && in.piped {
+					return blocked("pipes input into exec")
+				}
+				return nil
+			}
+			if name == "xargs" {
+				in = commandInput{}
+			}
+			if chdir != nil {
+				if chdir.dynamic {
+					dirs = workDirs{unknown: true}
+				} else {
+					dirs = dirs.cd(chdir.value)
+				}
+			}
+			args = next
+			continue
+		}
+		if shells[name] {
+			return c.checkShell(name, rest, in, dirs)
+		}
+		if interp, ok := interpreterFor(name); ok {
+			return checkInterpreter(name, interp, rest, in)
+		}
+		switch name {
+		case "eval":
+			parts := make([]string, len(rest))
+			for i, a := range rest {
+				if a.dynamic {
+					return blocked("eval runs a command only known at run time")
+				}
+				parts[i] = a.value
+			}
+			return c.checkNested(strings.Join(parts, " "), dirs)
+		case "source", ".":
+			if len(rest) > 0 && rest[0].dynamic {
+				return blocked("%s reads a script only known at run time", name)
+			}
+		case "find":
+			return c.checkFind(rest, dirs)
+		}
+		return nil
+	}
+	return nil
+}
+
+// unwrap returns the command a wrapper runs, or false if it runs none. It
+// also returns the directory the wrapper runs it in, if it changes it.
+func unwrap(name string, args []shellArg) ([]shellArg, *shellArg, bool) {
+	w := wrappers[name]
+	operands := w.operands
+	replace := ""
+	var chdir *shellArg
+	i := 0
+scan:
+	for ; i < len(args); i++ {
+		v := args[i].value
+		switch {
+		case args[i].dynamic:
+			break scan
+		case v == "--":
+			i++
+			break scan
+		case strings.HasPrefix(v, "--"):
+			flag, value, hasValue := strings.Cut(v, "=")
+			dir := shellArg{value: value}
+			if !hasValue && slices.Contains(w.longValue, flag) {
+				i++
+				if i < len(args) {
+					dir = args[i]
+				}
+			}
+			if w.chdir != "" && flag == "--chdir" {
+				chdir = &dir
+			}
+		case len(v) > 1 && v[0] == '-':
+			if name == "command" && strings.ContainsAny(v, "vV") {
+				return nil, nil, false // command -v only looks the command up
+			}
+			if j := strings.IndexAny(v[1:], w.valueFlags) + 1; w.valueFlags != "" && j > 0 {
+				value := shellArg{value: v[j+1:]}
+				if value.value == "" && i+1 < len(args) {
+					i++
+					value = args[i]
+				}
+				switch {
+				case name == "env" && v[j] == 'S':
+					// env -S splits its value into the command and arguments
+					var split []shellArg
+					for _, f := range strings.Fields(value.value) {
+						split = append(split, shellArg{value: f})
+					}
+					return append(split, args[i+1:]...), chdir, len(split) > 0 || i+1 < len(args)
+				case name == "xargs" && v[j] == 'I':
+					replace = value.value
+				case w.chdir != "" && v[j] == w.chdir[0]:
+					chdir = &value
+				}
+			}
+		case name == "env" && strings.Contains(v, "="):
+		case operands > 0:
+			operands--
+		default:
+			break scan
+		}
+	}
+	if i >= len(args) {
+		return nil, nil, false
+	}
+	next := slices.Clone(args[i:])
+	if name == "xargs" {
+		// Arguments read from input count as one value only known at run time
+		if replace == "" {
+			return append(next, shellArg{dynamic: true}), nil, true
+		}
+		for k := range next {
+			if strings.Contains(next[k].value, replace) {
+				next[k] = shellArg{dynamic: true}
+			}
+		}
+	}
+	return next, chdir, true
+}
+
+// checkShell checks the script a shell runs: a -c string, a file or its
+// standard input.
+func (c *scriptCheck) checkShell(name string, args []shellArg, in commandInput, dirs workDirs) error {
+	command, stdin := false, false
+	i := 0
+	for ; i < len(args); i++ {
+		v := args[i].value
+		if args[i].dynamic {
+			break
+		}
+		if v == "--" || v == "-" {
+			i++
+			break
+		}
+		if strings.HasPrefix(v, "--") {
+			if v == "--rcfile" || v == "--init-file" {
+				i++
+			}
+			continue
+		}
+		if len(v) < 2 || (v[0] != '-' && v[0] != '+') {
+			break
+		}
+		for _, f := range v[1:] {
+			switch f {
+			case 'c':
+				command = true
+			case 's':
+				stdin = true
+			case 'o', 'O':
+				i++
+			}
+		}
+	}
+	operands := args[min(i, len(args)):]
+
+	switch {
+	case command:
+		if len(operands) == 0 {
+			return nil
+		}
+		if operands[0].dynamic {
+			return blocked("%s -c runs a command only known at run time", name)
+		}
+		return c.checkNested(operands[0].value, dirs)
+	case !stdin && len(operands) > 0:
+		if operands[0].dynamic {
+			return blocked("%s runs a script only known at run time", name)
+		}
+		return nil
+	case in.doc != nil:
+		src, ok := c.document(in.doc)
+		if !ok {
+			return blocked("%s reads a script only known at run time", name)
+		}
+		return c.checkNested(src, dirs)
+	case in.piped:
+		return blocked("pipes input into %s to run as a script", name)
+	}
+	return nil
+}
+
+func interpreterFor(name string) (interpreter, bool) {
+	if strings.HasPrefix(name, "python") || strings.HasPrefix(name, "pypy") {
+		return interpreters["python"], true
+	}
+	if name == "nodejs" {
+		name = "node"
+	}
+	interp, ok := interpreters[name]
+	return interp, ok
+}
+
+// checkInterpreter blocks a runtime reading code from a pipe or from a file
+// only known at run time. Inline code, given in a flag or a here-document,
+// cannot be checked and is blocked too: python -c 'import os; os.system(...)'
+// can run anything.
+func checkInterpreter(name string, interp interpreter, args []shellArg, in commandInput) error {
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a.dynamic {
+			return blocked("%s runs a script only known at run time", name)
+		}
+		v := a.value
+		if v == "-" {
+			break
+		}
+		if v == "--" {
+			if i+1 < len(args) && args[i+1].dynamic {
+				return blocked("%s runs a script only known at run time", name)
+			}
+			if i+1 < len(args) {
+				return nil
+			}
+			break
+		}
+		if strings.HasPrefix(v, "--") {
+			if flag, _, _ := strings.Cut(v, "="); flag == "--eval" || flag == "--print" {
+				return blocked("%s runs inline code, which cannot be checked", name)
+			}
+			continue
+		}
+		if len(v) < 2 || v[0] != '-' {
+			return nil
+		}
+		for j := 1; j < len(v); j++ {
+			if strings.IndexByte(interp.codeFlags, v[j]) >= 0 {
+				return blocked("%s runs inline code, which cannot be checked", name)
+			}
+			if strings.IndexByte(interp.moduleFlags, v[j]) >= 0 {
+				return nil
+			}
+			if strings.IndexByte(interp.valueFlags, v[j]) >= 0 {
+				if j == len(v)-1 {
+					i++
+				}
+				break
+			}
+		}
+	}
+	if in.doc != nil {
+		return blocked("%s runs inline code from a here-document, which cannot be checked", name)
+	}
+	if in.piped {
+		return blocked("pipes input into %s to run as code", name)
+	}
+	return nil
+}
+
+// checkFind checks the commands find runs for each file. The -execdir
+// commands run in directories only known at run time.
+func (c *scriptCheck) checkFind(args []shellArg, dirs workDirs) error {
+	for i := 0; i < len(args); i++ {
+		switch flag := args[i].value; flag {
+		case "-exec", "-execdir", "-ok", "-okdir":
+			var command []shellArg
+			for i++; i < len(args) && args[i].value != ";" && args[i].value != "+"; i++ {
+				a := args[i]
+				if strings.Contains(a.value, "{}") {
+					a = shellArg{dynamic: true}
+				}
+				command = append(command, a)
+			}
+			in := dirs
+			if flag == "-execdir" || flag == "-okdir" {
+				in = workDirs{unknown: true}
+			}
+			if err := c.checkArgs(command, commandInput{}, in); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *scriptCheck) checkRedirect(r *syntax.Redirect) error {
+	switch r.Op {
+	case syntax.Hdoc, syntax.DashHdoc, syntax.WordHdoc:
+		return nil
+	}
+	target, ok := c.literal(r.Word)
+	if !ok {
+		return blocked("redirects to a path only known at run time")
+	}
+	write := r.Op != syntax.RdrIn && r.Op != syntax.DplIn
+	for _, rule := range redirectRules {
+		if (write || !rule.writeOnly) && rule.target.match(target) {
+			return blocked("redirect %s", rule.reason)
+		}
+	}
+	return nil
+}
+
+// checkFunc blocks functions that call themselves, the shape of the classic
+// fork bomb :(){ :|:& };:
+func (c *scriptCheck) checkFunc(fd *syntax.FuncDecl) error {
+	name := fd.Name.Value
+	recursive := false
+	syntax.Walk(fd.Body, func(node syntax.Node) bool {
+		if call, ok := node.(*syntax.CallExpr); ok && len(call.Args) > 0 && call.Args[0].Lit() == name {
+			recursive = true
+		}
+		return !recursive
+	})
+	if recursive {
+		return blocked("function %s calls itself", name)
+	}
+	return nil
+}
+
+func (c *scriptCheck) checkNested(src string, dirs workDirs) error {
+	return c.policy.checkSource(src, c.depth+1, c.root, dirs)
+}
+
+var errRunTime = errors.New("only known at run time")
+
+// expandConfig expands words without running anything: command and
+// process substitutions fail, and globs are kept as they are.
+func expandConfig(env expand.Environ) *expand.Config {
+	return &expand.Config{
+		Env:       env,
+		CmdSubst:  func(io.Writer, *syntax.CmdSubst) error { return errRunTime },
+		ProcSubst: func(*syntax.ProcSubst) (string, error) { return "", errRunTime },
+	}
+}
+
+// fields expands a word into arguments as the shell would, without globbing.
+func (c *scriptCheck) fields(w *syntax.Word) []shellArg {
+	c.env.dynamic = false
+	fields, err := expand.Fields(expandConfig(c.env), w)
+	if err != nil || c.env.dynamic {
+		return []shellArg{{dynamic: true, split: unquotedExpansion(w)}}
+	}
+	args := make([]shellArg, len(fields))
+	for i, f := range fields {
+		args[i] = shellArg{value: f}
+	}
+	return args
+}
+
+// literal expands a word into a single value and reports whether it is
+// known before the script runs.
+func (c *scriptCheck) literal(w *syntax.Word) (string, bool) {
+	if w == nil {
+		return "", true
+	}
+	c.env.dynamic = false
+	s, err := expand.Literal(expandConfig(c.env), w)
+	return s, err == nil && !c.env.dynamic
+}
+
+// document expands a here-document or here-string.
+func (c *scriptCheck) document(r *syntax.Redirect) (string, bool) {
+	if r.Op == syntax.WordHdoc || r.Hdoc == nil {
+		return c.literal(r.Word)
+	}
+	c.env.dynamic = false
+	s, err := expand.Document(expandConfig(c.env), r.Hdoc)
+	return s, err == nil && !c.env.dynamic
+}
+
+// unquotedExpansion reports whether a word has an unquoted expansion, which
+// the shell may split into several arguments.
+func unquotedExpansion(w *syntax.Word) bool {
+	for _, part := range w.Parts {
+		switch part.(type) {
+		case *syntax.ParamExp, *syntax.CmdSubst:
+			return true
+		}
+	}
+	return false
+}
+
+// scriptEnv resolves variables during expansion. A variable the script
+// assigns once, to a value known before it runs, resolves to that value; a
+// variable it never assigns resolves from the bash tool's environment.
+// Reading any other variable marks the expansion as dynamic.
+type scriptEnv struct {
+	base      map[string]string
+	assigned  map[string][]*syntax.Word // nil entries are values only known at run time
+	values    map[string]string
+	resolving map[string]bool
+	dynamic   bool
+}
+
+var _ expand.Environ = (*scriptEnv)(nil)
+
+func newScriptEnv(pairs []string) *scriptEnv {
+	e := &scriptEnv{
+		base:      make(map[string]string),
+		assigned:  make(map[string][]*syntax.Word),
+		values:    make(map[string]string),
+		resolving: make(map[string]bool),
+	}
+	for _, kv := range pairs {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			e.base[k] = v
+		}
+	}
+	return e
+}
+
+func (e *scriptEnv) assign(name string, value *syntax.Word) {
+	e.assigned[name] = append(e.assigned[name], value)
+}
+
+func (e *scriptEnv) Get(name string) expand.Variable {
+	if v, ok := e.lookup(name); ok {
+		return expand.Variable{Set: true, Kind: expand.String, Str: v}
+	}
+	_, assigned := e.assigned[name]
+	// The expander reads IFS and PWD on its own, and "HOME user" for ~user
+	if !assigned && (name == "IFS" || name == "PWD" || strings.Contains(name, " ")) {
+		return expand.Variable{}
+	}
+	e.dynamic = true
+	return expand.Variable{}
+}
+
+func (e *scriptEnv) lookup(name string) (string, bool) {
+	words, assigned := e.assigned[name]
+	if !assigned {
+		v, ok := e.base[name]
+		return v, ok
+	}
+	if v, ok := e.values[name]; ok {
+		return v, true
+	}
+	if len(words) != 1 || words[0] == nil || e.resolving[name] {
+		return "", false
+	}
+
+	e.resolving[name] = true
+	outer := e.dynamic
+	e.dynamic = false
+	v, err := expand.Literal(expandConfig(e), words[0])
+	known := err == nil && !e.dynamic
+	e.dynamic = outer
+	delete(e.resolving, name)
+
+	if !known {
+		e.assigned[name] = []*syntax.Word{nil}
+		return "", false
+	}
+	e.values[name] = v
+	return v, true
+}
+
+func (e *scriptEnv) Each(f func(name string, vr expand.Variable) bool) {
+	for name, v := range e.base {
+		if _, assigned := e.assigned[name]; !assigned && !f(name, expand.Variable{Set: true, Exported: true, Kind: expand.String, Str: v}) {
+			return
+		}
+	}
+	for name, v := range e.values {
+		if !f(name, expand.Variable{Set: true, Kind: expand.String, Str: v}) {
+			return
+		}
+	}
+}
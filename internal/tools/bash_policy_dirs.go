@@ -0,0 +1,434 @@
+package tools
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// maxWorkDirs bounds the directories tracked for one command; past it the
+// working directory counts as only known at run time.
+const maxWorkDirs = 32
+
+// workDirs is the set of directories a command may run in. Directories are
+// absolute when the project directory is known, and relative to it
+// otherwise.
+type workDirs struct {
+	dirs    []string
+	unknown bool // A cd went somewhere only known at run time
+}
+
+// startDirs returns the directories a script starts in: the project
+// directory, or "." when it is not known.
+func startDirs(root string) workDirs {
+	if root == "" {
+		return workDirs{dirs: []string{"."}}
+	}
+	return workDirs{dirs: []string{root}}
+}
+
+func (w workDirs) union(o workDirs) workDirs {
+	if w.unknown || o.unknown {
+		return workDirs{unknown: true}
+	}
+	u := workDirs{dirs: slices.Clone(w.dirs)}
+	for _, d := range o.dirs {
+		if !slices.Contains(u.dirs, d) {
+			u.dirs = append(u.dirs, d)
+		}
+	}
+	if len(u.dirs) > maxWorkDirs {
+		return workDirs{unknown: true}
+	}
+	return u
+}
+
+// cd returns the directories after changing to target from each of them.
+func (w workDirs) cd(target string) workDirs {
+	if w.unknown {
+		return w
+	}
+	var out workDirs
+	for _, d := range w.dirs {
+		out = out.union(workDirs{dirs: []string{joinPath(d, target)}})
+	}
+	return out
+}
+
+func joinPath(dir, p string) string {
+	if filepath.IsAbs(p) {
+		return filepath.Clean(p)
+	}
+	return filepath.Join(dir, p)
+}
+
+// projectPath returns an absolute path inside the project relative to the
+// project directory, and any other path as it is.
+func (c *scriptCheck) projectPath(p string) string {
+	if c.root == "" || !filepath.IsAbs(p) {
+		return p
+	}
+	rel, err := filepath.Rel(c.root, p)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return p
+	}
+	return rel
+}
+
+// resolvePaths resolves the path operands of rm, chmod, chown and find
+// against the directories the command may run in, so the built-in rules
+// see the paths it would touch: ./../../ becomes ../.., and * run from /
+// becomes /*. An operand resolves once for each possible directory, and a
+// relative one is only known at run time when the directory is.
+func (c *scriptCheck) resolvePaths(name string, args []shellArg, dirs workDirs) []shellArg {
+	skip := 0 // Operands before the paths, such as the mode of chmod
+	switch name {
+	case "rm":
+	case "chmod", "chown":
+		skip = 1
+	case "find":
+		return c.resolveFindPaths(args, dirs)
+	default:
+		return args
+	}
+	var out []shellArg
+	flags := true
+	for _, a := range args {
+		switch {
+		case a.dynamic:
+		case flags && a.value == "--":
+			flags = false
+		case flags && len(a.value) > 1 && a.value[0] == '-':
+		case skip > 0:
+			skip--
+		default:
+			out = append(out, c.resolvePath(a.value, dirs)...)
+			continue
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+// resolveFindPaths resolves the starting points of find, which default to
+// the working directory.
+func (c *scriptCheck) resolveFindPaths(args []shellArg, dirs workDirs) []shellArg {
+	i := 0
+	for i < len(args) && !args[i].dynamic {
+		v := args[i].value
+		if v == "-D" {
+			i++
+		} else if v != "-H" && v != "-L" && v != "-P" && !strings.HasPrefix(v, "-O") {
+			break
+		}
+		i++
+	}
+	out := slices.Clone(args[:min(i, len(args))])
+	start := len(out)
+	for ; i < len(args); i++ {
+		a := args[i]
+		if a.dynamic || a.value == "(" || a.value == "!" || strings.HasPrefix(a.value, "-") {
+			break
+		}
+		out = append(out, c.resolvePath(a.value, dirs)...)
+	}
+	if len(out) == start {
+		out = append(out, c.resolvePath(".", dirs)...)
+	}
+	return append(out, args[i:]...)
+}
+
+func (c *scriptCheck) resolvePath(p string, dirs workDirs) []shellArg {
+	if strings.HasPrefix(p, "~") {
+		return []shellArg{{value: p}}
+	}
+	if filepath.IsAbs(p) {
+		return []shellArg{{value: c.projectPath(filepath.Clean(p))}}
+	}
+	if dirs.unknown {
+		return []shellArg{{dynamic: true}}
+	}
+	var out []shellArg
+	for _, d := range dirs.dirs {
+		out = append(out, shellArg{value: c.projectPath(joinPath(d, p))})
+	}
+	return out
+}
+
+// dirFlow works out the directories each command in a script may run in by
+// following cd through sequences, && and || lists, conditionals and loops.
+// A cd that may have failed leaves both directories possible. Subshells,
+// pipelines and background commands keep their own cd to themselves, and
+// function bodies may run in any directory the script reaches.
+type dirFlow struct {
+	check      *scriptCheck
+	at         map[*syntax.CallExpr]workDirs
+	all        workDirs // Every directory a command may run in
+	funcs      []*syntax.FuncDecl
+	chdirFuncs map[string]bool // Functions that change directory
+}
+
+// flowDirs returns the directories each command in file may run in.
+func (c *scriptCheck) flowDirs(file *syntax.File, start workDirs) map[*syntax.CallExpr]workDirs {
+	f := &dirFlow{
+		check:      c,
+		at:         make(map[*syntax.CallExpr]workDirs),
+		all:        start,
+		chdirFuncs: c.chdirFuncs(file),
+	}
+	f.stmts(file.Stmts, start)
+	for {
+		seen := f.all
+		for i := 0; i < len(f.funcs); i++ {
+			f.stmt(f.funcs[i].Body, f.all)
+		}
+		if f.all.unknown || len(f.all.dirs) == len(seen.dirs) {
+			return f.at
+		}
+	}
+}
+
+// chdirFuncs returns the functions that change directory, directly or
+// through another function.
+func (c *scriptCheck) chdirFuncs(file *syntax.File) map[string]bool {
+	var decls []*syntax.FuncDecl
+	syntax.Walk(file, func(node syntax.Node) bool {
+		if fd, ok := node.(*syntax.FuncDecl); ok {
+			decls = append(decls, fd)
+		}
+		return true
+	})
+	marked := make(map[string]bool)
+	for changed := true; changed; {
+		changed = false
+		for _, fd := range decls {
+			if !marked[fd.Name.Value] && c.changesDir(fd.Body, marked) {
+				marked[fd.Name.Value] = true
+				changed = true
+			}
+		}
+	}
+	return marked
+}
+
+// changesDir reports whether node calls cd, pushd, popd or one of funcs.
+func (c *scriptCheck) changesDir(node syntax.Node, funcs map[string]bool) bool {
+	found := false
+	syntax.Walk(node, func(node syntax.Node) bool {
+		if call, ok := node.(*syntax.CallExpr); ok && len(call.Args) > 0 {
+			name, _ := c.literal(call.Args[0])
+			found = found || isChdir(name) || funcs[name]
+		}
+		return !found
+	})
+	return found
+}
+
+func isChdir(name string) bool {
+	return name == "cd" || name == "pushd" || name == "popd"
+}
+
+func (f *dirFlow) stmts(list []*syntax.Stmt, in workDirs) (ok, fail workDirs) {
+	ok, fail = in, in
+	cur := in
+	for _, s := range list {
+		ok, fail = f.stmt(s, cur)
+		cur = ok.union(fail)
+	}
+	return ok, fail
+}
+
+// stmt returns the directories after a statement succeeds and after it
+// fails.
+func (f *dirFlow) stmt(s *syntax.Stmt, in workDirs) (ok, fail workDirs) {
+	for _, r := range s.Redirs {
+		f.substs(r, in)
+	}
+	ok, fail = f.cmd(s.Cmd, in)
+	if s.Background || s.Coprocess {
+		return in, in
+	}
+	if s.Negated {
+		return fail, ok
+	}
+	return ok, fail
+}
+
+func (f *dirFlow) cmd(cmd syntax.Command, in workDirs) (ok, fail workDirs) {
+	switch n := cmd.(type) {
+	case *syntax.CallExpr:
+		f.substs(n, in)
+		f.record(n, in)
+		if out, changed := f.chdir(n, in); changed {
+			return out, in
+		}
+	case *syntax.BinaryCmd:
+		switch n.Op {
+		case syntax.AndStmt:
+			xOK, xFail := f.stmt(n.X, in)
+			yOK, yFail := f.stmt(n.Y, xOK)
+			return yOK, xFail.union(yFail)
+		case syntax.OrStmt:
+			xOK, xFail := f.stmt(n.X, in)
+			yOK, yFail := f.stmt(n.Y, xFail)
+			return xOK.union(yOK), yFail
+		default:
+			// Each side of a pipe runs in a subshell
+			f.stmt(n.X, in)
+			f.stmt(n.Y, in)
+		}
+	case *syntax.Block:
+		return f.stmts(n.Stmts, in)
+	case *syntax.Subshell:
+		f.stmts(n.Stmts, in)
+	case *syntax.IfClause:
+		condOK, condFail := f.stmts(n.Cond, in)
+		ok, fail = f.stmts(n.Then, condOK)
+		if n.Else == nil {
+			return ok.union(condFail), fail.union(condFail)
+		}
+		elseOK, elseFail := f.cmd(n.Else, condFail)
+		return ok.union(elseOK), fail.union(elseFail)
+	case *syntax.WhileClause:
+		out := f.loop(in, func(cur workDirs) workDirs {
+			condOK, condFail := f.stmts(n.Cond, cur)
+			if n.Until {
+				condOK, condFail = condFail, condOK
+			}
+			bodyOK, bodyFail := f.stmts(n.Do, condOK)
+			return condFail.union(bodyOK).union(bodyFail)
+		})
+		return out, out
+	case *syntax.ForClause:
+		f.substs(n.Loop, in)
+		out := f.loop(in, func(cur workDirs) workDirs {
+			bodyOK, bodyFail := f.stmts(n.Do, cur)
+			return bodyOK.union(bodyFail)
+		})
+		return out, out
+	case *syntax.CaseClause:
+		f.substs(n.Word, in)
+		ok, fail = in, in
+		for _, item := range n.Items {
+			for _, p := range item.Patterns {
+				f.substs(p, in)
+			}
+			itemOK, itemFail := f.stmts(item.Stmts, in)
+			ok, fail = ok.union(itemOK), fail.union(itemFail)
+		}
+		return ok, fail
+	case *syntax.FuncDecl:
+		f.funcs = append(f.funcs, n)
+	case *syntax.TimeClause:
+		if n.Stmt != nil {
+			return f.stmt(n.Stmt, in)
+		}
+	case *syntax.CoprocClause:
+		f.stmt(n.Stmt, in)
+	case nil:
+	default:
+		// Arithmetic, tests and declarations only run substitutions
+		f.substs(n, in)
+	}
+	return in, in
+}
+
+// loop runs a loop body until the directories it may end in stop growing.
+func (f *dirFlow) loop(in workDirs, body func(workDirs) workDirs) workDirs {
+	cur := in
+	for {
+		next := cur.union(body(cur))
+		if next.unknown || len(next.dirs) == len(cur.dirs) {
+			return next
+		}
+		cur = next
+	}
+}
+
+// substs follows the command and process substitutions in node, which run
+// in subshells.
+func (f *dirFlow) substs(node syntax.Node, in workDirs) {
+	if node == nil {
+		return
+	}
+	syntax.Walk(node, func(node syntax.Node) bool {
+		switch n := node.(type) {
+		case *syntax.CmdSubst:
+			f.stmts(n.Stmts, in)
+			return false
+		case *syntax.ProcSubst:
+			f.stmts(n.Stmts, in)
+			return false
+		}
+		return true
+	})
+}
+
+func (f *dirFlow) record(call *syntax.CallExpr, in workDirs) {
+	if prev, ok := f.at[call]; ok {
+		in = prev.union(in)
+	}
+	f.at[call] = in
+	f.all = f.all.union(in)
+}
+
+// chdir returns the directories after a command that changes directory,
+// and false for any other command.
+func (f *dirFlow) chdir(call *syntax.CallExpr, in workDirs) (workDirs, bool) {
+	var args []shellArg
+	for _, w := range call.Args {
+		args = append(args, f.check.fields(w)...)
+	}
+	for len(args) > 0 && !args[0].dynamic && (args[0].value == "builtin" || args[0].value == "command") {
+		args = args[1:]
+	}
+	if len(args) == 0 || args[0].dynamic {
+		return in, false
+	}
+	unknown := workDirs{unknown: true}
+	name, rest := args[0].value, args[1:]
+	switch {
+	case f.chdirFuncs[name] || name == "popd":
+		return unknown, true
+	case name == "eval":
+		return f.evalDirs(rest, in)
+	case name != "cd" && name != "pushd":
+		return in, false
+	}
+	for len(rest) > 0 && !rest[0].dynamic && len(rest[0].value) > 1 && rest[0].value[0] == '-' {
+		done := rest[0].value == "--"
+		rest = rest[1:]
+		if done {
+			break
+		}
+	}
+	if len(rest) == 0 {
+		if home, ok := f.check.env.lookup("HOME"); ok && name == "cd" {
+			return in.cd(home), true
+		}
+		return unknown, true
+	}
+	if rest[0].dynamic || rest[0].value == "-" || strings.HasPrefix(rest[0].value, "+") {
+		return unknown, true
+	}
+	return in.cd(rest[0].value), true
+}
+
+// evalDirs treats an eval that may change directory as going somewhere
+// only known at run time.
+func (f *dirFlow) evalDirs(args []shellArg, in workDirs) (workDirs, bool) {
+	parts := make([]string, len(args))
+	for i, a := range args {
+		if a.dynamic {
+			return workDirs{unknown: true}, true
+		}
+		parts[i] = a.value
+	}
+	file, err := syntax.NewParser(syntax.Variant(syntax.LangBash)).Parse(strings.NewReader(strings.Join(parts, " ")), "")
+	if err != nil || f.check.changesDir(file, f.chdirFuncs) {
+		return workDirs{unknown: true}, true
+	}
+	return in, false
+}
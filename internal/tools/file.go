@@ -10,7 +10,9 @@ import (
 )
 
 // ReadFileTool reads file contents
-type ReadFileTool struct{}
+type ReadFileTool struct {
+	Workspace *Workspace // Root for paths; nil means the project root
+}
 
 func (t *ReadFileTool) Name() string {
 	return "read_file"
@@ -67,13 +69,13 @@ func (t *ReadFileTool) Execute(ctx context.Context, input map[string]any) (strin
 	}
 
 	// Resolve path
-	absPath, err := filepath.Abs(path)
+	absPath, err := t.Workspace.ResolvePath(path)
 	if err != nil {
 		return "", fmt.Errorf("invalid path: %w", err)
 	}
 
 	// Validate path is within project directory
-	if err := ValidatePath(absPath); err != nil {
+	if err := t.Workspace.ValidatePath(absPath); err != nil {
 		return "", err
 	}
 
@@ -93,7 +95,7 @@ func (t *ReadFileTool) Execute(ctx context.Context, input map[string]any) (strin
 	// Check for signatures mode (Go files only)
 	if contextMode, ok := input["context"].(string); ok && contextMode == "signatures" {
 		if strings.HasSuffix(absPath, ".go") {
-			astTool := &ASTTool{}
+			astTool := &ASTTool{Workspace: t.Workspace}
 			return astTool.Execute(ctx, map[string]any{
 				"path":    absPath,
 				"include": []any{"functions", "types"},
@@ -103,7 +105,7 @@ func (t *ReadFileTool) Execute(ctx context.Context, input map[string]any) (strin
 	}
 
 	// Read file using openNoFollow to prevent symlink race attacks
-	f, err := openNoFollow(absPath, os.O_RDONLY, 0)
+	f, err := t.Workspace.openNoFollow(absPath, os.O_RDONLY, 0)
 	if err != nil {
 		return "", fmt.Errorf("failed to read file: %w", err)
 	}
@@ -197,7 +199,9 @@ func (t *ReadFileTool) chunkFile(content string, path string, maxTokens int) (st
 }
 
 // WriteFileTool writes content to a file
-type WriteFileTool struct{}
+type WriteFileTool struct {
+	Workspace *Workspace // Root for paths; nil means the project root
+}
 
 func (t *WriteFileTool) Name() string {
 	return "write_file"
@@ -240,13 +244,13 @@ func (t *WriteFileTool) Execute(ctx context.Context, input map[string]any) (stri
 	}
 
 	// Resolve path
-	absPath, err := filepath.Abs(path)
+	absPath, err := t.Workspace.ResolvePath(path)
 	if err != nil {
 		return "", fmt.Errorf("invalid path: %w", err)
 	}
 
 	// Validate path is within project directory (with symlink write protection)
-	if err := ValidatePathForWrite(absPath); err != nil {
+	if err := t.Workspace.ValidatePathForWrite(absPath); err != nil {
 		return "", err
 	}
 
@@ -257,7 +261,7 @@ func (t *WriteFileTool) Execute(ctx context.Context, input map[string]any) (stri
 	}
 
 	// Write file using openNoFollow to prevent symlink race attacks
-	f, err := openNoFollow(absPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	f, err := t.Workspace.openNoFollow(absPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
 	if err != nil {
 		// Fallback for new files where O_NOFOLLOW may fail on some systems
 		if os.IsNotExist(err) || os.IsPermission(err) {
@@ -269,7 +273,7 @@ func (t *WriteFileTool) Execute(ctx context.Context, input map[string]any) (stri
 			if evalErr != nil {
 				return "", fmt.Errorf("post-write validation failed: %w", evalErr)
 			}
-			root, rootErr := getProjectRoot()
+			root, rootErr := t.Workspace.Root()
 			if rootErr != nil {
 				return "", fmt.Errorf("post-write validation failed: %w", rootErr)
 			}
@@ -292,7 +296,9 @@ func (t *WriteFileTool) Execute(ctx context.Context, input map[string]any) (stri
 }
 
 // EditFileTool performs targeted edits on a file
-type EditFileTool struct{}
+type EditFileTool struct {
+	Workspace *Workspace // Root for paths; nil means the project root
+}
 
 func (t *EditFileTool) Name() string {
 	return "edit_file"
@@ -344,13 +350,13 @@ func (t *EditFileTool) Execute(ctx context.Context, input map[string]any) (strin
 	}
 
 	// Resolve path
-	absPath, err := filepath.Abs(path)
+	absPath, err := t.Workspace.ResolvePath(path)
 	if err != nil {
 		return "", fmt.Errorf("invalid path: %w", err)
 	}
 
 	// Validate path is within project directory (with symlink write protection)
-	if err := ValidatePathForWrite(absPath); err != nil {
+	if err := t.Workspace.ValidatePathForWrite(absPath); err != nil {
 		return "", err
 	}
 
@@ -383,7 +389,7 @@ func (t *EditFileTool) Execute(ctx context.Context, input map[string]any) (strin
 	newContent := strings.Replace(oldContent, oldText, newText, 1)
 
 	// Write back using openNoFollow to prevent symlink race attacks
-	wf, err := openNoFollow(absPath, os.O_WRONLY|os.O_TRUNC, 0644)
+	wf, err := t.Workspace.openNoFollow(absPath, os.O_WRONLY|os.O_TRUNC, 0644)
 	if err != nil {
 		return "", fmt.Errorf("failed to open file for writing: %w", err)
 	}
@@ -402,7 +408,9 @@ func (t *EditFileTool) Execute(ctx context.Context, input map[string]any) (strin
 }
 
 // ListFilesTool lists files in a directory
-type ListFilesTool struct{}
+type ListFilesTool struct {
+	Workspace *Workspace // Root for paths; nil means the project root
+}
 
 func (t *ListFilesTool) Name() string {
 	return "list_files"
@@ -455,13 +463,13 @@ func (t *ListFilesTool) Execute(ctx context.Context, input map[string]any) (stri
 	}
 
 	// Resolve path
-	absPath, err := filepath.Abs(path)
+	absPath, err := t.Workspace.ResolvePath(path)
 	if err != nil {
 		return "", fmt.Errorf("invalid path: %w", err)
 	}
 
 	// Validate path is within project directory
-	if err := ValidatePath(absPath); err != nil {
+	if err := t.Workspace.ValidatePath(absPath); err != nil {
 		return "", err
 	}
 
@@ -36,6 +36,62 @@ func InitProjectRoot() error {
 	return initErr
 }
 
+// Workspace is the directory a registry's tools resolve paths against and
+// keep file access within. It is the project root unless pointed at another
+// directory, e.g. while a task runs in an isolated git worktree. Each
+// registry has its own, so one session's worktree does not move the tools
+// of other sessions. A nil *Workspace is always the project root.
+type Workspace struct {
+	mu  sync.RWMutex
+	dir string // Replaces the project root when set
+}
+
+// Set points the workspace at dir. An empty dir restores the project root.
+func (w *Workspace) Set(dir string) error {
+	resolved := ""
+	if dir != "" {
+		var err error
+		if resolved, err = filepath.EvalSymlinks(dir); err != nil {
+			return fmt.Errorf("failed to resolve workspace root: %w", err)
+		}
+	}
+	w.mu.Lock()
+	w.dir = resolved
+	w.mu.Unlock()
+	return nil
+}
+
+// Dir returns the directory that replaces the project root, or "" if tools
+// operate on the project root. Subprocess-based tools use it as their
+// working directory.
+func (w *Workspace) Dir() string {
+	if w == nil {
+		return ""
+	}
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.dir
+}
+
+// Root returns the workspace directory if set, otherwise the cached project
+// root, initializing it lazily if needed.
+func (w *Workspace) Root() (string, error) {
+	if dir := w.Dir(); dir != "" {
+		return dir, nil
+	}
+	return getProjectRoot()
+}
+
+// ResolvePath makes path absolute. Relative paths are joined to the
+// workspace directory if one is set, and to the working directory otherwise.
+func (w *Workspace) ResolvePath(path string) (string, error) {
+	dir := w.Dir()
+	if dir == "" || filepath.IsAbs(path) {
+		return filepath.Abs(path)
+	}
+	return filepath.Join(dir, path), nil
+}
+
 // getProjectRoot returns the cached project root, initializing lazily if needed.
 func getProjectRoot() (string, error) {
 	if projectRoot != "" {
@@ -52,7 +108,13 @@ func getProjectRoot() (string, error) {
 // It resolves each existing path component to prevent symlink-based traversal and
 // TOCTOU races. For new files (write operations), it validates the parent directory.
 func ValidatePath(absPath string) error {
-	root, err := getProjectRoot()
+	return (*Workspace)(nil).ValidatePath(absPath)
+}
+
+// ValidatePath is like the package-level ValidatePath, checking against the
+// workspace root.
+func (w *Workspace) ValidatePath(absPath string) error {
+	root, err := w.Root()
 	if err != nil {
 		return err
 	}
@@ -77,8 +139,14 @@ func ValidatePath(absPath string) error {
 // ValidatePathForWrite is like ValidatePath but additionally checks that
 // the target is not a symlink (to prevent symlink swap attacks on writes).
 func ValidatePathForWrite(absPath string) error {
+	return (*Workspace)(nil).ValidatePathForWrite(absPath)
+}
+
+// ValidatePathForWrite is like the package-level ValidatePathForWrite,
+// checking against the workspace root.
+func (w *Workspace) ValidatePathForWrite(absPath string) error {
 	// First do the standard validation
-	if err := ValidatePath(absPath); err != nil {
+	if err := w.ValidatePath(absPath); err != nil {
 		return err
 	}
 
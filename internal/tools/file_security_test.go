@@ -1,8 +1,10 @@
 package tools
 
 import (
+	"context"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
 )
@@ -236,3 +238,67 @@ func TestResolveExistingPath(t *testing.T) {
 func syncOnce() sync.Once {
 	return sync.Once{}
 }
+
+func TestRegistrySetWorkspace_RedirectsTools(t *testing.T) {
+	project := t.TempDir()
+	chdirTemp(t, project)
+	workspace, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewEmptyRegistry()
+	r.Register(&WriteFileTool{Workspace: r.Workspace()})
+	r.Register(&BashTool{ProjectDir: project})
+	lsp := NewLSPTool(project, nil)
+	r.Register(lsp)
+	lspRoot := func() string {
+		m, _ := lsp.getManager()
+		return m.Root()
+	}
+	other := NewEmptyRegistry()
+	other.Register(&WriteFileTool{Workspace: other.Workspace()})
+	if err := r.SetWorkspace(workspace); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = r.SetWorkspace("") })
+
+	ctx := context.Background()
+	if _, err := r.Execute(ctx, "write_file", map[string]any{"path": "out.txt", "content": "x"}); err != nil {
+		t.Fatalf("relative write failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(workspace, "out.txt")); err != nil {
+		t.Errorf("expected write to land in the workspace: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(project, "out.txt")); !os.IsNotExist(err) {
+		t.Error("write leaked into the project directory")
+	}
+	if _, err := r.Execute(ctx, "write_file", map[string]any{"path": filepath.Join(project, "leak.txt"), "content": "x"}); err == nil {
+		t.Error("expected writes to the project directory to be denied while a workspace is active")
+	}
+
+	// Another registry, e.g. another session's, stays on the project
+	if _, err := other.Execute(ctx, "write_file", map[string]any{"path": "other.txt", "content": "x"}); err != nil {
+		t.Fatalf("write from another registry failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(project, "other.txt")); err != nil {
+		t.Errorf("expected another registry's write to land in the project: %v", err)
+	}
+	out, err := r.Execute(ctx, "bash", map[string]any{"command": "pwd"})
+	if err != nil || strings.TrimSpace(out) != workspace {
+		t.Errorf("expected bash to run in the workspace, got %q, %v", out, err)
+	}
+	if lspRoot() != workspace {
+		t.Errorf("expected the language servers rooted at the workspace, got %s", lspRoot())
+	}
+
+	if err := r.SetWorkspace(""); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := r.Workspace().ResolvePath("out.txt"); got != filepath.Join(project, "out.txt") {
+		t.Errorf("expected paths to resolve against the project again, got %s", got)
+	}
+	if root, _ := getProjectRoot(); lspRoot() != root {
+		t.Errorf("expected the language servers back on the project, got %s", lspRoot())
+	}
+}
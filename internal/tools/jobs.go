@@ -0,0 +1,593 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxJobOutput caps the output kept for each background job; the
+	// oldest output is dropped past it
+	maxJobOutput = 1 << 20
+	// maxJobOutputRead caps the output returned by one job_output call
+	maxJobOutputRead = 50000
+	// maxRunningJobs caps the background jobs running at once
+	maxRunningJobs = 8
+	// maxFinishedJobs caps the finished jobs kept for job_status; the
+	// oldest are forgotten first
+	maxFinishedJobs = 20
+	// jobKillGrace is how long a job has to exit after SIGTERM before it
+	// is killed outright
+	jobKillGrace = 3 * time.Second
+)
+
+// JobState is the state of a background job.
+type JobState string
+
+const (
+	JobRunning JobState = "running"
+	JobExited  JobState = "exited"
+	JobKilled  JobState = "killed"
+)
+
+// JobInfo describes a background job.
+type JobInfo struct {
+	ID         int
+	Command    string
+	State      JobState
+	ExitCode   int    // Valid once the job has exited
+	Limit      string // Sandbox limit that stopped the job, if any
+	StartedAt  time.Time
+	FinishedAt time.Time
+	OutputSize int64 // Bytes of output written so far, including dropped ones
+}
+
+// Duration returns how long the job has run, or ran.
+func (j JobInfo) Duration() time.Duration {
+	if j.FinishedAt.IsZero() {
+		return time.Since(j.StartedAt)
+	}
+	return j.FinishedAt.Sub(j.StartedAt)
+}
+
+// JobManager runs shell commands in the background for the bash_background
+// and job_* tools. Jobs run like bash tool commands: through its policy and
+// sandbox, in its working directory.
+type JobManager struct {
+	bash *BashTool
+
+	mu       sync.Mutex
+	jobs     map[int]*job
+	nextID   int
+	closed   bool
+	listener func([]JobInfo)
+}
+
+type job struct {
+	info   JobInfo
+	cmd    *exec.Cmd
+	output *jobOutput
+	done   chan struct{}
+}
+
+// NewJobManager returns a job manager that runs commands like bash does.
+func NewJobManager(bash *BashTool) *JobManager {
+	return &JobManager{
+		bash:   bash,
+		jobs:   make(map[int]*job),
+		nextID: 1,
+	}
+}
+
+// SetListener registers a function called with every job whenever a job
+// starts or finishes. It is called outside the manager's lock.
+func (m *JobManager) SetListener(fn func([]JobInfo)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.listener = fn
+}
+
+// Start runs command in the background and returns the new job.
+func (m *JobManager) Start(command string) (JobInfo, error) {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return JobInfo{}, errors.New("job manager is closed")
+	}
+	if n := m.runningLocked(); n >= maxRunningJobs {
+		m.mu.Unlock()
+		return JobInfo{}, fmt.Errorf("%d jobs are already running; kill one with job_kill first", n)
+	}
+
+	cmd, err := m.bash.command(context.Background(), command)
+	if err != nil {
+		m.mu.Unlock()
+		return JobInfo{}, err
+	}
+	output := &jobOutput{}
+	cmd.Stdout = output
+	cmd.Stderr = output
+	// Don't wait on orphans that keep the output pipe open
+	cmd.WaitDelay = time.Second
+	setJobProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		m.mu.Unlock()
+		return JobInfo{}, fmt.Errorf("failed to start job: %w", err)
+	}
+
+	j := &job{
+		info: JobInfo{
+			ID:        m.nextID,
+			Command:   command,
+			State:     JobRunning,
+			StartedAt: time.Now(),
+		},
+		cmd:    cmd,
+		output: output,
+		done:   make(chan struct{}),
+	}
+	m.nextID++
+	m.jobs[j.info.ID] = j
+	m.pruneLocked()
+	info := j.info
+	m.mu.Unlock()
+
+	// Report the start before the wait goroutine can report the exit
+	m.notify()
+	go m.wait(j)
+	return info, nil
+}
+
+// wait records how a job ended once its process exits.
+func (m *JobManager) wait(j *job) {
+	err := j.cmd.Wait()
+
+	m.mu.Lock()
+	j.info.FinishedAt = time.Now()
+	if j.info.State == JobRunning {
+		j.info.State = JobExited
+	}
+	j.info.ExitCode = j.cmd.ProcessState.ExitCode()
+	if err != nil && j.info.State == JobExited {
+		if lr, ok := m.bash.Sandbox.(LimitReporter); ok {
+			j.info.Limit = lr.LimitHit(j.cmd.ProcessState, j.output.tail(4096))
+		}
+	}
+	close(j.done)
+	m.mu.Unlock()
+
+	m.notify()
+}
+
+// Output returns the job's output from offset on, at most max bytes of it,
+// and the offset to read from next. Output dropped to stay under the cap is
+// skipped; dropped reports how many bytes of it were asked for.
+func (m *JobManager) Output(id int, offset int64, max int) (info JobInfo, data string, next, dropped int64, err error) {
+	m.mu.Lock()
+	j, ok := m.jobs[id]
+	if !ok {
+		m.mu.Unlock()
+		return JobInfo{}, "", 0, 0, fmt.Errorf("no job with id %d", id)
+	}
+	info = m.infoLocked(j)
+	m.mu.Unlock()
+
+	data, next, dropped = j.output.read(offset, max)
+	return info, data, next, dropped, nil
+}
+
+// Get returns the job with the given id.
+func (m *JobManager) Get(id int) (JobInfo, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return JobInfo{}, false
+	}
+	return m.infoLocked(j), true
+}
+
+// List returns every job the manager knows of, oldest first.
+func (m *JobManager) List() []JobInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.listLocked()
+}
+
+// Kill stops a running job: SIGTERM to its process group, then SIGKILL if
+// it is still running after a grace period. It returns once the job exits.
+func (m *JobManager) Kill(id int) (JobInfo, error) {
+	m.mu.Lock()
+	j, ok := m.jobs[id]
+	if !ok {
+		m.mu.Unlock()
+		return JobInfo{}, fmt.Errorf("no job with id %d", id)
+	}
+	if j.info.State != JobRunning {
+		info := m.infoLocked(j)
+		m.mu.Unlock()
+		return info, nil
+	}
+	j.info.State = JobKilled
+	m.mu.Unlock()
+
+	m.stop(j)
+	info, _ := m.Get(id)
+	return info, nil
+}
+
+func (m *JobManager) stop(j *job) {
+	terminateJob(j.cmd)
+	select {
+	case <-j.done:
+	case <-time.After(jobKillGrace):
+		killJob(j.cmd)
+		<-j.done
+	}
+}
+
+// Close kills every running job. The manager starts no jobs afterwards and
+// stops calling its listener.
+func (m *JobManager) Close() error {
+	m.mu.Lock()
+	m.closed = true
+	m.listener = nil
+	var running []*job
+	for _, j := range m.jobs {
+		if j.info.State == JobRunning {
+			j.info.State = JobKilled
+			running = append(running, j)
+		}
+	}
+	m.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, j := range running {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.stop(j)
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+func (m *JobManager) notify() {
+	m.mu.Lock()
+	fn := m.listener
+	jobs := m.listLocked()
+	m.mu.Unlock()
+	if fn != nil {
+		fn(jobs)
+	}
+}
+
+func (m *JobManager) infoLocked(j *job) JobInfo {
+	info := j.info
+	info.OutputSize = j.output.size()
+	return info
+}
+
+func (m *JobManager) listLocked() []JobInfo {
+	jobs := make([]JobInfo, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		jobs = append(jobs, m.infoLocked(j))
+	}
+	sort.Slice(jobs, func(i, k int) bool { return jobs[i].ID < jobs[k].ID })
+	return jobs
+}
+
+func (m *JobManager) runningLocked() int {
+	n := 0
+	for _, j := range m.jobs {
+		if j.info.State == JobRunning {
+			n++
+		}
+	}
+	return n
+}
+
+// pruneLocked forgets the oldest finished jobs past maxFinishedJobs.
+func (m *JobManager) pruneLocked() {
+	var finished []int
+	for id, j := range m.jobs {
+		if !j.info.FinishedAt.IsZero() {
+			finished = append(finished, id)
+		}
+	}
+	if len(finished) <= maxFinishedJobs {
+		return
+	}
+	sort.Ints(finished)
+	for _, id := range finished[:len(finished)-maxFinishedJobs] {
+		delete(m.jobs, id)
+	}
+}
+
+// jobOutput holds the combined stdout and stderr of a job, keeping the last
+// maxJobOutput bytes. Offsets count every byte written, dropped or not.
+type jobOutput struct {
+	mu      sync.Mutex
+	buf     []byte
+	dropped int64
+}
+
+func (o *jobOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.buf = append(o.buf, p...)
+	if over := len(o.buf) - maxJobOutput; over > 0 {
+		o.buf = append(o.buf[:0], o.buf[over:]...)
+		o.dropped += int64(over)
+	}
+	return len(p), nil
+}
+
+func (o *jobOutput) read(offset int64, max int) (data string, next, dropped int64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	end := o.dropped + int64(len(o.buf))
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > end {
+		offset = end
+	}
+	if offset < o.dropped {
+		dropped = o.dropped - offset
+		offset = o.dropped
+	}
+	chunk := o.buf[offset-o.dropped:]
+	if max > 0 && len(chunk) > max {
+		chunk = chunk[:max]
+	}
+	return string(chunk), offset + int64(len(chunk)), dropped
+}
+
+func (o *jobOutput) size() int64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.dropped + int64(len(o.buf))
+}
+
+func (o *jobOutput) tail(n int) string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.buf) > n {
+		return string(o.buf[len(o.buf)-n:])
+	}
+	return string(o.buf)
+}
+
+// describeJob formats a job's id, state and command for tool results.
+func describeJob(info JobInfo) string {
+	var status string
+	switch info.State {
+	case JobRunning:
+		status = fmt.Sprintf("running for %s", info.Duration().Round(time.Second))
+	case JobKilled:
+		status = fmt.Sprintf("killed after %s", info.Duration().Round(time.Second))
+	default:
+		status = fmt.Sprintf("exited with code %d after %s", info.ExitCode, info.Duration().Round(time.Second))
+		if info.Limit != "" {
+			status += ", stopped by the sandbox: hit the " + info.Limit
+		}
+	}
+	return fmt.Sprintf("Job %d (%s): %s", info.ID, status, info.Command)
+}
+
+// BashBackgroundTool starts a shell command as a background job
+type BashBackgroundTool struct {
+	Jobs *JobManager
+}
+
+func (t *BashBackgroundTool) Name() string {
+	return "bash_background"
+}
+
+func (t *BashBackgroundTool) Description() string {
+	return "Start a bash command in the background and return its job id right away. Use for dev servers, watchers and long builds or benchmarks. Read its output with job_output, check it with job_status and stop it with job_kill."
+}
+
+func (t *BashBackgroundTool) InputSchema() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"command": map[string]any{
+				"type":        "string",
+				"description": "The bash command to run in the background.",
+			},
+		},
+		"required": []string{"command"},
+	}
+}
+
+func (t *BashBackgroundTool) Permission() PermissionLevel {
+	return PermissionExecute
+}
+
+func (t *BashBackgroundTool) Execute(ctx context.Context, input map[string]any) (string, error) {
+	command, ok := input["command"].(string)
+	if !ok || command == "" {
+		return "", fmt.Errorf("command is required")
+	}
+	info, err := t.Jobs.Start(command)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Started job %d: %s\nUse job_output with id %d to read its output.", info.ID, command, info.ID), nil
+}
+
+// Close kills the jobs that are still running.
+func (t *BashBackgroundTool) Close() error {
+	return t.Jobs.Close()
+}
+
+// JobOutputTool reads a background job's output incrementally
+type JobOutputTool struct {
+	Jobs *JobManager
+}
+
+func (t *JobOutputTool) Name() string {
+	return "job_output"
+}
+
+func (t *JobOutputTool) Description() string {
+	return "Read the combined stdout and stderr of a background job, starting at a byte offset. Pass the returned next offset on the following call to get only new output."
+}
+
+func (t *JobOutputTool) InputSchema() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"id": map[string]any{
+				"type":        "integer",
+				"description": "The job id from bash_background.",
+			},
+			"offset": map[string]any{
+				"type":        "integer",
+				"description": "Byte offset to read from (default: 0).",
+				"default":     0,
+			},
+		},
+		"required": []string{"id"},
+	}
+}
+
+func (t *JobOutputTool) Permission() PermissionLevel {
+	return PermissionRead
+}
+
+func (t *JobOutputTool) Execute(ctx context.Context, input map[string]any) (string, error) {
+	id, ok := input["id"].(float64)
+	if !ok {
+		return "", fmt.Errorf("id is required")
+	}
+	var offset int64
+	if o, ok := input["offset"].(float64); ok && o > 0 {
+		offset = int64(o)
+	}
+
+	info, data, next, dropped, err := t.Jobs.Output(int(id), offset, maxJobOutputRead)
+	if err != nil {
+		return "", err
+	}
+
+	var result strings.Builder
+	result.WriteString(describeJob(info))
+	result.WriteString("\n")
+	if dropped > 0 {
+		fmt.Fprintf(&result, "(%d earlier bytes were dropped to stay under the output cap)\n", dropped)
+	}
+	if data == "" {
+		result.WriteString("(no new output)\n")
+	} else {
+		result.WriteString(data)
+		if !strings.HasSuffix(data, "\n") {
+			result.WriteString("\n")
+		}
+	}
+	fmt.Fprintf(&result, "Next offset: %d", next)
+	if next < info.OutputSize {
+		fmt.Fprintf(&result, " (%d more bytes available)", info.OutputSize-next)
+	}
+	return result.String(), nil
+}
+
+// JobStatusTool reports the state of background jobs
+type JobStatusTool struct {
+	Jobs *JobManager
+}
+
+func (t *JobStatusTool) Name() string {
+	return "job_status"
+}
+
+func (t *JobStatusTool) Description() string {
+	return "Show whether background jobs are running or how they exited. Without an id, lists every job."
+}
+
+func (t *JobStatusTool) InputSchema() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"id": map[string]any{
+				"type":        "integer",
+				"description": "The job id (default: all jobs).",
+			},
+		},
+	}
+}
+
+func (t *JobStatusTool) Permission() PermissionLevel {
+	return PermissionRead
+}
+
+func (t *JobStatusTool) Execute(ctx context.Context, input map[string]any) (string, error) {
+	if id, ok := input["id"].(float64); ok {
+		info, ok := t.Jobs.Get(int(id))
+		if !ok {
+			return "", fmt.Errorf("no job with id %d", int(id))
+		}
+		return fmt.Sprintf("%s\nOutput: %d bytes", describeJob(info), info.OutputSize), nil
+	}
+
+	jobs := t.Jobs.List()
+	if len(jobs) == 0 {
+		return "No background jobs.", nil
+	}
+	lines := make([]string, len(jobs))
+	for i, info := range jobs {
+		lines[i] = describeJob(info)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// JobKillTool stops a background job
+type JobKillTool struct {
+	Jobs *JobManager
+}
+
+func (t *JobKillTool) Name() string {
+	return "job_kill"
+}
+
+func (t *JobKillTool) Description() string {
+	return "Stop a running background job and everything it started."
+}
+
+func (t *JobKillTool) InputSchema() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"id": map[string]any{
+				"type":        "integer",
+				"description": "The job id to stop.",
+			},
+		},
+		"required": []string{"id"},
+	}
+}
+
+func (t *JobKillTool) Permission() PermissionLevel {
+	return PermissionExecute
+}
+
+func (t *JobKillTool) Execute(ctx context.Context, input map[string]any) (string, error) {
+	id, ok := input["id"].(float64)
+	if !ok {
+		return "", fmt.Errorf("id is required")
+	}
+	info, err := t.Jobs.Kill(int(id))
+	if err != nil {
+		return "", err
+	}
+	return describeJob(info), nil
+}
@@ -0,0 +1,191 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitForJob polls until the job leaves the running state.
+func waitForJob(t *testing.T, m *JobManager, id int) JobInfo {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if info, ok := m.Get(id); ok && !info.FinishedAt.IsZero() {
+			return info
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("job %d did not finish", id)
+	return JobInfo{}
+}
+
+func TestJobManager_OutputAndStatus(t *testing.T) {
+	m := NewJobManager(&BashTool{})
+	defer func() { _ = m.Close() }()
+
+	var mu sync.Mutex
+	var updates []JobInfo
+	m.SetListener(func(jobs []JobInfo) {
+		mu.Lock()
+		defer mu.Unlock()
+		updates = append(updates, jobs...)
+	})
+
+	info, err := m.Start("echo one; echo two >&2; exit 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ID != 1 || info.State != JobRunning {
+		t.Errorf("unexpected new job %+v", info)
+	}
+
+	done := waitForJob(t, m, info.ID)
+	if done.State != JobExited || done.ExitCode != 3 {
+		t.Errorf("expected exit code 3, got %+v", done)
+	}
+
+	_, data, next, dropped, err := m.Output(info.ID, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data != "one\ntwo\n" || next != 8 || dropped != 0 {
+		t.Errorf("unexpected output %q next=%d dropped=%d", data, next, dropped)
+	}
+	if _, data, _, _, _ = m.Output(info.ID, next, 0); data != "" {
+		t.Errorf("expected no new output at the next offset, got %q", data)
+	}
+	if _, data, _, _, _ = m.Output(info.ID, 4, 0); data != "two\n" {
+		t.Errorf("expected output from offset 4, got %q", data)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(updates) != 2 || updates[0].State != JobRunning || updates[1].State != JobExited {
+		t.Errorf("expected a start and an exit update, got %+v", updates)
+	}
+
+	if _, _, _, _, err := m.Output(99, 0, 0); err == nil {
+		t.Error("expected an error for an unknown job")
+	}
+}
+
+func TestJobManager_Kill(t *testing.T) {
+	dir := t.TempDir()
+	m := NewJobManager(&BashTool{ProjectDir: dir})
+	defer func() { _ = m.Close() }()
+
+	// The child writes a file unless it is killed along with the job
+	info, err := m.Start("(sleep 1; touch leaked) & sleep 30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	killed, err := m.Kill(info.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if killed.State != JobKilled || killed.FinishedAt.IsZero() {
+		t.Errorf("expected a finished, killed job, got %+v", killed)
+	}
+
+	time.Sleep(1500 * time.Millisecond)
+	if _, err := os.Stat(filepath.Join(dir, "leaked")); err == nil {
+		t.Error("expected the job's child process to be killed too")
+	}
+}
+
+func TestJobManager_Close(t *testing.T) {
+	m := NewJobManager(&BashTool{})
+	info, err := m.Start("sleep 30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := m.Get(info.ID); got.State != JobKilled || got.FinishedAt.IsZero() {
+		t.Errorf("expected Close to kill the job, got %+v", got)
+	}
+	if _, err := m.Start("true"); err == nil {
+		t.Error("expected no new jobs after Close")
+	}
+}
+
+func TestJobManager_Policy(t *testing.T) {
+	m := NewJobManager(&BashTool{})
+	defer func() { _ = m.Close() }()
+	if _, err := m.Start("rm -rf /"); err == nil || !strings.Contains(err.Error(), "blocked") {
+		t.Errorf("expected the bash policy to block the job, got %v", err)
+	}
+	if jobs := m.List(); len(jobs) != 0 {
+		t.Errorf("expected no job for a blocked command, got %+v", jobs)
+	}
+}
+
+func TestJobOutput_Cap(t *testing.T) {
+	var o jobOutput
+	chunk := strings.Repeat("x", maxJobOutput/2)
+	for range 3 {
+		_, _ = o.Write([]byte(chunk))
+	}
+	_, _ = o.Write([]byte("end"))
+
+	if got, want := o.size(), int64(3*len(chunk)+3); got != want {
+		t.Errorf("expected size %d, got %d", want, got)
+	}
+	data, next, dropped := o.read(0, 10)
+	if dropped != int64(len(chunk)+3) || data != strings.Repeat("x", 10) || next != dropped+10 {
+		t.Errorf("unexpected read past the cap: %q next=%d dropped=%d", data, next, dropped)
+	}
+	if data, _, _ := o.read(o.size()-3, 0); data != "end" {
+		t.Errorf("expected the latest output to be kept, got %q", data)
+	}
+}
+
+func TestJobTools(t *testing.T) {
+	jobs := NewJobManager(&BashTool{})
+	defer func() { _ = jobs.Close() }()
+	ctx := context.Background()
+
+	result, err := (&BashBackgroundTool{Jobs: jobs}).Execute(ctx, map[string]any{"command": "echo ready; sleep 30"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(result, "Started job 1") {
+		t.Errorf("unexpected start result %q", result)
+	}
+
+	output := &JobOutputTool{Jobs: jobs}
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(result, "ready\n") && time.Now().Before(deadline) {
+		result, err = output.Execute(ctx, map[string]any{"id": float64(1)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !strings.Contains(result, "Job 1 (running") || !strings.Contains(result, "ready\nNext offset: 6") {
+		t.Errorf("unexpected output result %q", result)
+	}
+	result, _ = output.Execute(ctx, map[string]any{"id": float64(1), "offset": float64(6)})
+	if !strings.Contains(result, "(no new output)") {
+		t.Errorf("expected no new output, got %q", result)
+	}
+
+	result, err = (&JobStatusTool{Jobs: jobs}).Execute(ctx, map[string]any{})
+	if err != nil || !strings.Contains(result, "Job 1 (running for") {
+		t.Errorf("unexpected status %q, err %v", result, err)
+	}
+
+	result, err = (&JobKillTool{Jobs: jobs}).Execute(ctx, map[string]any{"id": float64(1)})
+	if err != nil || !strings.HasPrefix(result, "Job 1 (killed after") {
+		t.Errorf("unexpected kill result %q, err %v", result, err)
+	}
+
+	if _, err := (&JobKillTool{Jobs: jobs}).Execute(ctx, map[string]any{}); err == nil {
+		t.Error("expected an error without an id")
+	}
+}
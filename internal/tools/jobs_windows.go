@@ -0,0 +1,16 @@
+//go:build windows
+
+package tools
+
+import "os/exec"
+
+func setJobProcessGroup(cmd *exec.Cmd) {}
+
+// terminateJob kills the job outright; Windows has no SIGTERM.
+func terminateJob(cmd *exec.Cmd) {
+	_ = cmd.Process.Kill()
+}
+
+func killJob(cmd *exec.Cmd) {
+	_ = cmd.Process.Kill()
+}
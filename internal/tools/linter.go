@@ -12,7 +12,9 @@ import (
 )
 
 // LinterTool runs golangci-lint and parses the results
-type LinterTool struct{}
+type LinterTool struct {
+	Workspace *Workspace // Root for paths; nil means the project root
+}
 
 func (t *LinterTool) Name() string {
 	return "lint"
@@ -98,19 +100,19 @@ func (t *LinterTool) Execute(ctx context.Context, input map[string]any) (string,
 	}
 
 	// Resolve path
-	absPath, err := filepath.Abs(path)
+	absPath, err := t.Workspace.ResolvePath(path)
 	if err != nil {
 		return "", fmt.Errorf("invalid path: %w", err)
 	}
 
 	// Validate path is within project directory
-	if err := ValidatePath(absPath); err != nil {
+	if err := t.Workspace.ValidatePath(absPath); err != nil {
 		return "", err
 	}
 
 	// If fix mode is enabled, validate for write access
 	if fix {
-		if err := ValidatePathForWrite(absPath); err != nil {
+		if err := t.Workspace.ValidatePathForWrite(absPath); err != nil {
 			return "", err
 		}
 	}
@@ -137,6 +139,7 @@ func (t *LinterTool) Execute(ctx context.Context, input map[string]any) (string,
 	defer cancel()
 
 	cmd := exec.CommandContext(ctx, "golangci-lint", args...)
+	cmd.Dir = t.Workspace.Dir()
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
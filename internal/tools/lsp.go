@@ -1,25 +1,94 @@
 package tools
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os/exec"
+	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/abdul-hamid-achik/vecai/internal/config"
+	"github.com/abdul-hamid-achik/vecai/internal/lsp"
 )
 
-// LSPTool provides LSP functionality by shelling out to gopls
-type LSPTool struct{}
+// maxLSPResults caps list-style results (references, symbols, edits)
+const maxLSPResults = 50
+
+// lspDiagnosticsWait is how long the diagnostics action waits for the server to publish
+const lspDiagnosticsWait = 5 * time.Second
+
+// LSPTool provides code intelligence through long-lived language server
+// sessions, routed by file extension. Each server is started on first use
+// and kept running until Close.
+type LSPTool struct {
+	Workspace *Workspace // Root for paths; nil means the project root
+
+	mu       sync.Mutex // Guards manager and initErr after initOnce
+	manager  *lsp.Manager
+	initOnce sync.Once
+	initErr  error
+}
+
+// NewLSPTool creates an LSPTool whose sessions are rooted at projectDir.
+// servers is usually LSPServersFromConfig(cfg); nil means gopls only.
+func NewLSPTool(projectDir string, servers []lsp.ServerConfig) *LSPTool {
+	if len(servers) == 0 {
+		servers = []lsp.ServerConfig{lsp.GoplsServer}
+	}
+	return &LSPTool{manager: lsp.NewManager(projectDir, servers)}
+}
+
+// LSPServersFromConfig returns the configured language servers followed by
+// gopls, so a configured server can take over .go files. cfg may be nil.
+func LSPServersFromConfig(cfg *config.ToolsConfig) []lsp.ServerConfig {
+	var servers []lsp.ServerConfig
+	if cfg != nil {
+		names := make([]string, 0, len(cfg.LSP.Servers))
+		for name := range cfg.LSP.Servers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			sc := cfg.LSP.Servers[name]
+			if sc.Command == "" || len(sc.Extensions) == 0 {
+				continue
+			}
+			exts := make([]string, len(sc.Extensions))
+			for i, ext := range sc.Extensions {
+				if !strings.HasPrefix(ext, ".") {
+					ext = "." + ext
+				}
+				exts[i] = ext
+			}
+			servers = append(servers, lsp.ServerConfig{
+				Name:       name,
+				Command:    sc.Command,
+				Args:       sc.Args,
+				Extensions: exts,
+				LanguageID: sc.LanguageID,
+			})
+		}
+	}
+	return append(servers, lsp.GoplsServer)
+}
 
 func (t *LSPTool) Name() string {
 	return "lsp_query"
 }
 
 func (t *LSPTool) Description() string {
-	return "Query Go language server (gopls) for code intelligence: go to definition, find references, find implementations, and hover info. Requires gopls to be installed (go install golang.org/x/tools/gopls@latest)."
+	desc := "Query a language server for code intelligence: go to definition, find references, find implementations, hover info, document symbols, workspace symbol search, rename preview, call hierarchy and diagnostics. The server is chosen by file extension"
+	var langs []string
+	for _, server := range t.servers() {
+		langs = append(langs, fmt.Sprintf("%s (%s)", strings.Join(server.Extensions, " "), server.Command))
+	}
+	return desc + ": " + strings.Join(langs, ", ") + "."
 }
 
 func (t *LSPTool) InputSchema() map[string]any {
@@ -28,12 +97,12 @@ func (t *LSPTool) InputSchema() map[string]any {
 		"properties": map[string]any{
 			"action": map[string]any{
 				"type":        "string",
-				"description": "The LSP action to perform: 'definition', 'references', 'implementation', 'hover'.",
-				"enum":        []string{"definition", "references", "implementation", "hover"},
+				"description": "The LSP action to perform. Positional actions (definition, references, implementation, hover, rename, call_hierarchy) need path, line and column; document_symbols and diagnostics need path; workspace_symbols needs query and optionally path to pick the language.",
+				"enum":        []string{"definition", "references", "implementation", "hover", "document_symbols", "workspace_symbols", "rename", "call_hierarchy", "diagnostics"},
 			},
 			"path": map[string]any{
 				"type":        "string",
-				"description": "Path to the Go file.",
+				"description": "Path to the source file. Its extension selects the language server.",
 			},
 			"line": map[string]any{
 				"type":        "integer",
@@ -43,8 +112,21 @@ func (t *LSPTool) InputSchema() map[string]any {
 				"type":        "integer",
 				"description": "Column number (1-indexed, byte offset).",
 			},
+			"query": map[string]any{
+				"type":        "string",
+				"description": "Symbol name or fragment for workspace_symbols.",
+			},
+			"new_name": map[string]any{
+				"type":        "string",
+				"description": "New identifier for rename. The rename is only previewed, not applied.",
+			},
+			"direction": map[string]any{
+				"type":        "string",
+				"description": "For call_hierarchy: 'incoming' (callers, default) or 'outgoing' (callees).",
+				"enum":        []string{"incoming", "outgoing"},
+			},
 		},
-		"required": []string{"action", "path", "line", "column"},
+		"required": []string{"action"},
 	}
 }
 
@@ -52,203 +134,602 @@ func (t *LSPTool) Permission() PermissionLevel {
 	return PermissionRead
 }
 
-func (t *LSPTool) Execute(ctx context.Context, input map[string]any) (string, error) {
-	action, ok := input["action"].(string)
-	if !ok || action == "" {
-		return "", fmt.Errorf("action is required")
+// Close shuts down any language servers that were started.
+func (t *LSPTool) Close() error {
+	m, err := t.getManager()
+	if err != nil {
+		return nil
 	}
+	return m.Close()
+}
 
-	path, ok := input["path"].(string)
-	if !ok || path == "" {
-		return "", fmt.Errorf("path is required")
+// NotifyFileChanged keeps the language server's view in sync after a file is
+// written. Relative paths resolve against the workspace, like tool input.
+func (t *LSPTool) NotifyFileChanged(path string) {
+	m, err := t.getManager()
+	if err != nil {
+		return
+	}
+	if path, err = t.Workspace.ResolvePath(path); err == nil {
+		m.FileChanged(path)
+	}
+}
+
+// getManager returns the session manager, rooting a zero-value LSPTool at the project root.
+func (t *LSPTool) getManager() (*lsp.Manager, error) {
+	t.initOnce.Do(func() {
+		if t.manager != nil {
+			return
+		}
+		root, err := getProjectRoot()
+		if err != nil {
+			t.initErr = err
+			return
+		}
+		t.manager = lsp.NewManager(root, []lsp.ServerConfig{lsp.GoplsServer})
+	})
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.manager, t.initErr
+}
+
+// SetRoot roots the language servers at dir, so queries see the same files
+// as the other tools. Running servers are shut down and start again in dir
+// on first use.
+func (t *LSPTool) SetRoot(dir string) {
+	old, err := t.getManager()
+	if err == nil && old.Root() == dir {
+		return
+	}
+	servers := t.servers()
+	t.mu.Lock()
+	t.manager, t.initErr = lsp.NewManager(dir, servers), nil
+	t.mu.Unlock()
+	if old != nil {
+		_ = old.Close()
+	}
+}
+
+// servers lists the configured servers without starting anything.
+func (t *LSPTool) servers() []lsp.ServerConfig {
+	if m, err := t.getManager(); err == nil {
+		return m.Servers()
+	}
+	return []lsp.ServerConfig{lsp.GoplsServer}
+}
+
+// lspRequest is a validated lsp_query input.
+type lspRequest struct {
+	action    string
+	absPath   string
+	line      int // 1-indexed
+	column    int // 1-indexed byte offset
+	query     string
+	newName   string
+	direction string
+}
+
+func (t *LSPTool) Execute(ctx context.Context, input map[string]any) (string, error) {
+	req, err := parseLSPRequest(t.Workspace, input)
+	if err != nil {
+		return "", err
 	}
 
-	line, ok := input["line"].(float64)
-	if !ok || line < 1 {
-		return "", fmt.Errorf("line is required and must be positive")
+	manager, err := t.getManager()
+	if err != nil {
+		return "", err
 	}
 
-	column, ok := input["column"].(float64)
-	if !ok || column < 1 {
-		return "", fmt.Errorf("column is required and must be positive")
+	// Create context with timeout (the first call also pays for server startup)
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	if req.action == "workspace_symbols" && req.absPath == "" {
+		return t.workspaceSymbolsAll(ctx, manager, req.query)
 	}
 
-	// Resolve path
-	absPath, err := filepath.Abs(path)
+	server, err := manager.ServerFor(req.absPath)
 	if err != nil {
-		return "", fmt.Errorf("invalid path: %w", err)
+		return "", fmt.Errorf("%w; add one under tools.lsp.servers in the config", err)
+	}
+	session, err := manager.Session(ctx, server)
+	if err != nil {
+		return "", serverStartError(server, err)
 	}
 
-	// Check if gopls is available
-	if _, err := exec.LookPath("gopls"); err != nil {
-		return "", fmt.Errorf("gopls not found. Install with: go install golang.org/x/tools/gopls@latest")
+	if req.action == "workspace_symbols" {
+		return t.workspaceSymbols(ctx, req.query, session)
 	}
 
-	// Build position string for gopls
-	position := fmt.Sprintf("%s:%d:%d", absPath, int(line), int(column))
+	uri, err := session.SyncFile(req.absPath)
+	if err != nil {
+		return "", err
+	}
+	f := newLSPFormatter(t.Workspace)
 
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
-	defer cancel()
+	var pos lsp.TextDocumentPositionParams
+	if req.line > 0 {
+		lineText, err := f.line(req.absPath, req.line-1)
+		if err != nil {
+			return "", err
+		}
+		pos = lsp.TextDocumentPositionParams{
+			TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+			Position:     lsp.Position{Line: req.line - 1, Character: utf16Column(lineText, req.column-1)},
+		}
+	}
 
-	switch action {
+	switch req.action {
 	case "definition":
-		return t.definition(ctx, position)
+		return t.locations(ctx, session, f, "textDocument/definition", pos, "Definition", "No definition found.")
 	case "references":
-		return t.references(ctx, position)
+		params := map[string]any{
+			"textDocument": pos.TextDocument,
+			"position":     pos.Position,
+			"context":      map[string]any{"includeDeclaration": true},
+		}
+		return t.locations(ctx, session, f, "textDocument/references", params, "References", "No references found.")
 	case "implementation":
-		return t.implementation(ctx, position)
+		return t.locations(ctx, session, f, "textDocument/implementation", pos, "Implementations", "No implementations found.")
 	case "hover":
-		return t.hover(ctx, position)
+		return t.hover(ctx, session, pos)
+	case "document_symbols":
+		return t.documentSymbols(ctx, session, f, req.absPath, uri)
+	case "rename":
+		return t.renamePreview(ctx, session, f, pos, req.newName)
+	case "call_hierarchy":
+		return t.callHierarchy(ctx, session, f, pos, req.direction)
+	case "diagnostics":
+		return t.diagnostics(ctx, session, f, req.absPath, uri)
 	default:
-		return "", fmt.Errorf("unknown action: %s (valid: definition, references, implementation, hover)", action)
+		return "", fmt.Errorf("unknown action: %s", req.action)
 	}
 }
 
-func (t *LSPTool) definition(ctx context.Context, position string) (string, error) {
-	cmd := exec.CommandContext(ctx, "gopls", "definition", position)
+// parseLSPRequest validates input according to what each action needs,
+// resolving its path against ws.
+func parseLSPRequest(ws *Workspace, input map[string]any) (*lspRequest, error) {
+	action, ok := input["action"].(string)
+	if !ok || action == "" {
+		return nil, fmt.Errorf("action is required")
+	}
+	req := &lspRequest{action: action}
 
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	switch action {
+	case "definition", "references", "implementation", "hover", "rename", "call_hierarchy":
+		if err := req.parsePath(ws, input); err != nil {
+			return nil, err
+		}
+		line, ok := input["line"].(float64)
+		if !ok || line < 1 {
+			return nil, fmt.Errorf("line is required and must be positive")
+		}
+		column, ok := input["column"].(float64)
+		if !ok || column < 1 {
+			return nil, fmt.Errorf("column is required and must be positive")
+		}
+		req.line, req.column = int(line), int(column)
+	case "document_symbols", "diagnostics":
+		if err := req.parsePath(ws, input); err != nil {
+			return nil, err
+		}
+	case "workspace_symbols":
+		req.query, _ = input["query"].(string)
+		if req.query == "" {
+			return nil, fmt.Errorf("query is required for workspace_symbols")
+		}
+		if path, _ := input["path"].(string); path != "" {
+			if err := req.parsePath(ws, input); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown action: %s (valid: definition, references, implementation, hover, document_symbols, workspace_symbols, rename, call_hierarchy, diagnostics)", action)
+	}
 
-	if err := cmd.Run(); err != nil {
-		if stderr.Len() > 0 {
-			return "", fmt.Errorf("gopls definition failed: %s", strings.TrimSpace(stderr.String()))
+	if action == "rename" {
+		req.newName, _ = input["new_name"].(string)
+		if req.newName == "" {
+			return nil, fmt.Errorf("new_name is required for rename")
+		}
+	}
+	if action == "call_hierarchy" {
+		req.direction, _ = input["direction"].(string)
+		if req.direction == "" {
+			req.direction = "incoming"
+		}
+		if req.direction != "incoming" && req.direction != "outgoing" {
+			return nil, fmt.Errorf("direction must be 'incoming' or 'outgoing'")
 		}
-		return "", fmt.Errorf("gopls definition failed: %w", err)
 	}
+	return req, nil
+}
 
-	result := strings.TrimSpace(stdout.String())
-	if result == "" {
-		return "No definition found.", nil
+// serverStartError turns a missing executable into an install hint.
+func serverStartError(server lsp.ServerConfig, err error) error {
+	if !errors.Is(err, lsp.ErrServerNotFound) {
+		return err
+	}
+	if server.InstallHint != "" {
+		return fmt.Errorf("%s not found. Install with: %s", server.Command, server.InstallHint)
 	}
+	return fmt.Errorf("%s not found in PATH (configured for %s files under tools.lsp.servers)", server.Command, strings.Join(server.Extensions, ", "))
+}
 
-	return fmt.Sprintf("## Definition\n%s", result), nil
+func (r *lspRequest) parsePath(ws *Workspace, input map[string]any) error {
+	path, ok := input["path"].(string)
+	if !ok || path == "" {
+		return fmt.Errorf("path is required")
+	}
+	absPath, err := ws.ResolvePath(path)
+	if err != nil {
+		return fmt.Errorf("invalid path: %w", err)
+	}
+	if err := ws.ValidatePath(absPath); err != nil {
+		return err
+	}
+	r.absPath = absPath
+	return nil
 }
 
-func (t *LSPTool) references(ctx context.Context, position string) (string, error) {
-	cmd := exec.CommandContext(ctx, "gopls", "references", position)
+func (t *LSPTool) locations(ctx context.Context, s *lsp.Session, f *lspFormatter, method string, params any, title, empty string) (string, error) {
+	var raw json.RawMessage
+	if err := s.Call(ctx, method, params, &raw); err != nil {
+		return "", fmt.Errorf("%s %s failed: %w", s.Server().Command, strings.TrimPrefix(method, "textDocument/"), err)
+	}
+	locs := decodeLocations(raw)
+	if len(locs) == 0 {
+		return empty, nil
+	}
+
+	var sb strings.Builder
+	if len(locs) == 1 && title == "Definition" {
+		sb.WriteString("## Definition\n")
+	} else {
+		sb.WriteString(fmt.Sprintf("## %s (%d found)\n", title, len(locs)))
+	}
+	for i, loc := range locs {
+		if i >= maxLSPResults {
+			sb.WriteString(fmt.Sprintf("\n... and %d more", len(locs)-maxLSPResults))
+			break
+		}
+		sb.WriteString(fmt.Sprintf("  %s\n", f.location(loc.URI, loc.Range.Start)))
+	}
+	return sb.String(), nil
+}
 
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+// decodeLocations accepts the Location, Location[] and LocationLink[] result shapes.
+func decodeLocations(raw json.RawMessage) []lsp.Location {
+	type locationOrLink struct {
+		URI                  string     `json:"uri"`
+		Range                *lsp.Range `json:"range"`
+		TargetURI            string     `json:"targetUri"`
+		TargetSelectionRange *lsp.Range `json:"targetSelectionRange"`
+	}
+	var items []locationOrLink
+	if err := json.Unmarshal(raw, &items); err != nil {
+		var single locationOrLink
+		if err := json.Unmarshal(raw, &single); err != nil {
+			return nil
+		}
+		items = []locationOrLink{single}
+	}
 
-	if err := cmd.Run(); err != nil {
-		if stderr.Len() > 0 {
-			return "", fmt.Errorf("gopls references failed: %s", strings.TrimSpace(stderr.String()))
+	var locs []lsp.Location
+	for _, it := range items {
+		switch {
+		case it.TargetURI != "" && it.TargetSelectionRange != nil:
+			locs = append(locs, lsp.Location{URI: it.TargetURI, Range: *it.TargetSelectionRange})
+		case it.URI != "" && it.Range != nil:
+			locs = append(locs, lsp.Location{URI: it.URI, Range: *it.Range})
 		}
-		return "", fmt.Errorf("gopls references failed: %w", err)
 	}
+	return locs
+}
+
+func (t *LSPTool) hover(ctx context.Context, s *lsp.Session, pos lsp.TextDocumentPositionParams) (string, error) {
+	var h lsp.Hover
+	if err := s.Call(ctx, "textDocument/hover", pos, &h); err != nil {
+		return "", fmt.Errorf("%s hover failed: %w", s.Server().Command, err)
+	}
+	text := strings.TrimSpace(h.Text())
+	if text == "" {
+		return "No hover information available.", nil
+	}
+	return fmt.Sprintf("## Hover Info\n%s", text), nil
+}
 
-	result := strings.TrimSpace(stdout.String())
-	if result == "" {
-		return "No references found.", nil
+func (t *LSPTool) documentSymbols(ctx context.Context, s *lsp.Session, f *lspFormatter, absPath, uri string) (string, error) {
+	var symbols []lsp.DocumentSymbol
+	params := map[string]any{"textDocument": lsp.TextDocumentIdentifier{URI: uri}}
+	if err := s.Call(ctx, "textDocument/documentSymbol", params, &symbols); err != nil {
+		return "", fmt.Errorf("%s documentSymbol failed: %w", s.Server().Command, err)
+	}
+	if len(symbols) == 0 {
+		return "No symbols found.", nil
 	}
 
-	// Format output
-	lines := strings.Split(result, "\n")
 	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("## References (%d found)\n", len(lines)))
-	for i, line := range lines {
-		if i >= 50 { // Limit to 50 references
-			sb.WriteString(fmt.Sprintf("\n... and %d more references", len(lines)-50))
-			break
+	sb.WriteString(fmt.Sprintf("## Symbols in %s\n", f.relPath(absPath)))
+	var walk func(syms []lsp.DocumentSymbol, depth int)
+	walk = func(syms []lsp.DocumentSymbol, depth int) {
+		for _, sym := range syms {
+			start := sym.SelectionRange.Start
+			if sym.Location != nil {
+				start = sym.Location.Range.Start
+			}
+			detail := ""
+			if sym.Detail != "" {
+				detail = " " + sym.Detail
+			}
+			sb.WriteString(fmt.Sprintf("%s- %s %s%s (line %d)\n", strings.Repeat("  ", depth), sym.Kind, sym.Name, detail, start.Line+1))
+			walk(sym.Children, depth+1)
 		}
-		sb.WriteString(fmt.Sprintf("  %s\n", line))
 	}
-
+	walk(symbols, 0)
 	return sb.String(), nil
 }
 
-func (t *LSPTool) implementation(ctx context.Context, position string) (string, error) {
-	cmd := exec.CommandContext(ctx, "gopls", "implementation", position)
+// workspaceSymbolsAll searches every running server, starting the first
+// installed one when none is running yet.
+func (t *LSPTool) workspaceSymbolsAll(ctx context.Context, m *lsp.Manager, query string) (string, error) {
+	sessions := m.Running()
+	if len(sessions) == 0 {
+		var firstErr error
+		for _, server := range m.Servers() {
+			s, err := m.Session(ctx, server)
+			if err == nil {
+				sessions = append(sessions, s)
+				break
+			}
+			if firstErr == nil {
+				firstErr = serverStartError(server, err)
+			}
+		}
+		if len(sessions) == 0 {
+			return "", firstErr
+		}
+	}
+	return t.workspaceSymbols(ctx, query, sessions...)
+}
 
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+func (t *LSPTool) workspaceSymbols(ctx context.Context, query string, sessions ...*lsp.Session) (string, error) {
+	var symbols []lsp.SymbolInformation
+	var errs []error
+	for _, s := range sessions {
+		var found []lsp.SymbolInformation
+		if err := s.Call(ctx, "workspace/symbol", map[string]any{"query": query}, &found); err != nil {
+			errs = append(errs, fmt.Errorf("%s workspace/symbol failed: %w", s.Server().Command, err))
+			continue
+		}
+		symbols = append(symbols, found...)
+	}
+	if len(errs) == len(sessions) {
+		return "", errors.Join(errs...)
+	}
+	if len(symbols) == 0 {
+		return fmt.Sprintf("No symbols matching %q.", query), nil
+	}
 
-	if err := cmd.Run(); err != nil {
-		if stderr.Len() > 0 {
-			return "", fmt.Errorf("gopls implementation failed: %s", strings.TrimSpace(stderr.String()))
+	f := newLSPFormatter(t.Workspace)
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("## Workspace symbols matching %q (%d found)\n", query, len(symbols)))
+	for i, sym := range symbols {
+		if i >= maxLSPResults {
+			sb.WriteString(fmt.Sprintf("\n... and %d more", len(symbols)-maxLSPResults))
+			break
 		}
-		return "", fmt.Errorf("gopls implementation failed: %w", err)
+		name := sym.Name
+		if sym.ContainerName != "" {
+			name = sym.ContainerName + "." + sym.Name
+		}
+		sb.WriteString(fmt.Sprintf("  %s %s  %s\n", sym.Kind, name, f.location(sym.Location.URI, sym.Location.Range.Start)))
 	}
+	return sb.String(), nil
+}
 
-	result := strings.TrimSpace(stdout.String())
-	if result == "" {
-		return "No implementations found.", nil
+func (t *LSPTool) renamePreview(ctx context.Context, s *lsp.Session, f *lspFormatter, pos lsp.TextDocumentPositionParams, newName string) (string, error) {
+	var edit lsp.WorkspaceEdit
+	params := map[string]any{
+		"textDocument": pos.TextDocument,
+		"position":     pos.Position,
+		"newName":      newName,
+	}
+	if err := s.Call(ctx, "textDocument/rename", params, &edit); err != nil {
+		return "", fmt.Errorf("%s rename failed: %w", s.Server().Command, err)
 	}
 
-	// Format output
-	lines := strings.Split(result, "\n")
-	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("## Implementations (%d found)\n", len(lines)))
-	for _, line := range lines {
-		sb.WriteString(fmt.Sprintf("  %s\n", line))
+	byURI := edit.Edits()
+	if len(byURI) == 0 {
+		return "Rename would not change any files.", nil
 	}
+	uris := make([]string, 0, len(byURI))
+	total := 0
+	for uri, edits := range byURI {
+		uris = append(uris, uri)
+		total += len(edits)
+	}
+	sort.Strings(uris)
 
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("## Rename preview: %d edits in %d files (not applied)\n", total, len(uris)))
+	shown := 0
+	for _, uri := range uris {
+		edits := byURI[uri]
+		sort.Slice(edits, func(i, j int) bool {
+			if edits[i].Range.Start.Line != edits[j].Range.Start.Line {
+				return edits[i].Range.Start.Line < edits[j].Range.Start.Line
+			}
+			return edits[i].Range.Start.Character < edits[j].Range.Start.Character
+		})
+		sb.WriteString(fmt.Sprintf("\n%s\n", f.relPath(lsp.URIToPath(uri))))
+		for _, e := range edits {
+			if shown >= maxLSPResults {
+				sb.WriteString(fmt.Sprintf("\n... and %d more edits", total-shown))
+				return sb.String(), nil
+			}
+			before := strings.TrimSpace(f.lineOrEmpty(lsp.URIToPath(uri), e.Range.Start.Line))
+			sb.WriteString(fmt.Sprintf("  %d: %s  ->  %q\n", e.Range.Start.Line+1, before, e.NewText))
+			shown++
+		}
+	}
 	return sb.String(), nil
 }
 
-func (t *LSPTool) hover(ctx context.Context, position string) (string, error) {
-	// gopls hover outputs JSON when used programmatically
-	cmd := exec.CommandContext(ctx, "gopls", "hover", "-json", position)
-
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+func (t *LSPTool) callHierarchy(ctx context.Context, s *lsp.Session, f *lspFormatter, pos lsp.TextDocumentPositionParams, direction string) (string, error) {
+	var items []lsp.CallHierarchyItem
+	if err := s.Call(ctx, "textDocument/prepareCallHierarchy", pos, &items); err != nil {
+		return "", fmt.Errorf("%s prepareCallHierarchy failed: %w", s.Server().Command, err)
+	}
+	if len(items) == 0 {
+		return "No callable symbol at this position.", nil
+	}
+	item := items[0]
 
-	if err := cmd.Run(); err != nil {
-		// Try non-JSON mode as fallback
-		cmd2 := exec.CommandContext(ctx, "gopls", "hover", position)
-		var stdout2 bytes.Buffer
-		cmd2.Stdout = &stdout2
-		if err2 := cmd2.Run(); err2 == nil && stdout2.Len() > 0 {
-			return fmt.Sprintf("## Hover Info\n%s", strings.TrimSpace(stdout2.String())), nil
+	type entry struct {
+		item  lsp.CallHierarchyItem
+		sites []lsp.Range
+	}
+	var entries []entry
+	title := "Callers of"
+	if direction == "outgoing" {
+		title = "Calls made by"
+		var calls []lsp.CallHierarchyOutgoingCall
+		if err := s.Call(ctx, "callHierarchy/outgoingCalls", map[string]any{"item": item}, &calls); err != nil {
+			return "", fmt.Errorf("%s outgoingCalls failed: %w", s.Server().Command, err)
+		}
+		for _, c := range calls {
+			entries = append(entries, entry{item: c.To})
+		}
+	} else {
+		var calls []lsp.CallHierarchyIncomingCall
+		if err := s.Call(ctx, "callHierarchy/incomingCalls", map[string]any{"item": item}, &calls); err != nil {
+			return "", fmt.Errorf("%s incomingCalls failed: %w", s.Server().Command, err)
 		}
+		for _, c := range calls {
+			entries = append(entries, entry{item: c.From, sites: c.FromRanges})
+		}
+	}
 
-		if stderr.Len() > 0 {
-			return "", fmt.Errorf("gopls hover failed: %s", strings.TrimSpace(stderr.String()))
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("## %s %s (%d found)\n", title, item.Name, len(entries)))
+	if len(entries) == 0 {
+		sb.WriteString("  (none)\n")
+	}
+	for i, e := range entries {
+		if i >= maxLSPResults {
+			sb.WriteString(fmt.Sprintf("\n... and %d more", len(entries)-maxLSPResults))
+			break
 		}
-		return "", fmt.Errorf("gopls hover failed: %w", err)
+		at := e.item.SelectionRange.Start
+		if len(e.sites) > 0 {
+			at = e.sites[0].Start
+		}
+		sb.WriteString(fmt.Sprintf("  %s  %s\n", e.item.Name, f.location(e.item.URI, at)))
 	}
+	return sb.String(), nil
+}
 
-	// Try to parse JSON
-	var hoverResult struct {
-		Synopsis string `json:"synopsis"`
-		FullDoc  string `json:"fullDocumentation"`
-		Sig      string `json:"signature"`
-		LinkPath string `json:"linkPath"`
+func (t *LSPTool) diagnostics(ctx context.Context, s *lsp.Session, f *lspFormatter, absPath, uri string) (string, error) {
+	diags := s.Diagnostics(ctx, uri, lspDiagnosticsWait)
+	if len(diags) == 0 {
+		return fmt.Sprintf("No diagnostics for %s.", f.relPath(absPath)), nil
 	}
 
-	if err := json.Unmarshal(stdout.Bytes(), &hoverResult); err != nil {
-		// Fallback to raw output
-		result := strings.TrimSpace(stdout.String())
-		if result == "" {
-			return "No hover information available.", nil
+	sort.Slice(diags, func(i, j int) bool {
+		return diags[i].Range.Start.Line < diags[j].Range.Start.Line
+	})
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("## Diagnostics for %s (%d)\n", f.relPath(absPath), len(diags)))
+	for _, d := range diags {
+		source := ""
+		if d.Source != "" {
+			source = " [" + d.Source + "]"
 		}
-		return fmt.Sprintf("## Hover Info\n%s", result), nil
+		sb.WriteString(fmt.Sprintf("  %s %s%s: %s\n", f.location(uri, d.Range.Start), d.Severity, source, d.Message))
 	}
+	return sb.String(), nil
+}
 
-	var sb strings.Builder
-	sb.WriteString("## Hover Info\n")
+// lspFormatter renders LSP positions as project-relative path:line:col with
+// 1-indexed byte columns, matching the tool's input convention.
+type lspFormatter struct {
+	root  string
+	lines map[string][]string
+}
+
+func newLSPFormatter(ws *Workspace) *lspFormatter {
+	root, _ := ws.Root()
+	return &lspFormatter{root: root, lines: make(map[string][]string)}
+}
 
-	if hoverResult.Sig != "" {
-		sb.WriteString(fmt.Sprintf("**Signature:**\n```go\n%s\n```\n\n", hoverResult.Sig))
+func (f *lspFormatter) relPath(path string) string {
+	if f.root != "" {
+		if rel, err := filepath.Rel(f.root, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
 	}
+	return path
+}
 
-	if hoverResult.Synopsis != "" {
-		sb.WriteString(fmt.Sprintf("**Synopsis:** %s\n\n", hoverResult.Synopsis))
+func (f *lspFormatter) line(path string, idx int) (string, error) {
+	lines, ok := f.lines[path]
+	if !ok {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		lines = strings.Split(string(data), "\n")
+		f.lines[path] = lines
 	}
+	if idx < 0 || idx >= len(lines) {
+		return "", fmt.Errorf("line %d is out of range (file has %d lines)", idx+1, len(lines))
+	}
+	return lines[idx], nil
+}
 
-	if hoverResult.FullDoc != "" {
-		sb.WriteString(fmt.Sprintf("**Documentation:**\n%s\n", hoverResult.FullDoc))
+func (f *lspFormatter) lineOrEmpty(path string, idx int) string {
+	text, _ := f.line(path, idx)
+	return text
+}
+
+func (f *lspFormatter) location(uri string, pos lsp.Position) string {
+	path := lsp.URIToPath(uri)
+	col := pos.Character + 1
+	if text, err := f.line(path, pos.Line); err == nil {
+		col = byteColumn(text, pos.Character) + 1
 	}
+	return fmt.Sprintf("%s:%d:%d", f.relPath(path), pos.Line+1, col)
+}
 
-	if hoverResult.LinkPath != "" {
-		sb.WriteString(fmt.Sprintf("\n**Package:** %s\n", hoverResult.LinkPath))
+// utf16Column converts a 0-based byte offset within line to LSP's UTF-16 offset.
+func utf16Column(line string, byteCol int) int {
+	if byteCol > len(line) {
+		byteCol = len(line)
+	}
+	n := 0
+	for _, r := range line[:byteCol] {
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
 	}
+	return n
+}
 
-	return sb.String(), nil
+// byteColumn converts a 0-based UTF-16 offset within line to a byte offset.
+func byteColumn(line string, utf16Col int) int {
+	units := 0
+	for i, r := range line {
+		if units >= utf16Col {
+			return i
+		}
+		if r >= 0x10000 {
+			units += 2
+		} else {
+			units++
+		}
+	}
+	return len(line)
 }
@@ -0,0 +1,242 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/abdul-hamid-achik/vecai/internal/config"
+)
+
+// buildFakeLSP compiles testdata/fakelsp and returns the binary's path.
+// It must be called before the test changes directory.
+func buildFakeLSP(t *testing.T) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("skipping fake language server build in short mode")
+	}
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go toolchain not available")
+	}
+	src, err := filepath.Abs(filepath.Join("testdata", "fakelsp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bin := filepath.Join(t.TempDir(), "fakelsp")
+	cmd := exec.Command(goBin, "build", "-o", bin, ".")
+	cmd.Dir = src
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("failed to build fake language server: %v\n%s", err, out)
+	}
+	return bin
+}
+
+func TestParseLSPRequest_Validation(t *testing.T) {
+	dir := t.TempDir()
+	chdirTemp(t, dir)
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		input   map[string]any
+		wantErr string
+	}{
+		{"missing action", map[string]any{}, "action is required"},
+		{"unknown action", map[string]any{"action": "format"}, "unknown action"},
+		{"positional needs line", map[string]any{"action": "hover", "path": "main.go"}, "line is required"},
+		{"positional needs column", map[string]any{"action": "definition", "path": "main.go", "line": float64(1)}, "column is required"},
+		{"workspace needs query", map[string]any{"action": "workspace_symbols"}, "query is required"},
+		{"rename needs new_name", map[string]any{"action": "rename", "path": "main.go", "line": float64(1), "column": float64(1)}, "new_name is required"},
+		{"bad direction", map[string]any{"action": "call_hierarchy", "path": "main.go", "line": float64(1), "column": float64(1), "direction": "sideways"}, "direction must be"},
+		{"path outside project", map[string]any{"action": "document_symbols", "path": "/etc/passwd"}, "access denied"},
+		{"symbols only need path", map[string]any{"action": "document_symbols", "path": "main.go"}, ""},
+		{"workspace ok", map[string]any{"action": "workspace_symbols", "query": "Foo"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := parseLSPRequest(nil, tt.input)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if req.action != tt.input["action"] {
+					t.Errorf("expected action %v, got %s", tt.input["action"], req.action)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestParseLSPRequest_CallHierarchyDefaultsToIncoming(t *testing.T) {
+	dir := t.TempDir()
+	chdirTemp(t, dir)
+	if err := os.WriteFile(filepath.Join(dir, "a.go"), []byte("package a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := parseLSPRequest(nil, map[string]any{"action": "call_hierarchy", "path": "a.go", "line": float64(1), "column": float64(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.direction != "incoming" {
+		t.Errorf("expected incoming, got %q", req.direction)
+	}
+}
+
+func TestUTF16ColumnConversion(t *testing.T) {
+	// "é" is 2 bytes/1 unit, "𝄞" is 4 bytes/2 units
+	line := "x := \"é𝄞\" + y"
+	yByte := strings.Index(line, "y")
+
+	u := utf16Column(line, yByte)
+	if u != yByte-1-2 {
+		t.Errorf("utf16Column = %d, want %d", u, yByte-3)
+	}
+	if back := byteColumn(line, u); back != yByte {
+		t.Errorf("byteColumn(%d) = %d, want %d", u, back, yByte)
+	}
+	if got := utf16Column("abc", 10); got != 3 {
+		t.Errorf("expected clamp to line length, got %d", got)
+	}
+	if got := byteColumn("abc", 10); got != 3 {
+		t.Errorf("expected clamp to line length, got %d", got)
+	}
+}
+
+func TestDecodeLocations(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want int
+	}{
+		{"single location", `{"uri":"file:///a.go","range":{"start":{"line":1,"character":2},"end":{"line":1,"character":3}}}`, 1},
+		{"location array", `[{"uri":"file:///a.go","range":{"start":{"line":0,"character":0},"end":{"line":0,"character":1}}},{"uri":"file:///b.go","range":{"start":{"line":2,"character":0},"end":{"line":2,"character":1}}}]`, 2},
+		{"location links", `[{"targetUri":"file:///c.go","targetRange":{},"targetSelectionRange":{"start":{"line":4,"character":5},"end":{"line":4,"character":6}}}]`, 1},
+		{"null", `null`, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			locs := decodeLocations(json.RawMessage(tt.raw))
+			if len(locs) != tt.want {
+				t.Fatalf("expected %d locations, got %d (%+v)", tt.want, len(locs), locs)
+			}
+		})
+	}
+}
+
+func TestLSPTool_NotifyFileChangedWithoutSession(t *testing.T) {
+	// Must not start gopls or panic when no session is running
+	tool := NewLSPTool(t.TempDir(), nil)
+	tool.NotifyFileChanged("main.go")
+	if err := tool.Close(); err != nil {
+		t.Errorf("Close returned error: %v", err)
+	}
+}
+
+func TestLSPServersFromConfig(t *testing.T) {
+	cfg := &config.ToolsConfig{LSP: config.LSPToolConfig{Servers: map[string]config.LSPServerConfig{
+		"typescript": {Command: "typescript-language-server", Args: []string{"--stdio"}, Extensions: []string{".ts", "tsx"}},
+		"python":     {Command: "pyright-langserver", Args: []string{"--stdio"}, Extensions: []string{".py"}},
+		"broken":     {Command: "", Extensions: []string{".rb"}},
+	}}}
+
+	servers := LSPServersFromConfig(cfg)
+	var names []string
+	for _, s := range servers {
+		names = append(names, s.Name)
+	}
+	if got := strings.Join(names, ","); got != "python,typescript,go" {
+		t.Fatalf("expected python,typescript,go (sorted, gopls last, incomplete skipped), got %s", got)
+	}
+	if exts := servers[1].Extensions; exts[1] != ".tsx" {
+		t.Errorf("expected extension to be normalized to .tsx, got %q", exts[1])
+	}
+
+	if got := LSPServersFromConfig(nil); len(got) != 1 || got[0].Command != "gopls" {
+		t.Errorf("expected gopls only for nil config, got %+v", got)
+	}
+}
+
+func TestLSPTool_RoutesByExtension(t *testing.T) {
+	bin := buildFakeLSP(t)
+	dir := t.TempDir()
+	chdirTemp(t, dir)
+
+	files := map[string]string{
+		"app.py":       "def greet(name):\n    return name\n\ngreet('x')\n",
+		"view.tsx":     "export const View = () => null\n",
+		"notes.rb":     "puts 'hi'\n",
+		"script.scala": "object Main\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &config.ToolsConfig{LSP: config.LSPToolConfig{Servers: map[string]config.LSPServerConfig{
+		"fake":  {Command: bin, Extensions: []string{".py", ".tsx"}},
+		"scala": {Command: "definitely-not-a-real-lsp", Extensions: []string{".scala"}},
+	}}}
+	tool := NewLSPTool(dir, LSPServersFromConfig(cfg))
+	t.Cleanup(func() { _ = tool.Close() })
+
+	run := func(input map[string]any) (string, error) {
+		return tool.Execute(context.Background(), input)
+	}
+
+	out, err := run(map[string]any{"action": "hover", "path": "app.py", "line": float64(1), "column": float64(6)})
+	if err != nil {
+		t.Fatalf("hover failed: %v", err)
+	}
+	if !strings.Contains(out, "python symbol `greet`") {
+		t.Errorf("unexpected hover output: %s", out)
+	}
+
+	out, err = run(map[string]any{"action": "definition", "path": "app.py", "line": float64(4), "column": float64(1)})
+	if err != nil {
+		t.Fatalf("definition failed: %v", err)
+	}
+	if !strings.Contains(out, "## Definition") || !strings.Contains(out, "app.py:1:5") {
+		t.Errorf("unexpected definition output: %s", out)
+	}
+
+	out, err = run(map[string]any{"action": "references", "path": "app.py", "line": float64(1), "column": float64(11)})
+	if err != nil {
+		t.Fatalf("references failed: %v", err)
+	}
+	if !strings.Contains(out, "References (2 found)") || !strings.Contains(out, "app.py:2:12") {
+		t.Errorf("unexpected references output: %s", out)
+	}
+
+	// The languageId is inferred per file, not per server
+	out, err = run(map[string]any{"action": "hover", "path": "view.tsx", "line": float64(1), "column": float64(14)})
+	if err != nil {
+		t.Fatalf("tsx hover failed: %v", err)
+	}
+	if !strings.Contains(out, "typescriptreact symbol `View`") {
+		t.Errorf("unexpected tsx hover output: %s", out)
+	}
+
+	_, err = run(map[string]any{"action": "hover", "path": "notes.rb", "line": float64(1), "column": float64(1)})
+	if err == nil || !strings.Contains(err.Error(), "no language server configured for .rb") {
+		t.Errorf("expected unconfigured extension error, got %v", err)
+	}
+
+	_, err = run(map[string]any{"action": "hover", "path": "script.scala", "line": float64(1), "column": float64(1)})
+	if err == nil || !strings.Contains(err.Error(), "configured for .scala files") {
+		t.Errorf("expected missing server error, got %v", err)
+	}
+}
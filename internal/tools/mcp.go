@@ -0,0 +1,246 @@
+package tools
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abdul-hamid-achik/vecai/internal/config"
+	"github.com/abdul-hamid-achik/vecai/internal/mcp"
+)
+
+// MCPToolPrefix starts the registry name of every tool provided by an MCP
+// server: mcp__<server>__<tool>.
+const MCPToolPrefix = "mcp__"
+
+// defaultMCPTimeout bounds a single MCP tool call.
+const defaultMCPTimeout = 60 * time.Second
+
+// invalidToolNameChars matches characters LLM APIs reject in tool names.
+var invalidToolNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// IsMCPTool reports whether name belongs to an MCP server tool.
+func IsMCPTool(name string) bool {
+	return strings.HasPrefix(name, MCPToolPrefix)
+}
+
+// MCPTool exposes one tool of a connected MCP server. Tools of the same
+// server share its client; closing any of them stops the server.
+type MCPTool struct {
+	client     *mcp.Client
+	remote     mcp.Tool
+	name       string
+	permission PermissionLevel
+	timeout    time.Duration
+}
+
+// NewMCPTool wraps a remote tool.
+func NewMCPTool(client *mcp.Client, remote mcp.Tool, permission PermissionLevel, timeout time.Duration) *MCPTool {
+	if timeout <= 0 {
+		timeout = defaultMCPTimeout
+	}
+	name := mcpToolName(client.Name(), remote.Name)
+	return &MCPTool{client: client, remote: remote, name: name, permission: permission, timeout: timeout}
+}
+
+// maxToolNameLen is the longest tool name LLM APIs accept.
+const maxToolNameLen = 64
+
+// mcpToolName returns the registry name of a server's tool, with invalid
+// characters replaced. Names that are too long are cut and end in a hash of
+// the full name, so cutting does not make two names equal.
+func mcpToolName(server, tool string) string {
+	full := MCPToolPrefix + server + "__" + tool
+	name := invalidToolNameChars.ReplaceAllString(full, "_")
+	if len(name) > maxToolNameLen {
+		return hashedToolName(name, full)
+	}
+	return name
+}
+
+// hashedToolName cuts name to fit a hash of full after it.
+func hashedToolName(name, full string) string {
+	sum := sha256.Sum256([]byte(full))
+	suffix := "_" + hex.EncodeToString(sum[:4])
+	return name[:min(len(name), maxToolNameLen-len(suffix))] + suffix
+}
+
+// fullName is the tool's name before invalid characters were replaced.
+func (t *MCPTool) fullName() string {
+	return MCPToolPrefix + t.client.Name() + "__" + t.remote.Name
+}
+
+func (t *MCPTool) Name() string {
+	return t.name
+}
+
+func (t *MCPTool) Description() string {
+	return fmt.Sprintf("[MCP %s] %s", t.client.Name(), t.remote.Description)
+}
+
+func (t *MCPTool) InputSchema() map[string]any {
+	if t.remote.InputSchema == nil {
+		return map[string]any{"type": "object", "properties": map[string]any{}}
+	}
+	return t.remote.InputSchema
+}
+
+func (t *MCPTool) Permission() PermissionLevel {
+	return t.permission
+}
+
+func (t *MCPTool) Execute(ctx context.Context, input map[string]any) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	result, err := t.client.CallTool(ctx, t.remote.Name, input)
+	if err != nil {
+		return "", err
+	}
+	text := result.Text()
+	if result.IsError {
+		if text == "" {
+			text = "tool reported an error"
+		}
+		return "", errors.New(text)
+	}
+	return text, nil
+}
+
+// Close stops the tool's server.
+func (t *MCPTool) Close() error {
+	return t.client.Close()
+}
+
+// ParsePermissionLevel parses "read", "write" or "execute".
+func ParsePermissionLevel(s string) (PermissionLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "read":
+		return PermissionRead, nil
+	case "write":
+		return PermissionWrite, nil
+	case "execute", "":
+		return PermissionExecute, nil
+	default:
+		return PermissionExecute, fmt.Errorf("invalid permission %q (want read, write or execute)", s)
+	}
+}
+
+// ConnectMCPServers starts the configured MCP servers in parallel and
+// registers their tools. Servers that fail are skipped and reported in the
+// returned errors. With readOnly, only tools granted read permission are
+// registered. The servers run until the registry is closed.
+func (r *Registry) ConnectMCPServers(ctx context.Context, servers map[string]config.MCPServerConfig, readOnly bool) []error {
+	names := make([]string, 0, len(servers))
+	for name, sc := range servers {
+		if !sc.Disabled {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	cwd, _ := os.Getwd()
+	errs := make([]error, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = r.connectMCPServer(ctx, name, servers[name], cwd, readOnly)
+		}()
+	}
+	wg.Wait()
+
+	var failed []error
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	return failed
+}
+
+func (r *Registry) connectMCPServer(ctx context.Context, name string, sc config.MCPServerConfig, dir string, readOnly bool) error {
+	defaultPerm, err := ParsePermissionLevel(sc.Permission)
+	if err != nil {
+		return fmt.Errorf("mcp server %q: %w", name, err)
+	}
+	overrides := make(map[string]PermissionLevel, len(sc.Tools))
+	for tool, perm := range sc.Tools {
+		level, err := ParsePermissionLevel(perm)
+		if err != nil {
+			return fmt.Errorf("mcp server %q tool %q: %w", name, tool, err)
+		}
+		overrides[tool] = level
+	}
+
+	cfg := mcp.ServerConfig{
+		Name:    name,
+		Command: sc.Command,
+		Args:    sc.Args,
+		Dir:     dir,
+		URL:     os.ExpandEnv(sc.URL),
+		Headers: make(map[string]string, len(sc.Headers)),
+	}
+	for k, v := range sc.Env {
+		cfg.Env = append(cfg.Env, k+"="+os.ExpandEnv(v))
+	}
+	for k, v := range sc.Headers {
+		cfg.Headers[k] = os.ExpandEnv(v)
+	}
+
+	client, err := mcp.Connect(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	remotes, err := client.ListTools(ctx)
+	if err != nil {
+		_ = client.Close()
+		return fmt.Errorf("mcp server %q: failed to list tools: %w", name, err)
+	}
+
+	var registered int
+	var errs []error
+	for _, remote := range remotes {
+		level, ok := overrides[remote.Name]
+		if !ok {
+			level = defaultPerm
+		}
+		if readOnly && level != PermissionRead {
+			continue
+		}
+		if err := r.registerMCPTool(NewMCPTool(client, remote, level, sc.Timeout)); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		registered++
+	}
+	if registered == 0 {
+		errs = append(errs, client.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// registerMCPTool registers t without replacing another tool. If its name
+// is taken, e.g. by a tool whose name differs only in replaced characters,
+// t gets a hash of its full name appended.
+func (r *Registry) registerMCPTool(t *MCPTool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, taken := r.tools[t.name]; taken {
+		t.name = hashedToolName(t.name, t.fullName())
+		if _, taken := r.tools[t.name]; taken {
+			return fmt.Errorf("mcp server %q: tool %q has the same name as another tool", t.client.Name(), t.remote.Name)
+		}
+	}
+	r.tools[t.name] = t
+	return nil
+}
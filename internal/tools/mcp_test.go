@@ -0,0 +1,132 @@
+package tools
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/abdul-hamid-achik/vecai/internal/config"
+	"github.com/abdul-hamid-achik/vecai/internal/mcp/mcptest"
+)
+
+func TestConnectMCPServers(t *testing.T) {
+	srv := mcptest.NewServer(mcptest.Echo(), mcptest.Fail())
+	httpSrv := httptest.NewServer(srv)
+	defer httpSrv.Close()
+	t.Setenv("FAKE_MCP_URL", httpSrv.URL)
+
+	servers := map[string]config.MCPServerConfig{
+		"docs.site": {URL: "${FAKE_MCP_URL}", Tools: map[string]string{"echo": "read"}},
+		"broken":    {Command: "vecai-no-such-mcp-server"},
+		"skipped":   {Command: "vecai-no-such-mcp-server", Disabled: true},
+	}
+
+	r := NewEmptyRegistry()
+	errs := r.ConnectMCPServers(context.Background(), servers, false)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), `"broken"`) {
+		t.Fatalf("expected only the broken server to fail, got %v", errs)
+	}
+
+	echo, ok := r.Get("mcp__docs_site__echo")
+	if !ok {
+		t.Fatalf("echo not registered; have %v", r.GetDefinitions())
+	}
+	if echo.Permission() != PermissionRead {
+		t.Errorf("expected per-tool read override, got %s", echo.Permission())
+	}
+	if !strings.Contains(echo.Description(), "Echo the given text") {
+		t.Errorf("unexpected description %q", echo.Description())
+	}
+	fail, ok := r.Get("mcp__docs_site__fail")
+	if !ok || fail.Permission() != PermissionExecute {
+		t.Fatalf("expected fail tool with default execute permission")
+	}
+
+	out, err := r.Execute(context.Background(), echo.Name(), map[string]any{"text": "hi"})
+	if err != nil || out != "hi" {
+		t.Errorf("echo = %q, %v", out, err)
+	}
+	if _, err := fail.Execute(context.Background(), nil); err == nil || err.Error() != "something went wrong" {
+		t.Errorf("expected tool error to surface, got %v", err)
+	}
+	if calls := srv.Calls(); len(calls) != 2 || calls[0].Args["text"] != "hi" {
+		t.Errorf("unexpected calls %+v", calls)
+	}
+
+	// Closing the registry ends the server session
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if srv.Sessions() != 0 {
+		t.Error("expected registry Close to stop the MCP client")
+	}
+}
+
+func TestConnectMCPServers_ReadOnlyRegistersReadTools(t *testing.T) {
+	srv := mcptest.NewServer(mcptest.Echo(), mcptest.Fail())
+	httpSrv := httptest.NewServer(srv)
+	defer httpSrv.Close()
+
+	r := NewEmptyRegistry()
+	defer r.Close()
+	servers := map[string]config.MCPServerConfig{
+		"fake": {URL: httpSrv.URL, Tools: map[string]string{"echo": "read"}},
+	}
+	if errs := r.ConnectMCPServers(context.Background(), servers, true); len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	if _, ok := r.Get("mcp__fake__echo"); !ok {
+		t.Error("expected read tool registered")
+	}
+	if _, ok := r.Get("mcp__fake__fail"); ok {
+		t.Error("expected execute tool skipped in read-only registry")
+	}
+
+	if errs := r.ConnectMCPServers(context.Background(), map[string]config.MCPServerConfig{
+		"bad": {URL: httpSrv.URL, Permission: "admin"},
+	}, false); len(errs) != 1 {
+		t.Errorf("expected invalid permission to be rejected, got %v", errs)
+	}
+}
+
+func TestConnectMCPServers_CollidingNames(t *testing.T) {
+	srv := mcptest.NewServer(mcptest.Echo())
+	httpSrv := httptest.NewServer(srv)
+	defer httpSrv.Close()
+
+	r := NewEmptyRegistry()
+	defer r.Close()
+	servers := map[string]config.MCPServerConfig{
+		"docs.site": {URL: httpSrv.URL, Permission: "read"},
+		"docs_site": {URL: httpSrv.URL, Permission: "execute"},
+	}
+	if errs := r.ConnectMCPServers(context.Background(), servers, false); len(errs) != 0 {
+		t.Fatal(errs)
+	}
+
+	perms := make(map[PermissionLevel]string)
+	for _, tool := range r.List() {
+		if IsMCPTool(tool.Name()) {
+			perms[tool.Permission()] = tool.Name()
+		}
+	}
+	if len(perms) != 2 || perms[PermissionRead] == perms[PermissionExecute] {
+		t.Fatalf("expected both echo tools under distinct names, got %v", perms)
+	}
+	if _, ok := r.Get("mcp__docs_site__echo"); !ok {
+		t.Errorf("expected one echo tool to keep the plain name, got %v", perms)
+	}
+}
+
+func TestMCPToolName(t *testing.T) {
+	if got := mcpToolName("docs.site", "search"); got != "mcp__docs_site__search" {
+		t.Errorf("expected invalid characters replaced, got %s", got)
+	}
+
+	long := strings.Repeat("x", 60)
+	a, b := mcpToolName("server", long+"a"), mcpToolName("server", long+"b")
+	if len(a) != maxToolNameLen || len(b) != maxToolNameLen || a == b {
+		t.Errorf("expected distinct names cut to %d characters, got %s and %s", maxToolNameLen, a, b)
+	}
+}
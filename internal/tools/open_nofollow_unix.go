@@ -12,7 +12,7 @@ import (
 // openNoFollow opens a file for writing without following symlinks.
 // This provides an additional defense against symlink swap attacks
 // that could occur between validation and open.
-func openNoFollow(path string, flag int, perm os.FileMode) (*os.File, error) {
+func (w *Workspace) openNoFollow(path string, flag int, perm os.FileMode) (*os.File, error) {
 	// Use O_NOFOLLOW to refuse to open symlinks
 	// Note: O_NOFOLLOW is available on both macOS and Linux
 	f, err := os.OpenFile(path, flag|syscall.O_NOFOLLOW, perm)
@@ -28,7 +28,7 @@ func openNoFollow(path string, flag int, perm os.FileMode) (*os.File, error) {
 		return nil, fmt.Errorf("post-open path resolution failed: %w", err)
 	}
 
-	root, err := getProjectRoot()
+	root, err := w.Root()
 	if err != nil {
 		f.Close()
 		return nil, err
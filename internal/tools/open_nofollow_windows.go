@@ -10,7 +10,7 @@ import (
 
 // openNoFollow opens a file for writing on Windows where O_NOFOLLOW is not available.
 // We still perform post-open validation to mitigate symlink swaps.
-func openNoFollow(path string, flag int, perm os.FileMode) (*os.File, error) {
+func (w *Workspace) openNoFollow(path string, flag int, perm os.FileMode) (*os.File, error) {
 	f, err := os.OpenFile(path, flag, perm)
 	if err != nil {
 		return nil, err
@@ -22,7 +22,7 @@ func openNoFollow(path string, flag int, perm os.FileMode) (*os.File, error) {
 		return nil, fmt.Errorf("post-open path resolution failed: %w", err)
 	}
 
-	root, err := getProjectRoot()
+	root, err := w.Root()
 	if err != nil {
 		f.Close()
 		return nil, err
@@ -0,0 +1,749 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxPatchFuzz is how many leading/trailing context lines a unified diff hunk
+// may drop when its full context no longer matches (like patch's --fuzz).
+const maxPatchFuzz = 2
+
+// ApplyPatchTool applies a patch spanning one or more files all-or-nothing.
+type ApplyPatchTool struct {
+	Workspace *Workspace // Root for paths; nil means the project root
+}
+
+func (t *ApplyPatchTool) Name() string {
+	return "apply_patch"
+}
+
+func (t *ApplyPatchTool) Description() string {
+	return "Apply a patch that edits one or more files in a single all-or-nothing step. Prefer this over repeated edit_file calls for multi-file or multi-location changes. " +
+		"Accepts either a unified diff (--- a/path, +++ b/path, @@ hunks; /dev/null creates or deletes a file) or search/replace blocks: a line with the file path, then <<<<<<< SEARCH, the exact old lines, =======, the new lines, >>>>>>> REPLACE (an empty SEARCH creates a new file). " +
+		"Hunks whose line numbers have drifted are located by their context, tolerating whitespace differences. If any hunk fails, no file is changed and each hunk's result is reported."
+}
+
+func (t *ApplyPatchTool) InputSchema() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"patch": map[string]any{
+				"type":        "string",
+				"description": "The unified diff or search/replace blocks to apply. Paths are relative to the project root.",
+			},
+		},
+		"required": []string{"patch"},
+	}
+}
+
+func (t *ApplyPatchTool) Permission() PermissionLevel {
+	return PermissionWrite
+}
+
+// filePatch is every change a patch makes to one file.
+type filePatch struct {
+	path    string // Target path as written in the patch
+	oldPath string // Source path when a unified diff renames the file
+	create  bool
+	delete  bool
+	hunks   []patchHunk
+}
+
+// patchHunk replaces the lines in before with the lines in after.
+type patchHunk struct {
+	before    []string
+	after     []string
+	line      int  // 1-indexed start from the @@ header; 0 when unknown
+	ordered   bool // Unified hunks apply top to bottom; search/replace blocks anywhere
+	leadCtx   int  // Unchanged lines at the start of the hunk
+	trailCtx  int  // Unchanged lines at the end of the hunk
+	sourceIdx int  // 1-indexed position within its file, for reporting
+}
+
+// hunkResult reports how one hunk applied.
+type hunkResult struct {
+	file  string
+	index int // 1-indexed; 0 reports a problem with the whole file
+	line  int // 1-indexed line where the hunk applied
+	note  string
+	err   error
+}
+
+func (r hunkResult) String() string {
+	if r.index == 0 {
+		return fmt.Sprintf("  %s: FAILED: %v", r.file, r.err)
+	}
+	if r.err != nil {
+		return fmt.Sprintf("  %s hunk %d: FAILED: %v", r.file, r.index, r.err)
+	}
+	s := fmt.Sprintf("  %s hunk %d: applied at line %d", r.file, r.index, r.line)
+	if r.note != "" {
+		s += " (" + r.note + ")"
+	}
+	return s
+}
+
+// pendingFile is the in-memory result of patching one file, written only
+// once every hunk in the patch has applied.
+type pendingFile struct {
+	workspace *Workspace
+	absPath   string
+	display   string
+	original  []byte // nil when the file did not exist
+	mode      os.FileMode
+	content   string
+	deleted   bool
+}
+
+func (t *ApplyPatchTool) Execute(ctx context.Context, input map[string]any) (string, error) {
+	patch, ok := input["patch"].(string)
+	if !ok || strings.TrimSpace(patch) == "" {
+		return "", fmt.Errorf("patch is required")
+	}
+
+	patches, err := parsePatch(patch)
+	if err != nil {
+		return "", err
+	}
+
+	var order []string
+	pending := make(map[string]*pendingFile)
+	var results []hunkResult
+	failed := false
+
+	// load returns the file's current state, including earlier sections of this patch.
+	load := func(path string) (*pendingFile, error) {
+		absPath, err := t.Workspace.ResolvePath(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path: %w", err)
+		}
+		if pf, ok := pending[absPath]; ok {
+			return pf, nil
+		}
+		if err := t.Workspace.ValidatePathForWrite(absPath); err != nil {
+			return nil, err
+		}
+		pf := &pendingFile{workspace: t.Workspace, absPath: absPath, display: path, mode: 0644, deleted: true}
+		info, err := os.Lstat(absPath)
+		switch {
+		case err == nil && info.Mode()&os.ModeSymlink != 0:
+			return nil, fmt.Errorf("access denied: refusing to patch through symlink %q", path)
+		case err == nil && info.IsDir():
+			return nil, fmt.Errorf("%s is a directory", path)
+		case err == nil:
+			data, readErr := os.ReadFile(absPath)
+			if readErr != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", path, readErr)
+			}
+			pf.original, pf.content, pf.mode, pf.deleted = data, string(data), info.Mode().Perm(), false
+		case !os.IsNotExist(err):
+			return nil, fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+		pending[absPath] = pf
+		order = append(order, absPath)
+		return pf, nil
+	}
+
+	for _, fp := range patches {
+		fail := func(err error) {
+			results = append(results, hunkResult{file: fp.path, err: err})
+			failed = true
+		}
+
+		source := fp.path
+		if fp.oldPath != "" {
+			source = fp.oldPath
+		}
+		src, err := load(source)
+		if err != nil {
+			fail(err)
+			continue
+		}
+		dst := src
+		if fp.oldPath != "" {
+			if dst, err = load(fp.path); err != nil {
+				fail(err)
+				continue
+			}
+			if !dst.deleted {
+				fail(fmt.Errorf("cannot rename %s: %s already exists", fp.oldPath, fp.path))
+				continue
+			}
+		}
+
+		switch {
+		case fp.create && !src.deleted:
+			fail(fmt.Errorf("cannot create %s: file already exists", fp.path))
+			continue
+		case !fp.create && src.deleted:
+			fail(fmt.Errorf("%s does not exist", source))
+			continue
+		}
+
+		if fp.delete {
+			src.deleted = true
+			src.content = ""
+			results = append(results, hunkResult{file: fp.path, index: 1, line: 1, note: "file deleted"})
+			continue
+		}
+
+		content, hunkResults := applyHunks(fp.path, src.content, fp.hunks)
+		results = append(results, hunkResults...)
+		for _, r := range hunkResults {
+			if r.err != nil {
+				failed = true
+			}
+		}
+		if fp.oldPath != "" {
+			src.deleted, src.content = true, ""
+			dst.mode = src.mode
+		}
+		dst.content, dst.deleted = content, false
+	}
+
+	var report strings.Builder
+	for _, r := range results {
+		report.WriteString(r.String())
+		report.WriteString("\n")
+	}
+	if failed {
+		return "", fmt.Errorf("patch not applied, no files were changed:\n%s", strings.TrimRight(report.String(), "\n"))
+	}
+
+	if err := commitPatch(order, pending); err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	changed := 0
+	for _, absPath := range order {
+		if pending[absPath].changed() {
+			changed++
+		}
+	}
+	sb.WriteString(fmt.Sprintf("Successfully applied patch to %d files (%d hunks)\n%s", changed, len(results), report.String()))
+
+	// Unified diffs for TUI visualization
+	for _, absPath := range order {
+		pf := pending[absPath]
+		if diff := GenerateUnifiedDiff(pf.display, string(pf.original), pf.content, 3); diff != "" {
+			sb.WriteString("\n")
+			sb.WriteString(diff)
+		}
+	}
+	return sb.String(), nil
+}
+
+// commitPatch writes every pending file, restoring the originals if any write fails.
+func commitPatch(order []string, pending map[string]*pendingFile) error {
+	var done []*pendingFile
+	for _, absPath := range order {
+		pf := pending[absPath]
+		if err := pf.write(); err != nil {
+			for _, prev := range done {
+				_ = prev.restore()
+			}
+			_ = pf.restore()
+			return fmt.Errorf("failed to apply patch to %s (all files restored): %w", pf.display, err)
+		}
+		done = append(done, pf)
+	}
+	return nil
+}
+
+// changed reports whether committing pf modifies the disk.
+func (pf *pendingFile) changed() bool {
+	if pf.original == nil {
+		return !pf.deleted
+	}
+	return pf.deleted || string(pf.original) != pf.content
+}
+
+func (pf *pendingFile) write() error {
+	switch {
+	case !pf.changed():
+		return nil
+	case pf.deleted:
+		return os.Remove(pf.absPath)
+	default:
+		return pf.workspace.writeNoFollow(pf.absPath, []byte(pf.content), pf.mode)
+	}
+}
+
+func (pf *pendingFile) restore() error {
+	if pf.original == nil {
+		if err := os.Remove(pf.absPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return pf.workspace.writeNoFollow(pf.absPath, pf.original, pf.mode)
+}
+
+// writeNoFollow creates parent directories and writes data without following
+// a symlink at absPath.
+func (w *Workspace) writeNoFollow(absPath string, data []byte, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(absPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directories: %w", err)
+	}
+	f, err := w.openNoFollow(absPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// PatchTargets returns every file path an apply_patch input touches, as
+// written in the patch, so callers can snapshot them before it runs.
+func PatchTargets(patch string) ([]string, error) {
+	patches, err := parsePatch(patch)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var paths []string
+	for _, fp := range patches {
+		for _, p := range []string{fp.oldPath, fp.path} {
+			if p != "" && !seen[p] {
+				seen[p] = true
+				paths = append(paths, p)
+			}
+		}
+	}
+	return paths, nil
+}
+
+// parsePatch detects the patch format and parses it.
+func parsePatch(patch string) ([]*filePatch, error) {
+	patch = strings.ReplaceAll(patch, "\r\n", "\n")
+	var patches []*filePatch
+	var err error
+	if strings.Contains(patch, "<<<<<<< SEARCH") {
+		patches, err = parseSearchReplace(patch)
+	} else {
+		patches, err = parseUnifiedDiff(patch)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(patches) == 0 {
+		return nil, fmt.Errorf("no file changes found in patch; expected a unified diff or SEARCH/REPLACE blocks")
+	}
+	return patches, nil
+}
+
+var hunkHeaderRe = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// parseUnifiedDiff parses git-style and plain unified diffs. Hunk line counts
+// are not trusted (models often get them wrong); a hunk runs until the next
+// header.
+func parseUnifiedDiff(patch string) ([]*filePatch, error) {
+	lines := strings.Split(patch, "\n")
+	var patches []*filePatch
+	var cur *filePatch
+	var hunk *patchHunk
+	inLeadCtx := false
+
+	flush := func() {
+		if cur != nil && hunk != nil {
+			// Blank lines after the last hunk are separators, not context
+			for len(hunk.before) > 0 && len(hunk.after) > 0 && hunk.trailCtx > 0 &&
+				hunk.before[len(hunk.before)-1] == "" && hunk.after[len(hunk.after)-1] == "" {
+				hunk.before = hunk.before[:len(hunk.before)-1]
+				hunk.after = hunk.after[:len(hunk.after)-1]
+				hunk.trailCtx--
+			}
+			if len(hunk.before) > 0 || len(hunk.after) > 0 {
+				hunk.sourceIdx = len(cur.hunks) + 1
+				cur.hunks = append(cur.hunks, *hunk)
+			}
+		}
+		hunk = nil
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			flush()
+			oldPath := diffPath(line[4:])
+			newPath := diffPath(lines[i+1][4:])
+			i++
+			fp := &filePatch{path: newPath}
+			switch {
+			case oldPath == "/dev/null" && newPath == "/dev/null":
+				return nil, fmt.Errorf("invalid file header at line %d: both sides are /dev/null", i)
+			case oldPath == "/dev/null":
+				fp.create = true
+			case newPath == "/dev/null":
+				fp.delete, fp.path = true, oldPath
+			case oldPath != newPath:
+				fp.oldPath = oldPath
+			}
+			patches = append(patches, fp)
+			cur = fp
+
+		case strings.HasPrefix(line, "@@"):
+			if cur == nil {
+				return nil, fmt.Errorf("hunk at line %d has no --- / +++ file header", i+1)
+			}
+			flush()
+			hunk = &patchHunk{ordered: true}
+			inLeadCtx = true
+			if m := hunkHeaderRe.FindStringSubmatch(line); m != nil {
+				hunk.line, _ = strconv.Atoi(m[1])
+				// "-N,0" means the hunk inserts after line N
+				if m[2] == "0" {
+					hunk.line++
+				}
+			}
+
+		case hunk != nil:
+			if strings.HasPrefix(line, `\`) { // "\ No newline at end of file"
+				continue
+			}
+			if strings.HasPrefix(line, "diff --git ") {
+				flush()
+				continue
+			}
+			op, text := byte(' '), line
+			if line != "" {
+				op, text = line[0], line[1:]
+			}
+			switch op {
+			case ' ':
+				hunk.before = append(hunk.before, text)
+				hunk.after = append(hunk.after, text)
+				if inLeadCtx {
+					hunk.leadCtx++
+				}
+				hunk.trailCtx++
+			case '-':
+				hunk.before = append(hunk.before, text)
+				hunk.trailCtx, inLeadCtx = 0, false
+			case '+':
+				hunk.after = append(hunk.after, text)
+				hunk.trailCtx, inLeadCtx = 0, false
+			default:
+				// Unprefixed text ends the hunk (e.g. prose after the diff)
+				flush()
+			}
+		}
+	}
+	flush()
+
+	for _, fp := range patches {
+		if len(fp.hunks) == 0 && !fp.delete {
+			return nil, fmt.Errorf("no hunks for %s", fp.path)
+		}
+	}
+	return patches, nil
+}
+
+// diffPath strips the a/ b/ prefixes and trailing timestamps from a diff header path.
+func diffPath(s string) string {
+	if i := strings.Index(s, "\t"); i >= 0 {
+		s = s[:i]
+	}
+	s = strings.TrimSpace(s)
+	if s == "/dev/null" {
+		return s
+	}
+	if strings.HasPrefix(s, "a/") || strings.HasPrefix(s, "b/") {
+		s = s[2:]
+	}
+	return s
+}
+
+// parseSearchReplace parses blocks of the form:
+//
+//	path/to/file
+//	<<<<<<< SEARCH
+//	old lines
+//	=======
+//	new lines
+//	>>>>>>> REPLACE
+//
+// A block without a path line applies to the previous block's file.
+func parseSearchReplace(patch string) ([]*filePatch, error) {
+	lines := strings.Split(patch, "\n")
+	var patches []*filePatch
+	byPath := make(map[string]*filePatch)
+	lastPath, candidate := "", ""
+
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " \t")
+		if line != "<<<<<<< SEARCH" {
+			if trimmed := strings.TrimSpace(line); trimmed != "" && !strings.HasPrefix(trimmed, "```") {
+				candidate = strings.Trim(trimmed, "`*: ")
+			}
+			continue
+		}
+
+		path := candidate
+		if path == "" {
+			path = lastPath
+		}
+		if path == "" {
+			return nil, fmt.Errorf("SEARCH block at line %d has no file path; put the path on the line before it", i+1)
+		}
+		lastPath, candidate = path, ""
+
+		start := i + 1
+		var before, after []string
+		sep, end := -1, -1
+		for j := start; j < len(lines); j++ {
+			marker := strings.TrimRight(lines[j], " \t")
+			if marker == "=======" && sep < 0 {
+				sep = j
+			} else if marker == ">>>>>>> REPLACE" && sep >= 0 {
+				end = j
+				break
+			}
+		}
+		if sep < 0 || end < 0 {
+			return nil, fmt.Errorf("SEARCH block at line %d for %s is missing ======= or >>>>>>> REPLACE", i+1, path)
+		}
+		before = append(before, lines[start:sep]...)
+		after = append(after, lines[sep+1:end]...)
+		i = end
+
+		fp, ok := byPath[path]
+		if !ok {
+			fp = &filePatch{path: path}
+			byPath[path] = fp
+			patches = append(patches, fp)
+		}
+		if len(before) == 0 {
+			if len(fp.hunks) > 0 {
+				return nil, fmt.Errorf("empty SEARCH block for %s must be its only block", path)
+			}
+			fp.create = true
+		}
+		fp.hunks = append(fp.hunks, patchHunk{before: before, after: after, sourceIdx: len(fp.hunks) + 1})
+	}
+	return patches, nil
+}
+
+// lineMatchers compare a hunk line to a file line, from strictest to loosest.
+var lineMatchers = []struct {
+	note  string
+	equal func(a, b string) bool
+}{
+	{"", func(a, b string) bool { return a == b }},
+	{"ignoring trailing whitespace", func(a, b string) bool {
+		return strings.TrimRight(a, " \t") == strings.TrimRight(b, " \t")
+	}},
+	{"ignoring whitespace", func(a, b string) bool {
+		return strings.Join(strings.Fields(a), " ") == strings.Join(strings.Fields(b), " ")
+	}},
+}
+
+// applyHunks applies hunks to content in order and reports each one. The
+// returned content is only meaningful when every result succeeded.
+func applyHunks(file, content string, hunks []patchHunk) (string, []hunkResult) {
+	lines := strings.Split(content, "\n")
+	trailingNewline := content == "" || strings.HasSuffix(content, "\n")
+	if strings.HasSuffix(content, "\n") {
+		lines = lines[:len(lines)-1]
+	}
+	if content == "" {
+		lines = nil
+	}
+
+	var results []hunkResult
+	from, delta := 0, 0
+	for _, h := range hunks {
+		hint := -1
+		if h.line > 0 {
+			hint = h.line - 1 + delta
+		}
+		minPos := 0
+		if h.ordered {
+			minPos = from
+		}
+
+		m, err := locateHunk(lines, h, minPos, hint)
+		res := hunkResult{file: file, index: h.sourceIdx}
+		if err != nil {
+			res.err = err
+			results = append(results, res)
+			continue
+		}
+		pos, before, after, note := m.pos, m.before, m.after, m.note
+
+		// Apply to a fresh slice so earlier results stay intact
+		updated := make([]string, 0, len(lines)-len(before)+len(after))
+		updated = append(updated, lines[:pos]...)
+		updated = append(updated, after...)
+		updated = append(updated, lines[pos+len(before):]...)
+		lines = updated
+
+		res.line = pos + 1
+		if expected := hint + m.lead; hint >= 0 && pos != expected {
+			offset := fmt.Sprintf("offset %+d lines", pos-expected)
+			if note == "" {
+				note = offset
+			} else {
+				note = offset + ", " + note
+			}
+		}
+		res.note = note
+		results = append(results, res)
+
+		from = pos + len(after)
+		delta += len(after) - len(before)
+	}
+
+	out := strings.Join(lines, "\n")
+	if trailingNewline && len(lines) > 0 {
+		out += "\n"
+	}
+	return out, results
+}
+
+// hunkMatch is where a hunk applies. before and after may be trimmed of
+// lead leading context lines (and some trailing ones) when fuzz was needed.
+type hunkMatch struct {
+	pos    int
+	lead   int
+	before []string
+	after  []string
+	note   string
+}
+
+// locateHunk finds where h applies, trying exact matches first, then
+// whitespace-tolerant ones, then (for unified hunks) dropping up to
+// maxPatchFuzz context lines from each end.
+func locateHunk(lines []string, h patchHunk, minPos, hint int) (hunkMatch, error) {
+	if len(h.before) == 0 {
+		// Pure insertion: only unified hunks carry a position
+		switch {
+		case len(lines) == 0:
+			return hunkMatch{after: h.after}, nil
+		case hint >= 0 && hint <= len(lines):
+			return hunkMatch{pos: hint, after: h.after}, nil
+		default:
+			return hunkMatch{}, fmt.Errorf("cannot insert into an existing file without a line number or context")
+		}
+	}
+
+	maxFuzz := 0
+	if h.ordered {
+		maxFuzz = maxPatchFuzz
+	}
+	for fuzz := 0; fuzz <= maxFuzz; fuzz++ {
+		lead, trail := min(fuzz, h.leadCtx), min(fuzz, h.trailCtx)
+		if fuzz > 0 && lead+trail == 0 {
+			break
+		}
+		if lead+trail >= len(h.before) {
+			break
+		}
+		before := h.before[lead : len(h.before)-trail]
+		after := h.after[lead : len(h.after)-trail]
+		fuzzHint := hint
+		if hint >= 0 {
+			fuzzHint = hint + lead
+		}
+
+		for _, m := range lineMatchers {
+			matches := findMatches(lines, before, minPos, m.equal)
+			if len(matches) == 0 {
+				continue
+			}
+			note := m.note
+			if fuzz > 0 {
+				fuzzNote := fmt.Sprintf("fuzz %d", fuzz)
+				if note == "" {
+					note = fuzzNote
+				} else {
+					note = fuzzNote + ", " + note
+				}
+			}
+			match := hunkMatch{pos: matches[0], lead: lead, before: before, after: after, note: note}
+			if !h.ordered {
+				if len(matches) > 1 {
+					return hunkMatch{}, fmt.Errorf("SEARCH text matches %d locations (lines %s); add surrounding lines to make it unique", len(matches), joinLineNumbers(matches))
+				}
+				return match, nil
+			}
+			match.pos = nearest(matches, fuzzHint)
+			return match, nil
+		}
+	}
+
+	if h.ordered {
+		return hunkMatch{}, fmt.Errorf("context not found: %q", firstNonEmpty(h.before))
+	}
+	return hunkMatch{}, fmt.Errorf("SEARCH text not found: %q", firstNonEmpty(h.before))
+}
+
+// findMatches returns every position at or after minPos where block matches lines.
+func findMatches(lines, block []string, minPos int, equal func(a, b string) bool) []int {
+	var matches []int
+	for pos := minPos; pos+len(block) <= len(lines); pos++ {
+		ok := true
+		for k, want := range block {
+			if !equal(lines[pos+k], want) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			matches = append(matches, pos)
+		}
+	}
+	return matches
+}
+
+// nearest picks the match closest to hint, or the first when there is no hint.
+func nearest(matches []int, hint int) int {
+	if hint < 0 {
+		return matches[0]
+	}
+	best := matches[0]
+	for _, m := range matches[1:] {
+		if abs(m-hint) < abs(best-hint) {
+			best = m
+		}
+	}
+	return best
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func joinLineNumbers(positions []int) string {
+	nums := make([]string, len(positions))
+	for i, p := range positions {
+		nums[i] = strconv.Itoa(p + 1)
+	}
+	return strings.Join(nums, ", ")
+}
+
+func firstNonEmpty(lines []string) string {
+	for _, l := range lines {
+		if t := strings.TrimSpace(l); t != "" {
+			if len(t) > 80 {
+				t = t[:80] + "..."
+			}
+			return t
+		}
+	}
+	return ""
+}
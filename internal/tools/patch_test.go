@@ -0,0 +1,258 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func readTestFile(t *testing.T, dir, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func applyTestPatch(t *testing.T, patch string) (string, error) {
+	t.Helper()
+	return (&ApplyPatchTool{}).Execute(context.Background(), map[string]any{"patch": patch})
+}
+
+func TestApplyPatch_UnifiedDiffAcrossFiles(t *testing.T) {
+	dir := t.TempDir()
+	chdirTemp(t, dir)
+	writeTestFiles(t, dir, map[string]string{
+		"a.go":   "package a\n\nfunc A() int {\n\treturn 1\n}\n",
+		"b.go":   "package b\n\nconst B = 1\n",
+		"old.go": "package old\n",
+	})
+
+	patch := `diff --git a/a.go b/a.go
+--- a/a.go
++++ b/a.go
+@@ -3,3 +3,3 @@
+ func A() int {
+-	return 1
++	return 2
+ }
+--- a/b.go
++++ b/b.go
+@@ -3 +3,2 @@
+ const B = 1
++const C = 2
+--- /dev/null
++++ b/pkg/new.go
+@@ -0,0 +1,2 @@
++package pkg
++
+--- a/old.go
++++ /dev/null
+@@ -1 +0,0 @@
+-package old
+`
+	out, err := applyTestPatch(t, patch)
+	if err != nil {
+		t.Fatalf("apply failed: %v", err)
+	}
+	if !strings.Contains(out, "Successfully applied patch to 4 files") {
+		t.Errorf("unexpected summary:\n%s", out)
+	}
+
+	if got := readTestFile(t, dir, "a.go"); !strings.Contains(got, "return 2") {
+		t.Errorf("a.go not patched:\n%s", got)
+	}
+	if got := readTestFile(t, dir, "b.go"); got != "package b\n\nconst B = 1\nconst C = 2\n" {
+		t.Errorf("b.go not patched: %q", got)
+	}
+	if got := readTestFile(t, dir, "pkg/new.go"); got != "package pkg\n\n" {
+		t.Errorf("new file has wrong content: %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "old.go")); !os.IsNotExist(err) {
+		t.Errorf("expected old.go to be deleted, stat err = %v", err)
+	}
+}
+
+func TestApplyPatch_DriftWhitespaceAndFuzz(t *testing.T) {
+	dir := t.TempDir()
+	chdirTemp(t, dir)
+	writeTestFiles(t, dir, map[string]string{
+		"main.go": "package main\n\n// added line 1\n// added line 2\n\nfunc main() {\n    run()   \n}\n\nfunc helper() {\n\tx := 1\n\t_ = x\n}\n",
+	})
+
+	// Hunk 1's line numbers are off by two and its whitespace differs;
+	// hunk 2's leading context line no longer exists.
+	patch := `--- a/main.go
++++ b/main.go
+@@ -4,3 +4,3 @@
+ func main() {
+-	run()
++	run(ctx)
+ }
+@@ -8,4 +8,4 @@
+ func helper() { // stale comment
+ 	x := 1
+-	_ = x
++	println(x)
+ }
+`
+	out, err := applyTestPatch(t, patch)
+	if err != nil {
+		t.Fatalf("apply failed: %v", err)
+	}
+	for _, want := range []string{
+		"main.go hunk 1: applied at line 6 (offset +2 lines, ignoring whitespace)",
+		"main.go hunk 2: applied at line 11 (offset +2 lines, fuzz 1)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output:\n%s", want, out)
+		}
+	}
+	got := readTestFile(t, dir, "main.go")
+	if !strings.Contains(got, "\trun(ctx)\n") || !strings.Contains(got, "\tprintln(x)\n") {
+		t.Errorf("main.go not patched:\n%s", got)
+	}
+}
+
+func TestApplyPatch_AllOrNothing(t *testing.T) {
+	dir := t.TempDir()
+	chdirTemp(t, dir)
+	original := map[string]string{
+		"a.txt": "one\ntwo\nthree\n",
+		"b.txt": "alpha\nbeta\n",
+	}
+	writeTestFiles(t, dir, original)
+
+	patch := `--- a/a.txt
++++ b/a.txt
+@@ -1,3 +1,3 @@
+ one
+-two
++TWO
+ three
+--- a/b.txt
++++ b/b.txt
+@@ -1,2 +1,2 @@
+ alpha
+-gamma
++GAMMA
+`
+	_, err := applyTestPatch(t, patch)
+	if err == nil {
+		t.Fatal("expected failure")
+	}
+	msg := err.Error()
+	for _, want := range []string{"no files were changed", "a.txt hunk 1: applied at line 1", "b.txt hunk 1: FAILED: context not found"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("expected %q in error:\n%s", want, msg)
+		}
+	}
+	for name, content := range original {
+		if got := readTestFile(t, dir, name); got != content {
+			t.Errorf("%s was modified despite the failure: %q", name, got)
+		}
+	}
+}
+
+func TestApplyPatch_SearchReplace(t *testing.T) {
+	dir := t.TempDir()
+	chdirTemp(t, dir)
+	writeTestFiles(t, dir, map[string]string{
+		"svc.py": "def a():\n    return 1\n\ndef b():\n    return 1\n",
+	})
+
+	patch := "svc.py\n```python\n<<<<<<< SEARCH\ndef b():\n    return 1\n=======\ndef b():\n    return 2\n>>>>>>> REPLACE\n<<<<<<< SEARCH\ndef a():\n=======\nasync def a():\n>>>>>>> REPLACE\n```\n\nnotes/todo.md\n<<<<<<< SEARCH\n=======\n- ship it\n>>>>>>> REPLACE\n"
+	out, err := applyTestPatch(t, patch)
+	if err != nil {
+		t.Fatalf("apply failed: %v", err)
+	}
+	if !strings.Contains(out, "svc.py hunk 2: applied at line 1") {
+		t.Errorf("unexpected report:\n%s", out)
+	}
+	if got := readTestFile(t, dir, "svc.py"); got != "async def a():\n    return 1\n\ndef b():\n    return 2\n" {
+		t.Errorf("svc.py not patched: %q", got)
+	}
+	if got := readTestFile(t, dir, "notes/todo.md"); got != "- ship it\n" {
+		t.Errorf("todo.md not created: %q", got)
+	}
+
+	// Ambiguous SEARCH text is rejected rather than guessed
+	writeTestFiles(t, dir, map[string]string{"dup.py": "x = 1\ny = 2\nx = 1\n"})
+	_, err = applyTestPatch(t, "dup.py\n<<<<<<< SEARCH\nx = 1\n=======\nx = 3\n>>>>>>> REPLACE\n")
+	if err == nil || !strings.Contains(err.Error(), "matches 2 locations (lines 1, 3)") {
+		t.Errorf("expected ambiguity error, got %v", err)
+	}
+}
+
+func TestApplyPatch_Rename(t *testing.T) {
+	dir := t.TempDir()
+	chdirTemp(t, dir)
+	writeTestFiles(t, dir, map[string]string{"old.txt": "keep\nchange\n"})
+
+	_, err := applyTestPatch(t, "--- a/old.txt\n+++ b/new.txt\n@@ -1,2 +1,2 @@\n keep\n-change\n+changed\n")
+	if err != nil {
+		t.Fatalf("apply failed: %v", err)
+	}
+	if got := readTestFile(t, dir, "new.txt"); got != "keep\nchanged\n" {
+		t.Errorf("new.txt has wrong content: %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "old.txt")); !os.IsNotExist(err) {
+		t.Error("expected old.txt to be removed")
+	}
+}
+
+func TestApplyPatch_Rejections(t *testing.T) {
+	dir := t.TempDir()
+	chdirTemp(t, dir)
+	writeTestFiles(t, dir, map[string]string{"exists.txt": "x\n"})
+
+	tests := []struct {
+		name    string
+		patch   string
+		wantErr string
+	}{
+		{"empty", "", "patch is required"},
+		{"no changes", "just some prose", "no file changes found"},
+		{"outside project", "--- a/../../etc/passwd\n+++ b/../../etc/passwd\n@@ -1 +1 @@\n-root\n+evil\n", "access denied"},
+		{"create existing", "--- /dev/null\n+++ b/exists.txt\n@@ -0,0 +1 @@\n+y\n", "already exists"},
+		{"missing file", "--- a/missing.txt\n+++ b/missing.txt\n@@ -1 +1 @@\n-a\n+b\n", "missing.txt does not exist"},
+		{"unterminated block", "exists.txt\n<<<<<<< SEARCH\nx\n", "missing ======= or >>>>>>> REPLACE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := applyTestPatch(t, tt.patch)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestChangedPaths(t *testing.T) {
+	patch := "--- a/x.go\n+++ b/y.go\n@@ -1 +1 @@\n-a\n+b\n--- /dev/null\n+++ b/z.go\n@@ -0,0 +1 @@\n+z\n"
+	if got := strings.Join(ChangedPaths("apply_patch", map[string]any{"patch": patch}), ","); got != "x.go,y.go,z.go" {
+		t.Errorf("unexpected apply_patch paths: %s", got)
+	}
+	if got := ChangedPaths("edit_file", map[string]any{"path": "a.go"}); len(got) != 1 || got[0] != "a.go" {
+		t.Errorf("unexpected edit_file paths: %v", got)
+	}
+	if got := ChangedPaths("read_file", map[string]any{"path": "a.go"}); got != nil {
+		t.Errorf("expected nil for read-only tool, got %v", got)
+	}
+}
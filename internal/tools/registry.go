@@ -2,7 +2,9 @@ package tools
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"sort"
@@ -42,17 +44,43 @@ type Tool interface {
 	Permission() PermissionLevel
 }
 
+// FileChangeListener is implemented by tools that keep state derived from
+// files on disk (e.g. a language server session) and must be told when the
+// agent modifies a file.
+type FileChangeListener interface {
+	NotifyFileChanged(path string)
+}
+
+// ChangedPaths returns the files a tool call will modify, as written in its
+// input, or nil for tools that do not write files.
+func ChangedPaths(name string, input map[string]any) []string {
+	switch name {
+	case "write_file", "edit_file":
+		if path, ok := input["path"].(string); ok && path != "" {
+			return []string{path}
+		}
+	case "apply_patch":
+		patch, _ := input["patch"].(string)
+		paths, _ := PatchTargets(patch)
+		return paths
+	}
+	return nil
+}
+
 // Registry manages available tools
 type Registry struct {
-	tools map[string]Tool
-	mu    sync.RWMutex
+	tools     map[string]Tool
+	workspace *Workspace // Shared by the registry's file tools
+	mu        sync.RWMutex
 }
 
 // NewRegistry creates a new tool registry with default tools
 // cfg can be nil to use default settings (all tools enabled)
 func NewRegistry(cfg *config.ToolsConfig) *Registry {
+	ws := &Workspace{}
 	r := &Registry{
-		tools: make(map[string]Tool),
+		tools:     make(map[string]Tool),
+		workspace: ws,
 	}
 
 	// Register vecgrep tools if enabled (or if no config provided)
@@ -67,22 +95,46 @@ func NewRegistry(cfg *config.ToolsConfig) *Registry {
 	}
 
 	// Core file tools (always enabled)
-	r.Register(&ReadFileTool{})
-	r.Register(&WriteFileTool{})
-	r.Register(&EditFileTool{})
-	r.Register(&ListFilesTool{})
+	r.Register(&ReadFileTool{Workspace: ws})
+	r.Register(&WriteFileTool{Workspace: ws})
+	r.Register(&EditFileTool{Workspace: ws})
+	r.Register(&ApplyPatchTool{Workspace: ws})
+	r.Register(&ListFilesTool{Workspace: ws})
 	cwd, _ := os.Getwd()
-	r.Register(&BashTool{
-		Sandbox:    DetectSandbox(),
+	var bashConfig config.BashToolConfig
+	sandboxConfig := config.DefaultConfig().Tools.Sandbox
+	if cfg != nil {
+		bashConfig = cfg.Bash
+		sandboxConfig = cfg.Sandbox
+	}
+	// Invalid rules are reported when the config is loaded
+	policy, _ := NewCommandPolicy(bashConfig)
+	var sandbox Sandbox
+	if sandboxConfig.Enabled {
+		sandbox = NewSandbox(sandboxConfig)
+	}
+	bash := &BashTool{
+		Sandbox:    sandbox,
 		ProjectDir: cwd,
-	})
-	r.Register(&GrepTool{})
+		Policy:     policy,
+	}
+	r.Register(bash)
+	// Background jobs run through the bash tool's policy and sandbox
+	jobs := NewJobManager(bash)
+	r.Register(&BashBackgroundTool{Jobs: jobs})
+	r.Register(&JobOutputTool{Jobs: jobs})
+	r.Register(&JobStatusTool{Jobs: jobs})
+	r.Register(&JobKillTool{Jobs: jobs})
+	r.Register(&GrepTool{Workspace: ws})
 
-	// Smart tools for Go development (always enabled)
-	r.Register(&ASTTool{})
-	r.Register(&LSPTool{})
-	r.Register(&LinterTool{})
-	r.Register(&TestRunnerTool{})
+	// Smart tools for Go development (always enabled); lsp_query also
+	// covers any language configured under tools.lsp.servers
+	r.Register(&ASTTool{Workspace: ws})
+	lspTool := NewLSPTool(cwd, LSPServersFromConfig(cfg))
+	lspTool.Workspace = ws
+	r.Register(lspTool)
+	r.Register(&LinterTool{Workspace: ws})
+	r.Register(&TestRunnerTool{Workspace: ws})
 
 	// Register gpeek tools if enabled (or if no config provided)
 	if cfg == nil || cfg.Gpeek.Enabled {
@@ -119,8 +171,10 @@ func NewRegistry(cfg *config.ToolsConfig) *Registry {
 // This is used in analysis mode to reduce token consumption and prevent modifications
 // cfg can be nil to use default settings (all tools enabled)
 func NewAnalysisRegistry(cfg *config.ToolsConfig) *Registry {
+	ws := &Workspace{}
 	r := &Registry{
-		tools: make(map[string]Tool),
+		tools:     make(map[string]Tool),
+		workspace: ws,
 	}
 
 	// Read-only vecgrep tools if enabled
@@ -131,13 +185,16 @@ func NewAnalysisRegistry(cfg *config.ToolsConfig) *Registry {
 	}
 
 	// Core read-only tools (always enabled)
-	r.Register(&ReadFileTool{})
-	r.Register(&ListFilesTool{})
-	r.Register(&GrepTool{})
+	r.Register(&ReadFileTool{Workspace: ws})
+	r.Register(&ListFilesTool{Workspace: ws})
+	r.Register(&GrepTool{Workspace: ws})
 
 	// Smart tools (read-only subset)
-	r.Register(&ASTTool{})
-	r.Register(&LSPTool{})
+	cwd, _ := os.Getwd()
+	r.Register(&ASTTool{Workspace: ws})
+	lspTool := NewLSPTool(cwd, LSPServersFromConfig(cfg))
+	lspTool.Workspace = ws
+	r.Register(lspTool)
 
 	// Git visualization tools (all read-only) if enabled
 	if cfg == nil || cfg.Gpeek.Enabled {
@@ -163,7 +220,8 @@ func NewAnalysisRegistry(cfg *config.ToolsConfig) *Registry {
 // Useful for tests that need to register only specific mock tools.
 func NewEmptyRegistry() *Registry {
 	return &Registry{
-		tools: make(map[string]Tool),
+		tools:     make(map[string]Tool),
+		workspace: &Workspace{},
 	}
 }
 
@@ -203,7 +261,93 @@ func (r *Registry) Execute(ctx context.Context, name string, input map[string]an
 	if !ok {
 		return "", fmt.Errorf("unknown tool: %s", name)
 	}
-	return tool.Execute(ctx, input)
+	result, err := tool.Execute(ctx, input)
+	if err == nil {
+		for _, path := range ChangedPaths(name, input) {
+			r.NotifyFileChanged(path)
+		}
+	}
+	return result, err
+}
+
+// NotifyFileChanged informs every FileChangeListener tool that path was modified.
+func (r *Registry) NotifyFileChanged(path string) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, tool := range r.tools {
+		if l, ok := tool.(FileChangeListener); ok {
+			l.NotifyFileChanged(path)
+		}
+	}
+}
+
+// Close releases resources held by tools that implement io.Closer
+// (long-lived subprocesses such as language servers and background jobs).
+func (r *Registry) Close() error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var errs []error
+	for _, tool := range r.tools {
+		if c, ok := tool.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", tool.Name(), err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// SetWorkspace points the registry's file tool path resolution, the bash
+// tool's working directory and the language servers at dir, e.g. an
+// isolated git worktree. An empty dir restores the project root. Other
+// registries are not affected. Call it between tasks, not while tools are
+// executing.
+func (r *Registry) SetWorkspace(dir string) error {
+	if err := r.workspace.Set(dir); err != nil {
+		return err
+	}
+	if dir == "" {
+		root, err := getProjectRoot()
+		if err != nil {
+			return err
+		}
+		dir = root
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if bash, ok := r.tools["bash"].(*BashTool); ok {
+		bash.ProjectDir = dir
+	}
+	if lsp, ok := r.tools["lsp_query"].(*LSPTool); ok {
+		lsp.SetRoot(dir)
+	}
+	return nil
+}
+
+// Workspace returns the workspace the registry's tools resolve paths against.
+func (r *Registry) Workspace() *Workspace {
+	return r.workspace
+}
+
+// SetProjectReadOnly makes the bash tool's sandbox mount the project
+// read-only, e.g. in Ask and Plan modes. Sandboxes that cannot do so ignore it.
+func (r *Registry) SetProjectReadOnly(readOnly bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if bash, ok := r.tools["bash"].(*BashTool); ok {
+		bash.SetProjectReadOnly(readOnly)
+	}
+}
+
+// Jobs returns the manager behind the background job tools, or nil if they
+// are not registered.
+func (r *Registry) Jobs() *JobManager {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if bg, ok := r.tools["bash_background"].(*BashBackgroundTool); ok {
+		return bg.Jobs
+	}
+	return nil
 }
 
 // GetDefinitions returns tool definitions for the LLM
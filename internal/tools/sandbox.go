@@ -1,5 +1,11 @@
 package tools
 
+import (
+	"os"
+
+	"github.com/abdul-hamid-achik/vecai/internal/config"
+)
+
 // Sandbox wraps command execution with OS-level sandboxing
 type Sandbox interface {
 	// Wrap takes a command and returns the sandboxed executable, args, and error
@@ -28,3 +34,32 @@ func DetectSandbox() Sandbox {
 	}
 	return &NoopSandbox{}
 }
+
+// NewSandbox returns the best available sandbox for the current OS,
+// configured with cfg where the sandbox supports it.
+func NewSandbox(cfg config.SandboxConfig) Sandbox {
+	s := DetectSandbox()
+	if c, ok := s.(configurableSandbox); ok {
+		return c.withConfig(cfg)
+	}
+	return s
+}
+
+// configurableSandbox is implemented by sandboxes that take settings from
+// the sandbox config.
+type configurableSandbox interface {
+	withConfig(cfg config.SandboxConfig) Sandbox
+}
+
+// ReadOnlySandbox is implemented by sandboxes that can mount the project
+// directory read-only.
+type ReadOnlySandbox interface {
+	WrapReadOnly(command string, projectDir string) (string, []string, error)
+}
+
+// LimitReporter is implemented by sandboxes that enforce resource limits.
+type LimitReporter interface {
+	// LimitHit returns the limit that most likely ended a failed command,
+	// e.g. "CPU time limit (60s)", or "" if it was not stopped by a limit.
+	LimitHit(state *os.ProcessState, stderr string) string
+}
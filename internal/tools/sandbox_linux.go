@@ -2,40 +2,203 @@
 
 package tools
 
-import "os/exec"
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/abdul-hamid-achik/vecai/internal/config"
+)
 
 // LinuxSandbox uses bubblewrap (bwrap) to sandbox commands on Linux.
-type LinuxSandbox struct{}
+type LinuxSandbox struct {
+	AllowNet bool                 // Share the host network; otherwise the command gets its own
+	Limits   config.SandboxLimits // Resource limits; zero fields are unlimited
+}
 
 func (s *LinuxSandbox) Wrap(command string, projectDir string) (string, []string, error) {
+	return s.wrap(command, projectDir, false)
+}
+
+// WrapReadOnly is Wrap with the project directory mounted read-only.
+func (s *LinuxSandbox) WrapReadOnly(command string, projectDir string) (string, []string, error) {
+	return s.wrap(command, projectDir, true)
+}
+
+func (s *LinuxSandbox) wrap(command, projectDir string, readOnly bool) (string, []string, error) {
 	args := []string{
 		"--unshare-pid",
 		"--die-with-parent",
-
+	}
+	if !s.AllowNet {
+		args = append(args, "--unshare-net")
+	}
+	projectBind := "--bind"
+	if readOnly {
+		projectBind = "--ro-bind"
+	}
+	args = append(args,
 		// Bind-mount essential read-only paths
 		"--ro-bind", "/usr", "/usr",
 		"--ro-bind", "/bin", "/bin",
 		"--ro-bind", "/lib", "/lib",
 		"--ro-bind", "/etc", "/etc",
 		"--symlink", "usr/lib64", "/lib64",
-
 		// Proc and dev
 		"--proc", "/proc",
 		"--dev", "/dev",
-
 		// Temp directory
 		"--tmpfs", "/tmp",
-
-		// Bind-mount project directory read-write
-		"--bind", projectDir, projectDir,
-
+		// Bind-mount project directory (read-write unless readOnly)
+		projectBind, projectDir, projectDir,
 		// Set working directory
 		"--chdir", projectDir,
+	)
+
+	// Run bash with the command, under rlimits when any are set
+	cgroups := s.useCgroups()
+	if ulimits := s.ulimits(cgroups); ulimits != "" {
+		args = append(args, "bash", "-c", ulimits+` && exec bash -c "$1"`, "vecai", command)
+	} else {
+		args = append(args, "bash", "-c", command)
+	}
+
+	if !cgroups {
+		return "bwrap", args, nil
+	}
+	// Memory and process limits go in a transient cgroup
+	scope := []string{"--user", "--scope", "--quiet", "--collect"}
+	if s.Limits.MaxProcesses > 0 {
+		scope = append(scope, "-p", fmt.Sprintf("TasksMax=%d", s.Limits.MaxProcesses))
+	}
+	if s.Limits.MemoryMB == 0 {
+		return "systemd-run", append(append(scope, "--", "bwrap"), args...), nil
+	}
+	scope = append(scope, "-p", fmt.Sprintf("MemoryMax=%dM", s.Limits.MemoryMB), "-p", "MemorySwapMax=0")
+	if scopeOOMPolicy() {
+		// Keep the scope running after an OOM kill so the check below can report it
+		scope = append(scope, "-p", "OOMPolicy=continue")
+	}
+	scope = append(scope, "--", "bash", "-c", oomReport, "vecai", "bwrap")
+	return "systemd-run", append(scope, args...), nil
+}
 
-		// Run bash with the command
-		"bash", "-c", command,
+// oomReport runs a command and, if the kernel OOM-killed anything in its
+// cgroup, says so on stderr, where LimitHit looks for it.
+const oomReport = `"$@"; status=$?; ` +
+	`if grep -qs '^oom_kill [1-9]' "/sys/fs/cgroup$(sed -n 's/^0:://p' /proc/self/cgroup)/memory.events"; then ` +
+	`echo "vecai: sandbox: out of memory" >&2; fi; exit $status`
+
+// cpuGraceSeconds is the CPU time between SIGXCPU at the soft limit and
+// SIGKILL at the hard one.
+const cpuGraceSeconds = 5
+
+// ulimits returns the bash ulimit commands for the configured limits.
+// Memory and process limits are left to the cgroup when there is one.
+func (s *LinuxSandbox) ulimits(cgroups bool) string {
+	var flags, cmds []string
+	l := s.Limits
+	if l.CPUSeconds > 0 {
+		// A soft limit below the hard one makes the kernel send SIGXCPU,
+		// so the limit can be told apart from other kills. The soft limit
+		// goes first since the hard one cannot drop below it.
+		cmds = append(cmds, fmt.Sprintf("ulimit -S -t %d", l.CPUSeconds), fmt.Sprintf("ulimit -H -t %d", l.CPUSeconds+cpuGraceSeconds))
+	}
+	if l.FileSizeMB > 0 {
+		flags = append(flags, fmt.Sprintf("-f %d", l.FileSizeMB*1024)) // KB
 	}
-	return "bwrap", args, nil
+	if l.MemoryMB > 0 && !cgroups {
+		flags = append(flags, fmt.Sprintf("-v %d", l.MemoryMB*1024)) // KB
+	}
+	if l.MaxProcesses > 0 && !cgroups {
+		flags = append(flags, fmt.Sprintf("-u %d", l.MaxProcesses))
+	}
+	if len(flags) > 0 {
+		cmds = append(cmds, "ulimit "+strings.Join(flags, " "))
+	}
+	return strings.Join(cmds, " && ")
+}
+
+// useCgroups reports whether memory and process limits can go in a cgroup.
+func (s *LinuxSandbox) useCgroups() bool {
+	return (s.Limits.MemoryMB > 0 || s.Limits.MaxProcesses > 0) && userScopesAvailable()
+}
+
+// userScopesAvailable reports whether systemd-run can start transient user
+// scopes, which cgroup v2 limits need. The probe runs once.
+var userScopesAvailable = sync.OnceValue(func() bool {
+	if _, err := exec.LookPath("systemd-run"); err != nil {
+		return false
+	}
+	return exec.Command("systemd-run", "--user", "--scope", "--quiet", "--collect", "true").Run() == nil
+})
+
+// scopeOOMPolicy reports whether transient scopes take OOMPolicy, which
+// systemd 253 added. Older versions never stop a scope on an OOM kill.
+var scopeOOMPolicy = sync.OnceValue(func() bool {
+	return exec.Command("systemd-run", "--user", "--scope", "--quiet", "--collect", "-p", "OOMPolicy=continue", "true").Run() == nil
+})
+
+// LimitHit names the limit that ended a command, from the signal that
+// killed it or, for memory and process limits, from its error output.
+// A SIGKILL is only put down to memory when the output shows an OOM kill;
+// otherwise it is the hard CPU limit of a command that ignored SIGXCPU.
+func (s *LinuxSandbox) LimitHit(state *os.ProcessState, stderr string) string {
+	if state == nil {
+		return ""
+	}
+	sig := exitSignal(state)
+	l := s.Limits
+	lower := strings.ToLower(stderr)
+	oom := l.MemoryMB > 0 && outOfMemory(lower)
+	switch {
+	case l.CPUSeconds > 0 && (sig == syscall.SIGXCPU || sig == syscall.SIGKILL && !oom):
+		return fmt.Sprintf("CPU time limit (%ds)", l.CPUSeconds)
+	case sig == syscall.SIGXFSZ && l.FileSizeMB > 0:
+		return fmt.Sprintf("file size limit (%d MB)", l.FileSizeMB)
+	case oom:
+		return fmt.Sprintf("memory limit (%d MB)", l.MemoryMB)
+	case l.MaxProcesses > 0 && outOfProcesses(lower):
+		return fmt.Sprintf("process limit (%d)", l.MaxProcesses)
+	}
+	return ""
+}
+
+// exitSignal returns the signal that ended a process. bash and bwrap report
+// a child killed by signal N as exit status 128+N.
+func exitSignal(state *os.ProcessState) syscall.Signal {
+	ws, ok := state.Sys().(syscall.WaitStatus)
+	if !ok {
+		return 0
+	}
+	if ws.Signaled() {
+		return ws.Signal()
+	}
+	if code := ws.ExitStatus(); code > 128 && code < 128+65 {
+		return syscall.Signal(code - 128)
+	}
+	return 0
+}
+
+func outOfMemory(stderr string) bool {
+	for _, s := range []string{"out of memory", "cannot allocate memory", "memoryerror", "bad_alloc"} {
+		if strings.Contains(stderr, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func outOfProcesses(stderr string) bool {
+	return strings.Contains(stderr, "fork") && (strings.Contains(stderr, "resource temporarily unavailable") || strings.Contains(stderr, "retry")) ||
+		strings.Contains(stderr, "failed to create new os thread")
+}
+
+func (s *LinuxSandbox) withConfig(cfg config.SandboxConfig) Sandbox {
+	return &LinuxSandbox{AllowNet: cfg.AllowNet, Limits: cfg.Limits}
 }
 
 func (s *LinuxSandbox) Available() bool {
@@ -0,0 +1,141 @@
+//go:build linux
+
+package tools
+
+import (
+	"os/exec"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/abdul-hamid-achik/vecai/internal/config"
+)
+
+func TestLinuxSandbox_Wrap(t *testing.T) {
+	s := &LinuxSandbox{}
+	exe, args, err := s.Wrap("echo hello", "/tmp/project")
+	if err != nil {
+		t.Fatalf("Wrap returned error: %v", err)
+	}
+	if exe != "bwrap" {
+		t.Errorf("expected exe 'bwrap', got %q", exe)
+	}
+	if !slices.Contains(args, "--unshare-net") {
+		t.Errorf("expected the network to be unshared, got %v", args)
+	}
+	if i := slices.Index(args, "--bind"); i < 0 || args[i+1] != "/tmp/project" {
+		t.Errorf("expected a read-write project bind, got %v", args)
+	}
+	if tail := args[len(args)-3:]; !slices.Equal(tail, []string{"bash", "-c", "echo hello"}) {
+		t.Errorf("expected the command last, got %v", tail)
+	}
+
+	s.AllowNet = true
+	_, args, _ = s.Wrap("echo hello", "/tmp/project")
+	if slices.Contains(args, "--unshare-net") {
+		t.Errorf("expected the host network with allow_net, got %v", args)
+	}
+}
+
+func TestLinuxSandbox_WrapReadOnly(t *testing.T) {
+	_, args, err := (&LinuxSandbox{}).WrapReadOnly("ls", "/tmp/project")
+	if err != nil {
+		t.Fatalf("WrapReadOnly returned error: %v", err)
+	}
+	if slices.Contains(args, "--bind") {
+		t.Errorf("expected no read-write bind, got %v", args)
+	}
+	if i := slices.Index(args, "--chdir"); i < 3 || args[i-3] != "--ro-bind" || args[i-2] != "/tmp/project" {
+		t.Errorf("expected a read-only project bind, got %v", args)
+	}
+}
+
+func TestLinuxSandbox_Limits(t *testing.T) {
+	ls := &LinuxSandbox{Limits: config.SandboxLimits{CPUSeconds: 1, FileSizeMB: 2}}
+	_, args, err := ls.Wrap("while :; do :; done", "/tmp/project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := slices.Index(args, "bash")
+	if i < 0 || !strings.HasPrefix(args[i+2], "ulimit -S -t 1 && ulimit -H -t 6 && ulimit -f 2048 && ") {
+		t.Fatalf("expected the limits before the command, got %v", args[i:])
+	}
+
+	// Run the wrapped command without bwrap to hit the CPU limit
+	cmd := exec.Command(args[i], args[i+1:]...)
+	if err := cmd.Run(); err == nil {
+		t.Fatal("expected the busy loop to be killed")
+	}
+	if got := ls.LimitHit(cmd.ProcessState, ""); got != "CPU time limit (1s)" {
+		t.Errorf("expected the CPU limit, got %q", got)
+	}
+}
+
+func TestLinuxSandbox_LimitHit(t *testing.T) {
+	s := &LinuxSandbox{Limits: config.SandboxLimits{FileSizeMB: 10, MemoryMB: 512, MaxProcesses: 64}}
+	run := func(script string) *exec.Cmd {
+		cmd := exec.Command("bash", "-c", script)
+		_ = cmd.Run()
+		return cmd
+	}
+
+	tests := []struct {
+		script, stderr, want string
+	}{
+		{"exit 153", "", "file size limit (10 MB)"},      // bash reports SIGXFSZ as 128+25
+		{"kill -XFSZ $$", "", "file size limit (10 MB)"}, // killed directly
+		{"exit 2", "fatal error: runtime: out of memory", "memory limit (512 MB)"},
+		{"kill -KILL $$", "vecai: sandbox: out of memory", "memory limit (512 MB)"},
+		{"kill -KILL $$", "", ""}, // killed, but not for memory
+		{"exit 254", "bash: fork: retry: Resource temporarily unavailable", "process limit (64)"},
+		{"kill -XCPU $$", "", ""}, // no CPU limit configured
+		{"exit 1", "FAIL", ""},
+	}
+	for _, tt := range tests {
+		if got := s.LimitHit(run(tt.script).ProcessState, tt.stderr); got != tt.want {
+			t.Errorf("%q with %q: got %q, want %q", tt.script, tt.stderr, got, tt.want)
+		}
+	}
+
+	// The hard CPU limit kills a command that ignores SIGXCPU
+	s.Limits.CPUSeconds = 30
+	if got := s.LimitHit(run("kill -KILL $$").ProcessState, ""); got != "CPU time limit (30s)" {
+		t.Errorf("expected a plain SIGKILL to be the CPU limit, got %q", got)
+	}
+	if got := s.LimitHit(run("kill -KILL $$").ProcessState, "vecai: sandbox: out of memory"); got != "memory limit (512 MB)" {
+		t.Errorf("expected an OOM kill to be the memory limit, got %q", got)
+	}
+}
+
+func TestLinuxSandbox_Cgroups(t *testing.T) {
+	scopes, policy := userScopesAvailable, scopeOOMPolicy
+	t.Cleanup(func() { userScopesAvailable, scopeOOMPolicy = scopes, policy })
+	userScopesAvailable = func() bool { return true }
+	scopeOOMPolicy = func() bool { return true }
+
+	s := &LinuxSandbox{Limits: config.SandboxLimits{MemoryMB: 256, MaxProcesses: 32}}
+	exe, args, err := s.Wrap("echo hello", "/tmp/project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exe != "systemd-run" || !slices.Contains(args, "MemoryMax=256M") || !slices.Contains(args, "TasksMax=32") || !slices.Contains(args, "OOMPolicy=continue") {
+		t.Errorf("expected the limits on the scope, got %v", args)
+	}
+	i := slices.Index(args, oomReport)
+	if i < 2 || args[i-1] != "-c" || args[i+2] != "bwrap" {
+		t.Fatalf("expected bwrap under the OOM check, got %v", args)
+	}
+
+	// The check keeps the command's output and exit status
+	cmd := exec.Command("bash", "-c", oomReport, "vecai", "bash", "-c", "echo hello; exit 3")
+	out, _ := cmd.Output()
+	if string(out) != "hello\n" || cmd.ProcessState.ExitCode() != 3 {
+		t.Errorf("expected the command's output and status, got %q and %d", out, cmd.ProcessState.ExitCode())
+	}
+
+	s.Limits.MemoryMB = 0
+	_, args, _ = s.Wrap("echo hello", "/tmp/project")
+	if slices.Contains(args, oomReport) {
+		t.Errorf("expected no OOM check without a memory limit, got %v", args)
+	}
+}
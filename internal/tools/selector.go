@@ -21,8 +21,8 @@ type ToolCategory string
 const (
 	CategoryCore     ToolCategory = "core"     // Always included: vecgrep, read_file, list_files, grep
 	CategoryGit      ToolCategory = "git"      // Git tools: gpeek_*
-	CategoryWrite    ToolCategory = "write"    // Write tools: write_file, edit_file
-	CategoryExecute  ToolCategory = "execute"  // Execute tools: bash
+	CategoryWrite    ToolCategory = "write"    // Write tools: write_file, edit_file, apply_patch
+	CategoryExecute  ToolCategory = "execute"  // Execute tools: bash, bash_background, job_*
 	CategoryWeb      ToolCategory = "web"      // Web tools: web_search
 	CategoryDev      ToolCategory = "dev"      // Dev tools: ast_parse, lsp_query, lint, test_run
 	CategoryMemory   ToolCategory = "memory"   // Memory tools: noted_remember, noted_recall, noted_forget
@@ -60,11 +60,16 @@ var GitTools = []string{
 var WriteTools = []string{
 	"write_file",
 	"edit_file",
+	"apply_patch",
 }
 
 // ExecuteTools are included when query mentions running/executing
 var ExecuteTools = []string{
 	"bash",
+	"bash_background",
+	"job_output",
+	"job_status",
+	"job_kill",
 }
 
 // WebTools are included when query mentions web/search
@@ -102,7 +107,8 @@ var writeKeywords = []string{
 // executeKeywords trigger inclusion of execute tools
 var executeKeywords = []string{
 	"run", "execute", "test", "build", "install", "npm", "go run",
-	"make", "shell", "command", "script",
+	"make", "shell", "command", "script", "server", "watch", "background",
+	"job",
 }
 
 // webKeywords trigger inclusion of web tools
@@ -165,6 +171,14 @@ func (ts *ToolSelector) SelectTools(query string) []ToolDefinition {
 		}
 	}
 
+	// MCP server tools were configured explicitly, so always offer them.
+	// recall_tool_result expands results compressed earlier, whatever the query.
+	for _, tool := range ts.registry.List() {
+		if IsMCPTool(tool.Name()) || tool.Name() == "recall_tool_result" {
+			toolNames[tool.Name()] = true
+		}
+	}
+
 	// Build definitions from registry
 	var defs []ToolDefinition
 	for name := range toolNames {
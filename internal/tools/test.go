@@ -7,13 +7,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"os/exec"
-	"path/filepath"
 	"strings"
 	"time"
 )
 
 // TestRunnerTool runs go test and parses the results
-type TestRunnerTool struct{}
+type TestRunnerTool struct {
+	Workspace *Workspace // Root for paths; nil means the project root
+}
 
 func (t *TestRunnerTool) Name() string {
 	return "test_run"
@@ -122,7 +123,7 @@ func (t *TestRunnerTool) Execute(ctx context.Context, input map[string]any) (str
 	absPath := path
 	if !strings.HasPrefix(path, "./") && path != "./..." {
 		var err error
-		absPath, err = filepath.Abs(path)
+		absPath, err = t.Workspace.ResolvePath(path)
 		if err != nil {
 			return "", fmt.Errorf("invalid path: %w", err)
 		}
@@ -155,6 +156,7 @@ func (t *TestRunnerTool) Execute(ctx context.Context, input map[string]any) (str
 	defer cancel()
 
 	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Dir = t.Workspace.Dir()
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
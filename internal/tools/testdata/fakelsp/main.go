@@ -0,0 +1,170 @@
+// Command fakelsp is a minimal language server used by the lsp_query tests.
+// It tracks opened documents and answers hover, definition and references by
+// looking up the identifier under the cursor in those documents.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+}
+
+type position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type positionParams struct {
+	TextDocument struct {
+		URI string `json:"uri"`
+	} `json:"textDocument"`
+	Position position `json:"position"`
+}
+
+type location struct {
+	URI   string `json:"uri"`
+	Range struct {
+		Start position `json:"start"`
+		End   position `json:"end"`
+	} `json:"range"`
+}
+
+var (
+	out  = bufio.NewWriter(os.Stdout)
+	docs = map[string][]string{} // URI -> lines
+	lang = map[string]string{}   // URI -> languageId
+)
+
+func main() {
+	in := bufio.NewReader(os.Stdin)
+	tp := textproto.NewReader(in)
+	for {
+		header, err := tp.ReadMIMEHeader()
+		if err != nil {
+			return
+		}
+		n, _ := strconv.Atoi(header.Get("Content-Length"))
+		body := make([]byte, n)
+		if _, err := io.ReadFull(in, body); err != nil {
+			return
+		}
+		var msg message
+		if err := json.Unmarshal(body, &msg); err != nil {
+			continue
+		}
+		if msg.Method == "exit" {
+			return
+		}
+		result := handle(msg)
+		if msg.ID != nil {
+			send(map[string]any{"jsonrpc": "2.0", "id": msg.ID, "result": result})
+		}
+	}
+}
+
+func handle(msg message) any {
+	switch msg.Method {
+	case "initialize":
+		return map[string]any{"capabilities": map[string]any{"hoverProvider": true}}
+	case "textDocument/didOpen":
+		var p struct {
+			TextDocument struct {
+				URI        string `json:"uri"`
+				LanguageID string `json:"languageId"`
+				Text       string `json:"text"`
+			} `json:"textDocument"`
+		}
+		_ = json.Unmarshal(msg.Params, &p)
+		docs[p.TextDocument.URI] = strings.Split(p.TextDocument.Text, "\n")
+		lang[p.TextDocument.URI] = p.TextDocument.LanguageID
+	case "textDocument/hover":
+		var p positionParams
+		_ = json.Unmarshal(msg.Params, &p)
+		word := wordAt(p.TextDocument.URI, p.Position)
+		if word == "" {
+			return nil
+		}
+		return map[string]any{"contents": map[string]any{
+			"kind":  "markdown",
+			"value": fmt.Sprintf("%s symbol `%s`", lang[p.TextDocument.URI], word),
+		}}
+	case "textDocument/definition":
+		var p positionParams
+		_ = json.Unmarshal(msg.Params, &p)
+		if locs := occurrences(p.TextDocument.URI, wordAt(p.TextDocument.URI, p.Position)); len(locs) > 0 {
+			return locs[0]
+		}
+	case "textDocument/references":
+		var p positionParams
+		_ = json.Unmarshal(msg.Params, &p)
+		return occurrences(p.TextDocument.URI, wordAt(p.TextDocument.URI, p.Position))
+	}
+	return nil
+}
+
+func send(v any) {
+	body, _ := json.Marshal(v)
+	fmt.Fprintf(out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	_ = out.Flush()
+}
+
+func isIdent(r byte) bool {
+	return r == '_' || unicode.IsLetter(rune(r)) || unicode.IsDigit(rune(r))
+}
+
+// wordAt returns the identifier at pos (ASCII test files only).
+func wordAt(uri string, pos position) string {
+	lines := docs[uri]
+	if pos.Line >= len(lines) {
+		return ""
+	}
+	line := lines[pos.Line]
+	start, end := pos.Character, pos.Character
+	for start > 0 && start <= len(line) && isIdent(line[start-1]) {
+		start--
+	}
+	for end < len(line) && isIdent(line[end]) {
+		end++
+	}
+	return line[start:end]
+}
+
+// occurrences finds every whole-word use of word in the document.
+func occurrences(uri, word string) []location {
+	var locs []location
+	if word == "" {
+		return locs
+	}
+	for i, line := range docs[uri] {
+		for off := 0; ; {
+			idx := strings.Index(line[off:], word)
+			if idx < 0 {
+				break
+			}
+			col := off + idx
+			off = col + len(word)
+			if (col > 0 && isIdent(line[col-1])) || (off < len(line) && isIdent(line[off])) {
+				continue
+			}
+			var loc location
+			loc.URI = uri
+			loc.Range.Start = position{Line: i, Character: col}
+			loc.Range.End = position{Line: i, Character: off}
+			locs = append(locs, loc)
+		}
+	}
+	return locs
+}
@@ -51,6 +51,7 @@ func TestRegistry(t *testing.T) {
 		"read_file",
 		"write_file",
 		"edit_file",
+		"apply_patch",
 		"list_files",
 		"bash",
 		"grep",
@@ -83,12 +84,12 @@ func TestRegistryList(t *testing.T) {
 	r := NewRegistry(nil)
 	tools := r.List()
 
-	// Base count is 27, plus up to 4 optional tools:
+	// Base count is 32, plus up to 4 optional tools:
 	// - web_search (if TAVILY_API_KEY is set)
 	// - noted_remember, noted_recall, noted_forget (if noted CLI is installed)
-	// Tools: vecgrep(7) + file(4) + bash(1) + grep(1) + gpeek(10) + smart(4) = 27
-	minExpected := 27
-	maxExpected := 31 // 27 + 1 (web) + 3 (noted)
+	// Tools: vecgrep(7) + file(5) + bash(1) + jobs(4) + grep(1) + gpeek(10) + smart(4) = 32
+	minExpected := 32
+	maxExpected := 36 // 32 + 1 (web) + 3 (noted)
 	if len(tools) < minExpected || len(tools) > maxExpected {
 		t.Errorf("expected %d-%d tools, got %d", minExpected, maxExpected, len(tools))
 	}
@@ -98,10 +99,10 @@ func TestRegistryGetDefinitions(t *testing.T) {
 	r := NewRegistry(nil)
 	defs := r.GetDefinitions()
 
-	// Base count is 27, plus up to 4 optional tools
-	// Tools: vecgrep(7) + file(4) + bash(1) + grep(1) + gpeek(10) + smart(4) = 27
-	minExpected := 27
-	maxExpected := 31 // 27 + 1 (web) + 3 (noted)
+	// Base count is 28, plus up to 4 optional tools
+	// Tools: vecgrep(7) + file(5) + bash(1) + grep(1) + gpeek(10) + smart(4) = 28
+	minExpected := 28
+	maxExpected := 32 // 28 + 1 (web) + 3 (noted)
 	if len(defs) < minExpected || len(defs) > maxExpected {
 		t.Errorf("expected %d-%d definitions, got %d", minExpected, maxExpected, len(defs))
 	}
@@ -108,6 +108,24 @@ func (a *TUIAdapter) ToolResult(name string, result string, isError bool) {
 	a.streamChan <- msg
 }
 
+// SubagentToolCall outputs a subagent's tool call, nested under the task
+// that started the subagent. groupID links the call to its result and must
+// be unique, since subagents run concurrently.
+func (a *TUIAdapter) SubagentToolCall(task, groupID, name, description string) {
+	msg := NewToolCallMsg(name, description)
+	msg.GroupID = groupID
+	msg.Subagent = task
+	a.streamChan <- msg
+}
+
+// SubagentToolResult outputs the result of a subagent's tool call
+func (a *TUIAdapter) SubagentToolResult(task, groupID, name, result string, isError bool) {
+	msg := NewToolResultMsg(name, result, isError)
+	msg.GroupID = groupID
+	msg.Subagent = task
+	a.streamChan <- msg
+}
+
 // Error outputs an error message
 func (a *TUIAdapter) Error(err error) {
 	a.streamChan <- NewErrorMsg(err.Error())
@@ -298,6 +316,11 @@ func (a *TUIAdapter) SetProjectInfo(workingDir, gitBranch string) {
 	})
 }
 
+// SetJobs updates the background jobs shown in the status bar
+func (a *TUIAdapter) SetJobs(jobs []tools.JobInfo) {
+	a.streamChan <- NewJobsMsg(jobs)
+}
+
 // WaitForRateLimit implements a TUI-compatible wait callback for rate limiting.
 // It sends countdown updates through the TUI channel instead of writing to stderr.
 func (a *TUIAdapter) WaitForRateLimit(ctx context.Context, duration time.Duration, reason string, attempt, maxAttempts int) error {
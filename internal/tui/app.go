@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/abdul-hamid-achik/vecai/internal/logging"
+	"github.com/abdul-hamid-achik/vecai/internal/tools"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 )
@@ -610,9 +611,9 @@ func (m Model) handlePermissionKey(key string) (tea.Model, tea.Cmd) {
 	case "n":
 		feedback = ContentBlock{Type: BlockWarning, Content: "Denied"}
 	case "a":
-		feedback = ContentBlock{Type: BlockSuccess, Content: "Always allowed (cached)"}
+		feedback = ContentBlock{Type: BlockSuccess, Content: "Always allowed"}
 	case "v":
-		feedback = ContentBlock{Type: BlockWarning, Content: "Never allowed (cached)"}
+		feedback = ContentBlock{Type: BlockWarning, Content: "Never allowed"}
 	}
 	m.AddBlock(feedback)
 
@@ -721,6 +722,7 @@ func (m Model) handleStreamMsg(msg StreamMsg) (tea.Model, tea.Cmd) {
 			m.loopIteration = msg.Stats.LoopIteration
 			m.maxIterations = msg.Stats.MaxIterations
 			m.loopStartTime = msg.Stats.LoopStartTime
+			m.escalation = msg.Stats.Escalation
 			// Don't overwrite accumulated tokens from done messages
 		}
 		return m, m.waitForStream()
@@ -731,6 +733,9 @@ func (m Model) handleStreamMsg(msg StreamMsg) (tea.Model, tea.Cmd) {
 		if msg.ToolDesc != "" {
 			m.activityMessage = "Running: " + msg.ToolName + " - " + truncate(msg.ToolDesc, 30)
 		}
+		if msg.Subagent != "" {
+			m.activityMessage = "Running: " + truncate(msg.Subagent, 30) + " › " + msg.ToolName
+		}
 		m.state = StateStreaming
 
 		// Create tool meta with timing and category
@@ -739,6 +744,7 @@ func (m Model) handleStreamMsg(msg StreamMsg) (tea.Model, tea.Cmd) {
 			StartTime: time.Now(),
 			IsRunning: true,
 			GroupID:   msg.GroupID,
+			Subagent:  msg.Subagent,
 		}
 		// Track running tool
 		if msg.GroupID != "" {
@@ -779,11 +785,18 @@ func (m Model) handleStreamMsg(msg StreamMsg) (tea.Model, tea.Cmd) {
 					Elapsed:   callMeta.Elapsed,
 					GroupID:   msg.GroupID,
 					ResultLen: len(msg.Text),
+					Subagent:  msg.Subagent,
 				}
 				delete(m.activeTools, msg.GroupID)
 			}
 		}
 
+		// A subagent's call line shows its outcome; only failures get a block
+		if msg.Subagent != "" && !msg.IsError {
+			m.updateViewportContent()
+			return m, m.waitForStream()
+		}
+
 		// Truncate long results (UTF-8 safe)
 		result := msg.Text
 		if len(result) > 2000 {
@@ -912,6 +925,19 @@ func (m Model) handleStreamMsg(msg StreamMsg) (tea.Model, tea.Cmd) {
 		}
 		return m, m.waitForStream()
 
+	case "jobs":
+		// Note jobs that finished since the last update
+		for _, job := range msg.Jobs {
+			if job.State != tools.JobRunning && m.jobRunning(job.ID) {
+				m.AddBlock(ContentBlock{
+					Type:    BlockInfo,
+					Content: formatJobStatus(job),
+				})
+			}
+		}
+		m.jobs = msg.Jobs
+		return m, m.waitForStream()
+
 	case "session_id":
 		m.sessionID = msg.Text
 		return m, m.waitForStream()
@@ -26,7 +26,7 @@ var BuiltinCommands = []CommandDef{
 	{Name: "/skills", Description: "List available skills"},
 	{Name: "/status", Description: "Check vecgrep index status"},
 	{Name: "/reindex", Description: "Update vecgrep search index"},
-	{Name: "/context", Description: "Show context usage breakdown"},
+	{Name: "/context", Description: "Show context usage and compaction state"},
 	{Name: "/compact", Description: "Compact conversation", HasArgs: true, ArgHint: "[focus]"},
 	{Name: "/sessions", Description: "List saved sessions"},
 	{Name: "/resume", Description: "Resume a session", HasArgs: true, ArgHint: "[id]"},
@@ -13,6 +13,7 @@ type SessionStats struct {
 	LoopStartTime  time.Time     // When the current loop started
 	InputTokens    int64         // Total input tokens used
 	OutputTokens   int64         // Total output tokens used
+	Escalation     string        // Tier escalation in the current loop, e.g. "fast→smart: repeated the same tool call"
 }
 
 // RateLimitInfo contains information about the current rate limit state
@@ -25,12 +26,13 @@ type RateLimitInfo struct {
 
 // StreamMsg represents a streaming message from the LLM
 type StreamMsg struct {
-	Type          string // "text", "thinking", "done", "tool_call", "tool_result", "error", "info", "warning", "success", "permission", "clear", "model_info", "stats", "rate_limit", "rate_limit_clear", "context_stats", "mode_change"
+	Type          string // "text", "thinking", "done", "tool_call", "tool_result", "error", "info", "warning", "success", "permission", "clear", "model_info", "stats", "rate_limit", "rate_limit_clear", "context_stats", "mode_change", "jobs"
 	Text          string
 	ToolName      string
 	ToolDesc      string
 	IsError       bool
 	GroupID       string                // Links tool_call to tool_result
+	Subagent      string                // Task label of the subagent that made a tool_call or tool_result
 	Level         tools.PermissionLevel
 	Stats         *SessionStats     // Session stats (only for "stats" type)
 	Usage         *TokenUsage       // Token usage (only for "done" type)
@@ -39,6 +41,7 @@ type StreamMsg struct {
 	ProjectInfo   *ProjectInfo      // Project info (only for "project_info" type)
 	ProgressData  *ProgressInfo     // Progress info (only for "progress" type)
 	ModeInfo      *AgentMode        // Agent mode (only for "mode_change" type)
+	Jobs          []tools.JobInfo   // Background jobs (only for "jobs" type)
 }
 
 // TokenUsage represents token counts from API response
@@ -265,3 +268,8 @@ func NewPlanUpdateMsg(text string) StreamMsg {
 func NewModeChangeMsg(mode AgentMode) StreamMsg {
 	return StreamMsg{Type: "mode_change", ModeInfo: &mode}
 }
+
+// NewJobsMsg creates a background jobs update message
+func NewJobsMsg(jobs []tools.JobInfo) StreamMsg {
+	return StreamMsg{Type: "jobs", Jobs: jobs}
+}
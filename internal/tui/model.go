@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/abdul-hamid-achik/vecai/internal/logging"
+	"github.com/abdul-hamid-achik/vecai/internal/tools"
 	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
@@ -133,18 +134,19 @@ type ToolBlockMeta struct {
 	IsRunning bool
 	GroupID   string // Links tool_call to tool_result
 	ResultLen int    // Original length before truncation
+	Subagent  string // Task label when a subagent made the call
 }
 
 // classifyTool returns the category for a given tool name
 func classifyTool(name string) ToolCategory {
 	switch name {
-	case "read_file", "list_files", "grep", "ast_parse", "lsp_query",
-		"vecgrep_search", "vecgrep_similar", "vecgrep_status",
+	case "read_file", "list_files", "grep", "ast_parse", "lsp_query", "task", "job_output",
+		"recall_tool_result", "vecgrep_search", "vecgrep_similar", "vecgrep_status",
 		"vecgrep_overview", "vecgrep_related_files":
 		return ToolCategoryRead
-	case "write_file", "edit_file":
+	case "write_file", "edit_file", "apply_patch":
 		return ToolCategoryWrite
-	case "bash":
+	case "bash", "bash_background", "job_kill":
 		return ToolCategoryExecute
 	default:
 		// Default: if name contains "read", "list", "search", "get" → read
@@ -216,11 +218,15 @@ type Model struct {
 	loopIteration int       // Current loop iteration
 	maxIterations int       // Maximum loop iterations
 	loopStartTime time.Time // When the current loop started
+	escalation    string    // Tier escalation in the current loop
 
 	// Context tracking
 	contextUsage float64 // Context usage as percentage (0.0 - 1.0)
 	contextWarn  bool    // Whether context warning threshold reached
 
+	// Background jobs started by the agent
+	jobs []tools.JobInfo
+
 	// Interrupt channels for ESC during streaming
 	interruptChan      chan struct{} // Graceful interrupt (first ESC)
 	forceInterruptChan chan struct{} // Force interrupt (second ESC)
@@ -513,6 +519,7 @@ func (m *Model) GetSessionStats() SessionStats {
 		MaxIterations: m.maxIterations,
 		LoopStartTime: m.loopStartTime,
 		InputTokens:   m.inputTokens,
+		Escalation:    m.escalation,
 		OutputTokens:  m.outputTokens,
 	}
 }
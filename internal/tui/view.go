@@ -5,6 +5,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/abdul-hamid-achik/vecai/internal/tools"
 	"github.com/charmbracelet/glamour"
 	"github.com/charmbracelet/glamour/ansi"
 	"github.com/charmbracelet/lipgloss"
@@ -463,10 +464,22 @@ func (m Model) renderStatusBar() string {
 		parts = append(parts, style.Render(contextStr))
 	}
 
+	// Background jobs - show while any are running
+	if jobs := m.runningJobs(); len(jobs) == 1 {
+		jobStr := fmt.Sprintf("%s job %d: %s (%s)", iconToolExec, jobs[0].ID,
+			truncateJobCommand(jobs[0].Command, 30), formatDuration(jobs[0].Duration()))
+		parts = append(parts, statsLabelStyle.Render(jobStr))
+	} else if len(jobs) > 1 {
+		parts = append(parts, statsLabelStyle.Render(fmt.Sprintf("%s %d jobs running", iconToolExec, len(jobs))))
+	}
+
 	// Iteration count (only during active processing)
 	if m.loopIteration > 0 && m.rateLimitInfo == nil && (m.state == StateStreaming || m.state == StateRateLimited) {
 		iterStr := fmt.Sprintf("[%d/%d]", m.loopIteration, m.maxIterations)
 		parts = append(parts, statsLabelStyle.Render(iterStr))
+		if m.escalation != "" {
+			parts = append(parts, warningStyle.Render("⇧ "+m.escalation))
+		}
 	}
 
 	// Queue count - show if items queued
@@ -526,6 +539,51 @@ func (m Model) renderStatusBar() string {
 	return statusBarStyle.Width(m.width).Padding(0, 1).Render(content)
 }
 
+// runningJobs returns the background jobs that are still running
+func (m Model) runningJobs() []tools.JobInfo {
+	var running []tools.JobInfo
+	for _, job := range m.jobs {
+		if job.State == tools.JobRunning {
+			running = append(running, job)
+		}
+	}
+	return running
+}
+
+// jobRunning reports whether the job was running at the last update
+func (m Model) jobRunning(id int) bool {
+	for _, job := range m.jobs {
+		if job.ID == id {
+			return job.State == tools.JobRunning
+		}
+	}
+	return false
+}
+
+// formatJobStatus describes how a background job ended
+func formatJobStatus(job tools.JobInfo) string {
+	command := truncateJobCommand(job.Command, 60)
+	switch {
+	case job.State == tools.JobKilled:
+		return fmt.Sprintf("Job %d killed: %s", job.ID, command)
+	case job.Limit != "":
+		return fmt.Sprintf("Job %d stopped by the sandbox (%s): %s", job.ID, job.Limit, command)
+	default:
+		return fmt.Sprintf("Job %d exited with code %d: %s", job.ID, job.ExitCode, command)
+	}
+}
+
+// truncateJobCommand shortens a job's command to its first line and max runes
+func truncateJobCommand(command string, max int) string {
+	if i := strings.IndexByte(command, '\n'); i >= 0 {
+		command = command[:i] + "…"
+	}
+	if r := []rune(command); len(r) > max {
+		return string(r[:max-1]) + "…"
+	}
+	return command
+}
+
 // formatDuration formats a duration as a human-readable string
 func formatDuration(d time.Duration) string {
 	if d < time.Second {
@@ -732,6 +790,9 @@ func (m Model) renderContent() string {
 
 // renderBlock renders a single content block
 func (m Model) renderBlock(block ContentBlock) string {
+	if block.ToolMeta != nil && block.ToolMeta.Subagent != "" {
+		return m.renderSubagentToolBlock(block)
+	}
 	switch block.Type {
 	case BlockUser:
 		return m.renderUserBlock(block)
@@ -818,6 +879,21 @@ func (m Model) renderToolCallBlock(block ContentBlock) string {
 	return rendered
 }
 
+// renderSubagentToolBlock renders a subagent's tool call, or its failure,
+// on one line indented under the task call that started the subagent
+func (m Model) renderSubagentToolBlock(block ContentBlock) string {
+	prefix := "    " + toolResultBorderStyle.Render("↳") + " " + toolDescStyle.Render(truncate(block.ToolMeta.Subagent, 30)+" ›") + " "
+	if block.Type == BlockToolResult {
+		line, _, _ := strings.Cut(block.Content, "\n")
+		return prefix + toolResultErrorStyle.Render(iconError+" "+block.ToolName+": ") + truncate(line, 80)
+	}
+	rendered := prefix + m.renderToolCallBlock(block)
+	if !block.ToolMeta.IsRunning && block.ToolMeta.Elapsed > 0 {
+		rendered += " " + toolElapsedStyle.Render("("+formatDuration(block.ToolMeta.Elapsed)+")")
+	}
+	return rendered
+}
+
 // renderToolResultBlock renders a tool execution result with elapsed time
 func (m Model) renderToolResultBlock(block ContentBlock) string {
 	// Collapsed view for non-error results
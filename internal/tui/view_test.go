@@ -5,6 +5,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/abdul-hamid-achik/vecai/internal/tools"
 )
 
 // stripANSI removes ANSI escape codes from a string for easier testing
@@ -393,3 +395,69 @@ func TestTruncateUTF8Safe(t *testing.T) {
 		})
 	}
 }
+
+func TestRenderBlock_SubagentTool(t *testing.T) {
+	streamChan := make(chan StreamMsg, 10)
+	model := NewModel("test", streamChan)
+
+	call := ContentBlock{
+		Type:     BlockToolCall,
+		ToolName: "grep",
+		Content:  "Grep: ServeHTTP",
+		ToolMeta: &ToolBlockMeta{ToolType: ToolCategoryRead, Elapsed: 300 * time.Millisecond, Subagent: "find handlers"},
+	}
+	plain := stripANSI(model.renderBlock(call))
+	if !strings.HasPrefix(plain, "    ↳ find handlers ›") || !strings.Contains(plain, "grep") || !strings.Contains(plain, "300ms") {
+		t.Errorf("Expected an indented call line with the task label and elapsed time, got %q", plain)
+	}
+
+	failed := ContentBlock{
+		Type:     BlockToolResult,
+		ToolName: "read_file",
+		Content:  "no such file\nmore detail",
+		IsError:  true,
+		ToolMeta: &ToolBlockMeta{Subagent: "find handlers"},
+	}
+	plain = stripANSI(model.renderBlock(failed))
+	if strings.Contains(plain, "\n") || !strings.Contains(plain, "read_file: no such file") {
+		t.Errorf("Expected a one-line failure under the task, got %q", plain)
+	}
+}
+
+func TestJobsMsg_StatusBarAndFinishedJobs(t *testing.T) {
+	streamChan := make(chan StreamMsg, 10)
+	model := NewModel("test", streamChan)
+	model.width = 200
+
+	started := time.Now().Add(-5 * time.Second)
+	running := []tools.JobInfo{
+		{ID: 1, Command: "npm run dev", State: tools.JobRunning, StartedAt: started},
+		{ID: 2, Command: "go test ./...", State: tools.JobRunning, StartedAt: started},
+	}
+	updated, _ := model.handleStreamMsg(NewJobsMsg(running))
+	model = updated.(Model)
+	if bar := stripANSI(model.renderStatusBar()); !strings.Contains(bar, "2 jobs running") {
+		t.Errorf("Expected the running job count in the status bar, got %q", bar)
+	}
+
+	finished := []tools.JobInfo{
+		running[0],
+		{ID: 2, Command: "go test ./...", State: tools.JobExited, ExitCode: 1, StartedAt: started, FinishedAt: time.Now()},
+	}
+	blocks := len(*model.blocks)
+	updated, _ = model.handleStreamMsg(NewJobsMsg(finished))
+	model = updated.(Model)
+	if len(*model.blocks) != blocks+1 || (*model.blocks)[blocks].Content != "Job 2 exited with code 1: go test ./..." {
+		t.Errorf("Expected one block for the finished job, got %+v", (*model.blocks)[blocks:])
+	}
+	if bar := stripANSI(model.renderStatusBar()); !strings.Contains(bar, "job 1: npm run dev (5.") {
+		t.Errorf("Expected the remaining job in the status bar, got %q", bar)
+	}
+
+	// Later updates don't report the same job again
+	updated, _ = model.handleStreamMsg(NewJobsMsg(finished))
+	model = updated.(Model)
+	if len(*model.blocks) != blocks+1 {
+		t.Errorf("Expected no block for a job that had already finished, got %d blocks", len(*model.blocks)-blocks)
+	}
+}
@@ -0,0 +1,213 @@
+// Package worktree runs agent tasks in a temporary git worktree on a scratch
+// branch so their changes can be reviewed, then merged, squashed or discarded.
+package worktree
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// BranchPrefix namespaces scratch branches created for isolated tasks.
+const BranchPrefix = "vecai/task-"
+
+// Worktree is a temporary checkout of a repository on its own scratch branch.
+type Worktree struct {
+	RepoDir string // Top level of the main working tree
+	Dir     string // Top level of the temporary checkout
+	Branch  string // Scratch branch checked out in Dir
+	Base    string // Commit the scratch branch started from
+	prefix  string // Working directory relative to RepoDir, "" at the top level
+}
+
+// IsRepo reports whether dir is inside a git working tree.
+func IsRepo(dir string) bool {
+	out, err := git(dir, "rev-parse", "--is-inside-work-tree")
+	return err == nil && out == "true"
+}
+
+// Create adds a worktree for the repository containing dir, on a new scratch
+// branch starting at HEAD. The checkout lives in a temporary directory.
+func Create(dir string) (*Worktree, error) {
+	repoDir, err := git(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, fmt.Errorf("not a git repository: %w", err)
+	}
+	prefix, err := git(dir, "rev-parse", "--show-prefix")
+	if err != nil {
+		return nil, err
+	}
+	base, err := git(repoDir, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("repository has no commits to branch from: %w", err)
+	}
+
+	id := make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("failed to generate branch name: %w", err)
+	}
+	branch := BranchPrefix + hex.EncodeToString(id)
+
+	tmp, err := os.MkdirTemp("", "vecai-worktree-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create worktree directory: %w", err)
+	}
+	// git refuses to add a worktree into an existing directory
+	wtDir := filepath.Join(tmp, filepath.Base(repoDir))
+	if _, err := git(repoDir, "worktree", "add", "-b", branch, wtDir, base); err != nil {
+		_ = os.RemoveAll(tmp)
+		return nil, fmt.Errorf("failed to add worktree: %w", err)
+	}
+
+	return &Worktree{
+		RepoDir: repoDir,
+		Dir:     wtDir,
+		Branch:  branch,
+		Base:    base,
+		prefix:  filepath.FromSlash(strings.TrimSuffix(prefix, "/")),
+	}, nil
+}
+
+// WorkDir returns the directory inside the worktree that corresponds to the
+// directory Create was called with.
+func (w *Worktree) WorkDir() string {
+	return filepath.Join(w.Dir, w.prefix)
+}
+
+// Dirty reports whether the main working tree has uncommitted changes, which
+// the worktree does not see since it starts from HEAD.
+func (w *Worktree) Dirty() bool {
+	out, err := git(w.RepoDir, "status", "--porcelain")
+	return err == nil && out != ""
+}
+
+// Diff returns the combined diff of everything the task changed relative to
+// the base commit, including new files and anything committed on the branch.
+func (w *Worktree) Diff() (string, error) {
+	if err := w.stage(); err != nil {
+		return "", err
+	}
+	return git(w.Dir, "diff", "--cached", "--no-color", w.Base)
+}
+
+// DiffStat returns a per-file summary of Diff.
+func (w *Worktree) DiffStat() (string, error) {
+	if err := w.stage(); err != nil {
+		return "", err
+	}
+	return git(w.Dir, "diff", "--cached", "--no-color", "--stat", w.Base)
+}
+
+// HasChanges reports whether the task changed anything relative to the base commit.
+func (w *Worktree) HasChanges() (bool, error) {
+	stat, err := w.DiffStat()
+	return stat != "", err
+}
+
+// Commit records all changes in the worktree on the scratch branch.
+// It returns false if there was nothing to commit.
+func (w *Worktree) Commit(message string) (bool, error) {
+	if err := w.stage(); err != nil {
+		return false, err
+	}
+	if _, err := git(w.Dir, "diff", "--cached", "--quiet"); err == nil {
+		return false, nil
+	}
+	if _, err := git(w.Dir, "commit", "--no-verify", "-m", message); err != nil {
+		return false, fmt.Errorf("failed to commit worktree changes: %w", err)
+	}
+	return true, nil
+}
+
+// Merge commits the task's changes and merges the scratch branch into the
+// main working tree's current branch, then removes the worktree.
+func (w *Worktree) Merge(message string) error {
+	if _, err := w.Commit(message); err != nil {
+		return err
+	}
+	if _, err := git(w.RepoDir, "merge", "--no-edit", w.Branch); err != nil {
+		_, _ = git(w.RepoDir, "merge", "--abort")
+		return fmt.Errorf("merge failed, worktree kept at %s: %w", w.Dir, err)
+	}
+	return w.remove()
+}
+
+// SquashApply stages the task's combined changes in the main working tree
+// without committing them, then removes the worktree.
+func (w *Worktree) SquashApply(message string) error {
+	if _, err := w.Commit(message); err != nil {
+		return err
+	}
+	if _, err := git(w.RepoDir, "merge", "--squash", w.Branch); err != nil {
+		return fmt.Errorf("squash failed, worktree kept at %s: %w", w.Dir, err)
+	}
+	return w.remove()
+}
+
+// Discard throws away the worktree and its scratch branch.
+func (w *Worktree) Discard() error {
+	return w.remove()
+}
+
+// Keep commits any pending changes and removes the checkout, leaving the
+// scratch branch for the user to merge later. It reports whether the branch
+// was kept; a branch without changes is deleted.
+func (w *Worktree) Keep(message string) (bool, error) {
+	changed, err := w.HasChanges()
+	if err != nil {
+		return false, err
+	}
+	if !changed {
+		return false, w.remove()
+	}
+	if _, err := w.Commit(message); err != nil {
+		return false, err
+	}
+	if _, err := git(w.RepoDir, "worktree", "remove", "--force", w.Dir); err != nil {
+		return false, fmt.Errorf("failed to remove worktree: %w", err)
+	}
+	_ = os.RemoveAll(filepath.Dir(w.Dir))
+	return true, nil
+}
+
+// stage adds every change, including untracked files, to the worktree's index.
+func (w *Worktree) stage() error {
+	if _, err := git(w.Dir, "add", "-A"); err != nil {
+		return fmt.Errorf("failed to stage worktree changes: %w", err)
+	}
+	return nil
+}
+
+// remove deletes the checkout and the scratch branch.
+func (w *Worktree) remove() error {
+	if _, err := git(w.RepoDir, "worktree", "remove", "--force", w.Dir); err != nil {
+		return fmt.Errorf("failed to remove worktree: %w", err)
+	}
+	_ = os.RemoveAll(filepath.Dir(w.Dir))
+	if _, err := git(w.RepoDir, "branch", "-D", w.Branch); err != nil {
+		return fmt.Errorf("failed to delete branch %s: %w", w.Branch, err)
+	}
+	return nil
+}
+
+// git runs a git command in dir and returns its trimmed stdout.
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			return "", fmt.Errorf("git %s: %w", args[0], err)
+		}
+		return "", fmt.Errorf("git %s: %s", args[0], msg)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,168 @@
+package worktree
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// initRepo creates a throwaway repository with one committed file.
+func initRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"config", "user.email", "test@example.com"},
+		{"config", "user.name", "Test"},
+		{"config", "commit.gpgsign", "false"},
+	} {
+		runGit(t, dir, args...)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "pkg"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, "pkg", "a.txt"), "one\n")
+	runGit(t, dir, "add", "-A")
+	runGit(t, dir, "commit", "-q", "-m", "initial")
+	return dir
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	out, err := git(dir, args...)
+	if err != nil {
+		t.Fatalf("git %v: %v", args, err)
+	}
+	return out
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// createWithChanges starts a worktree from the repo's pkg/ directory and
+// edits a tracked file and adds an untracked one.
+func createWithChanges(t *testing.T, repo string) *Worktree {
+	t.Helper()
+	wt, err := Create(filepath.Join(repo, "pkg"))
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	if !strings.HasSuffix(wt.WorkDir(), filepath.Join(filepath.Base(wt.Dir), "pkg")) {
+		t.Fatalf("unexpected work dir %s", wt.WorkDir())
+	}
+	writeFile(t, filepath.Join(wt.WorkDir(), "a.txt"), "two\n")
+	writeFile(t, filepath.Join(wt.WorkDir(), "b.txt"), "new\n")
+	return wt
+}
+
+func TestWorktree_DiffIsolatesChanges(t *testing.T) {
+	repo := initRepo(t)
+	wt := createWithChanges(t, repo)
+	defer func() { _ = wt.Discard() }()
+
+	if got := readFile(t, filepath.Join(repo, "pkg", "a.txt")); got != "one\n" {
+		t.Errorf("main tree modified by worktree edit: %q", got)
+	}
+	diff, err := wt.Diff()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"-one", "+two", "b/pkg/b.txt", "+new"} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("expected %q in diff:\n%s", want, diff)
+		}
+	}
+}
+
+func TestWorktree_Merge(t *testing.T) {
+	repo := initRepo(t)
+	wt := createWithChanges(t, repo)
+
+	if err := wt.Merge("task"); err != nil {
+		t.Fatalf("merge failed: %v", err)
+	}
+	if got := readFile(t, filepath.Join(repo, "pkg", "a.txt")); got != "two\n" {
+		t.Errorf("merge did not update a.txt: %q", got)
+	}
+	if got := runGit(t, repo, "log", "-1", "--format=%s"); got != "task" {
+		t.Errorf("expected task commit on main, got %q", got)
+	}
+	if _, err := os.Stat(wt.Dir); !os.IsNotExist(err) {
+		t.Errorf("expected worktree removed, stat err = %v", err)
+	}
+	if out := runGit(t, repo, "branch", "--list", wt.Branch); out != "" {
+		t.Errorf("expected scratch branch deleted, got %q", out)
+	}
+}
+
+func TestWorktree_SquashApply(t *testing.T) {
+	repo := initRepo(t)
+	wt := createWithChanges(t, repo)
+
+	if err := wt.SquashApply("task"); err != nil {
+		t.Fatalf("squash failed: %v", err)
+	}
+	if got := readFile(t, filepath.Join(repo, "pkg", "b.txt")); got != "new\n" {
+		t.Errorf("squash did not add b.txt: %q", got)
+	}
+	if got := runGit(t, repo, "log", "-1", "--format=%s"); got != "initial" {
+		t.Errorf("squash should not commit, HEAD is %q", got)
+	}
+	if status := runGit(t, repo, "status", "--porcelain"); !strings.Contains(status, "M  pkg/a.txt") || !strings.Contains(status, "A  pkg/b.txt") {
+		t.Errorf("expected staged changes, got:\n%s", status)
+	}
+}
+
+func TestWorktree_DiscardAndKeep(t *testing.T) {
+	repo := initRepo(t)
+
+	wt := createWithChanges(t, repo)
+	if err := wt.Discard(); err != nil {
+		t.Fatalf("discard failed: %v", err)
+	}
+	if status := runGit(t, repo, "status", "--porcelain"); status != "" {
+		t.Errorf("discard touched the main tree:\n%s", status)
+	}
+	if out := runGit(t, repo, "branch", "--list", wt.Branch); out != "" {
+		t.Errorf("expected scratch branch deleted, got %q", out)
+	}
+
+	wt = createWithChanges(t, repo)
+	kept, err := wt.Keep("task")
+	if err != nil || !kept {
+		t.Fatalf("keep = %v, %v", kept, err)
+	}
+	if out := runGit(t, repo, "show", wt.Branch+":pkg/b.txt"); out != "new" {
+		t.Errorf("expected changes committed on %s, got %q", wt.Branch, out)
+	}
+
+	// A worktree without changes leaves nothing behind
+	empty, err := Create(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kept, err := empty.Keep("task"); err != nil || kept {
+		t.Errorf("keep of unchanged worktree = %v, %v", kept, err)
+	}
+	if out := runGit(t, repo, "branch", "--list", empty.Branch); out != "" {
+		t.Errorf("expected unchanged branch deleted, got %q", out)
+	}
+}